@@ -1,40 +1,90 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
-	"github.com/rs/cors"
 
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/audit"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/blob"
 	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/config"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/filecheck"
 	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/grpcclient"
 	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/handler"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/kbimport"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/logging"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/mail"
 	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/middleware"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/outhook"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/search"
 	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/stream"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/upload"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/webhookq"
 )
 
 func main() {
-	cfg := config.Load()
+	configPath := flag.String("config", os.Getenv(config.PathEnv), "path to a YAML or TOML config file")
+	printConfig := flag.Bool("print-config", false, "print the effective config (secrets redacted) and exit")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+	if *printConfig {
+		if err := cfg.Dump(os.Stdout); err != nil {
+			log.Fatalf("print config: %v", err)
+		}
+		return
+	}
+	logger := logging.New(cfg.Log.Format, cfg.Log.Level, os.Stdout)
+	slog.SetDefault(logger)
+	logger.Info("effective config", slog.String("path", *configPath), slog.Any("config", cfg.Redacted()))
 
 	// M3: Warn (or fatally reject) when default/insecure secrets are in use
-	warnings := cfg.Validate()
+	warnings := cfg.Warnings()
 	if os.Getenv("GO_ENV") == "production" && len(warnings) > 0 {
 		for _, w := range warnings {
-			log.Printf("FATAL: %s", w)
+			logger.Error("insecure configuration", slog.String("warning", w))
 		}
-		log.Fatalf("Cannot start with insecure default secrets in production. Set GO_ENV to something other than 'production' for development.")
+		fatal(logger, "Cannot start with insecure default secrets in production. Set GO_ENV to something other than 'production' for development.", nil)
 	}
 	for _, w := range warnings {
-		log.Printf("WARNING: %s", w)
+		logger.Warn("insecure configuration", slog.String("warning", w))
+	}
+
+	// SIGINT/SIGTERM stop the server gracefully: in-flight requests finish,
+	// background workers stop, and the deferred Closes flush queued audit
+	// entries, webhook and outgoing delivery state and usage reports.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// Workers run until ctx ends and are waited for before the deferred
+	// Closes, so no delivery writes to a closed queue.
+	var workers sync.WaitGroup
+	background := func(run func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(ctx)
+		}()
 	}
 
-	clients, err := grpcclient.New(cfg.GRPCAddr)
+	clients, err := grpcclient.New(cfg.Server.GRPCAddr)
 	if err != nil {
-		log.Fatalf("failed to connect to gRPC service: %v", err)
+		fatal(logger, "failed to connect to gRPC service", err)
 	}
 	defer clients.Close()
 
@@ -42,7 +92,7 @@ func main() {
 
 	r.Use(chimiddleware.RequestID)
 	r.Use(chimiddleware.RealIP)
-	r.Use(chimiddleware.Logger)
+	r.Use(logging.Middleware(logger, logging.NewSampler(logging.ParseSampleRates(cfg.Log.SampleRoutes))))
 	r.Use(chimiddleware.Recoverer)
 	// Global request body size limit (10MB). File upload endpoints override with ParseMultipartForm.
 	r.Use(func(next http.Handler) http.Handler {
@@ -51,51 +101,214 @@ func main() {
 			next.ServeHTTP(w, r)
 		})
 	})
-	r.Use(cors.New(cors.Options{
-		AllowedOrigins:   cfg.AllowedOrigins,
-		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Authorization", "Content-Type", "Idempotency-Key", "X-Request-ID", "X-Runtime-Secret"},
-		AllowCredentials: true,
-	}).Handler)
+	corsHandler := middleware.NewCORS(cfg.CORS.Origins())
+	r.Use(corsHandler.Handler)
 
 	authHandler := handler.NewAuthHandler(clients)
 	chatHandler := handler.NewChatHandler(clients)
 	orgHandler := handler.NewOrgHandler(clients)
 	wsHandler := handler.NewWorkspaceHandler(clients)
 	settingsHandler := handler.NewSettingsHandler(clients)
-	toolsHandler := handler.NewToolsHandler(clients)
+	blobStore, err := blob.New(blob.Options{
+		Backend:  cfg.Blob.Backend,
+		LocalDir: cfg.Blob.LocalDir,
+		S3: blob.S3Options{
+			Endpoint:  cfg.Blob.S3.Endpoint,
+			Region:    cfg.Blob.S3.Region,
+			Bucket:    cfg.Blob.S3.Bucket,
+			AccessKey: cfg.Blob.S3.AccessKey,
+			SecretKey: cfg.Blob.S3.SecretKey,
+			Prefix:    cfg.Blob.S3.Prefix,
+			PathStyle: cfg.Blob.S3.PathStyle,
+			Timeout:   time.Duration(cfg.Blob.S3.TimeoutMs) * time.Millisecond,
+		},
+	})
+	if err != nil {
+		fatal(logger, "failed to open blob store", err)
+	}
+	var uploadScanner filecheck.Scanner
+	if strings.EqualFold(cfg.Uploads.Scan.Backend, "clamd") {
+		uploadScanner, err = filecheck.NewClamdScanner(cfg.Uploads.Scan.ClamdAddr, time.Duration(cfg.Uploads.Scan.TimeoutMs)*time.Millisecond)
+		if err != nil {
+			fatal(logger, "failed to configure upload scanner", err)
+		}
+	}
+	kbIntake, err := handler.NewKnowledgeIntake(clients, blobStore, handler.KnowledgeIntakeOptions{
+		AllowedTypes: cfg.Uploads.AllowedTypes,
+		MaxFileBytes: int64(cfg.Uploads.MaxFileBytes),
+		Scanner:      uploadScanner,
+		Quarantine:   strings.EqualFold(cfg.Uploads.Scan.OnInfected, "quarantine"),
+		FailOpen:     cfg.Uploads.Scan.FailOpen,
+	})
+	if err != nil {
+		fatal(logger, "invalid upload settings", err)
+	}
+	toolsHandler := handler.NewToolsHandler(clients, kbIntake)
+	blobsHandler := handler.NewBlobsHandler(blobStore, cfg.Auth.RuntimeSecret)
 	pluginHandler := handler.NewPluginHandler(clients)
-	channelsHandler := handler.NewChannelsHandler(clients, cfg.RuntimeSecret)
-	runtimeToolsHandler := handler.NewRuntimeToolsHandler(handler.RuntimeToolsHandlerOptions{
-		RuntimeSecret:      cfg.RuntimeSecret,
-		DefaultProvider:    cfg.WebSearchProvider,
-		TimeoutMs:          cfg.WebSearchTimeoutMs,
-		DuckDuckGoEndpoint: cfg.WebSearchDuckEndpoint,
-		BraveEndpoint:      cfg.WebSearchBraveEndpoint,
-		BraveAPIKey:        cfg.WebSearchBraveAPIKey,
-		SearxngEndpoint:    cfg.WebSearchSearxngEndpoint,
-		SearxngAPIKey:      cfg.WebSearchSearxngAPIKey,
-		SerpAPIEndpoint:    cfg.WebSearchSerpAPIEndpoint,
-		SerpAPIKey:         cfg.WebSearchSerpAPIKey,
+	channelsHandler := handler.NewChannelsHandler(clients, cfg.Auth.RuntimeSecret, blobStore)
+	webhookQueue, err := webhookq.Open(webhookq.Options{
+		Dir:                cfg.Webhooks.Dir,
+		Sync:               cfg.Webhooks.Sync,
+		MaxEvents:          cfg.Webhooks.MaxEvents,
+		MaxUnverified:      cfg.Webhooks.MaxUnverified,
+		MaxUnverifiedBytes: int64(cfg.Webhooks.MaxUnverifiedBytes),
+		DedupeWindow:       time.Duration(cfg.Webhooks.DedupeWindowMs) * time.Millisecond,
+		MaxAttempts:        cfg.Webhooks.MaxAttempts,
+		BaseDelay:          time.Duration(cfg.Webhooks.RetryBaseMs) * time.Millisecond,
+		MaxDelay:           time.Duration(cfg.Webhooks.RetryMaxMs) * time.Millisecond,
+		Concurrency:        cfg.Webhooks.Concurrency,
+		OnDone:             handler.ReleaseEmailAttachments(blobStore),
+	})
+	if err != nil {
+		fatal(logger, "failed to open webhook queue", err)
+	}
+	defer webhookQueue.Close()
+	webhooksHandler := handler.NewWebhooksHandler(clients, webhookQueue,
+		time.Duration(cfg.Webhooks.VerifyTimeoutMs)*time.Millisecond,
+		time.Duration(cfg.Webhooks.DeliverTimeoutMs)*time.Millisecond)
+	background(func(ctx context.Context) { webhookQueue.Run(ctx, webhooksHandler.Deliver) })
+	outgoingQueue, err := outhook.Open(outhook.Options{
+		Dir:            cfg.Outgoing.Dir,
+		Sync:           cfg.Outgoing.Sync,
+		MaxPending:     cfg.Outgoing.MaxPending,
+		MaxAttempts:    cfg.Outgoing.MaxAttempts,
+		BaseDelay:      time.Duration(cfg.Outgoing.RetryBaseMs) * time.Millisecond,
+		MaxDelay:       time.Duration(cfg.Outgoing.RetryMaxMs) * time.Millisecond,
+		Concurrency:    cfg.Outgoing.Concurrency,
+		Timeout:        time.Duration(cfg.Outgoing.TimeoutMs) * time.Millisecond,
+		KeepPerChannel: cfg.Outgoing.KeepPerChannel,
+		Retention:      time.Duration(cfg.Outgoing.RetentionMs) * time.Millisecond,
+		AllowPrivate:   cfg.Outgoing.AllowPrivateTargets,
+	})
+	if err != nil {
+		fatal(logger, "failed to open outgoing webhook queue", err)
+	}
+	defer outgoingQueue.Close()
+	outgoingWebhooksHandler := handler.NewOutgoingWebhooksHandler(clients, outgoingQueue, cfg.Auth.RuntimeSecret)
+	background(func(ctx context.Context) { outgoingQueue.Run(ctx, outgoingWebhooksHandler.Resolve) })
+	emailThreads, err := mail.OpenThreads(filepath.Join(cfg.Email.Dir, "threads.json"), cfg.Email.MaxThreads)
+	if err != nil {
+		fatal(logger, "failed to open email thread store", err)
+	}
+	defer emailThreads.Close()
+	emailHandler := handler.NewEmailHandler(clients, webhookQueue, blobStore, cfg.Auth.RuntimeSecret, handler.EmailOptions{
+		Dialer: mail.Dialer{
+			Timeout:      time.Duration(cfg.Email.TimeoutMs) * time.Millisecond,
+			AllowPrivate: cfg.Email.AllowPrivateHosts,
+		},
+		Threads:         emailThreads,
+		PollInterval:    time.Duration(cfg.Email.PollIntervalMs) * time.Millisecond,
+		MaxPerPoll:      cfg.Email.MaxPerPoll,
+		MaxMessageBytes: int64(cfg.Email.MaxMessageBytes),
+	})
+	background(emailHandler.Run)
+	searchUsageReporter := search.NewUsageReporter(handler.PluginUsageSink(clients.Chat), 0)
+	defer searchUsageReporter.Close()
+	runtimeToolsHandlerOptions := runtimeToolsOptions(cfg, clients)
+	runtimeToolsHandlerOptions.UsageReporter = searchUsageReporter
+	runtimeToolsHandler := handler.NewRuntimeToolsHandler(runtimeToolsHandlerOptions)
+	schedulerHandler := handler.NewSchedulerHandler(clients, stream.RuntimeProxy(cfg.Proxy.RuntimeAddr), cfg.Auth.RuntimeSecret)
+	authorizer := middleware.NewAuthorizer(clients.Org, time.Duration(cfg.Authz.CacheTTLMs)*time.Millisecond)
+	authorizer.ResolveResources(handler.ResourceWorkspaceResolver(clients), handler.ResourceParams...)
+	adminOnly := authorizer.Require(middleware.RoleAdmin)
+
+	auditSink, err := audit.NewSink(cfg.Audit.Sink, cfg.Audit.LogPath, audit.JSONLOptions{
+		MaxBytes:   int64(cfg.Audit.MaxBytes),
+		MaxBackups: cfg.Audit.MaxBackups,
+	}, clients.Org)
+	if err != nil {
+		fatal(logger, "failed to open audit sink", err)
+	}
+	var auditRecorder *audit.Recorder
+	if auditSink != nil {
+		auditRecorder = audit.NewRecorder(auditSink, 0)
+		defer auditRecorder.Close()
+	}
+	auditHandler := handler.NewAuditHandler(auditSink)
+	uploadStore, err := upload.NewStore(upload.Options{
+		Dir:           cfg.Uploads.Dir,
+		MaxFileBytes:  int64(cfg.Uploads.MaxFileBytes),
+		MaxChunkBytes: int64(cfg.Uploads.MaxChunkBytes),
+		TTL:           time.Duration(cfg.Uploads.SessionTTLMs) * time.Millisecond,
+	})
+	if err != nil {
+		fatal(logger, "failed to open upload store", err)
+	}
+	background(func(ctx context.Context) {
+		uploadStore.RunCollector(ctx, time.Duration(cfg.Uploads.GCIntervalMs)*time.Millisecond)
+	})
+	kbUploadsHandler := handler.NewKnowledgeUploadsHandler(clients, uploadStore, kbIntake)
+	kbImportsHandler, err := handler.NewKnowledgeImportsHandler(clients, kbIntake, uploadStore,
+		kbimport.NewJobs(time.Duration(cfg.Imports.JobTTLMs)*time.Millisecond),
+		handler.KnowledgeImportsOptions{
+			Dir:         cfg.Imports.Dir,
+			Concurrency: cfg.Imports.Concurrency,
+			Limits: kbimport.Limits{
+				MaxEntries:    cfg.Imports.MaxEntries,
+				MaxEntryBytes: int64(cfg.Uploads.MaxFileBytes),
+				MaxTotalBytes: int64(cfg.Imports.MaxTotalBytes),
+				MaxRatio:      int64(cfg.Imports.MaxRatio),
+			},
+			MaxURLs: cfg.Imports.MaxURLs,
+			Fetcher: kbimport.NewFetcher(time.Duration(cfg.Imports.URLTimeoutMs)*time.Millisecond, int64(cfg.Uploads.MaxFileBytes), cfg.Imports.AllowPrivateURLs),
+		})
+	if err != nil {
+		fatal(logger, "failed to open import dir", err)
+	}
+	idempotent := middleware.NewIdempotency(middleware.IdempotencyOptions{
+		Window:           time.Duration(cfg.Idempotency.WindowMs) * time.Millisecond,
+		MaxEntries:       cfg.Idempotency.MaxEntries,
+		MaxBytes:         int64(cfg.Idempotency.MaxBytes),
+		MaxResponseBytes: cfg.Idempotency.MaxResponseBytes,
+	}).Middleware
+	rateLimiter := middleware.NewRateLimiter(cfg.RateLimit.RequestsPerMinute, cfg.RateLimit.Burst)
+
+	// SIGHUP re-reads the config file; only CORS origins, rate limits and
+	// search settings are applied live.
+	reloader := config.NewReloader(*configPath, cfg)
+	reloader.OnReload(func(next *config.Config) {
+		corsHandler.SetOrigins(next.CORS.Origins())
+		rateLimiter.SetLimits(next.RateLimit.RequestsPerMinute, next.RateLimit.Burst)
+		runtimeToolsHandler.Reconfigure(runtimeToolsOptions(next, clients))
 	})
-	schedulerHandler := handler.NewSchedulerHandler(clients)
+	reloader.WatchSignals(context.Background())
 
 	// ── Public ────────────────────────────────────────────────────────────────
 	r.Post("/auth/login", authHandler.Login)
 	r.Post("/auth/signup", authHandler.Signup)
 	r.Post("/auth/refresh", authHandler.Refresh)
 
-	// Public webhook endpoint (signature verified in TS)
-	r.Post("/webhooks/{channelId}", channelsHandler.HandleWebhook)
+	// Public webhook endpoint (signature verified in TS, delivery queued)
+	r.Post("/webhooks/{channelId}", webhooksHandler.HandleWebhook)
+
+	// Public scheduler trigger (per-task token verified in TS)
+	r.Post("/triggers/{taskId}", schedulerHandler.FireTrigger)
 
 	// Public runtime endpoint (X-Runtime-Secret auth, no user JWT)
 	r.Post("/channels/{channelId}/send", channelsHandler.SendChannelMessage)
+	r.Patch("/channels/{channelId}/sent/{messageId}", channelsHandler.UpdateChannelMessage)
+	r.Delete("/channels/{channelId}/sent/{messageId}", channelsHandler.DeleteChannelMessage)
 	r.Post("/internal/tools/web-search", runtimeToolsHandler.WebSearch)
+	r.Get("/internal/tools/web-search/usage", runtimeToolsHandler.WebSearchUsage)
+	r.Post("/internal/events", outgoingWebhooksHandler.PublishEvent)
+	r.Post("/internal/email/send", emailHandler.Send)
+	r.Post("/internal/email/test", emailHandler.Test)
+	r.Get("/internal/blobs", blobsHandler.GetBlob)
+	r.Delete("/internal/blobs", blobsHandler.DeleteBlob)
 
 	// ── Protected ─────────────────────────────────────────────────────────────
 	r.Group(func(r chi.Router) {
-		r.Use(middleware.Auth(cfg.JWTSecret))
+		r.Use(middleware.Auth(cfg.Auth.JWTSecret))
+		r.Use(rateLimiter.Middleware)
 		r.Use(chimiddleware.Timeout(30 * time.Second))
+		if auditRecorder != nil {
+			r.Use(audit.Middleware(auditRecorder, handler.AuditScopeResolver(authorizer)))
+		}
+		// Membership of {orgId}/{wsId}, or of the workspace owning a
+		// {sessionId}, {agentId}, {channelId}, {kbId}, {workflowId} or
+		// {taskId}, is checked here.
+		r.Use(authorizer.Middleware)
 
 		// Auth
 		r.Post("/auth/logout", authHandler.Logout)
@@ -104,7 +317,7 @@ func main() {
 		// Orgs
 		r.Get("/orgs", orgHandler.ListOrgs)
 		r.Get("/orgs/{orgId}", orgHandler.GetOrg)
-		r.Patch("/orgs/{orgId}", orgHandler.UpdateOrg)
+		r.With(adminOnly).Patch("/orgs/{orgId}", orgHandler.UpdateOrg)
 		r.Get("/orgs/{orgId}/members", orgHandler.ListMembers)
 		r.Get("/orgs/{orgId}/workspaces", orgHandler.ListWorkspaces)
 		r.Get("/orgs/{orgId}/dashboard/stats", orgHandler.GetDashboardStats)
@@ -117,12 +330,13 @@ func main() {
 		r.Get("/orgs/{orgId}/usage/providers", orgHandler.GetUsageProviders)
 		r.Get("/orgs/{orgId}/usage/agent-ranking", orgHandler.GetUsageAgentRanking)
 		r.Get("/orgs/{orgId}/usage/records", orgHandler.ListUsageRecords)
+		r.With(adminOnly).Get("/orgs/{orgId}/audit-logs", auditHandler.ListOrgAuditEntries)
 
 		// Workspaces
 		r.Post("/workspaces", wsHandler.CreateWorkspace)
 		r.Get("/workspaces/{wsId}", wsHandler.GetWorkspace)
 		r.Patch("/workspaces/{wsId}", wsHandler.UpdateWorkspace)
-		r.Delete("/workspaces/{wsId}", wsHandler.DeleteWorkspace)
+		r.With(adminOnly).Delete("/workspaces/{wsId}", wsHandler.DeleteWorkspace)
 
 		// Plugins — marketplace + installed
 		r.Get("/plugins/marketplace", pluginHandler.ListMarketplace)
@@ -138,18 +352,18 @@ func main() {
 
 		// Settings — providers (match frontend: /workspaces/:wsId/providers/*)
 		r.Get("/workspaces/{wsId}/settings", settingsHandler.GetWorkspaceSettings)
-		r.Patch("/workspaces/{wsId}/settings", settingsHandler.UpdateWorkspaceSettings)
+		r.With(adminOnly).Patch("/workspaces/{wsId}/settings", settingsHandler.UpdateWorkspaceSettings)
 		r.Get("/workspaces/{wsId}/providers", settingsHandler.ListProviders)
-		r.Post("/workspaces/{wsId}/providers", settingsHandler.CreateProvider)
-		r.Patch("/workspaces/{wsId}/providers/{providerId}", settingsHandler.UpdateProvider)
-		r.Delete("/workspaces/{wsId}/providers/{providerId}", settingsHandler.DeleteProvider)
+		r.With(adminOnly).Post("/workspaces/{wsId}/providers", settingsHandler.CreateProvider)
+		r.With(adminOnly).Patch("/workspaces/{wsId}/providers/{providerId}", settingsHandler.UpdateProvider)
+		r.With(adminOnly).Delete("/workspaces/{wsId}/providers/{providerId}", settingsHandler.DeleteProvider)
 		r.Post("/workspaces/{wsId}/providers/{providerId}/test", settingsHandler.TestProvider)
 		r.Get("/workspaces/{wsId}/runtime/providers", settingsHandler.ListProviders)
-		r.Post("/workspaces/{wsId}/runtime/providers/custom", settingsHandler.CreateProvider)
-		r.Patch("/workspaces/{wsId}/runtime/providers/custom/{providerId}", settingsHandler.UpdateProvider)
-		r.Delete("/workspaces/{wsId}/runtime/providers/custom/{providerId}", settingsHandler.DeleteProvider)
-		r.Put("/workspaces/{wsId}/runtime/providers/{providerId}/override", settingsHandler.UpdateProvider)
-		r.Delete("/workspaces/{wsId}/runtime/providers/{providerId}/override", settingsHandler.DeleteProvider)
+		r.With(adminOnly).Post("/workspaces/{wsId}/runtime/providers/custom", settingsHandler.CreateProvider)
+		r.With(adminOnly).Patch("/workspaces/{wsId}/runtime/providers/custom/{providerId}", settingsHandler.UpdateProvider)
+		r.With(adminOnly).Delete("/workspaces/{wsId}/runtime/providers/custom/{providerId}", settingsHandler.DeleteProvider)
+		r.With(adminOnly).Put("/workspaces/{wsId}/runtime/providers/{providerId}/override", settingsHandler.UpdateProvider)
+		r.With(adminOnly).Delete("/workspaces/{wsId}/runtime/providers/{providerId}/override", settingsHandler.DeleteProvider)
 		r.Post("/workspaces/{wsId}/runtime/providers/{providerId}/test", settingsHandler.TestProvider)
 
 		// Settings — models (match frontend: /workspaces/:wsId/providers/:id/models/*)
@@ -169,26 +383,26 @@ func main() {
 
 		// Settings — API keys (match frontend: /workspaces/:wsId/api-keys)
 		r.Get("/workspaces/{wsId}/api-keys", settingsHandler.ListApiKeys)
-		r.Post("/workspaces/{wsId}/api-keys", settingsHandler.CreateApiKey)
-		r.Delete("/workspaces/{wsId}/api-keys/{keyId}", settingsHandler.DeleteApiKey)
+		r.With(adminOnly).Post("/workspaces/{wsId}/api-keys", settingsHandler.CreateApiKey)
+		r.With(adminOnly).Delete("/workspaces/{wsId}/api-keys/{keyId}", settingsHandler.DeleteApiKey)
 
 		// Chat — sessions
 		r.Get("/workspaces/{wsId}/sessions", chatHandler.ListSessions)
 		r.Get("/workspaces/{wsId}/runtime/metrics", chatHandler.GetRuntimeMetrics)
 		r.Get("/workspaces/{wsId}/usage/records", chatHandler.ListUsageRecords)
-		r.Post("/workspaces/{wsId}/plugin-usage/events", chatHandler.ReportPluginUsageEvents)
-		r.Post("/workspaces/{wsId}/sessions", chatHandler.CreateSession)
+		r.With(idempotent).Post("/workspaces/{wsId}/plugin-usage/events", chatHandler.ReportPluginUsageEvents)
+		r.With(idempotent).Post("/workspaces/{wsId}/sessions", chatHandler.CreateSession)
 		r.Patch("/sessions/{sessionId}", chatHandler.UpdateSession)
 		r.Delete("/sessions/{sessionId}", chatHandler.DeleteSession)
 
 		// Chat — messages
 		r.Get("/sessions/{sessionId}/messages", chatHandler.ListMessages)
-		r.Post("/sessions/{sessionId}/messages", chatHandler.SaveUserMessage)
+		r.With(idempotent).Post("/sessions/{sessionId}/messages", chatHandler.SaveUserMessage)
 		r.Patch("/sessions/{sessionId}/messages/{messageId}", chatHandler.UpdateUserMessage)
 
 		// Chat — agents
 		r.Get("/workspaces/{wsId}/agents", chatHandler.ListAgents)
-		r.Post("/workspaces/{wsId}/agents", chatHandler.CreateAgent)
+		r.With(idempotent).Post("/workspaces/{wsId}/agents", chatHandler.CreateAgent)
 		r.Get("/agents/{agentId}", chatHandler.GetAgent)
 		r.Patch("/agents/{agentId}", chatHandler.UpdateAgent)
 		r.Delete("/agents/{agentId}", chatHandler.DeleteAgent)
@@ -214,6 +428,11 @@ func main() {
 		r.Get("/knowledge-bases/{kbId}/documents", toolsHandler.ListKnowledgeBaseDocuments)
 		r.Post("/knowledge-bases/{kbId}/documents", toolsHandler.CreateKnowledgeBaseDocument)
 		r.Delete("/knowledge-bases/{kbId}/documents/{docId}", toolsHandler.DeleteKnowledgeBaseDocument)
+		r.With(idempotent).Post("/knowledge-bases/{kbId}/uploads", kbUploadsHandler.CreateUpload)
+		r.Get("/knowledge-bases/{kbId}/uploads/{uploadId}", kbUploadsHandler.GetUpload)
+		r.Put("/knowledge-bases/{kbId}/uploads/{uploadId}", kbUploadsHandler.PutChunk)
+		r.Delete("/knowledge-bases/{kbId}/uploads/{uploadId}", kbUploadsHandler.DeleteUpload)
+		r.Get("/knowledge-bases/{kbId}/imports/{jobId}", kbImportsHandler.GetImport)
 		r.Post("/knowledge-bases/{kbId}/search", toolsHandler.SearchKnowledgeBase)
 
 		// Channels — workspace-scoped (list + create)
@@ -225,22 +444,63 @@ func main() {
 		r.Get("/channels/{channelId}", channelsHandler.GetChannel)
 		r.Patch("/channels/{channelId}", channelsHandler.UpdateChannel)
 		r.Delete("/channels/{channelId}", channelsHandler.DeleteChannel)
-		r.Get("/channels/{channelId}/messages", channelsHandler.ListChannelMessages)
 		r.Get("/channels/{channelId}/rules", channelsHandler.ListRoutingRules)
 		r.Post("/channels/{channelId}/rules", channelsHandler.CreateRoutingRule)
+		r.Post("/channels/{channelId}/rules/simulate", channelsHandler.SimulateRoutingRules)
 		r.Patch("/channels/{channelId}/rules/{ruleId}", channelsHandler.UpdateRoutingRule)
 		r.Delete("/channels/{channelId}/rules/{ruleId}", channelsHandler.DeleteRoutingRule)
+		r.Get("/channels/{channelId}/webhook-dead-letters", webhooksHandler.ListDeadLetters)
+		r.Post("/channels/{channelId}/webhook-dead-letters/{eventId}/retry", webhooksHandler.RetryDeadLetter)
+		r.Delete("/channels/{channelId}/webhook-dead-letters/{eventId}", webhooksHandler.DeleteDeadLetter)
+		r.Get("/channels/{channelId}/deliveries", outgoingWebhooksHandler.ListDeliveries)
+		r.Post("/channels/{channelId}/deliveries/ping", outgoingWebhooksHandler.Ping)
+		r.Get("/channels/{channelId}/deliveries/{deliveryId}", outgoingWebhooksHandler.GetDelivery)
+		r.Post("/channels/{channelId}/deliveries/{deliveryId}/redeliver", outgoingWebhooksHandler.Redeliver)
 
 		// Scheduler
 		r.Get("/workspaces/{wsId}/scheduler/tasks", schedulerHandler.ListTasks)
 		r.Post("/workspaces/{wsId}/scheduler/tasks", schedulerHandler.CreateTask)
 		r.Patch("/workspaces/{wsId}/scheduler/tasks/{taskId}", schedulerHandler.UpdateTask)
 		r.Delete("/workspaces/{wsId}/scheduler/tasks/{taskId}", schedulerHandler.DeleteTask)
-		r.Post("/workspaces/{wsId}/scheduler/tasks/{taskId}/run", schedulerHandler.RunTask)
+		r.With(idempotent).Post("/workspaces/{wsId}/scheduler/tasks/{taskId}/run", schedulerHandler.RunTask)
 		r.Get("/workspaces/{wsId}/scheduler/tasks/{taskId}/executions", schedulerHandler.ListExecutions)
+		r.Get("/workspaces/{wsId}/scheduler/tasks/{taskId}/executions/{executionId}", schedulerHandler.GetExecution)
+		r.Post("/workspaces/{wsId}/scheduler/tasks/{taskId}/trigger-token", schedulerHandler.RotateTriggerToken)
+		r.Post("/workspaces/{wsId}/scheduler/tasks/{taskId}/pause", schedulerHandler.PauseTask)
+		r.Post("/workspaces/{wsId}/scheduler/tasks/{taskId}/resume", schedulerHandler.ResumeTask)
+		r.With(idempotent).Post("/workspaces/{wsId}/scheduler/tasks/{taskId}/backfill", schedulerHandler.Backfill)
+		r.Get("/scheduler/preview", schedulerHandler.Preview)
 
 		// LLM proxy → Bifrost sidecar
-		r.Handle("/v1/*", stream.BifrostProxy(cfg.BifrostAddr))
+		r.Handle("/v1/*", stream.BifrostProxy(cfg.Proxy.BifrostAddr))
+	})
+
+	// ── Protected long requests ───────────────────────────────────────────────
+	// Same middleware as above with a longer timeout, for requests that take
+	// in a large body or hand work to the background: reading an import
+	// archive, starting an upload's finalization.
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Auth(cfg.Auth.JWTSecret))
+		r.Use(rateLimiter.Middleware)
+		r.Use(chimiddleware.Timeout(longRequestTimeout))
+		if auditRecorder != nil {
+			r.Use(audit.Middleware(auditRecorder, handler.AuditScopeResolver(authorizer)))
+		}
+		r.Use(authorizer.Middleware)
+		r.With(idempotent).Post("/knowledge-bases/{kbId}/uploads/{uploadId}/complete", kbUploadsHandler.CompleteUpload)
+		r.With(idempotent).Post("/knowledge-bases/{kbId}/imports", kbImportsHandler.CreateImport)
+	})
+
+	// ── Protected streams ─────────────────────────────────────────────────────
+	// Same auth as above, without the request timeout: SSE responses stay
+	// open for as long as the run they follow, and message history exports
+	// (?format=csv|ndjson) until every page is written.
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Auth(cfg.Auth.JWTSecret))
+		r.Use(rateLimiter.Middleware)
+		r.Use(authorizer.Middleware)
+		r.Get("/channels/{channelId}/messages", channelsHandler.ListChannelMessages)
+		r.Get("/workspaces/{wsId}/scheduler/tasks/{taskId}/executions/{executionId}/stream", schedulerHandler.StreamExecution)
 	})
 
 	// ── Runtime proxy (JWT or X-Runtime-Secret) ──────────────────────────────
@@ -248,12 +508,80 @@ func main() {
 	// (service-to-service). This allows scheduled tasks, channel runs, and
 	// monitoring to reach runtime endpoints through the gateway without JWT.
 	r.Group(func(r chi.Router) {
-		r.Use(middleware.AuthOrRuntimeSecret(cfg.JWTSecret, cfg.RuntimeSecret))
-		r.Handle("/runtime/*", stream.RuntimeProxy(cfg.RuntimeAddr))
+		r.Use(middleware.AuthOrRuntimeSecret(cfg.Auth.JWTSecret, cfg.Auth.RuntimeSecret))
+		r.Handle("/runtime/*", stream.RuntimeProxy(cfg.Proxy.RuntimeAddr))
 	})
 
-	log.Printf("Gateway listening on :%s (gRPC → %s, Bifrost → %s, Runtime → %s)", cfg.Port, cfg.GRPCAddr, cfg.BifrostAddr, cfg.RuntimeAddr)
-	if err := http.ListenAndServe(":"+cfg.Port, r); err != nil {
-		log.Fatalf("server error: %v", err)
+	logger.Info("gateway listening", slog.String("addr", ":"+cfg.Server.Port),
+		slog.String("grpc_addr", cfg.Server.GRPCAddr),
+		slog.String("bifrost_addr", cfg.Proxy.BifrostAddr),
+		slog.String("runtime_addr", cfg.Proxy.RuntimeAddr))
+	srv := &http.Server{Addr: ":" + cfg.Server.Port, Handler: r}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.ListenAndServe() }()
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("server error", slog.Any("err", err))
+		}
+	case <-ctx.Done():
+		logger.Info("shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			// Streams still open after the grace period are cut off.
+			logger.Warn("graceful shutdown incomplete", slog.Any("err", err))
+			srv.Close()
+		}
+	}
+	stop()
+	workers.Wait()
+}
+
+// fatal logs a startup failure and exits. Deferred Closes are skipped; nothing
+// has been queued yet at that point.
+func fatal(logger *slog.Logger, msg string, err error) {
+	if err != nil {
+		logger.Error(msg, slog.Any("err", err))
+	} else {
+		logger.Error(msg)
+	}
+	os.Exit(1)
+}
+
+// longRequestTimeout bounds the routes in the "Protected long requests" group.
+const longRequestTimeout = 5 * time.Minute
+
+// shutdownTimeout bounds how long in-flight requests may finish on SIGTERM.
+const shutdownTimeout = 20 * time.Second
+
+func runtimeToolsOptions(cfg *config.Config, clients *grpcclient.Clients) handler.RuntimeToolsHandlerOptions {
+	return handler.RuntimeToolsHandlerOptions{
+		RuntimeSecret:      cfg.Auth.RuntimeSecret,
+		DefaultProvider:    cfg.Search.Provider,
+		TimeoutMs:          cfg.Search.TimeoutMs,
+		DuckDuckGoEndpoint: cfg.Search.DuckDuckGo.Endpoint,
+		BraveEndpoint:      cfg.Search.Brave.Endpoint,
+		BraveAPIKey:        cfg.Search.Brave.APIKey,
+		SearxngEndpoint:    cfg.Search.Searxng.Endpoint,
+		SearxngAPIKey:      cfg.Search.Searxng.APIKey,
+		SerpAPIEndpoint:    cfg.Search.SerpAPI.Endpoint,
+		SerpAPIKey:         cfg.Search.SerpAPI.APIKey,
+		TavilyEndpoint:     cfg.Search.Tavily.Endpoint,
+		TavilyAPIKey:       cfg.Search.Tavily.APIKey,
+		BingEndpoint:       cfg.Search.Bing.Endpoint,
+		BingAPIKey:         cfg.Search.Bing.APIKey,
+		GoogleEndpoint:     cfg.Search.Google.Endpoint,
+		GoogleAPIKey:       cfg.Search.Google.APIKey,
+		GoogleEngineID:     cfg.Search.Google.EngineID,
+		ExaEndpoint:        cfg.Search.Exa.Endpoint,
+		ExaAPIKey:          cfg.Search.Exa.APIKey,
+		IncludeDomains:     cfg.Search.IncludeDomains,
+		ExcludeDomains:     cfg.Search.ExcludeDomains,
+		Rerank:             cfg.Search.Rerank.Enabled,
+		TrustedDomains:     cfg.Search.Rerank.TrustedDomains,
+		WorkspaceSettings:  handler.WorkspaceSearchSettings(clients.Settings),
+		WorkspaceCacheTTL:  time.Duration(cfg.Search.WorkspaceCacheTTLMs) * time.Millisecond,
+		Quotas:             cfg.Search.Quotas(),
 	}
 }
@@ -0,0 +1,139 @@
+// Package audit records mutating API calls (who changed what, where) and
+// ships them to a pluggable Sink.
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Entry is one audited request.
+type Entry struct {
+	ID          string            `json:"id"`
+	Timestamp   string            `json:"timestamp"`
+	OrgID       string            `json:"orgId,omitempty"`
+	WorkspaceID string            `json:"workspaceId,omitempty"`
+	UserID      string            `json:"userId"`
+	Method      string            `json:"method"`
+	Route       string            `json:"route"`
+	Path        string            `json:"path"`
+	Params      map[string]string `json:"params,omitempty"`
+	RequestID   string            `json:"requestId,omitempty"`
+	Status      int               `json:"status"`
+	LatencyMs   int64             `json:"latencyMs"`
+	// RequestBody is the JSON request body with secrets redacted, as sent
+	// rather than a diff against the resource's previous state. Bodies over
+	// 64KB and non-JSON bodies are not kept.
+	RequestBody any `json:"requestBody,omitempty"`
+}
+
+// Filter selects entries in Querier.Recent. Empty fields match everything.
+type Filter struct {
+	OrgID       string
+	WorkspaceID string
+	UserID      string
+	Limit       int
+}
+
+func (f Filter) normalize() Filter {
+	f.OrgID = strings.TrimSpace(f.OrgID)
+	f.WorkspaceID = strings.TrimSpace(f.WorkspaceID)
+	f.UserID = strings.TrimSpace(f.UserID)
+	if f.Limit <= 0 {
+		f.Limit = 50
+	}
+	if f.Limit > 500 {
+		f.Limit = 500
+	}
+	return f
+}
+
+func (f Filter) match(e Entry) bool {
+	if f.OrgID != "" && e.OrgID != f.OrgID {
+		return false
+	}
+	if f.WorkspaceID != "" && e.WorkspaceID != f.WorkspaceID {
+		return false
+	}
+	if f.UserID != "" && e.UserID != f.UserID {
+		return false
+	}
+	return true
+}
+
+// Sink persists audit entries.
+type Sink interface {
+	Write(ctx context.Context, entries []Entry) error
+}
+
+// Querier is implemented by sinks that can read back recent entries,
+// newest first.
+type Querier interface {
+	Recent(ctx context.Context, filter Filter) ([]Entry, error)
+}
+
+// Recorder decouples request handling from sink latency: entries are queued
+// and written by a background worker in small batches. When the queue is
+// full, entries are dropped and logged rather than blocking requests.
+type Recorder struct {
+	sink  Sink
+	queue chan Entry
+	wg    sync.WaitGroup
+}
+
+const maxBatch = 64
+
+func NewRecorder(sink Sink, queueSize int) *Recorder {
+	if queueSize <= 0 {
+		queueSize = 1024
+	}
+	rec := &Recorder{sink: sink, queue: make(chan Entry, queueSize)}
+	rec.wg.Add(1)
+	go rec.run()
+	return rec
+}
+
+// Sink returns the underlying sink.
+func (r *Recorder) Sink() Sink {
+	return r.sink
+}
+
+func (r *Recorder) Record(entry Entry) {
+	select {
+	case r.queue <- entry:
+	default:
+		slog.Warn("audit: queue full, dropping entry",
+			slog.String("method", entry.Method), slog.String("route", entry.Route), slog.String("user_id", entry.UserID))
+	}
+}
+
+func (r *Recorder) run() {
+	defer r.wg.Done()
+	batch := make([]Entry, 0, maxBatch)
+	for entry := range r.queue {
+		batch = append(batch[:0], entry)
+	drain:
+		for len(batch) < maxBatch {
+			select {
+			case next, ok := <-r.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+		if err := r.sink.Write(context.Background(), batch); err != nil {
+			slog.Error("audit: write failed", slog.Int("entries", len(batch)), slog.Any("err", err))
+		}
+	}
+}
+
+// Close flushes queued entries and stops the worker.
+func (r *Recorder) Close() {
+	close(r.queue)
+	r.wg.Wait()
+}
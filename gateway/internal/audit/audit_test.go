@@ -0,0 +1,168 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/middleware"
+)
+
+type memorySink struct {
+	entries []Entry
+}
+
+func (s *memorySink) Write(ctx context.Context, entries []Entry) error {
+	s.entries = append(s.entries, entries...)
+	return nil
+}
+
+func TestMiddlewareRecordsRedactedMutations(t *testing.T) {
+	sink := &memorySink{}
+	rec := NewRecorder(sink, 16)
+
+	var handlerBody string
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), middleware.UserContextKey, middleware.UserClaims{UserID: "user-1"})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	r.Group(func(r chi.Router) {
+		r.Use(Middleware(rec, nil))
+		r.Get("/workspaces/{wsId}/providers", func(w http.ResponseWriter, r *http.Request) {})
+		r.Patch("/workspaces/{wsId}/providers/{providerId}", func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			handlerBody = string(b)
+			w.WriteHeader(http.StatusAccepted)
+		})
+	})
+
+	body := `{"name":"OpenAI","apiKey":"sk-live-123","configJson":"{\"appSecret\":\"s3\",\"appId\":\"a1\"}"}`
+	req := httptest.NewRequest(http.MethodPatch, "/workspaces/ws-1/providers/p-1", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/workspaces/ws-1/providers", nil))
+	rec.Close()
+
+	if handlerBody != body {
+		t.Fatalf("handler saw body %q, want original body", handlerBody)
+	}
+	if len(sink.entries) != 1 {
+		t.Fatalf("recorded %d entries, want 1 (GET is not audited)", len(sink.entries))
+	}
+	e := sink.entries[0]
+	if e.UserID != "user-1" || e.Status != http.StatusAccepted || e.WorkspaceID != "ws-1" {
+		t.Errorf("entry = %+v", e)
+	}
+	if e.Route != "/workspaces/{wsId}/providers/{providerId}" || e.Params["providerId"] != "p-1" {
+		t.Errorf("route = %q params = %v", e.Route, e.Params)
+	}
+	recorded, _ := json.Marshal(e.RequestBody)
+	if strings.Contains(string(recorded), "sk-live-123") || strings.Contains(string(recorded), "s3") {
+		t.Errorf("secrets leaked into the request body: %s", recorded)
+	}
+	if !strings.Contains(string(recorded), `"appId":"a1"`) || !strings.Contains(string(recorded), `"name":"OpenAI"`) {
+		t.Errorf("non-secret fields missing from the request body: %s", recorded)
+	}
+}
+
+func TestMiddlewareResolvesResourceScope(t *testing.T) {
+	sink := &memorySink{}
+	rec := NewRecorder(sink, 16)
+	var resolvedBeforeHandler bool
+	deleted := false
+	resolve := func(ctx context.Context, params map[string]string) (string, string) {
+		if params["channelId"] == "ch-1" && !deleted {
+			resolvedBeforeHandler = true
+			return "org-1", "ws-1"
+		}
+		return "", ""
+	}
+
+	r := chi.NewRouter()
+	r.Group(func(r chi.Router) {
+		r.Use(Middleware(rec, resolve))
+		r.Delete("/channels/{channelId}", func(w http.ResponseWriter, r *http.Request) {
+			deleted = true
+			w.WriteHeader(http.StatusNoContent)
+		})
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/channels/ch-1", nil))
+	rec.Close()
+
+	if len(sink.entries) != 1 {
+		t.Fatalf("recorded %d entries, want 1", len(sink.entries))
+	}
+	if e := sink.entries[0]; !resolvedBeforeHandler || e.OrgID != "org-1" || e.WorkspaceID != "ws-1" {
+		t.Errorf("entry org = %q ws = %q, want org-1/ws-1 resolved before the delete", e.OrgID, e.WorkspaceID)
+	}
+}
+
+func TestJSONLSinkRecent(t *testing.T) {
+	sink, err := NewJSONLSink(filepath.Join(t.TempDir(), "audit", "audit.jsonl"), JSONLOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	var entries []Entry
+	for i, org := range []string{"org-1", "org-2", "org-1", "org-1", "org-1"} {
+		entries = append(entries, Entry{ID: string(rune('a' + i)), OrgID: org, UserID: "u"})
+	}
+	if err := sink.Write(context.Background(), entries); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := sink.Recent(context.Background(), Filter{OrgID: "org-1", Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := ""
+	for _, e := range got {
+		ids += e.ID
+	}
+	if ids != "edc" {
+		t.Errorf("Recent ids = %q, want newest-first %q", ids, "edc")
+	}
+}
+
+func TestJSONLSinkRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := NewJSONLSink(path, JSONLOptions{MaxBytes: 1, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	// MaxBytes 1 rotates after every write, so only the last MaxBackups
+	// writes (d and e) are kept.
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		if err := sink.Write(context.Background(), []Entry{{ID: id, OrgID: "org-1"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("backup beyond MaxBackups exists: %v", err)
+	}
+	got, err := sink.Recent(context.Background(), Filter{OrgID: "org-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := ""
+	for _, e := range got {
+		ids += e.ID
+	}
+	if ids != "ed" {
+		t.Errorf("Recent ids = %q, want %q", ids, "ed")
+	}
+}
@@ -0,0 +1,149 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/middleware"
+	commonpb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/common"
+	orgpb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/org"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/redact"
+)
+
+// Sink kinds accepted by NewSink.
+const (
+	SinkJSONL  = "jsonl"
+	SinkStdout = "stdout"
+	SinkGRPC   = "grpc"
+	SinkNone   = "none"
+)
+
+// maxCapturedBody bounds how much of a request body is kept for the record.
+const maxCapturedBody = 64 << 10
+
+// NewSink builds the sink named by kind. It returns a nil Sink for "none".
+// path and rotate apply to the jsonl sink only.
+func NewSink(kind, path string, rotate JSONLOptions, org orgpb.OrgServiceClient) (Sink, error) {
+	switch strings.TrimSpace(strings.ToLower(kind)) {
+	case "", SinkJSONL:
+		return NewJSONLSink(path, rotate)
+	case SinkStdout:
+		return NewWriterSink(os.Stdout), nil
+	case SinkGRPC:
+		return NewGRPCSink(org), nil
+	case SinkNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown audit sink %q", kind)
+	}
+}
+
+// ScopeResolver finds the org and workspace that own the resource named by
+// a route's params, for routes without {orgId} or {wsId} such as
+// /channels/{channelId}. It returns empty strings when it cannot tell.
+type ScopeResolver func(ctx context.Context, params map[string]string) (orgID, workspaceID string)
+
+// Middleware records every non-GET request that reaches it. It must run
+// after Auth (for the user) and before the Authorizer (so that denied
+// requests are recorded too), inside a chi Group so the route is resolved.
+// resolve may be nil; it is consulted before the handler runs, so that
+// deleted resources are still attributed to their org.
+func Middleware(rec *Recorder, resolve ScopeResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			entry := Entry{
+				ID:        uuid.NewString(),
+				Timestamp: start.UTC().Format(time.RFC3339Nano),
+				Method:    r.Method,
+				Path:      r.URL.Path,
+				RequestID: chimiddleware.GetReqID(r.Context()),
+			}
+			if user, ok := middleware.GetUser(r); ok {
+				entry.UserID = user.UserID
+			}
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				entry.Route = rctx.RoutePattern()
+				entry.Params = make(map[string]string, len(rctx.URLParams.Keys))
+				for i, key := range rctx.URLParams.Keys {
+					if key == "*" || i >= len(rctx.URLParams.Values) {
+						continue
+					}
+					entry.Params[key] = rctx.URLParams.Values[i]
+				}
+			}
+			if resolve != nil && entry.Params["orgId"] == "" && entry.Params["wsId"] == "" {
+				entry.OrgID, entry.WorkspaceID = resolve(r.Context(), entry.Params)
+			}
+
+			entry.RequestBody = captureBody(r)
+			ctx, scope := middleware.TrackScope(r.Context())
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			entry.Status = ww.Status()
+			if entry.Status == 0 {
+				entry.Status = http.StatusOK
+			}
+			entry.LatencyMs = time.Since(start).Milliseconds()
+			if scope.OrgID != "" {
+				entry.OrgID, entry.WorkspaceID = scope.OrgID, scope.WorkspaceID
+			}
+			if entry.OrgID == "" {
+				entry.OrgID = entry.Params["orgId"]
+			}
+			if entry.WorkspaceID == "" {
+				entry.WorkspaceID = entry.Params["wsId"]
+			}
+			rec.Record(entry)
+		})
+	}
+}
+
+// captureBody reads up to maxCapturedBody bytes of a JSON request body,
+// restores it for the handler, and returns it redacted. Non-JSON bodies
+// (e.g. multipart uploads) are not captured.
+func captureBody(r *http.Request) any {
+	if r.Body == nil || !strings.Contains(strings.ToLower(r.Header.Get("Content-Type")), "json") {
+		return nil
+	}
+	buf, err := io.ReadAll(io.LimitReader(r.Body, maxCapturedBody+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+	if err != nil || len(buf) == 0 {
+		return nil
+	}
+	if len(buf) > maxCapturedBody {
+		return map[string]any{"truncated": true}
+	}
+	if v, ok := redact.JSON(buf); ok {
+		return v
+	}
+	return nil
+}
+
+func userContextFrom(ctx context.Context) *commonpb.UserContext {
+	u, ok := ctx.Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		return nil
+	}
+	return &commonpb.UserContext{UserId: u.UserID, Email: u.Email, Name: u.Name}
+}
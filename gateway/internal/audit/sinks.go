@@ -0,0 +1,282 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	orgpb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/org"
+)
+
+// WriterSink writes one JSON object per line to w (e.g. os.Stdout).
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Write(ctx context.Context, entries []Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	enc := json.NewEncoder(s.w)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// JSONLOptions bounds a JSONLSink's files. Zero values take the defaults.
+type JSONLOptions struct {
+	// MaxBytes rotates the file once it grows past this size. Default 64MB.
+	MaxBytes int64
+	// MaxBackups is how many rotated files (path.1 newest ... path.N) are
+	// kept and searched by Recent. Default 4.
+	MaxBackups int
+}
+
+// JSONLSink appends entries to a JSON Lines file, rotated by size, and can
+// read them back.
+type JSONLSink struct {
+	path string
+	opts JSONLOptions
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func NewJSONLSink(path string, opts JSONLOptions) (*JSONLSink, error) {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = 64 << 20
+	}
+	if opts.MaxBackups <= 0 {
+		opts.MaxBackups = 4
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	s := &JSONLSink{path: path, opts: opts}
+	if err := s.openLocked(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *JSONLSink) openLocked() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file, s.size = file, info.Size()
+	return nil
+}
+
+func (s *JSONLSink) backup(n int) string {
+	return fmt.Sprintf("%s.%d", s.path, n)
+}
+
+func (s *JSONLSink) Write(ctx context.Context, entries []Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+	n, err := s.file.Write(buf.Bytes())
+	s.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if s.size >= s.opts.MaxBytes {
+		return s.rotateLocked()
+	}
+	return nil
+}
+
+// rotateLocked shifts path.i to path.i+1, dropping the oldest, moves the
+// current file to path.1 and starts a new one. s.mu must be held.
+func (s *JSONLSink) rotateLocked() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	os.Remove(s.backup(s.opts.MaxBackups))
+	for i := s.opts.MaxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(s.backup(i), s.backup(i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Rename(s.path, s.backup(1)); err != nil {
+		return err
+	}
+	return s.openLocked()
+}
+
+// Recent returns the newest matching entries. The lock is held only to open
+// the current and rotated files and note how much of the current file is
+// written; the scan itself runs concurrently with writes.
+func (s *JSONLSink) Recent(ctx context.Context, filter Filter) ([]Entry, error) {
+	filter = filter.normalize()
+
+	s.mu.Lock()
+	var readers []io.Reader
+	var files []*os.File
+	for i := s.opts.MaxBackups; i >= 1; i-- {
+		if file, err := os.Open(s.backup(i)); err == nil {
+			files = append(files, file)
+			readers = append(readers, file)
+		}
+	}
+	current, err := os.Open(s.path)
+	if err == nil {
+		files = append(files, current)
+		readers = append(readers, io.LimitReader(current, s.size))
+	}
+	s.mu.Unlock()
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	// Keep a ring of the last Limit matches while streaming oldest to newest.
+	ring := make([]Entry, 0, filter.Limit)
+	next := 0
+	scanner := bufio.NewScanner(io.MultiReader(readers...))
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if !filter.match(entry) {
+			continue
+		}
+		if len(ring) < filter.Limit {
+			ring = append(ring, entry)
+			continue
+		}
+		ring[next] = entry
+		next = (next + 1) % filter.Limit
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([]Entry, 0, len(ring))
+	for i := len(ring) - 1; i >= 0; i-- {
+		out = append(out, ring[(next+i)%len(ring)])
+	}
+	return out, nil
+}
+
+func (s *JSONLSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// GRPCSink stores entries through the Org service so they live alongside
+// the rest of the org's data.
+type GRPCSink struct {
+	org orgpb.OrgServiceClient
+}
+
+func NewGRPCSink(org orgpb.OrgServiceClient) *GRPCSink {
+	return &GRPCSink{org: org}
+}
+
+func (s *GRPCSink) Write(ctx context.Context, entries []Entry) error {
+	items := make([]*orgpb.AuditEntry, 0, len(entries))
+	for _, entry := range entries {
+		body := ""
+		if entry.RequestBody != nil {
+			if b, err := json.Marshal(entry.RequestBody); err == nil {
+				body = string(b)
+			}
+		}
+		items = append(items, &orgpb.AuditEntry{
+			Id:          entry.ID,
+			OrgId:       entry.OrgID,
+			WorkspaceId: entry.WorkspaceID,
+			UserId:      entry.UserID,
+			Method:      entry.Method,
+			Route:       entry.Route,
+			Path:        entry.Path,
+			Params:      entry.Params,
+			RequestId:   entry.RequestID,
+			Status:      int32(entry.Status),
+			LatencyMs:   entry.LatencyMs,
+			ChangesJson: body,
+			CreatedAt:   entry.Timestamp,
+		})
+	}
+	_, err := s.org.RecordAuditEntries(ctx, &orgpb.RecordAuditEntriesRequest{Entries: items})
+	return err
+}
+
+// Recent lists entries through the Org service. The caller's user context is
+// forwarded so the service can enforce org membership as well.
+func (s *GRPCSink) Recent(ctx context.Context, filter Filter) ([]Entry, error) {
+	filter = filter.normalize()
+	resp, err := s.org.ListAuditEntries(ctx, &orgpb.ListAuditEntriesRequest{
+		OrgId:       filter.OrgID,
+		WorkspaceId: filter.WorkspaceID,
+		UserId:      filter.UserID,
+		Limit:       int32(filter.Limit),
+		UserContext: userContextFrom(ctx),
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Entry, 0, len(resp.GetEntries()))
+	for _, item := range resp.GetEntries() {
+		entry := Entry{
+			ID:          item.GetId(),
+			Timestamp:   item.GetCreatedAt(),
+			OrgID:       item.GetOrgId(),
+			WorkspaceID: item.GetWorkspaceId(),
+			UserID:      item.GetUserId(),
+			Method:      item.GetMethod(),
+			Route:       item.GetRoute(),
+			Path:        item.GetPath(),
+			Params:      item.GetParams(),
+			RequestID:   item.GetRequestId(),
+			Status:      int(item.GetStatus()),
+			LatencyMs:   item.GetLatencyMs(),
+		}
+		if raw := item.GetChangesJson(); raw != "" {
+			var body any
+			if json.Unmarshal([]byte(raw), &body) == nil {
+				entry.RequestBody = body
+			}
+		}
+		out = append(out, entry)
+	}
+	return out, nil
+}
@@ -0,0 +1,65 @@
+// Package blob stores uploaded files behind a small interface so documents
+// can be referenced by a storage URI instead of a path on the gateway host.
+// Two backends exist: the local filesystem (local://<key>) and any
+// S3-compatible object store (s3://<bucket>/<key>).
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+var (
+	ErrNotFound = errors.New("blob not found")
+	// ErrForeignURI is returned for a URI that belongs to another backend or
+	// bucket.
+	ErrForeignURI = errors.New("blob uri not handled by this store")
+)
+
+// Store puts, reads and deletes blobs addressed by the URI Put returns.
+type Store interface {
+	// Put stores size bytes read from r under key and returns its URI.
+	Put(ctx context.Context, key string, r io.Reader, size int64) (string, error)
+	Open(ctx context.Context, uri string) (io.ReadCloser, error)
+	// Delete removes the blob; deleting a missing blob is not an error.
+	Delete(ctx context.Context, uri string) error
+}
+
+// Options selects and configures a backend.
+type Options struct {
+	Backend  string // local | s3
+	LocalDir string
+	S3       S3Options
+}
+
+// New builds the Store selected by opts.Backend.
+func New(opts Options) (Store, error) {
+	switch strings.ToLower(strings.TrimSpace(opts.Backend)) {
+	case "", "local":
+		return NewLocalStore(opts.LocalDir)
+	case "s3":
+		return NewS3Store(opts.S3)
+	default:
+		return nil, fmt.Errorf("unknown blob backend %q", opts.Backend)
+	}
+}
+
+// cleanKey normalizes key to a relative slash-separated path and rejects
+// keys that try to climb out of the store's root.
+func cleanKey(key string) (string, error) {
+	key = strings.ReplaceAll(strings.TrimSpace(key), "\\", "/")
+	for _, segment := range strings.Split(key, "/") {
+		if segment == ".." {
+			return "", fmt.Errorf("invalid blob key %q", key)
+		}
+	}
+	clean := strings.TrimPrefix(path.Clean("/"+key), "/")
+	if clean == "" {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return clean, nil
+}
@@ -0,0 +1,128 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const localScheme = "local://"
+
+// localPrefixes are the key prefixes the gateway writes blobs under. The
+// root may sit next to, or around, other gateway state, so keys outside them
+// and hidden path segments are never read, written or deleted.
+var localPrefixes = []string{"kb-uploads/", "quarantine/", "channel-attachments/"}
+
+// LocalStore keeps blobs as files under a root directory. Its URIs are only
+// meaningful on this host, so other services read them through the gateway.
+type LocalStore struct {
+	root string
+}
+
+func NewLocalStore(root string) (*LocalStore, error) {
+	if strings.TrimSpace(root) == "" {
+		return nil, fmt.Errorf("local blob directory is required")
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalStore{root: abs}, nil
+}
+
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, size int64) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	if !localKeyAllowed(key) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	target := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", err
+	}
+	// Write next to the target and rename so a reader never sees a partial
+	// file.
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".put-*")
+	if err != nil {
+		return "", err
+	}
+	written, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && size >= 0 && written != size {
+		err = fmt.Errorf("wrote %d bytes, expected %d", written, size)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), target)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return localScheme + key, nil
+}
+
+func (s *LocalStore) Open(ctx context.Context, uri string) (io.ReadCloser, error) {
+	key, err := s.key(uri)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (s *LocalStore) Delete(ctx context.Context, uri string) error {
+	key, err := s.key(uri)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStore) key(uri string) (string, error) {
+	key, ok := strings.CutPrefix(uri, localScheme)
+	if !ok {
+		return "", ErrForeignURI
+	}
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	if !localKeyAllowed(key) {
+		return "", ErrForeignURI
+	}
+	return key, nil
+}
+
+// localKeyAllowed reports whether a cleaned key is under localPrefixes and
+// has no hidden segment, such as the temporary files Put writes.
+func localKeyAllowed(key string) bool {
+	for _, segment := range strings.Split(key, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return false
+		}
+	}
+	for _, prefix := range localPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
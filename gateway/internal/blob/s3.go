@@ -0,0 +1,283 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const s3Scheme = "s3://"
+
+// emptyPayloadHash is the SHA-256 of an empty body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Options configures an S3-compatible store (AWS S3, MinIO, R2, ...).
+type S3Options struct {
+	// Endpoint is the service URL, e.g. https://s3.us-east-1.amazonaws.com
+	// or http://localhost:9000 for MinIO.
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// Prefix is prepended to every key.
+	Prefix string
+	// PathStyle addresses objects as <endpoint>/<bucket>/<key> instead of
+	// <bucket>.<endpoint host>/<key>; MinIO usually needs it.
+	PathStyle bool
+	Timeout   time.Duration
+}
+
+// S3Store talks to the S3 REST API directly, signing requests with
+// Signature Version 4. Objects are written with a single PUT, which S3
+// limits to 5GB.
+type S3Store struct {
+	opts     S3Options
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+func NewS3Store(opts S3Options) (*S3Store, error) {
+	endpoint, err := url.Parse(strings.TrimRight(strings.TrimSpace(opts.Endpoint), "/"))
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", opts.Endpoint)
+	}
+	if strings.TrimSpace(opts.Bucket) == "" {
+		return nil, fmt.Errorf("s3 bucket is required")
+	}
+	if opts.AccessKey == "" || opts.SecretKey == "" {
+		return nil, fmt.Errorf("s3 access key and secret key are required")
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Minute
+	}
+	opts.Prefix = strings.Trim(opts.Prefix, "/")
+	return &S3Store{
+		opts:     opts,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: opts.Timeout},
+		now:      time.Now,
+	}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	if s.opts.Prefix != "" {
+		key = s.opts.Prefix + "/" + key
+	}
+	if size < 0 {
+		return "", fmt.Errorf("s3 put needs the object size")
+	}
+	req, err := s.newRequest(ctx, http.MethodPut, key, io.NopCloser(r))
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	res, err := s.do(req, "UNSIGNED-PAYLOAD")
+	if err != nil {
+		return "", err
+	}
+	res.Body.Close()
+	return s3Scheme + s.opts.Bucket + "/" + key, nil
+}
+
+func (s *S3Store) Open(ctx context.Context, uri string) (io.ReadCloser, error) {
+	key, err := s.key(uri)
+	if err != nil {
+		return nil, err
+	}
+	req, err := s.newRequest(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, uri string) error {
+	key, err := s.key(uri)
+	if err != nil {
+		return err
+	}
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	res, err := s.do(req, emptyPayloadHash)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+func (s *S3Store) key(uri string) (string, error) {
+	rest, ok := strings.CutPrefix(uri, s3Scheme)
+	if !ok {
+		return "", ErrForeignURI
+	}
+	bucket, key, _ := strings.Cut(rest, "/")
+	if bucket != s.opts.Bucket {
+		return "", ErrForeignURI
+	}
+	return cleanKey(key)
+}
+
+// newRequest addresses key in the bucket. The escaped path is kept in
+// URL.RawPath so the signed path and the sent path agree.
+func (s *S3Store) newRequest(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+	u := *s.endpoint
+	objectPath := "/" + awsEscape(key, true)
+	if s.opts.PathStyle {
+		objectPath = "/" + awsEscape(s.opts.Bucket, false) + objectPath
+	} else {
+		u.Host = s.opts.Bucket + "." + u.Host
+	}
+	basePath := strings.TrimRight(u.Path, "/")
+	unescaped, err := url.PathUnescape(basePath + objectPath)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = unescaped
+	u.RawPath = basePath + objectPath
+	return http.NewRequestWithContext(ctx, method, u.String(), body)
+}
+
+func (s *S3Store) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	signV4(req, payloadHash, s.opts.Region, s.opts.AccessKey, s.opts.SecretKey, s.now())
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 %s failed: %w", req.Method, err)
+	}
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return res, nil
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	detail, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	return nil, fmt.Errorf("s3 %s http %d: %s", req.Method, res.StatusCode, strings.TrimSpace(string(detail)))
+}
+
+// signV4 adds AWS Signature Version 4 headers for the s3 service.
+func signV4(req *http.Request, payloadHash, region, accessKey, secretKey string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "host" || lower == "content-type" || lower == "range" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vs := append([]string(nil), values[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, awsEscape(k, false)+"="+awsEscape(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape percent-encodes everything but RFC 3986 unreserved characters
+// (and '/' when keepSlash), as SigV4 requires.
+func awsEscape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -1,99 +1,597 @@
+// Package config loads the gateway configuration: built-in defaults, then an
+// optional YAML or TOML file, then environment variables. Every field is
+// described once by its struct tags:
+//
+//	config:"key"    key in the config file (sections nest by struct)
+//	env:"NAME"      environment variable that overrides the file
+//	default:"v"     value used when neither file nor env sets it
+//	secret:"true"   masked in Dump/Redacted
+//	reload:"true"   applied on SIGHUP without a restart (see Reloader)
 package config
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
 )
 
+// PathEnv names the environment variable holding the config file path.
+const PathEnv = "GATEWAY_CONFIG"
+
 type Config struct {
-	Port                     string
-	GRPCAddr                 string
-	BifrostAddr              string
-	RuntimeAddr              string
-	JWTSecret                string
-	RuntimeSecret            string
-	WebSearchProvider        string
-	WebSearchTimeoutMs       int
-	WebSearchDuckEndpoint    string
-	WebSearchBraveEndpoint   string
-	WebSearchBraveAPIKey     string
-	WebSearchSearxngEndpoint string
-	WebSearchSearxngAPIKey   string
-	WebSearchSerpAPIEndpoint string
-	WebSearchSerpAPIKey      string
-	AllowedOrigins           []string
-}
-
-func Load() *Config {
-	return &Config{
-		Port:                     getEnv("PORT", "8080"),
-		GRPCAddr:                 getEnv("GRPC_ADDR", "localhost:50051"),
-		BifrostAddr:              getEnv("BIFROST_ADDR", "http://localhost:8081"),
-		RuntimeAddr:              getEnv("RUNTIME_ADDR", "http://localhost:8082"),
-		JWTSecret:                getEnv("JWT_SECRET", "dev-secret-change-in-production"),
-		RuntimeSecret:            getEnv("RUNTIME_SECRET", "dev-runtime-secret"),
-		WebSearchProvider:        getEnv("WEB_SEARCH_PROVIDER", "auto"),
-		WebSearchTimeoutMs:       getIntEnv("WEB_SEARCH_TIMEOUT_MS", 12000),
-		WebSearchDuckEndpoint:    getEnv("WEB_SEARCH_DUCKDUCKGO_ENDPOINT", "https://api.duckduckgo.com/"),
-		WebSearchBraveEndpoint:   getEnv("WEB_SEARCH_BRAVE_ENDPOINT", "https://api.search.brave.com/res/v1/web/search"),
-		WebSearchBraveAPIKey:     getEnv("BRAVE_SEARCH_API_KEY", ""),
-		WebSearchSearxngEndpoint: getEnv("WEB_SEARCH_SEARXNG_ENDPOINT", ""),
-		WebSearchSearxngAPIKey:   getEnv("WEB_SEARCH_SEARXNG_API_KEY", ""),
-		WebSearchSerpAPIEndpoint: getEnv("WEB_SEARCH_SERPAPI_ENDPOINT", "https://serpapi.com/search.json"),
-		WebSearchSerpAPIKey:      getEnv("SERPAPI_API_KEY", ""),
-		AllowedOrigins:           buildAllowedOrigins(getEnv("FRONTEND_URL", "")),
-	}
-}
-
-// Validate returns warnings when default/insecure secrets are still in use.
-func (c *Config) Validate() []string {
-	var warnings []string
-	if c.JWTSecret == "dev-secret-change-in-production" {
-		warnings = append(warnings, "JWT_SECRET is using the default development value — set a strong secret in production")
+	Server      ServerConfig      `config:"server"`
+	Auth        AuthConfig        `config:"auth"`
+	Proxy       ProxyConfig       `config:"proxy"`
+	Search      SearchConfig      `config:"search" reload:"true"`
+	RateLimit   RateLimitConfig   `config:"rate_limit" reload:"true"`
+	CORS        CORSConfig        `config:"cors" reload:"true"`
+	Authz       AuthzConfig       `config:"authz"`
+	Audit       AuditConfig       `config:"audit"`
+	Idempotency IdempotencyConfig `config:"idempotency"`
+	Uploads     UploadsConfig     `config:"uploads"`
+	Blob        BlobConfig        `config:"blob"`
+	Imports     ImportsConfig     `config:"imports"`
+	Webhooks    WebhooksConfig    `config:"webhooks"`
+	Outgoing    OutgoingConfig    `config:"outgoing_webhooks"`
+	Email       EmailConfig       `config:"email"`
+	Log         LogConfig         `config:"log"`
+}
+
+type ServerConfig struct {
+	Port     string `config:"port" env:"PORT" default:"8080"`
+	GRPCAddr string `config:"grpc_addr" env:"GRPC_ADDR" default:"localhost:50051"`
+}
+
+type AuthConfig struct {
+	JWTSecret     string `config:"jwt_secret" env:"JWT_SECRET" default:"dev-secret-change-in-production" secret:"true"`
+	RuntimeSecret string `config:"runtime_secret" env:"RUNTIME_SECRET" default:"dev-runtime-secret" secret:"true"`
+}
+
+// ProxyConfig holds the upstreams reverse-proxied under /v1 and /runtime.
+type ProxyConfig struct {
+	BifrostAddr string `config:"bifrost_addr" env:"BIFROST_ADDR" default:"http://localhost:8081"`
+	RuntimeAddr string `config:"runtime_addr" env:"RUNTIME_ADDR" default:"http://localhost:8082"`
+}
+
+type SearchConfig struct {
+	Provider   string           `config:"provider" env:"WEB_SEARCH_PROVIDER" default:"auto"`
+	TimeoutMs  int              `config:"timeout_ms" env:"WEB_SEARCH_TIMEOUT_MS" default:"12000"`
+	DuckDuckGo DuckDuckGoConfig `config:"duckduckgo"`
+	Brave      BraveConfig      `config:"brave"`
+	Searxng    SearxngConfig    `config:"searxng"`
+	SerpAPI    SerpAPIConfig    `config:"serpapi"`
+	Tavily     TavilyConfig     `config:"tavily"`
+	Bing       BingConfig       `config:"bing"`
+	Google     GoogleConfig     `config:"google"`
+	Exa        ExaConfig        `config:"exa"`
+
+	// WorkspaceCacheTTLMs is how long a workspace's own search settings are
+	// cached before being fetched again from the Settings service.
+	WorkspaceCacheTTLMs int `config:"workspace_cache_ttl_ms" env:"WEB_SEARCH_WORKSPACE_CACHE_TTL_MS" default:"60000"`
+	// IncludeDomains/ExcludeDomains apply to every workspace that does not
+	// set its own lists.
+	IncludeDomains []string           `config:"include_domains" env:"WEB_SEARCH_INCLUDE_DOMAINS"`
+	ExcludeDomains []string           `config:"exclude_domains" env:"WEB_SEARCH_EXCLUDE_DOMAINS"`
+	Rerank         SearchRerankConfig `config:"rerank"`
+}
+
+// Quotas returns the monthly call quota configured for each paid provider,
+// keyed by provider name.
+func (c SearchConfig) Quotas() map[string]int {
+	return map[string]int{
+		"brave":   c.Brave.MonthlyQuota,
+		"serpapi": c.SerpAPI.MonthlyQuota,
+		"tavily":  c.Tavily.MonthlyQuota,
+		"bing":    c.Bing.MonthlyQuota,
+		"google":  c.Google.MonthlyQuota,
+		"exa":     c.Exa.MonthlyQuota,
 	}
-	if c.RuntimeSecret == "dev-runtime-secret" {
-		warnings = append(warnings, "RUNTIME_SECRET is using the default development value — set a strong secret in production")
+}
+
+type SearchRerankConfig struct {
+	Enabled        bool     `config:"enabled" env:"WEB_SEARCH_RERANK"`
+	TrustedDomains []string `config:"trusted_domains" env:"WEB_SEARCH_TRUSTED_DOMAINS"`
+}
+
+type DuckDuckGoConfig struct {
+	Endpoint string `config:"endpoint" env:"WEB_SEARCH_DUCKDUCKGO_ENDPOINT" default:"https://api.duckduckgo.com/"`
+}
+
+type BraveConfig struct {
+	Endpoint     string `config:"endpoint" env:"WEB_SEARCH_BRAVE_ENDPOINT" default:"https://api.search.brave.com/res/v1/web/search"`
+	APIKey       string `config:"api_key" env:"BRAVE_SEARCH_API_KEY" secret:"true"`
+	MonthlyQuota int    `config:"monthly_quota" env:"WEB_SEARCH_BRAVE_MONTHLY_QUOTA"`
+}
+
+type SearxngConfig struct {
+	Endpoint string `config:"endpoint" env:"WEB_SEARCH_SEARXNG_ENDPOINT"`
+	APIKey   string `config:"api_key" env:"WEB_SEARCH_SEARXNG_API_KEY" secret:"true"`
+}
+
+type SerpAPIConfig struct {
+	Endpoint     string `config:"endpoint" env:"WEB_SEARCH_SERPAPI_ENDPOINT" default:"https://serpapi.com/search.json"`
+	APIKey       string `config:"api_key" env:"SERPAPI_API_KEY" secret:"true"`
+	MonthlyQuota int    `config:"monthly_quota" env:"WEB_SEARCH_SERPAPI_MONTHLY_QUOTA"`
+}
+
+type TavilyConfig struct {
+	Endpoint     string `config:"endpoint" env:"WEB_SEARCH_TAVILY_ENDPOINT" default:"https://api.tavily.com/search"`
+	APIKey       string `config:"api_key" env:"TAVILY_API_KEY" secret:"true"`
+	MonthlyQuota int    `config:"monthly_quota" env:"WEB_SEARCH_TAVILY_MONTHLY_QUOTA"`
+}
+
+type BingConfig struct {
+	Endpoint     string `config:"endpoint" env:"WEB_SEARCH_BING_ENDPOINT" default:"https://api.bing.microsoft.com/v7.0/search"`
+	APIKey       string `config:"api_key" env:"BING_SEARCH_API_KEY" secret:"true"`
+	MonthlyQuota int    `config:"monthly_quota" env:"WEB_SEARCH_BING_MONTHLY_QUOTA"`
+}
+
+// GoogleConfig targets the Custom Search JSON API; EngineID is the
+// Programmable Search Engine "cx".
+type GoogleConfig struct {
+	Endpoint     string `config:"endpoint" env:"WEB_SEARCH_GOOGLE_ENDPOINT" default:"https://www.googleapis.com/customsearch/v1"`
+	APIKey       string `config:"api_key" env:"GOOGLE_CSE_API_KEY" secret:"true"`
+	EngineID     string `config:"engine_id" env:"GOOGLE_CSE_ID"`
+	MonthlyQuota int    `config:"monthly_quota" env:"WEB_SEARCH_GOOGLE_MONTHLY_QUOTA"`
+}
+
+type ExaConfig struct {
+	Endpoint     string `config:"endpoint" env:"WEB_SEARCH_EXA_ENDPOINT" default:"https://api.exa.ai/search"`
+	APIKey       string `config:"api_key" env:"EXA_API_KEY" secret:"true"`
+	MonthlyQuota int    `config:"monthly_quota" env:"WEB_SEARCH_EXA_MONTHLY_QUOTA"`
+}
+
+// RateLimitConfig limits authenticated requests per user. A zero
+// RequestsPerMinute disables limiting; Burst defaults to RequestsPerMinute.
+type RateLimitConfig struct {
+	RequestsPerMinute int `config:"requests_per_minute" env:"RATE_LIMIT_RPM" default:"0"`
+	Burst             int `config:"burst" env:"RATE_LIMIT_BURST" default:"0"`
+}
+
+type CORSConfig struct {
+	AllowedOrigins []string `config:"allowed_origins" env:"CORS_ALLOWED_ORIGINS" default:"http://localhost:3000,http://localhost:3001,http://localhost:3002,http://127.0.0.1:3000,http://127.0.0.1:3001,http://127.0.0.1:3002,http://[::1]:3000,http://[::1]:3001,http://[::1]:3002"`
+	FrontendURL    string   `config:"frontend_url" env:"FRONTEND_URL"`
+}
+
+// Origins returns AllowedOrigins plus FrontendURL when set.
+func (c CORSConfig) Origins() []string {
+	origins := append([]string(nil), c.AllowedOrigins...)
+	if frontendURL := strings.TrimSpace(c.FrontendURL); frontendURL != "" {
+		origins = append(origins, frontendURL)
 	}
-	return warnings
+	return origins
+}
+
+type AuthzConfig struct {
+	CacheTTLMs int `config:"cache_ttl_ms" env:"AUTHZ_CACHE_TTL_MS" default:"30000"`
+}
+
+type AuditConfig struct {
+	Sink    string `config:"sink" env:"AUDIT_SINK" default:"jsonl"`
+	LogPath string `config:"log_path" env:"AUDIT_LOG_PATH" default:"../data/audit/audit.jsonl"`
+	// MaxBytes rotates the jsonl log past this size, keeping MaxBackups
+	// rotated files.
+	MaxBytes   int `config:"max_bytes" env:"AUDIT_MAX_BYTES" default:"67108864"`
+	MaxBackups int `config:"max_backups" env:"AUDIT_MAX_BACKUPS" default:"4"`
+}
+
+// IdempotencyConfig bounds the responses kept for Idempotency-Key replays.
+type IdempotencyConfig struct {
+	WindowMs         int `config:"window_ms" env:"IDEMPOTENCY_WINDOW_MS" default:"86400000"`
+	MaxEntries       int `config:"max_entries" env:"IDEMPOTENCY_MAX_ENTRIES" default:"10000"`
+	MaxBytes         int `config:"max_bytes" env:"IDEMPOTENCY_MAX_BYTES" default:"67108864"`
+	MaxResponseBytes int `config:"max_response_bytes" env:"IDEMPOTENCY_MAX_RESPONSE_BYTES" default:"262144"`
+}
+
+// UploadsConfig governs resumable knowledge-base uploads. Chunks must fit
+// under the gateway's 10MB request body limit.
+type UploadsConfig struct {
+	Dir           string `config:"dir" env:"UPLOADS_DIR" default:"../data/kb-uploads/.sessions"`
+	MaxFileBytes  int    `config:"max_file_bytes" env:"UPLOADS_MAX_FILE_BYTES" default:"1073741824"`
+	MaxChunkBytes int    `config:"max_chunk_bytes" env:"UPLOADS_MAX_CHUNK_BYTES" default:"8388608"`
+	SessionTTLMs  int    `config:"session_ttl_ms" env:"UPLOADS_SESSION_TTL_MS" default:"86400000"`
+	GCIntervalMs  int    `config:"gc_interval_ms" env:"UPLOADS_GC_INTERVAL_MS" default:"600000"`
+	// AllowedTypes limits document types gateway-wide; empty allows every
+	// supported type. Workspaces can narrow it further.
+	AllowedTypes []string         `config:"allowed_types" env:"UPLOADS_ALLOWED_TYPES"`
+	Scan         UploadScanConfig `config:"scan"`
+}
+
+// UploadScanConfig selects the malware scanner run before a knowledge-base
+// document is created.
+type UploadScanConfig struct {
+	Backend   string `config:"backend" env:"UPLOADS_SCAN_BACKEND" default:"none"`
+	ClamdAddr string `config:"clamd_addr" env:"UPLOADS_SCAN_CLAMD_ADDR" default:"tcp://localhost:3310"`
+	TimeoutMs int    `config:"timeout_ms" env:"UPLOADS_SCAN_TIMEOUT_MS" default:"120000"`
+	// OnInfected is reject (drop the file) or quarantine (keep it under
+	// quarantine/ in the blob store for review).
+	OnInfected string `config:"on_infected" env:"UPLOADS_SCAN_ON_INFECTED" default:"reject"`
+	// FailOpen accepts files when the scanner is unreachable.
+	FailOpen bool `config:"fail_open" env:"UPLOADS_SCAN_FAIL_OPEN"`
+}
+
+// ImportsConfig governs bulk knowledge-base imports from archives and URLs.
+// Each extracted or fetched file is also held to uploads.max_file_bytes.
+type ImportsConfig struct {
+	Dir              string `config:"dir" env:"IMPORTS_DIR" default:"../data/kb-uploads/.imports"`
+	Concurrency      int    `config:"concurrency" env:"IMPORTS_CONCURRENCY" default:"4"`
+	MaxEntries       int    `config:"max_entries" env:"IMPORTS_MAX_ENTRIES" default:"1000"`
+	MaxTotalBytes    int    `config:"max_total_bytes" env:"IMPORTS_MAX_TOTAL_BYTES" default:"2147483648"`
+	MaxRatio         int    `config:"max_compression_ratio" env:"IMPORTS_MAX_COMPRESSION_RATIO" default:"100"`
+	MaxURLs          int    `config:"max_urls" env:"IMPORTS_MAX_URLS" default:"100"`
+	URLTimeoutMs     int    `config:"url_timeout_ms" env:"IMPORTS_URL_TIMEOUT_MS" default:"60000"`
+	AllowPrivateURLs bool   `config:"allow_private_urls" env:"IMPORTS_ALLOW_PRIVATE_URLS"`
+	JobTTLMs         int    `config:"job_ttl_ms" env:"IMPORTS_JOB_TTL_MS" default:"86400000"`
+}
+
+// WebhooksConfig governs the ingress queue for channel platform webhooks.
+// Verified events are written to a log under Dir and acknowledged at
+// once, then delivered to the service in the background.
+type WebhooksConfig struct {
+	Dir string `config:"dir" env:"WEBHOOKS_DIR" default:"../data/webhooks"`
+	// Sync fsyncs the log before each acknowledgement.
+	Sync             bool `config:"sync" env:"WEBHOOKS_SYNC" default:"true"`
+	VerifyTimeoutMs  int  `config:"verify_timeout_ms" env:"WEBHOOKS_VERIFY_TIMEOUT_MS" default:"3000"`
+	DeliverTimeoutMs int  `config:"deliver_timeout_ms" env:"WEBHOOKS_DELIVER_TIMEOUT_MS" default:"15000"`
+	Concurrency      int  `config:"concurrency" env:"WEBHOOKS_CONCURRENCY" default:"4"`
+	MaxAttempts      int  `config:"max_attempts" env:"WEBHOOKS_MAX_ATTEMPTS" default:"8"`
+	RetryBaseMs      int  `config:"retry_base_ms" env:"WEBHOOKS_RETRY_BASE_MS" default:"1000"`
+	RetryMaxMs       int  `config:"retry_max_ms" env:"WEBHOOKS_RETRY_MAX_MS" default:"300000"`
+	// MaxEvents bounds queued plus dead-lettered events; past it webhooks
+	// are refused with 503 so the platform redelivers later.
+	MaxEvents int `config:"max_events" env:"WEBHOOKS_MAX_EVENTS" default:"10000"`
+	// MaxUnverified and MaxUnverifiedBytes bound the events queued while
+	// the service could not check their signatures.
+	MaxUnverified      int `config:"max_unverified" env:"WEBHOOKS_MAX_UNVERIFIED" default:"100"`
+	MaxUnverifiedBytes int `config:"max_unverified_bytes" env:"WEBHOOKS_MAX_UNVERIFIED_BYTES" default:"16777216"`
+	DedupeWindowMs     int `config:"dedupe_window_ms" env:"WEBHOOKS_DEDUPE_WINDOW_MS" default:"86400000"`
+}
+
+// OutgoingConfig governs delivery of workspace events to outgoing webhook
+// channels. Deliveries and their attempts are logged under Dir.
+type OutgoingConfig struct {
+	Dir            string `config:"dir" env:"OUTGOING_WEBHOOKS_DIR" default:"../data/outgoing-webhooks"`
+	Sync           bool   `config:"sync" env:"OUTGOING_WEBHOOKS_SYNC" default:"true"`
+	TimeoutMs      int    `config:"timeout_ms" env:"OUTGOING_WEBHOOKS_TIMEOUT_MS" default:"10000"`
+	Concurrency    int    `config:"concurrency" env:"OUTGOING_WEBHOOKS_CONCURRENCY" default:"8"`
+	MaxAttempts    int    `config:"max_attempts" env:"OUTGOING_WEBHOOKS_MAX_ATTEMPTS" default:"8"`
+	RetryBaseMs    int    `config:"retry_base_ms" env:"OUTGOING_WEBHOOKS_RETRY_BASE_MS" default:"10000"`
+	RetryMaxMs     int    `config:"retry_max_ms" env:"OUTGOING_WEBHOOKS_RETRY_MAX_MS" default:"3600000"`
+	MaxPending     int    `config:"max_pending" env:"OUTGOING_WEBHOOKS_MAX_PENDING" default:"10000"`
+	KeepPerChannel int    `config:"keep_per_channel" env:"OUTGOING_WEBHOOKS_KEEP_PER_CHANNEL" default:"500"`
+	RetentionMs    int    `config:"retention_ms" env:"OUTGOING_WEBHOOKS_RETENTION_MS" default:"604800000"`
+	// AllowPrivateTargets lets endpoints resolve to private or loopback
+	// addresses. For local development only.
+	AllowPrivateTargets bool `config:"allow_private_targets" env:"OUTGOING_WEBHOOKS_ALLOW_PRIVATE_TARGETS"`
+}
+
+// EmailConfig governs email channels: how often their IMAP mailboxes are
+// polled for new mail, and the limits on what is fetched and sent.
+type EmailConfig struct {
+	Dir             string `config:"dir" env:"EMAIL_DIR" default:"../data/email"`
+	PollIntervalMs  int    `config:"poll_interval_ms" env:"EMAIL_POLL_INTERVAL_MS" default:"60000"`
+	TimeoutMs       int    `config:"timeout_ms" env:"EMAIL_TIMEOUT_MS" default:"30000"`
+	MaxPerPoll      int    `config:"max_per_poll" env:"EMAIL_MAX_PER_POLL" default:"50"`
+	MaxMessageBytes int    `config:"max_message_bytes" env:"EMAIL_MAX_MESSAGE_BYTES" default:"10485760"`
+	MaxThreads      int    `config:"max_threads" env:"EMAIL_MAX_THREADS" default:"10000"`
+	// AllowPrivateHosts lets mail servers resolve to private or loopback
+	// addresses. For local development only.
+	AllowPrivateHosts bool `config:"allow_private_hosts" env:"EMAIL_ALLOW_PRIVATE_HOSTS"`
 }
 
-func buildAllowedOrigins(frontendURL string) []string {
-	origins := []string{
-		"http://localhost:3000",
-		"http://localhost:3001",
-		"http://localhost:3002",
-		"http://127.0.0.1:3000",
-		"http://127.0.0.1:3001",
-		"http://127.0.0.1:3002",
-		"http://[::1]:3000",
-		"http://[::1]:3001",
-		"http://[::1]:3002",
+// BlobConfig selects where uploaded knowledge-base files are stored.
+// Documents record the resulting storage URI, so switching backends only
+// affects new uploads. LocalDir is a directory of its own, not the data
+// directory that also holds the database and logs.
+type BlobConfig struct {
+	Backend  string       `config:"backend" env:"BLOB_BACKEND" default:"local"`
+	LocalDir string       `config:"local_dir" env:"BLOB_LOCAL_DIR" default:"../data/blobs"`
+	S3       BlobS3Config `config:"s3"`
+}
+
+type BlobS3Config struct {
+	Endpoint  string `config:"endpoint" env:"BLOB_S3_ENDPOINT"`
+	Region    string `config:"region" env:"BLOB_S3_REGION" default:"us-east-1"`
+	Bucket    string `config:"bucket" env:"BLOB_S3_BUCKET"`
+	AccessKey string `config:"access_key" env:"BLOB_S3_ACCESS_KEY" secret:"true"`
+	SecretKey string `config:"secret_key" env:"BLOB_S3_SECRET_KEY" secret:"true"`
+	Prefix    string `config:"prefix" env:"BLOB_S3_PREFIX"`
+	PathStyle bool   `config:"path_style" env:"BLOB_S3_PATH_STYLE"`
+	TimeoutMs int    `config:"timeout_ms" env:"BLOB_S3_TIMEOUT_MS" default:"300000"`
+}
+
+type LogConfig struct {
+	Format       string `config:"format" env:"LOG_FORMAT" default:"json"`
+	Level        string `config:"level" env:"LOG_LEVEL" default:"info"`
+	SampleRoutes string `config:"sample_routes" env:"LOG_SAMPLE_ROUTES" default:"/v1/*=10,/runtime/*=10,/internal/tools/web-search=5"`
+}
+
+// Load builds the configuration from defaults, the file at path (YAML or
+// TOML, chosen by extension; skipped when path is empty) and the
+// environment. It returns every problem found, not just the first.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	var errs []error
+
+	var values map[string]any
+	if strings.TrimSpace(path) != "" {
+		var err error
+		values, err = readFile(path)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	frontendURL = strings.TrimSpace(frontendURL)
-	if frontendURL != "" {
-		origins = append(origins, frontendURL)
+	seen := make(map[string]bool)
+	walk(reflect.ValueOf(cfg).Elem(), "", false, func(f field) {
+		raw, source, ok := lookup(f, values, seen)
+		if !ok {
+			return
+		}
+		if err := f.set(raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s (from %s): %w", f.key, source, err))
+		}
+	})
+	for key := range values {
+		if !seen[key] {
+			errs = append(errs, fmt.Errorf("%s: unknown key in %s", key, path))
+		}
 	}
 
-	return origins
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return cfg, errors.Join(errs...)
+	}
+	return cfg, nil
 }
 
-func getEnv(key, fallback string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+// lookup returns the raw value for f, in env > file > default order.
+func lookup(f field, values map[string]any, seen map[string]bool) (any, string, bool) {
+	if f.env != "" {
+		if v, ok := os.LookupEnv(f.env); ok && v != "" {
+			if _, inFile := values[f.key]; inFile {
+				seen[f.key] = true
+			}
+			return v, "env " + f.env, true
+		}
+	}
+	if v, ok := values[f.key]; ok {
+		seen[f.key] = true
+		return v, "file", true
 	}
-	return fallback
+	if f.def != "" {
+		return f.def, "default", true
+	}
+	return nil, "", false
 }
 
-func getIntEnv(key string, fallback int) int {
-	raw := os.Getenv(key)
-	if raw == "" {
-		return fallback
+// Validate checks value ranges and formats, returning all problems joined.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		fail("server.port", "must be a port number, got %q", c.Server.Port)
 	}
-	parsed, err := strconv.Atoi(strings.TrimSpace(raw))
-	if err != nil || parsed <= 0 {
-		return fallback
+	if strings.TrimSpace(c.Server.GRPCAddr) == "" {
+		fail("server.grpc_addr", "is required")
+	}
+	if strings.TrimSpace(c.Auth.JWTSecret) == "" {
+		fail("auth.jwt_secret", "is required")
+	}
+	if strings.TrimSpace(c.Auth.RuntimeSecret) == "" {
+		fail("auth.runtime_secret", "is required")
+	}
+	checkURL := func(key, raw string, required bool) {
+		if strings.TrimSpace(raw) == "" {
+			if required {
+				fail(key, "is required")
+			}
+			return
+		}
+		if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail(key, "must be an http(s) URL, got %q", raw)
+		}
+	}
+	checkURL("proxy.bifrost_addr", c.Proxy.BifrostAddr, true)
+	checkURL("proxy.runtime_addr", c.Proxy.RuntimeAddr, true)
+
+	switch strings.ToLower(strings.TrimSpace(c.Search.Provider)) {
+	case "auto", "duckduckgo", "brave", "searxng", "serpapi", "tavily", "bing", "google", "exa":
+	default:
+		fail("search.provider", "unknown provider %q", c.Search.Provider)
+	}
+	if c.Search.TimeoutMs <= 0 {
+		fail("search.timeout_ms", "must be positive")
+	}
+	checkURL("search.duckduckgo.endpoint", c.Search.DuckDuckGo.Endpoint, false)
+	checkURL("search.brave.endpoint", c.Search.Brave.Endpoint, false)
+	checkURL("search.searxng.endpoint", c.Search.Searxng.Endpoint, false)
+	checkURL("search.serpapi.endpoint", c.Search.SerpAPI.Endpoint, false)
+	checkURL("search.tavily.endpoint", c.Search.Tavily.Endpoint, false)
+	checkURL("search.bing.endpoint", c.Search.Bing.Endpoint, false)
+	checkURL("search.google.endpoint", c.Search.Google.Endpoint, false)
+	checkURL("search.exa.endpoint", c.Search.Exa.Endpoint, false)
+	if (c.Search.Google.APIKey == "") != (c.Search.Google.EngineID == "") {
+		fail("search.google", "api_key and engine_id must be set together")
+	}
+	for provider, quota := range c.Search.Quotas() {
+		if quota < 0 {
+			fail("search."+provider+".monthly_quota", "must not be negative")
+		}
+	}
+
+	if c.RateLimit.RequestsPerMinute < 0 {
+		fail("rate_limit.requests_per_minute", "must not be negative")
+	}
+	if c.RateLimit.Burst < 0 {
+		fail("rate_limit.burst", "must not be negative")
+	}
+
+	for _, origin := range c.CORS.Origins() {
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			fail("cors.allowed_origins", "invalid origin %q", origin)
+		}
+	}
+
+	if c.Authz.CacheTTLMs <= 0 {
+		fail("authz.cache_ttl_ms", "must be positive")
 	}
-	return parsed
+	switch c.Audit.Sink {
+	case "jsonl", "stdout", "grpc", "none":
+	default:
+		fail("audit.sink", "must be one of jsonl, stdout, grpc, none, got %q", c.Audit.Sink)
+	}
+	if c.Audit.Sink == "jsonl" && strings.TrimSpace(c.Audit.LogPath) == "" {
+		fail("audit.log_path", "is required for the jsonl sink")
+	}
+	if c.Idempotency.WindowMs <= 0 {
+		fail("idempotency.window_ms", "must be positive")
+	}
+	if strings.TrimSpace(c.Uploads.Dir) == "" {
+		fail("uploads.dir", "is required")
+	}
+	if c.Uploads.MaxFileBytes <= 0 {
+		fail("uploads.max_file_bytes", "must be positive")
+	}
+	if c.Uploads.MaxChunkBytes <= 0 || c.Uploads.MaxChunkBytes > 10<<20 {
+		fail("uploads.max_chunk_bytes", "must be between 1 and 10485760")
+	}
+	if c.Uploads.SessionTTLMs <= 0 {
+		fail("uploads.session_ttl_ms", "must be positive")
+	}
+	if c.Uploads.GCIntervalMs <= 0 {
+		fail("uploads.gc_interval_ms", "must be positive")
+	}
+	if strings.TrimSpace(c.Imports.Dir) == "" {
+		fail("imports.dir", "is required")
+	}
+	if strings.TrimSpace(c.Webhooks.Dir) == "" {
+		fail("webhooks.dir", "is required")
+	}
+	if c.Webhooks.RetryMaxMs < c.Webhooks.RetryBaseMs {
+		fail("webhooks.retry_max_ms", "must be at least webhooks.retry_base_ms")
+	}
+	if strings.TrimSpace(c.Outgoing.Dir) == "" {
+		fail("outgoing_webhooks.dir", "is required")
+	}
+	if c.Outgoing.RetryMaxMs < c.Outgoing.RetryBaseMs {
+		fail("outgoing_webhooks.retry_max_ms", "must be at least outgoing_webhooks.retry_base_ms")
+	}
+	if strings.TrimSpace(c.Email.Dir) == "" {
+		fail("email.dir", "is required")
+	}
+	for _, limit := range []struct {
+		key   string
+		value int
+	}{
+		{"audit.max_bytes", c.Audit.MaxBytes},
+		{"audit.max_backups", c.Audit.MaxBackups},
+		{"idempotency.max_entries", c.Idempotency.MaxEntries},
+		{"idempotency.max_bytes", c.Idempotency.MaxBytes},
+		{"idempotency.max_response_bytes", c.Idempotency.MaxResponseBytes},
+		{"imports.concurrency", c.Imports.Concurrency},
+		{"imports.max_entries", c.Imports.MaxEntries},
+		{"imports.max_total_bytes", c.Imports.MaxTotalBytes},
+		{"imports.max_compression_ratio", c.Imports.MaxRatio},
+		{"imports.max_urls", c.Imports.MaxURLs},
+		{"imports.url_timeout_ms", c.Imports.URLTimeoutMs},
+		{"imports.job_ttl_ms", c.Imports.JobTTLMs},
+		{"webhooks.verify_timeout_ms", c.Webhooks.VerifyTimeoutMs},
+		{"webhooks.deliver_timeout_ms", c.Webhooks.DeliverTimeoutMs},
+		{"webhooks.concurrency", c.Webhooks.Concurrency},
+		{"webhooks.max_attempts", c.Webhooks.MaxAttempts},
+		{"webhooks.retry_base_ms", c.Webhooks.RetryBaseMs},
+		{"webhooks.retry_max_ms", c.Webhooks.RetryMaxMs},
+		{"webhooks.max_events", c.Webhooks.MaxEvents},
+		{"webhooks.max_unverified", c.Webhooks.MaxUnverified},
+		{"webhooks.max_unverified_bytes", c.Webhooks.MaxUnverifiedBytes},
+		{"webhooks.dedupe_window_ms", c.Webhooks.DedupeWindowMs},
+		{"outgoing_webhooks.timeout_ms", c.Outgoing.TimeoutMs},
+		{"outgoing_webhooks.concurrency", c.Outgoing.Concurrency},
+		{"outgoing_webhooks.max_attempts", c.Outgoing.MaxAttempts},
+		{"outgoing_webhooks.retry_base_ms", c.Outgoing.RetryBaseMs},
+		{"outgoing_webhooks.retry_max_ms", c.Outgoing.RetryMaxMs},
+		{"outgoing_webhooks.max_pending", c.Outgoing.MaxPending},
+		{"outgoing_webhooks.keep_per_channel", c.Outgoing.KeepPerChannel},
+		{"outgoing_webhooks.retention_ms", c.Outgoing.RetentionMs},
+		{"email.poll_interval_ms", c.Email.PollIntervalMs},
+		{"email.timeout_ms", c.Email.TimeoutMs},
+		{"email.max_per_poll", c.Email.MaxPerPoll},
+		{"email.max_message_bytes", c.Email.MaxMessageBytes},
+		{"email.max_threads", c.Email.MaxThreads},
+	} {
+		if limit.value <= 0 {
+			fail(limit.key, "must be positive")
+		}
+	}
+	switch strings.ToLower(c.Uploads.Scan.Backend) {
+	case "none":
+	case "clamd":
+		if strings.TrimSpace(c.Uploads.Scan.ClamdAddr) == "" {
+			fail("uploads.scan.clamd_addr", "is required for the clamd backend")
+		}
+		if c.Uploads.Scan.TimeoutMs <= 0 {
+			fail("uploads.scan.timeout_ms", "must be positive")
+		}
+	default:
+		fail("uploads.scan.backend", "must be none or clamd, got %q", c.Uploads.Scan.Backend)
+	}
+	switch strings.ToLower(c.Uploads.Scan.OnInfected) {
+	case "reject", "quarantine":
+	default:
+		fail("uploads.scan.on_infected", "must be reject or quarantine, got %q", c.Uploads.Scan.OnInfected)
+	}
+	switch strings.ToLower(c.Blob.Backend) {
+	case "local":
+		if strings.TrimSpace(c.Blob.LocalDir) == "" {
+			fail("blob.local_dir", "is required for the local backend")
+		}
+	case "s3":
+		checkURL("blob.s3.endpoint", c.Blob.S3.Endpoint, true)
+		if strings.TrimSpace(c.Blob.S3.Bucket) == "" {
+			fail("blob.s3.bucket", "is required for the s3 backend")
+		}
+		if c.Blob.S3.AccessKey == "" || c.Blob.S3.SecretKey == "" {
+			fail("blob.s3.access_key", "access_key and secret_key are required for the s3 backend")
+		}
+		if c.Blob.S3.TimeoutMs <= 0 {
+			fail("blob.s3.timeout_ms", "must be positive")
+		}
+	default:
+		fail("blob.backend", "must be local or s3, got %q", c.Blob.Backend)
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case "json", "text":
+	default:
+		fail("log.format", "must be json or text, got %q", c.Log.Format)
+	}
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "warning", "error":
+	default:
+		fail("log.level", "must be debug, info, warn or error, got %q", c.Log.Level)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Warnings returns warnings when default/insecure secrets are still in use.
+func (c *Config) Warnings() []string {
+	var warnings []string
+	if c.Auth.JWTSecret == "dev-secret-change-in-production" {
+		warnings = append(warnings, "JWT_SECRET is using the default development value — set a strong secret in production")
+	}
+	if c.Auth.RuntimeSecret == "dev-runtime-secret" {
+		warnings = append(warnings, "RUNTIME_SECRET is using the default development value — set a strong secret in production")
+	}
+	return warnings
 }
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFileAndEnv(t *testing.T) {
+	yamlPath := writeConfig(t, "gateway.yaml", `
+server:
+  port: "9090"   # comment
+search:
+  provider: brave
+  brave:
+    api_key: "from-file"
+cors:
+  allowed_origins:
+    - https://app.example.com
+  frontend_url: https://admin.example.com
+`)
+	tomlPath := writeConfig(t, "gateway.toml", `
+[server]
+port = "9090"
+
+[search]
+provider = "brave"
+
+[search.brave]
+api_key = "from-file" # comment
+
+[cors]
+allowed_origins = ["https://app.example.com"]
+frontend_url = "https://admin.example.com"
+`)
+	for _, path := range []string{yamlPath, tomlPath} {
+		t.Run(filepath.Ext(path), func(t *testing.T) {
+			t.Setenv("BRAVE_SEARCH_API_KEY", "from-env")
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.Server.Port != "9090" || cfg.Search.Provider != "brave" {
+				t.Errorf("file values not applied: %+v", cfg.Server)
+			}
+			if cfg.Search.Brave.APIKey != "from-env" {
+				t.Errorf("env should override file, got %q", cfg.Search.Brave.APIKey)
+			}
+			if cfg.Search.TimeoutMs != 12000 || cfg.Proxy.BifrostAddr != "http://localhost:8081" {
+				t.Errorf("defaults not applied: %+v %+v", cfg.Search, cfg.Proxy)
+			}
+			want := []string{"https://app.example.com", "https://admin.example.com"}
+			if got := cfg.CORS.Origins(); !reflect.DeepEqual(got, want) {
+				t.Errorf("Origins() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadReportsAllErrors(t *testing.T) {
+	t.Setenv("AUTHZ_CACHE_TTL_MS", "soon")
+	path := writeConfig(t, "gateway.yaml", `
+server:
+  port: "0"
+search:
+  provider: nope
+  timeout_ms: 0
+unknown: true
+`)
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	for _, want := range []string{
+		"authz.cache_ttl_ms (from env AUTHZ_CACHE_TTL_MS)",
+		"server.port",
+		"search.provider",
+		"search.timeout_ms",
+		"unknown: unknown key",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error missing %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestDumpRedactsSecrets(t *testing.T) {
+	t.Setenv("JWT_SECRET", "super-secret-jwt")
+	t.Setenv("SERPAPI_API_KEY", "serp-key")
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := cfg.Dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "super-secret-jwt") || strings.Contains(out, "serp-key") {
+		t.Fatalf("secret leaked:\n%s", out)
+	}
+	if !strings.Contains(out, `auth.jwt_secret = "[REDACTED]"`) || !strings.Contains(out, `search.brave.api_key = ""`) {
+		t.Errorf("unexpected dump:\n%s", out)
+	}
+}
+
+func TestReloadAppliesOnlyReloadableFields(t *testing.T) {
+	path := writeConfig(t, "gateway.toml", `
+[server]
+port = "8080"
+[rate_limit]
+requests_per_minute = 60
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloader := NewReloader(path, cfg)
+	var applied *Config
+	reloader.OnReload(func(next *Config) { applied = next })
+
+	os.WriteFile(path, []byte(`
+[server]
+port = "9999"
+[rate_limit]
+requests_per_minute = 120
+[cors]
+allowed_origins = ["https://new.example.com"]
+`), 0o600)
+	skipped, err := reloader.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(skipped, []string{"server.port"}) {
+		t.Errorf("skipped = %v, want [server.port]", skipped)
+	}
+	if applied == nil || applied.RateLimit.RequestsPerMinute != 120 || applied.Server.Port != "8080" {
+		t.Fatalf("applied = %+v", applied)
+	}
+	if !reflect.DeepEqual(applied.CORS.AllowedOrigins, []string{"https://new.example.com"}) {
+		t.Errorf("cors not reloaded: %v", applied.CORS.AllowedOrigins)
+	}
+	if cfg.RateLimit.RequestsPerMinute != 60 {
+		t.Error("reload must not mutate the previous config")
+	}
+
+	os.WriteFile(path, []byte("[rate_limit]\nrequests_per_minute = -1\n"), 0o600)
+	if _, err := reloader.Reload(); err == nil {
+		t.Error("invalid config should be rejected")
+	}
+	if reloader.Current().RateLimit.RequestsPerMinute != 120 {
+		t.Error("failed reload must keep the current config")
+	}
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/redact"
+)
+
+// field is one leaf of Config, addressed by its dotted file key.
+type field struct {
+	key    string
+	env    string
+	def    string
+	secret bool
+	reload bool
+	value  reflect.Value
+}
+
+// walk visits every leaf field of v (a struct), depth first in declaration
+// order. reload is inherited from enclosing sections.
+func walk(v reflect.Value, prefix string, reload bool, visit func(field)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := sf.Tag.Get("config")
+		if name == "" {
+			continue
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		fieldReload := reload || sf.Tag.Get("reload") == "true"
+		if sf.Type.Kind() == reflect.Struct {
+			walk(v.Field(i), key, fieldReload, visit)
+			continue
+		}
+		visit(field{
+			key:    key,
+			env:    sf.Tag.Get("env"),
+			def:    sf.Tag.Get("default"),
+			secret: sf.Tag.Get("secret") == "true",
+			reload: fieldReload,
+			value:  v.Field(i),
+		})
+	}
+}
+
+// set assigns raw (a string from env/defaults, or a value parsed from the
+// config file) to the field, converting to the field's type.
+func (f field) set(raw any) error {
+	switch f.value.Kind() {
+	case reflect.String:
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("expected a string")
+		}
+		f.value.SetString(strings.TrimSpace(s))
+	case reflect.Int:
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("expected an integer")
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("expected an integer, got %q", s)
+		}
+		f.value.SetInt(int64(n))
+	case reflect.Bool:
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("expected a boolean")
+		}
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("expected a boolean, got %q", s)
+		}
+		f.value.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		switch v := raw.(type) {
+		case []string:
+			items = v
+		case string:
+			items = strings.Split(v, ",")
+		default:
+			return fmt.Errorf("expected a list")
+		}
+		out := make([]string, 0, len(items))
+		for _, item := range items {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+		f.value.Set(reflect.ValueOf(out))
+	default:
+		return fmt.Errorf("unsupported field type %s", f.value.Type())
+	}
+	return nil
+}
+
+// Redacted returns the effective configuration as dotted key → value, with
+// secret fields masked (or empty when unset).
+func (c *Config) Redacted() map[string]any {
+	out := make(map[string]any)
+	walk(reflect.ValueOf(c).Elem(), "", false, func(f field) {
+		value := f.value.Interface()
+		if f.secret && !f.value.IsZero() {
+			value = redact.Mask
+		}
+		out[f.key] = value
+	})
+	return out
+}
+
+// Dump writes the effective configuration, secrets masked, one
+// "key = value" line per field in sorted order.
+func (c *Config) Dump(w io.Writer) error {
+	values := c.Redacted()
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, err := fmt.Fprintf(w, "%s = %s\n", key, formatValue(values[key])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatValue(v any) string {
+	switch value := v.(type) {
+	case string:
+		return strconv.Quote(value)
+	case []string:
+		quoted := make([]string, len(value))
+		for i, item := range value {
+			quoted[i] = strconv.Quote(item)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// readFile parses a YAML (.yaml/.yml) or TOML (.toml) config file into a flat
+// map of dotted keys to string or []string values.
+//
+// Both formats are parsed in full; the config only needs nested tables of
+// scalars and lists of scalars, so a list of maps is rejected with its key.
+// Every value is kept as a string (or []string); typing happens in Load.
+func readFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config %s: %w", path, err)
+	}
+	var values map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		values, err = parseYAML(data)
+	case ".toml":
+		values, err = parseTOML(data)
+	default:
+		return nil, fmt.Errorf("config %s: unsupported format (want .yaml, .yml or .toml)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return values, nil
+}
+
+func parseYAML(data []byte) (map[string]any, error) {
+	var doc map[string]any
+	if err := yaml.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	values := make(map[string]any)
+	return values, flatten(values, "", doc)
+}
+
+func parseTOML(data []byte) (map[string]any, error) {
+	var doc map[string]any
+	if _, err := toml.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+		return nil, err
+	}
+	values := make(map[string]any)
+	return values, flatten(values, "", doc)
+}
+
+// flatten stores the leaves of table under dotted keys beneath prefix.
+func flatten(values map[string]any, prefix string, table map[string]any) error {
+	keys := make([]string, 0, len(table))
+	for key := range table {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, name := range keys {
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		switch v := table[name].(type) {
+		case map[string]any:
+			if err := flatten(values, key, v); err != nil {
+				return err
+			}
+		case []any:
+			list := make([]string, 0, len(v))
+			for _, item := range v {
+				s, ok := scalarString(item)
+				if !ok {
+					return fmt.Errorf("%s: lists may only hold strings, numbers or booleans", key)
+				}
+				list = append(list, s)
+			}
+			values[key] = list
+		case map[any]any:
+			return fmt.Errorf("%s: map keys must be strings", key)
+		case []map[string]any:
+			return fmt.Errorf("%s: lists may only hold strings, numbers or booleans", key)
+		default:
+			s, ok := scalarString(v)
+			if !ok {
+				return fmt.Errorf("%s: unsupported value of type %T", key, v)
+			}
+			values[key] = s
+		}
+	}
+	return nil
+}
+
+// scalarString renders a decoded scalar as the string Load expects. A null
+// value is the empty string.
+func scalarString(v any) (string, bool) {
+	switch s := v.(type) {
+	case nil:
+		return "", true
+	case string:
+		return s, true
+	case bool:
+		return strconv.FormatBool(s), true
+	case int:
+		return strconv.Itoa(s), true
+	case int64:
+		return strconv.FormatInt(s, 10), true
+	case uint64:
+		return strconv.FormatUint(s, 10), true
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64), true
+	case time.Time:
+		return s.Format(time.RFC3339Nano), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want map[string]any
+	}{
+		{
+			name: "tables and dotted keys",
+			src: `
+top = "x"
+[search]
+provider = 'brave' # comment
+brave.api_key = "k#1"
+[search.tavily]
+endpoint = "https://api.tavily.com"
+`,
+			want: map[string]any{
+				"top":                    "x",
+				"search.provider":        "brave",
+				"search.brave.api_key":   "k#1",
+				"search.tavily.endpoint": "https://api.tavily.com",
+			},
+		},
+		{
+			name: "multi-line array with comments and trailing comma",
+			src: `
+[cors]
+allowed_origins = [
+  "https://a.example.com", # first
+  "https://b.example.com",
+]
+`,
+			want: map[string]any{
+				"cors.allowed_origins": []string{"https://a.example.com", "https://b.example.com"},
+			},
+		},
+		{
+			name: "typed scalars, inline tables and multi-line strings",
+			src: `
+[server]
+port = 9090
+sync = true
+[search]
+brave = { api_key = "k" }
+note = """
+hi"""
+`,
+			want: map[string]any{
+				"server.port":          "9090",
+				"server.sync":          "true",
+				"search.brave.api_key": "k",
+				"search.note":          "hi",
+			},
+		},
+		{
+			name: "empty array",
+			src:  "names = []",
+			want: map[string]any{"names": []string{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTOML([]byte(tt.src))
+			if err != nil {
+				t.Fatalf("parseTOML: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTOML = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want map[string]any
+	}{
+		{
+			name: "nested maps and scalars",
+			src: `---
+server:
+  port: 9090   # comment
+  grpc_addr: 'localhost:50051'
+search:
+  provider: brave
+  brave:
+    api_key: ~
+`,
+			want: map[string]any{
+				"server.port":          "9090",
+				"server.grpc_addr":     "localhost:50051",
+				"search.provider":      "brave",
+				"search.brave.api_key": "",
+			},
+		},
+		{
+			name: "block and flow lists",
+			src: `
+cors:
+  allowed_origins:
+    - https://a.example.com
+    - "https://b.example.com"
+  frontend_urls: [
+    https://app.example.com,
+  ]
+`,
+			want: map[string]any{
+				"cors.allowed_origins": []string{"https://a.example.com", "https://b.example.com"},
+				"cors.frontend_urls":   []string{"https://app.example.com"},
+			},
+		},
+		{
+			name: "anchors, block scalars and flow maps",
+			src: `
+defaults: &defaults
+  timeout_ms: 500
+search: *defaults
+note: |
+  hi
+brave: {api_key: k}
+`,
+			want: map[string]any{
+				"defaults.timeout_ms": "500",
+				"search.timeout_ms":   "500",
+				"note":                "hi\n",
+				"brave.api_key":       "k",
+			},
+		},
+		{
+			name: "empty document",
+			src:  "# nothing configured\n",
+			want: map[string]any{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseYAML([]byte(tt.src))
+			if err != nil {
+				t.Fatalf("parseYAML: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseYAML = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRejectsInvalid(t *testing.T) {
+	tests := []struct {
+		name, format, src, wantErr string
+	}{
+		{"yaml list of maps", "yaml", "hooks:\n  - url: https://x\n    secret: s", "hooks: lists may only hold"},
+		{"yaml syntax", "yaml", "server:\n\tport: 1", "line 2"},
+		{"toml array of tables", "toml", "[[hooks]]\nurl = \"x\"", "hooks: lists may only hold"},
+		{"toml syntax", "toml", "[search]\nprovider", "line 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parse := parseYAML
+			if tt.format == "toml" {
+				parse = parseTOML
+			}
+			_, err := parse([]byte(tt.src))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parse error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
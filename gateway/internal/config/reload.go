@@ -0,0 +1,102 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+)
+
+// Reloader re-reads the configuration on demand (typically SIGHUP) and
+// applies only the fields tagged reload:"true" — CORS origins, rate limits
+// and search provider settings. Changes to any other field are reported as
+// requiring a restart and otherwise ignored.
+type Reloader struct {
+	path string
+
+	mu          sync.Mutex
+	current     *Config
+	subscribers []func(*Config)
+}
+
+func NewReloader(path string, current *Config) *Reloader {
+	return &Reloader{path: path, current: current}
+}
+
+// Current returns the configuration in effect.
+func (r *Reloader) Current() *Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// OnReload registers fn to receive the new configuration after each
+// successful reload.
+func (r *Reloader) OnReload(fn func(*Config)) {
+	r.mu.Lock()
+	r.subscribers = append(r.subscribers, fn)
+	r.mu.Unlock()
+}
+
+// Reload loads and validates the configuration again. On any error the
+// current configuration stays in effect. It returns the keys that changed
+// but were not applied because they need a restart.
+func (r *Reloader) Reload() ([]string, error) {
+	loaded, err := Load(r.path)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	next := *r.current
+	var skipped []string
+	nextFields := make(map[string]field)
+	walk(reflect.ValueOf(&next).Elem(), "", false, func(f field) { nextFields[f.key] = f })
+	walk(reflect.ValueOf(loaded).Elem(), "", false, func(f field) {
+		dst := nextFields[f.key]
+		if reflect.DeepEqual(dst.value.Interface(), f.value.Interface()) {
+			return
+		}
+		if !f.reload {
+			skipped = append(skipped, f.key)
+			return
+		}
+		dst.value.Set(f.value)
+	})
+	r.current = &next
+	subscribers := append([]func(*Config){}, r.subscribers...)
+	r.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(&next)
+	}
+	return skipped, nil
+}
+
+// WatchSignals reloads on every SIGHUP until ctx is done.
+func (r *Reloader) WatchSignals(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				skipped, err := r.Reload()
+				if err != nil {
+					slog.Error("config reload failed; keeping current config", slog.Any("err", err))
+					continue
+				}
+				if len(skipped) > 0 {
+					slog.Warn("config reload: changes require a restart", slog.Any("keys", skipped))
+				}
+				slog.Info("config reloaded", slog.Any("config", r.Current().Redacted()))
+			}
+		}
+	}()
+}
@@ -0,0 +1,239 @@
+// Package cron parses the cron expressions scheduled tasks use and
+// computes their fire times. It accepts what the service's scheduler
+// accepts: five fields (minute hour day-of-month month day-of-week), or
+// six with a leading seconds field, each a list of values, ranges and
+// steps, with month and weekday names; or one of the presets @yearly,
+// @annually, @monthly, @weekly, @daily, @midnight and @hourly.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	second, minute, hour, dom, month, dow uint64
+	// domAny and dowAny record a day field starting with "*". When both
+	// day fields are restricted, a day matching either fires.
+	domAny, dowAny bool
+}
+
+type bounds struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	secondBounds = bounds{name: "second", min: 0, max: 59}
+	minuteBounds = bounds{name: "minute", min: 0, max: 59}
+	hourBounds   = bounds{name: "hour", min: 0, max: 23}
+	domBounds    = bounds{name: "day-of-month", min: 1, max: 31}
+	monthBounds  = bounds{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Sunday is 0 or 7.
+	dowBounds = bounds{name: "day-of-week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// presets are the "@" shorthands and the five-field expressions they
+// stand for.
+var presets = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a five- or six-field cron expression or a preset.
+func Parse(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@") {
+		preset, ok := presets[expr]
+		if !ok {
+			return nil, fmt.Errorf("unknown preset %q", expr)
+		}
+		expr = preset
+	}
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("expected 5 or 6 fields, got %d", len(fields))
+	}
+	s := &Schedule{domAny: strings.HasPrefix(fields[3], "*"), dowAny: strings.HasPrefix(fields[5], "*")}
+	var err error
+	for i, f := range []struct {
+		set *uint64
+		b   bounds
+	}{
+		{&s.second, secondBounds}, {&s.minute, minuteBounds}, {&s.hour, hourBounds},
+		{&s.dom, domBounds}, {&s.month, monthBounds}, {&s.dow, dowBounds},
+	} {
+		if *f.set, err = parseField(fields[i], f.b); err != nil {
+			return nil, err
+		}
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+func parseField(field string, b bounds) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(field, ",") {
+		rng, stepText, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("%s field: invalid step %q", b.name, stepText)
+			}
+			step = n
+		}
+		lo, hi := b.min, b.max
+		switch loText, hiText, isRange := strings.Cut(rng, "-"); {
+		case rng == "*":
+		case isRange:
+			var err error
+			if lo, err = b.value(loText); err != nil {
+				return 0, err
+			}
+			if hi, err = b.value(hiText); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("%s field: range %q runs backwards", b.name, rng)
+			}
+		default:
+			v, err := b.value(rng)
+			if err != nil {
+				return 0, err
+			}
+			// "5/15" runs from 5 to the end of the range.
+			lo = v
+			if !hasStep {
+				hi = v
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func (b bounds) value(text string) (int, error) {
+	if v, ok := b.names[strings.ToLower(text)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("%s field: invalid value %q", b.name, text)
+	}
+	if v < b.min || v > b.max {
+		return 0, fmt.Errorf("%s field: %d is outside %d-%d", b.name, v, b.min, b.max)
+	}
+	return v, nil
+}
+
+// searchYears bounds how far Next looks; an expression with no time in
+// that span (such as February 30th) never fires.
+const searchYears = 5
+
+// Next returns the first fire time after t, in t's location, or the zero
+// time if there is none. Local times skipped by a daylight-saving change
+// do not fire; local times it repeats fire at both instants.
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Second).Add(time.Second)
+	limit := t.Year() + searchYears
+	// Each loop moves to the start of the next candidate unit; once one
+	// field has moved, the smaller fields start from their minimum.
+	moved := false
+wrap:
+	if t.Year() > limit {
+		return time.Time{}
+	}
+	for !has(s.month, int(t.Month())) {
+		if !moved {
+			moved = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+	for !s.dayMatches(t) {
+		if !moved {
+			moved = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		}
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+	for !has(s.hour, t.Hour()) {
+		if !moved {
+			moved = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+		}
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+	for !has(s.minute, t.Minute()) {
+		if !moved {
+			moved = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+	for !has(s.second, t.Second()) {
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto wrap
+		}
+	}
+	return t
+}
+
+// NextN returns up to n fire times after t.
+func (s *Schedule) NextN(t time.Time, n int) []time.Time {
+	var out []time.Time
+	for len(out) < n {
+		if t = s.Next(t); t.IsZero() {
+			break
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom, dow := has(s.dom, t.Day()), has(s.dow, int(t.Weekday()))
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func has(set uint64, v int) bool { return set&(1<<uint(v)) != 0 }
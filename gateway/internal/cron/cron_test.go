@@ -0,0 +1,79 @@
+package cron
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	newYork, _ := time.LoadLocation("America/New_York")
+	cases := []struct {
+		expr  string
+		after time.Time
+		want  []string
+	}{
+		{"*/15 * * * *", time.Date(2026, 3, 1, 10, 7, 30, 0, time.UTC),
+			[]string{"2026-03-01T10:15:00Z", "2026-03-01T10:30:00Z", "2026-03-01T10:45:00Z"}},
+		{"30 9 * * mon-fri", time.Date(2026, 10, 16, 9, 30, 0, 0, shanghai), // a Friday, at the fire time
+			[]string{"2026-10-19T09:30:00+08:00", "2026-10-20T09:30:00+08:00"}},
+		{"0 0 0 1,15 * *", time.Date(2026, 12, 20, 0, 0, 0, 0, time.UTC),
+			[]string{"2027-01-01T00:00:00Z", "2027-01-15T00:00:00Z"}},
+		// Both day fields restricted: the 13th or any Friday.
+		{"0 12 13 * 5", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+			[]string{"2026-02-06T12:00:00Z", "2026-02-13T12:00:00Z", "2026-02-20T12:00:00Z"}},
+		{"0 0 29 feb 7", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+			[]string{"2026-02-08T00:00:00Z", "2026-02-15T00:00:00Z"}},
+		// 02:30 does not exist on 2026-03-08 in New York.
+		{"30 2 * * *", time.Date(2026, 3, 7, 12, 0, 0, 0, newYork),
+			[]string{"2026-03-09T02:30:00-04:00"}},
+		{"0 0 30 2 *", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), nil},
+		{"@yearly", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+			[]string{"2027-01-01T00:00:00Z", "2028-01-01T00:00:00Z"}},
+		{"@annually", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+			[]string{"2027-01-01T00:00:00Z"}},
+		{"@monthly", time.Date(2026, 3, 1, 0, 0, 0, 0, shanghai),
+			[]string{"2026-04-01T00:00:00+08:00", "2026-05-01T00:00:00+08:00"}},
+		{"@weekly", time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC), // a Friday
+			[]string{"2026-10-18T00:00:00Z", "2026-10-25T00:00:00Z"}},
+		{"@daily", time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC),
+			[]string{"2026-10-17T00:00:00Z", "2026-10-18T00:00:00Z"}},
+		{" @midnight ", time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC),
+			[]string{"2026-10-17T00:00:00Z"}},
+		{"@hourly", time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC),
+			[]string{"2026-10-16T13:00:00Z", "2026-10-16T14:00:00Z"}},
+	}
+	for _, c := range cases {
+		s, err := Parse(c.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", c.expr, err)
+			continue
+		}
+		n := max(len(c.want), 1)
+		var got []string
+		for _, next := range s.NextN(c.after, n) {
+			got = append(got, next.Format(time.RFC3339))
+		}
+		if strings.Join(got, " ") != strings.Join(c.want, " ") {
+			t.Errorf("%q after %s = %v, want %v", c.expr, c.after, got, c.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for expr, want := range map[string]string{
+		"* * * *":         "expected 5 or 6 fields",
+		"60 * * * *":      "minute field: 60 is outside 0-59",
+		"* * * 13 *":      "month field: 13 is outside 1-12",
+		"* * * * fri-mon": "day-of-week field: range",
+		"*/0 * * * *":     "minute field: invalid step",
+		"* 1,,2 * * *":    "hour field: invalid value",
+		"@every 5m":       `unknown preset "@every 5m"`,
+		"@Daily":          `unknown preset "@Daily"`,
+	} {
+		if _, err := Parse(expr); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Parse(%q) = %v, want %q", expr, err, want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package filecheck
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+type kind int
+
+const (
+	kindText kind = iota
+	kindHTML
+	kindPDF
+	kindOOXML
+	kindOLE
+	kindRTF
+)
+
+// supportedTypes maps every accepted extension to the content it must
+// carry. OOXML types also name the part directory their archive needs.
+var supportedTypes = map[string]struct {
+	kind kind
+	part string
+}{
+	"txt": {kind: kindText}, "text": {kind: kindText}, "md": {kind: kindText}, "markdown": {kind: kindText},
+	"csv": {kind: kindText}, "tsv": {kind: kindText}, "json": {kind: kindText},
+	"yaml": {kind: kindText}, "yml": {kind: kindText}, "log": {kind: kindText},
+	"html": {kind: kindHTML}, "htm": {kind: kindHTML},
+	"pdf":  {kind: kindPDF},
+	"docx": {kind: kindOOXML, part: "word/"},
+	"xlsx": {kind: kindOOXML, part: "xl/"},
+	"pptx": {kind: kindOOXML, part: "ppt/"},
+	"doc":  {kind: kindOLE}, "xls": {kind: kindOLE}, "ppt": {kind: kindOLE},
+	"rtf": {kind: kindRTF},
+}
+
+var (
+	pdfMagic = []byte("%PDF-")
+	zipMagic = []byte("PK\x03\x04")
+	oleMagic = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+	rtfMagic = []byte(`{\rtf`)
+)
+
+// SupportedTypes lists every type Detect can return.
+func SupportedTypes() []string {
+	out := make([]string, 0, len(supportedTypes))
+	for t := range supportedTypes {
+		out = append(out, t)
+	}
+	return out
+}
+
+// ExtensionType returns the lower-case extension of name, or "" when it
+// has none.
+func ExtensionType(name string) string {
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+}
+
+// Detect sniffs the content of a file and returns its document type and
+// MIME type. A file whose extension names a supported type must look like
+// that type; one without a recognised extension is typed by content alone.
+func Detect(name string, r io.ReaderAt, size int64) (fileType, mime string, err error) {
+	head := make([]byte, min(size, 512))
+	if _, err := r.ReadAt(head, 0); err != nil && err != io.EOF {
+		return "", "", err
+	}
+	mime = http.DetectContentType(head)
+
+	ext := ExtensionType(name)
+	want, known := supportedTypes[ext]
+	if !known {
+		fileType = sniffType(head, mime, r, size)
+		if fileType == "" {
+			return "", mime, reject(CodeUnsupportedType, "unsupported file type (detected %s)", mime)
+		}
+		return fileType, mime, nil
+	}
+
+	ok := false
+	switch want.kind {
+	case kindText, kindHTML:
+		// DetectContentType calls UTF-8/16 text "text/plain" and anything
+		// starting with markup "text/html"; both are fine for either kind.
+		ok = strings.HasPrefix(mime, "text/plain") || strings.HasPrefix(mime, "text/html")
+	case kindPDF:
+		ok = bytes.HasPrefix(head, pdfMagic)
+	case kindOOXML:
+		ok = bytes.HasPrefix(head, zipMagic) && ooxmlPart(r, size) == want.part
+	case kindOLE:
+		ok = bytes.HasPrefix(head, oleMagic)
+	case kindRTF:
+		ok = bytes.HasPrefix(head, rtfMagic)
+	}
+	if !ok {
+		return "", mime, reject(CodeTypeMismatch, "file content (%s) does not match its .%s extension", mime, ext)
+	}
+	return ext, mime, nil
+}
+
+// sniffType types a file without a usable extension.
+func sniffType(head []byte, mime string, r io.ReaderAt, size int64) string {
+	switch {
+	case bytes.HasPrefix(head, pdfMagic):
+		return "pdf"
+	case bytes.HasPrefix(head, rtfMagic):
+		return "rtf"
+	case bytes.HasPrefix(head, zipMagic):
+		for t, info := range supportedTypes {
+			if info.kind == kindOOXML && info.part == ooxmlPart(r, size) {
+				return t
+			}
+		}
+	case strings.HasPrefix(mime, "text/html"):
+		return "html"
+	case strings.HasPrefix(mime, "text/plain"):
+		return "txt"
+	}
+	return ""
+}
+
+// ooxmlPart returns the main part directory ("word/", "xl/", "ppt/") of an
+// Office Open XML archive, or "" if r is not one.
+func ooxmlPart(r io.ReaderAt, size int64) string {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return ""
+	}
+	hasContentTypes := false
+	part := ""
+	for _, f := range zr.File {
+		if f.Name == "[Content_Types].xml" {
+			hasContentTypes = true
+		}
+		for _, p := range []string{"word/", "xl/", "ppt/"} {
+			if part == "" && strings.HasPrefix(f.Name, p) {
+				part = p
+			}
+		}
+	}
+	if !hasContentTypes {
+		return ""
+	}
+	return part
+}
@@ -0,0 +1,120 @@
+// Package filecheck decides whether an uploaded knowledge-base file may be
+// stored: its content must match a supported type, the workspace policy
+// must allow that type, size and storage use, and a malware scanner must
+// not flag it. Every refusal is a *Rejection carrying a stable code.
+package filecheck
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Rejection codes returned to clients.
+const (
+	CodeUnsupportedType = "UNSUPPORTED_FILE_TYPE"
+	CodeTypeMismatch    = "FILE_TYPE_MISMATCH"
+	CodeTypeNotAllowed  = "FILE_TYPE_NOT_ALLOWED"
+	CodeFileTooLarge    = "FILE_TOO_LARGE"
+	CodeQuotaExceeded   = "STORAGE_QUOTA_EXCEEDED"
+	CodeMalware         = "MALWARE_DETECTED"
+	CodeQuarantined     = "FILE_QUARANTINED"
+	CodeScanUnavailable = "SCAN_UNAVAILABLE"
+)
+
+// Rejection explains why a file was refused.
+type Rejection struct {
+	Code    string
+	Message string
+}
+
+func (r *Rejection) Error() string { return r.Message }
+
+// HTTPStatus maps the rejection to a response status.
+func (r *Rejection) HTTPStatus() int {
+	switch r.Code {
+	case CodeUnsupportedType, CodeTypeMismatch, CodeTypeNotAllowed:
+		return http.StatusUnsupportedMediaType
+	case CodeFileTooLarge, CodeQuotaExceeded:
+		return http.StatusRequestEntityTooLarge
+	case CodeScanUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusUnprocessableEntity
+	}
+}
+
+func reject(code, format string, args ...any) *Rejection {
+	return &Rejection{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Policy limits what a workspace may upload. Zero values mean no limit
+// and an empty AllowedTypes allows every supported type.
+type Policy struct {
+	AllowedTypes      []string
+	MaxFileBytes      int64
+	StorageQuotaBytes int64
+	// UsedBytes is what the workspace's documents already occupy.
+	UsedBytes int64
+}
+
+// Narrow combines the gateway-wide limits in p with a workspace policy;
+// the workspace can only tighten them.
+func (p Policy) Narrow(ws Policy) Policy {
+	out := p
+	switch {
+	case len(p.AllowedTypes) == 0:
+		out.AllowedTypes = ws.AllowedTypes
+	case len(ws.AllowedTypes) > 0:
+		out.AllowedTypes = nil
+		for _, t := range ws.AllowedTypes {
+			if slices.Contains(p.AllowedTypes, t) {
+				out.AllowedTypes = append(out.AllowedTypes, t)
+			}
+		}
+		if len(out.AllowedTypes) == 0 {
+			// Nothing in common: allow nothing rather than everything.
+			out.AllowedTypes = []string{""}
+		}
+	}
+	if ws.MaxFileBytes > 0 && (out.MaxFileBytes <= 0 || ws.MaxFileBytes < out.MaxFileBytes) {
+		out.MaxFileBytes = ws.MaxFileBytes
+	}
+	out.StorageQuotaBytes = ws.StorageQuotaBytes
+	out.UsedBytes = ws.UsedBytes
+	return out
+}
+
+// Check validates a file of the given type and size against the policy.
+func (p Policy) Check(fileType string, size int64) error {
+	if len(p.AllowedTypes) > 0 && !slices.Contains(p.AllowedTypes, fileType) {
+		return reject(CodeTypeNotAllowed, "file type %q is not allowed in this workspace", fileType)
+	}
+	return p.CheckSize(size)
+}
+
+// CheckSize validates only the size, for when the type is not known yet.
+func (p Policy) CheckSize(size int64) error {
+	if p.MaxFileBytes > 0 && size > p.MaxFileBytes {
+		return reject(CodeFileTooLarge, "file is %d bytes, the limit is %d", size, p.MaxFileBytes)
+	}
+	if p.StorageQuotaBytes > 0 && p.UsedBytes+size > p.StorageQuotaBytes {
+		return reject(CodeQuotaExceeded, "workspace storage quota of %d bytes would be exceeded (%d used)",
+			p.StorageQuotaBytes, p.UsedBytes)
+	}
+	return nil
+}
+
+// NormalizeTypes lower-cases, trims and de-duplicates a type list,
+// dropping leading dots.
+func NormalizeTypes(types []string) []string {
+	var out []string
+	for _, t := range types {
+		t = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(t)), ".")
+		if t != "" && !slices.Contains(out, t) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
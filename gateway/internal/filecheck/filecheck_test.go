@@ -0,0 +1,172 @@
+package filecheck
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func zipWith(t *testing.T, names ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, "<x/>")
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDetect(t *testing.T) {
+	docx := zipWith(t, "[Content_Types].xml", "word/document.xml")
+	plainZip := zipWith(t, "readme.txt")
+	cases := []struct {
+		name     string
+		content  []byte
+		wantType string
+		wantCode string
+	}{
+		{"notes.md", []byte("# Title\n\nbody"), "md", ""},
+		{"data.csv", []byte("a,b\n1,2\n"), "csv", ""},
+		{"page.md", []byte("<!-- front matter -->\n# x"), "md", ""},
+		{"report.pdf", []byte("%PDF-1.7\n..."), "pdf", ""},
+		{"report.pdf", []byte("MZ\x90\x00 not a pdf"), "", CodeTypeMismatch},
+		{"spec.docx", docx, "docx", ""},
+		{"spec.docx", plainZip, "", CodeTypeMismatch},
+		{"sheet.xlsx", docx, "", CodeTypeMismatch},
+		{"legacy.doc", append([]byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}, 0, 0), "doc", ""},
+		{"notes.txt", []byte{0x7f, 'E', 'L', 'F', 2, 1, 1, 0, 0, 0}, "", CodeTypeMismatch},
+		{"upload", []byte("%PDF-1.4"), "pdf", ""},
+		{"upload.bin", docx, "docx", ""},
+		{"upload", []byte("plain words"), "txt", ""},
+		{"setup.exe", []byte("MZ\x90\x00\x03\x00\x00\x00"), "", CodeUnsupportedType},
+	}
+	for _, tc := range cases {
+		gotType, _, err := Detect(tc.name, bytes.NewReader(tc.content), int64(len(tc.content)))
+		if tc.wantCode != "" {
+			var rej *Rejection
+			if !errors.As(err, &rej) || rej.Code != tc.wantCode {
+				t.Errorf("Detect(%s) err = %v, want %s", tc.name, err, tc.wantCode)
+			}
+			continue
+		}
+		if err != nil || gotType != tc.wantType {
+			t.Errorf("Detect(%s) = %q, %v; want %q", tc.name, gotType, err, tc.wantType)
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	gateway := Policy{AllowedTypes: []string{"pdf", "md", "txt"}, MaxFileBytes: 100}
+	p := gateway.Narrow(Policy{AllowedTypes: []string{"md", "docx"}, MaxFileBytes: 50, StorageQuotaBytes: 1000, UsedBytes: 960})
+
+	check := func(fileType string, size int64, wantCode string) {
+		t.Helper()
+		err := p.Check(fileType, size)
+		if wantCode == "" {
+			if err != nil {
+				t.Errorf("Check(%s, %d) = %v", fileType, size, err)
+			}
+			return
+		}
+		var rej *Rejection
+		if !errors.As(err, &rej) || rej.Code != wantCode {
+			t.Errorf("Check(%s, %d) = %v, want %s", fileType, size, err, wantCode)
+		}
+	}
+	check("md", 40, "")
+	check("pdf", 10, CodeTypeNotAllowed)  // dropped by the workspace
+	check("docx", 10, CodeTypeNotAllowed) // never allowed by the gateway
+	check("md", 60, CodeFileTooLarge)     // workspace limit is tighter
+	check("md", 41, CodeQuotaExceeded)
+
+	none := gateway.Narrow(Policy{AllowedTypes: []string{"docx"}})
+	if err := none.Check("pdf", 1); err == nil {
+		t.Error("disjoint type lists should allow nothing")
+	}
+	if got := NormalizeTypes([]string{" .PDF", "pdf", "", "Md"}); strings.Join(got, ",") != "pdf,md" {
+		t.Errorf("NormalizeTypes = %v", got)
+	}
+}
+
+// fakeClamd answers one INSTREAM request, reporting EICAR when the stream
+// contains it.
+func fakeClamd(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				if cmd, _ := br.ReadString(0); cmd != "zINSTREAM\x00" {
+					io.WriteString(conn, "UNKNOWN COMMAND\x00")
+					return
+				}
+				var data []byte
+				for {
+					var size uint32
+					if err := binary.Read(br, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					chunk := make([]byte, size)
+					if _, err := io.ReadFull(br, chunk); err != nil {
+						return
+					}
+					data = append(data, chunk...)
+				}
+				if bytes.Contains(data, []byte("EICAR-STANDARD-ANTIVIRUS-TEST-FILE")) {
+					io.WriteString(conn, "stream: Eicar-Test-Signature FOUND\x00")
+					return
+				}
+				io.WriteString(conn, "stream: OK\x00")
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestClamdScanner(t *testing.T) {
+	scanner, err := NewClamdScanner("tcp://"+fakeClamd(t), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	// Large enough to span several INSTREAM chunks.
+	clean := strings.Repeat("harmless text ", 20000)
+	if v, err := scanner.Scan(ctx, strings.NewReader(clean)); err != nil || !v.Clean {
+		t.Fatalf("clean file: %+v, %v", v, err)
+	}
+	eicar := `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+	v, err := scanner.Scan(ctx, strings.NewReader(eicar))
+	if err != nil || v.Clean || v.Signature != "Eicar-Test-Signature" {
+		t.Fatalf("eicar: %+v, %v", v, err)
+	}
+	if _, err := parseClamdReply("INSTREAM size limit exceeded. ERROR"); err == nil {
+		t.Error("error reply should fail")
+	}
+}
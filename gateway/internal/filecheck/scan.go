@@ -0,0 +1,123 @@
+package filecheck
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// Verdict is a scanner's opinion of a file.
+type Verdict struct {
+	Clean bool
+	// Signature names what was found when Clean is false.
+	Signature string
+}
+
+// Scanner inspects file content for malware.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Verdict, error)
+}
+
+// NoopScanner passes every file.
+type NoopScanner struct{}
+
+func (NoopScanner) Scan(context.Context, io.Reader) (Verdict, error) {
+	return Verdict{Clean: true}, nil
+}
+
+// ClamdScanner streams files to a ClamAV-compatible daemon with the
+// INSTREAM command.
+type ClamdScanner struct {
+	network string
+	addr    string
+	timeout time.Duration
+}
+
+const clamdChunkSize = 64 << 10
+
+// NewClamdScanner accepts "tcp://host:port", "unix:///path/clamd.sock" or
+// a bare host:port.
+func NewClamdScanner(addr string, timeout time.Duration) (*ClamdScanner, error) {
+	network, address := "tcp", strings.TrimSpace(addr)
+	if rest, ok := strings.CutPrefix(address, "unix://"); ok {
+		network, address = "unix", rest
+	} else {
+		address = strings.TrimPrefix(address, "tcp://")
+	}
+	if address == "" {
+		return nil, fmt.Errorf("clamd address is required")
+	}
+	if timeout <= 0 {
+		timeout = 2 * time.Minute
+	}
+	return &ClamdScanner{network: network, addr: address, timeout: timeout}, nil
+}
+
+func (s *ClamdScanner) Scan(ctx context.Context, r io.Reader) (Verdict, error) {
+	dialer := net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(ctx, s.network, s.addr)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("clamd dial: %w", err)
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(s.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	if _, err := io.WriteString(conn, "zINSTREAM\x00"); err != nil {
+		return Verdict{}, fmt.Errorf("clamd write: %w", err)
+	}
+	buf := make([]byte, clamdChunkSize)
+	var size [4]byte
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			if _, err := conn.Write(size[:]); err != nil {
+				return Verdict{}, fmt.Errorf("clamd write: %w", err)
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				// clamd hangs up once the stream passes its StreamMaxLength;
+				// its reply says so.
+				break
+			}
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			binary.BigEndian.PutUint32(size[:], 0)
+			if _, err := conn.Write(size[:]); err != nil {
+				return Verdict{}, fmt.Errorf("clamd write: %w", err)
+			}
+			break
+		}
+		if readErr != nil {
+			return Verdict{}, readErr
+		}
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return Verdict{}, fmt.Errorf("clamd read: %w", err)
+	}
+	return parseClamdReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// parseClamdReply reads "stream: OK", "stream: <name> FOUND" or
+// "<reason> ERROR".
+func parseClamdReply(reply string) (Verdict, error) {
+	_, result, _ := strings.Cut(reply, ": ")
+	switch {
+	case result == "OK":
+		return Verdict{Clean: true}, nil
+	case strings.HasSuffix(result, " FOUND"):
+		return Verdict{Signature: strings.TrimSuffix(result, " FOUND")}, nil
+	default:
+		return Verdict{}, fmt.Errorf("clamd: %s", reply)
+	}
+}
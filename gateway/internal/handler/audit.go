@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/audit"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/middleware"
+)
+
+type AuditHandler struct {
+	querier audit.Querier
+}
+
+// NewAuditHandler serves audit queries from sink when it supports reading
+// entries back; otherwise the endpoint answers 501.
+func NewAuditHandler(sink audit.Sink) *AuditHandler {
+	querier, _ := sink.(audit.Querier)
+	return &AuditHandler{querier: querier}
+}
+
+func (h *AuditHandler) ListOrgAuditEntries(w http.ResponseWriter, r *http.Request) {
+	if h.querier == nil {
+		writeError(w, http.StatusNotImplemented, "audit sink does not support queries")
+		return
+	}
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	// Entries are recorded under the org ID; the route may name it by slug.
+	orgID := chi.URLParam(r, "orgId")
+	if scope, ok := middleware.GetScope(r); ok && scope.OrgID != "" {
+		orgID = scope.OrgID
+	}
+	entries, err := h.querier.Recent(r.Context(), audit.Filter{
+		OrgID:       orgID,
+		WorkspaceID: q.Get("workspaceId"),
+		UserID:      q.Get("userId"),
+		Limit:       limit,
+	})
+	if err != nil {
+		writeGRPCError(w, r, err)
+		return
+	}
+	writeData(w, http.StatusOK, entries)
+}
+
+// AuditScopeResolver attributes audit entries on resource routes
+// (/sessions/{sessionId}, /channels/{channelId}, /agents/{agentId}, ...) to
+// the resource's workspace, through the Authorizer's cached lookup, and via
+// the caller's memberships to its org.
+func AuditScopeResolver(authorizer *middleware.Authorizer) audit.ScopeResolver {
+	return func(ctx context.Context, params map[string]string) (string, string) {
+		user, ok := ctx.Value(middleware.UserContextKey).(middleware.UserClaims)
+		if !ok {
+			return "", ""
+		}
+		wsID, err := authorizer.ResourceWorkspace(ctx, user, func(param string) string { return params[param] })
+		if err != nil || wsID == "" {
+			return "", ""
+		}
+		m, err := authorizer.Resolve(ctx, user)
+		if err != nil {
+			return "", wsID
+		}
+		return m.Workspaces[wsID], wsID
+	}
+}
@@ -66,7 +66,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 	resp, err := h.clients.Auth.Login(r.Context(), &req)
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, authResponse(resp))
@@ -84,7 +84,7 @@ func (h *AuthHandler) Signup(w http.ResponseWriter, r *http.Request) {
 	}
 	resp, err := h.clients.Auth.Signup(r.Context(), &req)
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusCreated, authResponse(resp))
@@ -109,7 +109,7 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, map[string]any{"data": nil})
@@ -127,7 +127,7 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 		RefreshToken: body.RefreshToken,
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, map[string]any{
@@ -153,7 +153,7 @@ func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, map[string]any{
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/blob"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/logging"
+)
+
+// BlobsHandler lets internal services read and delete stored uploads by
+// storage URI, so they never need the gateway's disk or S3 credentials:
+//
+//	GET    /internal/blobs?uri=<storage uri>
+//	DELETE /internal/blobs?uri=<storage uri>
+type BlobsHandler struct {
+	blobs         blob.Store
+	runtimeSecret string
+}
+
+func NewBlobsHandler(blobs blob.Store, runtimeSecret string) *BlobsHandler {
+	return &BlobsHandler{blobs: blobs, runtimeSecret: runtimeSecret}
+}
+
+func (h *BlobsHandler) GetBlob(w http.ResponseWriter, r *http.Request) {
+	uri, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+	rc, err := h.blobs.Open(r.Context(), uri)
+	if err != nil {
+		writeBlobError(w, r, err)
+		return
+	}
+	defer rc.Close()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, rc); err != nil {
+		logging.FromContext(r.Context()).Warn("stream blob", slog.String("storage_uri", uri), slog.Any("err", err))
+	}
+}
+
+func (h *BlobsHandler) DeleteBlob(w http.ResponseWriter, r *http.Request) {
+	uri, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+	if err := h.blobs.Delete(r.Context(), uri); err != nil {
+		writeBlobError(w, r, err)
+		return
+	}
+	writeData(w, http.StatusOK, nil)
+}
+
+func (h *BlobsHandler) authorize(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if !authorizeRuntime(w, r, h.runtimeSecret) {
+		return "", false
+	}
+	uri := r.URL.Query().Get("uri")
+	if uri == "" {
+		writeError(w, http.StatusBadRequest, "uri is required")
+		return "", false
+	}
+	return uri, true
+}
+
+func writeBlobError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, blob.ErrNotFound):
+		writeError(w, http.StatusNotFound, "blob not found")
+	case errors.Is(err, blob.ErrForeignURI):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		logging.FromContext(r.Context()).Error("blob request failed", slog.Any("err", err))
+		writeError(w, http.StatusBadGateway, "blob storage unavailable")
+	}
+}
@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/logging"
+	channelspb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/channels"
+)
+
+const (
+	channelMessagePageSize = 50
+	channelMessageMaxPage  = 200
+	// Exports page through the history; past this many rows they stop.
+	channelMessageMaxExport = 50000
+)
+
+// channelMessagesRequest builds the RPC request from the query string:
+// limit, cursor, chatId, threadId, direction, agentId, from, to, q, order.
+func channelMessagesRequest(r *http.Request) *channelspb.ListChannelMessagesRequest {
+	q := r.URL.Query()
+	limit := int32(channelMessagePageSize)
+	if raw := q.Get("limit"); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 && n <= channelMessageMaxPage {
+			limit = int32(n)
+		}
+	}
+	return &channelspb.ListChannelMessagesRequest{
+		ChannelId:   chi.URLParam(r, "channelId"),
+		Limit:       limit,
+		Cursor:      q.Get("cursor"),
+		ChatId:      q.Get("chatId"),
+		ThreadId:    q.Get("threadId"),
+		Direction:   strings.ToLower(strings.TrimSpace(q.Get("direction"))),
+		AgentId:     q.Get("agentId"),
+		From:        q.Get("from"),
+		To:          q.Get("to"),
+		Q:           strings.TrimSpace(q.Get("q")),
+		Order:       strings.ToLower(strings.TrimSpace(q.Get("order"))),
+		UserContext: userCtxFromRequest(r),
+	}
+}
+
+// ListChannelMessages returns one page of a channel's message history,
+// newest first. nextCursor is empty on the last page. With format=csv or
+// format=ndjson the whole filtered history is streamed as a download.
+func (h *ChannelsHandler) ListChannelMessages(w http.ResponseWriter, r *http.Request) {
+	req := channelMessagesRequest(r)
+	format := strings.TrimSpace(strings.ToLower(r.URL.Query().Get("format")))
+	if format != "" && format != "csv" && format != "ndjson" {
+		writeError(w, http.StatusBadRequest, "format must be csv or ndjson")
+		return
+	}
+	if format != "" {
+		req.Limit = channelMessageMaxPage
+	}
+
+	resp, err := h.clients.Channels.ListChannelMessages(r.Context(), req)
+	if err != nil {
+		writeGRPCError(w, r, err)
+		return
+	}
+	if format == "" {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"data":       resp.GetMessages(),
+			"nextCursor": resp.GetNextCursor(),
+		})
+		return
+	}
+	h.exportChannelMessages(w, r, req, resp, format)
+}
+
+// Export outcomes, sent in the X-Export-Status trailer. A truncated or
+// failed export also ends with a marker row, since the 200 status line is
+// long gone by then and not every client reads trailers.
+const (
+	exportComplete  = "complete"
+	exportTruncated = "truncated"
+	exportFailed    = "failed"
+)
+
+// exportChannelMessages streams the first page it is given and keeps
+// fetching pages until the cursor runs out or the export cap is hit.
+func (h *ChannelsHandler) exportChannelMessages(w http.ResponseWriter, r *http.Request, req *channelspb.ListChannelMessagesRequest, resp *channelspb.ListChannelMessagesResponse, format string) {
+	fileName := fmt.Sprintf("channel-messages-%s-%s.%s", req.GetChannelId(), time.Now().UTC().Format("20060102-150405"), format)
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson; charset=utf-8")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", fileName))
+	w.Header().Set("Trailer", "X-Export-Status")
+	w.WriteHeader(http.StatusOK)
+
+	var write func(*channelspb.ChannelMessage) error
+	var mark func(status string, rows int) error
+	var flush func() error
+	if format == "csv" {
+		writer := csv.NewWriter(w)
+		_ = writer.Write([]string{
+			"id", "channelId", "direction", "chatId", "threadId", "sender", "agentId",
+			"messageType", "status", "platformMessageId", "content", "createdAt",
+		})
+		write = func(m *channelspb.ChannelMessage) error {
+			row := []string{
+				m.GetId(), m.GetChannelId(), m.GetDirection(), m.GetChatId(), m.GetThreadId(), m.GetSender(), m.GetAgentId(),
+				m.GetMessageType(), m.GetStatus(), m.GetPlatformMessageId(), m.GetContent(), m.GetCreatedAt(),
+			}
+			for i, cell := range row {
+				row[i] = csvSafeCell(cell)
+			}
+			return writer.Write(row)
+		}
+		mark = func(status string, rows int) error {
+			return writer.Write([]string{fmt.Sprintf("# export %s after %d rows", status, rows)})
+		}
+		flush = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	} else {
+		encoder := json.NewEncoder(w)
+		write = func(m *channelspb.ChannelMessage) error { return encoder.Encode(m) }
+		mark = func(status string, rows int) error {
+			return encoder.Encode(map[string]any{"exportStatus": status, "rows": rows})
+		}
+		flush = func() error { return nil }
+	}
+	finish := func(status string, rows int) {
+		if status != exportComplete {
+			_ = mark(status, rows)
+		}
+		_ = flush()
+		w.Header().Set("X-Export-Status", status)
+	}
+
+	written := 0
+	for {
+		for _, m := range resp.GetMessages() {
+			if written == channelMessageMaxExport {
+				logging.FromContext(r.Context()).Warn("channel message export truncated",
+					slog.String("channel_id", req.GetChannelId()), slog.Int("rows", written))
+				finish(exportTruncated, written)
+				return
+			}
+			if err := write(m); err != nil {
+				return
+			}
+			written++
+		}
+		if err := flush(); err != nil {
+			return
+		}
+		if resp.GetNextCursor() == "" {
+			finish(exportComplete, written)
+			return
+		}
+		req.Cursor = resp.GetNextCursor()
+		var err error
+		if resp, err = h.clients.Channels.ListChannelMessages(r.Context(), req); err != nil {
+			logging.FromContext(r.Context()).Error("channel message export failed",
+				slog.String("channel_id", req.GetChannelId()), slog.Int("rows", written), slog.Any("err", err))
+			finish(exportFailed, written)
+			return
+		}
+	}
+}
+
+// csvSafeCell defuses spreadsheet formula injection. Message fields come
+// from chat users, and a cell starting with =, +, -, @, tab or CR is run as
+// a formula when the export is opened in a spreadsheet, so such cells are
+// prefixed with a quote to be read as text.
+func csvSafeCell(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/grpcclient"
+	channelspb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/channels"
+)
+
+// pagedChannels serves total messages in pages, failing the page at failAt
+// (a row offset) when it is positive.
+type pagedChannels struct {
+	channelspb.ChannelsServiceClient
+	total, failAt int
+}
+
+func (p *pagedChannels) ListChannelMessages(_ context.Context, in *channelspb.ListChannelMessagesRequest, _ ...grpc.CallOption) (*channelspb.ListChannelMessagesResponse, error) {
+	start, _ := strconv.Atoi(in.GetCursor())
+	if p.failAt > 0 && start >= p.failAt {
+		return nil, errors.New("channels service unavailable")
+	}
+	end := min(start+int(in.GetLimit()), p.total)
+	resp := &channelspb.ListChannelMessagesResponse{}
+	for i := start; i < end; i++ {
+		resp.Messages = append(resp.Messages, &channelspb.ChannelMessage{Id: strconv.Itoa(i), ChannelId: in.GetChannelId()})
+	}
+	if end < p.total {
+		resp.NextCursor = strconv.Itoa(end)
+	}
+	return resp, nil
+}
+
+func TestExportChannelMessagesReportsOutcome(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		channels   *pagedChannels
+		wantRows   int
+		wantStatus string
+		wantMarker string
+	}{
+		{"complete", "ndjson", &pagedChannels{total: 450}, 450, exportComplete, ""},
+		{"truncated", "ndjson", &pagedChannels{total: channelMessageMaxExport + 50}, channelMessageMaxExport, exportTruncated, `{"exportStatus":"truncated","rows":50000}`},
+		{"failed mid-export", "csv", &pagedChannels{total: 1000, failAt: 400}, 400, exportFailed, "# export failed after 400 rows"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewChannelsHandler(&grpcclient.Clients{Channels: tt.channels}, "secret", nil)
+			r := httptest.NewRequest(http.MethodGet, "/channels/ch-1/messages?format="+tt.format, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("channelId", "ch-1")
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+			h.ListChannelMessages(w, r)
+
+			res := w.Result()
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d", res.StatusCode)
+			}
+			if got := res.Trailer.Get("X-Export-Status"); got != tt.wantStatus {
+				t.Errorf("X-Export-Status trailer = %q, want %q", got, tt.wantStatus)
+			}
+			lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+			rows := len(lines)
+			if tt.format == "csv" {
+				rows-- // header
+			}
+			if tt.wantMarker != "" {
+				if last := lines[len(lines)-1]; last != tt.wantMarker {
+					t.Errorf("last line = %q, want marker %q", last, tt.wantMarker)
+				}
+				rows--
+			} else {
+				var last map[string]any
+				json.Unmarshal([]byte(lines[len(lines)-1]), &last)
+				if _, ok := last["exportStatus"]; ok {
+					t.Errorf("complete export ends with a marker: %v", last)
+				}
+			}
+			if rows != tt.wantRows {
+				t.Errorf("exported %d rows, want %d", rows, tt.wantRows)
+			}
+		})
+	}
+}
+
+// fixedChannels serves one page of messages.
+type fixedChannels struct {
+	channelspb.ChannelsServiceClient
+	messages []*channelspb.ChannelMessage
+}
+
+func (f *fixedChannels) ListChannelMessages(context.Context, *channelspb.ListChannelMessagesRequest, ...grpc.CallOption) (*channelspb.ListChannelMessagesResponse, error) {
+	return &channelspb.ListChannelMessagesResponse{Messages: f.messages}, nil
+}
+
+func TestExportChannelMessagesCSVEscapesFormulas(t *testing.T) {
+	channels := &fixedChannels{messages: []*channelspb.ChannelMessage{
+		{Id: "1", Sender: "@SUM(A1:A9)", ChatId: "-1001", Content: `=HYPERLINK("http://evil.example","x")`},
+		{Id: "2", Sender: "+cmd", ChatId: "\tchat", Content: "\r=1+1"},
+		{Id: "3", Sender: "alice", Content: "hello = world"},
+	}}
+	h := NewChannelsHandler(&grpcclient.Clients{Channels: channels}, "secret", nil)
+	r := httptest.NewRequest(http.MethodGet, "/channels/ch-1/messages?format=csv", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("channelId", "ch-1")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+	w := httptest.NewRecorder()
+	h.ListChannelMessages(w, r)
+
+	records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Columns: chatId 3, sender 5, content 10.
+	want := [][3]string{
+		{"'-1001", "'@SUM(A1:A9)", `'=HYPERLINK("http://evil.example","x")`},
+		{"'\tchat", "'+cmd", "'\r=1+1"},
+		{"", "alice", "hello = world"},
+	}
+	for i, row := range records[1:] {
+		if got := [3]string{row[3], row[5], row[10]}; got != want[i] {
+			t.Errorf("row %d = %q, want %q", i+1, got, want[i])
+		}
+	}
+}
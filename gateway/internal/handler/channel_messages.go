@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	channelspb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/channels"
+)
+
+// Outbound message types.
+const (
+	messageTypeText     = "text"
+	messageTypeMarkdown = "markdown"
+	messageTypeImage    = "image"
+	messageTypeFile     = "file"
+	messageTypeCard     = "card"
+)
+
+// outboundMessageBody is the runtime-facing message envelope. Legacy
+// callers send only chatId, text and threadId, which is a text message.
+//
+// Attachments for image and file messages are sent as multipart/form-data:
+// the envelope as JSON in the "payload" field and the file in "file".
+type outboundMessageBody struct {
+	ChatId   string `json:"chatId"`
+	ThreadId string `json:"threadId"`
+	Text     string `json:"text"`
+	Message  *struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+		// Card is the platform card payload (a Feishu card, Slack blocks...).
+		// Blocks is accepted as an alias.
+		Card   json.RawMessage `json:"card"`
+		Blocks json.RawMessage `json:"blocks"`
+	} `json:"message"`
+}
+
+func (b outboundMessageBody) toProto() (*channelspb.OutboundMessage, error) {
+	if b.Message == nil {
+		if strings.TrimSpace(b.Text) == "" {
+			return nil, fmt.Errorf("text is required")
+		}
+		return &channelspb.OutboundMessage{Type: messageTypeText, Text: b.Text}, nil
+	}
+	msg := &channelspb.OutboundMessage{
+		Type: strings.ToLower(strings.TrimSpace(b.Message.Type)),
+		Text: b.Message.Text,
+	}
+	if msg.Type == "" {
+		msg.Type = messageTypeText
+	}
+	card := b.Message.Card
+	if len(card) == 0 {
+		card = b.Message.Blocks
+	}
+	switch msg.Type {
+	case messageTypeText, messageTypeMarkdown:
+		if strings.TrimSpace(msg.Text) == "" {
+			return nil, fmt.Errorf("message.text is required for %s messages", msg.Type)
+		}
+	case messageTypeCard:
+		if len(card) == 0 || string(card) == "null" {
+			return nil, fmt.Errorf("message.card is required for card messages")
+		}
+		msg.CardJson = string(card)
+	case messageTypeImage, messageTypeFile:
+	default:
+		return nil, fmt.Errorf("message.type must be one of text, markdown, image, file, card")
+	}
+	return msg, nil
+}
+
+// SendChannelMessage posts a message to the channel platform on behalf of
+// the runtime and returns the platform message ID.
+func (h *ChannelsHandler) SendChannelMessage(w http.ResponseWriter, r *http.Request) {
+	if !authorizeRuntime(w, r, h.runtimeSecret) {
+		return
+	}
+	channelID := chi.URLParam(r, "channelId")
+	var body outboundMessageBody
+	var file multipart.File
+	var fileHeader *multipart.FileHeader
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid multipart form data")
+			return
+		}
+		if err := json.Unmarshal([]byte(r.FormValue("payload")), &body); err != nil {
+			writeError(w, http.StatusBadRequest, "payload must be a JSON message envelope")
+			return
+		}
+		var err error
+		if file, fileHeader, err = r.FormFile("file"); err == nil {
+			defer file.Close()
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if strings.TrimSpace(body.ChatId) == "" {
+		writeError(w, http.StatusBadRequest, "chatId is required")
+		return
+	}
+	msg, err := body.toProto()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	needsFile := msg.Type == messageTypeImage || msg.Type == messageTypeFile
+	switch {
+	case needsFile && file == nil:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("%s messages need a multipart \"file\" part", msg.Type))
+		return
+	case !needsFile && file != nil:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("%s messages take no attachment", msg.Type))
+		return
+	}
+
+	if file != nil {
+		attachment, err := h.storeAttachment(r, channelID, file, fileHeader)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to store attachment")
+			return
+		}
+		// The platform keeps its own copy once the message is sent.
+		defer deleteOrphanBlob(r, h.blobs, attachment.GetStorageUri())
+		msg.Attachments = []*channelspb.OutboundAttachment{attachment}
+	}
+
+	resp, err := h.clients.Channels.SendRichChannelMessage(r.Context(), &channelspb.SendRichChannelMessageRequest{
+		ChannelId:   channelID,
+		ChatId:      body.ChatId,
+		ThreadId:    body.ThreadId,
+		Message:     msg,
+		UserContext: userCtxFromRequest(r),
+	})
+	if err != nil {
+		writeGRPCError(w, r, err)
+		return
+	}
+	writeData(w, http.StatusOK, sentMessageMap(resp))
+}
+
+func (h *ChannelsHandler) storeAttachment(r *http.Request, channelID string, file multipart.File, header *multipart.FileHeader) (*channelspb.OutboundAttachment, error) {
+	mimeType := header.Header.Get("Content-Type")
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		sniff := make([]byte, 512)
+		n, _ := io.ReadFull(file, sniff)
+		mimeType = http.DetectContentType(sniff[:n])
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	name := sanitizeUploadFileName(header.Filename)
+	key := fmt.Sprintf("channel-attachments/%s/%d-%s-%s", channelID, time.Now().UnixMilli(), uuid.NewString(), name)
+	uri, err := h.blobs.Put(r.Context(), key, file, header.Size)
+	if err != nil {
+		return nil, err
+	}
+	return &channelspb.OutboundAttachment{Name: name, MimeType: mimeType, Size: header.Size, StorageUri: uri}, nil
+}
+
+// UpdateChannelMessage edits a message the runtime sent earlier. Only
+// text, markdown and card messages can be edited.
+func (h *ChannelsHandler) UpdateChannelMessage(w http.ResponseWriter, r *http.Request) {
+	if !authorizeRuntime(w, r, h.runtimeSecret) {
+		return
+	}
+	var body outboundMessageBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	msg, err := body.toProto()
+	if err == nil && (msg.Type == messageTypeImage || msg.Type == messageTypeFile) {
+		err = fmt.Errorf("%s messages cannot be edited", msg.Type)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	resp, err := h.clients.Channels.UpdateChannelMessage(r.Context(), &channelspb.UpdateChannelMessageRequest{
+		ChannelId:   chi.URLParam(r, "channelId"),
+		ChatId:      body.ChatId,
+		MessageId:   chi.URLParam(r, "messageId"),
+		Message:     msg,
+		UserContext: userCtxFromRequest(r),
+	})
+	if err != nil {
+		writeGRPCError(w, r, err)
+		return
+	}
+	writeData(w, http.StatusOK, sentMessageMap(resp))
+}
+
+// DeleteChannelMessage recalls a message the runtime sent earlier.
+func (h *ChannelsHandler) DeleteChannelMessage(w http.ResponseWriter, r *http.Request) {
+	if !authorizeRuntime(w, r, h.runtimeSecret) {
+		return
+	}
+	_, err := h.clients.Channels.DeleteChannelMessage(r.Context(), &channelspb.DeleteChannelMessageRequest{
+		ChannelId:   chi.URLParam(r, "channelId"),
+		ChatId:      r.URL.Query().Get("chatId"),
+		MessageId:   chi.URLParam(r, "messageId"),
+		UserContext: userCtxFromRequest(r),
+	})
+	if err != nil {
+		writeGRPCError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func sentMessageMap(item *channelspb.SentChannelMessage) map[string]any {
+	return map[string]any{
+		"messageId": item.GetMessageId(),
+		"chatId":    item.GetChatId(),
+	}
+}
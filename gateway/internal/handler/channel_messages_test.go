@@ -0,0 +1,261 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/blob"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/grpcclient"
+	channelspb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/channels"
+)
+
+func TestOutboundMessageBodyToProto(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    *channelspb.OutboundMessage
+		wantErr string
+	}{
+		{
+			name: "legacy text",
+			body: `{"chatId": "c", "text": "hi"}`,
+			want: &channelspb.OutboundMessage{Type: messageTypeText, Text: "hi"},
+		},
+		{
+			name:    "legacy without text",
+			body:    `{"chatId": "c", "text": "  "}`,
+			wantErr: "text is required",
+		},
+		{
+			name: "type defaults to text",
+			body: `{"message": {"text": "hi"}}`,
+			want: &channelspb.OutboundMessage{Type: messageTypeText, Text: "hi"},
+		},
+		{
+			name: "markdown is normalized",
+			body: `{"message": {"type": " Markdown ", "text": "*hi*"}}`,
+			want: &channelspb.OutboundMessage{Type: messageTypeMarkdown, Text: "*hi*"},
+		},
+		{
+			name:    "markdown without text",
+			body:    `{"message": {"type": "markdown"}}`,
+			wantErr: "message.text is required for markdown messages",
+		},
+		{
+			name: "card",
+			body: `{"message": {"type": "card", "card": {"header": "x"}}}`,
+			want: &channelspb.OutboundMessage{Type: messageTypeCard, CardJson: `{"header": "x"}`},
+		},
+		{
+			name: "blocks alias",
+			body: `{"message": {"type": "card", "blocks": [{"type": "section"}]}}`,
+			want: &channelspb.OutboundMessage{Type: messageTypeCard, CardJson: `[{"type": "section"}]`},
+		},
+		{
+			name:    "null card",
+			body:    `{"message": {"type": "card", "card": null}}`,
+			wantErr: "message.card is required",
+		},
+		{
+			name: "file caption",
+			body: `{"message": {"type": "file", "text": "report"}}`,
+			want: &channelspb.OutboundMessage{Type: messageTypeFile, Text: "report"},
+		},
+		{
+			name:    "unknown type",
+			body:    `{"message": {"type": "video"}}`,
+			wantErr: "message.type must be one of",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body outboundMessageBody
+			if err := json.Unmarshal([]byte(tt.body), &body); err != nil {
+				t.Fatal(err)
+			}
+			got, err := body.toProto()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("toProto: %v", err)
+			}
+			if got.Type != tt.want.Type || got.Text != tt.want.Text || got.CardJson != tt.want.CardJson {
+				t.Errorf("toProto = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeChannels records SendRichChannelMessage calls.
+type fakeChannels struct {
+	channelspb.ChannelsServiceClient
+	sent    []*channelspb.SendRichChannelMessageRequest
+	blobs   blob.Store
+	present []bool // whether each attachment's blob existed at send time
+}
+
+func (f *fakeChannels) SendRichChannelMessage(ctx context.Context, in *channelspb.SendRichChannelMessageRequest, _ ...grpc.CallOption) (*channelspb.SentChannelMessage, error) {
+	f.sent = append(f.sent, in)
+	for _, a := range in.GetMessage().GetAttachments() {
+		rc, err := f.blobs.Open(ctx, a.GetStorageUri())
+		if err == nil {
+			rc.Close()
+		}
+		f.present = append(f.present, err == nil)
+	}
+	return &channelspb.SentChannelMessage{MessageId: "m-1", ChatId: in.GetChatId()}, nil
+}
+
+func multipartMessage(t *testing.T, payload string, file []byte, contentType string) *http.Request {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("payload", payload)
+	if file != nil {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", `form-data; name="file"; filename="../chart.png"`)
+		if contentType != "" {
+			header.Set("Content-Type", contentType)
+		}
+		part, _ := mw.CreatePart(header)
+		part.Write(file)
+	}
+	mw.Close()
+	r := httptest.NewRequest(http.MethodPost, "/channels/ch-1/messages", &buf)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestSendChannelMessageAttachments(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 32)...)
+	tests := []struct {
+		name       string
+		req        func(t *testing.T) *http.Request
+		wantStatus int
+		wantErr    string
+		wantMime   string
+	}{
+		{
+			name: "image with sniffed type",
+			req: func(t *testing.T) *http.Request {
+				return multipartMessage(t, `{"chatId": "c", "message": {"type": "image"}}`, png, "application/octet-stream")
+			},
+			wantStatus: http.StatusOK,
+			wantMime:   "image/png",
+		},
+		{
+			name: "file with declared type",
+			req: func(t *testing.T) *http.Request {
+				return multipartMessage(t, `{"chatId": "c", "message": {"type": "file", "text": "q3"}}`, []byte("a,b\n"), "text/csv")
+			},
+			wantStatus: http.StatusOK,
+			wantMime:   "text/csv",
+		},
+		{
+			name: "image without file",
+			req: func(t *testing.T) *http.Request {
+				return multipartMessage(t, `{"chatId": "c", "message": {"type": "image"}}`, nil, "")
+			},
+			wantStatus: http.StatusBadRequest,
+			wantErr:    "image messages need a multipart",
+		},
+		{
+			name: "text with file",
+			req: func(t *testing.T) *http.Request {
+				return multipartMessage(t, `{"chatId": "c", "message": {"type": "text", "text": "hi"}}`, png, "image/png")
+			},
+			wantStatus: http.StatusBadRequest,
+			wantErr:    "text messages take no attachment",
+		},
+		{
+			name: "payload is not JSON",
+			req: func(t *testing.T) *http.Request {
+				return multipartMessage(t, `chatId=c`, png, "image/png")
+			},
+			wantStatus: http.StatusBadRequest,
+			wantErr:    "payload must be a JSON message envelope",
+		},
+		{
+			name: "missing chat",
+			req: func(t *testing.T) *http.Request {
+				return multipartMessage(t, `{"message": {"type": "image"}}`, png, "image/png")
+			},
+			wantStatus: http.StatusBadRequest,
+			wantErr:    "chatId is required",
+		},
+		{
+			name: "wrong runtime secret",
+			req: func(t *testing.T) *http.Request {
+				r := multipartMessage(t, `{"chatId": "c", "text": "hi"}`, nil, "")
+				r.Header.Set("X-Runtime-Secret", "nope")
+				return r
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blobs, err := blob.NewLocalStore(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			channels := &fakeChannels{blobs: blobs}
+			h := NewChannelsHandler(&grpcclient.Clients{Channels: channels}, "secret", blobs)
+
+			r := tt.req(t)
+			if r.Header.Get("X-Runtime-Secret") == "" {
+				r.Header.Set("X-Runtime-Secret", "secret")
+			}
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("channelId", "ch-1")
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+			h.SendChannelMessage(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantErr != "" && !strings.Contains(w.Body.String(), tt.wantErr) {
+				t.Errorf("body = %s, want %q", w.Body, tt.wantErr)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if len(channels.sent) != 0 {
+					t.Error("rejected message was sent")
+				}
+				return
+			}
+			if len(channels.sent) != 1 {
+				t.Fatalf("sent %d messages, want 1", len(channels.sent))
+			}
+			attachments := channels.sent[0].GetMessage().GetAttachments()
+			if len(attachments) != 1 {
+				t.Fatalf("attachments = %v, want 1", attachments)
+			}
+			a := attachments[0]
+			if a.GetMimeType() != tt.wantMime || a.GetName() != "chart.png" || !strings.HasPrefix(a.GetStorageUri(), "local://channel-attachments/ch-1/") {
+				t.Errorf("attachment = %+v", a)
+			}
+			if !channels.present[0] {
+				t.Error("attachment blob missing while the message was sent")
+			}
+			// The platform keeps its own copy, so the blob is dropped after.
+			if _, err := blobs.Open(context.Background(), a.GetStorageUri()); err == nil {
+				t.Error("attachment blob kept after send")
+			}
+		})
+	}
+}
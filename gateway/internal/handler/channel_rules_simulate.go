@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	channelspb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/channels"
+)
+
+type simulatedMessageBody struct {
+	Content  string `json:"content"`
+	Sender   string `json:"sender"`
+	ChatId   string `json:"chatId"`
+	ThreadId string `json:"threadId"`
+}
+
+type draftRuleBody struct {
+	Id            string `json:"id"`
+	Field         string `json:"field"`
+	Operator      string `json:"operator"`
+	Value         string `json:"value"`
+	TargetAgentId string `json:"targetAgentId"`
+	Priority      int32  `json:"priority"`
+	Enabled       *bool  `json:"enabled"`
+}
+
+// SimulateRoutingRules reports which agent a message would be routed to
+// and how every rule evaluated, without routing anything. The message is
+// a sample ("message"), a stored inbound message ("messageId") or the
+// last N inbound messages ("replayLast"). A "rules" array, even an empty
+// one, is evaluated in place of the saved rules so edits can be previewed.
+func (h *ChannelsHandler) SimulateRoutingRules(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Message    *simulatedMessageBody `json:"message"`
+		MessageId  string                `json:"messageId"`
+		ReplayLast int32                 `json:"replayLast"`
+		Rules      *[]draftRuleBody      `json:"rules"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	req := &channelspb.SimulateRoutingRulesRequest{
+		ChannelId:   chi.URLParam(r, "channelId"),
+		MessageId:   body.MessageId,
+		ReplayLast:  body.ReplayLast,
+		UserContext: userCtxFromRequest(r),
+	}
+	if body.Message != nil {
+		req.Message = &channelspb.SimulatedMessage{
+			Content:  body.Message.Content,
+			Sender:   body.Message.Sender,
+			ChatId:   body.Message.ChatId,
+			ThreadId: body.Message.ThreadId,
+		}
+	}
+	if body.Rules != nil {
+		req.Draft = true
+		for _, rule := range *body.Rules {
+			enabled := rule.Enabled == nil || *rule.Enabled
+			req.Rules = append(req.Rules, &channelspb.RoutingRule{
+				Id:            rule.Id,
+				Field:         rule.Field,
+				Operator:      rule.Operator,
+				Value:         rule.Value,
+				TargetAgentId: rule.TargetAgentId,
+				Priority:      rule.Priority,
+				Enabled:       enabled,
+			})
+		}
+	}
+
+	resp, err := h.clients.Channels.SimulateRoutingRules(r.Context(), req)
+	if err != nil {
+		writeGRPCError(w, r, err)
+		return
+	}
+	results := make([]map[string]any, 0, len(resp.GetResults()))
+	for _, item := range resp.GetResults() {
+		results = append(results, routingSimulationMap(item, req.Draft))
+	}
+	writeData(w, http.StatusOK, map[string]any{"draft": req.Draft, "results": results})
+}
+
+func routingSimulationMap(item *channelspb.RoutingSimulation, draft bool) map[string]any {
+	trace := make([]map[string]any, 0, len(item.GetTrace()))
+	for _, step := range item.GetTrace() {
+		trace = append(trace, map[string]any{
+			"ruleId":   step.GetRuleId(),
+			"priority": step.GetPriority(),
+			"outcome":  step.GetOutcome(),
+			"detail":   step.GetDetail(),
+		})
+	}
+	var matched any
+	if rule := item.GetMatchedRule(); rule != nil {
+		matched = routingRuleMap(rule)
+	}
+	msg := item.GetMessage()
+	out := map[string]any{
+		"messageId": item.GetMessageId(),
+		"message": map[string]any{
+			"content":  msg.GetContent(),
+			"sender":   msg.GetSender(),
+			"chatId":   msg.GetChatId(),
+			"threadId": msg.GetThreadId(),
+		},
+		"matchedRule":   matched,
+		"targetAgentId": item.GetTargetAgentId(),
+		"trace":         trace,
+	}
+	if draft {
+		out["savedTargetAgentId"] = item.GetSavedTargetAgentId()
+		out["changed"] = item.GetChanged()
+	}
+	return out
+}
+
+func routingRuleMap(rule *channelspb.RoutingRule) map[string]any {
+	return map[string]any{
+		"id":            rule.GetId(),
+		"channelId":     rule.GetChannelId(),
+		"field":         rule.GetField(),
+		"operator":      rule.GetOperator(),
+		"value":         rule.GetValue(),
+		"targetAgentId": rule.GetTargetAgentId(),
+		"priority":      rule.GetPriority(),
+		"enabled":       rule.GetEnabled(),
+	}
+}
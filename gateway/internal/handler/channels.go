@@ -1,13 +1,12 @@
 package handler
 
 import (
-	"crypto/subtle"
 	"encoding/json"
-	"io"
 	"net/http"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/blob"
 	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/grpcclient"
 	channelspb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/channels"
 )
@@ -15,6 +14,7 @@ import (
 type ChannelsHandler struct {
 	clients       *grpcclient.Clients
 	runtimeSecret string
+	blobs         blob.Store
 }
 
 func channelMap(item *channelspb.Channel) map[string]any {
@@ -40,8 +40,8 @@ func channelMap(item *channelspb.Channel) map[string]any {
 	}
 }
 
-func NewChannelsHandler(clients *grpcclient.Clients, runtimeSecret string) *ChannelsHandler {
-	return &ChannelsHandler{clients: clients, runtimeSecret: runtimeSecret}
+func NewChannelsHandler(clients *grpcclient.Clients, runtimeSecret string, blobs blob.Store) *ChannelsHandler {
+	return &ChannelsHandler{clients: clients, runtimeSecret: runtimeSecret, blobs: blobs}
 }
 
 
@@ -50,7 +50,7 @@ func (h *ChannelsHandler) ListChannels(w http.ResponseWriter, r *http.Request) {
 		WorkspaceId: chi.URLParam(r, "wsId"), UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	items := make([]map[string]any, 0, len(resp.GetChannels()))
@@ -90,7 +90,7 @@ func (h *ChannelsHandler) CreateChannel(w http.ResponseWriter, r *http.Request)
 	}
 	resp, err := h.clients.Channels.CreateChannel(r.Context(), req)
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusCreated, channelMap(resp))
@@ -101,7 +101,7 @@ func (h *ChannelsHandler) GetChannel(w http.ResponseWriter, r *http.Request) {
 		ChannelId: chi.URLParam(r, "channelId"), UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, channelMap(resp))
@@ -133,7 +133,7 @@ func (h *ChannelsHandler) UpdateChannel(w http.ResponseWriter, r *http.Request)
 	}
 	resp, err := h.clients.Channels.UpdateChannel(r.Context(), req)
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, channelMap(resp))
@@ -144,7 +144,7 @@ func (h *ChannelsHandler) DeleteChannel(w http.ResponseWriter, r *http.Request)
 		ChannelId: chi.URLParam(r, "channelId"), UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -155,7 +155,7 @@ func (h *ChannelsHandler) ListRoutingRules(w http.ResponseWriter, r *http.Reques
 		ChannelId: chi.URLParam(r, "channelId"), UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, resp.Rules)
@@ -171,7 +171,7 @@ func (h *ChannelsHandler) CreateRoutingRule(w http.ResponseWriter, r *http.Reque
 	body.UserContext = userCtxFromRequest(r)
 	resp, err := h.clients.Channels.CreateRoutingRule(r.Context(), &body)
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusCreated, resp)
@@ -187,7 +187,7 @@ func (h *ChannelsHandler) UpdateRoutingRule(w http.ResponseWriter, r *http.Reque
 	body.UserContext = userCtxFromRequest(r)
 	resp, err := h.clients.Channels.UpdateRoutingRule(r.Context(), &body)
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, resp)
@@ -198,66 +198,12 @@ func (h *ChannelsHandler) DeleteRoutingRule(w http.ResponseWriter, r *http.Reque
 		RuleId: chi.URLParam(r, "ruleId"), ChannelId: chi.URLParam(r, "channelId"), UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// HandleWebhook — public endpoint, no JWT auth required
-func (h *ChannelsHandler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
-	// Limit webhook body to 1MB
-	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
-
-	channelID := chi.URLParam(r, "channelId")
-	if channelID == "" || len(channelID) > 64 {
-		writeError(w, http.StatusBadRequest, "invalid channel ID")
-		return
-	}
-
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		writeError(w, http.StatusBadRequest, "failed to read body")
-		return
-	}
-	headers := make(map[string]string)
-	for k, v := range r.Header {
-		if len(v) > 0 {
-			headers[k] = v[0]
-		}
-	}
-	resp, err := h.clients.Channels.HandleWebhook(r.Context(), &channelspb.WebhookRequest{
-		ChannelId: channelID,
-		Body:      string(body),
-		Headers:   headers,
-	})
-	if err != nil {
-		writeGRPCError(w, err)
-		return
-	}
-	if !resp.Accepted {
-		writeError(w, http.StatusUnauthorized, resp.Message)
-		return
-	}
-	// Return challenge string for platform URL verification handshakes
-	if resp.Challenge != "" {
-		writeJSON(w, http.StatusOK, map[string]string{"challenge": resp.Challenge})
-		return
-	}
-	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
-}
-
-func (h *ChannelsHandler) ListChannelMessages(w http.ResponseWriter, r *http.Request) {
-	resp, err := h.clients.Channels.ListChannelMessages(r.Context(), &channelspb.ListChannelMessagesRequest{
-		ChannelId: chi.URLParam(r, "channelId"), UserContext: userCtxFromRequest(r),
-	})
-	if err != nil {
-		writeGRPCError(w, err)
-		return
-	}
-	writeData(w, http.StatusOK, resp.Messages)
-}
-
 func (h *ChannelsHandler) TestConnection(w http.ResponseWriter, r *http.Request) {
 	var body struct {
 		Type   string            `json:"type"`
@@ -272,7 +218,7 @@ func (h *ChannelsHandler) TestConnection(w http.ResponseWriter, r *http.Request)
 		Type: body.Type, ConfigJson: string(configBytes), UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, map[string]any{
@@ -281,32 +227,3 @@ func (h *ChannelsHandler) TestConnection(w http.ResponseWriter, r *http.Request)
 		"error":   resp.Error,
 	})
 }
-
-func (h *ChannelsHandler) SendChannelMessage(w http.ResponseWriter, r *http.Request) {
-	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Runtime-Secret")), []byte(h.runtimeSecret)) != 1 {
-		writeError(w, http.StatusUnauthorized, "invalid runtime secret")
-		return
-	}
-	var req struct {
-		ChatId   string `json:"chatId"`
-		Text     string `json:"text"`
-		ThreadId string `json:"threadId"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
-		return
-	}
-	body := channelspb.SendChannelMessageRequest{
-		ChannelId:   chi.URLParam(r, "channelId"),
-		ChatId:      req.ChatId,
-		Text:        req.Text,
-		ThreadId:    req.ThreadId,
-		UserContext: userCtxFromRequest(r),
-	}
-	_, err := h.clients.Channels.SendChannelMessage(r.Context(), &body)
-	if err != nil {
-		writeGRPCError(w, err)
-		return
-	}
-	w.WriteHeader(http.StatusNoContent)
-}
@@ -207,7 +207,7 @@ func (h *ChatHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
 		WorkspaceId: chi.URLParam(r, "wsId"), UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 
@@ -230,7 +230,7 @@ func (h *ChatHandler) CreateSession(w http.ResponseWriter, r *http.Request) {
 		WorkspaceId: chi.URLParam(r, "wsId"), Title: body.Title, UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusCreated, sessionMap(resp))
@@ -265,7 +265,7 @@ func (h *ChatHandler) UpdateSession(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := h.clients.Chat.UpdateSession(r.Context(), req)
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, sessionMap(resp))
@@ -277,7 +277,7 @@ func (h *ChatHandler) DeleteSession(w http.ResponseWriter, r *http.Request) {
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, nil)
@@ -301,7 +301,7 @@ func (h *ChatHandler) ListMessages(w http.ResponseWriter, r *http.Request) {
 		BeforeMessageId: beforeMessageId,
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 
@@ -330,7 +330,7 @@ func (h *ChatHandler) SaveUserMessage(w http.ResponseWriter, r *http.Request) {
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusCreated, messageMap(resp))
@@ -352,7 +352,7 @@ func (h *ChatHandler) UpdateUserMessage(w http.ResponseWriter, r *http.Request)
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 
@@ -376,7 +376,7 @@ func (h *ChatHandler) GetRuntimeMetrics(w http.ResponseWriter, r *http.Request)
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 
@@ -453,7 +453,7 @@ func (h *ChatHandler) ListUsageRecords(w http.ResponseWriter, r *http.Request) {
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 
@@ -716,7 +716,7 @@ func (h *ChatHandler) ReportPluginUsageEvents(w http.ResponseWriter, r *http.Req
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 
@@ -732,7 +732,7 @@ func (h *ChatHandler) ListAgents(w http.ResponseWriter, r *http.Request) {
 		WorkspaceId: chi.URLParam(r, "wsId"), UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 
@@ -782,7 +782,7 @@ func (h *ChatHandler) CreateAgent(w http.ResponseWriter, r *http.Request) {
 		UserContext:    userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusCreated, agentMap(resp))
@@ -793,7 +793,7 @@ func (h *ChatHandler) GetAgent(w http.ResponseWriter, r *http.Request) {
 		Id: chi.URLParam(r, "agentId"), UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, agentMap(resp))
@@ -836,7 +836,7 @@ func (h *ChatHandler) UpdateAgent(w http.ResponseWriter, r *http.Request) {
 		UserContext:      userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, agentMap(resp))
@@ -847,7 +847,7 @@ func (h *ChatHandler) DeleteAgent(w http.ResponseWriter, r *http.Request) {
 		Id: chi.URLParam(r, "agentId"), UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -861,7 +861,7 @@ func (h *ChatHandler) ListWorkflows(w http.ResponseWriter, r *http.Request) {
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	items := make([]map[string]any, len(resp.Workflows))
@@ -905,7 +905,7 @@ func (h *ChatHandler) CreateWorkflow(w http.ResponseWriter, r *http.Request) {
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusCreated, workflowMap(resp))
@@ -917,7 +917,7 @@ func (h *ChatHandler) GetWorkflow(w http.ResponseWriter, r *http.Request) {
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, workflowMap(resp))
@@ -968,7 +968,7 @@ func (h *ChatHandler) UpdateWorkflow(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := h.clients.Chat.UpdateWorkflow(r.Context(), req)
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, workflowMap(resp))
@@ -1011,7 +1011,7 @@ func (h *ChatHandler) ValidateWorkflow(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := h.clients.Chat.ValidateWorkflow(r.Context(), req)
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	issues := make([]map[string]any, len(resp.Issues))
@@ -1036,7 +1036,7 @@ func (h *ChatHandler) ListWorkflowNodeTypes(w http.ResponseWriter, r *http.Reque
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 
@@ -1068,7 +1068,7 @@ func (h *ChatHandler) GetBlueprint(w http.ResponseWriter, r *http.Request) {
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, blueprintMap(resp))
@@ -1135,7 +1135,7 @@ func (h *ChatHandler) SaveBlueprint(w http.ResponseWriter, r *http.Request) {
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, blueprintMap(resp))
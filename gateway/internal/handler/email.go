@@ -0,0 +1,359 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/blob"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/grpcclient"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/logging"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/mail"
+	channelspb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/channels"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/webhookq"
+)
+
+// maxEmailText bounds the body text passed on for one email; quoted
+// history makes long threads grow quickly.
+const maxEmailText = 64 << 10
+
+// EmailOptions configures an EmailHandler.
+type EmailOptions struct {
+	Dialer          mail.Dialer
+	Threads         *mail.Threads
+	PollInterval    time.Duration
+	MaxPerPoll      int
+	MaxMessageBytes int64
+}
+
+// EmailHandler connects email channels to the channel pipeline. It polls
+// each channel's IMAP mailbox and queues new mail as webhook events, so
+// mail reaches the service through the same HandleWebhook delivery as
+// platform webhooks. It also sends the email plugin's replies over SMTP,
+// threaded under the message they answer.
+type EmailHandler struct {
+	clients       *grpcclient.Clients
+	queue         *webhookq.Queue
+	blobs         blob.Store
+	runtimeSecret string
+	opts          EmailOptions
+}
+
+func NewEmailHandler(clients *grpcclient.Clients, queue *webhookq.Queue, blobs blob.Store, runtimeSecret string, opts EmailOptions) *EmailHandler {
+	return &EmailHandler{clients: clients, queue: queue, blobs: blobs, runtimeSecret: runtimeSecret, opts: opts}
+}
+
+// emailEvent is the webhook body queued for one received email; the
+// service's email plugin parses it.
+type emailEvent struct {
+	MessageID   string            `json:"messageId"`
+	ThreadID    string            `json:"threadId"`
+	InReplyTo   string            `json:"inReplyTo,omitempty"`
+	From        string            `json:"from"`
+	FromName    string            `json:"fromName,omitempty"`
+	To          []string          `json:"to"`
+	Subject     string            `json:"subject"`
+	Date        time.Time         `json:"date"`
+	Text        string            `json:"text"`
+	Attachments []emailAttachment `json:"attachments"`
+}
+
+type emailAttachment struct {
+	Name       string `json:"name"`
+	MimeType   string `json:"mimeType"`
+	Size       int    `json:"size"`
+	StorageURI string `json:"storageUri"`
+}
+
+// Run polls every active email channel each interval until ctx is done.
+func (h *EmailHandler) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		h.pollAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *EmailHandler) pollAll(ctx context.Context) {
+	log := logging.FromContext(ctx)
+	listCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	resp, err := h.clients.Channels.ListEmailChannels(listCtx, &channelspb.EmailChannelsRequest{})
+	cancel()
+	if err != nil {
+		log.Warn("list email channels", slog.Any("err", err))
+		return
+	}
+	for _, ch := range resp.GetChannels() {
+		account, err := emailAccount(ch.GetConfigJson())
+		if err == nil {
+			err = h.poll(ctx, ch.GetChannelId(), account)
+		}
+		if err != nil {
+			log.Warn("poll email channel", slog.String("channel_id", ch.GetChannelId()), slog.Any("err", err))
+		}
+	}
+}
+
+// poll queues the mailbox's unread mail, marking each message read once
+// it is queued. A message that cannot be queued stays unread and is tried
+// again next poll.
+func (h *EmailHandler) poll(ctx context.Context, channelID string, account mail.Account) error {
+	session, err := h.opts.Dialer.OpenIMAP(ctx, account, h.opts.MaxMessageBytes)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	uids, err := session.Unseen(h.opts.MaxPerPoll)
+	if err != nil {
+		return err
+	}
+	log := logging.FromContext(ctx).With(slog.String("channel_id", channelID))
+	for _, uid := range uids {
+		size, err := session.Size(uid)
+		if err != nil {
+			return err
+		}
+		if size > h.opts.MaxMessageBytes {
+			log.Warn("skipping oversized email", slog.Int64("size", size))
+		} else {
+			raw, err := session.Fetch(uid)
+			if err != nil {
+				return err
+			}
+			err = h.ingest(ctx, channelID, account, raw)
+			if errors.Is(err, mail.ErrMalformed) {
+				log.Warn("skipping malformed email", slog.Any("err", err))
+			} else if err != nil {
+				return err
+			}
+		}
+		if err := session.MarkSeen(uid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ingest queues one raw email for delivery to the service.
+func (h *EmailHandler) ingest(ctx context.Context, channelID string, account mail.Account, raw []byte) error {
+	m, err := mail.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if m.Automated || strings.EqualFold(m.From, account.Address) {
+		return nil
+	}
+	if m.MessageID == "" {
+		sum := sha256.Sum256(raw)
+		m.MessageID = hex.EncodeToString(sum[:16]) + "@message-id.invalid"
+	}
+	event := emailEvent{
+		MessageID: m.MessageID, ThreadID: m.ThreadID(), InReplyTo: m.InReplyTo,
+		From: m.From, FromName: m.FromName, To: m.To, Subject: m.Subject, Date: m.Date,
+		Text: truncateUTF8(m.Text, maxEmailText), Attachments: []emailAttachment{},
+	}
+	// Keys derive from the message ID, so a message queued twice
+	// overwrites its attachments instead of storing them again.
+	sum := sha256.Sum256([]byte(m.MessageID))
+	for i, a := range m.Attachments {
+		key := fmt.Sprintf("channel-attachments/%s/email-%s-%d-%s", channelID, hex.EncodeToString(sum[:8]), i, sanitizeUploadFileName(a.Name))
+		uri, err := h.blobs.Put(ctx, key, bytes.NewReader(a.Data), int64(len(a.Data)))
+		if err != nil {
+			return fmt.Errorf("store attachment: %w", err)
+		}
+		event.Attachments = append(event.Attachments, emailAttachment{Name: a.Name, MimeType: a.MIMEType, Size: len(a.Data), StorageURI: uri})
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, _, err = h.queue.Enqueue(webhookq.Event{
+		ChannelID:  channelID,
+		EventID:    m.MessageID,
+		Body:       string(body),
+		Headers:    map[string][]string{"content-type": {"application/json"}},
+		Verified:   true,
+		ReceivedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	h.opts.Threads.Record(channelID, m.ThreadID(), m.Subject, m.MessageID, m.References)
+	return nil
+}
+
+// ReleaseEmailAttachments is the webhook queue's OnDone hook. Once a queued
+// email has been delivered or dropped, the attachments ingest stored for it
+// are deleted, as a sent message's attachment is once the service has it.
+func ReleaseEmailAttachments(blobs blob.Store) func(webhookq.Event) {
+	return func(ev webhookq.Event) {
+		// Only keys ingest wrote for this channel are touched.
+		prefix := "channel-attachments/" + ev.ChannelID + "/email-"
+		if !strings.Contains(ev.Body, prefix) {
+			return
+		}
+		var event emailEvent
+		if err := json.Unmarshal([]byte(ev.Body), &event); err != nil {
+			return
+		}
+		for _, a := range event.Attachments {
+			if !strings.Contains(a.StorageURI, prefix) {
+				continue
+			}
+			if err := blobs.Delete(context.Background(), a.StorageURI); err != nil {
+				slog.Warn("delete email attachment", slog.String("channel_id", ev.ChannelID),
+					slog.String("storage_uri", a.StorageURI), slog.Any("err", err))
+			}
+		}
+	}
+}
+
+// Send — internal endpoint (X-Runtime-Secret auth). Sends a message from
+// an email channel's account; with a threadId it is a reply in that
+// channel's thread.
+func (h *EmailHandler) Send(w http.ResponseWriter, r *http.Request) {
+	if !authorizeRuntime(w, r, h.runtimeSecret) {
+		return
+	}
+	var body struct {
+		ChannelID   string            `json:"channelId"`
+		Config      map[string]string `json:"config"`
+		To          string            `json:"to"`
+		ThreadID    string            `json:"threadId"`
+		Text        string            `json:"text"`
+		Attachments []struct {
+			Name     string `json:"name"`
+			MimeType string `json:"mimeType"`
+			Data     []byte `json:"data"`
+		} `json:"attachments"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if body.ChannelID == "" {
+		writeError(w, http.StatusBadRequest, "channelId is required")
+		return
+	}
+	account, err := mail.AccountFromConfig(body.Config)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid email config: "+err.Error())
+		return
+	}
+	out := mail.Outgoing{To: []string{body.To}, Text: body.Text}
+	for _, a := range body.Attachments {
+		out.Attachments = append(out.Attachments, mail.Attachment{Name: a.Name, MIMEType: a.MimeType, Data: a.Data})
+	}
+	if thread, ok := h.opts.Threads.Get(body.ChannelID, body.ThreadID); ok {
+		thread.Reply(&out)
+	} else {
+		out.Subject = account.Subject
+		if out.Subject == "" {
+			out.Subject = firstLine(body.Text, 78)
+		}
+		if body.ThreadID != "" {
+			// The thread was forgotten; still point at its first message.
+			out.Subject = mail.ReplySubject(out.Subject)
+			out.InReplyTo, out.References = body.ThreadID, []string{body.ThreadID}
+		}
+	}
+
+	id, err := h.opts.Dialer.Send(r.Context(), account, out)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("send email", slog.Any("err", err))
+		writeError(w, http.StatusBadGateway, "send email: "+err.Error())
+		return
+	}
+	threadID := body.ThreadID
+	if threadID == "" {
+		threadID = id
+	}
+	h.opts.Threads.Record(body.ChannelID, threadID, out.Subject, id, out.References)
+	writeData(w, http.StatusOK, map[string]string{"messageId": id, "threadId": threadID})
+}
+
+// Test — internal endpoint (X-Runtime-Secret auth). Logs in to the
+// config's IMAP and SMTP servers without fetching or sending anything.
+func (h *EmailHandler) Test(w http.ResponseWriter, r *http.Request) {
+	if !authorizeRuntime(w, r, h.runtimeSecret) {
+		return
+	}
+	var body struct {
+		Config map[string]string `json:"config"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	result := func(err error) {
+		if err != nil {
+			writeData(w, http.StatusOK, map[string]any{"success": false, "error": err.Error()})
+			return
+		}
+		writeData(w, http.StatusOK, map[string]any{"success": true})
+	}
+	account, err := mail.AccountFromConfig(body.Config)
+	if err != nil {
+		result(err)
+		return
+	}
+	session, err := h.opts.Dialer.OpenIMAP(r.Context(), account, h.opts.MaxMessageBytes)
+	if err != nil {
+		result(fmt.Errorf("imap: %w", err))
+		return
+	}
+	session.Close()
+	if err := h.opts.Dialer.CheckSMTP(r.Context(), account); err != nil {
+		result(fmt.Errorf("smtp: %w", err))
+		return
+	}
+	result(nil)
+}
+
+func emailAccount(configJSON string) (mail.Account, error) {
+	cfg := map[string]string{}
+	if configJSON != "" {
+		if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
+			return mail.Account{}, fmt.Errorf("invalid channel config: %w", err)
+		}
+	}
+	return mail.AccountFromConfig(cfg)
+}
+
+// firstLine returns the first non-empty line of s, cut to max runes.
+func firstLine(s string, max int) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			if r := []rune(line); len(r) > max {
+				return string(r[:max])
+			}
+			return line
+		}
+	}
+	return ""
+}
+
+func truncateUTF8(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	s = s[:max]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s + "\n[truncated]"
+}
@@ -2,8 +2,9 @@ package handler
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/logging"
 	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/middleware"
 	commonpb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/common"
 )
@@ -58,14 +60,18 @@ func writeError(w http.ResponseWriter, code int, msg string) {
 	writeJSON(w, code, map[string]string{"error": msg, "code": "ERROR", "message": msg})
 }
 
-func writeGRPCError(w http.ResponseWriter, err error) {
+func writeGRPCError(w http.ResponseWriter, r *http.Request, err error) {
+	logger := logging.FromContext(r.Context())
 	st, ok := status.FromError(err)
 	if !ok {
-		log.Printf("Internal error: %v", err)
+		logger.Error("internal error", slog.Any("err", err))
 		writeError(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
 	httpCode := grpcCodeToHTTP(st.Code())
+	if httpCode >= 500 {
+		logger.Warn("grpc call failed", slog.String("grpc_code", st.Code().String()), slog.String("err", st.Message()))
+	}
 	writeJSON(w, httpCode, map[string]string{
 		"error":   st.Message(),
 		"code":    st.Code().String(),
@@ -111,3 +117,13 @@ func userCtxFromRequest(r *http.Request) *commonpb.UserContext {
 	}
 	return &commonpb.UserContext{UserId: u.UserID, Email: u.Email, Name: u.Name}
 }
+
+// authorizeRuntime checks the X-Runtime-Secret header of an internal
+// (service-to-service) request, writing a 401 when it does not match.
+func authorizeRuntime(w http.ResponseWriter, r *http.Request, secret string) bool {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Runtime-Secret")), []byte(secret)) != 1 {
+		writeError(w, http.StatusUnauthorized, "invalid runtime secret")
+		return false
+	}
+	return true
+}
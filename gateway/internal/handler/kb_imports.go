@@ -0,0 +1,374 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc/status"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/filecheck"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/grpcclient"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/kbimport"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/logging"
+	toolspb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/tools"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/upload"
+)
+
+// KnowledgeImportsOptions configures bulk imports.
+type KnowledgeImportsOptions struct {
+	// Dir holds archives and extracted files while a job runs.
+	Dir         string
+	Concurrency int
+	Limits      kbimport.Limits
+	MaxURLs     int
+	Fetcher     *kbimport.Fetcher
+}
+
+// KnowledgeImportsHandler bulk-imports documents into a knowledge base:
+//
+//	POST /knowledge-bases/{kbId}/imports          multipart "archive" (zip or tar.gz),
+//	                                              or JSON {"uploadId"} / {"urls": [...]}
+//	GET  /knowledge-bases/{kbId}/imports/{jobId}  job with per-file status
+//
+// The POST answers 202 with a job at once; extraction, validation and
+// document creation run in the background. Archives larger than the
+// request body limit are sent as a resumable upload first and referenced
+// by uploadId. Every file goes through the same KnowledgeIntake as a
+// single upload.
+type KnowledgeImportsHandler struct {
+	clients *grpcclient.Clients
+	intake  *KnowledgeIntake
+	uploads *upload.Store
+	jobs    *kbimport.Jobs
+	opts    KnowledgeImportsOptions
+}
+
+func NewKnowledgeImportsHandler(clients *grpcclient.Clients, intake *KnowledgeIntake, uploads *upload.Store, jobs *kbimport.Jobs, opts KnowledgeImportsOptions) (*KnowledgeImportsHandler, error) {
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &KnowledgeImportsHandler{clients: clients, intake: intake, uploads: uploads, jobs: jobs, opts: opts}, nil
+}
+
+type createImportRequest struct {
+	UploadID string   `json:"uploadId"`
+	URLs     []string `json:"urls"`
+}
+
+// importSource is what a job reads: an archive, an upload session holding
+// one, or a list of URLs.
+type importSource struct {
+	archive  *os.File
+	uploadID string // assembled by the job and dropped once extracted
+	urls     []string
+}
+
+func (h *KnowledgeImportsHandler) CreateImport(w http.ResponseWriter, r *http.Request) {
+	kbID := chi.URLParam(r, "kbId")
+	// Fail fast if the caller cannot use the knowledge base.
+	if _, err := h.intake.Policy(r, kbID); err != nil {
+		writeIntakeError(w, r, err)
+		return
+	}
+	workDir, err := os.MkdirTemp(h.opts.Dir, "job-*")
+	if err != nil {
+		logging.FromContext(r.Context()).Error("create import dir", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to start import")
+		return
+	}
+	src, ok := h.readSource(w, r, kbID, workDir)
+	if !ok {
+		os.RemoveAll(workDir)
+		return
+	}
+
+	job := h.jobs.Create(kbID, uploadUserID(r))
+	if len(src.urls) > 0 {
+		h.jobs.Update(job.ID, func(j *kbimport.Job) {
+			for _, u := range src.urls {
+				j.Items = append(j.Items, kbimport.Item{Name: u, Source: "url", Status: kbimport.ItemPending})
+			}
+		})
+		job, _ = h.jobs.Get(job.ID)
+	}
+	// The job outlives the request; keep its user and logging context but
+	// not its cancellation or timeout.
+	go h.run(r.WithContext(context.WithoutCancel(r.Context())), job.ID, kbID, src, workDir)
+
+	w.Header().Set("Location", r.URL.Path+"/"+job.ID)
+	writeData(w, http.StatusAccepted, importJobView(job))
+}
+
+// readSource parses the request into an importSource, writing the error
+// response itself when it cannot.
+func (h *KnowledgeImportsHandler) readSource(w http.ResponseWriter, r *http.Request, kbID, workDir string) (importSource, bool) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid multipart form data")
+			return importSource{}, false
+		}
+		file, _, err := r.FormFile("archive")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "archive is required")
+			return importSource{}, false
+		}
+		defer file.Close()
+		archive, err := os.CreateTemp(workDir, "archive-*")
+		if err == nil {
+			_, err = io.Copy(archive, file)
+		}
+		if err != nil {
+			if archive != nil {
+				archive.Close()
+			}
+			logging.FromContext(r.Context()).Error("save import archive", slog.Any("err", err))
+			writeError(w, http.StatusInternalServerError, "failed to save archive")
+			return importSource{}, false
+		}
+		return importSource{archive: archive}, true
+	}
+
+	var req createImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return importSource{}, false
+	}
+	var urls []string
+	for _, u := range req.URLs {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	switch {
+	case req.UploadID != "" && len(urls) > 0:
+		writeError(w, http.StatusBadRequest, "send either uploadId or urls, not both")
+		return importSource{}, false
+	case req.UploadID != "":
+		sess, err := h.uploads.Get(req.UploadID)
+		if err == nil && (sess.UserID != uploadUserID(r) || sess.KnowledgeBaseID != kbID) {
+			err = upload.ErrNotFound
+		}
+		if err != nil {
+			writeUploadError(w, r, sess, err)
+			return importSource{}, false
+		}
+		// Claim the session so it cannot also be completed as a document;
+		// checksumming and reading it are left to the job.
+		sess, started, err := h.uploads.Begin(sess.ID)
+		if err == nil && !started {
+			err = upload.ErrFinalized
+		}
+		if err != nil {
+			writeUploadError(w, r, sess, err)
+			return importSource{}, false
+		}
+		return importSource{uploadID: sess.ID}, true
+	case len(urls) == 0:
+		writeError(w, http.StatusBadRequest, "an archive, uploadId or urls is required")
+		return importSource{}, false
+	case len(urls) > h.opts.MaxURLs:
+		writeError(w, http.StatusBadRequest, "too many urls")
+		return importSource{}, false
+	}
+	return importSource{urls: urls}, true
+}
+
+func (h *KnowledgeImportsHandler) GetImport(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.jobs.Get(chi.URLParam(r, "jobId"))
+	if !ok || job.UserID != uploadUserID(r) || job.KnowledgeBaseID != chi.URLParam(r, "kbId") {
+		writeError(w, http.StatusNotFound, "import job not found")
+		return
+	}
+	writeData(w, http.StatusOK, importJobView(job))
+}
+
+func (h *KnowledgeImportsHandler) run(r *http.Request, jobID, kbID string, src importSource, workDir string) {
+	defer os.RemoveAll(workDir)
+	logger := logging.FromContext(r.Context()).With(slog.String("import_job", jobID))
+	h.jobs.Update(jobID, func(j *kbimport.Job) { j.Status = kbimport.JobRunning })
+
+	if src.uploadID != "" {
+		_, archive, err := h.uploads.Assemble(src.uploadID)
+		if err != nil {
+			// Leave the session retryable, as a failed completion would. A
+			// checksum mismatch has already reset it for a new upload.
+			code, message := importErrorCode(err)
+			if code == "ERROR" {
+				logger.Error("assemble import archive", slog.Any("err", err))
+			}
+			if !errors.Is(err, upload.ErrChecksumMismatch) {
+				h.uploads.Fail(src.uploadID, code, message, false)
+			}
+			h.jobs.Update(jobID, func(j *kbimport.Job) {
+				j.Status = kbimport.JobFailed
+				j.Code = code
+				j.Error = message
+			})
+			return
+		}
+		src.archive = archive
+	}
+	if src.archive != nil {
+		entries, skipped, err := h.extract(src, workDir)
+		if err != nil {
+			logger.Warn("import archive rejected", slog.Any("err", err))
+			h.jobs.Update(jobID, func(j *kbimport.Job) {
+				j.Status = kbimport.JobFailed
+				j.Code = archiveErrorCode(err)
+				j.Error = err.Error()
+			})
+			return
+		}
+		// Skipped entries go first so the indexes of the entries that follow
+		// are known before any worker starts.
+		first := len(skipped)
+		h.jobs.Update(jobID, func(j *kbimport.Job) {
+			for _, s := range skipped {
+				j.Items = append(j.Items, kbimport.Item{Name: s.Name, Source: "archive", Status: kbimport.ItemSkipped, Code: s.Code, Error: s.Reason})
+			}
+			for _, e := range entries {
+				j.Items = append(j.Items, kbimport.Item{Name: e.Name, Source: "archive", Status: kbimport.ItemPending, Size: e.Size})
+			}
+		})
+		kbimport.ForEach(r.Context(), h.opts.Concurrency, len(entries), func(i int) {
+			h.importEntry(r, jobID, first+i, kbID, entries[i])
+		})
+	} else {
+		kbimport.ForEach(r.Context(), h.opts.Concurrency, len(src.urls), func(i int) {
+			h.jobs.UpdateItem(jobID, i, func(item *kbimport.Item) { item.Status = kbimport.ItemProcessing })
+			entry, err := h.opts.Fetcher.Fetch(r.Context(), src.urls[i], workDir)
+			if err != nil {
+				h.failItem(r, jobID, i, err)
+				return
+			}
+			h.importEntry(r, jobID, i, kbID, entry)
+		})
+	}
+
+	job, _ := h.jobs.Get(jobID)
+	counts := job.Counts()
+	logger.Info("import finished", slog.Int("created", counts[kbimport.ItemCreated]),
+		slog.Int("failed", counts[kbimport.ItemFailed]), slog.Int("skipped", counts[kbimport.ItemSkipped]))
+	h.jobs.Update(jobID, func(j *kbimport.Job) { j.Status = kbimport.JobCompleted })
+}
+
+// extract unpacks the archive, then drops it and any upload session it
+// came from.
+func (h *KnowledgeImportsHandler) extract(src importSource, workDir string) ([]kbimport.Entry, []kbimport.Skipped, error) {
+	defer func() {
+		src.archive.Close()
+		if src.uploadID != "" {
+			h.uploads.Remove(src.uploadID)
+		}
+	}()
+	info, err := src.archive.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	return kbimport.Extract(src.archive, info.Size(), workDir, h.opts.Limits)
+}
+
+// importEntry validates, stores and registers one file.
+func (h *KnowledgeImportsHandler) importEntry(r *http.Request, jobID string, i int, kbID string, entry kbimport.Entry) {
+	defer os.Remove(entry.Path)
+	h.jobs.UpdateItem(jobID, i, func(item *kbimport.Item) {
+		item.Status = kbimport.ItemProcessing
+		item.Size = entry.Size
+	})
+	f, err := os.Open(entry.Path)
+	if err != nil {
+		h.failItem(r, jobID, i, err)
+		return
+	}
+	name := path.Base(entry.Name)
+	stored, err := h.intake.Accept(r, kbID, name, f, entry.Size)
+	f.Close()
+	if err != nil {
+		h.failItem(r, jobID, i, err)
+		return
+	}
+	defer stored.Release()
+	doc, err := h.clients.Tools.CreateKnowledgeBaseDocument(r.Context(), &toolspb.CreateKnowledgeBaseDocumentRequest{
+		KnowledgeBaseId: kbID,
+		Name:            name,
+		Type:            stored.FileType,
+		Size:            entry.Size,
+		StorageUri:      stored.URI,
+		UserContext:     userCtxFromRequest(r),
+	})
+	if err != nil {
+		h.intake.Discard(r, stored.URI)
+		h.failItem(r, jobID, i, err)
+		return
+	}
+	h.jobs.UpdateItem(jobID, i, func(item *kbimport.Item) {
+		item.Status = kbimport.ItemCreated
+		item.DocumentID = doc.GetId()
+	})
+}
+
+func (h *KnowledgeImportsHandler) failItem(r *http.Request, jobID string, i int, err error) {
+	code, message := importErrorCode(err)
+	if code == "ERROR" {
+		logging.FromContext(r.Context()).Error("import item failed", slog.String("import_job", jobID), slog.Any("err", err))
+	}
+	h.jobs.UpdateItem(jobID, i, func(item *kbimport.Item) {
+		item.Status = kbimport.ItemFailed
+		item.Code = code
+		item.Error = message
+	})
+}
+
+// importErrorCode turns an item failure into a code and a message safe to
+// show the client.
+func importErrorCode(err error) (string, string) {
+	var rejection *filecheck.Rejection
+	var coded *kbimport.Error
+	switch {
+	case errors.As(err, &rejection):
+		return rejection.Code, rejection.Message
+	case errors.As(err, &coded):
+		return coded.Code, coded.Message
+	case errors.Is(err, upload.ErrChecksumMismatch):
+		return "CHECKSUM_MISMATCH", err.Error()
+	}
+	if st, ok := status.FromError(err); ok {
+		return st.Code().String(), st.Message()
+	}
+	return "ERROR", "failed to save upload file"
+}
+
+func archiveErrorCode(err error) string {
+	switch {
+	case errors.Is(err, kbimport.ErrTooManyEntries), errors.Is(err, kbimport.ErrArchiveTooLarge),
+		errors.Is(err, kbimport.ErrCompressionRatio):
+		return "ARCHIVE_LIMIT_EXCEEDED"
+	default:
+		return "INVALID_ARCHIVE"
+	}
+}
+
+func importJobView(job kbimport.Job) map[string]any {
+	return map[string]any{
+		"id":              job.ID,
+		"knowledgeBaseId": job.KnowledgeBaseID,
+		"status":          job.Status,
+		"code":            job.Code,
+		"error":           job.Error,
+		"items":           job.Items,
+		"counts":          job.Counts(),
+		"createdAt":       job.CreatedAt,
+		"updatedAt":       job.UpdatedAt,
+		"finishedAt":      job.FinishedAt,
+	}
+}
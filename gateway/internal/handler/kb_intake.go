@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/status"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/blob"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/filecheck"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/grpcclient"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/logging"
+	toolspb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/tools"
+)
+
+// KnowledgeIntakeOptions are the gateway-wide upload limits.
+type KnowledgeIntakeOptions struct {
+	// AllowedTypes is empty to allow every supported type.
+	AllowedTypes []string
+	MaxFileBytes int64
+	// Scanner is nil to skip malware scanning.
+	Scanner filecheck.Scanner
+	// Quarantine keeps infected files under quarantine/ instead of
+	// dropping them.
+	Quarantine bool
+	// FailOpen accepts files when the scanner errors.
+	FailOpen bool
+}
+
+// KnowledgeIntake is the single path from an uploaded file to a stored
+// blob, shared by the multipart and resumable upload endpoints: the
+// content is sniffed, checked against the gateway and workspace policy,
+// scanned, and only then stored.
+type KnowledgeIntake struct {
+	clients *grpcclient.Clients
+	blobs   blob.Store
+	opts    KnowledgeIntakeOptions
+
+	// reserved holds, per workspace, the bytes of accepted files whose
+	// documents do not exist yet and so are missing from UsedBytes.
+	mu       sync.Mutex
+	reserved map[string]int64
+}
+
+// StoredUpload is a file Accept stored. Its size counts against the
+// workspace quota until Release, which callers defer until its document is
+// created or the blob discarded, so concurrent uploads cannot overrun it.
+type StoredUpload struct {
+	URI      string
+	FileType string
+	release  func()
+}
+
+// Release returns the upload's reservation.
+func (s StoredUpload) Release() {
+	if s.release != nil {
+		s.release()
+	}
+}
+
+func NewKnowledgeIntake(clients *grpcclient.Clients, blobs blob.Store, opts KnowledgeIntakeOptions) (*KnowledgeIntake, error) {
+	opts.AllowedTypes = filecheck.NormalizeTypes(opts.AllowedTypes)
+	supported := filecheck.SupportedTypes()
+	for _, t := range opts.AllowedTypes {
+		if !slices.Contains(supported, t) {
+			return nil, fmt.Errorf("unsupported upload type %q", t)
+		}
+	}
+	return &KnowledgeIntake{clients: clients, blobs: blobs, opts: opts, reserved: map[string]int64{}}, nil
+}
+
+// Policy returns the effective limits for a knowledge base. The call also
+// fails if the caller may not use it.
+func (k *KnowledgeIntake) Policy(r *http.Request, kbID string) (filecheck.Policy, error) {
+	policy, wsID, err := k.policy(r, kbID)
+	if err != nil {
+		return filecheck.Policy{}, err
+	}
+	k.mu.Lock()
+	policy.UsedBytes += k.reserved[wsID]
+	k.mu.Unlock()
+	return policy, nil
+}
+
+// policy is Policy, without reservations, plus the knowledge base's
+// workspace.
+func (k *KnowledgeIntake) policy(r *http.Request, kbID string) (filecheck.Policy, string, error) {
+	resp, err := k.clients.Tools.GetKnowledgeBaseUploadPolicy(r.Context(), &toolspb.GetKnowledgeBaseRequest{
+		Id:          kbID,
+		UserContext: userCtxFromRequest(r),
+	})
+	if err != nil {
+		return filecheck.Policy{}, "", err
+	}
+	gateway := filecheck.Policy{AllowedTypes: k.opts.AllowedTypes, MaxFileBytes: k.opts.MaxFileBytes}
+	return gateway.Narrow(filecheck.Policy{
+		AllowedTypes:      filecheck.NormalizeTypes(resp.GetAllowedTypes()),
+		MaxFileBytes:      resp.GetMaxFileBytes(),
+		StorageQuotaBytes: resp.GetStorageQuotaBytes(),
+		UsedBytes:         resp.GetUsedBytes(),
+	}), resp.GetWorkspaceId(), nil
+}
+
+// reserve checks size against the quota, counting other reservations, and
+// reserves it. The check and the reservation happen under one lock.
+func (k *KnowledgeIntake) reserve(policy filecheck.Policy, wsID string, size int64) (func(), error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	policy.UsedBytes += k.reserved[wsID]
+	if err := policy.CheckSize(size); err != nil {
+		return nil, err
+	}
+	k.reserved[wsID] += size
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			k.mu.Lock()
+			if k.reserved[wsID] -= size; k.reserved[wsID] <= 0 {
+				delete(k.reserved, wsID)
+			}
+			k.mu.Unlock()
+		})
+	}, nil
+}
+
+// Precheck rejects a declared upload before any bytes arrive. The type is
+// judged by extension here; Accept checks the content.
+func (k *KnowledgeIntake) Precheck(r *http.Request, kbID, name string, size int64) error {
+	policy, err := k.Policy(r, kbID)
+	if err != nil {
+		return err
+	}
+	if ext := filecheck.ExtensionType(name); slices.Contains(filecheck.SupportedTypes(), ext) {
+		return policy.Check(ext, size)
+	}
+	return policy.CheckSize(size)
+}
+
+// Accept validates and scans a file, then stores it. It returns the blob
+// URI and the detected document type; the caller must Release the result.
+func (k *KnowledgeIntake) Accept(r *http.Request, kbID, name string, data io.ReaderAt, size int64) (StoredUpload, error) {
+	policy, wsID, err := k.policy(r, kbID)
+	if err != nil {
+		return StoredUpload{}, err
+	}
+	fileType, mime, err := filecheck.Detect(name, data, size)
+	if err != nil {
+		return StoredUpload{}, err
+	}
+	if err := policy.Check(fileType, size); err != nil {
+		return StoredUpload{}, err
+	}
+	release, err := k.reserve(policy, wsID, size)
+	if err != nil {
+		return StoredUpload{}, err
+	}
+	if err := k.scan(r, kbID, name, data, size); err != nil {
+		release()
+		return StoredUpload{}, err
+	}
+	uri, err := k.blobs.Put(r.Context(), knowledgeBaseUploadKey(kbID, name), io.NewSectionReader(data, 0, size), size)
+	if err != nil {
+		release()
+		return StoredUpload{}, err
+	}
+	logging.AddAttrs(r.Context(), slog.String("file_type", fileType), slog.String("mime", mime))
+	return StoredUpload{URI: uri, FileType: fileType, release: release}, nil
+}
+
+// Discard removes a stored upload that no document ended up referencing.
+func (k *KnowledgeIntake) Discard(r *http.Request, uri string) {
+	deleteOrphanBlob(r, k.blobs, uri)
+}
+
+func (k *KnowledgeIntake) scan(r *http.Request, kbID, name string, data io.ReaderAt, size int64) error {
+	if k.opts.Scanner == nil {
+		return nil
+	}
+	logger := logging.FromContext(r.Context())
+	verdict, err := k.opts.Scanner.Scan(r.Context(), io.NewSectionReader(data, 0, size))
+	if err != nil {
+		if k.opts.FailOpen {
+			logger.Warn("upload scan failed, accepting file", slog.Any("err", err))
+			return nil
+		}
+		logger.Error("upload scan failed", slog.Any("err", err))
+		return &filecheck.Rejection{Code: filecheck.CodeScanUnavailable, Message: "malware scanner is unavailable, try again later"}
+	}
+	if verdict.Clean {
+		return nil
+	}
+	attrs := []any{slog.String("kb_id", kbID), slog.String("file_name", name), slog.String("signature", verdict.Signature)}
+	if !k.opts.Quarantine {
+		logger.Warn("upload rejected by malware scan", attrs...)
+		return &filecheck.Rejection{Code: filecheck.CodeMalware, Message: "file rejected by malware scan: " + verdict.Signature}
+	}
+	key := "quarantine/" + strings.TrimPrefix(knowledgeBaseUploadKey(kbID, name), "kb-uploads/")
+	uri, err := k.blobs.Put(r.Context(), key, io.NewSectionReader(data, 0, size), size)
+	if err != nil {
+		logger.Error("quarantine upload failed", append(attrs, slog.Any("err", err))...)
+	} else {
+		logger.Warn("upload quarantined by malware scan", append(attrs, slog.String("storage_uri", uri))...)
+	}
+	return &filecheck.Rejection{Code: filecheck.CodeQuarantined, Message: "file quarantined by malware scan: " + verdict.Signature}
+}
+
+// writeIntakeError reports a rejection with its code, a gRPC failure as
+// usual, and anything else as a storage failure.
+func writeIntakeError(w http.ResponseWriter, r *http.Request, err error) {
+	var rejection *filecheck.Rejection
+	if errors.As(err, &rejection) {
+		writeJSON(w, rejection.HTTPStatus(), map[string]string{
+			"error":   rejection.Message,
+			"code":    rejection.Code,
+			"message": rejection.Message,
+		})
+		return
+	}
+	if _, ok := status.FromError(err); ok {
+		writeGRPCError(w, r, err)
+		return
+	}
+	logging.FromContext(r.Context()).Error("store knowledge base upload", slog.Any("err", err))
+	writeError(w, http.StatusInternalServerError, "failed to save upload file")
+}
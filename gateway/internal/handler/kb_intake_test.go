@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/filecheck"
+)
+
+func TestIntakeReservationsHoldTheQuota(t *testing.T) {
+	k := &KnowledgeIntake{reserved: map[string]int64{}}
+	policy := filecheck.Policy{StorageQuotaBytes: 100, UsedBytes: 10}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		releases []func()
+		rejected int
+	)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := k.reserve(policy, "ws-1", 30)
+			mu.Lock()
+			defer mu.Unlock()
+			var rejection *filecheck.Rejection
+			switch {
+			case err == nil:
+				releases = append(releases, release)
+			case errors.As(err, &rejection) && rejection.Code == filecheck.CodeQuotaExceeded:
+				rejected++
+			default:
+				t.Errorf("reserve: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(releases) != 3 || rejected != 7 {
+		t.Fatalf("accepted %d, rejected %d; want 3 and 7", len(releases), rejected)
+	}
+	if _, err := k.reserve(policy, "ws-2", 30); err != nil {
+		t.Errorf("other workspace should not share the reservation: %v", err)
+	}
+
+	releases[0]()
+	releases[0]() // Release is idempotent.
+	if got := k.reserved["ws-1"]; got != 60 {
+		t.Errorf("reserved after one release = %d, want 60", got)
+	}
+	releases[1]()
+	releases[2]()
+	if _, ok := k.reserved["ws-1"]; ok {
+		t.Error("empty reservation should be dropped")
+	}
+}
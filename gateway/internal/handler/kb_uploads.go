@@ -0,0 +1,283 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/filecheck"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/grpcclient"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/logging"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/middleware"
+	toolspb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/tools"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/upload"
+)
+
+// KnowledgeUploadsHandler serves resumable knowledge-base document uploads:
+//
+//	POST   /knowledge-bases/{kbId}/uploads                      {fileName, size, checksum}
+//	GET    /knowledge-bases/{kbId}/uploads/{uploadId}           progress, then status and documentId
+//	PUT    /knowledge-bases/{kbId}/uploads/{uploadId}           chunk at Upload-Offset
+//	POST   /knowledge-bases/{kbId}/uploads/{uploadId}/complete  start verifying and creating the document
+//	DELETE /knowledge-bases/{kbId}/uploads/{uploadId}           abort
+//
+// Chunks stay under the global body limit, so large files never have to
+// fit in one request. Completion answers 202 at once; the client polls the
+// upload until its status is completed or failed.
+type KnowledgeUploadsHandler struct {
+	clients *grpcclient.Clients
+	store   *upload.Store
+	intake  *KnowledgeIntake
+}
+
+func NewKnowledgeUploadsHandler(clients *grpcclient.Clients, store *upload.Store, intake *KnowledgeIntake) *KnowledgeUploadsHandler {
+	return &KnowledgeUploadsHandler{clients: clients, store: store, intake: intake}
+}
+
+type createUploadRequest struct {
+	FileName string `json:"fileName"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"` // hex SHA-256, optionally "sha256:"-prefixed
+}
+
+func (h *KnowledgeUploadsHandler) uploadView(sess upload.Session) map[string]any {
+	return map[string]any{
+		"id":              sess.ID,
+		"knowledgeBaseId": sess.KnowledgeBaseID,
+		"fileName":        sess.FileName,
+		"size":            sess.Size,
+		"offset":          sess.Offset,
+		"complete":        sess.Complete(),
+		"status":          sess.Status,
+		"documentId":      sess.DocumentID,
+		"code":            sess.Code,
+		"error":           sess.Error,
+		"chunkSize":       h.store.MaxChunkBytes(),
+		"createdAt":       sess.CreatedAt,
+		"updatedAt":       sess.UpdatedAt,
+	}
+}
+
+func (h *KnowledgeUploadsHandler) CreateUpload(w http.ResponseWriter, r *http.Request) {
+	var req createUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	req.FileName = strings.TrimSpace(req.FileName)
+	if req.FileName == "" {
+		writeError(w, http.StatusBadRequest, "fileName is required")
+		return
+	}
+	if req.Size <= 0 {
+		writeError(w, http.StatusBadRequest, "size must be positive")
+		return
+	}
+
+	kbID := chi.URLParam(r, "kbId")
+	// Fail before any bytes arrive if the caller cannot use the knowledge
+	// base or the file cannot fit its policy.
+	if err := h.intake.Precheck(r, kbID, req.FileName, req.Size); err != nil {
+		writeIntakeError(w, r, err)
+		return
+	}
+
+	sess, err := h.store.Create(upload.Session{
+		KnowledgeBaseID: kbID,
+		UserID:          uploadUserID(r),
+		FileName:        req.FileName,
+		Size:            req.Size,
+		Checksum:        req.Checksum,
+	})
+	if err != nil {
+		writeUploadError(w, r, sess, err)
+		return
+	}
+	w.Header().Set("Location", r.URL.Path+"/"+sess.ID)
+	writeData(w, http.StatusCreated, h.uploadView(sess))
+}
+
+func (h *KnowledgeUploadsHandler) GetUpload(w http.ResponseWriter, r *http.Request) {
+	sess, ok := h.session(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(sess.Offset, 10))
+	writeData(w, http.StatusOK, h.uploadView(sess))
+}
+
+// PutChunk appends the request body at the offset given by the
+// Upload-Offset header (or ?offset=).
+func (h *KnowledgeUploadsHandler) PutChunk(w http.ResponseWriter, r *http.Request) {
+	sess, ok := h.session(w, r)
+	if !ok {
+		return
+	}
+	rawOffset := r.Header.Get("Upload-Offset")
+	if rawOffset == "" {
+		rawOffset = r.URL.Query().Get("offset")
+	}
+	offset, err := strconv.ParseInt(strings.TrimSpace(rawOffset), 10, 64)
+	if err != nil || offset < 0 {
+		writeError(w, http.StatusBadRequest, "Upload-Offset header is required")
+		return
+	}
+	if r.ContentLength > h.store.MaxChunkBytes() {
+		writeError(w, http.StatusRequestEntityTooLarge, "chunk exceeds chunkSize")
+		return
+	}
+
+	sess, err = h.store.WriteChunk(sess.ID, offset, r.Body)
+	if err != nil {
+		writeUploadError(w, r, sess, err)
+		return
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(sess.Offset, 10))
+	writeData(w, http.StatusOK, h.uploadView(sess))
+}
+
+// CompleteUpload hands a fully received upload to finalize and answers 202
+// with the session, whose status moves from processing to completed or
+// failed. Repeating the request while it runs or after it succeeded starts
+// nothing new; after a failure it retries, unless the malware scan flagged
+// the file. Data that fails the checksum is dropped and the session goes back
+// to uploading from offset 0.
+func (h *KnowledgeUploadsHandler) CompleteUpload(w http.ResponseWriter, r *http.Request) {
+	sess, ok := h.session(w, r)
+	if !ok {
+		return
+	}
+	sess, started, err := h.store.Begin(sess.ID)
+	if err != nil {
+		writeUploadError(w, r, sess, err)
+		return
+	}
+	if started {
+		// Finalizing outlives the request; keep its user and logging
+		// context but not its cancellation or timeout.
+		go h.finalize(r.WithContext(context.WithoutCancel(r.Context())), sess)
+	}
+	w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/complete"))
+	writeData(w, http.StatusAccepted, h.uploadView(sess))
+}
+
+// finalize verifies the assembled file against the declared checksum and
+// only then validates, stores and registers it as a knowledge-base document,
+// recording the outcome on the session.
+func (h *KnowledgeUploadsHandler) finalize(r *http.Request, sess upload.Session) {
+	logger := logging.FromContext(r.Context()).With(slog.String("upload_id", sess.ID))
+	fail := func(err error, discard bool) {
+		code, message := importErrorCode(err)
+		if code == "ERROR" {
+			logger.Error("finalize upload failed", slog.Any("err", err))
+		}
+		if err := h.store.Fail(sess.ID, code, message, discard); err != nil {
+			logger.Warn("record failed upload", slog.Any("err", err))
+		}
+	}
+
+	sess, data, err := h.store.Assemble(sess.ID)
+	if errors.Is(err, upload.ErrChecksumMismatch) {
+		// The session is already reset for the client to upload again.
+		return
+	}
+	if err != nil {
+		fail(err, false)
+		return
+	}
+	stored, err := h.intake.Accept(r, sess.KnowledgeBaseID, sess.FileName, data, sess.Size)
+	data.Close()
+	if err != nil {
+		var rejection *filecheck.Rejection
+		flagged := errors.As(err, &rejection) && (rejection.Code == filecheck.CodeMalware || rejection.Code == filecheck.CodeQuarantined)
+		fail(err, flagged)
+		return
+	}
+	defer stored.Release()
+	resp, err := h.clients.Tools.CreateKnowledgeBaseDocument(r.Context(), &toolspb.CreateKnowledgeBaseDocumentRequest{
+		KnowledgeBaseId: sess.KnowledgeBaseID,
+		Name:            sess.FileName,
+		Type:            stored.FileType,
+		Size:            sess.Size,
+		StorageUri:      stored.URI,
+		UserContext:     userCtxFromRequest(r),
+	})
+	if err != nil {
+		h.intake.Discard(r, stored.URI)
+		fail(err, false)
+		return
+	}
+	if err := h.store.Finish(sess.ID, resp.GetId()); err != nil {
+		logger.Warn("record completed upload", slog.Any("err", err))
+	}
+}
+
+func (h *KnowledgeUploadsHandler) DeleteUpload(w http.ResponseWriter, r *http.Request) {
+	sess, ok := h.session(w, r)
+	if !ok {
+		return
+	}
+	if sess.Status == upload.StatusProcessing {
+		writeError(w, http.StatusConflict, "upload is being processed")
+		return
+	}
+	if err := h.store.Remove(sess.ID); err != nil {
+		writeUploadError(w, r, sess, err)
+		return
+	}
+	writeData(w, http.StatusOK, nil)
+}
+
+// session loads {uploadId} and hides sessions that belong to another user
+// or knowledge base.
+func (h *KnowledgeUploadsHandler) session(w http.ResponseWriter, r *http.Request) (upload.Session, bool) {
+	sess, err := h.store.Get(chi.URLParam(r, "uploadId"))
+	if err == nil && (sess.UserID != uploadUserID(r) || sess.KnowledgeBaseID != chi.URLParam(r, "kbId")) {
+		err = upload.ErrNotFound
+	}
+	if err != nil {
+		writeUploadError(w, r, sess, err)
+		return upload.Session{}, false
+	}
+	return sess, true
+}
+
+func uploadUserID(r *http.Request) string {
+	if u, ok := middleware.GetUser(r); ok {
+		return u.UserID
+	}
+	return ""
+}
+
+func writeUploadError(w http.ResponseWriter, r *http.Request, sess upload.Session, err error) {
+	var code int
+	switch {
+	case errors.Is(err, upload.ErrNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, upload.ErrOffsetMismatch), errors.Is(err, upload.ErrIncomplete),
+		errors.Is(err, upload.ErrNotRetryable), errors.Is(err, upload.ErrFinalized):
+		code = http.StatusConflict
+	case errors.Is(err, upload.ErrTooLarge):
+		code = http.StatusRequestEntityTooLarge
+	case errors.Is(err, upload.ErrChecksumMismatch):
+		code = http.StatusUnprocessableEntity
+	case errors.Is(err, upload.ErrInvalidChecksum):
+		code = http.StatusBadRequest
+	default:
+		logging.FromContext(r.Context()).Error("upload failed", slog.String("upload_id", sess.ID), slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "upload failed")
+		return
+	}
+	body := map[string]any{"error": err.Error(), "code": "ERROR", "message": err.Error()}
+	// Tell the client where to resume.
+	if sess.ID != "" && code != http.StatusNotFound {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(sess.Offset, 10))
+		body["offset"] = sess.Offset
+	}
+	writeJSON(w, code, body)
+}
@@ -470,7 +470,7 @@ func (h *OrgHandler) ListOrgs(w http.ResponseWriter, r *http.Request) {
 		UserContext: &commonpb.UserContext{UserId: u.UserID, Email: u.Email, Name: u.Name},
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	items := make([]map[string]any, 0, len(resp.Orgs))
@@ -486,7 +486,7 @@ func (h *OrgHandler) GetOrg(w http.ResponseWriter, r *http.Request) {
 		OrgId: orgID, UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, mapOrg(resp))
@@ -503,7 +503,7 @@ func (h *OrgHandler) UpdateOrg(w http.ResponseWriter, r *http.Request) {
 	body.UserContext = userCtxFromRequest(r)
 	resp, err := h.clients.Org.UpdateOrg(r.Context(), &body)
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, mapOrg(resp))
@@ -515,7 +515,7 @@ func (h *OrgHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
 		OrgId: orgID, UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, resp.Members)
@@ -527,7 +527,7 @@ func (h *OrgHandler) ListWorkspaces(w http.ResponseWriter, r *http.Request) {
 		OrgId: orgID, UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	items := make([]map[string]any, 0, len(resp.Workspaces))
@@ -543,7 +543,7 @@ func (h *OrgHandler) GetDashboardStats(w http.ResponseWriter, r *http.Request) {
 		OrgId: orgID, UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 
@@ -572,7 +572,7 @@ func (h *OrgHandler) GetDashboardTokenStats(w http.ResponseWriter, r *http.Reque
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 
@@ -887,7 +887,7 @@ func (h *OrgHandler) GetDashboardWorkload(w http.ResponseWriter, r *http.Request
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 
@@ -976,7 +976,7 @@ func (h *OrgHandler) GetDashboardActivities(w http.ResponseWriter, r *http.Reque
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 
@@ -1234,7 +1234,7 @@ func (h *OrgHandler) GetUsageOverview(w http.ResponseWriter, r *http.Request) {
 
 	views, matchedWorkspace, err := h.listOrgUsageViews(r, orgID, params)
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	if !matchedWorkspace {
@@ -1333,7 +1333,7 @@ func (h *OrgHandler) GetUsageMetrics(w http.ResponseWriter, r *http.Request) {
 
 	views, matchedWorkspace, err := h.listOrgUsageViews(r, orgID, params)
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	if !matchedWorkspace {
@@ -1582,7 +1582,7 @@ func (h *OrgHandler) GetUsageTokenTrend(w http.ResponseWriter, r *http.Request)
 
 	views, matchedWorkspace, err := h.listOrgUsageViews(r, orgID, params)
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	if !matchedWorkspace {
@@ -1624,7 +1624,7 @@ func (h *OrgHandler) GetUsageProviders(w http.ResponseWriter, r *http.Request) {
 
 	views, matchedWorkspace, err := h.listOrgUsageViews(r, orgID, params)
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	if !matchedWorkspace {
@@ -1679,7 +1679,7 @@ func (h *OrgHandler) GetUsageAgentRanking(w http.ResponseWriter, r *http.Request
 
 	views, matchedWorkspace, err := h.listOrgUsageViews(r, orgID, params)
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	if !matchedWorkspace {
@@ -1738,7 +1738,7 @@ func (h *OrgHandler) ListUsageRecords(w http.ResponseWriter, r *http.Request) {
 
 	views, matchedWorkspace, err := h.listOrgUsageViews(r, orgID, params)
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	if !matchedWorkspace {
@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/grpcclient"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/logging"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/outhook"
+	channelspb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/channels"
+)
+
+const outgoingWebhookChannelType = "outgoing_webhook"
+
+// Workspace events outgoing webhook channels can subscribe to. "ping" is
+// only ever sent on request, to test an endpoint.
+var outgoingEventTypes = []string{"agent.reply", "task.execution", "channel.message"}
+
+const outgoingEventPing = "ping"
+
+// outgoingEvent is the JSON body POSTed to an endpoint.
+type outgoingEvent struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	WorkspaceID string          `json:"workspaceId"`
+	ChannelID   string          `json:"channelId"`
+	OccurredAt  time.Time       `json:"occurredAt"`
+	Data        json.RawMessage `json:"data"`
+}
+
+// OutgoingWebhooksHandler fans workspace events out to the workspace's
+// outgoing webhook channels and serves their delivery logs. Events are
+// reported by the service and the runtime on an internal endpoint; the
+// queue does the signing, sending and retrying.
+type OutgoingWebhooksHandler struct {
+	clients       *grpcclient.Clients
+	queue         *outhook.Queue
+	runtimeSecret string
+}
+
+func NewOutgoingWebhooksHandler(clients *grpcclient.Clients, queue *outhook.Queue, runtimeSecret string) *OutgoingWebhooksHandler {
+	return &OutgoingWebhooksHandler{clients: clients, queue: queue, runtimeSecret: runtimeSecret}
+}
+
+// PublishEvent — internal endpoint (X-Runtime-Secret auth). Queues one
+// delivery per active channel in the workspace subscribed to the event.
+func (h *OutgoingWebhooksHandler) PublishEvent(w http.ResponseWriter, r *http.Request) {
+	if !authorizeRuntime(w, r, h.runtimeSecret) {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 256<<10)
+	var body struct {
+		ID          string          `json:"id"`
+		WorkspaceID string          `json:"workspaceId"`
+		Type        string          `json:"type"`
+		OccurredAt  time.Time       `json:"occurredAt"`
+		Data        json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if strings.TrimSpace(body.WorkspaceID) == "" {
+		writeError(w, http.StatusBadRequest, "workspaceId is required")
+		return
+	}
+	if !slices.Contains(outgoingEventTypes, body.Type) {
+		writeError(w, http.StatusBadRequest, "type must be one of "+strings.Join(outgoingEventTypes, ", "))
+		return
+	}
+	event := outgoingEvent{ID: body.ID, Type: body.Type, WorkspaceID: body.WorkspaceID, OccurredAt: body.OccurredAt, Data: body.Data}
+	if event.ID == "" {
+		event.ID = uuid.NewString()
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now().UTC()
+	}
+	if len(event.Data) == 0 {
+		event.Data = json.RawMessage("{}")
+	}
+
+	resp, err := h.clients.Channels.ListOutgoingWebhooks(r.Context(), &channelspb.OutgoingWebhooksRequest{WorkspaceId: body.WorkspaceID})
+	if err != nil {
+		writeGRPCError(w, r, err)
+		return
+	}
+	queued := 0
+	for _, hook := range resp.GetWebhooks() {
+		if !hook.GetActive() || !(slices.Contains(hook.GetEvents(), event.Type) || slices.Contains(hook.GetEvents(), "*")) {
+			continue
+		}
+		event.ChannelID = hook.GetChannelId()
+		if _, err := h.enqueue(event); err != nil {
+			logging.FromContext(r.Context()).Error("queue outgoing webhook",
+				slog.String("channel_id", event.ChannelID), slog.String("event_id", event.ID), slog.Any("err", err))
+			writeQueueError(w, err)
+			return
+		}
+		queued++
+	}
+	writeData(w, http.StatusAccepted, map[string]any{"eventId": event.ID, "deliveries": queued})
+}
+
+func (h *OutgoingWebhooksHandler) enqueue(event outgoingEvent) (outhook.Delivery, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return outhook.Delivery{}, err
+	}
+	return h.queue.Enqueue(outhook.Delivery{
+		ChannelID:   event.ChannelID,
+		WorkspaceID: event.WorkspaceID,
+		EventID:     event.ID,
+		EventType:   event.Type,
+		Payload:     string(payload),
+	})
+}
+
+// Resolve looks up a channel's endpoint before each delivery attempt. A
+// deleted channel, or one no longer an outgoing webhook, is inactive.
+func (h *OutgoingWebhooksHandler) Resolve(ctx context.Context, channelID string) (outhook.Target, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	resp, err := h.clients.Channels.ListOutgoingWebhooks(ctx, &channelspb.OutgoingWebhooksRequest{ChannelId: channelID})
+	if status.Code(err) == codes.NotFound {
+		return outhook.Target{}, nil
+	}
+	if err != nil {
+		return outhook.Target{}, err
+	}
+	for _, hook := range resp.GetWebhooks() {
+		if hook.GetChannelId() == channelID {
+			return outhook.Target{URL: hook.GetUrl(), Secret: hook.GetSecret(), Active: hook.GetActive()}, nil
+		}
+	}
+	return outhook.Target{}, nil
+}
+
+func (h *OutgoingWebhooksHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authorizeChannel(h.clients, w, r); !ok {
+		return
+	}
+	limit := 50
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 && n <= 500 {
+			limit = n
+		}
+	}
+	deliveries := h.queue.List(chi.URLParam(r, "channelId"), r.URL.Query().Get("status"), limit)
+	items := make([]map[string]any, 0, len(deliveries))
+	for _, d := range deliveries {
+		items = append(items, deliveryView(d, false))
+	}
+	writeData(w, http.StatusOK, items)
+}
+
+func (h *OutgoingWebhooksHandler) GetDelivery(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authorizeChannel(h.clients, w, r); !ok {
+		return
+	}
+	d, err := h.queue.Get(chi.URLParam(r, "channelId"), chi.URLParam(r, "deliveryId"))
+	if err != nil {
+		writeDeliveryError(w, r, err)
+		return
+	}
+	writeData(w, http.StatusOK, deliveryView(d, true))
+}
+
+// Redeliver sends a logged delivery's payload again as a new delivery.
+func (h *OutgoingWebhooksHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authorizeChannel(h.clients, w, r); !ok {
+		return
+	}
+	d, err := h.queue.Redeliver(chi.URLParam(r, "channelId"), chi.URLParam(r, "deliveryId"))
+	if err != nil {
+		writeDeliveryError(w, r, err)
+		return
+	}
+	writeData(w, http.StatusAccepted, deliveryView(d, false))
+}
+
+// Ping queues a "ping" event to the channel's endpoint, to test it.
+func (h *OutgoingWebhooksHandler) Ping(w http.ResponseWriter, r *http.Request) {
+	ch, ok := authorizeChannel(h.clients, w, r)
+	if !ok {
+		return
+	}
+	if ch.GetType() != outgoingWebhookChannelType {
+		writeError(w, http.StatusBadRequest, "channel is not an outgoing webhook")
+		return
+	}
+	d, err := h.enqueue(outgoingEvent{
+		ID:          uuid.NewString(),
+		Type:        outgoingEventPing,
+		WorkspaceID: ch.GetWorkspaceId(),
+		ChannelID:   chi.URLParam(r, "channelId"),
+		OccurredAt:  time.Now().UTC(),
+		Data:        json.RawMessage("{}"),
+	})
+	if err != nil {
+		writeDeliveryError(w, r, err)
+		return
+	}
+	writeData(w, http.StatusAccepted, deliveryView(d, false))
+}
+
+func writeQueueError(w http.ResponseWriter, err error) {
+	if errors.Is(err, outhook.ErrFull) {
+		w.Header().Set("Retry-After", "60")
+		writeError(w, http.StatusServiceUnavailable, "outgoing webhook queue is full")
+		return
+	}
+	writeError(w, http.StatusServiceUnavailable, "failed to queue delivery")
+}
+
+func writeDeliveryError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, outhook.ErrNotFound):
+		writeError(w, http.StatusNotFound, "delivery not found")
+	case errors.Is(err, outhook.ErrFull), errors.Is(err, outhook.ErrClosed):
+		writeQueueError(w, err)
+	default:
+		logging.FromContext(r.Context()).Error("queue delivery", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "internal server error")
+	}
+}
+
+func deliveryView(d outhook.Delivery, detail bool) map[string]any {
+	view := map[string]any{
+		"id":            d.ID,
+		"channelId":     d.ChannelID,
+		"eventId":       d.EventID,
+		"eventType":     d.EventType,
+		"redeliveryOf":  d.RedeliveryOf,
+		"status":        d.Status,
+		"attemptCount":  len(d.Attempts),
+		"createdAt":     d.CreatedAt,
+		"nextAttemptAt": d.NextAttemptAt,
+		"finishedAt":    d.FinishedAt,
+	}
+	if n := len(d.Attempts); n > 0 {
+		last := d.Attempts[n-1]
+		view["lastStatusCode"] = last.StatusCode
+		view["lastError"] = last.Error
+	}
+	if detail {
+		view["payload"] = json.RawMessage(d.Payload)
+		view["attempts"] = d.Attempts
+	}
+	if d.Status != outhook.StatusPending {
+		delete(view, "nextAttemptAt")
+	}
+	return view
+}
@@ -197,7 +197,7 @@ func (h *PluginHandler) ListMarketplace(w http.ResponseWriter, r *http.Request)
 		UserContext:  userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 
@@ -221,7 +221,7 @@ func (h *PluginHandler) GetMarketplacePlugin(w http.ResponseWriter, r *http.Requ
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, pluginMap(resp))
@@ -233,7 +233,7 @@ func (h *PluginHandler) ListPluginReviews(w http.ResponseWriter, r *http.Request
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	reviews := make([]map[string]any, len(resp.Reviews))
@@ -258,7 +258,7 @@ func (h *PluginHandler) SetPluginFavorite(w http.ResponseWriter, r *http.Request
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 
@@ -282,7 +282,7 @@ func (h *PluginHandler) UpsertPluginReview(w http.ResponseWriter, r *http.Reques
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 
@@ -295,7 +295,7 @@ func (h *PluginHandler) ListWorkspacePlugins(w http.ResponseWriter, r *http.Requ
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 
@@ -360,7 +360,7 @@ func (h *PluginHandler) InstallWorkspacePlugin(w http.ResponseWriter, r *http.Re
 		UserContext:     userCtxFromRequest(r),
 	})
 	if grpcErr != nil {
-		writeGRPCError(w, grpcErr)
+		writeGRPCError(w, r, grpcErr)
 		return
 	}
 
@@ -374,7 +374,7 @@ func (h *PluginHandler) UninstallWorkspacePlugin(w http.ResponseWriter, r *http.
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, nil)
@@ -396,7 +396,7 @@ func (h *PluginHandler) UpdateWorkspacePlugin(w http.ResponseWriter, r *http.Req
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 
@@ -425,7 +425,7 @@ func (h *PluginHandler) UpdateWorkspacePluginConfig(w http.ResponseWriter, r *ht
 		UserContext: userCtxFromRequest(r),
 	})
 	if grpcErr != nil {
-		writeGRPCError(w, grpcErr)
+		writeGRPCError(w, r, grpcErr)
 		return
 	}
 
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/grpcclient"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/middleware"
+	channelspb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/channels"
+	chatpb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/chat"
+	commonpb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/common"
+	schedulerpb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/scheduler"
+	toolspb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/tools"
+)
+
+// ResourceParams are the route parameters ResourceWorkspaceResolver can
+// place in a workspace, in the order the Authorizer checks them.
+var ResourceParams = []string{"sessionId", "agentId", "channelId", "kbId", "workflowId", "taskId"}
+
+// ResourceWorkspaceResolver looks up the workspace owning a session, agent,
+// channel, knowledge base, workflow or scheduler task through its service.
+func ResourceWorkspaceResolver(clients *grpcclient.Clients) middleware.ResourceResolver {
+	return func(ctx context.Context, user middleware.UserClaims, param, id string) (string, error) {
+		uc := &commonpb.UserContext{UserId: user.UserID, Email: user.Email, Name: user.Name}
+		var wsID string
+		var err error
+		switch param {
+		case "sessionId":
+			var s *chatpb.Session
+			if s, err = clients.Chat.GetSession(ctx, &chatpb.GetSessionRequest{SessionId: id, UserContext: uc}); err == nil {
+				wsID = s.GetWorkspaceId()
+			}
+		case "agentId":
+			var agent *chatpb.AgentItem
+			if agent, err = clients.Chat.GetAgent(ctx, &chatpb.GetAgentRequest{Id: id, UserContext: uc}); err == nil {
+				wsID = agent.GetWorkspaceId()
+			}
+		case "channelId":
+			var ch *channelspb.Channel
+			if ch, err = clients.Channels.GetChannel(ctx, &channelspb.ChannelRequest{ChannelId: id, UserContext: uc}); err == nil {
+				wsID = ch.GetWorkspaceId()
+			}
+		case "kbId":
+			var policy *toolspb.KnowledgeBaseUploadPolicy
+			if policy, err = clients.Tools.GetKnowledgeBaseUploadPolicy(ctx, &toolspb.GetKnowledgeBaseRequest{Id: id, UserContext: uc}); err == nil {
+				wsID = policy.GetWorkspaceId()
+			}
+		case "workflowId":
+			var wf *chatpb.WorkflowItem
+			if wf, err = clients.Chat.GetWorkflow(ctx, &chatpb.GetWorkflowRequest{WorkflowId: id, UserContext: uc}); err == nil {
+				wsID = wf.GetWorkspaceId()
+			}
+		case "taskId":
+			var task *schedulerpb.ScheduledTask
+			if task, err = clients.Scheduler.GetTask(ctx, &schedulerpb.TaskRequest{TaskId: id, UserContext: uc}); err == nil {
+				wsID = task.GetWorkspaceId()
+			}
+		default:
+			return "", nil
+		}
+		switch {
+		case err == nil && wsID != "":
+			return wsID, nil
+		case err == nil:
+			return "", middleware.ErrResourceNotFound
+		case transientGRPCError(err):
+			return "", err
+		}
+		switch status.Code(err) {
+		case codes.PermissionDenied, codes.Unauthenticated:
+			return "", middleware.ErrResourceForbidden
+		default:
+			// Not found or malformed: the handler answers with its own error.
+			return "", middleware.ErrResourceNotFound
+		}
+	}
+}
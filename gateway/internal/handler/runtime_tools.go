@@ -1,21 +1,36 @@
 package handler
 
 import (
-	"crypto/subtle"
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/logging"
+	chatpb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/chat"
+	settingspb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/settings"
 	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/search"
 )
 
 type RuntimeToolsHandler struct {
-	runtimeSecret      string
-	defaultProvider    string
-	registry           *search.Registry
-	selectionInputSeed search.ResolveInput
+	runtimeSecret string
+	search        atomic.Pointer[runtimeSearchState]
+	usage         *search.Usage
+	reporter      *search.UsageReporter
+}
+
+// runtimeSearchState holds the search configuration Reconfigure swaps in: the
+// global settings and the per-workspace settings and registries resolved on
+// top of them. Swapping it whole drops every cached workspace registry on a
+// reload. Quota counters and the usage reporter live on the handler instead,
+// so they carry over across reloads.
+type runtimeSearchState struct {
+	workspaces *search.WorkspaceRegistries
 }
 
 type RuntimeToolsHandlerOptions struct {
@@ -29,47 +44,177 @@ type RuntimeToolsHandlerOptions struct {
 	SearxngAPIKey      string
 	SerpAPIEndpoint    string
 	SerpAPIKey         string
+	TavilyEndpoint     string
+	TavilyAPIKey       string
+	BingEndpoint       string
+	BingAPIKey         string
+	GoogleEndpoint     string
+	GoogleAPIKey       string
+	GoogleEngineID     string
+	ExaEndpoint        string
+	ExaAPIKey          string
+	IncludeDomains     []string
+	ExcludeDomains     []string
+	Rerank             bool
+	TrustedDomains     []string
+	// WorkspaceSettings loads per-workspace overrides; nil disables them.
+	WorkspaceSettings search.SettingsLoader
+	WorkspaceCacheTTL time.Duration
+	// Quotas caps monthly calls per provider on the gateway's own keys.
+	Quotas map[string]int
+	// UsageReporter receives one event per upstream call made for a
+	// workspace; nil disables reporting. Like RuntimeSecret, it is not
+	// reloadable.
+	UsageReporter *search.UsageReporter
 }
 
 type webSearchRequest struct {
-	Query      string `json:"query"`
-	Provider   string `json:"provider,omitempty"`
-	Count      int    `json:"count,omitempty"`
-	MaxResults int    `json:"maxResults,omitempty"` // backward compatibility
-	Country    string `json:"country,omitempty"`
-	SearchLang string `json:"search_lang,omitempty"`
-	Freshness  string `json:"freshness,omitempty"`
+	WorkspaceID string `json:"workspaceId,omitempty"`
+	RunID       string `json:"runId,omitempty"`
+	Query       string `json:"query"`
+	Provider    string `json:"provider,omitempty"`
+	Count       int    `json:"count,omitempty"`
+	MaxResults  int    `json:"maxResults,omitempty"` // backward compatibility
+	Country     string `json:"country,omitempty"`
+	SearchLang  string `json:"search_lang,omitempty"`
+	Freshness   string `json:"freshness,omitempty"`
+	Offset      int    `json:"offset,omitempty"`
+	Page        int    `json:"page,omitempty"`
+	Type        string `json:"type,omitempty"`
+	SafeSearch  string `json:"safesearch,omitempty"`
+	// IncludeDomains/ExcludeDomains can only narrow the workspace policy.
+	IncludeDomains []string `json:"include_domains,omitempty"`
+	ExcludeDomains []string `json:"exclude_domains,omitempty"`
 }
 
 func NewRuntimeToolsHandler(options RuntimeToolsHandlerOptions) *RuntimeToolsHandler {
-	timeout := time.Duration(options.TimeoutMs) * time.Millisecond
-	if timeout <= 0 {
-		timeout = 12 * time.Second
-	}
-
-	registry := search.NewRegistry(
-		search.NewDuckDuckGoProvider(options.DuckDuckGoEndpoint, timeout),
-		search.NewBraveProvider(options.BraveEndpoint, options.BraveAPIKey, timeout),
-		search.NewSearxngProvider(options.SearxngEndpoint, options.SearxngAPIKey, timeout),
-		search.NewSerpAPIProvider(options.SerpAPIEndpoint, options.SerpAPIKey, timeout),
-	)
-
-	return &RuntimeToolsHandler{
-		runtimeSecret:   options.RuntimeSecret,
-		defaultProvider: strings.TrimSpace(strings.ToLower(options.DefaultProvider)),
-		registry:        registry,
-		selectionInputSeed: search.ResolveInput{
-			Configured:      strings.TrimSpace(strings.ToLower(options.DefaultProvider)),
-			BraveAPIKey:     options.BraveAPIKey,
-			SearxngEndpoint: options.SearxngEndpoint,
-			SerpAPIKey:      options.SerpAPIKey,
-		},
+	h := &RuntimeToolsHandler{
+		runtimeSecret: options.RuntimeSecret,
+		usage:         search.NewUsage(options.Quotas),
+		reporter:      options.UsageReporter,
+	}
+	h.Reconfigure(options)
+	return h
+}
+
+// Reconfigure rebuilds the search providers from options (e.g. after a config
+// reload). RuntimeSecret and UsageReporter are not reloadable and are
+// ignored; usage counters survive the reload.
+func (h *RuntimeToolsHandler) Reconfigure(options RuntimeToolsHandlerOptions) {
+	h.usage.SetQuotas(options.Quotas)
+	base := search.Settings{
+		DefaultProvider:    strings.TrimSpace(strings.ToLower(options.DefaultProvider)),
+		Timeout:            time.Duration(options.TimeoutMs) * time.Millisecond,
+		DuckDuckGoEndpoint: options.DuckDuckGoEndpoint,
+		BraveEndpoint:      options.BraveEndpoint,
+		BraveAPIKey:        options.BraveAPIKey,
+		SearxngEndpoint:    options.SearxngEndpoint,
+		SearxngAPIKey:      options.SearxngAPIKey,
+		SerpAPIEndpoint:    options.SerpAPIEndpoint,
+		SerpAPIKey:         options.SerpAPIKey,
+		TavilyEndpoint:     options.TavilyEndpoint,
+		TavilyAPIKey:       options.TavilyAPIKey,
+		BingEndpoint:       options.BingEndpoint,
+		BingAPIKey:         options.BingAPIKey,
+		GoogleEndpoint:     options.GoogleEndpoint,
+		GoogleAPIKey:       options.GoogleAPIKey,
+		GoogleEngineID:     options.GoogleEngineID,
+		ExaEndpoint:        options.ExaEndpoint,
+		ExaAPIKey:          options.ExaAPIKey,
+		IncludeDomains:     options.IncludeDomains,
+		ExcludeDomains:     options.ExcludeDomains,
+		Rerank:             options.Rerank,
+		TrustedDomains:     options.TrustedDomains,
+	}
+	h.search.Store(&runtimeSearchState{
+		workspaces: search.NewWorkspaceRegistries(base, options.WorkspaceSettings, options.WorkspaceCacheTTL),
+	})
+}
+
+// WorkspaceSearchSettings loads a workspace's web search overrides through
+// the Settings service.
+func WorkspaceSearchSettings(client settingspb.SettingsServiceClient) search.SettingsLoader {
+	return func(ctx context.Context, workspaceID string) (search.Settings, error) {
+		resp, err := client.GetWebSearchSettings(ctx, &settingspb.WorkspaceRequest{WorkspaceId: workspaceID})
+		if err != nil {
+			return search.Settings{}, err
+		}
+		return search.ParseWorkspaceSettings(resp.GetProvider(), resp.GetConfigJson())
+	}
+}
+
+// PluginUsageSink reports search usage to the Chat service as
+// plugin-usage.v1 events, one call per workspace in the batch.
+func PluginUsageSink(client chatpb.ChatServiceClient) search.UsageSink {
+	return func(ctx context.Context, events []search.UsageEvent) error {
+		byWorkspace := map[string][]*chatpb.PluginUsageEvent{}
+		var order []string
+		for _, ev := range events {
+			if _, ok := byWorkspace[ev.WorkspaceID]; !ok {
+				order = append(order, ev.WorkspaceID)
+			}
+			byWorkspace[ev.WorkspaceID] = append(byWorkspace[ev.WorkspaceID], pluginUsageEvent(ev))
+		}
+		var firstErr error
+		for _, workspaceID := range order {
+			_, err := client.ReportPluginUsageEvents(ctx, &chatpb.ReportPluginUsageEventsRequest{
+				WorkspaceId: workspaceID,
+				Events:      byWorkspace[workspaceID],
+			})
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("workspace %s: %w", workspaceID, err)
+			}
+		}
+		return firstErr
+	}
+}
+
+func pluginUsageEvent(ev search.UsageEvent) *chatpb.PluginUsageEvent {
+	status, successes, failures := "success", 1, 0
+	if ev.ErrorType != "" {
+		status, successes, failures = "failure", 0, 1
+	}
+	metrics, _ := json.Marshal(map[string]any{
+		"successCount": successes,
+		"failureCount": failures,
+		"latencyMs":    ev.Latency.Milliseconds(),
+		"resultCount":  ev.Results,
+	})
+	payload := map[string]any{
+		"provider":   ev.Provider,
+		"recordType": "tool",
+		"billable":   ev.Billable,
+	}
+	if ev.ErrorType != "" {
+		payload["errorType"] = ev.ErrorType
+	}
+	payloadJSON, _ := json.Marshal(payload)
+	return &chatpb.PluginUsageEvent{
+		SpecVersion:   "plugin-usage.v1",
+		PluginName:    "gateway.web-search",
+		PluginVersion: "1.0.0",
+		EventId:       uuid.NewString(),
+		EventType:     "web_search.call",
+		Timestamp:     ev.Timestamp.UTC().Format(time.RFC3339Nano),
+		WorkspaceId:   ev.WorkspaceID,
+		RunId:         ev.RunID,
+		Status:        status,
+		MetricsJson:   string(metrics),
+		PayloadJson:   string(payloadJSON),
 	}
 }
 
+// WebSearchUsage returns the per-provider call counters and quota usage of
+// this gateway instance.
+func (h *RuntimeToolsHandler) WebSearchUsage(w http.ResponseWriter, r *http.Request) {
+	if !authorizeRuntime(w, r, h.runtimeSecret) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"providers": h.usage.Snapshot()})
+}
+
 func (h *RuntimeToolsHandler) WebSearch(w http.ResponseWriter, r *http.Request) {
-	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Runtime-Secret")), []byte(h.runtimeSecret)) != 1 {
-		writeError(w, http.StatusUnauthorized, "invalid runtime secret")
+	if !authorizeRuntime(w, r, h.runtimeSecret) {
 		return
 	}
 
@@ -95,15 +240,29 @@ func (h *RuntimeToolsHandler) WebSearch(w http.ResponseWriter, r *http.Request)
 		count = 10
 	}
 
-	resolvedProvider := search.ResolveProviderName(search.ResolveInput{
-		Requested:       req.Provider,
-		Configured:      h.defaultProvider,
-		BraveAPIKey:     h.selectionInputSeed.BraveAPIKey,
-		SearxngEndpoint: h.selectionInputSeed.SearxngEndpoint,
-		SerpAPIKey:      h.selectionInputSeed.SerpAPIKey,
-	}, h.registry)
+	if req.WorkspaceID != "" {
+		logging.AddAttrs(r.Context(), slog.String("workspace_id", req.WorkspaceID))
+	}
+	logger := logging.FromContext(r.Context())
+	workspaces := h.search.Load().workspaces
+	settings, registry, err := workspaces.Resolve(r.Context(), req.WorkspaceID)
+	if err != nil {
+		logger.Warn("workspace search settings unavailable, using gateway defaults",
+			slog.String("workspace_id", req.WorkspaceID),
+			slog.Any("err", err))
+	}
+	selection := settings.ResolveInput()
+	selection.Requested = req.Provider
+	base, _ := workspaces.Base()
+	billable := func(provider string) bool {
+		return settings.Credential(provider) == base.Credential(provider)
+	}
+	selection.Exhausted = func(provider string) bool {
+		return billable(provider) && h.usage.Exhausted(provider)
+	}
+	resolvedProvider := search.ResolveProviderName(selection, registry)
 
-	provider, ok := h.registry.Get(resolvedProvider)
+	provider, ok := registry.Get(resolvedProvider)
 	if !ok {
 		writeJSON(w, http.StatusOK, map[string]any{
 			"query":     query,
@@ -116,15 +275,47 @@ func (h *RuntimeToolsHandler) WebSearch(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	safeSearch := req.SafeSearch
+	if strings.TrimSpace(safeSearch) == "" {
+		safeSearch = settings.SafeSearch
+	}
+	include, exclude := search.NarrowDomains(settings.IncludeDomains, settings.ExcludeDomains, req.IncludeDomains, req.ExcludeDomains)
+
+	logging.AddAttrs(r.Context(), slog.String("search_provider", resolvedProvider))
+	start := time.Now()
 	response, err := provider.Search(r.Context(), search.Query{
-		Query:      query,
-		Count:      count,
-		Country:    req.Country,
-		SearchLang: req.SearchLang,
-		Freshness:  req.Freshness,
+		Query:          query,
+		Count:          count,
+		Country:        req.Country,
+		SearchLang:     req.SearchLang,
+		Freshness:      req.Freshness,
+		Offset:         req.Offset,
+		Page:           req.Page,
+		Type:           req.Type,
+		SafeSearch:     safeSearch,
+		IncludeDomains: include,
+		ExcludeDomains: exclude,
+		Rerank:         settings.Rerank,
+		TrustedDomains: settings.TrustedDomains,
 	})
+	event := search.UsageEvent{
+		Provider:    resolvedProvider,
+		WorkspaceID: req.WorkspaceID,
+		RunID:       req.RunID,
+		Latency:     time.Since(start),
+		Results:     len(response.Results),
+		Billable:    billable(resolvedProvider),
+		Timestamp:   start,
+	}
 	if err != nil {
 		errorType := search.ClassifyError(err)
+		event.ErrorType = errorType
+		h.recordUsage(r.Context(), event)
+		logger.Warn("web search upstream failed",
+			slog.String("search_provider", resolvedProvider),
+			slog.String("error_type", errorType),
+			slog.Int64("latency_ms", time.Since(start).Milliseconds()),
+			slog.Any("err", err))
 		writeJSON(w, http.StatusOK, map[string]any{
 			"query":     query,
 			"provider":  resolvedProvider,
@@ -136,8 +327,22 @@ func (h *RuntimeToolsHandler) WebSearch(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	h.recordUsage(r.Context(), event)
 	if response.Provider == "" {
 		response.Provider = resolvedProvider
 	}
+	logger.Debug("web search upstream ok",
+		slog.String("search_provider", response.Provider),
+		slog.Int("results", len(response.Results)),
+		slog.Int64("latency_ms", time.Since(start).Milliseconds()))
 	writeJSON(w, http.StatusOK, response)
 }
+
+func (h *RuntimeToolsHandler) recordUsage(ctx context.Context, event search.UsageEvent) {
+	h.usage.Record(event)
+	// The Chat service attributes usage to a workspace, so calls without
+	// one are only counted locally.
+	if h.reporter != nil && event.WorkspaceID != "" {
+		h.reporter.Report(ctx, event)
+	}
+}
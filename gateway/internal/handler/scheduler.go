@@ -2,19 +2,32 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/cron"
 	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/grpcclient"
+	chatpb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/chat"
 	schedulerpb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/scheduler"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type SchedulerHandler struct {
 	clients *grpcclient.Clients
+	// runtime proxies to the runtime's HTTP API, for execution streams.
+	runtime       http.Handler
+	runtimeSecret string
 }
 
-func NewSchedulerHandler(clients *grpcclient.Clients) *SchedulerHandler {
-	return &SchedulerHandler{clients: clients}
+func NewSchedulerHandler(clients *grpcclient.Clients, runtime http.Handler, runtimeSecret string) *SchedulerHandler {
+	return &SchedulerHandler{clients: clients, runtime: runtime, runtimeSecret: runtimeSecret}
 }
 
 
@@ -22,7 +35,7 @@ func (h *SchedulerHandler) ListTasks(w http.ResponseWriter, r *http.Request) {
 	resp, err := h.clients.Scheduler.ListTasks(r.Context(), &schedulerpb.WorkspaceRequest{
 		WorkspaceId: chi.URLParam(r, "wsId"), UserContext: userCtxFromRequest(r),
 	})
-	if err != nil { writeGRPCError(w, err); return }
+	if err != nil { writeGRPCError(w, r, err); return }
 	writeData(w, http.StatusOK, resp.Tasks)
 }
 
@@ -33,8 +46,28 @@ func (h *SchedulerHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
 	}
 	body.WorkspaceId = chi.URLParam(r, "wsId")
 	body.UserContext = userCtxFromRequest(r)
+	if body.ScheduleType == "" {
+		body.ScheduleType = "cron"
+	}
+	in := taskInput{
+		ScheduleType: body.ScheduleType, CronExpression: body.CronExpression, RunAt: body.RunAt,
+		Timezone: body.Timezone, MaxRuns: body.MaxRuns, TargetAgentID: body.TargetAgentId,
+		TriggerJSON: body.TriggerJson, ConcurrencyPolicy: body.ConcurrencyPolicy,
+		RetryMaxAttempts: body.RetryMaxAttempts, RetryBackoffSeconds: body.RetryBackoffSeconds,
+	}
+	errs := in.validate(time.Now())
+	if strings.TrimSpace(body.Name) == "" {
+		errs["name"] = "is required"
+	}
+	if !h.checkTargetAgent(w, r, body.WorkspaceId, body.TargetAgentId, errs) {
+		return
+	}
+	if len(errs) > 0 {
+		writeFieldErrors(w, "invalid task", errs); return
+	}
+	body.RunAt = in.RunAt
 	resp, err := h.clients.Scheduler.CreateTask(r.Context(), &body)
-	if err != nil { writeGRPCError(w, err); return }
+	if err != nil { writeGRPCError(w, r, err); return }
 	writeData(w, http.StatusCreated, resp)
 }
 
@@ -45,8 +78,35 @@ func (h *SchedulerHandler) UpdateTask(w http.ResponseWriter, r *http.Request) {
 	}
 	body.TaskId = chi.URLParam(r, "taskId")
 	body.UserContext = userCtxFromRequest(r)
+	wsID := chi.URLParam(r, "wsId")
+	// An update replaces the whole schedule but may leave the type out.
+	scheduleType := body.ScheduleType
+	if scheduleType == "" {
+		task, ok := h.findTask(w, r, wsID, body.TaskId)
+		if !ok {
+			return
+		}
+		scheduleType = task.GetScheduleType()
+	}
+	in := taskInput{
+		ScheduleType: scheduleType, CronExpression: body.CronExpression, RunAt: body.RunAt,
+		Timezone: body.Timezone, MaxRuns: body.MaxRuns, TargetAgentID: body.TargetAgentId,
+		TriggerJSON: body.TriggerJson, ConcurrencyPolicy: body.ConcurrencyPolicy,
+		RetryMaxAttempts: body.RetryMaxAttempts, RetryBackoffSeconds: body.RetryBackoffSeconds,
+	}
+	errs := in.validate(time.Now())
+	if body.Status != "" && !taskStatuses[body.Status] {
+		errs["status"] = "must be active, paused or completed"
+	}
+	if !h.checkTargetAgent(w, r, wsID, body.TargetAgentId, errs) {
+		return
+	}
+	if len(errs) > 0 {
+		writeFieldErrors(w, "invalid task", errs); return
+	}
+	body.RunAt = in.RunAt
 	resp, err := h.clients.Scheduler.UpdateTask(r.Context(), &body)
-	if err != nil { writeGRPCError(w, err); return }
+	if err != nil { writeGRPCError(w, r, err); return }
 	writeData(w, http.StatusOK, resp)
 }
 
@@ -54,7 +114,7 @@ func (h *SchedulerHandler) DeleteTask(w http.ResponseWriter, r *http.Request) {
 	_, err := h.clients.Scheduler.DeleteTask(r.Context(), &schedulerpb.TaskRequest{
 		TaskId: chi.URLParam(r, "taskId"), UserContext: userCtxFromRequest(r),
 	})
-	if err != nil { writeGRPCError(w, err); return }
+	if err != nil { writeGRPCError(w, r, err); return }
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -62,14 +122,468 @@ func (h *SchedulerHandler) RunTask(w http.ResponseWriter, r *http.Request) {
 	resp, err := h.clients.Scheduler.RunTask(r.Context(), &schedulerpb.TaskRequest{
 		TaskId: chi.URLParam(r, "taskId"), UserContext: userCtxFromRequest(r),
 	})
-	if err != nil { writeGRPCError(w, err); return }
+	if err != nil { writeGRPCError(w, r, err); return }
+	writeData(w, http.StatusOK, resp)
+}
+
+// PauseTask stops a task's schedule and triggers; runs in progress finish.
+func (h *SchedulerHandler) PauseTask(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.clients.Scheduler.PauseTask(r.Context(), &schedulerpb.TaskRequest{
+		TaskId: chi.URLParam(r, "taskId"), UserContext: userCtxFromRequest(r),
+	})
+	if err != nil { writeGRPCError(w, r, err); return }
+	writeData(w, http.StatusOK, resp)
+}
+
+// ResumeTask reactivates a paused task. Slots missed while paused are not
+// run; use Backfill for those.
+func (h *SchedulerHandler) ResumeTask(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.clients.Scheduler.ResumeTask(r.Context(), &schedulerpb.TaskRequest{
+		TaskId: chi.URLParam(r, "taskId"), UserContext: userCtxFromRequest(r),
+	})
+	if err != nil { writeGRPCError(w, r, err); return }
 	writeData(w, http.StatusOK, resp)
 }
 
+const maxBackfillSlots = 100
+
+// Backfill runs a cron task's slots in [from, to) that have no execution
+// yet. Body: {"from", "to"} as RFC 3339; to must not be in the future.
+// Answers 202 with the queued executions and the number of slots skipped
+// because they already ran.
+func (h *SchedulerHandler) Backfill(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body"); return
+	}
+	wsID, taskID := chi.URLParam(r, "wsId"), chi.URLParam(r, "taskId")
+	task, ok := h.findTask(w, r, wsID, taskID)
+	if !ok {
+		return
+	}
+	if task.GetScheduleType() != "cron" {
+		writeError(w, http.StatusPreconditionFailed, "only cron tasks can be backfilled"); return
+	}
+
+	errs := map[string]string{}
+	from, err := time.Parse(time.RFC3339, body.From)
+	if err != nil {
+		errs["from"] = "must be an RFC 3339 time"
+	}
+	to, err := time.Parse(time.RFC3339, body.To)
+	if err != nil {
+		errs["to"] = "must be an RFC 3339 time"
+	} else if to.After(time.Now()) {
+		errs["to"] = "must not be in the future"
+	} else if len(errs) == 0 && !from.Before(to) {
+		errs["to"] = "must be after from"
+	}
+	if len(errs) > 0 {
+		writeFieldErrors(w, "invalid backfill range", errs); return
+	}
+	slots, err := backfillSlots(task, from, to)
+	if err != nil {
+		writeFieldErrors(w, "invalid backfill range", map[string]string{"from": err.Error()}); return
+	}
+	if len(slots) == 0 {
+		writeJSON(w, http.StatusOK, map[string]any{"data": []any{}, "skipped": 0}); return
+	}
+
+	resp, err := h.clients.Scheduler.BackfillTask(r.Context(), &schedulerpb.BackfillTaskRequest{
+		TaskId: taskID, Slots: slots, UserContext: userCtxFromRequest(r),
+	})
+	if err != nil { writeGRPCError(w, r, err); return }
+	writeJSON(w, http.StatusAccepted, map[string]any{
+		"data":    resp.GetExecutions(),
+		"skipped": resp.GetSkipped(),
+	})
+}
+
+// backfillSlots lists the task's cron fire times in [from, to). A task
+// without a timezone runs in the server's local time.
+func backfillSlots(task *schedulerpb.ScheduledTask, from, to time.Time) ([]string, error) {
+	sched, err := cron.Parse(task.GetCronExpression())
+	if err != nil {
+		return nil, errors.New("task has an invalid cron expression: " + err.Error())
+	}
+	loc := time.Local
+	if task.GetTimezone() != "" {
+		if loc, err = loadTimezone(task.GetTimezone()); err != nil {
+			return nil, errors.New("task has an invalid timezone: " + err.Error())
+		}
+	}
+	var slots []string
+	for t := sched.Next(from.In(loc).Add(-time.Second)); !t.IsZero() && t.Before(to); t = sched.Next(t) {
+		if t.Before(from) {
+			continue
+		}
+		if len(slots) == maxBackfillSlots {
+			return nil, errors.New("range covers more than " + strconv.Itoa(maxBackfillSlots) + " runs")
+		}
+		slots = append(slots, t.UTC().Format(time.RFC3339))
+	}
+	return slots, nil
+}
+
+// findTask looks a task up in its workspace. It writes the response and
+// returns false when the task is missing or the lookup fails.
+func (h *SchedulerHandler) findTask(w http.ResponseWriter, r *http.Request, wsID, taskID string) (*schedulerpb.ScheduledTask, bool) {
+	tasks, err := h.clients.Scheduler.ListTasks(r.Context(), &schedulerpb.WorkspaceRequest{
+		WorkspaceId: wsID, UserContext: userCtxFromRequest(r),
+	})
+	if err != nil { writeGRPCError(w, r, err); return nil, false }
+	for _, t := range tasks.GetTasks() {
+		if t.GetId() == taskID {
+			return t, true
+		}
+	}
+	writeError(w, http.StatusNotFound, "task not found")
+	return nil, false
+}
+
+// RotateTriggerToken replaces a webhook task's trigger token.
+func (h *SchedulerHandler) RotateTriggerToken(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.clients.Scheduler.RotateTriggerToken(r.Context(), &schedulerpb.TaskRequest{
+		TaskId: chi.URLParam(r, "taskId"), UserContext: userCtxFromRequest(r),
+	})
+	if err != nil { writeGRPCError(w, r, err); return }
+	writeData(w, http.StatusOK, resp)
+}
+
+const maxTriggerPayload = 64 << 10
+
+// FireTrigger — public endpoint for webhook tasks, no JWT auth. The task's
+// trigger token comes in X-Trigger-Token or the token query parameter; the
+// request body becomes the run's input. Answers 202 with the execution,
+// which is still running.
+func (h *SchedulerHandler) FireTrigger(w http.ResponseWriter, r *http.Request) {
+	token := r.Header.Get("X-Trigger-Token")
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	if token == "" {
+		writeError(w, http.StatusUnauthorized, "missing trigger token"); return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxTriggerPayload))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "payload exceeds 64KB"); return
+		}
+		writeError(w, http.StatusBadRequest, "failed to read body"); return
+	}
+	resp, err := h.clients.Scheduler.FireTrigger(r.Context(), &schedulerpb.FireTriggerRequest{
+		TaskId: chi.URLParam(r, "taskId"), Token: token,
+		Payload: string(body), ContentType: r.Header.Get("Content-Type"),
+	})
+	if err != nil { writeGRPCError(w, r, err); return }
+	writeData(w, http.StatusAccepted, resp)
+}
+
+const (
+	executionPageSize = 20
+	executionMaxPage  = 200
+)
+
+// ListExecutions returns one page of a task's executions, newest first.
+// Query: limit, cursor, status (queued|running|success|failed|cancelled),
+// from, to.
+// nextCursor is empty on the last page.
 func (h *SchedulerHandler) ListExecutions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := int32(executionPageSize)
+	if raw := q.Get("limit"); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 && n <= executionMaxPage {
+			limit = int32(n)
+		}
+	}
 	resp, err := h.clients.Scheduler.ListExecutions(r.Context(), &schedulerpb.ListExecutionsRequest{
-		TaskId: chi.URLParam(r, "taskId"), UserContext: userCtxFromRequest(r),
+		TaskId:      chi.URLParam(r, "taskId"),
+		Limit:       limit,
+		Cursor:      q.Get("cursor"),
+		Status:      strings.ToLower(strings.TrimSpace(q.Get("status"))),
+		From:        q.Get("from"),
+		To:          q.Get("to"),
+		UserContext: userCtxFromRequest(r),
+	})
+	if err != nil { writeGRPCError(w, r, err); return }
+	writeJSON(w, http.StatusOK, map[string]any{
+		"data":       resp.GetExecutions(),
+		"nextCursor": resp.GetNextCursor(),
+	})
+}
+
+// GetExecution returns one execution with its run and usage records.
+func (h *SchedulerHandler) GetExecution(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.clients.Scheduler.GetExecution(r.Context(), &schedulerpb.GetExecutionRequest{
+		TaskId: chi.URLParam(r, "taskId"), ExecutionId: chi.URLParam(r, "executionId"),
+		UserContext: userCtxFromRequest(r),
+	})
+	if err != nil { writeGRPCError(w, r, err); return }
+	writeData(w, http.StatusOK, resp)
+}
+
+// StreamExecution relays the runtime's SSE stream for an execution's run.
+// The stream replays from the cursor query parameter and ends with the
+// run; a finished run replays while the runtime still holds it.
+func (h *SchedulerHandler) StreamExecution(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.clients.Scheduler.GetExecution(r.Context(), &schedulerpb.GetExecutionRequest{
+		TaskId: chi.URLParam(r, "taskId"), ExecutionId: chi.URLParam(r, "executionId"),
+		UserContext: userCtxFromRequest(r),
+	})
+	if err != nil { writeGRPCError(w, r, err); return }
+	runID := resp.GetExecution().GetRunId()
+	if runID == "" {
+		if resp.GetExecution().GetStatus() == "running" {
+			writeError(w, http.StatusConflict, "execution has not started its run yet")
+		} else {
+			writeError(w, http.StatusNotFound, "execution has no run")
+		}
+		return
+	}
+	upstream := r.Clone(r.Context())
+	upstream.URL.Path = "/runtime/runs/" + runID + "/stream"
+	upstream.URL.RawPath = "/runtime/runs/" + url.PathEscape(runID) + "/stream"
+	upstream.URL.RawQuery = url.Values{"cursor": {r.URL.Query().Get("cursor")}}.Encode()
+	upstream.Header.Del("Authorization")
+	upstream.Header.Set("X-Runtime-Secret", h.runtimeSecret)
+	h.runtime.ServeHTTP(w, upstream)
+}
+
+const (
+	defaultPreviewCount = 5
+	maxPreviewCount     = 50
+)
+
+// Preview returns the next fire times of a cron expression, so a schedule
+// can be checked before it is saved. Query: cron_expression, timezone
+// (IANA, default UTC), count (default 5, at most 50).
+func (h *SchedulerHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	errs := map[string]string{}
+	tz := q.Get("timezone")
+	if tz == "" {
+		tz = "UTC"
+	}
+	loc, err := loadTimezone(tz)
+	if err != nil {
+		errs["timezone"] = err.Error()
+	}
+	count := defaultPreviewCount
+	if raw := q.Get("count"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxPreviewCount {
+			errs["count"] = "must be between 1 and " + strconv.Itoa(maxPreviewCount)
+		}
+		count = n
+	}
+	sched, err := cron.Parse(q.Get("cron_expression"))
+	if err != nil {
+		errs["cron_expression"] = err.Error()
+	}
+	if len(errs) > 0 {
+		writeFieldErrors(w, "invalid preview request", errs); return
+	}
+	runs := []string{}
+	for _, t := range sched.NextN(time.Now().In(loc), count) {
+		runs = append(runs, t.Format(time.RFC3339))
+	}
+	writeData(w, http.StatusOK, map[string]any{
+		"cron_expression": q.Get("cron_expression"),
+		"timezone":        tz,
+		"next_runs":       runs,
+	})
+}
+
+var taskStatuses = map[string]bool{"active": true, "paused": true, "completed": true}
+
+var concurrencyPolicies = map[string]bool{"allow": true, "forbid": true, "replace": true}
+
+const (
+	maxRetryAttempts       = 10
+	maxRetryBackoffSeconds = 3600
+)
+
+// taskInput is the schedule part of a create or update request.
+type taskInput struct {
+	ScheduleType   string
+	CronExpression string
+	RunAt          string
+	Timezone       string
+	MaxRuns        int32
+	TargetAgentID  string
+	TriggerJSON    string
+
+	ConcurrencyPolicy   string
+	RetryMaxAttempts    int32
+	RetryBackoffSeconds int32
+}
+
+// validate returns problems by field name. A valid run_at is normalized
+// to RFC 3339 with its offset.
+func (in *taskInput) validate(now time.Time) map[string]string {
+	errs := map[string]string{}
+	loc := time.UTC
+	if in.Timezone != "" {
+		var err error
+		if loc, err = loadTimezone(in.Timezone); err != nil {
+			errs["timezone"] = err.Error()
+			loc = time.UTC
+		}
+	}
+	if in.MaxRuns < 0 {
+		errs["max_runs"] = "must not be negative"
+	}
+	if in.ConcurrencyPolicy != "" && !concurrencyPolicies[in.ConcurrencyPolicy] {
+		errs["concurrency_policy"] = "must be allow, forbid or replace"
+	}
+	if in.RetryMaxAttempts < 0 || in.RetryMaxAttempts > maxRetryAttempts {
+		errs["retry_max_attempts"] = "must be between 1 and " + strconv.Itoa(maxRetryAttempts) + ", or 0 to leave it unset"
+	}
+	if in.RetryBackoffSeconds < 0 || in.RetryBackoffSeconds > maxRetryBackoffSeconds {
+		errs["retry_backoff_seconds"] = "must be between 1 and " + strconv.Itoa(maxRetryBackoffSeconds) + ", or 0 to leave it unset"
+	}
+	switch in.ScheduleType {
+	case "cron":
+		if in.RunAt != "" {
+			errs["run_at"] = "must be empty for cron tasks"
+		}
+		if strings.TrimSpace(in.CronExpression) == "" {
+			errs["cron_expression"] = "is required for cron tasks"
+			break
+		}
+		sched, err := cron.Parse(in.CronExpression)
+		if err != nil {
+			errs["cron_expression"] = err.Error()
+		} else if sched.Next(now.In(loc)).IsZero() {
+			errs["cron_expression"] = "never fires"
+		}
+	case "once":
+		if in.CronExpression != "" {
+			errs["cron_expression"] = "must be empty for once tasks"
+		}
+		if in.RunAt == "" {
+			errs["run_at"] = "is required for once tasks"
+			break
+		}
+		at, err := parseRunAt(in.RunAt, loc)
+		if err != nil {
+			errs["run_at"] = err.Error()
+		} else if !at.After(now) {
+			errs["run_at"] = "must be in the future"
+		} else {
+			in.RunAt = at.Format(time.RFC3339)
+		}
+	case "webhook", "channel_message", "kb_document":
+		if in.CronExpression != "" {
+			errs["cron_expression"] = "must be empty for " + in.ScheduleType + " tasks"
+		}
+		if in.RunAt != "" {
+			errs["run_at"] = "must be empty for " + in.ScheduleType + " tasks"
+		}
+		if msg := validateTrigger(in.ScheduleType, in.TriggerJSON); msg != "" {
+			errs["trigger_json"] = msg
+		}
+	default:
+		errs["schedule_type"] = "must be cron, once, webhook, channel_message or kb_document"
+	}
+	return errs
+}
+
+// triggerConfig mirrors the service's trigger_json for event-triggered tasks.
+type triggerConfig struct {
+	RateLimitPerMinute *int    `json:"rateLimitPerMinute"`
+	ChannelID          *string `json:"channelId"`
+	KnowledgeBaseID    *string `json:"knowledgeBaseId"`
+	Matchers           []struct {
+		Operator string `json:"operator"`
+		Value    string `json:"value"`
+	} `json:"matchers"`
+}
+
+var triggerOperators = map[string]bool{"contains": true, "starts_with": true, "equals": true, "regex": true}
+
+// validateTrigger returns what is wrong with a trigger_json, or "". Regex
+// syntax is left to the service, whose engine runs the matchers.
+func validateTrigger(scheduleType, raw string) string {
+	if strings.TrimSpace(raw) == "" {
+		return ""
+	}
+	var cfg triggerConfig
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return "must be a JSON object: " + err.Error()
+	}
+	if n := cfg.RateLimitPerMinute; n != nil && (*n < 1 || *n > 60) {
+		return "rateLimitPerMinute must be between 1 and 60"
+	}
+	if scheduleType != "channel_message" && (cfg.ChannelID != nil || len(cfg.Matchers) > 0) {
+		return "channelId and matchers apply only to channel_message tasks"
+	}
+	if scheduleType != "kb_document" && cfg.KnowledgeBaseID != nil {
+		return "knowledgeBaseId applies only to kb_document tasks"
+	}
+	for i, m := range cfg.Matchers {
+		if !triggerOperators[m.Operator] {
+			return "matchers[" + strconv.Itoa(i) + "].operator must be contains, starts_with, equals or regex"
+		}
+		if m.Value == "" {
+			return "matchers[" + strconv.Itoa(i) + "].value is required"
+		}
+	}
+	return ""
+}
+
+// parseRunAt accepts RFC 3339, or a local date and time read in loc.
+func parseRunAt(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errInvalidRunAt
+}
+
+var errInvalidRunAt = errors.New("must be an RFC 3339 time, or YYYY-MM-DDTHH:MM in the task's timezone")
+
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "Local" {
+		return nil, errors.New("must be an IANA time zone name")
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errors.New("unknown time zone " + strconv.Quote(name))
+	}
+	return loc, nil
+}
+
+// checkTargetAgent adds a target_agent_id error unless the agent exists in
+// the workspace. It writes the response and returns false when the lookup
+// itself fails.
+func (h *SchedulerHandler) checkTargetAgent(w http.ResponseWriter, r *http.Request, wsID, agentID string, errs map[string]string) bool {
+	if agentID == "" {
+		return true
+	}
+	agent, err := h.clients.Chat.GetAgent(r.Context(), &chatpb.GetAgentRequest{Id: agentID, UserContext: userCtxFromRequest(r)})
+	if status.Code(err) == codes.NotFound || (err == nil && agent.GetWorkspaceId() != wsID) {
+		errs["target_agent_id"] = "no such agent in this workspace"
+		return true
+	}
+	if err != nil {
+		writeGRPCError(w, r, err)
+		return false
+	}
+	return true
+}
+
+// writeFieldErrors answers 400 with the problems keyed by request field.
+func writeFieldErrors(w http.ResponseWriter, msg string, fields map[string]string) {
+	writeJSON(w, http.StatusBadRequest, map[string]any{
+		"error": msg, "code": "INVALID_ARGUMENT", "message": msg, "fields": fields,
 	})
-	if err != nil { writeGRPCError(w, err); return }
-	writeData(w, http.StatusOK, resp.Executions)
 }
@@ -0,0 +1,247 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/grpcclient"
+	schedulerpb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/scheduler"
+)
+
+// fakeScheduler answers GetExecution with a fixed execution or error.
+type fakeScheduler struct {
+	schedulerpb.SchedulerServiceClient
+	execution *schedulerpb.TaskExecution
+	err       error
+}
+
+func (f *fakeScheduler) GetExecution(ctx context.Context, in *schedulerpb.GetExecutionRequest, _ ...grpc.CallOption) (*schedulerpb.ExecutionDetail, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &schedulerpb.ExecutionDetail{Execution: f.execution}, nil
+}
+
+func TestStreamExecution(t *testing.T) {
+	tests := []struct {
+		name       string
+		execution  *schedulerpb.TaskExecution
+		err        error
+		wantStatus int
+		wantPath   string
+	}{
+		{
+			name:       "running without a run yet",
+			execution:  &schedulerpb.TaskExecution{Id: "ex-1", Status: "running"},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "finished without a run",
+			execution:  &schedulerpb.TaskExecution{Id: "ex-1", Status: "failed"},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "unknown execution",
+			err:        status.Error(codes.NotFound, "Execution not found"),
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "proxied to the run stream",
+			execution:  &schedulerpb.TaskExecution{Id: "ex-1", Status: "running", RunId: "run/1"},
+			wantStatus: http.StatusOK,
+			wantPath:   "/runtime/runs/run%2F1/stream",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var proxied *http.Request
+			runtime := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				proxied = r
+				w.Header().Set("Content-Type", "text/event-stream")
+			})
+			scheduler := &fakeScheduler{execution: tt.execution, err: tt.err}
+			h := NewSchedulerHandler(&grpcclient.Clients{Scheduler: scheduler}, runtime, "secret")
+
+			r := httptest.NewRequest(http.MethodGet, "/tasks/t-1/executions/ex-1/stream?cursor=42", nil)
+			r.Header.Set("Authorization", "Bearer user-token")
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("taskId", "t-1")
+			rctx.URLParams.Add("executionId", "ex-1")
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+			h.StreamExecution(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantPath == "" {
+				if proxied != nil {
+					t.Error("request reached the runtime")
+				}
+				return
+			}
+			if proxied == nil {
+				t.Fatal("request was not proxied")
+			}
+			if got := proxied.URL.EscapedPath(); got != tt.wantPath {
+				t.Errorf("path = %s, want %s", got, tt.wantPath)
+			}
+			if got := proxied.URL.Query().Get("cursor"); got != "42" {
+				t.Errorf("cursor = %q, want 42", got)
+			}
+			if proxied.Header.Get("X-Runtime-Secret") != "secret" || proxied.Header.Get("Authorization") != "" {
+				t.Errorf("headers = %v", proxied.Header)
+			}
+		})
+	}
+}
+
+func TestValidateTrigger(t *testing.T) {
+	tests := []struct {
+		name, scheduleType, raw, want string
+	}{
+		{"empty", "webhook", "  ", ""},
+		{"rate limit", "webhook", `{"rateLimitPerMinute": 60}`, ""},
+		{"channel matchers", "channel_message", `{"channelId": "ch-1", "matchers": [{"operator": "regex", "value": "^/deploy"}]}`, ""},
+		{"knowledge base", "kb_document", `{"knowledgeBaseId": "kb-1"}`, ""},
+		{"not an object", "webhook", `[1]`, "must be a JSON object"},
+		{"not JSON", "webhook", `{`, "must be a JSON object"},
+		{"rate limit too low", "webhook", `{"rateLimitPerMinute": 0}`, "rateLimitPerMinute must be between 1 and 60"},
+		{"rate limit too high", "kb_document", `{"rateLimitPerMinute": 61}`, "rateLimitPerMinute must be between 1 and 60"},
+		{"channel on webhook", "webhook", `{"channelId": "ch-1"}`, "apply only to channel_message tasks"},
+		{"matchers on kb", "kb_document", `{"matchers": [{"operator": "equals", "value": "x"}]}`, "apply only to channel_message tasks"},
+		{"knowledge base on channel", "channel_message", `{"knowledgeBaseId": "kb-1"}`, "applies only to kb_document tasks"},
+		{"unknown operator", "channel_message", `{"matchers": [{"operator": "equals", "value": "x"}, {"operator": "like", "value": "x"}]}`, "matchers[1].operator must be"},
+		{"empty value", "channel_message", `{"matchers": [{"operator": "contains"}]}`, "matchers[0].value is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validateTrigger(tt.scheduleType, tt.raw)
+			if (tt.want == "" && got != "") || !strings.Contains(got, tt.want) {
+				t.Errorf("validateTrigger = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackfillSlots(t *testing.T) {
+	newYork, _ := time.LoadLocation("America/New_York")
+	utc := func(s string) time.Time {
+		t, _ := time.Parse(time.RFC3339, s)
+		return t
+	}
+	tests := []struct {
+		name     string
+		cron, tz string
+		from, to time.Time
+		want     []string
+		wantErr  string
+	}{
+		{
+			name: "from inclusive, to exclusive",
+			cron: "0 * * * *", tz: "UTC",
+			from: utc("2026-03-01T10:00:00Z"), to: utc("2026-03-01T13:00:00Z"),
+			want: []string{"2026-03-01T10:00:00Z", "2026-03-01T11:00:00Z", "2026-03-01T12:00:00Z"},
+		},
+		{
+			name: "slots follow the task's timezone",
+			cron: "0 9 * * *", tz: "Asia/Shanghai",
+			from: utc("2026-03-01T00:00:00Z"), to: utc("2026-03-03T00:00:00Z"),
+			want: []string{"2026-03-01T01:00:00Z", "2026-03-02T01:00:00Z"},
+		},
+		{
+			name: "skipped local time has no slot",
+			cron: "30 2 * * *", tz: "America/New_York",
+			from: time.Date(2026, 3, 7, 0, 0, 0, 0, newYork), to: time.Date(2026, 3, 10, 0, 0, 0, 0, newYork),
+			want: []string{"2026-03-07T07:30:00Z", "2026-03-09T06:30:00Z"},
+		},
+		{
+			name: "repeated local time has a slot at both instants",
+			cron: "30 1 * * *", tz: "America/New_York",
+			from: time.Date(2026, 11, 1, 0, 0, 0, 0, newYork), to: time.Date(2026, 11, 2, 0, 0, 0, 0, newYork),
+			want: []string{"2026-11-01T05:30:00Z", "2026-11-01T06:30:00Z"},
+		},
+		{
+			name: "exactly the cap",
+			cron: "* * * * *", tz: "UTC",
+			from: utc("2026-03-01T00:00:00Z"), to: utc("2026-03-01T01:40:00Z"),
+		},
+		{
+			name: "over the cap",
+			cron: "* * * * *", tz: "UTC",
+			from: utc("2026-03-01T00:00:00Z"), to: utc("2026-03-01T01:41:00Z"),
+			wantErr: "range covers more than 100 runs",
+		},
+		{
+			name: "invalid cron", cron: "61 * * * *", tz: "UTC",
+			from: utc("2026-03-01T00:00:00Z"), to: utc("2026-03-02T00:00:00Z"),
+			wantErr: "task has an invalid cron expression",
+		},
+		{
+			name: "invalid timezone", cron: "0 * * * *", tz: "Mars/Olympus",
+			from: utc("2026-03-01T00:00:00Z"), to: utc("2026-03-02T00:00:00Z"),
+			wantErr: "task has an invalid timezone",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &schedulerpb.ScheduledTask{CronExpression: tt.cron, Timezone: tt.tz}
+			got, err := backfillSlots(task, tt.from, tt.to)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == nil {
+				if len(got) != maxBackfillSlots {
+					t.Errorf("got %d slots, want %d", len(got), maxBackfillSlots)
+				}
+				return
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("slots = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaskInputRetryBounds(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name              string
+		attempts, backoff int32
+		wantErrs          []string
+	}{
+		{name: "unset", attempts: 0, backoff: 0},
+		{name: "at the bounds", attempts: 10, backoff: 3600},
+		{name: "negative", attempts: -1, backoff: -1, wantErrs: []string{"retry_max_attempts", "retry_backoff_seconds"}},
+		{name: "too large", attempts: 11, backoff: 3601, wantErrs: []string{"retry_max_attempts", "retry_backoff_seconds"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := taskInput{ScheduleType: "cron", CronExpression: "@daily", RetryMaxAttempts: tt.attempts, RetryBackoffSeconds: tt.backoff}
+			errs := in.validate(now)
+			if len(errs) != len(tt.wantErrs) {
+				t.Fatalf("errs = %v, want %v", errs, tt.wantErrs)
+			}
+			for _, field := range tt.wantErrs {
+				if !strings.Contains(errs[field], "or 0 to leave it unset") {
+					t.Errorf("%s = %q", field, errs[field])
+				}
+			}
+		})
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/grpcclient"
 	settingspb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/settings"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/redact"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/search"
 )
 
 type SettingsHandler struct {
@@ -78,6 +80,9 @@ type workspaceSettingsView struct {
 	OcrConfig                  any     `json:"ocrConfig"`
 	DocumentProcessingProvider string  `json:"documentProcessingProvider"`
 	DocumentProcessingConfig   any     `json:"documentProcessingConfig"`
+	WebSearchProvider          string  `json:"webSearchProvider"`
+	WebSearchConfig            any     `json:"webSearchConfig"`
+	KBUploadPolicy             any     `json:"kbUploadPolicy"`
 }
 
 func NewSettingsHandler(clients *grpcclient.Clients) *SettingsHandler {
@@ -344,6 +349,8 @@ func mapWorkspaceSettingsToView(resp *settingspb.WorkspaceSettings) workspaceSet
 		return parsed
 	}
 
+	// Provider configs carry API keys and every member can read them, so
+	// secrets are masked as in the config dump; see restoreMaskedConfig.
 	return workspaceSettingsView{
 		ID:                         resp.GetId(),
 		Name:                       resp.GetName(),
@@ -357,9 +364,12 @@ func mapWorkspaceSettingsToView(resp *settingspb.WorkspaceSettings) workspaceSet
 		AgentModelID:               modelFieldValue(resp.GetAgentModelIds()),
 		SubAgentModelID:            modelFieldValue(resp.GetSubAgentModelIds()),
 		OcrProvider:                resp.GetOcrProvider(),
-		OcrConfig:                  parseJSONMap(resp.GetOcrConfigJson(), map[string]any{}),
+		OcrConfig:                  redact.Value(parseJSONMap(resp.GetOcrConfigJson(), map[string]any{})),
 		DocumentProcessingProvider: resp.GetDocumentProcessingProvider(),
-		DocumentProcessingConfig:   parseJSONMap(resp.GetDocumentProcessingConfigJson(), map[string]any{}),
+		DocumentProcessingConfig:   redact.Value(parseJSONMap(resp.GetDocumentProcessingConfigJson(), map[string]any{})),
+		WebSearchProvider:          resp.GetWebSearchProvider(),
+		WebSearchConfig:            redact.Value(parseJSONMap(resp.GetWebSearchConfigJson(), map[string]any{})),
+		KBUploadPolicy:             parseJSONMap(resp.GetKbUploadPolicyJson(), map[string]any{}),
 	}
 }
 
@@ -487,7 +497,7 @@ func readObjectJSONField(body map[string]any, key string) (string, bool) {
 func (h *SettingsHandler) GetWorkspaceSettings(w http.ResponseWriter, r *http.Request) {
 	resp, err := h.clients.Settings.GetWorkspaceSettings(r.Context(), h.wsReq(r))
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, mapWorkspaceSettingsToView(resp))
@@ -561,21 +571,81 @@ func (h *SettingsHandler) UpdateWorkspaceSettings(w http.ResponseWriter, r *http
 		req.DocumentProcessingConfigJson = value
 		req.SetDocumentProcessingConfigJson = true
 	}
+	if value, ok := readStringField(body, "webSearchProvider"); ok {
+		req.WebSearchProvider = value
+		req.SetWebSearchProvider = true
+	}
+	if value, ok := readObjectJSONField(body, "webSearchConfig"); ok {
+		req.WebSearchConfigJson = value
+		req.SetWebSearchConfigJson = true
+	}
+	if value, ok := readObjectJSONField(body, "kbUploadPolicy"); ok {
+		req.KbUploadPolicyJson = value
+		req.SetKbUploadPolicyJson = true
+	}
+	if req.SetWebSearchConfigJson {
+		if _, err := search.ParseWorkspaceSettings(req.WebSearchProvider, req.WebSearchConfigJson); err != nil {
+			writeFieldErrors(w, "invalid web search config", map[string]string{"webSearchConfig": err.Error()})
+			return
+		}
+	}
+	if !h.restoreMaskedConfig(w, r, req) {
+		return
+	}
 
 	resp, err := h.clients.Settings.UpdateWorkspaceSettings(r.Context(), req)
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, mapWorkspaceSettingsToView(resp))
 }
 
+// restoreMaskedConfig puts the stored secrets back into provider configs that
+// a client sent back with masked values, so saving a settings form unchanged
+// does not overwrite its API keys with the mask. It writes the error response
+// and returns false when the stored settings cannot be read.
+func (h *SettingsHandler) restoreMaskedConfig(w http.ResponseWriter, r *http.Request, req *settingspb.UpdateWorkspaceSettingsRequest) bool {
+	fields := []struct {
+		set    bool
+		value  *string
+		stored func(*settingspb.WorkspaceSettings) string
+	}{
+		{req.SetOcrConfigJson, &req.OcrConfigJson, (*settingspb.WorkspaceSettings).GetOcrConfigJson},
+		{req.SetDocumentProcessingConfigJson, &req.DocumentProcessingConfigJson, (*settingspb.WorkspaceSettings).GetDocumentProcessingConfigJson},
+		{req.SetWebSearchConfigJson, &req.WebSearchConfigJson, (*settingspb.WorkspaceSettings).GetWebSearchConfigJson},
+	}
+	var current *settingspb.WorkspaceSettings
+	for _, f := range fields {
+		if !f.set || !strings.Contains(*f.value, redact.Mask) {
+			continue
+		}
+		if current == nil {
+			resp, err := h.clients.Settings.GetWorkspaceSettings(r.Context(), h.wsReq(r))
+			if err != nil {
+				writeGRPCError(w, r, err)
+				return false
+			}
+			current = resp
+		}
+		var incoming, stored any
+		if err := json.Unmarshal([]byte(*f.value), &incoming); err != nil {
+			continue
+		}
+		_ = json.Unmarshal([]byte(f.stored(current)), &stored)
+		if restored, err := json.Marshal(redact.Restore(incoming, stored)); err == nil {
+			*f.value = string(restored)
+		}
+	}
+	return true
+}
+
 // ── Providers ─────────────────────────────────────────────────────────────────
 
 func (h *SettingsHandler) ListProviders(w http.ResponseWriter, r *http.Request) {
 	resp, err := h.clients.Settings.ListProviders(r.Context(), h.wsReq(r))
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	out := make([]providerView, 0, len(resp.Providers))
@@ -616,7 +686,7 @@ func (h *SettingsHandler) CreateProvider(w http.ResponseWriter, r *http.Request)
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	created := mapProviderToView(resp, 0)
@@ -661,7 +731,7 @@ func (h *SettingsHandler) UpdateProvider(w http.ResponseWriter, r *http.Request)
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	modelCount := int32(0)
@@ -679,7 +749,7 @@ func (h *SettingsHandler) DeleteProvider(w http.ResponseWriter, r *http.Request)
 		Id: chi.URLParam(r, "providerId"), WorkspaceId: chi.URLParam(r, "wsId"), UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -692,7 +762,7 @@ func (h *SettingsHandler) ListModels(w http.ResponseWriter, r *http.Request) {
 		ProviderId: chi.URLParam(r, "providerId"), WorkspaceId: chi.URLParam(r, "wsId"), UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, mapSeriesToView(resp.GetSeries()))
@@ -703,7 +773,7 @@ func (h *SettingsHandler) ListModelCatalog(w http.ResponseWriter, r *http.Reques
 		ProviderId: chi.URLParam(r, "providerId"), WorkspaceId: chi.URLParam(r, "wsId"), UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, mapSeriesToView(resp.GetSeries()))
@@ -712,12 +782,12 @@ func (h *SettingsHandler) ListModelCatalog(w http.ResponseWriter, r *http.Reques
 func (h *SettingsHandler) ListAllModels(w http.ResponseWriter, r *http.Request) {
 	resp, err := h.clients.Settings.ListAllModels(r.Context(), h.wsReq(r))
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	providersResp, err := h.clients.Settings.ListProviders(r.Context(), h.wsReq(r))
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 
@@ -752,7 +822,7 @@ func (h *SettingsHandler) CreateModel(w http.ResponseWriter, r *http.Request) {
 		UserContext:      userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusCreated, resp)
@@ -779,7 +849,7 @@ func (h *SettingsHandler) UpdateModel(w http.ResponseWriter, r *http.Request) {
 		UserContext:      userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, resp)
@@ -790,7 +860,7 @@ func (h *SettingsHandler) DeleteModel(w http.ResponseWriter, r *http.Request) {
 		Id: chi.URLParam(r, "modelId"), WorkspaceId: chi.URLParam(r, "wsId"), UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -801,7 +871,7 @@ func (h *SettingsHandler) DeleteModel(w http.ResponseWriter, r *http.Request) {
 func (h *SettingsHandler) ListApiKeys(w http.ResponseWriter, r *http.Request) {
 	resp, err := h.clients.Settings.ListApiKeys(r.Context(), h.wsReq(r))
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, resp.ApiKeys)
@@ -823,7 +893,7 @@ func (h *SettingsHandler) CreateApiKey(w http.ResponseWriter, r *http.Request) {
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusCreated, map[string]any{
@@ -842,7 +912,7 @@ func (h *SettingsHandler) DeleteApiKey(w http.ResponseWriter, r *http.Request) {
 		Id: chi.URLParam(r, "keyId"), WorkspaceId: chi.URLParam(r, "wsId"), UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -855,7 +925,7 @@ func (h *SettingsHandler) TestProvider(w http.ResponseWriter, r *http.Request) {
 		Id: chi.URLParam(r, "providerId"), WorkspaceId: chi.URLParam(r, "wsId"), UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, map[string]any{
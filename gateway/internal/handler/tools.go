@@ -1,12 +1,11 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -14,16 +13,19 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/blob"
 	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/grpcclient"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/logging"
 	toolspb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/tools"
 )
 
 type ToolsHandler struct {
 	clients *grpcclient.Clients
+	intake  *KnowledgeIntake
 }
 
-func NewToolsHandler(clients *grpcclient.Clients) *ToolsHandler {
-	return &ToolsHandler{clients: clients}
+func NewToolsHandler(clients *grpcclient.Clients, intake *KnowledgeIntake) *ToolsHandler {
+	return &ToolsHandler{clients: clients, intake: intake}
 }
 
 
@@ -34,7 +36,7 @@ func (h *ToolsHandler) ListTools(w http.ResponseWriter, r *http.Request) {
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, resp.Tools)
@@ -46,7 +48,7 @@ func (h *ToolsHandler) ListToolAuthorizations(w http.ResponseWriter, r *http.Req
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, resp.Authorizations)
@@ -62,7 +64,7 @@ func (h *ToolsHandler) UpsertToolAuthorization(w http.ResponseWriter, r *http.Re
 	body.UserContext = userCtxFromRequest(r)
 	resp, err := h.clients.Tools.UpsertToolAuthorization(r.Context(), &body)
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, resp)
@@ -74,7 +76,7 @@ func (h *ToolsHandler) ListKnowledgeBases(w http.ResponseWriter, r *http.Request
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 
@@ -119,9 +121,9 @@ func (h *ToolsHandler) CreateKnowledgeBase(w http.ResponseWriter, r *http.Reques
 
 	resp, err := h.clients.Tools.CreateKnowledgeBase(r.Context(), req)
 	if err != nil {
-		log.Printf("tools.create_knowledge_base failed: ws=%s name=%q embedding=%q err=%v",
-			chi.URLParam(r, "wsId"), body.Name, body.EmbeddingModel, err)
-		writeGRPCError(w, err)
+		logging.FromContext(r.Context()).Warn("tools.create_knowledge_base failed",
+			slog.String("name", body.Name), slog.String("embedding_model", body.EmbeddingModel), slog.Any("err", err))
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusCreated, mapKnowledgeBase(resp))
@@ -177,7 +179,7 @@ func (h *ToolsHandler) UpdateKnowledgeBase(w http.ResponseWriter, r *http.Reques
 
 	resp, err := h.clients.Tools.UpdateKnowledgeBase(r.Context(), req)
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, mapKnowledgeBase(resp))
@@ -189,7 +191,7 @@ func (h *ToolsHandler) DeleteKnowledgeBase(w http.ResponseWriter, r *http.Reques
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, nil)
@@ -201,7 +203,7 @@ func (h *ToolsHandler) ListKnowledgeBaseDocuments(w http.ResponseWriter, r *http
 		UserContext: userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 
@@ -226,30 +228,25 @@ func (h *ToolsHandler) CreateKnowledgeBaseDocument(w http.ResponseWriter, r *htt
 	defer file.Close()
 
 	fileName := strings.TrimSpace(header.Filename)
-	fileType := strings.TrimPrefix(strings.ToLower(filepath.Ext(fileName)), ".")
-	if fileType == "" {
-		fileType = "txt"
-	}
-	filePath, writtenSize, err := saveKnowledgeBaseUpload(file, fileName, chi.URLParam(r, "kbId"))
+	kbID := chi.URLParam(r, "kbId")
+	stored, err := h.intake.Accept(r, kbID, fileName, file, header.Size)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to save upload file")
+		writeIntakeError(w, r, err)
 		return
 	}
-	size := header.Size
-	if size <= 0 {
-		size = writtenSize
-	}
+	defer stored.Release()
 
 	resp, err := h.clients.Tools.CreateKnowledgeBaseDocument(r.Context(), &toolspb.CreateKnowledgeBaseDocumentRequest{
-		KnowledgeBaseId: chi.URLParam(r, "kbId"),
+		KnowledgeBaseId: kbID,
 		Name:            fileName,
-		Type:            fileType,
-		Size:            size,
-		FilePath:        filePath,
+		Type:            stored.FileType,
+		Size:            header.Size,
+		StorageUri:      stored.URI,
 		UserContext:     userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		h.intake.Discard(r, stored.URI)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusCreated, mapKnowledgeBaseDocument(resp))
@@ -270,30 +267,20 @@ func sanitizeUploadFileName(name string) string {
 	return clean
 }
 
-func saveKnowledgeBaseUpload(src io.Reader, originalName string, kbID string) (string, int64, error) {
-	rootDir, err := filepath.Abs(filepath.Join("..", "data", "kb-uploads", kbID))
-	if err != nil {
-		return "", 0, err
-	}
-	if err := os.MkdirAll(rootDir, 0o755); err != nil {
-		return "", 0, err
-	}
-
+// knowledgeBaseUploadKey returns a fresh blob key for an uploaded file
+// under the knowledge base's upload prefix.
+func knowledgeBaseUploadKey(kbID, originalName string) string {
 	safeName := sanitizeUploadFileName(originalName)
-	targetName := fmt.Sprintf("%d-%s-%s", time.Now().UnixMilli(), uuid.NewString(), safeName)
-	targetPath := filepath.Join(rootDir, targetName)
-
-	dst, err := os.Create(targetPath)
-	if err != nil {
-		return "", 0, err
-	}
-	defer dst.Close()
+	return fmt.Sprintf("kb-uploads/%s/%d-%s-%s", kbID, time.Now().UnixMilli(), uuid.NewString(), safeName)
+}
 
-	written, err := io.Copy(dst, src)
-	if err != nil {
-		return "", 0, err
+// deleteOrphanBlob removes a stored upload that no document ended up
+// referencing. It runs even if the request was cancelled.
+func deleteOrphanBlob(r *http.Request, blobs blob.Store, uri string) {
+	if err := blobs.Delete(context.WithoutCancel(r.Context()), uri); err != nil {
+		logging.FromContext(r.Context()).Warn("delete orphaned upload blob",
+			slog.String("storage_uri", uri), slog.Any("err", err))
 	}
-	return targetPath, written, nil
 }
 
 func (h *ToolsHandler) DeleteKnowledgeBaseDocument(w http.ResponseWriter, r *http.Request) {
@@ -303,7 +290,7 @@ func (h *ToolsHandler) DeleteKnowledgeBaseDocument(w http.ResponseWriter, r *htt
 		UserContext:     userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 	writeData(w, http.StatusOK, nil)
@@ -329,7 +316,7 @@ func (h *ToolsHandler) SearchKnowledgeBase(w http.ResponseWriter, r *http.Reques
 		UserContext:     userCtxFromRequest(r),
 	})
 	if err != nil {
-		writeGRPCError(w, err)
+		writeGRPCError(w, r, err)
 		return
 	}
 
@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc/status"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/grpcclient"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/logging"
+	channelspb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/channels"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/redact"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/webhookq"
+)
+
+// WebhooksHandler is the ingress for channel platform webhooks. A request
+// is verified by the service (challenge handshakes are answered there and
+// then), written to the queue and acknowledged; Deliver later hands it to
+// the service for processing. Events that cannot be delivered end up as
+// dead letters, listed and retried per channel.
+type WebhooksHandler struct {
+	clients        *grpcclient.Clients
+	queue          *webhookq.Queue
+	verifyTimeout  time.Duration
+	deliverTimeout time.Duration
+}
+
+func NewWebhooksHandler(clients *grpcclient.Clients, queue *webhookq.Queue, verifyTimeout, deliverTimeout time.Duration) *WebhooksHandler {
+	return &WebhooksHandler{clients: clients, queue: queue, verifyTimeout: verifyTimeout, deliverTimeout: deliverTimeout}
+}
+
+// HandleWebhook — public endpoint, no JWT auth required
+func (h *WebhooksHandler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
+	// Limit webhook body to 1MB
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+	channelID := chi.URLParam(r, "channelId")
+	if channelID == "" || len(channelID) > 64 {
+		writeError(w, http.StatusBadRequest, "invalid channel ID")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read body")
+		return
+	}
+	// Platforms sign lower-cased header names; keep every value.
+	headers := make(map[string][]string, len(r.Header))
+	for k, v := range r.Header {
+		headers[strings.ToLower(k)] = v
+	}
+	ev := webhookq.Event{ChannelID: channelID, Body: string(body), Headers: headers, Verified: true, ReceivedAt: time.Now().UTC()}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.verifyTimeout)
+	verdict, err := h.clients.Channels.VerifyWebhook(ctx, webhookRequest(ev))
+	cancel()
+	switch {
+	case err != nil && !transientGRPCError(err):
+		writeGRPCError(w, r, err)
+		return
+	case err != nil:
+		// The service is down or slow. Queue the event anyway so the
+		// platform is not left redelivering; the signature is checked on
+		// delivery, against the time it arrived here. Unverified events
+		// are capped separately, past which the platform gets a 503.
+		logging.FromContext(r.Context()).Warn("webhook verification unavailable, queueing unverified",
+			slog.String("channel_id", channelID), slog.Any("err", err))
+		ev.Verified = false
+	case !verdict.GetAccepted():
+		writeError(w, http.StatusUnauthorized, verdict.GetMessage())
+		return
+	case verdict.GetChallenge() != "":
+		// Return challenge string for platform URL verification handshakes
+		writeJSON(w, http.StatusOK, map[string]string{"challenge": verdict.GetChallenge()})
+		return
+	}
+	ev.EventID = verdict.GetEventId()
+
+	queued, duplicate, err := h.queue.Enqueue(ev)
+	switch {
+	case errors.Is(err, webhookq.ErrFull):
+		w.Header().Set("Retry-After", "60")
+		writeError(w, http.StatusServiceUnavailable, "webhook queue is full")
+		return
+	case errors.Is(err, webhookq.ErrUnverifiedFull):
+		// Too many events are already waiting on the service to check
+		// their signatures; have the platform retry once it is back.
+		w.Header().Set("Retry-After", "60")
+		writeError(w, http.StatusServiceUnavailable, "webhook verification unavailable")
+		return
+	case err != nil:
+		logging.FromContext(r.Context()).Error("enqueue webhook", slog.Any("err", err))
+		writeError(w, http.StatusServiceUnavailable, "failed to accept webhook")
+		return
+	case duplicate:
+		writeJSON(w, http.StatusOK, map[string]string{"status": "duplicate"})
+		return
+	}
+	logging.AddAttrs(r.Context(), slog.String("webhook_event_id", queued.ID))
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Deliver hands a queued event to the service. Failures the service may
+// recover from are retried; anything else dead-letters the event.
+func (h *WebhooksHandler) Deliver(ctx context.Context, ev webhookq.Event) error {
+	ctx, cancel := context.WithTimeout(ctx, h.deliverTimeout)
+	defer cancel()
+	req := webhookRequest(ev)
+	req.Verified = ev.Verified
+	req.EventId = ev.EventID
+	req.ReceivedAtMs = ev.ReceivedAt.UnixMilli()
+	resp, err := h.clients.Channels.HandleWebhook(ctx, req)
+	if err != nil {
+		if transientGRPCError(err) {
+			return err
+		}
+		return webhookq.Permanent(err)
+	}
+	if !resp.GetAccepted() {
+		return webhookq.Permanent(errors.New(resp.GetMessage()))
+	}
+	return nil
+}
+
+func webhookRequest(ev webhookq.Event) *channelspb.WebhookRequest {
+	first := make(map[string]string, len(ev.Headers))
+	all := make(map[string]*channelspb.WebhookHeaderValues, len(ev.Headers))
+	for k, v := range ev.Headers {
+		if len(v) > 0 {
+			first[k] = v[0]
+		}
+		all[k] = &channelspb.WebhookHeaderValues{Values: v}
+	}
+	return &channelspb.WebhookRequest{ChannelId: ev.ChannelID, Body: ev.Body, Headers: first, HeaderValues: all}
+}
+
+// transientGRPCError reports whether err is worth retrying: an outage,
+// timeout or server-side failure rather than a rejection.
+func transientGRPCError(err error) bool {
+	return grpcCodeToHTTP(status.Code(err)) >= http.StatusInternalServerError
+}
+
+// authorizeChannel checks the caller can see the {channelId} channel.
+func authorizeChannel(clients *grpcclient.Clients, w http.ResponseWriter, r *http.Request) (*channelspb.Channel, bool) {
+	ch, err := clients.Channels.GetChannel(r.Context(), &channelspb.ChannelRequest{
+		ChannelId: chi.URLParam(r, "channelId"), UserContext: userCtxFromRequest(r),
+	})
+	if err != nil {
+		writeGRPCError(w, r, err)
+		return nil, false
+	}
+	return ch, true
+}
+
+func (h *WebhooksHandler) authorizeChannel(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if _, ok := authorizeChannel(h.clients, w, r); !ok {
+		return "", false
+	}
+	return chi.URLParam(r, "channelId"), true
+}
+
+func (h *WebhooksHandler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	channelID, ok := h.authorizeChannel(w, r)
+	if !ok {
+		return
+	}
+	events := h.queue.DeadLetters(channelID)
+	items := make([]map[string]any, 0, len(events))
+	for _, ev := range events {
+		items = append(items, deadLetterView(ev))
+	}
+	writeData(w, http.StatusOK, items)
+}
+
+func (h *WebhooksHandler) RetryDeadLetter(w http.ResponseWriter, r *http.Request) {
+	channelID, ok := h.authorizeChannel(w, r)
+	if !ok {
+		return
+	}
+	ev, err := h.queue.Retry(channelID, chi.URLParam(r, "eventId"))
+	if err != nil {
+		writeDeadLetterError(w, r, err)
+		return
+	}
+	writeData(w, http.StatusAccepted, deadLetterView(ev))
+}
+
+func (h *WebhooksHandler) DeleteDeadLetter(w http.ResponseWriter, r *http.Request) {
+	channelID, ok := h.authorizeChannel(w, r)
+	if !ok {
+		return
+	}
+	if err := h.queue.Drop(channelID, chi.URLParam(r, "eventId")); err != nil {
+		writeDeadLetterError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeDeadLetterError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, webhookq.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "dead letter not found")
+		return
+	}
+	logging.FromContext(r.Context()).Error("update dead letter", slog.Any("err", err))
+	writeError(w, http.StatusInternalServerError, "internal server error")
+}
+
+func deadLetterView(ev webhookq.Event) map[string]any {
+	return map[string]any{
+		"id":         ev.ID,
+		"channelId":  ev.ChannelID,
+		"eventId":    ev.EventID,
+		"body":       ev.Body,
+		"headers":    redact.Headers(ev.Headers),
+		"verified":   ev.Verified,
+		"receivedAt": ev.ReceivedAt,
+		"attempts":   ev.Attempts,
+		"lastError":  ev.LastError,
+		"deadAt":     ev.DeadAt,
+	}
+}
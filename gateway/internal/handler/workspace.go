@@ -43,7 +43,7 @@ func (h *WorkspaceHandler) GetWorkspace(w http.ResponseWriter, r *http.Request)
 	resp, err := h.clients.Workspace.GetWorkspace(r.Context(), &workspacepb.GetWorkspaceRequest{
 		WorkspaceId: chi.URLParam(r, "wsId"), UserContext: userCtxFromRequest(r),
 	})
-	if err != nil { writeGRPCError(w, err); return }
+	if err != nil { writeGRPCError(w, r, err); return }
 	writeData(w, http.StatusOK, mapWorkspacePayload(resp))
 }
 
@@ -54,7 +54,7 @@ func (h *WorkspaceHandler) CreateWorkspace(w http.ResponseWriter, r *http.Reques
 	}
 	body.UserContext = userCtxFromRequest(r)
 	resp, err := h.clients.Workspace.CreateWorkspace(r.Context(), &body)
-	if err != nil { writeGRPCError(w, err); return }
+	if err != nil { writeGRPCError(w, r, err); return }
 	writeData(w, http.StatusCreated, mapWorkspacePayload(resp))
 }
 
@@ -66,7 +66,7 @@ func (h *WorkspaceHandler) UpdateWorkspace(w http.ResponseWriter, r *http.Reques
 	body.WorkspaceId = chi.URLParam(r, "wsId")
 	body.UserContext = userCtxFromRequest(r)
 	resp, err := h.clients.Workspace.UpdateWorkspace(r.Context(), &body)
-	if err != nil { writeGRPCError(w, err); return }
+	if err != nil { writeGRPCError(w, r, err); return }
 	writeData(w, http.StatusOK, mapWorkspacePayload(resp))
 }
 
@@ -74,6 +74,6 @@ func (h *WorkspaceHandler) DeleteWorkspace(w http.ResponseWriter, r *http.Reques
 	_, err := h.clients.Workspace.DeleteWorkspace(r.Context(), &workspacepb.DeleteWorkspaceRequest{
 		WorkspaceId: chi.URLParam(r, "wsId"), UserContext: userCtxFromRequest(r),
 	})
-	if err != nil { writeGRPCError(w, err); return }
+	if err != nil { writeGRPCError(w, r, err); return }
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,262 @@
+// Package kbimport turns an archive or a list of URLs into files ready to
+// become knowledge-base documents, and tracks the resulting import jobs.
+package kbimport
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Item error codes, alongside filecheck's rejection codes.
+const (
+	CodeUnsafePath       = "UNSAFE_PATH"
+	CodeUnsupportedEntry = "UNSUPPORTED_ENTRY"
+	CodeEntryTooLarge    = "FILE_TOO_LARGE"
+	CodeInvalidURL       = "INVALID_URL"
+	CodeBlockedURL       = "BLOCKED_URL"
+	CodeFetchFailed      = "FETCH_FAILED"
+)
+
+// Error is a failure with a stable code.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+var (
+	ErrUnknownArchive  = errors.New("archive must be a zip or tar.gz file")
+	ErrTooManyEntries  = errors.New("archive has too many entries")
+	ErrArchiveTooLarge = errors.New("archive expands beyond the size limit")
+	// ErrCompressionRatio flags a likely zip bomb.
+	ErrCompressionRatio = errors.New("archive compression ratio is suspiciously high")
+)
+
+// Limits bound what an archive may expand to. Sizes are checked against
+// the bytes actually decompressed, never the headers.
+type Limits struct {
+	MaxEntries    int
+	MaxEntryBytes int64
+	MaxTotalBytes int64
+	// MaxRatio caps uncompressed/compressed size for the whole archive
+	// and, for zip, for each entry.
+	MaxRatio int64
+}
+
+// Entry is a file extracted to local disk.
+type Entry struct {
+	// Name is the entry's cleaned path inside the archive (or the name
+	// derived from a URL).
+	Name string
+	Path string
+	Size int64
+}
+
+// Skipped is an archive entry that was not extracted.
+type Skipped struct {
+	Name   string
+	Code   string
+	Reason string
+}
+
+// Extract unpacks a zip or gzip-compressed tar archive into dir, which
+// must exist. Entries are written under generated names, so an entry path
+// can never escape dir; paths that try to are reported as skipped anyway.
+// Limit violations that suggest a bomb abort the whole extraction.
+func Extract(r io.ReaderAt, size int64, dir string, limits Limits) ([]Entry, []Skipped, error) {
+	var magic [4]byte
+	if _, err := r.ReadAt(magic[:], 0); err != nil {
+		return nil, nil, ErrUnknownArchive
+	}
+	x := &extractor{dir: dir, limits: limits, archiveSize: size}
+	var err error
+	switch {
+	case bytes.Equal(magic[:], []byte("PK\x03\x04")), bytes.Equal(magic[:], []byte("PK\x05\x06")):
+		err = x.zip(r, size)
+	case magic[0] == 0x1f && magic[1] == 0x8b:
+		err = x.tarGz(io.NewSectionReader(r, 0, size))
+	default:
+		err = ErrUnknownArchive
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return x.entries, x.skipped, nil
+}
+
+// ratioFloor exempts small outputs from ratio checks: a few kilobytes of
+// repetitive text can legitimately compress very well.
+const ratioFloor = 1 << 20
+
+type extractor struct {
+	dir         string
+	limits      Limits
+	archiveSize int64
+
+	total   int64
+	seen    int
+	entries []Entry
+	skipped []Skipped
+}
+
+func (x *extractor) zip(r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("read zip: %w", err)
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name, ok, err := x.admit(f.Name, f.Mode())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			x.skip(name, CodeUnsupportedEntry, err.Error())
+			continue
+		}
+		written, err := x.write(name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if x.limits.MaxRatio > 0 && written > ratioFloor && written/max(int64(f.CompressedSize64), 1) > x.limits.MaxRatio {
+			return ErrCompressionRatio
+		}
+	}
+	return nil
+}
+
+func (x *extractor) tarGz(r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("read gzip: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read tar: %w", err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeXGlobalHeader:
+			continue
+		}
+		name, ok, err := x.admit(hdr.Name, hdr.FileInfo().Mode())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if _, err := x.write(name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// admit counts an entry and decides whether to extract it, returning its
+// cleaned name.
+func (x *extractor) admit(raw string, mode os.FileMode) (string, bool, error) {
+	name := strings.ReplaceAll(raw, "\\", "/")
+	if ignoredEntry(name) {
+		return "", false, nil
+	}
+	x.seen++
+	if x.limits.MaxEntries > 0 && x.seen > x.limits.MaxEntries {
+		return "", false, ErrTooManyEntries
+	}
+	if path.IsAbs(name) || filepath.VolumeName(name) != "" || hasDotDot(name) {
+		x.skip(name, CodeUnsafePath, "entry path escapes the archive")
+		return "", false, nil
+	}
+	if !mode.IsRegular() {
+		x.skip(name, CodeUnsupportedEntry, "only regular files are imported")
+		return "", false, nil
+	}
+	return path.Clean(name), true, nil
+}
+
+// write copies one entry to disk, stopping at the entry and archive
+// limits. Bytes of skipped entries still count towards the archive total
+// so a bomb made of many oversized entries is caught too.
+func (x *extractor) write(name string, r io.Reader) (int64, error) {
+	limit := int64(math.MaxInt64 - 1)
+	if x.limits.MaxTotalBytes > 0 {
+		limit = x.limits.MaxTotalBytes - x.total
+	}
+	entryCapped := x.limits.MaxEntryBytes > 0 && x.limits.MaxEntryBytes < limit
+	if entryCapped {
+		limit = x.limits.MaxEntryBytes
+	}
+	f, err := os.CreateTemp(x.dir, "entry-*")
+	if err != nil {
+		return 0, err
+	}
+	written, err := io.Copy(f, io.LimitReader(r, limit+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	x.total += written
+	switch {
+	case err != nil:
+		os.Remove(f.Name())
+		x.skip(name, CodeUnsupportedEntry, err.Error())
+		return written, nil
+	case written > limit && !entryCapped:
+		os.Remove(f.Name())
+		return written, ErrArchiveTooLarge
+	case written > limit:
+		os.Remove(f.Name())
+		x.skip(name, CodeEntryTooLarge, fmt.Sprintf("entry exceeds %d bytes", x.limits.MaxEntryBytes))
+		return written, nil
+	case x.limits.MaxRatio > 0 && x.total > ratioFloor && x.total/max(x.archiveSize, 1) > x.limits.MaxRatio:
+		os.Remove(f.Name())
+		return written, ErrCompressionRatio
+	}
+	x.entries = append(x.entries, Entry{Name: name, Path: f.Name(), Size: written})
+	return written, nil
+}
+
+func (x *extractor) skip(name, code, reason string) {
+	x.skipped = append(x.skipped, Skipped{Name: name, Code: code, Reason: reason})
+}
+
+func hasDotDot(name string) bool {
+	for _, segment := range strings.Split(name, "/") {
+		if segment == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoredEntry drops archive metadata such as macOS resource forks and
+// dotfiles without reporting them.
+func ignoredEntry(name string) bool {
+	if strings.HasPrefix(name, "__MACOSX/") {
+		return true
+	}
+	base := path.Base(name)
+	return strings.HasPrefix(base, ".") && base != "." && base != ".."
+}
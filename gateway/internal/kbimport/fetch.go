@@ -0,0 +1,141 @@
+package kbimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/netguard"
+)
+
+// Fetcher downloads documents from user-supplied URLs. Unless private
+// addresses are allowed it refuses to connect to loopback, private,
+// link-local and other internal addresses, checked on the resolved IP of
+// every connection so redirects and DNS tricks cannot reach them either.
+type Fetcher struct {
+	client   *http.Client
+	maxBytes int64
+}
+
+func NewFetcher(timeout time.Duration, maxBytes int64, allowPrivate bool) *Fetcher {
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+	dialer := netguard.Dialer(10*time.Second, allowPrivate)
+	transport := &http.Transport{
+		// No proxy: the guard must see the real destination.
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		MaxIdleConnsPerHost:   2,
+	}
+	return &Fetcher{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 5 {
+					return errors.New("too many redirects")
+				}
+				if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+					return errors.New("redirect to a non-http URL")
+				}
+				return nil
+			},
+		},
+		maxBytes: maxBytes,
+	}
+}
+
+// Fetch downloads rawURL into a new file in dir. The entry is named after
+// the Content-Disposition filename or the last path segment, with an
+// extension added from the Content-Type when the name has none.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL, dir string) (Entry, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Entry{}, &Error{Code: CodeInvalidURL, Message: "url must be an absolute http(s) URL"}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Entry{}, &Error{Code: CodeInvalidURL, Message: err.Error()}
+	}
+	res, err := f.client.Do(req)
+	if err != nil {
+		if errors.Is(err, netguard.ErrBlockedAddress) {
+			return Entry{}, &Error{Code: CodeBlockedURL, Message: "url resolves to a private or internal address"}
+		}
+		return Entry{}, &Error{Code: CodeFetchFailed, Message: fmt.Sprintf("fetch failed: %v", err)}
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return Entry{}, &Error{Code: CodeFetchFailed, Message: fmt.Sprintf("fetch failed: http %d", res.StatusCode)}
+	}
+	if f.maxBytes > 0 && res.ContentLength > f.maxBytes {
+		return Entry{}, &Error{Code: CodeEntryTooLarge, Message: fmt.Sprintf("document exceeds %d bytes", f.maxBytes)}
+	}
+
+	file, err := os.CreateTemp(dir, "url-*")
+	if err != nil {
+		return Entry{}, err
+	}
+	src := io.Reader(res.Body)
+	if f.maxBytes > 0 {
+		src = io.LimitReader(res.Body, f.maxBytes+1)
+	}
+	written, err := io.Copy(file, src)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && f.maxBytes > 0 && written > f.maxBytes {
+		err = &Error{Code: CodeEntryTooLarge, Message: fmt.Sprintf("document exceeds %d bytes", f.maxBytes)}
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		var coded *Error
+		if errors.As(err, &coded) {
+			return Entry{}, err
+		}
+		return Entry{}, &Error{Code: CodeFetchFailed, Message: fmt.Sprintf("fetch failed: %v", err)}
+	}
+	return Entry{Name: fetchedName(res), Path: file.Name(), Size: written}, nil
+}
+
+func fetchedName(res *http.Response) string {
+	name := ""
+	if _, params, err := mime.ParseMediaType(res.Header.Get("Content-Disposition")); err == nil {
+		name = path.Base(strings.ReplaceAll(params["filename"], "\\", "/"))
+	}
+	if name == "" || name == "." || name == "/" {
+		name = path.Base(res.Request.URL.Path)
+	}
+	if name == "" || name == "." || name == "/" {
+		name = res.Request.URL.Hostname()
+	}
+	if path.Ext(name) == "" {
+		mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+		switch mediaType {
+		case "text/html":
+			name += ".html"
+		case "application/pdf":
+			name += ".pdf"
+		case "text/markdown":
+			name += ".md"
+		case "text/csv":
+			name += ".csv"
+		case "application/json":
+			name += ".json"
+		case "text/plain":
+			name += ".txt"
+		}
+	}
+	return name
+}
@@ -0,0 +1,166 @@
+package kbimport
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Job states.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// Item states.
+const (
+	ItemPending    = "pending"
+	ItemProcessing = "processing"
+	ItemCreated    = "created"
+	ItemFailed     = "failed"
+	ItemSkipped    = "skipped"
+)
+
+// Item is the progress of one file in a job.
+type Item struct {
+	Name       string `json:"name"`
+	Source     string `json:"source"` // archive | url
+	Status     string `json:"status"`
+	Size       int64  `json:"size,omitempty"`
+	DocumentID string `json:"documentId,omitempty"`
+	Code       string `json:"code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Job is one bulk import. Jobs live in memory only, so they are lost on
+// restart and visible only on the gateway instance that ran them.
+type Job struct {
+	ID              string     `json:"id"`
+	KnowledgeBaseID string     `json:"knowledgeBaseId"`
+	UserID          string     `json:"-"`
+	Status          string     `json:"status"`
+	Code            string     `json:"code,omitempty"`
+	Error           string     `json:"error,omitempty"`
+	Items           []Item     `json:"items"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+	FinishedAt      *time.Time `json:"finishedAt,omitempty"`
+}
+
+// Counts tallies items by status.
+func (j Job) Counts() map[string]int {
+	counts := map[string]int{}
+	for _, item := range j.Items {
+		counts[item.Status]++
+	}
+	return counts
+}
+
+// Jobs holds import jobs and forgets finished ones after ttl.
+type Jobs struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+func NewJobs(ttl time.Duration) *Jobs {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	return &Jobs{ttl: ttl, now: time.Now, jobs: map[string]*Job{}}
+}
+
+// Create registers a queued job.
+func (s *Jobs) Create(kbID, userID string) Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now().UTC()
+	for id, job := range s.jobs {
+		if job.FinishedAt != nil && now.Sub(*job.FinishedAt) > s.ttl {
+			delete(s.jobs, id)
+		}
+	}
+	job := &Job{
+		ID:              uuid.NewString(),
+		KnowledgeBaseID: kbID,
+		UserID:          userID,
+		Status:          JobQueued,
+		Items:           []Item{},
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	s.jobs[job.ID] = job
+	return job.clone()
+}
+
+// Get returns a copy of a job.
+func (s *Jobs) Get(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return job.clone(), true
+}
+
+// Update applies fn to a job under the store lock.
+func (s *Jobs) Update(id string, fn func(*Job)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return
+	}
+	fn(job)
+	job.UpdatedAt = s.now().UTC()
+	if (job.Status == JobCompleted || job.Status == JobFailed) && job.FinishedAt == nil {
+		finished := job.UpdatedAt
+		job.FinishedAt = &finished
+	}
+}
+
+// UpdateItem applies fn to item i of a job.
+func (s *Jobs) UpdateItem(id string, i int, fn func(*Item)) {
+	s.Update(id, func(job *Job) {
+		if i >= 0 && i < len(job.Items) {
+			fn(&job.Items[i])
+		}
+	})
+}
+
+func (j *Job) clone() Job {
+	out := *j
+	out.Items = append([]Item(nil), j.Items...)
+	return out
+}
+
+// ForEach calls fn for 0..n-1 on at most concurrency goroutines and waits
+// for them. Indexes not yet started when ctx ends are not run.
+func ForEach(ctx context.Context, concurrency, n int, fn func(i int)) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			fn(i)
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,215 @@
+package kbimport
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type archiveEntry struct {
+	name    string
+	content string
+	mode    int64
+}
+
+func buildZip(t *testing.T, entries ...archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		if e.mode != 0 {
+			hdr.SetMode(os.FileMode(e.mode))
+		}
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(e.content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func buildTarGz(t *testing.T, entries ...archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.content)), Typeflag: tar.TypeReg}
+		if e.mode == int64(os.ModeSymlink) {
+			hdr = &tar.Header{Name: e.name, Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(e.content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func extract(t *testing.T, archive []byte, limits Limits) ([]Entry, []Skipped, error) {
+	t.Helper()
+	return Extract(bytes.NewReader(archive), int64(len(archive)), t.TempDir(), limits)
+}
+
+func entryNames(entries []Entry) string {
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestExtractZip(t *testing.T) {
+	archive := buildZip(t,
+		archiveEntry{name: "docs/a.md", content: "# A"},
+		archiveEntry{name: "../../etc/cron.d/evil", content: "x"},
+		archiveEntry{name: "/abs/path.txt", content: "x"},
+		archiveEntry{name: "__MACOSX/docs/._a.md", content: "x"},
+		archiveEntry{name: "docs/.DS_Store", content: "x"},
+		archiveEntry{name: "big.txt", content: strings.Repeat("b", 100)},
+		archiveEntry{name: "link", content: "/etc/passwd", mode: int64(os.ModeSymlink | 0o777)},
+	)
+	entries, skipped, err := extract(t, archive, Limits{MaxEntryBytes: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := entryNames(entries); got != "docs/a.md" {
+		t.Errorf("entries = %s", got)
+	}
+	if content, _ := os.ReadFile(entries[0].Path); string(content) != "# A" {
+		t.Errorf("content = %q", content)
+	}
+	codes := map[string]string{}
+	for _, s := range skipped {
+		codes[s.Name] = s.Code
+	}
+	want := map[string]string{
+		"../../etc/cron.d/evil": CodeUnsafePath,
+		"/abs/path.txt":         CodeUnsafePath,
+		"big.txt":               CodeEntryTooLarge,
+		"link":                  CodeUnsupportedEntry,
+	}
+	for name, code := range want {
+		if codes[name] != code {
+			t.Errorf("skipped[%s] = %q, want %s (all: %v)", name, codes[name], code, codes)
+		}
+	}
+}
+
+func TestExtractTarGz(t *testing.T) {
+	archive := buildTarGz(t,
+		archiveEntry{name: "notes/one.txt", content: "one"},
+		archiveEntry{name: "notes/../../two.txt", content: "two"},
+		archiveEntry{name: "notes/link", mode: int64(os.ModeSymlink)},
+	)
+	entries, skipped, err := extract(t, archive, Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := entryNames(entries); got != "notes/one.txt" {
+		t.Errorf("entries = %s", got)
+	}
+	if len(skipped) != 2 {
+		t.Errorf("skipped = %+v", skipped)
+	}
+}
+
+func TestExtractBombs(t *testing.T) {
+	zeros := strings.Repeat("\x00", 4<<20)
+	bomb := buildZip(t, archiveEntry{name: "zeros.txt", content: zeros})
+	if _, _, err := extract(t, bomb, Limits{MaxRatio: 100}); !errors.Is(err, ErrCompressionRatio) {
+		t.Errorf("ratio bomb err = %v", err)
+	}
+	if _, _, err := extract(t, bomb, Limits{MaxTotalBytes: 1 << 20}); !errors.Is(err, ErrArchiveTooLarge) {
+		t.Errorf("total bomb err = %v", err)
+	}
+	many := make([]archiveEntry, 6)
+	for i := range many {
+		many[i] = archiveEntry{name: strings.Repeat("f", i+1) + ".txt", content: "x"}
+	}
+	if _, _, err := extract(t, buildTarGz(t, many...), Limits{MaxEntries: 5}); !errors.Is(err, ErrTooManyEntries) {
+		t.Errorf("entry count err = %v", err)
+	}
+	if _, _, err := extract(t, []byte("plain text, not an archive"), Limits{}); !errors.Is(err, ErrUnknownArchive) {
+		t.Errorf("unknown archive err = %v", err)
+	}
+}
+
+func TestFetcher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/guide":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<html>guide</html>"))
+		case "/download":
+			w.Header().Set("Content-Disposition", `attachment; filename="../report.pdf"`)
+			w.Write([]byte("%PDF-1.4"))
+		case "/huge":
+			w.Write([]byte(strings.Repeat("x", 200)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	blocked := NewFetcher(time.Second, 0, false)
+	var coded *Error
+	if _, err := blocked.Fetch(ctx, server.URL+"/guide", t.TempDir()); !errors.As(err, &coded) || coded.Code != CodeBlockedURL {
+		t.Fatalf("loopback fetch err = %v, want %s", err, CodeBlockedURL)
+	}
+	if _, err := blocked.Fetch(ctx, "file:///etc/passwd", t.TempDir()); !errors.As(err, &coded) || coded.Code != CodeInvalidURL {
+		t.Errorf("file url err = %v", err)
+	}
+
+	fetcher := NewFetcher(time.Second, 100, true)
+	entry, err := fetcher.Fetch(ctx, server.URL+"/guide", t.TempDir())
+	if err != nil || entry.Name != "guide.html" || entry.Size != int64(len("<html>guide</html>")) {
+		t.Errorf("guide = %+v, %v", entry, err)
+	}
+	if entry, err := fetcher.Fetch(ctx, server.URL+"/download", t.TempDir()); err != nil || entry.Name != "report.pdf" {
+		t.Errorf("download = %+v, %v", entry, err)
+	}
+	if _, err := fetcher.Fetch(ctx, server.URL+"/huge", t.TempDir()); !errors.As(err, &coded) || coded.Code != CodeEntryTooLarge {
+		t.Errorf("huge err = %v", err)
+	}
+	if _, err := fetcher.Fetch(ctx, server.URL+"/missing", t.TempDir()); !errors.As(err, &coded) || coded.Code != CodeFetchFailed {
+		t.Errorf("missing err = %v", err)
+	}
+}
+
+func TestForEachBoundsConcurrency(t *testing.T) {
+	var running, peak, done atomic.Int32
+	ForEach(context.Background(), 3, 20, func(int) {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(2 * time.Millisecond)
+		running.Add(-1)
+		done.Add(1)
+	})
+	if done.Load() != 20 || peak.Load() > 3 {
+		t.Errorf("done=%d peak=%d", done.Load(), peak.Load())
+	}
+}
@@ -0,0 +1,109 @@
+// Package logging provides the gateway's structured (log/slog) logger and
+// threads a request-scoped logger through the request context.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/redact"
+)
+
+// New builds a logger writing JSON (default) or text to w. Attributes whose
+// keys look like secrets are masked, as are bearer tokens in string values.
+func New(format, level string, w io.Writer) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       ParseLevel(level),
+		ReplaceAttr: redactAttr,
+	}
+	var h slog.Handler
+	if strings.EqualFold(strings.TrimSpace(format), "text") {
+		h = slog.NewTextHandler(w, opts)
+	} else {
+		h = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(h)
+}
+
+// ParseLevel maps debug|info|warn|error to a slog level (default info).
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if redact.IsSecretKey(a.Key) {
+		return slog.String(a.Key, redact.Mask)
+	}
+	if a.Value.Kind() == slog.KindString && strings.HasPrefix(strings.TrimSpace(a.Value.String()), "Bearer ") {
+		return slog.String(a.Key, "Bearer "+redact.Mask)
+	}
+	return a
+}
+
+type ctxKey struct{}
+
+// requestState is shared by every layer of a request, so attributes added
+// deep inside the chain (e.g. the user ID from Auth) also reach the access
+// log line written by the outermost middleware.
+type requestState struct {
+	mu     sync.Mutex
+	logger *slog.Logger
+	attrs  []slog.Attr
+}
+
+func newContext(ctx context.Context, logger *slog.Logger) (context.Context, *requestState) {
+	state := &requestState{logger: logger}
+	return context.WithValue(ctx, ctxKey{}, state), state
+}
+
+// AddAttrs attaches attrs to the request-scoped logger in ctx. It is a no-op
+// outside of a request started by Middleware.
+func AddAttrs(ctx context.Context, attrs ...slog.Attr) {
+	state, ok := ctx.Value(ctxKey{}).(*requestState)
+	if !ok || len(attrs) == 0 {
+		return
+	}
+	args := make([]any, len(attrs))
+	for i, attr := range attrs {
+		args[i] = attr
+	}
+	state.mu.Lock()
+	state.logger = state.logger.With(args...)
+	state.attrs = append(state.attrs, attrs...)
+	state.mu.Unlock()
+}
+
+// FromContext returns the request-scoped logger, enriched with the matched
+// route pattern and workspace ID once routing has happened. Outside of a
+// request it returns slog.Default().
+func FromContext(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	if state, ok := ctx.Value(ctxKey{}).(*requestState); ok {
+		state.mu.Lock()
+		logger = state.logger
+		state.mu.Unlock()
+	}
+	if rctx := chi.RouteContext(ctx); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			logger = logger.With(slog.String("route", pattern))
+		}
+		if wsID := rctx.URLParam("wsId"); wsID != "" {
+			logger = logger.With(slog.String("ws_id", wsID))
+		}
+	}
+	return logger
+}
@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("invalid json log line %q: %v", line, err)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestMiddlewareAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New("json", "debug", &buf)
+
+	r := chi.NewRouter()
+	r.Use(chimiddleware.RequestID)
+	r.Use(Middleware(logger, nil))
+	r.Get("/workspaces/{wsId}/agents", func(w http.ResponseWriter, r *http.Request) {
+		AddAttrs(r.Context(), slog.String("user_id", "u1"))
+		FromContext(r.Context()).Info("handler", slog.String("api_key", "sk-live"), slog.String("auth", "Bearer abc"))
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/workspaces/ws-1/agents", nil))
+
+	lines := decodeLines(t, &buf)
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2: %s", len(lines), buf.String())
+	}
+	handlerLine, access := lines[0], lines[1]
+	if handlerLine["api_key"] != "[REDACTED]" || handlerLine["auth"] != "Bearer [REDACTED]" {
+		t.Errorf("secrets not redacted: %v", handlerLine)
+	}
+	if handlerLine["ws_id"] != "ws-1" || handlerLine["user_id"] != "u1" || handlerLine["request_id"] == "" {
+		t.Errorf("handler line missing request context: %v", handlerLine)
+	}
+	want := map[string]any{
+		"msg":     "http request",
+		"level":   "WARN",
+		"route":   "/workspaces/{wsId}/agents",
+		"ws_id":   "ws-1",
+		"user_id": "u1",
+		"status":  float64(http.StatusTeapot),
+	}
+	for k, v := range want {
+		if access[k] != v {
+			t.Errorf("access[%q] = %v, want %v", k, access[k], v)
+		}
+	}
+	if _, ok := access["latency_ms"]; !ok {
+		t.Errorf("access log missing latency_ms: %v", access)
+	}
+}
+
+func TestSampler(t *testing.T) {
+	s := NewSampler(ParseSampleRates("/v1/*=3, bad, /x=1"))
+	kept := 0
+	for i := 0; i < 9; i++ {
+		if ok, rate := s.Keep("/v1/*", http.StatusOK); ok {
+			kept++
+			if rate != 3 {
+				t.Errorf("rate = %d, want 3", rate)
+			}
+		}
+	}
+	if kept != 3 {
+		t.Errorf("kept %d of 9, want 3", kept)
+	}
+	if ok, _ := s.Keep("/v1/*", http.StatusBadGateway); !ok {
+		t.Error("errors must always be logged")
+	}
+	if ok, rate := s.Keep("/x", http.StatusOK); !ok || rate != 1 {
+		t.Errorf("unsampled route = %v/%d", ok, rate)
+	}
+}
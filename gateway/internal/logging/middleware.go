@@ -0,0 +1,130 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// Sampler thins access logs for high-volume routes. A route with rate N logs
+// one in N successful requests; 4xx/5xx responses are always logged.
+type Sampler struct {
+	rates    map[string]uint64
+	mu       sync.Mutex
+	counters map[string]*atomic.Uint64
+}
+
+// ParseSampleRates parses "routePattern=N,routePattern=N".
+func ParseSampleRates(raw string) map[string]int {
+	out := make(map[string]int)
+	for _, part := range strings.Split(raw, ",") {
+		route, rate, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(rate))
+		if err != nil || n <= 1 {
+			continue
+		}
+		out[strings.TrimSpace(route)] = n
+	}
+	return out
+}
+
+func NewSampler(rates map[string]int) *Sampler {
+	s := &Sampler{rates: make(map[string]uint64, len(rates)), counters: make(map[string]*atomic.Uint64)}
+	for route, rate := range rates {
+		if rate > 1 {
+			s.rates[route] = uint64(rate)
+		}
+	}
+	return s
+}
+
+// Keep reports whether a request should be logged, and at which rate it
+// was sampled (1 when it was not sampled).
+func (s *Sampler) Keep(route string, status int) (bool, int) {
+	if s == nil || status >= 400 {
+		return true, 1
+	}
+	rate, ok := s.rates[route]
+	if !ok {
+		return true, 1
+	}
+	s.mu.Lock()
+	counter, ok := s.counters[route]
+	if !ok {
+		counter = &atomic.Uint64{}
+		s.counters[route] = counter
+	}
+	s.mu.Unlock()
+	return counter.Add(1)%rate == 1, int(rate)
+}
+
+// Middleware replaces chi's text Logger: it installs a request-scoped logger
+// (carrying request_id) in the context and writes one structured access log
+// line per request with status, latency, route, ws_id and user_id.
+// It must run after chimiddleware.RequestID.
+func Middleware(base *slog.Logger, sampler *Sampler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			logger := base.With(slog.String("request_id", chimiddleware.GetReqID(r.Context())))
+			ctx, state := newContext(r.Context(), logger)
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			defer func() {
+				status := ww.Status()
+				if status == 0 {
+					status = http.StatusOK
+				}
+				route := ""
+				wsID := ""
+				if rctx := chi.RouteContext(r.Context()); rctx != nil {
+					route = rctx.RoutePattern()
+					wsID = rctx.URLParam("wsId")
+				}
+				keep, rate := sampler.Keep(route, status)
+				if !keep {
+					return
+				}
+
+				attrs := []slog.Attr{
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.String("route", route),
+					slog.Int("status", status),
+					slog.Int("bytes", ww.BytesWritten()),
+					slog.Int64("latency_ms", time.Since(start).Milliseconds()),
+					slog.String("remote_ip", r.RemoteAddr),
+				}
+				if wsID != "" {
+					attrs = append(attrs, slog.String("ws_id", wsID))
+				}
+				if rate > 1 {
+					attrs = append(attrs, slog.Int("sample_rate", rate))
+				}
+				state.mu.Lock()
+				attrs = append(attrs, state.attrs...)
+				state.mu.Unlock()
+
+				level := slog.LevelInfo
+				if status >= 500 {
+					level = slog.LevelError
+				} else if status >= 400 {
+					level = slog.LevelWarn
+				}
+				logger.LogAttrs(r.Context(), level, "http request", attrs...)
+			}()
+
+			next.ServeHTTP(ww, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/netguard"
+)
+
+// Connection security for IMAP and SMTP.
+const (
+	SecurityTLS      = "tls"      // implicit TLS
+	SecurityStartTLS = "starttls" // upgrade after connecting; SMTP only
+	SecurityNone     = "none"     // plaintext; loopback hosts only
+)
+
+// Account is an email channel's mailbox, built from its channel config.
+type Account struct {
+	Address      string
+	Username     string
+	Password     string
+	IMAPHost     string
+	IMAPPort     int
+	IMAPSecurity string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPSecurity string
+	Mailbox      string
+	// Subject is used for messages that do not reply to a thread.
+	Subject string
+}
+
+// AccountFromConfig reads an email channel config. Keys: address,
+// username (default address), password, imapHost, imapPort, imapSecurity
+// (tls|none), smtpHost, smtpPort, smtpSecurity (tls|starttls|none),
+// mailbox (default INBOX) and subject.
+func AccountFromConfig(cfg map[string]string) (Account, error) {
+	get := func(key string) string { return strings.TrimSpace(cfg[key]) }
+	a := Account{
+		Address:      get("address"),
+		Username:     get("username"),
+		Password:     cfg["password"],
+		IMAPHost:     get("imapHost"),
+		IMAPSecurity: strings.ToLower(get("imapSecurity")),
+		SMTPHost:     get("smtpHost"),
+		SMTPSecurity: strings.ToLower(get("smtpSecurity")),
+		Mailbox:      get("mailbox"),
+		Subject:      get("subject"),
+	}
+	addr, err := mail.ParseAddress(a.Address)
+	if err != nil {
+		return Account{}, fmt.Errorf("address: %v", err)
+	}
+	a.Address = addr.Address
+	if a.Username == "" {
+		a.Username = a.Address
+	}
+	if a.Mailbox == "" {
+		a.Mailbox = "INBOX"
+	}
+	if a.IMAPSecurity == "" {
+		a.IMAPSecurity = SecurityTLS
+	}
+	if a.SMTPSecurity == "" {
+		a.SMTPSecurity = SecurityStartTLS
+	}
+	if a.IMAPSecurity != SecurityTLS && a.IMAPSecurity != SecurityNone {
+		return Account{}, errors.New("imapSecurity must be tls or none")
+	}
+	if a.SMTPSecurity != SecurityTLS && a.SMTPSecurity != SecurityStartTLS && a.SMTPSecurity != SecurityNone {
+		return Account{}, errors.New("smtpSecurity must be tls, starttls or none")
+	}
+	if a.IMAPHost == "" || a.SMTPHost == "" {
+		return Account{}, errors.New("imapHost and smtpHost are required")
+	}
+	imapDefault := map[string]int{SecurityTLS: 993, SecurityNone: 143}[a.IMAPSecurity]
+	smtpDefault := map[string]int{SecurityTLS: 465, SecurityStartTLS: 587, SecurityNone: 25}[a.SMTPSecurity]
+	if a.IMAPPort, err = port(get("imapPort"), imapDefault); err != nil {
+		return Account{}, fmt.Errorf("imapPort: %v", err)
+	}
+	if a.SMTPPort, err = port(get("smtpPort"), smtpDefault); err != nil {
+		return Account{}, fmt.Errorf("smtpPort: %v", err)
+	}
+	return a, nil
+}
+
+func port(raw string, fallback int) (int, error) {
+	if raw == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 || n > 65535 {
+		return 0, errors.New("must be a port number")
+	}
+	return n, nil
+}
+
+// Dialer opens IMAP and SMTP connections. Unless AllowPrivate is set,
+// mail servers must resolve to public addresses, since hosts come from
+// channel config.
+type Dialer struct {
+	Timeout      time.Duration
+	AllowPrivate bool
+	// TLSConfig, when set, is cloned for each connection; tests use it
+	// to trust their own certificates.
+	TLSConfig *tls.Config
+}
+
+func (d Dialer) dial(ctx context.Context, host string, port int, implicitTLS bool) (net.Conn, error) {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	conn, err := netguard.Dialer(d.Timeout, d.AllowPrivate).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	// The timeout bounds the whole session, not each read.
+	deadline, ok := ctx.Deadline()
+	if limit := time.Now().Add(d.Timeout); d.Timeout > 0 && (!ok || limit.Before(deadline)) {
+		deadline, ok = limit, true
+	}
+	if ok {
+		conn.SetDeadline(deadline)
+	}
+	if !implicitTLS {
+		return conn, nil
+	}
+	tlsConn := tls.Client(conn, d.tlsConfig(host))
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+func (d Dialer) tlsConfig(host string) *tls.Config {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if d.TLSConfig != nil {
+		cfg = d.TLSConfig.Clone()
+	}
+	cfg.ServerName = host
+	return cfg
+}
+
+// plaintextAllowed reports whether credentials may be sent unencrypted to
+// host: only to the local machine, as net/smtp also insists.
+func plaintextAllowed(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,178 @@
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxReferences bounds the References header of a reply; the thread root
+// is always kept.
+const maxReferences = 20
+
+// Outgoing is a message to send. Leave MessageID empty to have one
+// generated.
+type Outgoing struct {
+	MessageID   string
+	From        string
+	To          []string
+	Subject     string
+	Text        string
+	InReplyTo   string
+	References  []string
+	Attachments []Attachment
+	Date        time.Time
+}
+
+// Build renders the message as RFC 5322 bytes and returns its Message-ID.
+func (o Outgoing) Build() (string, []byte, error) {
+	from, err := mail.ParseAddress(o.From)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid from address: %w", err)
+	}
+	if len(o.To) == 0 {
+		return "", nil, errors.New("no recipients")
+	}
+	to := make([]string, 0, len(o.To))
+	for _, addr := range o.To {
+		parsed, err := mail.ParseAddress(addr)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid recipient %q: %w", addr, err)
+		}
+		to = append(to, parsed.String())
+	}
+	id := o.MessageID
+	if id == "" {
+		id = uuid.NewString() + "@" + domainOf(from.Address)
+	}
+	date := o.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	var buf bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+	header("From", from.String())
+	header("To", strings.Join(to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", oneLine(o.Subject)))
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", "<"+id+">")
+	if o.InReplyTo != "" {
+		header("In-Reply-To", "<"+oneLine(o.InReplyTo)+">")
+	}
+	if refs := trimReferences(o.References); len(refs) > 0 {
+		for i, ref := range refs {
+			refs[i] = "<" + oneLine(ref) + ">"
+		}
+		header("References", strings.Join(refs, " "))
+	}
+	header("MIME-Version", "1.0")
+
+	if len(o.Attachments) == 0 {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, o.Text); err != nil {
+			return "", nil, err
+		}
+		return id, buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	header("Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": mw.Boundary()}))
+	buf.WriteString("\r\n")
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if err := writeQuotedPrintable(part, o.Text); err != nil {
+		return "", nil, err
+	}
+	for _, a := range o.Attachments {
+		mediaType := a.MIMEType
+		if _, _, err := mime.ParseMediaType(mediaType); err != nil {
+			mediaType = "application/octet-stream"
+		}
+		// Non-ASCII names are RFC 2231 encoded by FormatMediaType.
+		name := oneLine(a.Name)
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(mediaType, map[string]string{"name": name})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": name})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return "", nil, err
+		}
+		if err := writeBase64(part, a.Data); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return "", nil, err
+	}
+	return id, buf.Bytes(), nil
+}
+
+// ReplySubject prefixes subject with "Re: " unless it already has it.
+func ReplySubject(subject string) string {
+	if strings.HasPrefix(strings.ToLower(strings.TrimSpace(subject)), "re:") {
+		return subject
+	}
+	return "Re: " + subject
+}
+
+func trimReferences(refs []string) []string {
+	if len(refs) <= maxReferences {
+		return append([]string(nil), refs...)
+	}
+	return append([]string{refs[0]}, refs[len(refs)-maxReferences+1:]...)
+}
+
+func writeQuotedPrintable(w io.Writer, text string) error {
+	qp := quotedprintable.NewWriter(w)
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n")
+	if _, err := qp.Write([]byte(text)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func writeBase64(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		if _, err := fmt.Fprintf(w, "%s\r\n", encoded[:76]); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err := fmt.Fprintf(w, "%s\r\n", encoded)
+	return err
+}
+
+// oneLine keeps header values on one line, so they cannot inject headers.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(strings.NewReplacer("\r", " ", "\n", " ").Replace(s)), " ")
+}
+
+func domainOf(address string) string {
+	if i := strings.LastIndexByte(address, '@'); i >= 0 && i < len(address)-1 {
+		return address[i+1:]
+	}
+	return "localhost"
+}
@@ -0,0 +1,235 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxIMAPLine bounds one response line, literals excluded.
+const maxIMAPLine = 64 << 10
+
+var ErrTooLarge = errors.New("message too large")
+
+// IMAPSession is a logged-in IMAP connection with the account's mailbox
+// selected. It is not safe for concurrent use.
+type IMAPSession struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+	// maxLiteral bounds literals in responses; larger ones end the session.
+	maxLiteral int64
+}
+
+// imapLine is one response line with its literals cut out: text keeps
+// the {n} markers, literals holds the data in order.
+type imapLine struct {
+	text     string
+	literals [][]byte
+}
+
+var (
+	literalRE   = regexp.MustCompile(`\{(\d+)\}$`)
+	fetchUIDRE  = regexp.MustCompile(`\bUID (\d+)`)
+	fetchSizeRE = regexp.MustCompile(`\bRFC822\.SIZE (\d+)`)
+)
+
+// OpenIMAP logs in to the account's IMAP server and selects its mailbox.
+// Messages larger than maxBytes cannot be fetched.
+func (d Dialer) OpenIMAP(ctx context.Context, a Account, maxBytes int64) (*IMAPSession, error) {
+	if a.IMAPSecurity == SecurityNone && !plaintextAllowed(a.IMAPHost) {
+		return nil, ErrPlaintextAuth
+	}
+	conn, err := d.dial(ctx, a.IMAPHost, a.IMAPPort, a.IMAPSecurity == SecurityTLS)
+	if err != nil {
+		return nil, err
+	}
+	s := &IMAPSession{conn: conn, r: bufio.NewReader(conn), maxLiteral: maxBytes}
+	greeting, err := s.readLine()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting.text, "* OK") && !strings.HasPrefix(greeting.text, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf("imap: unexpected greeting %q", greeting.text)
+	}
+	if !strings.HasPrefix(greeting.text, "* PREAUTH") {
+		if _, err := s.command("LOGIN " + quote(a.Username) + " " + quote(a.Password)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if _, err := s.command("SELECT " + quote(a.Mailbox)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Unseen returns the UIDs of up to limit unread messages, oldest first.
+func (s *IMAPSession) Unseen(limit int) ([]uint32, error) {
+	lines, err := s.command("UID SEARCH UNSEEN")
+	if err != nil {
+		return nil, err
+	}
+	var uids []uint32
+	for _, l := range lines {
+		fields := strings.Fields(l.text)
+		if len(fields) < 2 || !strings.EqualFold(fields[1], "SEARCH") {
+			continue
+		}
+		for _, f := range fields[2:] {
+			if n, err := strconv.ParseUint(f, 10, 32); err == nil {
+				uids = append(uids, uint32(n))
+			}
+		}
+	}
+	slices.Sort(uids)
+	if limit > 0 && len(uids) > limit {
+		uids = uids[:limit]
+	}
+	return uids, nil
+}
+
+// Size returns a message's size in bytes.
+func (s *IMAPSession) Size(uid uint32) (int64, error) {
+	lines, err := s.command(fmt.Sprintf("UID FETCH %d (RFC822.SIZE)", uid))
+	if err != nil {
+		return 0, err
+	}
+	for _, l := range lines {
+		if m := fetchSizeRE.FindStringSubmatch(l.text); m != nil && fetchedUID(l) == uid {
+			return strconv.ParseInt(m[1], 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("imap: no size for message %d", uid)
+}
+
+// Fetch returns a raw message without marking it read.
+func (s *IMAPSession) Fetch(uid uint32) ([]byte, error) {
+	lines, err := s.command(fmt.Sprintf("UID FETCH %d (BODY.PEEK[])", uid))
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range lines {
+		if len(l.literals) > 0 && fetchedUID(l) == uid {
+			return l.literals[0], nil
+		}
+	}
+	return nil, fmt.Errorf("imap: message %d not returned", uid)
+}
+
+// MarkSeen flags a message as read.
+func (s *IMAPSession) MarkSeen(uid uint32) error {
+	_, err := s.command(fmt.Sprintf(`UID STORE %d +FLAGS.SILENT (\Seen)`, uid))
+	return err
+}
+
+// Close logs out and closes the connection.
+func (s *IMAPSession) Close() error {
+	s.command("LOGOUT")
+	return s.conn.Close()
+}
+
+func fetchedUID(l imapLine) uint32 {
+	if !strings.Contains(l.text, " FETCH ") {
+		return 0
+	}
+	m := fetchUIDRE.FindStringSubmatch(l.text)
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.ParseUint(m[1], 10, 32)
+	return uint32(n)
+}
+
+// command sends one tagged command and returns the untagged responses
+// that came before its completion. A NO or BAD completion is an error.
+func (s *IMAPSession) command(cmd string) ([]imapLine, error) {
+	s.tag++
+	tag := fmt.Sprintf("A%03d", s.tag)
+	if _, err := io.WriteString(s.conn, tag+" "+cmd+"\r\n"); err != nil {
+		return nil, err
+	}
+	var untagged []imapLine
+	for {
+		l, err := s.readLine()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case strings.HasPrefix(l.text, tag+" "):
+			status := strings.TrimPrefix(l.text, tag+" ")
+			if !strings.HasPrefix(strings.ToUpper(status), "OK") {
+				verb, _, _ := strings.Cut(cmd, " ")
+				return nil, fmt.Errorf("imap %s: %s", verb, status)
+			}
+			return untagged, nil
+		case strings.HasPrefix(l.text, "* "):
+			untagged = append(untagged, l)
+		default:
+			return nil, fmt.Errorf("imap: unexpected response %q", l.text)
+		}
+	}
+}
+
+// readLine reads one response line, following any literals it contains.
+func (s *IMAPSession) readLine() (imapLine, error) {
+	var l imapLine
+	var text strings.Builder
+	for {
+		part, err := s.readText()
+		if err != nil {
+			return imapLine{}, err
+		}
+		text.WriteString(part)
+		m := literalRE.FindStringSubmatch(part)
+		if m == nil {
+			break
+		}
+		n, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil || n > s.maxLiteral {
+			return imapLine{}, ErrTooLarge
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(s.r, data); err != nil {
+			return imapLine{}, err
+		}
+		l.literals = append(l.literals, data)
+	}
+	l.text = text.String()
+	return l, nil
+}
+
+// readText reads up to the next CRLF, which it strips.
+func (s *IMAPSession) readText() (string, error) {
+	var line []byte
+	for {
+		chunk, err := s.r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > maxIMAPLine {
+			return "", errors.New("imap: response line too long")
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(line), "\r\n"), nil
+	}
+}
+
+// quote renders s as an IMAP quoted string.
+func quote(s string) string {
+	s = strings.NewReplacer("\r", "", "\n", "", "\x00", "").Replace(s)
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,312 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const threadReply = "From: =?UTF-8?B?5byg5LiJ?= <Zhang@Example.com>\r\n" +
+	"To: support@example.org\r\n" +
+	"Subject: =?UTF-8?Q?Re:_Order_#42?=\r\n" +
+	"Message-ID: <reply-2@example.com>\r\n" +
+	"In-Reply-To: <ours-1@example.org>\r\n" +
+	"References: <root-0@example.com> <ours-1@example.org>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=outer\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/alternative; boundary=inner\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain; charset=gb2312\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"xOO6w6OsyczGt7W9wcs=\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/html; charset=utf-8\r\n" +
+	"\r\n" +
+	"<p>ignored</p>\r\n" +
+	"--inner--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/pdf\r\n" +
+	"Content-Disposition: attachment; filename*=utf-8''%E5%8F%91%E7%A5%A8.pdf\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"JVBERi0x\r\n" +
+	"--outer--\r\n"
+
+func TestParseThreadedMultipart(t *testing.T) {
+	m, err := Parse([]byte(threadReply))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.From != "zhang@example.com" || m.FromName != "张三" || m.Subject != "Re: Order #42" {
+		t.Errorf("headers = %q %q %q", m.From, m.FromName, m.Subject)
+	}
+	if m.MessageID != "reply-2@example.com" || m.InReplyTo != "ours-1@example.org" || m.ThreadID() != "root-0@example.com" {
+		t.Errorf("ids = %q %q %q", m.MessageID, m.InReplyTo, m.ThreadID())
+	}
+	if m.Text != "你好，商品到了" {
+		t.Errorf("text = %q", m.Text)
+	}
+	if len(m.Attachments) != 1 || m.Attachments[0].Name != "发票.pdf" || string(m.Attachments[0].Data) != "%PDF-1" {
+		t.Errorf("attachments = %+v", m.Attachments)
+	}
+}
+
+// imapStandIn serves a mailbox of raw messages keyed by UID over plain
+// IMAP, enough for OpenIMAP and its methods.
+func imapStandIn(t *testing.T, messages map[uint32]string) (int, *sync.Map) {
+	t.Helper()
+	seen := &sync.Map{}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	fetchRE := regexp.MustCompile(`^UID FETCH (\d+) \((.*)\)$`)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				fmt.Fprint(conn, "* OK ready\r\n")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					tag, cmd, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+					switch {
+					case cmd == `LOGIN "bot@example.org" "pw"`, strings.HasPrefix(cmd, "SELECT "):
+					case strings.HasPrefix(cmd, "LOGIN "):
+						fmt.Fprintf(conn, "%s NO [AUTHENTICATIONFAILED] bad login\r\n", tag)
+						continue
+					case cmd == "UID SEARCH UNSEEN":
+						var uids []string
+						for uid := range messages {
+							if _, ok := seen.Load(uid); !ok {
+								uids = append(uids, strconv.Itoa(int(uid)))
+							}
+						}
+						fmt.Fprintf(conn, "* SEARCH %s\r\n", strings.Join(uids, " "))
+					case fetchRE.MatchString(cmd):
+						m := fetchRE.FindStringSubmatch(cmd)
+						n, _ := strconv.Atoi(m[1])
+						raw := messages[uint32(n)]
+						// An unsolicited flag update comes first.
+						fmt.Fprint(conn, "* 9 FETCH (FLAGS (\\Seen))\r\n")
+						if m[2] == "RFC822.SIZE" {
+							fmt.Fprintf(conn, "* 1 FETCH (UID %d RFC822.SIZE %d)\r\n", n, len(raw))
+						} else {
+							fmt.Fprintf(conn, "* 1 FETCH (UID %d BODY[] {%d}\r\n%s)\r\n", n, len(raw), raw)
+						}
+					case strings.HasPrefix(cmd, "UID STORE "):
+						n, _ := strconv.Atoi(strings.Fields(cmd)[2])
+						seen.Store(uint32(n), true)
+					case cmd == "LOGOUT":
+						fmt.Fprintf(conn, "* BYE\r\n%s OK bye\r\n", tag)
+						return
+					default:
+						fmt.Fprintf(conn, "%s BAD unknown\r\n", tag)
+						continue
+					}
+					fmt.Fprintf(conn, "%s OK done\r\n", tag)
+				}
+			}()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, seen
+}
+
+// smtpStandIn accepts one message per connection and sends what it
+// received on the returned channel.
+func smtpStandIn(t *testing.T) (int, <-chan string) {
+	t.Helper()
+	got := make(chan string, 4)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				fmt.Fprint(conn, "220 stand-in\r\n")
+				var envelope []string
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					cmd := strings.TrimRight(line, "\r\n")
+					switch verb := strings.ToUpper(strings.Fields(cmd + " x")[0]); verb {
+					case "EHLO":
+						fmt.Fprint(conn, "250-stand-in\r\n250 AUTH PLAIN\r\n")
+					case "AUTH":
+						fmt.Fprint(conn, "235 ok\r\n")
+					case "MAIL", "RCPT":
+						envelope = append(envelope, cmd)
+						fmt.Fprint(conn, "250 ok\r\n")
+					case "DATA":
+						fmt.Fprint(conn, "354 go\r\n")
+						var data strings.Builder
+						for {
+							l, err := r.ReadString('\n')
+							if err != nil || l == ".\r\n" {
+								break
+							}
+							data.WriteString(strings.TrimPrefix(l, "."))
+						}
+						got <- strings.Join(envelope, "\n") + "\n\n" + data.String()
+						fmt.Fprint(conn, "250 queued\r\n")
+					case "QUIT":
+						fmt.Fprint(conn, "221 bye\r\n")
+						return
+					default:
+						fmt.Fprint(conn, "502 no\r\n")
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, got
+}
+
+func testAccount(t *testing.T, imapPort, smtpPort int) Account {
+	t.Helper()
+	a, err := AccountFromConfig(map[string]string{
+		"address": "bot@example.org", "password": "pw",
+		"imapHost": "127.0.0.1", "imapSecurity": "none",
+		"smtpHost": "127.0.0.1", "smtpSecurity": "none",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.IMAPPort, a.SMTPPort = imapPort, smtpPort
+	return a
+}
+
+var testDialer = Dialer{Timeout: 5 * time.Second, AllowPrivate: true}
+
+func TestIMAPFetchesUnseenAndMarksThem(t *testing.T) {
+	port, seen := imapStandIn(t, map[uint32]string{7: threadReply, 3: "From: a@b.c\r\n\r\nhi\r\n"})
+	a := testAccount(t, port, 0)
+	ctx := context.Background()
+
+	s, err := testDialer.OpenIMAP(ctx, a, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uids, err := s.Unseen(10)
+	if err != nil || len(uids) != 2 || uids[0] != 3 || uids[1] != 7 {
+		t.Fatalf("unseen = %v, %v", uids, err)
+	}
+	if size, err := s.Size(7); err != nil || size != int64(len(threadReply)) {
+		t.Errorf("size = %d, %v", size, err)
+	}
+	raw, err := s.Fetch(7)
+	if err != nil || string(raw) != threadReply {
+		t.Fatalf("fetch = %q, %v", raw, err)
+	}
+	if err := s.MarkSeen(7); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if _, ok := seen.Load(uint32(7)); !ok {
+		t.Error("message 7 not marked seen")
+	}
+
+	// Oversized literals end the session instead of being buffered.
+	s, _ = testDialer.OpenIMAP(ctx, a, 10)
+	if _, err := s.Fetch(3); err != ErrTooLarge {
+		t.Errorf("oversized fetch = %v", err)
+	}
+	s.Close()
+
+	a.Password = "wrong"
+	if _, err := testDialer.OpenIMAP(ctx, a, 1<<20); err == nil || !strings.Contains(err.Error(), "AUTHENTICATIONFAILED") {
+		t.Errorf("bad login = %v", err)
+	}
+}
+
+func TestReplySentInThread(t *testing.T) {
+	port, got := smtpStandIn(t)
+	a := testAccount(t, 0, port)
+	threads, err := OpenThreads(filepath.Join(t.TempDir(), "threads.json"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, _ := Parse([]byte(threadReply))
+	threads.Record("ch-1", in.ThreadID(), in.Subject, in.MessageID, in.References)
+
+	th, ok := threads.Get("ch-1", "root-0@example.com")
+	if !ok {
+		t.Fatal("thread not recorded")
+	}
+	// Message IDs come from senders; another channel has its own threads.
+	if _, ok := threads.Get("ch-2", "root-0@example.com"); ok {
+		t.Error("thread visible from another channel")
+	}
+	out := Outgoing{To: []string{in.From}, Text: "已收到，谢谢！\n-- bot", Attachments: []Attachment{
+		{Name: "回执.txt", MIMEType: "text/plain", Data: []byte("ok")},
+	}}
+	th.Reply(&out)
+	id, err := testDialer.Send(context.Background(), a, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := <-got
+	envelope, raw, _ := strings.Cut(sent, "\n\n")
+	if envelope != "MAIL FROM:<bot@example.org>\nRCPT TO:<zhang@example.com>" {
+		t.Errorf("envelope = %q", envelope)
+	}
+	m, err := Parse([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.MessageID != id || m.Subject != "Re: Order #42" || m.InReplyTo != "reply-2@example.com" {
+		t.Errorf("reply headers = %q %q %q", m.MessageID, m.Subject, m.InReplyTo)
+	}
+	if strings.Join(m.References, " ") != "root-0@example.com ours-1@example.org reply-2@example.com" {
+		t.Errorf("references = %v", m.References)
+	}
+	if m.Text != "已收到，谢谢！\n-- bot" || len(m.Attachments) != 1 || m.Attachments[0].Name != "回执.txt" {
+		t.Errorf("reply body = %q, %+v", m.Text, m.Attachments)
+	}
+
+	// The reply joins the thread; it survives a reopen.
+	threads.Record("ch-1", th.References[0], out.Subject, id, nil)
+	if err := threads.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	reopened, _ := OpenThreads(filepath.Join(filepath.Dir(threads.path), "threads.json"), 10)
+	if th, _ := reopened.Get("ch-1", "root-0@example.com"); th.LastMessageID != id || len(th.References) != 4 {
+		t.Errorf("reopened thread = %+v", th)
+	}
+
+	a.SMTPHost = "mail.example.org"
+	if _, err := testDialer.Send(context.Background(), a, out); err != ErrPlaintextAuth {
+		t.Errorf("plaintext to remote host = %v", err)
+	}
+}
@@ -0,0 +1,301 @@
+// Package mail speaks just enough IMAP, SMTP and MIME for email channels:
+// fetching unread mail from a mailbox, parsing it into text, threading
+// headers and attachments, and sending replies that land in the same
+// thread in the recipient's mail client.
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// maxParts bounds how many MIME parts one message may have.
+const maxParts = 100
+
+var ErrMalformed = errors.New("malformed message")
+
+// Attachment is a file carried by a message.
+type Attachment struct {
+	Name     string
+	MIMEType string
+	Data     []byte
+}
+
+// Message is a parsed email. Message IDs are kept without angle brackets.
+type Message struct {
+	MessageID   string
+	InReplyTo   string
+	References  []string
+	From        string
+	FromName    string
+	To          []string
+	Subject     string
+	Date        time.Time
+	Text        string
+	Attachments []Attachment
+	// Automated is set for auto-replies, bounces and bulk mail, which must
+	// not be answered.
+	Automated bool
+}
+
+// ThreadID identifies the conversation a message belongs to: the first
+// message of the thread, as named by References or In-Reply-To, or the
+// message itself when it starts a thread.
+func (m Message) ThreadID() string {
+	if len(m.References) > 0 {
+		return m.References[0]
+	}
+	if m.InReplyTo != "" {
+		return m.InReplyTo
+	}
+	return m.MessageID
+}
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+// Parse parses a raw RFC 5322 message. The body text is the first
+// text/plain part, or the first text/html part with its tags stripped;
+// parts with a filename are attachments.
+func Parse(raw []byte) (Message, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return Message{}, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	h := msg.Header
+	m := Message{
+		MessageID:  firstID(h.Get("Message-Id")),
+		InReplyTo:  firstID(h.Get("In-Reply-To")),
+		References: messageIDs(h.Get("References")),
+		Subject:    decodeHeader(h.Get("Subject")),
+		Automated:  automated(h),
+	}
+	if from, err := wordDecoderParser.Parse(h.Get("From")); err == nil {
+		m.From, m.FromName = strings.ToLower(from.Address), from.Name
+	} else {
+		return Message{}, fmt.Errorf("%w: bad From header: %v", ErrMalformed, err)
+	}
+	if to, err := wordDecoderParser.ParseList(h.Get("To")); err == nil {
+		for _, addr := range to {
+			m.To = append(m.To, strings.ToLower(addr.Address))
+		}
+	}
+	if date, err := h.Date(); err == nil {
+		m.Date = date.UTC()
+	}
+
+	var htmlText string
+	parts := 0
+	err = walkPart(h, msg.Body, &parts, func(mediaType, name string, body []byte, params map[string]string) {
+		switch {
+		case name != "":
+			m.Attachments = append(m.Attachments, Attachment{Name: name, MIMEType: mediaType, Data: body})
+		case mediaType == "text/plain" && m.Text == "":
+			m.Text = decodeCharset(body, params["charset"])
+		case mediaType == "text/html" && htmlText == "":
+			htmlText = stripHTML(decodeCharset(body, params["charset"]))
+		}
+	})
+	if err != nil {
+		return Message{}, err
+	}
+	if m.Text == "" {
+		m.Text = htmlText
+	}
+	m.Text = strings.TrimSpace(strings.ReplaceAll(m.Text, "\r\n", "\n"))
+	return m, nil
+}
+
+var wordDecoderParser = &mail.AddressParser{WordDecoder: wordDecoder}
+
+// automated reports whether a message was sent by a machine (RFC 3834),
+// is a bounce, or is list or bulk mail.
+func automated(h mail.Header) bool {
+	if v := strings.ToLower(strings.TrimSpace(h.Get("Auto-Submitted"))); v != "" && v != "no" {
+		return true
+	}
+	switch strings.ToLower(strings.TrimSpace(h.Get("Precedence"))) {
+	case "bulk", "junk", "list", "auto_reply":
+		return true
+	}
+	// Delivery status notifications (bounces) are multipart/report.
+	if mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type")); mediaType == "multipart/report" {
+		return true
+	}
+	return h.Get("X-Autoreply") != "" || h.Get("X-Autorespond") != "" || h.Get("List-Id") != ""
+}
+
+// partHeader is a message or MIME part header.
+type partHeader interface {
+	Get(key string) string
+}
+
+// walkPart calls visit for every leaf part below a part with header h and
+// body r, with its transfer encoding removed.
+func walkPart(h partHeader, r io.Reader, parts *int, visit func(mediaType, name string, body []byte, params map[string]string)) error {
+	*parts++
+	if *parts > maxParts {
+		return fmt.Errorf("%w: more than %d parts", ErrMalformed, maxParts)
+	}
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(r, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrMalformed, err)
+			}
+			if err := walkPart(part.Header, part, parts, visit); err != nil {
+				return err
+			}
+		}
+	}
+	body, err := io.ReadAll(decodeTransfer(h.Get("Content-Transfer-Encoding"), r))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	visit(mediaType, attachmentName(h, params), body, params)
+	return nil
+}
+
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &base64Cleaner{r: r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	default:
+		return r
+	}
+}
+
+// base64Cleaner drops the line breaks and whitespace base64 bodies are
+// wrapped with.
+type base64Cleaner struct{ r io.Reader }
+
+func (c *base64Cleaner) Read(p []byte) (int, error) {
+	for {
+		n, err := c.r.Read(p)
+		kept := 0
+		for _, b := range p[:n] {
+			if b != '\r' && b != '\n' && b != ' ' && b != '\t' {
+				p[kept] = b
+				kept++
+			}
+		}
+		if kept > 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+// attachmentName is the part's filename, from Content-Disposition or the
+// older Content-Type name parameter. Inline text parts without one are
+// body text.
+func attachmentName(h partHeader, params map[string]string) string {
+	name := ""
+	disposition, dparams, err := mime.ParseMediaType(h.Get("Content-Disposition"))
+	if err == nil {
+		name = dparams["filename"]
+	}
+	if name == "" {
+		name = params["name"]
+	}
+	name = decodeHeader(name)
+	if name == "" && disposition == "attachment" {
+		name = "attachment"
+	}
+	// Keep the base name only; senders control it.
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.TrimSpace(name)
+}
+
+func decodeHeader(value string) string {
+	decoded, err := wordDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, err
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
+// decodeCharset converts body from charset to UTF-8. Unknown charsets are
+// passed through.
+func decodeCharset(body []byte, charset string) string {
+	charset = strings.ToLower(strings.TrimSpace(charset))
+	if charset == "" || charset == "utf-8" || charset == "us-ascii" {
+		return string(body)
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return string(body)
+	}
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return string(body)
+	}
+	return string(decoded)
+}
+
+var (
+	htmlDropRE  = regexp.MustCompile(`(?is)<(script|style|head)\b.*?</(script|style|head)>`)
+	htmlBreakRE = regexp.MustCompile(`(?i)<(br|/p|/div|/li|/tr|/h[1-6])\b[^>]*>`)
+	htmlTagRE   = regexp.MustCompile(`<[^>]*>`)
+	blankRunRE  = regexp.MustCompile(`\n{3,}`)
+)
+
+func stripHTML(s string) string {
+	s = htmlDropRE.ReplaceAllString(s, "")
+	s = htmlBreakRE.ReplaceAllString(s, "\n")
+	s = html.UnescapeString(htmlTagRE.ReplaceAllString(s, ""))
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return blankRunRE.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+}
+
+var messageIDRE = regexp.MustCompile(`<([^<>\s]+)>`)
+
+// messageIDs extracts the IDs from a References-style header.
+func messageIDs(value string) []string {
+	var ids []string
+	for _, m := range messageIDRE.FindAllStringSubmatch(value, -1) {
+		ids = append(ids, m[1])
+	}
+	return ids
+}
+
+func firstID(value string) string {
+	if ids := messageIDs(value); len(ids) > 0 {
+		return ids[0]
+	}
+	return strings.Trim(strings.TrimSpace(value), "<>")
+}
@@ -0,0 +1,87 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"net/smtp"
+)
+
+var ErrPlaintextAuth = errors.New("refusing to send credentials without TLS to a non-local server")
+
+// Send delivers msg from the account's address through its SMTP server and
+// returns the Message-ID it was sent with.
+func (d Dialer) Send(ctx context.Context, a Account, msg Outgoing) (string, error) {
+	msg.From = a.Address
+	id, raw, err := msg.Build()
+	if err != nil {
+		return "", err
+	}
+	c, err := d.smtpClient(ctx, a)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+	if err := c.Mail(a.Address); err != nil {
+		return "", err
+	}
+	for _, rcpt := range msg.To {
+		if err := c.Rcpt(rcpt); err != nil {
+			return "", err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(raw); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return id, c.Quit()
+}
+
+// CheckSMTP connects and authenticates without sending anything.
+func (d Dialer) CheckSMTP(ctx context.Context, a Account) error {
+	c, err := d.smtpClient(ctx, a)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return c.Quit()
+}
+
+// smtpClient returns a client that has said hello, secured the connection
+// and logged in.
+func (d Dialer) smtpClient(ctx context.Context, a Account) (*smtp.Client, error) {
+	if a.SMTPSecurity == SecurityNone && a.Password != "" && !plaintextAllowed(a.SMTPHost) {
+		return nil, ErrPlaintextAuth
+	}
+	conn, err := d.dial(ctx, a.SMTPHost, a.SMTPPort, a.SMTPSecurity == SecurityTLS)
+	if err != nil {
+		return nil, err
+	}
+	c, err := smtp.NewClient(conn, a.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := c.Hello(domainOf(a.Address)); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if a.SMTPSecurity == SecurityStartTLS {
+		if err := c.StartTLS(d.tlsConfig(a.SMTPHost)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if a.Password != "" {
+		if err := c.Auth(smtp.PlainAuth("", a.Username, a.Password, a.SMTPHost)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,186 @@
+package mail
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Thread is what a reply needs to land in a conversation: its subject and
+// the message IDs to reference.
+type Thread struct {
+	Subject       string    `json:"subject"`
+	References    []string  `json:"references"`
+	LastMessageID string    `json:"lastMessageId"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// threadsSaveDelay is how long a change waits before the store is written,
+// so a burst of mail rewrites the file once.
+const threadsSaveDelay = time.Second
+
+// Threads remembers recent threads by channel and thread ID, the message ID
+// of their first message. Message IDs come from senders, so the same ID in
+// another channel is another thread. Changes are saved to a JSON file
+// shortly after they happen and on Close; the least recently updated
+// threads are forgotten beyond the limit.
+type Threads struct {
+	path  string
+	limit int
+	delay time.Duration
+
+	// saveMu serializes writes of the file; it is taken before mu.
+	saveMu  sync.Mutex
+	mu      sync.Mutex
+	threads map[string]*Thread
+	dirty   bool
+	timer   *time.Timer
+}
+
+// OpenThreads loads the store at path, creating its directory.
+func OpenThreads(path string, limit int) (*Threads, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	t := &Threads{path: path, limit: limit, delay: threadsSaveDelay, threads: map[string]*Thread{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &t.threads); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// threadKey is the store key of a channel's thread. Channel IDs hold no
+// slash, so the key splits unambiguously.
+func threadKey(channelID, threadID string) string {
+	return channelID + "/" + threadID
+}
+
+// Get returns the channel's thread with id.
+func (t *Threads) Get(channelID, id string) (Thread, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	th, ok := t.threads[threadKey(channelID, id)]
+	if !ok {
+		return Thread{}, false
+	}
+	return *th, true
+}
+
+// Reply sets o's subject and threading headers to answer the thread's
+// latest message.
+func (th Thread) Reply(o *Outgoing) {
+	o.Subject = ReplySubject(th.Subject)
+	o.InReplyTo = th.LastMessageID
+	o.References = append([]string(nil), th.References...)
+}
+
+// Record adds a message, received or sent, to its thread in the channel.
+// refs are the message's own References, used when the thread is new to the
+// store.
+func (t *Threads) Record(channelID, threadID, subject, messageID string, refs []string) {
+	if channelID == "" || threadID == "" || messageID == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := threadKey(channelID, threadID)
+	th, ok := t.threads[key]
+	if !ok {
+		th = &Thread{Subject: subject, References: trimReferences(refs)}
+		if len(th.References) == 0 {
+			th.References = []string{threadID}
+		}
+		t.threads[key] = th
+	}
+	if th.Subject == "" {
+		th.Subject = subject
+	}
+	if !slices.Contains(th.References, messageID) {
+		th.References = trimReferences(append(th.References, messageID))
+	}
+	th.LastMessageID = messageID
+	th.UpdatedAt = time.Now().UTC()
+	t.evict()
+	t.dirty = true
+	if t.timer == nil {
+		t.timer = time.AfterFunc(t.delay, func() {
+			if err := t.flush(); err != nil {
+				slog.Warn("mail: save threads failed", slog.Any("err", err))
+			}
+		})
+	}
+}
+
+// Close writes any pending change.
+func (t *Threads) Close() error {
+	t.mu.Lock()
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	t.mu.Unlock()
+	return t.flush()
+}
+
+func (t *Threads) evict() {
+	if t.limit <= 0 || len(t.threads) <= t.limit {
+		return
+	}
+	ids := make([]string, 0, len(t.threads))
+	for id := range t.threads {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return t.threads[ids[i]].UpdatedAt.Before(t.threads[ids[j]].UpdatedAt) })
+	for _, id := range ids[:len(ids)-t.limit] {
+		delete(t.threads, id)
+	}
+}
+
+// flush writes the store if it changed since the last write.
+func (t *Threads) flush() error {
+	t.saveMu.Lock()
+	defer t.saveMu.Unlock()
+	t.mu.Lock()
+	t.timer = nil
+	if !t.dirty {
+		t.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(t.threads)
+	if err == nil {
+		t.dirty = false
+	}
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		t.markDirty()
+		return err
+	}
+	if err := os.Rename(tmp, t.path); err != nil {
+		t.markDirty()
+		return err
+	}
+	return nil
+}
+
+// markDirty keeps a change whose write failed for the next flush.
+func (t *Threads) markDirty() {
+	t.mu.Lock()
+	t.dirty = true
+	t.mu.Unlock()
+}
@@ -3,11 +3,14 @@ package middleware
 import (
 	"context"
 	"crypto/subtle"
+	"log/slog"
 	"net/http"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/logging"
 )
 
 // secureCompare performs a constant-time comparison of two strings
@@ -63,6 +66,7 @@ func Auth(jwtSecret string) func(http.Handler) http.Handler {
 				http.Error(w, `{"error":"invalid token claims"}`, http.StatusUnauthorized)
 				return
 			}
+			logging.AddAttrs(r.Context(), slog.String("user_id", user.UserID))
 			ctx := context.WithValue(r.Context(), UserContextKey, user)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
@@ -94,6 +98,7 @@ func AuthOrRuntimeSecret(jwtSecret, runtimeSecret string) func(http.Handler) htt
 			// Try X-Runtime-Secret first (fast path for internal calls)
 			if secret := r.Header.Get("X-Runtime-Secret"); secret != "" {
 				if secureCompare(secret, runtimeSecret) {
+					logging.AddAttrs(r.Context(), slog.String("caller", "runtime"))
 					next.ServeHTTP(w, r)
 					return
 				}
@@ -121,6 +126,7 @@ func AuthOrRuntimeSecret(jwtSecret, runtimeSecret string) func(http.Handler) htt
 				http.Error(w, `{"error":"invalid token claims"}`, http.StatusUnauthorized)
 				return
 			}
+			logging.AddAttrs(r.Context(), slog.String("user_id", user.UserID))
 			ctx := context.WithValue(r.Context(), UserContextKey, user)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
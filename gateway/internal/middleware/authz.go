@@ -0,0 +1,388 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	commonpb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/common"
+	orgpb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/org"
+)
+
+// Org member roles, ordered from least to most privileged.
+const (
+	RoleMember = "member"
+	RoleAdmin  = "admin"
+	RoleOwner  = "owner"
+)
+
+const (
+	scopeKey        contextKey = "scope"
+	scopeTrackerKey contextKey = "scopeTracker"
+)
+
+func roleRank(role string) int {
+	switch strings.ToLower(strings.TrimSpace(role)) {
+	case RoleOwner:
+		return 3
+	case RoleAdmin:
+		return 2
+	case RoleMember:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Membership is a snapshot of the orgs and workspaces a user belongs to.
+type Membership struct {
+	UserID     string
+	OrgRoles   map[string]string // orgID → role
+	OrgSlugs   map[string]string // org slug → orgID
+	Workspaces map[string]string // workspaceID → orgID
+	fetchedAt  time.Time
+}
+
+// OrgID resolves an org reference, which routes accept as either the org's
+// ID or its slug, to the org ID.
+func (m *Membership) OrgID(ref string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	if _, ok := m.OrgRoles[ref]; ok {
+		return ref, true
+	}
+	orgID, ok := m.OrgSlugs[ref]
+	return orgID, ok
+}
+
+// OrgRole returns the caller's role in the org with the given ID or slug.
+func (m *Membership) OrgRole(ref string) (string, bool) {
+	orgID, ok := m.OrgID(ref)
+	if !ok {
+		return "", false
+	}
+	role, ok := m.OrgRoles[orgID]
+	return role, ok
+}
+
+// WorkspaceRole returns the caller's role in the org that owns workspaceID.
+func (m *Membership) WorkspaceRole(workspaceID string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	orgID, ok := m.Workspaces[workspaceID]
+	if !ok {
+		return "", false
+	}
+	return m.OrgRole(orgID)
+}
+
+// Scope is the org (and workspace, if any) a request was authorized against.
+type Scope struct {
+	OrgID       string
+	WorkspaceID string
+	Role        string
+}
+
+// Errors a ResourceResolver returns for resources it cannot place.
+var (
+	// ErrResourceNotFound lets the request through, so the handler answers
+	// with its own 404.
+	ErrResourceNotFound = errors.New("resource not found")
+	// ErrResourceForbidden rejects the request with 403.
+	ErrResourceForbidden = errors.New("resource forbidden")
+)
+
+// ResourceResolver returns the workspace that owns the resource named by the
+// route parameter param (e.g. "sessionId") with value id.
+type ResourceResolver func(ctx context.Context, user UserClaims, param, id string) (string, error)
+
+type resourceEntry struct {
+	workspaceID string
+	fetchedAt   time.Time
+}
+
+// Authorizer checks org and workspace membership at the edge, before a
+// request is forwarded to gRPC. Memberships are resolved through the Org
+// service and cached per user for a short TTL. Routes naming a resource
+// rather than a workspace ({sessionId}, {channelId}, ...) are placed in
+// their workspace through a ResourceResolver, cached per resource.
+type Authorizer struct {
+	org        orgpb.OrgServiceClient
+	ttl        time.Duration
+	minRefresh time.Duration
+	now        func() time.Time
+
+	resolve        ResourceResolver
+	resourceParams []string
+
+	mu        sync.Mutex
+	cache     map[string]*Membership
+	resources map[string]resourceEntry // param + "\x00" + id → owner
+	lastPrune time.Time
+}
+
+func NewAuthorizer(org orgpb.OrgServiceClient, ttl time.Duration) *Authorizer {
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	return &Authorizer{
+		org:        org,
+		ttl:        ttl,
+		minRefresh: 2 * time.Second,
+		now:        time.Now,
+		cache:      make(map[string]*Membership),
+		resources:  make(map[string]resourceEntry),
+	}
+}
+
+// ResolveResources has the Authorizer check the route parameters params, in
+// order, through resolve. It must be called before the Authorizer serves
+// requests.
+func (a *Authorizer) ResolveResources(resolve ResourceResolver, params ...string) {
+	a.resolve = resolve
+	a.resourceParams = params
+}
+
+// ResourceWorkspace returns the workspace owning the first resource named in
+// params, or "" when params name none. Results are cached for the TTL.
+func (a *Authorizer) ResourceWorkspace(ctx context.Context, user UserClaims, params func(string) string) (string, error) {
+	if a.resolve == nil {
+		return "", nil
+	}
+	for _, param := range a.resourceParams {
+		id := params(param)
+		if id == "" {
+			continue
+		}
+		key := param + "\x00" + id
+		a.mu.Lock()
+		entry, ok := a.resources[key]
+		a.mu.Unlock()
+		if ok && a.now().Sub(entry.fetchedAt) <= a.ttl {
+			return entry.workspaceID, nil
+		}
+		wsID, err := a.resolve(ctx, user, param, id)
+		if err != nil {
+			return "", err
+		}
+		a.mu.Lock()
+		a.resources[key] = resourceEntry{workspaceID: wsID, fetchedAt: a.now()}
+		a.pruneLocked()
+		a.mu.Unlock()
+		return wsID, nil
+	}
+	return "", nil
+}
+
+// Invalidate drops the cached membership for userID.
+func (a *Authorizer) Invalidate(userID string) {
+	a.mu.Lock()
+	delete(a.cache, userID)
+	a.mu.Unlock()
+}
+
+func (a *Authorizer) cached(userID string) *Membership {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	m := a.cache[userID]
+	if m == nil || a.now().Sub(m.fetchedAt) > a.ttl {
+		return nil
+	}
+	return m
+}
+
+// Resolve returns the caller's memberships, from cache when fresh.
+func (a *Authorizer) Resolve(ctx context.Context, user UserClaims) (*Membership, error) {
+	if m := a.cached(user.UserID); m != nil {
+		return m, nil
+	}
+	return a.fetch(ctx, user)
+}
+
+func (a *Authorizer) fetch(ctx context.Context, user UserClaims) (*Membership, error) {
+	uc := &commonpb.UserContext{UserId: user.UserID, Email: user.Email, Name: user.Name}
+	resp, err := a.org.ListMemberships(ctx, &orgpb.ListOrgsRequest{UserContext: uc})
+	if err != nil {
+		return nil, err
+	}
+	m := &Membership{
+		UserID:     user.UserID,
+		OrgRoles:   make(map[string]string, len(resp.GetMemberships())),
+		OrgSlugs:   make(map[string]string, len(resp.GetMemberships())),
+		Workspaces: make(map[string]string),
+		fetchedAt:  a.now(),
+	}
+	for _, ms := range resp.GetMemberships() {
+		role := strings.ToLower(strings.TrimSpace(ms.GetRole()))
+		if role == "" {
+			continue
+		}
+		m.OrgRoles[ms.GetOrgId()] = role
+		if slug := ms.GetSlug(); slug != "" {
+			m.OrgSlugs[slug] = ms.GetOrgId()
+		}
+		for _, wsID := range ms.GetWorkspaceIds() {
+			m.Workspaces[wsID] = ms.GetOrgId()
+		}
+	}
+
+	a.mu.Lock()
+	a.cache[user.UserID] = m
+	a.pruneLocked()
+	a.mu.Unlock()
+	return m, nil
+}
+
+// pruneLocked drops expired memberships and resources, at most once per TTL,
+// so the caches hold only users and resources seen recently. a.mu must be
+// held.
+func (a *Authorizer) pruneLocked() {
+	now := a.now()
+	if now.Sub(a.lastPrune) < a.ttl {
+		return
+	}
+	a.lastPrune = now
+	for userID, m := range a.cache {
+		if now.Sub(m.fetchedAt) > a.ttl {
+			delete(a.cache, userID)
+		}
+	}
+	for key, entry := range a.resources {
+		if now.Sub(entry.fetchedAt) > a.ttl {
+			delete(a.resources, key)
+		}
+	}
+}
+
+// scopeFor resolves the caller's scope for the {orgId} or {wsId} in the route.
+// An {orgId} given as a slug is resolved to the org ID in the returned scope.
+// A miss against a cached snapshot triggers one refresh so that freshly
+// created workspaces are visible, but no more often than minRefresh per user.
+func (a *Authorizer) scopeFor(ctx context.Context, user UserClaims, orgID, wsID string) (Scope, bool, error) {
+	lookup := func(m *Membership) (Scope, bool) {
+		scope := Scope{WorkspaceID: wsID}
+		if wsID != "" {
+			scope.OrgID = m.Workspaces[wsID]
+		} else if id, ok := m.OrgID(orgID); ok {
+			scope.OrgID = id
+		}
+		role, ok := m.OrgRoles[scope.OrgID]
+		scope.Role = role
+		return scope, ok
+	}
+	m, err := a.Resolve(ctx, user)
+	if err != nil {
+		return Scope{}, false, err
+	}
+	if scope, ok := lookup(m); ok {
+		return scope, true, nil
+	}
+	if a.now().Sub(m.fetchedAt) < a.minRefresh {
+		return Scope{}, false, nil
+	}
+	if m, err = a.fetch(ctx, user); err != nil {
+		return Scope{}, false, err
+	}
+	scope, ok := lookup(m)
+	return scope, ok, nil
+}
+
+func scopeParams(r *http.Request) (orgID, wsID string) {
+	return strings.TrimSpace(chi.URLParam(r, "orgId")), strings.TrimSpace(chi.URLParam(r, "wsId"))
+}
+
+func (a *Authorizer) authorize(w http.ResponseWriter, r *http.Request, minRole string) (*http.Request, bool) {
+	user, ok := GetUser(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return r, false
+	}
+	orgID, wsID := scopeParams(r)
+	resourceWS, err := a.ResourceWorkspace(r.Context(), user, func(param string) string {
+		return strings.TrimSpace(chi.URLParam(r, param))
+	})
+	switch {
+	case errors.Is(err, ErrResourceNotFound):
+	case errors.Is(err, ErrResourceForbidden):
+		http.Error(w, `{"error":"forbidden: not a member"}`, http.StatusForbidden)
+		return r, false
+	case err != nil:
+		http.Error(w, `{"error":"authorization unavailable"}`, http.StatusServiceUnavailable)
+		return r, false
+	case resourceWS != "" && wsID != "" && resourceWS != wsID:
+		// e.g. a scheduler task looked up under another workspace.
+		http.Error(w, `{"error":"forbidden: resource belongs to another workspace"}`, http.StatusForbidden)
+		return r, false
+	case resourceWS != "":
+		wsID = resourceWS
+	}
+	if orgID == "" && wsID == "" {
+		return r, true
+	}
+	scope, member, err := a.scopeFor(r.Context(), user, orgID, wsID)
+	if err != nil {
+		http.Error(w, `{"error":"authorization unavailable"}`, http.StatusServiceUnavailable)
+		return r, false
+	}
+	if !member {
+		http.Error(w, `{"error":"forbidden: not a member"}`, http.StatusForbidden)
+		return r, false
+	}
+	if roleRank(scope.Role) < roleRank(minRole) {
+		http.Error(w, `{"error":"forbidden: insufficient role"}`, http.StatusForbidden)
+		return r, false
+	}
+	if tracked, ok := r.Context().Value(scopeTrackerKey).(*Scope); ok {
+		*tracked = scope
+	}
+	ctx := context.WithValue(r.Context(), scopeKey, scope)
+	return r.WithContext(ctx), true
+}
+
+// Middleware rejects callers who are not members of the {orgId} or {wsId}
+// named in the route, or of the workspace owning a resource it names.
+// Routes without any of these pass through. It must be installed on a chi
+// Group (or via With) so URL params are resolved.
+func (a *Authorizer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, ok := a.authorize(w, r, RoleMember)
+		if !ok {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Require enforces a minimum org role for the route's {orgId} or {wsId}.
+func (a *Authorizer) Require(minRole string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r, ok := a.authorize(w, r, minRole)
+			if !ok {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetScope returns the org/workspace scope resolved by the Authorizer.
+func GetScope(r *http.Request) (Scope, bool) {
+	scope, ok := r.Context().Value(scopeKey).(Scope)
+	return scope, ok
+}
+
+// TrackScope returns a context under which the Authorizer also records the
+// resolved scope into the returned Scope. This lets middleware that wraps the
+// Authorizer (e.g. audit logging) see the result after the handler returns.
+func TrackScope(ctx context.Context) (context.Context, *Scope) {
+	scope := &Scope{}
+	return context.WithValue(ctx, scopeTrackerKey, scope), scope
+}
@@ -0,0 +1,247 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc"
+
+	orgpb "github.com/liukai/next-ai-agent-user-backend/gateway/internal/pb/org"
+)
+
+type fakeOrgClient struct {
+	orgpb.OrgServiceClient
+	roles      map[string]string   // orgID → role of the test user
+	workspaces map[string][]string // orgID → workspace IDs
+	slugs      map[string]string   // orgID → slug
+	listCalls  int
+}
+
+func (f *fakeOrgClient) ListMemberships(ctx context.Context, in *orgpb.ListOrgsRequest, opts ...grpc.CallOption) (*orgpb.ListMembershipsResponse, error) {
+	f.listCalls++
+	out := &orgpb.ListMembershipsResponse{}
+	for id, role := range f.roles {
+		out.Memberships = append(out.Memberships, &orgpb.OrgMembership{OrgId: id, Slug: f.slugs[id], Role: role, WorkspaceIds: f.workspaces[id]})
+	}
+	return out, nil
+}
+
+func newAuthzRouter(a *Authorizer) http.Handler {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), UserContextKey, UserClaims{UserID: "user-1"})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	// As in main, the authorizer sits inside a group so that URL params are
+	// already resolved when it runs.
+	r.Group(func(r chi.Router) {
+		r.Use(a.Middleware)
+		r.Get("/orgs/{orgId}", ok)
+		r.With(a.Require(RoleAdmin)).Patch("/orgs/{orgId}", ok)
+		r.Get("/workspaces/{wsId}", ok)
+		r.With(a.Require(RoleAdmin)).Delete("/workspaces/{wsId}", ok)
+		r.Get("/agents/{agentId}", ok)
+	})
+	return r
+}
+
+func TestAuthorizerRoutes(t *testing.T) {
+	client := &fakeOrgClient{
+		roles:      map[string]string{"org-admin": RoleAdmin, "org-member": RoleMember},
+		workspaces: map[string][]string{"org-admin": {"ws-a"}, "org-member": {"ws-m"}},
+		slugs:      map[string]string{"org-admin": "acme", "org-member": "globex"},
+	}
+	router := newAuthzRouter(NewAuthorizer(client, time.Minute))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+	}{
+		{"member reads org", http.MethodGet, "/orgs/org-member", http.StatusNoContent},
+		{"non-member reads org", http.MethodGet, "/orgs/org-other", http.StatusForbidden},
+		{"member updates org", http.MethodPatch, "/orgs/org-member", http.StatusForbidden},
+		{"admin updates org", http.MethodPatch, "/orgs/org-admin", http.StatusNoContent},
+		{"member reads org by slug", http.MethodGet, "/orgs/globex", http.StatusNoContent},
+		{"member updates org by slug", http.MethodPatch, "/orgs/globex", http.StatusForbidden},
+		{"admin updates org by slug", http.MethodPatch, "/orgs/acme", http.StatusNoContent},
+		{"non-member reads org by slug", http.MethodGet, "/orgs/initech", http.StatusForbidden},
+		{"member reads workspace", http.MethodGet, "/workspaces/ws-m", http.StatusNoContent},
+		{"unknown workspace", http.MethodGet, "/workspaces/ws-x", http.StatusForbidden},
+		{"member deletes workspace", http.MethodDelete, "/workspaces/ws-m", http.StatusForbidden},
+		{"admin deletes workspace", http.MethodDelete, "/workspaces/ws-a", http.StatusNoContent},
+		{"unscoped route", http.MethodGet, "/agents/agent-1", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.want {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.path, rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizerResolvesSlugScopeToOrgID(t *testing.T) {
+	client := &fakeOrgClient{
+		roles: map[string]string{"org-1": RoleAdmin},
+		slugs: map[string]string{"org-1": "acme"},
+	}
+	a := NewAuthorizer(client, time.Minute)
+	var got Scope
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), UserContextKey, UserClaims{UserID: "user-1"})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	r.With(a.Middleware).Get("/orgs/{orgId}/audit-logs", func(w http.ResponseWriter, r *http.Request) {
+		got, _ = GetScope(r)
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orgs/acme/audit-logs", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET by slug = %d, want 200", rec.Code)
+	}
+	if got.OrgID != "org-1" || got.Role != RoleAdmin {
+		t.Fatalf("scope = %+v, want org-1/admin", got)
+	}
+}
+
+func TestAuthorizerCachesAndRefreshesOnMiss(t *testing.T) {
+	client := &fakeOrgClient{
+		roles:      map[string]string{"org-1": RoleMember},
+		workspaces: map[string][]string{"org-1": {"ws-1"}},
+	}
+	now := time.Unix(1_700_000_000, 0)
+	a := NewAuthorizer(client, time.Minute)
+	a.now = func() time.Time { return now }
+	router := newAuthzRouter(a)
+
+	get := func(path string) int {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	get("/workspaces/ws-1")
+	get("/workspaces/ws-1")
+	if client.listCalls != 1 {
+		t.Fatalf("ListMemberships calls = %d, want 1 (cached)", client.listCalls)
+	}
+
+	// A workspace created after the snapshot is not refreshed within minRefresh...
+	client.workspaces["org-1"] = append(client.workspaces["org-1"], "ws-2")
+	if code := get("/workspaces/ws-2"); code != http.StatusForbidden {
+		t.Fatalf("new workspace within minRefresh = %d, want 403", code)
+	}
+	// ...but is picked up by a single refresh afterwards.
+	now = now.Add(5 * time.Second)
+	if code := get("/workspaces/ws-2"); code != http.StatusNoContent {
+		t.Fatalf("new workspace after refresh = %d, want 204", code)
+	}
+	if client.listCalls != 2 {
+		t.Fatalf("ListMemberships calls = %d, want 2", client.listCalls)
+	}
+
+	now = now.Add(2 * time.Minute)
+	get("/workspaces/ws-1")
+	if client.listCalls != 3 {
+		t.Fatalf("ListMemberships calls after TTL = %d, want 3", client.listCalls)
+	}
+}
+
+func TestAuthorizerPrunesExpiredMemberships(t *testing.T) {
+	client := &fakeOrgClient{roles: map[string]string{"org-1": RoleMember}}
+	now := time.Unix(1_700_000_000, 0)
+	a := NewAuthorizer(client, time.Minute)
+	a.now = func() time.Time { return now }
+
+	for _, id := range []string{"user-1", "user-2", "user-3"} {
+		if _, err := a.Resolve(context.Background(), UserClaims{UserID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now = now.Add(2 * time.Minute)
+	if _, err := a.Resolve(context.Background(), UserClaims{UserID: "user-4"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.cache) != 1 || a.cache["user-4"] == nil {
+		t.Fatalf("cache after TTL holds %d users, want only user-4", len(a.cache))
+	}
+}
+
+func TestAuthorizerResourceRoutes(t *testing.T) {
+	client := &fakeOrgClient{
+		roles:      map[string]string{"org-1": RoleMember},
+		workspaces: map[string][]string{"org-1": {"ws-1"}},
+	}
+	owners := map[string]string{"s-mine": "ws-1", "s-other": "ws-2", "t-mine": "ws-1"}
+	lookups := 0
+	a := NewAuthorizer(client, time.Minute)
+	a.ResolveResources(func(_ context.Context, _ UserClaims, param, id string) (string, error) {
+		lookups++
+		switch {
+		case id == "s-down":
+			return "", errors.New("chat service unavailable")
+		case id == "s-denied":
+			return "", ErrResourceForbidden
+		case owners[id] == "":
+			return "", ErrResourceNotFound
+		}
+		return owners[id], nil
+	}, "sessionId", "taskId")
+
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), UserContextKey, UserClaims{UserID: "user-1"})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	r.Group(func(r chi.Router) {
+		r.Use(a.Middleware)
+		r.Get("/sessions/{sessionId}/messages", ok)
+		r.Get("/workspaces/{wsId}/scheduler/tasks/{taskId}", ok)
+	})
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/sessions/s-mine/messages", http.StatusNoContent},
+		{"/sessions/s-other/messages", http.StatusForbidden},
+		{"/sessions/s-denied/messages", http.StatusForbidden},
+		{"/sessions/s-missing/messages", http.StatusNoContent}, // the handler answers 404
+		{"/sessions/s-down/messages", http.StatusServiceUnavailable},
+		{"/workspaces/ws-1/scheduler/tasks/t-mine", http.StatusNoContent},
+		{"/workspaces/ws-1/scheduler/tasks/s-other", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.path, rec.Code, tt.want)
+		}
+	}
+
+	before := lookups
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sessions/s-mine/messages", nil))
+	if lookups != before {
+		t.Errorf("resource lookups = %d, want %d (cached)", lookups, before)
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/rs/cors"
+)
+
+// CORS wraps rs/cors with an allow-list that can be swapped at runtime
+// (config reload) without rebuilding the router.
+type CORS struct {
+	origins atomic.Pointer[map[string]bool]
+	handler *cors.Cors
+}
+
+func NewCORS(origins []string) *CORS {
+	c := &CORS{}
+	c.SetOrigins(origins)
+	c.handler = cors.New(cors.Options{
+		AllowOriginFunc:  c.allowed,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type", "Idempotency-Key", "X-Request-ID", "X-Runtime-Secret"},
+		AllowCredentials: true,
+	})
+	return c
+}
+
+func (c *CORS) SetOrigins(origins []string) {
+	set := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		set[origin] = true
+	}
+	c.origins.Store(&set)
+}
+
+func (c *CORS) allowed(origin string) bool {
+	return (*c.origins.Load())[origin]
+}
+
+func (c *CORS) Handler(next http.Handler) http.Handler {
+	return c.handler.Handler(next)
+}
@@ -0,0 +1,288 @@
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	// Request bodies beyond this are spooled to a temporary file while
+	// they are hashed rather than held in memory.
+	maxBufferedIdempotentBody = 1 << 20
+)
+
+type idempotencyRecord struct {
+	fingerprint [sha256.Size]byte
+	inFlight    bool
+	status      int
+	header      http.Header
+	body        []byte
+	expiresAt   time.Time
+	size        int64
+	elem        *list.Element // position in Idempotency.lru once stored
+}
+
+// IdempotencyOptions configures an Idempotency store. Zero values take
+// the defaults noted on each field.
+type IdempotencyOptions struct {
+	// Window is how long a response is replayed. Default 24h.
+	Window time.Duration
+	// MaxEntries and MaxBytes bound the stored responses; the least
+	// recently used are evicted first. Defaults 10000 and 64MB.
+	MaxEntries int
+	MaxBytes   int64
+	// MaxResponseBytes skips storing larger responses. Default 256KB.
+	MaxResponseBytes int
+}
+
+// Idempotency replays the first response for a repeated Idempotency-Key.
+// Records are keyed by (user, method + path, key) and kept for window.
+//
+//   - a repeat with the same body replays the stored status, headers and body;
+//   - a repeat with a different body is rejected with 422;
+//   - a repeat while the first request is still running is rejected with 409.
+//
+// 5xx responses are not stored, so clients may retry after server failures;
+// neither are responses over MaxResponseBytes, whose repeats run again.
+// Requests without the header pass through untouched.
+type Idempotency struct {
+	opts IdempotencyOptions
+	now  func() time.Time
+
+	mu        sync.Mutex
+	records   map[string]*idempotencyRecord
+	lru       *list.List // stored record keys, most recently used first
+	bytes     int64      // total size of stored records
+	lastSweep time.Time
+}
+
+func NewIdempotency(opts IdempotencyOptions) *Idempotency {
+	if opts.Window <= 0 {
+		opts.Window = 24 * time.Hour
+	}
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = 10000
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = 64 << 20
+	}
+	if opts.MaxResponseBytes <= 0 {
+		opts.MaxResponseBytes = 256 << 10
+	}
+	return &Idempotency{
+		opts:    opts,
+		now:     time.Now,
+		records: make(map[string]*idempotencyRecord),
+		lru:     list.New(),
+	}
+}
+
+// removeLocked forgets the record under key. m.mu must be held.
+func (m *Idempotency) removeLocked(key string, rec *idempotencyRecord) {
+	delete(m.records, key)
+	if rec.elem != nil {
+		m.lru.Remove(rec.elem)
+		m.bytes -= rec.size
+		rec.elem = nil
+	}
+}
+
+// sweepLocked drops expired records, at most once a minute.
+func (m *Idempotency) sweepLocked(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	m.lastSweep = now
+	for key, rec := range m.records {
+		if !rec.inFlight && now.After(rec.expiresAt) {
+			m.removeLocked(key, rec)
+		}
+	}
+}
+
+// evictLocked drops least recently used stored records until the store is
+// back within MaxEntries and MaxBytes. In-flight records are never evicted.
+func (m *Idempotency) evictLocked() {
+	for (len(m.records) > m.opts.MaxEntries || m.bytes > m.opts.MaxBytes) && m.lru.Len() > 0 {
+		key := m.lru.Back().Value.(string)
+		m.removeLocked(key, m.records[key])
+	}
+}
+
+func (m *Idempotency) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimSpace(r.Header.Get(IdempotencyKeyHeader))
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			http.Error(w, `{"error":"Idempotency-Key is too long"}`, http.StatusBadRequest)
+			return
+		}
+
+		fingerprint, body, err := spoolBody(r.Body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, `{"error":"request body too large"}`, http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, `{"error":"failed to read body"}`, http.StatusBadRequest)
+			return
+		}
+		defer body.Close()
+		r.Body = body
+
+		user, _ := GetUser(r)
+		recordKey := user.UserID + "\x00" + r.Method + " " + r.URL.Path + "\x00" + key
+
+		now := m.now()
+		m.mu.Lock()
+		m.sweepLocked(now)
+		rec, ok := m.records[recordKey]
+		if ok && !rec.inFlight && now.After(rec.expiresAt) {
+			m.removeLocked(recordKey, rec)
+			ok = false
+		}
+		if ok {
+			if rec.elem != nil {
+				m.lru.MoveToFront(rec.elem)
+			}
+			m.mu.Unlock()
+			switch {
+			case rec.fingerprint != fingerprint:
+				http.Error(w, `{"error":"Idempotency-Key was already used with a different request body"}`, http.StatusUnprocessableEntity)
+			case rec.inFlight:
+				http.Error(w, `{"error":"a request with this Idempotency-Key is already in progress"}`, http.StatusConflict)
+			default:
+				for k, v := range rec.header {
+					w.Header()[k] = v
+				}
+				w.Header().Set(IdempotencyReplayedHeader, "true")
+				w.WriteHeader(rec.status)
+				w.Write(rec.body)
+			}
+			return
+		}
+		rec = &idempotencyRecord{fingerprint: fingerprint, inFlight: true}
+		m.records[recordKey] = rec
+		m.mu.Unlock()
+
+		cw := &capturingWriter{ResponseWriter: w, status: http.StatusOK, limit: m.opts.MaxResponseBytes}
+		completed := false
+		defer func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			if !completed || cw.status >= 500 || cw.overflow {
+				m.removeLocked(recordKey, rec)
+				return
+			}
+			rec.inFlight = false
+			rec.status = cw.status
+			rec.header = w.Header().Clone()
+			rec.body = cw.body.Bytes()
+			rec.expiresAt = m.now().Add(m.opts.Window)
+			rec.size = int64(len(recordKey) + len(rec.body))
+			for k, v := range rec.header {
+				rec.size += int64(len(k))
+				for _, s := range v {
+					rec.size += int64(len(s))
+				}
+			}
+			rec.elem = m.lru.PushFront(recordKey)
+			m.bytes += rec.size
+			m.evictLocked()
+		}()
+		next.ServeHTTP(cw, r)
+		completed = true
+	})
+}
+
+// spoolBody reads body to the end, hashing it as it goes, and returns a
+// replacement that replays it. The first maxBufferedIdempotentBody bytes
+// are kept in memory and the rest in a temporary file, removed on Close.
+func spoolBody(body io.Reader) ([sha256.Size]byte, io.ReadCloser, error) {
+	var fingerprint [sha256.Size]byte
+	h := sha256.New()
+	var head bytes.Buffer
+	n, err := io.Copy(&head, io.TeeReader(io.LimitReader(body, maxBufferedIdempotentBody), h))
+	if err != nil {
+		return fingerprint, nil, err
+	}
+	if n < maxBufferedIdempotentBody {
+		h.Sum(fingerprint[:0])
+		return fingerprint, io.NopCloser(&head), nil
+	}
+	tail, err := os.CreateTemp("", "idempotent-body-*")
+	if err != nil {
+		return fingerprint, nil, err
+	}
+	spooled := &spooledBody{Reader: io.MultiReader(&head, tail), file: tail}
+	if _, err := io.Copy(io.MultiWriter(tail, h), body); err != nil {
+		spooled.Close()
+		return fingerprint, nil, err
+	}
+	if _, err := tail.Seek(0, io.SeekStart); err != nil {
+		spooled.Close()
+		return fingerprint, nil, err
+	}
+	h.Sum(fingerprint[:0])
+	return fingerprint, spooled, nil
+}
+
+// spooledBody replays a request body partly held in a temporary file.
+type spooledBody struct {
+	io.Reader
+	file *os.File
+}
+
+func (b *spooledBody) Close() error {
+	b.file.Close()
+	return os.Remove(b.file.Name())
+}
+
+// capturingWriter tees the response so it can be replayed later. Past limit
+// bytes it stops buffering and marks the response as too large to store.
+type capturingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	limit       int
+	overflow    bool
+}
+
+func (c *capturingWriter) WriteHeader(code int) {
+	if !c.wroteHeader {
+		c.status = code
+		c.wroteHeader = true
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *capturingWriter) Write(b []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	if !c.overflow {
+		if c.body.Len()+len(b) > c.limit {
+			c.overflow = true
+			c.body = bytes.Buffer{}
+		} else {
+			c.body.Write(b)
+		}
+	}
+	return c.ResponseWriter.Write(b)
+}
@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func idempotentRequest(userID, key, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/workspaces/ws-1/sessions", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	return req.WithContext(context.WithValue(req.Context(), UserContextKey, UserClaims{UserID: userID}))
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	calls := 0
+	status := http.StatusCreated
+	h := NewIdempotency(IdempotencyOptions{}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"data":{"id":"s-%d"}}`, calls)
+	}))
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := serve(idempotentRequest("u1", "k1", `{"title":"a"}`))
+	second := serve(idempotentRequest("u1", "k1", `{"title":"a"}`))
+	if calls != 1 {
+		t.Fatalf("handler calls = %d, want 1", calls)
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %q, want %d %q", second.Code, second.Body, first.Code, first.Body)
+	}
+	if second.Header().Get(IdempotencyReplayedHeader) != "true" || second.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("replay headers = %v", second.Header())
+	}
+
+	if rec := serve(idempotentRequest("u1", "k1", `{"title":"b"}`)); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("different body = %d, want 422", rec.Code)
+	}
+	if serve(idempotentRequest("u2", "k1", `{"title":"a"}`)); calls != 2 {
+		t.Errorf("other user's key should not replay; calls = %d", calls)
+	}
+	if serve(idempotentRequest("u1", "", `{"title":"a"}`)); calls != 3 {
+		t.Errorf("request without key should pass through; calls = %d", calls)
+	}
+
+	status = http.StatusServiceUnavailable
+	serve(idempotentRequest("u1", "k5xx", `{}`))
+	serve(idempotentRequest("u1", "k5xx", `{}`))
+	if calls != 5 {
+		t.Errorf("5xx responses should not be stored; calls = %d", calls)
+	}
+}
+
+func TestIdempotencyConcurrentDuplicate(t *testing.T) {
+	m := NewIdempotency(IdempotencyOptions{})
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	done := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, idempotentRequest("u1", "k1", `{}`))
+		done <- rec.Code
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, idempotentRequest("u1", "k1", `{}`))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("in-flight duplicate = %d, want 409", rec.Code)
+	}
+	close(release)
+	if code := <-done; code != http.StatusCreated {
+		t.Errorf("first request = %d, want 201", code)
+	}
+}
+
+func TestIdempotencyBoundsStoredResponses(t *testing.T) {
+	calls := 0
+	m := NewIdempotency(IdempotencyOptions{MaxEntries: 2, MaxResponseBytes: 16})
+	h := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Query().Get("big") != "" {
+			w.Write([]byte(strings.Repeat("x", 17)))
+			return
+		}
+		fmt.Fprintf(w, "%d", calls)
+	}))
+	serve := func(key, query string) string {
+		req := idempotentRequest("u1", key, `{}`)
+		req.URL.RawQuery = query
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	if serve("big", "big=1"); serve("big", "big=1") != strings.Repeat("x", 17) || calls != 2 {
+		t.Errorf("oversized response was stored; calls = %d", calls)
+	}
+	if len(m.records) != 0 {
+		t.Errorf("records = %d, want oversized response dropped", len(m.records))
+	}
+
+	a := serve("a", "")
+	serve("b", "")
+	serve("a", "") // a is now the most recently used
+	serve("c", "")
+	if len(m.records) != 2 || m.records["u1\x00POST /workspaces/ws-1/sessions\x00b"] != nil {
+		t.Errorf("after eviction records = %d, want b evicted", len(m.records))
+	}
+	if got := serve("a", ""); got != a {
+		t.Errorf("a replayed %q, want %q", got, a)
+	}
+	before := calls
+	serve("b", "")
+	if calls != before+1 {
+		t.Error("evicted key b was replayed")
+	}
+}
+
+func TestIdempotencySpoolsLargeBodies(t *testing.T) {
+	var got []string
+	h := NewIdempotency(IdempotencyOptions{}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, string(body))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	large := strings.Repeat("a", maxBufferedIdempotentBody+10)
+	for _, body := range []string{large, large, large[:len(large)-1] + "b"} {
+		h.ServeHTTP(httptest.NewRecorder(), idempotentRequest("u1", "k1", body))
+	}
+	if len(got) != 1 || got[0] != large {
+		t.Fatalf("handler saw %d bodies, want the large body once", len(got))
+	}
+
+	rec := httptest.NewRecorder()
+	req := idempotentRequest("u1", "k2", large)
+	req.Body = http.MaxBytesReader(rec, req.Body, 1024)
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("body over the request limit = %d, want 413", rec.Code)
+	}
+}
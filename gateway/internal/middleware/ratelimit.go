@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a per-user token bucket: each user (or client IP for
+// anonymous requests) may make perMinute requests per minute with bursts up
+// to burst. Limits can be changed at runtime with SetLimits; zero disables
+// limiting.
+type RateLimiter struct {
+	mu        sync.Mutex
+	perMinute int
+	burst     int
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(perMinute, burst int) *RateLimiter {
+	l := &RateLimiter{buckets: make(map[string]*bucket), now: time.Now}
+	l.SetLimits(perMinute, burst)
+	return l
+}
+
+// SetLimits replaces the limits. Existing buckets keep their tokens, capped
+// to the new burst.
+func (l *RateLimiter) SetLimits(perMinute, burst int) {
+	if burst <= 0 {
+		burst = perMinute
+	}
+	l.mu.Lock()
+	l.perMinute, l.burst = perMinute, burst
+	for _, b := range l.buckets {
+		b.tokens = math.Min(b.tokens, float64(burst))
+	}
+	l.mu.Unlock()
+}
+
+// allow takes a token for key, or reports how long until one is available.
+func (l *RateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.perMinute <= 0 {
+		return true, 0
+	}
+	now := l.now()
+	rate := float64(l.perMinute) / float64(time.Minute)
+	if now.Sub(l.lastSweep) > time.Minute {
+		l.lastSweep = now
+		for k, b := range l.buckets {
+			if float64(now.Sub(b.last))*rate >= float64(l.burst) {
+				delete(l.buckets, k)
+			}
+		}
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.burst), b.tokens+float64(now.Sub(b.last))*rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / rate)
+}
+
+// Middleware enforces the limit, answering 429 with Retry-After. It should
+// run after Auth so requests are keyed by user rather than IP.
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.RemoteAddr
+		if user, ok := GetUser(r); ok && user.UserID != "" {
+			key = "user:" + user.UserID
+		}
+		ok, wait := l.allow(key)
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, `{"error":"rate limit exceeded"}`, http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewRateLimiter(60, 2)
+	l.now = func() time.Time { return now }
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/orgs", nil)
+		req = req.WithContext(context.WithValue(req.Context(), UserContextKey, UserClaims{UserID: userID}))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	serve("u1")
+	serve("u1")
+	rec := serve("u1")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("third request = %d Retry-After=%q, want 429 / 1", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if serve("u2").Code != http.StatusOK {
+		t.Error("limits must be per user")
+	}
+
+	now = now.Add(time.Second)
+	if serve("u1").Code != http.StatusOK {
+		t.Error("token should refill after a second at 60/min")
+	}
+
+	l.SetLimits(0, 0)
+	for i := 0; i < 5; i++ {
+		if serve("u1").Code != http.StatusOK {
+			t.Fatal("zero limit should disable limiting")
+		}
+	}
+}
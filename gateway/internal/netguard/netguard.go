@@ -0,0 +1,46 @@
+// Package netguard keeps requests to user-supplied URLs away from
+// loopback, private, link-local and other internal addresses.
+package netguard
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+var ErrBlockedAddress = errors.New("address is not publicly routable")
+
+// Dialer returns a dialer that, unless allowPrivate is set, refuses to
+// connect to non-public addresses. The check runs on the resolved IP of
+// every connection, so redirects and DNS tricks cannot get around it.
+// Clients using it must not go through a proxy.
+func Dialer(timeout time.Duration, allowPrivate bool) *net.Dialer {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !PublicIP(ip) {
+				return ErrBlockedAddress
+			}
+			return nil
+		}
+	}
+	return dialer
+}
+
+// PublicIP reports whether ip is publicly routable.
+func PublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	// Carrier-grade NAT (100.64.0.0/10) is internal too.
+	if v4 := ip.To4(); v4 != nil && v4[0] == 100 && v4[1]&0xc0 == 64 {
+		return false
+	}
+	return true
+}
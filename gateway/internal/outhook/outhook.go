@@ -0,0 +1,494 @@
+// Package outhook delivers workspace events to the HTTP endpoints of
+// outgoing webhook channels. Every request is signed with the channel's
+// secret, failed deliveries are retried with exponential backoff, and
+// each delivery is kept in a log, with its attempts, so it can be
+// inspected and redelivered. The log and the retry scheduler are shared
+// with the inbound webhook queue through package walq.
+package outhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/netguard"
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/walq"
+)
+
+var (
+	ErrNotFound = errors.New("webhook delivery not found")
+	ErrFull     = errors.New("outgoing webhook queue is full")
+	ErrClosed   = errors.New("outgoing webhook queue is closed")
+)
+
+// Delivery statuses.
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Request headers sent with every delivery.
+const (
+	HeaderID        = "X-Webhook-Id"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// responseSnippet is how much of an endpoint's response body is logged.
+const responseSnippet = 1024
+
+// Delivery is one event bound for one channel's endpoint.
+type Delivery struct {
+	ID          string `json:"id"`
+	ChannelID   string `json:"channelId"`
+	WorkspaceID string `json:"workspaceId"`
+	EventID     string `json:"eventId"`
+	EventType   string `json:"eventType"`
+	// Payload is the request body, identical on every attempt.
+	Payload string `json:"payload"`
+	// RedeliveryOf is the delivery this one was manually redelivered from.
+	RedeliveryOf  string     `json:"redeliveryOf,omitempty"`
+	Status        string     `json:"status"`
+	Attempts      []Attempt  `json:"attempts"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	NextAttemptAt time.Time  `json:"nextAttemptAt"`
+	FinishedAt    *time.Time `json:"finishedAt,omitempty"`
+}
+
+// Attempt is one HTTP request made for a delivery.
+type Attempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	DurationMs int64     `json:"durationMs"`
+	Error      string    `json:"error,omitempty"`
+	// Response is the start of the response body.
+	Response string `json:"response,omitempty"`
+}
+
+// Target is where a channel's deliveries go. It is looked up before every
+// attempt, so URL and secret changes apply to retries too.
+type Target struct {
+	URL    string
+	Secret string
+	// Active is false once the channel is disabled or deleted; its pending
+	// deliveries then fail without being sent.
+	Active bool
+}
+
+// Resolver looks up a channel's current target. An error is treated as
+// temporary and the attempt is retried later.
+type Resolver func(ctx context.Context, channelID string) (Target, error)
+
+// Sign returns the X-Webhook-Signature value for body sent at timestamp
+// (Unix seconds): "sha256=" followed by the hex HMAC-SHA256, keyed with
+// the channel secret, of "<timestamp>.<body>".
+func Sign(secret, timestamp, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Options configures a Queue.
+type Options struct {
+	Dir string
+	// Sync fsyncs the log after every enqueue.
+	Sync bool
+	// MaxPending bounds deliveries not yet finished.
+	MaxPending  int
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Concurrency int
+	// Timeout bounds one HTTP attempt.
+	Timeout time.Duration
+	// KeepPerChannel and Retention bound the log of finished deliveries.
+	KeepPerChannel int
+	Retention      time.Duration
+	// AllowPrivate lets endpoints resolve to private or loopback
+	// addresses, for development.
+	AllowPrivate bool
+}
+
+// record is one line of the log.
+type record struct {
+	Op       string    `json:"op"` // enqueue | attempt | finish | forget
+	Delivery *Delivery `json:"delivery,omitempty"`
+	ID       string    `json:"id,omitempty"`
+	Attempt  *Attempt  `json:"attempt,omitempty"`
+	Status   string    `json:"status,omitempty"`
+	At       time.Time `json:"at"`
+	Next     time.Time `json:"next,omitempty"`
+}
+
+// Queue holds every delivery in memory, rebuilt from the log on Open.
+type Queue struct {
+	opts   Options
+	now    func() time.Time
+	sched  *walq.Scheduler[Delivery]
+	client *http.Client
+
+	mu         sync.Mutex
+	log        *walq.Log[record]
+	deliveries map[string]*Delivery
+	pending    walq.Pending
+	closed     bool
+}
+
+// Open replays the log in opts.Dir, drops finished deliveries past the
+// retention limits and compacts the log.
+func Open(opts Options) (*Queue, error) {
+	if opts.MaxPending <= 0 {
+		opts.MaxPending = 10000
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 8
+	}
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = 10 * time.Second
+	}
+	if opts.MaxDelay < opts.BaseDelay {
+		opts.MaxDelay = time.Hour
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 8
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.KeepPerChannel <= 0 {
+		opts.KeepPerChannel = 500
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = 7 * 24 * time.Hour
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	q := &Queue{
+		opts:  opts,
+		now:   time.Now,
+		sched: walq.NewScheduler[Delivery](opts.Concurrency),
+		client: &http.Client{
+			Timeout: opts.Timeout,
+			Transport: &http.Transport{
+				// No proxy: the address guard must see the real destination.
+				Proxy:                 nil,
+				DialContext:           netguard.Dialer(10*time.Second, opts.AllowPrivate).DialContext,
+				TLSHandshakeTimeout:   10 * time.Second,
+				ResponseHeaderTimeout: opts.Timeout,
+				MaxIdleConnsPerHost:   2,
+			},
+			// Endpoints are expected to answer directly; a redirect is
+			// reported as the response it is.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		deliveries: map[string]*Delivery{},
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	log, err := walq.OpenLog(filepath.Join(opts.Dir, "deliveries.log"), "outhook", q.apply, q.snapshot)
+	if err != nil {
+		return nil, err
+	}
+	q.log = log
+	return q, nil
+}
+
+// apply folds one record into the in-memory state.
+func (q *Queue) apply(rec record) {
+	switch rec.Op {
+	case "enqueue":
+		if rec.Delivery == nil {
+			return
+		}
+		d := *rec.Delivery
+		q.deliveries[d.ID] = &d
+		if d.Status == StatusPending {
+			q.pending.Push(d.ID)
+		}
+	case "attempt":
+		if d, ok := q.deliveries[rec.ID]; ok && rec.Attempt != nil {
+			d.Attempts = append(d.Attempts, *rec.Attempt)
+			d.NextAttemptAt = rec.Next
+		}
+	case "finish":
+		if d, ok := q.deliveries[rec.ID]; ok {
+			if rec.Attempt != nil {
+				d.Attempts = append(d.Attempts, *rec.Attempt)
+			}
+			at := rec.At
+			d.Status = rec.Status
+			d.FinishedAt = &at
+			q.pending.Remove(rec.ID)
+		}
+	case "forget":
+		delete(q.deliveries, rec.ID)
+		q.pending.Remove(rec.ID)
+	}
+}
+
+// snapshot writes one enqueue record per delivery for compaction, after
+// dropping the expired ones.
+func (q *Queue) snapshot(emit func(record) error) error {
+	q.expire()
+	now := q.now()
+	ids := make([]string, 0, len(q.deliveries))
+	for id := range q.deliveries {
+		ids = append(ids, id)
+	}
+	// Creation order, so replay rebuilds the same pending order.
+	sort.Slice(ids, func(i, j int) bool {
+		return q.deliveries[ids[i]].CreatedAt.Before(q.deliveries[ids[j]].CreatedAt)
+	})
+	for _, id := range ids {
+		if err := emit(record{Op: "enqueue", Delivery: q.deliveries[id], At: now}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expire drops finished deliveries older than the retention period. It
+// only changes memory; it runs as part of compaction.
+func (q *Queue) expire() {
+	cutoff := q.now().Add(-q.opts.Retention)
+	for id, d := range q.deliveries {
+		if d.FinishedAt != nil && d.FinishedAt.Before(cutoff) {
+			delete(q.deliveries, id)
+		}
+	}
+}
+
+// trim forgets a channel's oldest finished deliveries past KeepPerChannel.
+func (q *Queue) trim(channelID string) {
+	var finished []*Delivery
+	for _, d := range q.deliveries {
+		if d.ChannelID == channelID && d.FinishedAt != nil {
+			finished = append(finished, d)
+		}
+	}
+	if len(finished) <= q.opts.KeepPerChannel {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].FinishedAt.After(*finished[j].FinishedAt) })
+	now := q.now().UTC()
+	for _, d := range finished[q.opts.KeepPerChannel:] {
+		q.log.Supersede(2 + len(d.Attempts))
+		if err := q.log.Append(record{Op: "forget", ID: d.ID, At: now}, false); err != nil {
+			slog.Warn("outhook: log append failed", slog.Any("err", err))
+			return
+		}
+	}
+}
+
+// Enqueue durably records a new delivery of d's event to d's channel.
+func (q *Queue) Enqueue(d Delivery) (Delivery, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.enqueue(d)
+}
+
+func (q *Queue) enqueue(d Delivery) (Delivery, error) {
+	if q.closed {
+		return Delivery{}, ErrClosed
+	}
+	if q.pending.Len() >= q.opts.MaxPending {
+		return Delivery{}, ErrFull
+	}
+	now := q.now().UTC()
+	d.ID = uuid.NewString()
+	d.Status = StatusPending
+	d.Attempts = nil
+	d.CreatedAt, d.NextAttemptAt, d.FinishedAt = now, now, nil
+	if err := q.log.Append(record{Op: "enqueue", Delivery: &d, At: now}, q.opts.Sync); err != nil {
+		delete(q.deliveries, d.ID)
+		q.pending.Remove(d.ID)
+		return Delivery{}, err
+	}
+	q.sched.Signal()
+	return d, nil
+}
+
+// Redeliver queues a fresh delivery of a logged delivery's payload, with
+// a new attempt budget. The original is left as it is.
+func (q *Queue) Redeliver(channelID, id string) (Delivery, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	orig, ok := q.deliveries[id]
+	if !ok || orig.ChannelID != channelID {
+		return Delivery{}, ErrNotFound
+	}
+	d := *orig
+	d.RedeliveryOf = orig.ID
+	return q.enqueue(d)
+}
+
+// Get returns one of a channel's deliveries.
+func (q *Queue) Get(channelID, id string) (Delivery, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	d, ok := q.deliveries[id]
+	if !ok || d.ChannelID != channelID {
+		return Delivery{}, ErrNotFound
+	}
+	return d.clone(), nil
+}
+
+// List returns a channel's deliveries, newest first, optionally only
+// those with the given status.
+func (q *Queue) List(channelID, status string, limit int) []Delivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var out []Delivery
+	for _, d := range q.deliveries {
+		if d.ChannelID == channelID && (status == "" || d.Status == status) {
+			out = append(out, d.clone())
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+func (d *Delivery) clone() Delivery {
+	c := *d
+	c.Attempts = append([]Attempt(nil), d.Attempts...)
+	return c
+}
+
+// Run sends due deliveries on up to Concurrency goroutines until ctx ends.
+func (q *Queue) Run(ctx context.Context, resolve Resolver) {
+	q.sched.Run(ctx, q.claim, func(d Delivery) {
+		attempt, retryable := q.send(ctx, d, resolve)
+		q.settle(d, attempt, retryable)
+	})
+}
+
+// claim takes the oldest due delivery, or reports how long until one is due.
+func (q *Queue) claim() (Delivery, time.Duration, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	id, wait, ok := q.pending.Claim(q.now(), func(id string) time.Time { return q.deliveries[id].NextAttemptAt })
+	if !ok {
+		return Delivery{}, wait, false
+	}
+	return q.deliveries[id].clone(), 0, true
+}
+
+// send makes one attempt. An attempt without Error succeeded; otherwise
+// retryable says whether another attempt may help.
+func (q *Queue) send(ctx context.Context, d Delivery, resolve Resolver) (Attempt, bool) {
+	start := q.now()
+	attempt := Attempt{At: start.UTC()}
+	finish := func(errMsg string, retryable bool) (Attempt, bool) {
+		attempt.Error = errMsg
+		attempt.DurationMs = q.now().Sub(start).Milliseconds()
+		return attempt, retryable
+	}
+
+	target, err := resolve(ctx, d.ChannelID)
+	if err != nil {
+		return finish(fmt.Sprintf("look up channel: %v", err), true)
+	}
+	if !target.Active {
+		return finish("channel is disabled or deleted", false)
+	}
+	ctx, cancel := context.WithTimeout(ctx, q.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.URL, bytes.NewReader([]byte(d.Payload)))
+	if err != nil {
+		return finish(fmt.Sprintf("invalid endpoint: %v", err), false)
+	}
+	timestamp := strconv.FormatInt(q.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "next-ai-agent-webhooks/1.0")
+	req.Header.Set(HeaderID, d.ID)
+	req.Header.Set(HeaderEvent, d.EventType)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(target.Secret, timestamp, d.Payload))
+
+	res, err := q.client.Do(req)
+	if err != nil {
+		if errors.Is(err, netguard.ErrBlockedAddress) {
+			return finish("endpoint resolves to a private or internal address", false)
+		}
+		return finish(err.Error(), true)
+	}
+	defer res.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(res.Body, responseSnippet))
+	attempt.StatusCode = res.StatusCode
+	attempt.Response = string(snippet)
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return finish("", false)
+	case res.StatusCode == http.StatusRequestTimeout, res.StatusCode == http.StatusTooManyRequests, res.StatusCode >= 500:
+		return finish(fmt.Sprintf("endpoint returned %d", res.StatusCode), true)
+	default:
+		return finish(fmt.Sprintf("endpoint returned %d", res.StatusCode), false)
+	}
+}
+
+// settle records the outcome of an attempt.
+func (q *Queue) settle(d Delivery, attempt Attempt, retryable bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending.Done(d.ID)
+	if q.closed {
+		return
+	}
+	if _, ok := q.deliveries[d.ID]; !ok {
+		return
+	}
+	now := q.now().UTC()
+	var rec record
+	switch {
+	case attempt.Error == "":
+		rec = record{Op: "finish", ID: d.ID, Attempt: &attempt, Status: StatusSucceeded, At: now}
+	case !retryable || len(d.Attempts)+1 >= q.opts.MaxAttempts:
+		rec = record{Op: "finish", ID: d.ID, Attempt: &attempt, Status: StatusFailed, At: now}
+		slog.Warn("outhook: delivery failed", slog.String("delivery", d.ID),
+			slog.String("channel", d.ChannelID), slog.String("err", attempt.Error))
+	default:
+		rec = record{Op: "attempt", ID: d.ID, Attempt: &attempt, At: now, Next: now.Add(walq.Backoff(q.opts.BaseDelay, q.opts.MaxDelay, len(d.Attempts)+1))}
+	}
+	q.log.Supersede(1)
+	if err := q.log.Append(rec, false); err != nil {
+		slog.Warn("outhook: log append failed", slog.Any("err", err))
+	}
+	if rec.Op == "finish" {
+		q.trim(d.ChannelID)
+	}
+	q.log.MaybeCompact()
+	q.sched.Signal()
+}
+
+// Close stops accepting deliveries and closes the log. Attempts still in
+// flight are not recorded and are made again after restart.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	return q.log.Close()
+}
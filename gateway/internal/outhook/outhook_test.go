@@ -0,0 +1,138 @@
+package outhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/walq/walqtest"
+)
+
+func openQueue(t *testing.T, dir string, allowPrivate bool) *Queue {
+	t.Helper()
+	q, err := Open(Options{
+		Dir: dir, Sync: true, MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 4 * time.Millisecond,
+		KeepPerChannel: 2, AllowPrivate: allowPrivate,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { q.Close() })
+	return q
+}
+
+func staticTarget(url string) Resolver {
+	return func(context.Context, string) (Target, error) {
+		return Target{URL: url, Secret: "s3cret", Active: true}, nil
+	}
+}
+
+// runUntil runs the queue until cond holds or a second passes.
+func runUntil(t *testing.T, q *Queue, resolve Resolver, cond func() bool) {
+	t.Helper()
+	walqtest.RunUntil(t, func(ctx context.Context) { q.Run(ctx, resolve) }, cond)
+}
+
+func status(q *Queue, id string) string {
+	d, err := q.Get("ch1", id)
+	if err != nil {
+		return ""
+	}
+	return d.Status
+}
+
+func TestDeliverySignedAndRetried(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts := r.Header.Get(HeaderTimestamp)
+		if r.Header.Get(HeaderSignature) != Sign("s3cret", ts, string(body)) || r.Header.Get(HeaderEvent) != "ping" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		io.WriteString(w, "thanks")
+	}))
+	defer srv.Close()
+
+	q := openQueue(t, t.TempDir(), true)
+	d, err := q.Enqueue(Delivery{ChannelID: "ch1", EventType: "ping", Payload: `{"type":"ping"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runUntil(t, q, staticTarget(srv.URL), func() bool { return status(q, d.ID) == StatusSucceeded })
+	got, _ := q.Get("ch1", d.ID)
+	if len(got.Attempts) != 2 || got.Attempts[0].StatusCode != http.StatusBadGateway || got.Attempts[1].Response != "thanks" {
+		t.Fatalf("attempts = %+v", got.Attempts)
+	}
+}
+
+func TestClientErrorsAndBlockedAddressesFailWithoutRetry(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+
+	q := openQueue(t, t.TempDir(), true)
+	d, _ := q.Enqueue(Delivery{ChannelID: "ch1", EventType: "ping", Payload: "{}"})
+	runUntil(t, q, staticTarget(srv.URL), func() bool { return status(q, d.ID) == StatusFailed })
+	if calls.Load() != 1 {
+		t.Errorf("410 was retried: %d calls", calls.Load())
+	}
+
+	guarded := openQueue(t, t.TempDir(), false)
+	d, _ = guarded.Enqueue(Delivery{ChannelID: "ch1", EventType: "ping", Payload: "{}"})
+	runUntil(t, guarded, staticTarget(srv.URL), func() bool { return status(guarded, d.ID) == StatusFailed })
+	if calls.Load() != 1 {
+		t.Error("request reached a loopback endpoint")
+	}
+	got, _ := guarded.Get("ch1", d.ID)
+	if len(got.Attempts) != 1 {
+		t.Errorf("blocked endpoint attempted %d times", len(got.Attempts))
+	}
+}
+
+func TestRedeliverTrimAndRestart(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	q := openQueue(t, dir, true)
+	first, _ := q.Enqueue(Delivery{ChannelID: "ch1", EventType: "ping", Payload: `{"n":1}`})
+	runUntil(t, q, staticTarget(srv.URL), func() bool { return status(q, first.ID) == StatusSucceeded })
+
+	if _, err := q.Redeliver("ch2", first.ID); err != ErrNotFound {
+		t.Errorf("redeliver from another channel = %v", err)
+	}
+	again, err := q.Redeliver("ch1", first.ID)
+	if err != nil || again.RedeliveryOf != first.ID || again.Payload != first.Payload {
+		t.Fatalf("redeliver = %+v, %v", again, err)
+	}
+	third, _ := q.Enqueue(Delivery{ChannelID: "ch1", EventType: "ping", Payload: `{"n":3}`})
+	runUntil(t, q, staticTarget(srv.URL), func() bool {
+		return status(q, again.ID) == StatusSucceeded && status(q, third.ID) == StatusSucceeded
+	})
+	// Only two finished deliveries are kept per channel.
+	if n := len(q.List("ch1", "", 0)); n != 2 {
+		t.Errorf("kept %d deliveries, want 2", n)
+	}
+	q.Close()
+
+	pending, _ := openQueue(t, dir, true).Enqueue(Delivery{ChannelID: "ch1", EventType: "ping", Payload: "{}"})
+	reopened := openQueue(t, dir, true)
+	if got := reopened.List("ch1", StatusPending, 0); len(got) != 1 || got[0].ID != pending.ID {
+		t.Errorf("pending after restart = %+v", got)
+	}
+	if n := len(reopened.List("ch1", StatusSucceeded, 0)); n != 2 {
+		t.Errorf("finished after restart = %d, want 2", n)
+	}
+}
@@ -0,0 +1,138 @@
+// Package redact masks secret values (API keys, tokens, channel credentials)
+// before request data is written to audit records or logs.
+package redact
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Mask replaces every redacted value.
+const Mask = "[REDACTED]"
+
+// secretKeyFragments are matched against normalized keys (lowercase, with
+// '_' and '-' removed), so "api_key", "apiKey" and "X-Api-Key" all match.
+var secretKeyFragments = []string{
+	"secret",
+	"password",
+	"apikey",
+	"fullkey",
+	"rawkey",
+	"encryptkey",
+	"privatekey",
+	"accesskey",
+	"authorization",
+	"credential",
+	"cookie",
+}
+
+// secretKeySuffixes match only at the end of a normalized key: "accessToken"
+// and "X-Auth-Token" are secrets, token counts like "maxTokens" or
+// "tokenUsage" are not.
+var secretKeySuffixes = []string{
+	"token",
+}
+
+func normalizeKey(key string) string {
+	key = strings.ToLower(key)
+	key = strings.ReplaceAll(key, "_", "")
+	return strings.ReplaceAll(key, "-", "")
+}
+
+// IsSecretKey reports whether values stored under key should be masked.
+func IsSecretKey(key string) bool {
+	k := normalizeKey(key)
+	for _, fragment := range secretKeyFragments {
+		if strings.Contains(k, fragment) {
+			return true
+		}
+	}
+	for _, suffix := range secretKeySuffixes {
+		if strings.HasSuffix(k, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Value returns a deep copy of v with secret fields masked. Nested JSON
+// carried as a string (e.g. a channel's "configJson") is decoded and
+// redacted as well, and returned in decoded form.
+func Value(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			if IsSecretKey(k) {
+				if item != nil && item != "" {
+					out[k] = Mask
+				} else {
+					out[k] = item
+				}
+				continue
+			}
+			if s, ok := item.(string); ok && strings.HasSuffix(normalizeKey(k), "json") {
+				var nested any
+				if err := json.Unmarshal([]byte(s), &nested); err == nil {
+					out[k] = Value(nested)
+					continue
+				}
+			}
+			out[k] = Value(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = Value(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// Restore undoes Value for a client that sends a masked document back: every
+// secret field still holding Mask takes its value from previous, or is
+// dropped when previous has none.
+func Restore(v, previous any) any {
+	val, ok := v.(map[string]any)
+	if !ok {
+		return v
+	}
+	prev, _ := previous.(map[string]any)
+	out := make(map[string]any, len(val))
+	for k, item := range val {
+		if item == Mask && IsSecretKey(k) {
+			if old, ok := prev[k]; ok {
+				out[k] = old
+			}
+			continue
+		}
+		out[k] = Restore(item, prev[k])
+	}
+	return out
+}
+
+// JSON decodes raw as JSON and returns it redacted. ok is false when raw is
+// not valid JSON.
+func JSON(raw []byte) (any, bool) {
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, false
+	}
+	return Value(v), true
+}
+
+// Headers returns a single-value copy of h with secret headers masked.
+func Headers(h map[string][]string) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		if IsSecretKey(k) {
+			out[k] = Mask
+			continue
+		}
+		out[k] = strings.Join(v, ", ")
+	}
+	return out
+}
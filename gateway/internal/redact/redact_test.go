@@ -0,0 +1,53 @@
+package redact
+
+import "testing"
+
+func TestIsSecretKey(t *testing.T) {
+	for key, want := range map[string]bool{
+		"api_key":         true,
+		"X-Api-Key":       true,
+		"accessToken":     true,
+		"refresh_token":   true,
+		"X-Auth-Token":    true,
+		"token":           true,
+		"clientSecret":    true,
+		"Authorization":   true,
+		"maxTokens":       false,
+		"max_tokens":      false,
+		"totalTokens":     false,
+		"tokenUsage":      false,
+		"inputTokenCount": false,
+		"name":            false,
+		"configJson":      false,
+	} {
+		if got := IsSecretKey(key); got != want {
+			t.Errorf("IsSecretKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestRestoreKeepsStoredSecrets(t *testing.T) {
+	stored := map[string]any{
+		"provider": "brave",
+		"brave":    map[string]any{"apiKey": "real-brave"},
+		"tavily":   map[string]any{"apiKey": "real-tavily"},
+	}
+	edited := Value(stored).(map[string]any)
+	edited["provider"] = "tavily"
+	edited["tavily"] = map[string]any{"apiKey": "new-tavily"}
+	edited["exa"] = map[string]any{"apiKey": Mask}
+
+	got := Restore(edited, stored).(map[string]any)
+	if key := got["brave"].(map[string]any)["apiKey"]; key != "real-brave" {
+		t.Errorf("masked key not restored: %v", key)
+	}
+	if key := got["tavily"].(map[string]any)["apiKey"]; key != "new-tavily" {
+		t.Errorf("edited key overwritten: %v", key)
+	}
+	if _, ok := got["exa"].(map[string]any)["apiKey"]; ok {
+		t.Error("mask without a stored value should be dropped")
+	}
+	if got["provider"] != "tavily" {
+		t.Errorf("provider = %v", got["provider"])
+	}
+}
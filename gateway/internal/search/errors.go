@@ -3,7 +3,11 @@ package search
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net"
 	"strings"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/netguard"
 )
 
 const (
@@ -44,6 +48,20 @@ func NewTypedError(errorType string, err error) error {
 	return &TypedError{Type: errorType, Err: err}
 }
 
+// requestError wraps a failed round trip to provider, telling client
+// timeouts apart from other network failures.
+func requestError(provider string, err error) error {
+	errorType := ErrorTypeNetwork
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		errorType = ErrorTypeTimeout
+	}
+	if errors.Is(err, netguard.ErrBlockedAddress) {
+		errorType = ErrorTypeConfig
+	}
+	return NewTypedError(errorType, fmt.Errorf("%s request failed: %w", provider, err))
+}
+
 func ClassifyError(err error) string {
 	if err == nil {
 		return ""
@@ -0,0 +1,112 @@
+package search
+
+import (
+	"strings"
+)
+
+// cleanDomains lowercases and strips schemes, "www." and paths so that
+// "https://www.Example.com/docs" is stored as "example.com".
+func cleanDomains(domains []string) []string {
+	out := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		domain = strings.TrimSpace(strings.ToLower(domain))
+		if i := strings.Index(domain, "://"); i >= 0 {
+			domain = domain[i+3:]
+		}
+		if i := strings.IndexAny(domain, "/?#"); i >= 0 {
+			domain = domain[:i]
+		}
+		domain = strings.TrimPrefix(strings.TrimSuffix(domain, "."), "www.")
+		if domain != "" {
+			out = append(out, domain)
+		}
+	}
+	return out
+}
+
+// FilterDomains keeps results whose host is (a subdomain of) an include
+// entry, when includes are given, and drops those matching an exclude entry.
+func FilterDomains(items []ResultItem, include, exclude []string) []ResultItem {
+	if len(include) == 0 && len(exclude) == 0 {
+		return items
+	}
+	out := make([]ResultItem, 0, len(items))
+	for _, item := range items {
+		host := hostname(item.URL)
+		if len(include) > 0 && !matchesDomain(host, include) {
+			continue
+		}
+		if matchesDomain(host, exclude) {
+			continue
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+func matchesDomain(host string, domains []string) bool {
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// NarrowDomains combines a caller's include/exclude lists with a policy's
+// (workspace or gateway) lists. Excludes are merged. A caller can narrow the
+// policy's includes but never widen them: requested includes outside the
+// policy are dropped, and if none remain the policy's list applies.
+func NarrowDomains(policyInclude, policyExclude, include, exclude []string) ([]string, []string) {
+	exclude = append(append([]string{}, policyExclude...), cleanDomains(exclude)...)
+	include = cleanDomains(include)
+	if len(policyInclude) == 0 {
+		return include, exclude
+	}
+	narrowed := make([]string, 0, len(include))
+	for _, domain := range include {
+		if matchesDomain(domain, policyInclude) {
+			narrowed = append(narrowed, domain)
+		}
+	}
+	if len(narrowed) == 0 {
+		return policyInclude, exclude
+	}
+	return narrowed, exclude
+}
+
+// maxSiteOperators caps how many domains are spelled out as site: operators;
+// longer lists are left to post-filtering to keep the query within limits.
+const maxSiteOperators = 8
+
+// withSiteOperators appends site:/-site: operators for q's domain lists to
+// the query text, for engines that understand them.
+func withSiteOperators(q Query) string {
+	parts := []string{q.Query}
+	if n := len(q.IncludeDomains); n == 1 {
+		parts = append(parts, "site:"+q.IncludeDomains[0])
+	} else if n > 1 && n <= maxSiteOperators {
+		sites := make([]string, 0, n)
+		for _, domain := range q.IncludeDomains {
+			sites = append(sites, "site:"+domain)
+		}
+		parts = append(parts, "("+strings.Join(sites, " OR ")+")")
+	}
+	if len(q.ExcludeDomains) <= maxSiteOperators {
+		for _, domain := range q.ExcludeDomains {
+			parts = append(parts, "-site:"+domain)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// finalizeResults is the common tail of every provider: drop results outside
+// the domain filters, optionally re-rank, then de-duplicate and truncate.
+func finalizeResults(q Query, items []ResultItem, limit int) []ResultItem {
+	items = FilterDomains(items, q.IncludeDomains, q.ExcludeDomains)
+	if q.Rerank {
+		items = Rerank(items, q.TrustedDomains)
+	}
+	return uniqueByURL(items, limit)
+}
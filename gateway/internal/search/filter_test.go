@@ -0,0 +1,122 @@
+package search
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func resultURLs(items []ResultItem) []string {
+	out := []string{}
+	for _, item := range items {
+		out = append(out, item.URL)
+	}
+	return out
+}
+
+func TestFilterDomains(t *testing.T) {
+	items := []ResultItem{
+		{URL: "https://go.dev/doc"},
+		{URL: "https://blog.go.dev/x"},
+		{URL: "https://www.spam.example/seo"},
+		{URL: "https://notgo.dev/"},
+	}
+	if got := resultURLs(FilterDomains(items, []string{"go.dev"}, nil)); !reflect.DeepEqual(got, []string{"https://go.dev/doc", "https://blog.go.dev/x"}) {
+		t.Errorf("include: %v", got)
+	}
+	if got := resultURLs(FilterDomains(items, nil, []string{"spam.example", "blog.go.dev"})); !reflect.DeepEqual(got, []string{"https://go.dev/doc", "https://notgo.dev/"}) {
+		t.Errorf("exclude: %v", got)
+	}
+}
+
+func TestNarrowDomains(t *testing.T) {
+	cases := []struct {
+		name                     string
+		policyInclude, include   []string
+		policyExclude, exclude   []string
+		wantInclude, wantExclude []string
+	}{
+		{"no policy", nil, []string{"Go.dev"}, nil, nil, []string{"go.dev"}, []string{}},
+		{"narrow within policy", []string{"go.dev"}, []string{"pkg.go.dev", "evil.example"}, nil, nil, []string{"pkg.go.dev"}, []string{}},
+		{"cannot widen", []string{"go.dev"}, []string{"evil.example"}, nil, nil, []string{"go.dev"}, []string{}},
+		{"excludes merge", nil, nil, []string{"spam.example"}, []string{"seo.example"}, []string{}, []string{"spam.example", "seo.example"}},
+	}
+	for _, tc := range cases {
+		include, exclude := NarrowDomains(tc.policyInclude, tc.policyExclude, tc.include, tc.exclude)
+		if !reflect.DeepEqual(include, tc.wantInclude) || !reflect.DeepEqual(exclude, tc.wantExclude) {
+			t.Errorf("%s: got include=%v exclude=%v", tc.name, include, exclude)
+		}
+	}
+}
+
+func TestWithSiteOperators(t *testing.T) {
+	q := Query{Query: "generics", IncludeDomains: []string{"go.dev", "github.com"}, ExcludeDomains: []string{"spam.example"}}
+	if got, want := withSiteOperators(q), "generics (site:go.dev OR site:github.com) -site:spam.example"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	q = Query{Query: "generics", IncludeDomains: []string{"go.dev"}}
+	if got, want := withSiteOperators(q), "generics site:go.dev"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRerank(t *testing.T) {
+	items := []ResultItem{
+		{Title: "Ten tips for Go generics", URL: "https://seo.example/tips"},
+		{Title: "Ten Tips for Go Generics - Mirror Site", URL: "https://mirror.example/tips"},
+		{Title: "Docs", URL: "https://other.example/docs"},
+		{Title: "Tutorial: Getting started with generics", URL: "https://go.dev/doc/tutorial/generics"},
+	}
+	got := resultURLs(Rerank(items, []string{"go.dev"}))
+	// go.dev moves up three places; the mirror's title repeats the first
+	// result's and is demoted to the end.
+	want := []string{
+		"https://seo.example/tips",
+		"https://go.dev/doc/tutorial/generics",
+		"https://other.example/docs",
+		"https://mirror.example/tips",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+}
+
+func TestProviderDomainFilters(t *testing.T) {
+	query := Query{Query: "q", Count: 3, IncludeDomains: []string{"go.dev"}, ExcludeDomains: []string{"blog.go.dev"}}
+	t.Run("site operators", func(t *testing.T) {
+		runUpstreamCases(t, func(endpoint string) Provider {
+			return NewBraveProvider(endpoint, "brave-key", time.Second)
+		}, []upstreamCase{{
+			name:  "query and post-filter",
+			query: query,
+			body: `{"web":{"results":[
+				{"title":"A","url":"https://go.dev/a"},
+				{"title":"B","url":"https://blog.go.dev/b"},
+				{"title":"C","url":"https://elsewhere.example/c"}]}}`,
+			check: func(t *testing.T, _ *http.Request, params url.Values, _ map[string]any) {
+				expectParam(t, params, "q", "q site:go.dev -site:blog.go.dev")
+			},
+			wantURLs: []string{"https://go.dev/a"},
+		}})
+	})
+	t.Run("native", func(t *testing.T) {
+		runUpstreamCases(t, func(endpoint string) Provider {
+			return NewTavilyProvider(endpoint, "tvly-key", time.Second)
+		}, []upstreamCase{{
+			name:  "include and exclude lists",
+			query: query,
+			body:  `{"results":[{"title":"A","url":"https://go.dev/a"}]}`,
+			check: func(t *testing.T, _ *http.Request, _ url.Values, payload map[string]any) {
+				if got := payload["include_domains"]; !reflect.DeepEqual(got, []any{"go.dev"}) {
+					t.Errorf("include_domains = %v", got)
+				}
+				if got := payload["exclude_domains"]; !reflect.DeepEqual(got, []any{"blog.go.dev"}) {
+					t.Errorf("exclude_domains = %v", got)
+				}
+			},
+			wantURLs: []string{"https://go.dev/a"},
+		}})
+	})
+}
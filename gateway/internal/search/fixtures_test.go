@@ -0,0 +1,343 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Recorded provider responses live in testdata/fixtures/<provider>/ and are
+// replayed through httptest. Each replay is compared against a golden file
+// holding the request the provider sent and the Response it produced, so a
+// change in either shows up in review. Refresh the golden files with
+//
+//	go test ./internal/search -run TestProviderFixtures -update
+var update = flag.Bool("update", false, "rewrite testdata/golden from the current output")
+
+// fixtureProviders builds each provider against a replay server. Endpoints
+// keep the real path so path-derived behaviour (Brave verticals) is covered.
+var fixtureProviders = map[string]func(baseURL string, timeout time.Duration) Provider{
+	ProviderDuckDuckGo: func(baseURL string, timeout time.Duration) Provider {
+		return NewDuckDuckGoProvider(baseURL+"/", timeout)
+	},
+	ProviderBrave: func(baseURL string, timeout time.Duration) Provider {
+		return NewBraveProvider(baseURL+"/res/v1/web/search", "brave-key", timeout)
+	},
+	ProviderSearxng: func(baseURL string, timeout time.Duration) Provider {
+		return NewSearxngProvider(baseURL, "", timeout)
+	},
+	ProviderSerpAPI: func(baseURL string, timeout time.Duration) Provider {
+		return NewSerpAPIProvider(baseURL+"/search.json", "serp-key", timeout)
+	},
+	ProviderTavily: func(baseURL string, timeout time.Duration) Provider {
+		return NewTavilyProvider(baseURL+"/search", "tvly-key", timeout)
+	},
+	ProviderBing: func(baseURL string, timeout time.Duration) Provider {
+		p := NewBingProvider(baseURL+"/v7.0/search", "bing-key", timeout)
+		p.now = func() time.Time { return fixedNow }
+		return p
+	},
+	ProviderGoogle: func(baseURL string, timeout time.Duration) Provider {
+		return NewGoogleProvider(baseURL+"/customsearch/v1", "google-key", "cx-id", timeout)
+	},
+	ProviderExa: func(baseURL string, timeout time.Duration) Provider {
+		p := NewExaProvider(baseURL+"/search", "exa-key", timeout)
+		p.now = func() time.Time { return fixedNow }
+		return p
+	},
+}
+
+// recordedRequest is the part of an upstream request worth pinning in a
+// golden file. Credentials are left out.
+type recordedRequest struct {
+	Method string              `json:"method"`
+	Path   string              `json:"path"`
+	Query  map[string][]string `json:"query,omitempty"`
+	Body   any                 `json:"body,omitempty"`
+}
+
+type replayResult struct {
+	Request  recordedRequest `json:"request"`
+	Response Response        `json:"response"`
+}
+
+// replay serves body with status for every request and records the last
+// request it saw.
+func replay(t *testing.T, status int, body []byte) (*httptest.Server, *recordedRequest) {
+	t.Helper()
+	seen := &recordedRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*seen = recordedRequest{Method: r.Method, Path: r.URL.Path}
+		query := r.URL.Query()
+		for _, secret := range []string{"api_key", "key"} {
+			query.Del(secret)
+		}
+		if len(query) > 0 {
+			seen.Query = query
+		}
+		if raw, _ := io.ReadAll(r.Body); len(raw) > 0 {
+			if err := json.Unmarshal(raw, &seen.Body); err != nil {
+				t.Errorf("request body is not JSON: %s", raw)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server, seen
+}
+
+func readFixture(t *testing.T, provider, name string) []byte {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", "fixtures", provider, name+".json"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	return raw
+}
+
+// assertGolden compares got, as indented JSON, with testdata/golden/<name>.json,
+// rewriting the file instead when -update is set.
+func assertGolden(t *testing.T, name string, got any) {
+	t.Helper()
+	raw, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	raw = append(raw, '\n')
+	path := filepath.Join("testdata", "golden", name+".json")
+	if *update {
+		if err := os.WriteFile(path, raw, 0o644); err != nil {
+			t.Fatalf("write golden: %v", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(raw, want) {
+		t.Errorf("output differs from %s (run with -update if the change is intended)\ngot:\n%s\nwant:\n%s", path, raw, want)
+	}
+}
+
+func TestProviderFixtures(t *testing.T) {
+	cases := []struct {
+		provider string
+		fixture  string
+		query    Query
+	}{
+		{ProviderDuckDuckGo, "instant_answer", Query{Query: "golang", Count: 5}},
+		{ProviderBrave, "web", Query{Query: "golang generics", Count: 3, Country: "us", Freshness: "pm"}},
+		{ProviderBrave, "news", Query{Query: "go 1.24 release", Count: 5, Type: TypeNews}},
+		{ProviderSearxng, "web", Query{Query: "sqlite wal mode", Count: 5, SafeSearch: SafeSearchStrict}},
+		{ProviderSerpAPI, "web", Query{Query: "kubernetes pod disruption budget", Count: 3, Country: "de", SearchLang: "en", Freshness: "py"}},
+		{ProviderSerpAPI, "news", Query{Query: "rust 2024 edition", Count: 5, Type: TypeNews}},
+		{ProviderTavily, "web", Query{Query: "postgres logical replication slots", Count: 5}},
+		{ProviderBing, "web", Query{Query: "terraform state locking", Count: 5, Country: "us", SearchLang: "en"}},
+		{ProviderGoogle, "web", Query{Query: "cache-control header", Count: 2, Offset: 10}},
+		{ProviderExa, "web", Query{Query: "llama 2 paper", Count: 5, Freshness: "py"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.provider+"/"+tc.fixture, func(t *testing.T) {
+			server, seen := replay(t, http.StatusOK, readFixture(t, tc.provider, tc.fixture))
+			res, err := fixtureProviders[tc.provider](server.URL, time.Second).Search(context.Background(), tc.query)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			assertGolden(t, tc.provider+"_"+tc.fixture, replayResult{Request: *seen, Response: res})
+		})
+	}
+}
+
+// SearXNG pages hold as many results as its engines return, so an offset that
+// is not a multiple of Count must still land on the right results. The
+// fixtures are three pages of four results each.
+func TestSearxngPagedFixture(t *testing.T) {
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pageno := r.URL.Query().Get("pageno")
+		pages = append(pages, pageno)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(readFixture(t, ProviderSearxng, "page"+pageno))
+	}))
+	defer server.Close()
+
+	res, err := NewSearxngProvider(server.URL, "", time.Second).Search(context.Background(), Query{Query: "sqlite wal mode", Count: 3, Offset: 6})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	assertGolden(t, "searxng_paged", struct {
+		Pages    []string `json:"pages"`
+		Response Response `json:"response"`
+	}{pages, res})
+}
+
+func TestProviderErrorPaths(t *testing.T) {
+	cases := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"rate limited", http.StatusTooManyRequests, `{"error":"rate limit exceeded"}`, ErrorTypeRateLimit},
+		{"server error", http.StatusInternalServerError, `internal error`, ErrorTypeUpstream5xx},
+		{"bad gateway", http.StatusBadGateway, ``, ErrorTypeUpstream5xx},
+		{"malformed json", http.StatusOK, `{"results": [`, ErrorTypeUnknown},
+	}
+	for name, newProvider := range fixtureProviders {
+		for _, tc := range cases {
+			t.Run(name+"/"+tc.name, func(t *testing.T) {
+				server, _ := replay(t, tc.status, []byte(tc.body))
+				_, err := newProvider(server.URL, time.Second).Search(context.Background(), Query{Query: "q"})
+				if got := ClassifyError(err); got != tc.want {
+					t.Errorf("error type = %q (%v), want %q", got, err, tc.want)
+				}
+			})
+		}
+
+		t.Run(name+"/timeout", func(t *testing.T) {
+			release := make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-release:
+				case <-r.Context().Done():
+				}
+			}))
+			defer server.Close()
+			defer close(release)
+			_, err := newProvider(server.URL, 50*time.Millisecond).Search(context.Background(), Query{Query: "q"})
+			if got := ClassifyError(err); got != ErrorTypeTimeout {
+				t.Errorf("error type = %q (%v), want %q", got, err, ErrorTypeTimeout)
+			}
+		})
+
+		t.Run(name+"/unreachable", func(t *testing.T) {
+			server := httptest.NewServer(http.NotFoundHandler())
+			server.Close()
+			_, err := newProvider(server.URL, time.Second).Search(context.Background(), Query{Query: "q"})
+			if got := ClassifyError(err); got != ErrorTypeNetwork {
+				t.Errorf("error type = %q (%v), want %q", got, err, ErrorTypeNetwork)
+			}
+		})
+	}
+}
+
+func TestCollectDuckTopics(t *testing.T) {
+	var payload duckResponse
+	if err := json.Unmarshal(readFixture(t, ProviderDuckDuckGo, "instant_answer"), &payload); err != nil {
+		t.Fatal(err)
+	}
+	var items []ResultItem
+	collectDuckTopics(&items, payload.RelatedTopics)
+	want := []ResultItem{
+		{Title: "Robert Griesemer", URL: "https://duckduckgo.com/Robert_Griesemer", Description: "Swiss computer scientist."},
+		{Title: "Erlang (programming language)", URL: "https://duckduckgo.com/Erlang_(programming_language)", Description: "A general-purpose, concurrent, functional programming language."},
+		{Title: "Limbo", URL: "https://duckduckgo.com/Limbo_(programming_language)", Description: "Limbo"},
+		{Title: "Robert Griesemer", URL: "https://duckduckgo.com/Robert_Griesemer", Description: "duplicate of the first topic."},
+	}
+	if len(items) != len(want) {
+		t.Fatalf("got %d items, want %d: %+v", len(items), len(want), items)
+	}
+	for i := range want {
+		if items[i] != want[i] {
+			t.Errorf("item[%d] = %+v, want %+v", i, items[i], want[i])
+		}
+	}
+}
+
+func TestSerpAPITbs(t *testing.T) {
+	cases := map[string]string{
+		"pd": "qdr:d", "day": "qdr:d", "D": "qdr:d",
+		"pw": "qdr:w", "week": "qdr:w",
+		"pm": "qdr:m", " month ": "qdr:m",
+		"py": "qdr:y", "y": "qdr:y",
+		"": "", "2025-01-01to2025-02-01": "", "fortnight": "",
+	}
+	for in, want := range cases {
+		if got := serpAPITbs(in); got != want {
+			t.Errorf("serpAPITbs(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "dial tcp: i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, ""},
+		{"typed wins over message", NewTypedError(ErrorTypeConfig, io.EOF), ErrorTypeConfig},
+		{"wrapped typed", fmt.Errorf("search failed: %w", NewTypedError(ErrorTypeRateLimit, nil)), ErrorTypeRateLimit},
+		{"typed without type", &TypedError{Err: io.EOF}, ErrorTypeUnknown},
+		{"deadline", context.DeadlineExceeded, ErrorTypeTimeout},
+		{"timeout message", errString("Client.Timeout exceeded while awaiting headers"), ErrorTypeTimeout},
+		{"refused", errString("dial tcp 127.0.0.1:1: connect: connection refused"), ErrorTypeNetwork},
+		{"dns", errString("lookup api.example: no such host"), ErrorTypeNetwork},
+		{"429 in message", errString("upstream said 429"), ErrorTypeRateLimit},
+		{"5xx in message", errString("brave http 503: unavailable"), ErrorTypeUpstream5xx},
+		{"other", io.ErrUnexpectedEOF, ErrorTypeUnknown},
+		{"request timeout", requestError("brave", timeoutError{}), ErrorTypeTimeout},
+		{"request failure", requestError("brave", errString("connection reset by peer")), ErrorTypeNetwork},
+	}
+	for _, tc := range cases {
+		if got := ClassifyError(tc.err); got != tc.want {
+			t.Errorf("%s: ClassifyError(%v) = %q, want %q", tc.name, tc.err, got, tc.want)
+		}
+	}
+}
+
+type errString string
+
+func (e errString) Error() string { return string(e) }
+
+func TestResolveProviderNamePrecedence(t *testing.T) {
+	registry := NewRegistryFromSettings(Settings{})
+	cases := []struct {
+		name  string
+		input ResolveInput
+		want  string
+	}{
+		{"requested is case-insensitive", ResolveInput{Requested: " Brave "}, ProviderBrave},
+		{"requested auto falls through", ResolveInput{Requested: "auto", Configured: "exa"}, ProviderExa},
+		{"unknown request falls through", ResolveInput{Requested: "yahoo", Configured: "bing"}, ProviderBing},
+		{"configured beats keys", ResolveInput{Configured: "tavily", BraveAPIKey: "k"}, ProviderTavily},
+		{"searxng before serpapi", ResolveInput{SearxngEndpoint: "http://searx", SerpAPIKey: "k"}, ProviderSearxng},
+		{"serpapi before tavily", ResolveInput{SerpAPIKey: "k", TavilyAPIKey: "k"}, ProviderSerpAPI},
+		{"blank keys are ignored", ResolveInput{BraveAPIKey: "  "}, ProviderDuckDuckGo},
+		{"exhausted falls back", ResolveInput{BraveAPIKey: "k", Exhausted: func(p string) bool { return p == ProviderBrave }}, ProviderDuckDuckGo},
+	}
+	for _, tc := range cases {
+		if got := ResolveProviderName(tc.input, registry); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	onlyPaid := NewRegistry(NewBraveProvider("", "", time.Second), NewExaProvider("", "", time.Second))
+	if got := ResolveProviderName(ResolveInput{}, onlyPaid); got != ProviderBrave {
+		t.Errorf("without duckduckgo the first registered provider should win, got %q", got)
+	}
+	if got := ResolveProviderName(ResolveInput{}, NewRegistry()); got != ProviderDuckDuckGo {
+		t.Errorf("empty registry = %q, want duckduckgo", got)
+	}
+	if got := ResolveProviderName(ResolveInput{Requested: "exa"}, onlyPaid); got != ProviderExa {
+		t.Errorf("requested provider in registry should be used, got %q", got)
+	}
+}
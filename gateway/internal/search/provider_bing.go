@@ -0,0 +1,260 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type BingProvider struct {
+	endpoint string
+	apiKey   string
+	client   *http.Client
+	now      func() time.Time
+}
+
+func NewBingProvider(endpoint, apiKey string, timeout time.Duration) *BingProvider {
+	if strings.TrimSpace(endpoint) == "" {
+		endpoint = "https://api.bing.microsoft.com/v7.0/search"
+	}
+	if timeout <= 0 {
+		timeout = 12 * time.Second
+	}
+	return &BingProvider{
+		endpoint: endpoint,
+		apiKey:   strings.TrimSpace(apiKey),
+		client:   &http.Client{Timeout: timeout},
+		now:      time.Now,
+	}
+}
+
+func (p *BingProvider) Name() string {
+	return ProviderBing
+}
+
+type bingOrganization struct {
+	Name string `json:"name"`
+}
+
+// bingResult is the union of web page, news article, image and video
+// answers; each vertical fills a subset.
+type bingResult struct {
+	Name            string             `json:"name"`
+	URL             string             `json:"url"`
+	Snippet         string             `json:"snippet"`
+	Description     string             `json:"description"`
+	DateLastCrawled string             `json:"dateLastCrawled"`
+	DatePublished   string             `json:"datePublished"`
+	SiteName        string             `json:"siteName"`
+	ContentURL      string             `json:"contentUrl"`
+	HostPageURL     string             `json:"hostPageUrl"`
+	ThumbnailURL    string             `json:"thumbnailUrl"`
+	Width           int                `json:"width"`
+	Height          int                `json:"height"`
+	Duration        string             `json:"duration"`
+	Provider        []bingOrganization `json:"provider"`
+	Publisher       []bingOrganization `json:"publisher"`
+	Image           struct {
+		Thumbnail struct {
+			ContentURL string `json:"contentUrl"`
+		} `json:"thumbnail"`
+	} `json:"image"`
+}
+
+// bingResponse holds web results under webPages and vertical results
+// (news/images/videos endpoints) under value.
+type bingResponse struct {
+	WebPages struct {
+		Value []bingResult `json:"value"`
+	} `json:"webPages"`
+	Value []bingResult `json:"value"`
+}
+
+// bingEndpoint derives the vertical endpoint from the web endpoint
+// (.../v7.0/search -> .../v7.0/news/search).
+func bingEndpoint(webEndpoint, searchType string) string {
+	if searchType == TypeWeb || !strings.HasSuffix(webEndpoint, "/search") {
+		return webEndpoint
+	}
+	return strings.TrimSuffix(webEndpoint, "/search") + "/" + searchType + "/search"
+}
+
+var bingSafeSearch = map[string]string{
+	SafeSearchOff:      "Off",
+	SafeSearchModerate: "Moderate",
+	SafeSearchStrict:   "Strict",
+}
+
+func (p *BingProvider) Search(ctx context.Context, query Query) (Response, error) {
+	if p.apiKey == "" {
+		return Response{}, NewTypedError(ErrorTypeConfig, fmt.Errorf("bing api key is missing"))
+	}
+
+	q := query.Normalize()
+	u, err := url.Parse(bingEndpoint(p.endpoint, q.Type))
+	if err != nil {
+		return Response{}, NewTypedError(ErrorTypeConfig, fmt.Errorf("invalid bing endpoint: %w", err))
+	}
+
+	params := u.Query()
+	params.Set("q", withSiteOperators(q))
+	params.Set("count", fmt.Sprintf("%d", q.Count))
+	if q.Offset > 0 {
+		params.Set("offset", fmt.Sprintf("%d", q.Offset))
+	}
+	if q.Type == TypeWeb {
+		params.Set("responseFilter", "Webpages")
+	}
+	if q.SafeSearch != "" {
+		params.Set("safeSearch", bingSafeSearch[q.SafeSearch])
+	}
+	var unsupported []string
+	// mkt needs both parts (e.g. en-US); otherwise fall back to cc/setLang.
+	if q.Country != "" && q.SearchLang != "" {
+		params.Set("mkt", q.SearchLang+"-"+strings.ToUpper(q.Country))
+	} else if q.Country != "" {
+		params.Set("cc", strings.ToUpper(q.Country))
+	}
+	if q.SearchLang != "" {
+		params.Set("setLang", q.SearchLang)
+	}
+	if freshness := bingFreshness(q.Freshness, p.now()); freshness != "" {
+		if q.Type != TypeWeb && strings.Contains(freshness, "..") {
+			// Date ranges are only accepted by the web endpoint.
+			unsupported = append(unsupported, "freshness")
+		} else {
+			params.Set("freshness", freshness)
+		}
+	}
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Response{}, NewTypedError(ErrorTypeUnknown, fmt.Errorf("create bing request failed: %w", err))
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Ocp-Apim-Subscription-Key", p.apiKey)
+	req.Header.Set("User-Agent", "next-ai-agent-gateway/0.1 (+web-search)")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return Response{}, requestError("bing", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 2048))
+		detail := strings.TrimSpace(string(body))
+		if detail == "" {
+			detail = res.Status
+		}
+		errorType := ErrorTypeUnknown
+		if res.StatusCode == http.StatusTooManyRequests {
+			errorType = ErrorTypeRateLimit
+		} else if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+			errorType = ErrorTypeConfig
+		} else if res.StatusCode >= 500 {
+			errorType = ErrorTypeUpstream5xx
+		}
+		return Response{}, NewTypedError(errorType, fmt.Errorf("bing http %d: %s", res.StatusCode, detail))
+	}
+
+	var payload bingResponse
+	if err := json.NewDecoder(res.Body).Decode(&payload); err != nil {
+		return Response{}, NewTypedError(ErrorTypeUnknown, fmt.Errorf("decode bing response failed: %w", err))
+	}
+
+	rows := payload.Value
+	if q.Type == TypeWeb {
+		rows = payload.WebPages.Value
+	}
+	results := make([]ResultItem, 0, q.Count)
+	for _, row := range rows {
+		title := strings.TrimSpace(row.Name)
+		link := strings.TrimSpace(row.URL)
+		if link == "" {
+			link = strings.TrimSpace(row.HostPageURL)
+		}
+		desc := strings.TrimSpace(row.Snippet)
+		if desc == "" {
+			desc = strings.TrimSpace(row.Description)
+		}
+		if title == "" && link == "" && desc == "" {
+			continue
+		}
+		published := strings.TrimSpace(row.DatePublished)
+		if published == "" {
+			published = strings.TrimSpace(row.DateLastCrawled)
+		}
+		siteName := strings.TrimSpace(row.SiteName)
+		if siteName == "" {
+			siteName = hostname(link)
+		}
+		item := ResultItem{
+			Title:       title,
+			URL:         link,
+			Description: desc,
+			Published:   published,
+			SiteName:    siteName,
+			Type:        q.Type,
+			Thumbnail:   strings.TrimSpace(row.ThumbnailURL),
+		}
+		switch q.Type {
+		case TypeNews:
+			item.Thumbnail = strings.TrimSpace(row.Image.Thumbnail.ContentURL)
+			if len(row.Provider) > 0 {
+				item.Source = strings.TrimSpace(row.Provider[0].Name)
+			}
+		case TypeImages:
+			item.ImageURL = strings.TrimSpace(row.ContentURL)
+			item.Width = row.Width
+			item.Height = row.Height
+			item.Source = siteName
+		case TypeVideos:
+			item.Duration = strings.TrimSpace(row.Duration)
+			if len(row.Publisher) > 0 {
+				item.Source = strings.TrimSpace(row.Publisher[0].Name)
+			}
+		}
+		results = append(results, item)
+		if len(results) >= q.Count {
+			break
+		}
+	}
+
+	out := Response{
+		Query:    q.Query,
+		Provider: ProviderBing,
+		Type:     q.Type,
+		Offset:   q.Offset,
+		Results:  finalizeResults(q, results, q.Count),
+	}
+	out.Total = len(out.Results)
+	if out.Total == 0 {
+		out.Note = emptyNote(q)
+	}
+	out.Note = joinNotes(out.Note, unsupportedNote(ProviderBing, unsupported))
+	return out, nil
+}
+
+// bingFreshness maps freshness to Bing's Day/Week/Month, or to an explicit
+// date range for a year since Bing has no "Year" value.
+func bingFreshness(freshness string, now time.Time) string {
+	switch strings.TrimSpace(strings.ToLower(freshness)) {
+	case "pd", "day", "d":
+		return "Day"
+	case "pw", "week", "w":
+		return "Week"
+	case "pm", "month", "m":
+		return "Month"
+	case "py", "year", "y":
+		return now.AddDate(-1, 0, 0).Format("2006-01-02") + ".." + now.Format("2006-01-02")
+	default:
+		return ""
+	}
+}
@@ -35,19 +35,52 @@ func (p *BraveProvider) Name() string {
 	return ProviderBrave
 }
 
+type braveThumbnail struct {
+	Src string `json:"src"`
+}
+
+type braveResult struct {
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	Description string `json:"description"`
+	Age         string `json:"age"`
+	PageAge     string `json:"page_age"`
+	Source      string `json:"source"`
+	MetaURL     struct {
+		Hostname string `json:"hostname"`
+	} `json:"meta_url"`
+	Thumbnail  braveThumbnail `json:"thumbnail"`
+	Properties struct {
+		URL    string `json:"url"`
+		Width  int    `json:"width"`
+		Height int    `json:"height"`
+	} `json:"properties"`
+	Video struct {
+		Duration  string `json:"duration"`
+		Publisher string `json:"publisher"`
+		Creator   string `json:"creator"`
+	} `json:"video"`
+}
+
+// braveResponse covers both the web endpoint (results under "web") and the
+// news/images/videos endpoints (results at the top level).
 type braveResponse struct {
 	Web struct {
-		Results []struct {
-			Title       string `json:"title"`
-			URL         string `json:"url"`
-			Description string `json:"description"`
-			Age         string `json:"age"`
-			PageAge     string `json:"page_age"`
-			MetaURL     struct {
-				Hostname string `json:"hostname"`
-			} `json:"meta_url"`
-		} `json:"results"`
+		Results []braveResult `json:"results"`
 	} `json:"web"`
+	Results []braveResult `json:"results"`
+}
+
+// braveEndpoint derives the vertical endpoint from the configured web
+// search endpoint (.../web/search -> .../news/search).
+func braveEndpoint(webEndpoint, searchType string) string {
+	if searchType == TypeWeb {
+		return webEndpoint
+	}
+	if i := strings.LastIndex(webEndpoint, "/web/search"); i >= 0 {
+		return webEndpoint[:i] + "/" + searchType + "/search" + webEndpoint[i+len("/web/search"):]
+	}
+	return webEndpoint
 }
 
 func (p *BraveProvider) Search(ctx context.Context, query Query) (Response, error) {
@@ -56,14 +89,26 @@ func (p *BraveProvider) Search(ctx context.Context, query Query) (Response, erro
 	}
 
 	q := query.Normalize()
-	u, err := url.Parse(p.endpoint)
+	u, err := url.Parse(braveEndpoint(p.endpoint, q.Type))
 	if err != nil {
 		return Response{}, NewTypedError(ErrorTypeConfig, fmt.Errorf("invalid brave endpoint: %w", err))
 	}
 
+	var unsupported []string
 	params := u.Query()
-	params.Set("q", q.Query)
+	params.Set("q", withSiteOperators(q))
 	params.Set("count", fmt.Sprintf("%d", q.Count))
+	// Brave's offset counts pages of size count; a misaligned Offset is
+	// rounded down to the page boundary and the remainder skipped locally.
+	skip := 0
+	if q.Offset > 0 {
+		if q.Type == TypeImages {
+			unsupported = append(unsupported, "offset")
+		} else {
+			params.Set("offset", fmt.Sprintf("%d", q.Offset/q.Count))
+			skip = q.Offset % q.Count
+		}
+	}
 	if q.Country != "" {
 		params.Set("country", q.Country)
 	}
@@ -71,7 +116,19 @@ func (p *BraveProvider) Search(ctx context.Context, query Query) (Response, erro
 		params.Set("search_lang", q.SearchLang)
 	}
 	if q.Freshness != "" {
-		params.Set("freshness", q.Freshness)
+		if q.Type == TypeImages {
+			unsupported = append(unsupported, "freshness")
+		} else {
+			params.Set("freshness", q.Freshness)
+		}
+	}
+	if q.SafeSearch != "" {
+		safe := q.SafeSearch
+		if q.Type == TypeImages && safe == SafeSearchModerate {
+			// The image endpoint only knows off and strict.
+			safe = SafeSearchStrict
+		}
+		params.Set("safesearch", safe)
 	}
 	u.RawQuery = params.Encode()
 
@@ -85,7 +142,7 @@ func (p *BraveProvider) Search(ctx context.Context, query Query) (Response, erro
 
 	res, err := p.client.Do(req)
 	if err != nil {
-		return Response{}, NewTypedError(ErrorTypeNetwork, fmt.Errorf("brave request failed: %w", err))
+		return Response{}, requestError("brave", err)
 	}
 	defer res.Body.Close()
 
@@ -109,8 +166,12 @@ func (p *BraveProvider) Search(ctx context.Context, query Query) (Response, erro
 		return Response{}, NewTypedError(ErrorTypeUnknown, fmt.Errorf("decode brave response failed: %w", err))
 	}
 
+	rows := payload.Results
+	if q.Type == TypeWeb {
+		rows = payload.Web.Results
+	}
 	results := make([]ResultItem, 0, q.Count)
-	for _, row := range payload.Web.Results {
+	for _, row := range rows {
 		title := strings.TrimSpace(row.Title)
 		link := strings.TrimSpace(row.URL)
 		desc := strings.TrimSpace(row.Description)
@@ -121,14 +182,32 @@ func (p *BraveProvider) Search(ctx context.Context, query Query) (Response, erro
 		if published == "" {
 			published = strings.TrimSpace(row.Age)
 		}
-		results = append(results, ResultItem{
+		item := ResultItem{
 			Title:       title,
 			URL:         link,
 			Description: desc,
 			Published:   published,
 			SiteName:    strings.TrimSpace(row.MetaURL.Hostname),
-		})
-		if len(results) >= q.Count {
+			Type:        q.Type,
+			Thumbnail:   strings.TrimSpace(row.Thumbnail.Src),
+		}
+		switch q.Type {
+		case TypeImages:
+			item.Source = strings.TrimSpace(row.Source)
+			item.ImageURL = strings.TrimSpace(row.Properties.URL)
+			item.Width = row.Properties.Width
+			item.Height = row.Properties.Height
+		case TypeVideos:
+			item.Source = strings.TrimSpace(row.Video.Publisher)
+			if item.Source == "" {
+				item.Source = strings.TrimSpace(row.Video.Creator)
+			}
+			item.Duration = strings.TrimSpace(row.Video.Duration)
+		case TypeNews:
+			item.Source = item.SiteName
+		}
+		results = append(results, item)
+		if len(results) >= skip+q.Count {
 			break
 		}
 	}
@@ -136,11 +215,14 @@ func (p *BraveProvider) Search(ctx context.Context, query Query) (Response, erro
 	out := Response{
 		Query:    q.Query,
 		Provider: ProviderBrave,
-		Results:  uniqueByURL(results, q.Count),
+		Type:     q.Type,
+		Offset:   q.Offset,
+		Results:  skipResults(finalizeResults(q, results, skip+q.Count), skip),
 	}
 	out.Total = len(out.Results)
 	if out.Total == 0 {
-		out.Note = "No public web results found"
+		out.Note = emptyNote(q)
 	}
+	out.Note = joinNotes(out.Note, unsupportedNote(ProviderBrave, unsupported))
 	return out, nil
 }
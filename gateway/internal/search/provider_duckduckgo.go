@@ -57,6 +57,20 @@ func (p *DuckDuckGoProvider) Search(ctx context.Context, query Query) (Response,
 	params.Set("format", "json")
 	params.Set("no_html", "1")
 	params.Set("skip_disambig", "1")
+	var unsupported []string
+	switch q.SafeSearch {
+	case SafeSearchOff:
+		params.Set("kp", "-2")
+	case SafeSearchModerate:
+		params.Set("kp", "-1")
+	case SafeSearchStrict:
+		params.Set("kp", "1")
+	}
+	// The Instant Answer API has no paging or verticals: Offset is applied
+	// locally and other types fall back to web results.
+	if q.Type != TypeWeb {
+		unsupported = append(unsupported, "type="+q.Type)
+	}
 	u.RawQuery = params.Encode()
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
@@ -67,7 +81,7 @@ func (p *DuckDuckGoProvider) Search(ctx context.Context, query Query) (Response,
 
 	res, err := p.client.Do(req)
 	if err != nil {
-		return Response{}, NewTypedError(ErrorTypeNetwork, fmt.Errorf("duckduckgo request failed: %w", err))
+		return Response{}, requestError("duckduckgo", err)
 	}
 	defer res.Body.Close()
 
@@ -104,17 +118,20 @@ func (p *DuckDuckGoProvider) Search(ctx context.Context, query Query) (Response,
 		})
 	}
 	collectDuckTopics(&items, payload.RelatedTopics)
-	items = uniqueByURL(items, q.Count)
+	items = skipResults(finalizeResults(q, items, q.Offset+q.Count), q.Offset)
 
 	out := Response{
 		Query:    q.Query,
 		Provider: ProviderDuckDuckGo,
+		Type:     TypeWeb,
+		Offset:   q.Offset,
 		Results:  items,
 		Total:    len(items),
 	}
 	if len(items) == 0 {
-		out.Note = "No public web results found"
+		out.Note = emptyNote(q)
 	}
+	out.Note = joinNotes(out.Note, unsupportedNote(ProviderDuckDuckGo, unsupported))
 	return out, nil
 }
 
@@ -178,6 +195,16 @@ func uniqueByURL(items []ResultItem, limit int) []ResultItem {
 	return out
 }
 
+// hostname returns the host of rawURL without a leading "www.", for
+// providers that do not report a site name.
+func hostname(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
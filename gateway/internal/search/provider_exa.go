@@ -0,0 +1,208 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type ExaProvider struct {
+	endpoint string
+	apiKey   string
+	client   *http.Client
+	now      func() time.Time
+}
+
+func NewExaProvider(endpoint, apiKey string, timeout time.Duration) *ExaProvider {
+	if strings.TrimSpace(endpoint) == "" {
+		endpoint = "https://api.exa.ai/search"
+	}
+	if timeout <= 0 {
+		timeout = 12 * time.Second
+	}
+	return &ExaProvider{
+		endpoint: endpoint,
+		apiKey:   strings.TrimSpace(apiKey),
+		client:   &http.Client{Timeout: timeout},
+		now:      time.Now,
+	}
+}
+
+func (p *ExaProvider) Name() string {
+	return ProviderExa
+}
+
+type exaRequest struct {
+	Query              string      `json:"query"`
+	NumResults         int         `json:"numResults"`
+	Type               string      `json:"type"`
+	Category           string      `json:"category,omitempty"`
+	UserLocation       string      `json:"userLocation,omitempty"`
+	StartPublishedDate string      `json:"startPublishedDate,omitempty"`
+	IncludeDomains     []string    `json:"includeDomains,omitempty"`
+	ExcludeDomains     []string    `json:"excludeDomains,omitempty"`
+	Contents           exaContents `json:"contents"`
+}
+
+type exaContents struct {
+	Text struct {
+		MaxCharacters int `json:"maxCharacters"`
+	} `json:"text"`
+}
+
+type exaResponse struct {
+	Results []struct {
+		Title         string `json:"title"`
+		URL           string `json:"url"`
+		PublishedDate string `json:"publishedDate"`
+		Author        string `json:"author"`
+		Text          string `json:"text"`
+	} `json:"results"`
+}
+
+// Search maps Query onto Exa's /search API. Exa ranks semantically and has
+// no language, safe-search or paging parameters: SearchLang and SafeSearch
+// are reported as ignored and Offset is emulated by over-fetching. Country
+// becomes the userLocation hint, Freshness a startPublishedDate and the news
+// type the "news" category; images and videos are not available.
+func (p *ExaProvider) Search(ctx context.Context, query Query) (Response, error) {
+	if p.apiKey == "" {
+		return Response{}, NewTypedError(ErrorTypeConfig, fmt.Errorf("exa api key is missing"))
+	}
+
+	q := query.Normalize()
+	var unsupported []string
+	fetch, ok := localWindow(q, exaMaxResults)
+	offset := q.Offset
+	if !ok {
+		unsupported = append(unsupported, "offset")
+		offset = 0
+	}
+	resultType := TypeWeb
+	switch q.Type {
+	case TypeNews:
+		resultType = TypeNews
+	case TypeImages, TypeVideos:
+		unsupported = append(unsupported, "type="+q.Type)
+	}
+	if q.SearchLang != "" {
+		unsupported = append(unsupported, "search_lang")
+	}
+	if q.SafeSearch != "" {
+		unsupported = append(unsupported, "safesearch")
+	}
+	payload := exaRequest{
+		Query:              q.Query,
+		NumResults:         fetch,
+		Type:               "auto",
+		UserLocation:       strings.ToUpper(q.Country),
+		StartPublishedDate: exaStartPublishedDate(q.Freshness, p.now()),
+		IncludeDomains:     q.IncludeDomains,
+		ExcludeDomains:     q.ExcludeDomains,
+	}
+	if resultType == TypeNews {
+		payload.Category = "news"
+	}
+	payload.Contents.Text.MaxCharacters = 500
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Response{}, NewTypedError(ErrorTypeUnknown, fmt.Errorf("encode exa request failed: %w", err))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return Response{}, NewTypedError(ErrorTypeConfig, fmt.Errorf("create exa request failed: %w", err))
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", p.apiKey)
+	req.Header.Set("User-Agent", "next-ai-agent-gateway/0.1 (+web-search)")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return Response{}, requestError("exa", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 2048))
+		detail := strings.TrimSpace(string(body))
+		if detail == "" {
+			detail = res.Status
+		}
+		errorType := ErrorTypeUnknown
+		if res.StatusCode == http.StatusTooManyRequests {
+			errorType = ErrorTypeRateLimit
+		} else if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+			errorType = ErrorTypeConfig
+		} else if res.StatusCode >= 500 {
+			errorType = ErrorTypeUpstream5xx
+		}
+		return Response{}, NewTypedError(errorType, fmt.Errorf("exa http %d: %s", res.StatusCode, detail))
+	}
+
+	var decoded exaResponse
+	if err := json.NewDecoder(res.Body).Decode(&decoded); err != nil {
+		return Response{}, NewTypedError(ErrorTypeUnknown, fmt.Errorf("decode exa response failed: %w", err))
+	}
+
+	results := make([]ResultItem, 0, fetch)
+	for _, row := range decoded.Results {
+		title := strings.TrimSpace(row.Title)
+		link := strings.TrimSpace(row.URL)
+		desc := strings.Join(strings.Fields(row.Text), " ")
+		if title == "" && link == "" && desc == "" {
+			continue
+		}
+		results = append(results, ResultItem{
+			Title:       title,
+			URL:         link,
+			Description: desc,
+			Published:   strings.TrimSpace(row.PublishedDate),
+			SiteName:    hostname(link),
+			Type:        resultType,
+		})
+		if len(results) >= fetch {
+			break
+		}
+	}
+
+	out := Response{
+		Query:    q.Query,
+		Provider: ProviderExa,
+		Type:     resultType,
+		Offset:   offset,
+		Results:  skipResults(finalizeResults(q, results, offset+q.Count), offset),
+	}
+	out.Total = len(out.Results)
+	if out.Total == 0 {
+		out.Note = emptyNote(q)
+	}
+	out.Note = joinNotes(out.Note, unsupportedNote(ProviderExa, unsupported))
+	return out, nil
+}
+
+// exaMaxResults is the per-request cap on numResults.
+const exaMaxResults = 100
+
+func exaStartPublishedDate(freshness string, now time.Time) string {
+	var start time.Time
+	switch strings.TrimSpace(strings.ToLower(freshness)) {
+	case "pd", "day", "d":
+		start = now.AddDate(0, 0, -1)
+	case "pw", "week", "w":
+		start = now.AddDate(0, 0, -7)
+	case "pm", "month", "m":
+		start = now.AddDate(0, -1, 0)
+	case "py", "year", "y":
+		start = now.AddDate(-1, 0, 0)
+	default:
+		return ""
+	}
+	return start.UTC().Format(time.RFC3339)
+}
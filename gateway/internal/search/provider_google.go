@@ -0,0 +1,223 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GoogleProvider queries the Google Custom Search JSON API (Programmable
+// Search Engine), which needs both an API key and a search engine ID (cx).
+type GoogleProvider struct {
+	endpoint string
+	apiKey   string
+	engineID string
+	client   *http.Client
+}
+
+func NewGoogleProvider(endpoint, apiKey, engineID string, timeout time.Duration) *GoogleProvider {
+	if strings.TrimSpace(endpoint) == "" {
+		endpoint = "https://www.googleapis.com/customsearch/v1"
+	}
+	if timeout <= 0 {
+		timeout = 12 * time.Second
+	}
+	return &GoogleProvider{
+		endpoint: endpoint,
+		apiKey:   strings.TrimSpace(apiKey),
+		engineID: strings.TrimSpace(engineID),
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+func (p *GoogleProvider) Name() string {
+	return ProviderGoogle
+}
+
+type googleResponse struct {
+	Items []struct {
+		Title       string `json:"title"`
+		Link        string `json:"link"`
+		Snippet     string `json:"snippet"`
+		DisplayLink string `json:"displayLink"`
+		Image       struct {
+			ContextLink   string `json:"contextLink"`
+			ThumbnailLink string `json:"thumbnailLink"`
+			Width         int    `json:"width"`
+			Height        int    `json:"height"`
+		} `json:"image"`
+		Pagemap struct {
+			Metatags []map[string]string `json:"metatags"`
+		} `json:"pagemap"`
+	} `json:"items"`
+}
+
+func (p *GoogleProvider) Search(ctx context.Context, query Query) (Response, error) {
+	if p.apiKey == "" {
+		return Response{}, NewTypedError(ErrorTypeConfig, fmt.Errorf("google api key is missing"))
+	}
+	if p.engineID == "" {
+		return Response{}, NewTypedError(ErrorTypeConfig, fmt.Errorf("google search engine id (cx) is missing"))
+	}
+
+	q := query.Normalize()
+	u, err := url.Parse(p.endpoint)
+	if err != nil {
+		return Response{}, NewTypedError(ErrorTypeConfig, fmt.Errorf("invalid google endpoint: %w", err))
+	}
+
+	params := u.Query()
+	params.Set("q", withSiteOperators(q))
+	params.Set("key", p.apiKey)
+	params.Set("cx", p.engineID)
+	params.Set("num", fmt.Sprintf("%d", q.Count))
+	var unsupported []string
+	offset := q.Offset
+	// start is 1-based and the API serves at most the first 100 results.
+	if offset > 0 && offset+q.Count <= 100 {
+		params.Set("start", fmt.Sprintf("%d", offset+1))
+	} else if offset > 0 {
+		unsupported = append(unsupported, "offset")
+		offset = 0
+	}
+	resultType := q.Type
+	switch q.Type {
+	case TypeImages:
+		params.Set("searchType", "image")
+	case TypeNews, TypeVideos:
+		unsupported = append(unsupported, "type="+q.Type)
+		resultType = TypeWeb
+	}
+	switch q.SafeSearch {
+	case SafeSearchOff:
+		params.Set("safe", "off")
+	case SafeSearchModerate, SafeSearchStrict:
+		params.Set("safe", "active")
+	}
+	if q.Country != "" {
+		params.Set("gl", q.Country)
+	}
+	if q.SearchLang != "" {
+		params.Set("hl", q.SearchLang)
+		params.Set("lr", "lang_"+q.SearchLang)
+	}
+	if restrict := googleDateRestrict(q.Freshness); restrict != "" {
+		params.Set("dateRestrict", restrict)
+	}
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Response{}, NewTypedError(ErrorTypeUnknown, fmt.Errorf("create google request failed: %w", err))
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "next-ai-agent-gateway/0.1 (+web-search)")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return Response{}, requestError("google", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 2048))
+		detail := strings.TrimSpace(string(body))
+		if detail == "" {
+			detail = res.Status
+		}
+		errorType := ErrorTypeUnknown
+		lowered := strings.ToLower(detail)
+		if res.StatusCode == http.StatusTooManyRequests ||
+			(res.StatusCode == http.StatusForbidden && (strings.Contains(lowered, "ratelimitexceeded") || strings.Contains(lowered, "quota"))) {
+			// Google reports exhausted daily quota as 403.
+			errorType = ErrorTypeRateLimit
+		} else if res.StatusCode == http.StatusBadRequest || res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+			// 400 is returned for an invalid key or cx.
+			errorType = ErrorTypeConfig
+		} else if res.StatusCode >= 500 {
+			errorType = ErrorTypeUpstream5xx
+		}
+		return Response{}, NewTypedError(errorType, fmt.Errorf("google http %d: %s", res.StatusCode, detail))
+	}
+
+	var payload googleResponse
+	if err := json.NewDecoder(res.Body).Decode(&payload); err != nil {
+		return Response{}, NewTypedError(ErrorTypeUnknown, fmt.Errorf("decode google response failed: %w", err))
+	}
+
+	results := make([]ResultItem, 0, q.Count)
+	for _, row := range payload.Items {
+		title := strings.TrimSpace(row.Title)
+		link := strings.TrimSpace(row.Link)
+		desc := strings.TrimSpace(row.Snippet)
+		if title == "" && link == "" && desc == "" {
+			continue
+		}
+		var published, siteName string
+		if len(row.Pagemap.Metatags) > 0 {
+			meta := row.Pagemap.Metatags[0]
+			published = strings.TrimSpace(meta["article:published_time"])
+			siteName = strings.TrimSpace(meta["og:site_name"])
+		}
+		if siteName == "" {
+			siteName = strings.TrimPrefix(strings.TrimSpace(row.DisplayLink), "www.")
+		}
+		item := ResultItem{
+			Title:       title,
+			URL:         link,
+			Description: desc,
+			Published:   published,
+			SiteName:    siteName,
+			Type:        resultType,
+		}
+		if resultType == TypeImages {
+			// For image search, link is the image itself.
+			item.ImageURL = link
+			if page := strings.TrimSpace(row.Image.ContextLink); page != "" {
+				item.URL = page
+			}
+			item.Thumbnail = strings.TrimSpace(row.Image.ThumbnailLink)
+			item.Width = row.Image.Width
+			item.Height = row.Image.Height
+			item.Source = siteName
+		}
+		results = append(results, item)
+		if len(results) >= q.Count {
+			break
+		}
+	}
+
+	out := Response{
+		Query:    q.Query,
+		Provider: ProviderGoogle,
+		Type:     resultType,
+		Offset:   offset,
+		Results:  finalizeResults(q, results, q.Count),
+	}
+	out.Total = len(out.Results)
+	if out.Total == 0 {
+		out.Note = emptyNote(q)
+	}
+	out.Note = joinNotes(out.Note, unsupportedNote(ProviderGoogle, unsupported))
+	return out, nil
+}
+
+func googleDateRestrict(freshness string) string {
+	switch strings.TrimSpace(strings.ToLower(freshness)) {
+	case "pd", "day", "d":
+		return "d1"
+	case "pw", "week", "w":
+		return "w1"
+	case "pm", "month", "m":
+		return "m1"
+	case "py", "year", "y":
+		return "y1"
+	default:
+		return ""
+	}
+}
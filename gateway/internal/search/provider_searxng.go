@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -39,27 +40,131 @@ type searxngResponse struct {
 		Content       string `json:"content"`
 		PublishedDate string `json:"publishedDate"`
 		Engine        string `json:"engine"`
+		ImgSrc        string `json:"img_src"`
+		ThumbnailSrc  string `json:"thumbnail_src"`
+		Thumbnail     string `json:"thumbnail"`
+		Resolution    string `json:"resolution"`
+		Source        string `json:"source"`
+		Author        string `json:"author"`
+		Length        string `json:"length"`
 	} `json:"results"`
 }
 
+var searxngCategories = map[string]string{
+	TypeWeb:    "general",
+	TypeNews:   "news",
+	TypeImages: "images",
+	TypeVideos: "videos",
+}
+
+var searxngSafeSearch = map[string]string{
+	SafeSearchOff:      "0",
+	SafeSearchModerate: "1",
+	SafeSearchStrict:   "2",
+}
+
+// parseResolution reads SearXNG's "1920 x 1080" image resolution.
+func parseResolution(resolution string) (int, int) {
+	var width, height int
+	if _, err := fmt.Sscanf(strings.ReplaceAll(resolution, " ", ""), "%dx%d", &width, &height); err != nil {
+		return 0, 0
+	}
+	return width, height
+}
+
+// searxngMaxRequests bounds the pages fetched for one search.
+const searxngMaxRequests = 4
+
+// Search reads the window [Offset, Offset+Count). SearXNG's page size is set
+// by its engines rather than by the caller, so the first page is fetched to
+// learn it; the window is then read from the pages that hold it and cut
+// locally.
 func (p *SearxngProvider) Search(ctx context.Context, query Query) (Response, error) {
 	if p.endpoint == "" {
 		return Response{}, NewTypedError(ErrorTypeConfig, fmt.Errorf("searxng endpoint is missing"))
 	}
-
 	q := query.Normalize()
+
+	first, pageSize, err := p.fetchPage(ctx, q, 1)
+	if err != nil {
+		return Response{}, err
+	}
+	requests := 1
+	pageno, skip := 1, q.Offset
+	window := first
+	if pageSize > 0 && q.Offset >= pageSize {
+		pageno, skip = q.Offset/pageSize+1, q.Offset%pageSize
+		if window, _, err = p.fetchPage(ctx, q, pageno); err != nil {
+			return Response{}, err
+		}
+		requests++
+	}
+	// Whole pages are kept until enough survive the domain filters; the
+	// window is cut only after finalizeResults.
+	for pageSize > 0 && len(finalizeResults(q, window, skip+q.Count)) < skip+q.Count && requests < searxngMaxRequests {
+		pageno++
+		next, _, err := p.fetchPage(ctx, q, pageno)
+		if err != nil {
+			return Response{}, err
+		}
+		requests++
+		// Stop at the last page, or on an instance that ignores pageno.
+		if !addsNewURLs(window, next) {
+			break
+		}
+		window = append(window, next...)
+	}
+
+	out := Response{
+		Query:    q.Query,
+		Provider: ProviderSearxng,
+		Type:     q.Type,
+		Offset:   q.Offset,
+		Results:  skipResults(finalizeResults(q, window, skip+q.Count), skip),
+	}
+	out.Total = len(out.Results)
+	if out.Total == 0 {
+		out.Note = emptyNote(q)
+	}
+	return out, nil
+}
+
+// addsNewURLs reports whether next holds a result whose URL is not in have.
+func addsNewURLs(have, next []ResultItem) bool {
+	seen := make(map[string]bool, len(have))
+	for _, item := range have {
+		seen[item.URL] = true
+	}
+	for _, item := range next {
+		if !seen[item.URL] {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchPage requests one SearXNG results page. It returns the usable items
+// and the number of rows on the page, empty ones included.
+func (p *SearxngProvider) fetchPage(ctx context.Context, q Query, pageno int) ([]ResultItem, int, error) {
 	u, err := url.Parse(p.endpoint)
 	if err != nil {
-		return Response{}, NewTypedError(ErrorTypeConfig, fmt.Errorf("invalid searxng endpoint: %w", err))
+		return nil, 0, NewTypedError(ErrorTypeConfig, fmt.Errorf("invalid searxng endpoint: %w", err))
 	}
 	if strings.TrimSpace(u.Path) == "" || strings.TrimSpace(u.Path) == "/" {
 		u.Path = "/search"
 	}
 
 	params := u.Query()
-	params.Set("q", q.Query)
+	params.Set("q", withSiteOperators(q))
 	params.Set("format", "json")
-	params.Set("pageno", "1")
+	params.Set("pageno", strconv.Itoa(pageno))
+	params.Set("categories", searxngCategories[q.Type])
+	if q.SafeSearch != "" {
+		params.Set("safesearch", searxngSafeSearch[q.SafeSearch])
+	}
+	if timeRange := freshnessWindow(q.Freshness); timeRange != "" {
+		params.Set("time_range", timeRange)
+	}
 	if q.SearchLang != "" {
 		params.Set("language", q.SearchLang)
 	}
@@ -70,7 +175,7 @@ func (p *SearxngProvider) Search(ctx context.Context, query Query) (Response, er
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
-		return Response{}, NewTypedError(ErrorTypeUnknown, fmt.Errorf("create searxng request failed: %w", err))
+		return nil, 0, NewTypedError(ErrorTypeUnknown, fmt.Errorf("create searxng request failed: %w", err))
 	}
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("User-Agent", "next-ai-agent-gateway/0.1 (+web-search)")
@@ -80,7 +185,7 @@ func (p *SearxngProvider) Search(ctx context.Context, query Query) (Response, er
 
 	res, err := p.client.Do(req)
 	if err != nil {
-		return Response{}, NewTypedError(ErrorTypeNetwork, fmt.Errorf("searxng request failed: %w", err))
+		return nil, 0, requestError("searxng", err)
 	}
 	defer res.Body.Close()
 
@@ -96,15 +201,15 @@ func (p *SearxngProvider) Search(ctx context.Context, query Query) (Response, er
 		} else if res.StatusCode >= 500 {
 			errorType = ErrorTypeUpstream5xx
 		}
-		return Response{}, NewTypedError(errorType, fmt.Errorf("searxng http %d: %s", res.StatusCode, detail))
+		return nil, 0, NewTypedError(errorType, fmt.Errorf("searxng http %d: %s", res.StatusCode, detail))
 	}
 
 	var payload searxngResponse
 	if err := json.NewDecoder(res.Body).Decode(&payload); err != nil {
-		return Response{}, NewTypedError(ErrorTypeUnknown, fmt.Errorf("decode searxng response failed: %w", err))
+		return nil, 0, NewTypedError(ErrorTypeUnknown, fmt.Errorf("decode searxng response failed: %w", err))
 	}
 
-	results := make([]ResultItem, 0, q.Count)
+	results := make([]ResultItem, 0, len(payload.Results))
 	for _, row := range payload.Results {
 		title := strings.TrimSpace(row.Title)
 		link := strings.TrimSpace(row.URL)
@@ -112,26 +217,30 @@ func (p *SearxngProvider) Search(ctx context.Context, query Query) (Response, er
 		if title == "" && link == "" && desc == "" {
 			continue
 		}
-		results = append(results, ResultItem{
+		item := ResultItem{
 			Title:       title,
 			URL:         link,
 			Description: desc,
 			Published:   strings.TrimSpace(row.PublishedDate),
 			SiteName:    strings.TrimSpace(row.Engine),
-		})
-		if len(results) >= q.Count {
-			break
+			Type:        q.Type,
+			Source:      strings.TrimSpace(row.Source),
 		}
+		switch q.Type {
+		case TypeImages:
+			item.ImageURL = strings.TrimSpace(row.ImgSrc)
+			item.Thumbnail = strings.TrimSpace(row.ThumbnailSrc)
+			item.Width, item.Height = parseResolution(row.Resolution)
+		case TypeVideos:
+			item.Thumbnail = strings.TrimSpace(row.Thumbnail)
+			item.Duration = strings.TrimSpace(row.Length)
+			if item.Source == "" {
+				item.Source = strings.TrimSpace(row.Author)
+			}
+		default:
+			item.Thumbnail = strings.TrimSpace(row.Thumbnail)
+		}
+		results = append(results, item)
 	}
-
-	out := Response{
-		Query:    q.Query,
-		Provider: ProviderSearxng,
-		Results:  uniqueByURL(results, q.Count),
-	}
-	out.Total = len(out.Results)
-	if out.Total == 0 {
-		out.Note = "No public web results found"
-	}
-	return out, nil
+	return results, len(payload.Results), nil
 }
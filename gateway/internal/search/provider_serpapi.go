@@ -35,14 +35,31 @@ func (p *SerpAPIProvider) Name() string {
 	return ProviderSerpAPI
 }
 
+type serpAPIResult struct {
+	Title          string `json:"title"`
+	Link           string `json:"link"`
+	Snippet        string `json:"snippet"`
+	Date           string `json:"date"`
+	Source         string `json:"source"`
+	Thumbnail      string `json:"thumbnail"`
+	Original       string `json:"original"`
+	OriginalWidth  int    `json:"original_width"`
+	OriginalHeight int    `json:"original_height"`
+	Duration       string `json:"duration"`
+}
+
 type serpAPIResponse struct {
-	OrganicResults []struct {
-		Title   string `json:"title"`
-		Link    string `json:"link"`
-		Snippet string `json:"snippet"`
-		Date    string `json:"date"`
-		Source  string `json:"source"`
-	} `json:"organic_results"`
+	OrganicResults []serpAPIResult `json:"organic_results"`
+	NewsResults    []serpAPIResult `json:"news_results"`
+	ImagesResults  []serpAPIResult `json:"images_results"`
+	VideoResults   []serpAPIResult `json:"video_results"`
+}
+
+// serpAPITbm selects Google's vertical for each result type.
+var serpAPITbm = map[string]string{
+	TypeNews:   "nws",
+	TypeImages: "isch",
+	TypeVideos: "vid",
 }
 
 func (p *SerpAPIProvider) Search(ctx context.Context, query Query) (Response, error) {
@@ -57,9 +74,22 @@ func (p *SerpAPIProvider) Search(ctx context.Context, query Query) (Response, er
 	}
 
 	params := u.Query()
-	params.Set("q", q.Query)
+	params.Set("q", withSiteOperators(q))
 	params.Set("api_key", p.apiKey)
 	params.Set("num", fmt.Sprintf("%d", q.Count))
+	if q.Offset > 0 {
+		params.Set("start", fmt.Sprintf("%d", q.Offset))
+	}
+	if tbm := serpAPITbm[q.Type]; tbm != "" {
+		params.Set("tbm", tbm)
+	}
+	switch q.SafeSearch {
+	case SafeSearchOff:
+		params.Set("safe", "off")
+	case SafeSearchModerate, SafeSearchStrict:
+		// Google only distinguishes on and off.
+		params.Set("safe", "active")
+	}
 	if q.Country != "" {
 		params.Set("gl", q.Country)
 	}
@@ -82,7 +112,7 @@ func (p *SerpAPIProvider) Search(ctx context.Context, query Query) (Response, er
 
 	res, err := p.client.Do(req)
 	if err != nil {
-		return Response{}, NewTypedError(ErrorTypeNetwork, fmt.Errorf("serpapi request failed: %w", err))
+		return Response{}, requestError("serpapi", err)
 	}
 	defer res.Body.Close()
 
@@ -106,21 +136,42 @@ func (p *SerpAPIProvider) Search(ctx context.Context, query Query) (Response, er
 		return Response{}, NewTypedError(ErrorTypeUnknown, fmt.Errorf("decode serpapi response failed: %w", err))
 	}
 
+	rows := payload.OrganicResults
+	switch q.Type {
+	case TypeNews:
+		rows = payload.NewsResults
+	case TypeImages:
+		rows = payload.ImagesResults
+	case TypeVideos:
+		rows = payload.VideoResults
+	}
 	results := make([]ResultItem, 0, q.Count)
-	for _, row := range payload.OrganicResults {
+	for _, row := range rows {
 		title := strings.TrimSpace(row.Title)
 		link := strings.TrimSpace(row.Link)
 		desc := strings.TrimSpace(row.Snippet)
 		if title == "" && link == "" && desc == "" {
 			continue
 		}
-		results = append(results, ResultItem{
+		item := ResultItem{
 			Title:       title,
 			URL:         link,
 			Description: desc,
 			Published:   strings.TrimSpace(row.Date),
 			SiteName:    strings.TrimSpace(row.Source),
-		})
+			Type:        q.Type,
+			Thumbnail:   strings.TrimSpace(row.Thumbnail),
+			Duration:    strings.TrimSpace(row.Duration),
+		}
+		if q.Type != TypeWeb {
+			item.Source = item.SiteName
+		}
+		if q.Type == TypeImages {
+			item.ImageURL = strings.TrimSpace(row.Original)
+			item.Width = row.OriginalWidth
+			item.Height = row.OriginalHeight
+		}
+		results = append(results, item)
 		if len(results) >= q.Count {
 			break
 		}
@@ -129,11 +180,13 @@ func (p *SerpAPIProvider) Search(ctx context.Context, query Query) (Response, er
 	out := Response{
 		Query:    q.Query,
 		Provider: ProviderSerpAPI,
-		Results:  uniqueByURL(results, q.Count),
+		Type:     q.Type,
+		Offset:   q.Offset,
+		Results:  finalizeResults(q, results, q.Count),
 	}
 	out.Total = len(out.Results)
 	if out.Total == 0 {
-		out.Note = "No public web results found"
+		out.Note = emptyNote(q)
 	}
 	return out, nil
 }
@@ -0,0 +1,245 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type TavilyProvider struct {
+	endpoint string
+	apiKey   string
+	client   *http.Client
+}
+
+func NewTavilyProvider(endpoint, apiKey string, timeout time.Duration) *TavilyProvider {
+	if strings.TrimSpace(endpoint) == "" {
+		endpoint = "https://api.tavily.com/search"
+	}
+	if timeout <= 0 {
+		timeout = 12 * time.Second
+	}
+	return &TavilyProvider{
+		endpoint: endpoint,
+		apiKey:   strings.TrimSpace(apiKey),
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+func (p *TavilyProvider) Name() string {
+	return ProviderTavily
+}
+
+type tavilyRequest struct {
+	Query         string `json:"query"`
+	MaxResults    int    `json:"max_results"`
+	Topic         string `json:"topic,omitempty"`
+	TimeRange     string `json:"time_range,omitempty"`
+	Country       string `json:"country,omitempty"`
+	SearchDepth   string `json:"search_depth,omitempty"`
+	IncludeImages bool   `json:"include_images,omitempty"`
+	// IncludeImageDescriptions makes "images" a list of objects.
+	IncludeImageDescriptions bool     `json:"include_image_descriptions,omitempty"`
+	IncludeDomains           []string `json:"include_domains,omitempty"`
+	ExcludeDomains           []string `json:"exclude_domains,omitempty"`
+}
+
+// tavilyMaxResults is the API's per-request cap.
+const tavilyMaxResults = 20
+
+type tavilyResponse struct {
+	Results []struct {
+		Title         string  `json:"title"`
+		URL           string  `json:"url"`
+		Content       string  `json:"content"`
+		Score         float64 `json:"score"`
+		PublishedDate string  `json:"published_date"`
+	} `json:"results"`
+	Images []struct {
+		URL         string `json:"url"`
+		Description string `json:"description"`
+	} `json:"images"`
+}
+
+// Search maps Query onto Tavily's /search API. Tavily has no language,
+// paging or safe-search parameters: SearchLang and SafeSearch are reported
+// as ignored and Offset is emulated by over-fetching. Country is converted
+// from an ISO code to the country name Tavily expects. Images come from
+// include_images; videos are not available.
+func (p *TavilyProvider) Search(ctx context.Context, query Query) (Response, error) {
+	if p.apiKey == "" {
+		return Response{}, NewTypedError(ErrorTypeConfig, fmt.Errorf("tavily api key is missing"))
+	}
+
+	q := query.Normalize()
+	var unsupported []string
+	fetch, ok := localWindow(q, tavilyMaxResults)
+	offset := q.Offset
+	if !ok {
+		unsupported = append(unsupported, "offset")
+		offset = 0
+	}
+	payload := tavilyRequest{
+		Query:          q.Query,
+		MaxResults:     fetch,
+		Topic:          "general",
+		TimeRange:      freshnessWindow(q.Freshness),
+		SearchDepth:    "basic",
+		IncludeDomains: q.IncludeDomains,
+		ExcludeDomains: q.ExcludeDomains,
+	}
+	switch q.Type {
+	case TypeNews:
+		payload.Topic = "news"
+	case TypeImages:
+		payload.IncludeImages = true
+		payload.IncludeImageDescriptions = true
+	case TypeVideos:
+		unsupported = append(unsupported, "type=videos")
+	}
+	if payload.Topic == "general" {
+		// Country boosting is only available for the general topic.
+		payload.Country = tavilyCountry(q.Country)
+	} else if q.Country != "" {
+		unsupported = append(unsupported, "country")
+	}
+	if q.SearchLang != "" {
+		unsupported = append(unsupported, "search_lang")
+	}
+	if q.SafeSearch != "" {
+		unsupported = append(unsupported, "safesearch")
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Response{}, NewTypedError(ErrorTypeUnknown, fmt.Errorf("encode tavily request failed: %w", err))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return Response{}, NewTypedError(ErrorTypeConfig, fmt.Errorf("create tavily request failed: %w", err))
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	req.Header.Set("User-Agent", "next-ai-agent-gateway/0.1 (+web-search)")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return Response{}, requestError("tavily", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 2048))
+		detail := strings.TrimSpace(string(body))
+		if detail == "" {
+			detail = res.Status
+		}
+		errorType := ErrorTypeUnknown
+		if res.StatusCode == http.StatusTooManyRequests || res.StatusCode == 432 || res.StatusCode == 433 {
+			// 432/433: plan or pay-as-you-go limit exceeded.
+			errorType = ErrorTypeRateLimit
+		} else if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+			errorType = ErrorTypeConfig
+		} else if res.StatusCode >= 500 {
+			errorType = ErrorTypeUpstream5xx
+		}
+		return Response{}, NewTypedError(errorType, fmt.Errorf("tavily http %d: %s", res.StatusCode, detail))
+	}
+
+	var decoded tavilyResponse
+	if err := json.NewDecoder(res.Body).Decode(&decoded); err != nil {
+		return Response{}, NewTypedError(ErrorTypeUnknown, fmt.Errorf("decode tavily response failed: %w", err))
+	}
+
+	resultType := q.Type
+	if resultType == TypeVideos {
+		resultType = TypeWeb
+	}
+	results := make([]ResultItem, 0, fetch)
+	if q.Type == TypeImages {
+		for _, image := range decoded.Images {
+			link := strings.TrimSpace(image.URL)
+			if link == "" {
+				continue
+			}
+			results = append(results, ResultItem{
+				Title:       strings.TrimSpace(image.Description),
+				URL:         link,
+				Description: strings.TrimSpace(image.Description),
+				SiteName:    hostname(link),
+				Type:        TypeImages,
+				ImageURL:    link,
+				Thumbnail:   link,
+				Source:      hostname(link),
+			})
+		}
+	}
+	for _, row := range decoded.Results {
+		if q.Type == TypeImages {
+			break
+		}
+		title := strings.TrimSpace(row.Title)
+		link := strings.TrimSpace(row.URL)
+		desc := strings.TrimSpace(row.Content)
+		if title == "" && link == "" && desc == "" {
+			continue
+		}
+		results = append(results, ResultItem{
+			Title:       title,
+			URL:         link,
+			Description: desc,
+			Published:   strings.TrimSpace(row.PublishedDate),
+			SiteName:    hostname(link),
+			Type:        resultType,
+		})
+		if len(results) >= fetch {
+			break
+		}
+	}
+
+	out := Response{
+		Query:    q.Query,
+		Provider: ProviderTavily,
+		Type:     resultType,
+		Offset:   offset,
+		Results:  skipResults(finalizeResults(q, results, offset+q.Count), offset),
+	}
+	out.Total = len(out.Results)
+	if out.Total == 0 {
+		out.Note = emptyNote(q)
+	}
+	out.Note = joinNotes(out.Note, unsupportedNote(ProviderTavily, unsupported))
+	return out, nil
+}
+
+// tavilyCountries maps ISO 3166 alpha-2 codes to the lowercase country names
+// accepted by Tavily's country boost. Unknown codes are not forwarded.
+var tavilyCountries = map[string]string{
+	"ar": "argentina", "au": "australia", "at": "austria", "be": "belgium",
+	"br": "brazil", "ca": "canada", "cl": "chile", "cn": "china",
+	"co": "colombia", "cz": "czech republic", "dk": "denmark", "eg": "egypt",
+	"fi": "finland", "fr": "france", "de": "germany", "gr": "greece",
+	"hk": "hong kong", "in": "india", "id": "indonesia", "ie": "ireland",
+	"il": "israel", "it": "italy", "jp": "japan", "kr": "south korea",
+	"my": "malaysia", "mx": "mexico", "nl": "netherlands", "nz": "new zealand",
+	"ng": "nigeria", "no": "norway", "pk": "pakistan", "pe": "peru",
+	"ph": "philippines", "pl": "poland", "pt": "portugal", "ro": "romania",
+	"ru": "russia", "sa": "saudi arabia", "sg": "singapore", "za": "south africa",
+	"es": "spain", "se": "sweden", "ch": "switzerland", "tw": "taiwan",
+	"th": "thailand", "tr": "turkey", "ua": "ukraine", "ae": "united arab emirates",
+	"gb": "united kingdom", "uk": "united kingdom", "us": "united states", "vn": "vietnam",
+}
+
+func tavilyCountry(country string) string {
+	country = strings.TrimSpace(strings.ToLower(country))
+	if len(country) > 2 {
+		return country
+	}
+	return tavilyCountries[country]
+}
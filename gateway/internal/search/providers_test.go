@@ -0,0 +1,419 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// upstreamCase drives one provider call against an httptest stand-in.
+type upstreamCase struct {
+	name     string
+	query    Query
+	status   int
+	body     string
+	check    func(t *testing.T, r *http.Request, params url.Values, payload map[string]any)
+	wantType string
+	wantURLs []string
+}
+
+func runUpstreamCases(t *testing.T, newProvider func(endpoint string) Provider, cases []upstreamCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var payload map[string]any
+				if r.Method == http.MethodPost {
+					raw, _ := io.ReadAll(r.Body)
+					if err := json.Unmarshal(raw, &payload); err != nil {
+						t.Errorf("request body is not JSON: %s", raw)
+					}
+				}
+				if tc.check != nil {
+					tc.check(t, r, r.URL.Query(), payload)
+				}
+				status := tc.status
+				if status == 0 {
+					status = http.StatusOK
+				}
+				w.WriteHeader(status)
+				io.WriteString(w, tc.body)
+			}))
+			defer server.Close()
+
+			res, err := newProvider(server.URL).Search(context.Background(), tc.query)
+			if tc.wantType != "" {
+				if got := ClassifyError(err); got != tc.wantType {
+					t.Fatalf("error type = %q (%v), want %q", got, err, tc.wantType)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			if res.Total != len(tc.wantURLs) {
+				t.Fatalf("total = %d, want %d: %+v", res.Total, len(tc.wantURLs), res.Results)
+			}
+			for i, want := range tc.wantURLs {
+				if res.Results[i].URL != want {
+					t.Errorf("result[%d].URL = %q, want %q", i, res.Results[i].URL, want)
+				}
+			}
+		})
+	}
+}
+
+func expectParam(t *testing.T, params url.Values, key, want string) {
+	t.Helper()
+	if got := params.Get(key); got != want {
+		t.Errorf("param %s = %q, want %q", key, got, want)
+	}
+}
+
+func expectField(t *testing.T, payload map[string]any, key string, want any) {
+	t.Helper()
+	if got := payload[key]; got != want {
+		t.Errorf("body %s = %v, want %v", key, got, want)
+	}
+}
+
+var fixedNow = time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+
+func TestTavilyProvider(t *testing.T) {
+	runUpstreamCases(t, func(endpoint string) Provider {
+		return NewTavilyProvider(endpoint, "tvly-key", time.Second)
+	}, []upstreamCase{
+		{
+			name:  "maps query",
+			query: Query{Query: "go generics", Count: 3, Country: "US", Freshness: "pw"},
+			body: `{"results":[
+				{"title":"A","url":"https://a.example/1","content":"first","published_date":"2026-03-10"},
+				{"title":"A dup","url":"https://a.example/1","content":"dup"},
+				{"title":"B","url":"https://www.b.example/2","content":"second"}]}`,
+			check: func(t *testing.T, r *http.Request, _ url.Values, payload map[string]any) {
+				if r.Header.Get("Authorization") != "Bearer tvly-key" {
+					t.Errorf("authorization = %q", r.Header.Get("Authorization"))
+				}
+				expectField(t, payload, "query", "go generics")
+				expectField(t, payload, "max_results", float64(3))
+				expectField(t, payload, "time_range", "week")
+				expectField(t, payload, "country", "united states")
+			},
+			wantURLs: []string{"https://a.example/1", "https://www.b.example/2"},
+		},
+		{name: "rate limited", status: http.StatusTooManyRequests, query: Query{Query: "q"}, wantType: ErrorTypeRateLimit},
+		{name: "plan limit", status: 432, query: Query{Query: "q"}, wantType: ErrorTypeRateLimit},
+		{name: "bad key", status: http.StatusUnauthorized, query: Query{Query: "q"}, wantType: ErrorTypeConfig},
+		{name: "upstream down", status: http.StatusBadGateway, query: Query{Query: "q"}, wantType: ErrorTypeUpstream5xx},
+	})
+
+	if _, err := NewTavilyProvider("", "", time.Second).Search(context.Background(), Query{Query: "q"}); ClassifyError(err) != ErrorTypeConfig {
+		t.Errorf("missing key error = %v", err)
+	}
+}
+
+func TestBingProvider(t *testing.T) {
+	runUpstreamCases(t, func(endpoint string) Provider {
+		p := NewBingProvider(endpoint, "bing-key", time.Second)
+		p.now = func() time.Time { return fixedNow }
+		return p
+	}, []upstreamCase{
+		{
+			name:  "market from country and language",
+			query: Query{Query: "weather", Count: 3, Country: "gb", SearchLang: "en", Freshness: "py"},
+			body: `{"webPages":{"value":[
+				{"name":"Met Office","url":"https://www.metoffice.gov.uk/","snippet":"forecast","datePublished":"2026-03-01"},
+				{"name":"BBC","url":"https://www.bbc.co.uk/weather","snippet":"bbc"}]}}`,
+			check: func(t *testing.T, r *http.Request, params url.Values, _ map[string]any) {
+				if r.Header.Get("Ocp-Apim-Subscription-Key") != "bing-key" {
+					t.Errorf("missing subscription key header")
+				}
+				expectParam(t, params, "q", "weather")
+				expectParam(t, params, "count", "3")
+				expectParam(t, params, "mkt", "en-GB")
+				expectParam(t, params, "setLang", "en")
+				expectParam(t, params, "freshness", "2025-03-15..2026-03-15")
+			},
+			wantURLs: []string{"https://www.metoffice.gov.uk/", "https://www.bbc.co.uk/weather"},
+		},
+		{
+			name:  "country only",
+			query: Query{Query: "news", Country: "de", Freshness: "day"},
+			body:  `{}`,
+			check: func(t *testing.T, _ *http.Request, params url.Values, _ map[string]any) {
+				expectParam(t, params, "cc", "DE")
+				expectParam(t, params, "mkt", "")
+				expectParam(t, params, "freshness", "Day")
+			},
+		},
+		{name: "forbidden", status: http.StatusForbidden, query: Query{Query: "q"}, wantType: ErrorTypeConfig},
+		{name: "rate limited", status: http.StatusTooManyRequests, query: Query{Query: "q"}, wantType: ErrorTypeRateLimit},
+		{name: "malformed", body: `{"webPages":`, query: Query{Query: "q"}, wantType: ErrorTypeUnknown},
+	})
+}
+
+func TestGoogleProvider(t *testing.T) {
+	runUpstreamCases(t, func(endpoint string) Provider {
+		return NewGoogleProvider(endpoint, "g-key", "engine-1", time.Second)
+	}, []upstreamCase{
+		{
+			name:  "maps query",
+			query: Query{Query: "golang", Count: 4, Country: "fr", SearchLang: "fr", Freshness: "month"},
+			body: `{"items":[
+				{"title":"Go","link":"https://go.dev/","snippet":"The Go language","displayLink":"go.dev",
+				 "pagemap":{"metatags":[{"og:site_name":"Go","article:published_time":"2026-01-01"}]}}]}`,
+			check: func(t *testing.T, _ *http.Request, params url.Values, _ map[string]any) {
+				expectParam(t, params, "key", "g-key")
+				expectParam(t, params, "cx", "engine-1")
+				expectParam(t, params, "num", "4")
+				expectParam(t, params, "gl", "fr")
+				expectParam(t, params, "lr", "lang_fr")
+				expectParam(t, params, "dateRestrict", "m1")
+			},
+			wantURLs: []string{"https://go.dev/"},
+		},
+		{name: "daily quota", status: http.StatusForbidden, body: `{"error":{"errors":[{"reason":"dailyLimitExceeded"}],"message":"Quota exceeded"}}`, query: Query{Query: "q"}, wantType: ErrorTypeRateLimit},
+		{name: "invalid key", status: http.StatusBadRequest, body: `{"error":{"message":"API key not valid"}}`, query: Query{Query: "q"}, wantType: ErrorTypeConfig},
+		{name: "upstream down", status: http.StatusServiceUnavailable, query: Query{Query: "q"}, wantType: ErrorTypeUpstream5xx},
+	})
+
+	if _, err := NewGoogleProvider("", "g-key", "", time.Second).Search(context.Background(), Query{Query: "q"}); ClassifyError(err) != ErrorTypeConfig {
+		t.Errorf("missing cx error = %v", err)
+	}
+}
+
+func TestExaProvider(t *testing.T) {
+	runUpstreamCases(t, func(endpoint string) Provider {
+		p := NewExaProvider(endpoint, "exa-key", time.Second)
+		p.now = func() time.Time { return fixedNow }
+		return p
+	}, []upstreamCase{
+		{
+			name:  "maps query",
+			query: Query{Query: "vector databases", Count: 2, Country: "us", Freshness: "pd"},
+			body: `{"results":[
+				{"title":"One","url":"https://one.example/","publishedDate":"2026-03-14T00:00:00Z","text":"  lots\n of   text "},
+				{"title":"Two","url":"https://two.example/","text":"more"},
+				{"title":"Three","url":"https://three.example/","text":"extra"}]}`,
+			check: func(t *testing.T, r *http.Request, _ url.Values, payload map[string]any) {
+				if r.Header.Get("x-api-key") != "exa-key" {
+					t.Errorf("missing x-api-key header")
+				}
+				expectField(t, payload, "query", "vector databases")
+				expectField(t, payload, "numResults", float64(2))
+				expectField(t, payload, "userLocation", "US")
+				expectField(t, payload, "startPublishedDate", "2026-03-14T12:00:00Z")
+			},
+			wantURLs: []string{"https://one.example/", "https://two.example/"},
+		},
+		{name: "bad key", status: http.StatusUnauthorized, query: Query{Query: "q"}, wantType: ErrorTypeConfig},
+		{name: "rate limited", status: http.StatusTooManyRequests, query: Query{Query: "q"}, wantType: ErrorTypeRateLimit},
+		{name: "upstream down", status: http.StatusInternalServerError, query: Query{Query: "q"}, wantType: ErrorTypeUpstream5xx},
+	})
+}
+
+func TestResolveProviderNameAutoOrder(t *testing.T) {
+	registry := NewRegistry(
+		NewDuckDuckGoProvider("", time.Second),
+		NewBraveProvider("", "", time.Second),
+		NewTavilyProvider("", "", time.Second),
+		NewBingProvider("", "", time.Second),
+		NewGoogleProvider("", "", "", time.Second),
+		NewExaProvider("", "", time.Second),
+	)
+	cases := []struct {
+		name  string
+		input ResolveInput
+		want  string
+	}{
+		{"nothing configured", ResolveInput{}, ProviderDuckDuckGo},
+		{"requested wins", ResolveInput{Requested: "bing", TavilyAPIKey: "k"}, ProviderBing},
+		{"brave before tavily", ResolveInput{BraveAPIKey: "k", TavilyAPIKey: "k"}, ProviderBrave},
+		{"tavily before exa", ResolveInput{TavilyAPIKey: "k", ExaAPIKey: "k"}, ProviderTavily},
+		{"exa before bing", ResolveInput{ExaAPIKey: "k", BingAPIKey: "k"}, ProviderExa},
+		{"google needs engine id", ResolveInput{GoogleAPIKey: "k"}, ProviderDuckDuckGo},
+		{"google", ResolveInput{GoogleAPIKey: "k", GoogleEngineID: "cx"}, ProviderGoogle},
+	}
+	for _, tc := range cases {
+		if got := ResolveProviderName(tc.input, registry); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestQueryNormalizePaging(t *testing.T) {
+	cases := []struct {
+		name  string
+		in    Query
+		off   int
+		page  int
+		qtype string
+		safe  string
+	}{
+		{"defaults", Query{Query: "q"}, 0, 1, TypeWeb, ""},
+		{"page to offset", Query{Count: 5, Page: 3}, 10, 3, TypeWeb, ""},
+		{"offset wins over page", Query{Count: 5, Offset: 7, Page: 3}, 7, 2, TypeWeb, ""},
+		{"offset clamped", Query{Count: 10, Offset: 5000}, maxOffset, maxOffset/10 + 1, TypeWeb, ""},
+		{"negative offset", Query{Offset: -4}, 0, 1, TypeWeb, ""},
+		{"type and safesearch aliases", Query{Type: "Image", SafeSearch: "active"}, 0, 1, TypeImages, SafeSearchStrict},
+		{"unknown type", Query{Type: "maps", SafeSearch: "bogus"}, 0, 1, TypeWeb, ""},
+	}
+	for _, tc := range cases {
+		got := tc.in.Normalize()
+		if got.Offset != tc.off || got.Page != tc.page || got.Type != tc.qtype || got.SafeSearch != tc.safe {
+			t.Errorf("%s: got offset=%d page=%d type=%q safe=%q", tc.name, got.Offset, got.Page, got.Type, got.SafeSearch)
+		}
+	}
+}
+
+func TestProviderPagingAndTypes(t *testing.T) {
+	t.Run("brave news", func(t *testing.T) {
+		runUpstreamCases(t, func(endpoint string) Provider {
+			return NewBraveProvider(endpoint+"/res/v1/web/search", "brave-key", time.Second)
+		}, []upstreamCase{{
+			name:  "offset and safesearch",
+			query: Query{Query: "q", Count: 5, Offset: 10, Type: "news", SafeSearch: "strict"},
+			body:  `{"results":[{"title":"N","url":"https://n.example/1"}]}`,
+			check: func(t *testing.T, r *http.Request, params url.Values, _ map[string]any) {
+				if r.URL.Path != "/res/v1/news/search" {
+					t.Errorf("path = %q", r.URL.Path)
+				}
+				expectParam(t, params, "offset", "2")
+				expectParam(t, params, "safesearch", "strict")
+			},
+			wantURLs: []string{"https://n.example/1"},
+		}})
+	})
+	t.Run("serpapi images", func(t *testing.T) {
+		runUpstreamCases(t, func(endpoint string) Provider {
+			return NewSerpAPIProvider(endpoint, "serp-key", time.Second)
+		}, []upstreamCase{{
+			name:  "tbm and start",
+			query: Query{Query: "q", Count: 4, Page: 2, Type: "images", SafeSearch: "off"},
+			body:  `{"images_results":[{"title":"I","link":"https://i.example/page","original":"https://i.example/i.png"}]}`,
+			check: func(t *testing.T, _ *http.Request, params url.Values, _ map[string]any) {
+				expectParam(t, params, "tbm", "isch")
+				expectParam(t, params, "start", "4")
+				expectParam(t, params, "safe", "off")
+			},
+			wantURLs: []string{"https://i.example/page"},
+		}})
+	})
+	t.Run("google start", func(t *testing.T) {
+		runUpstreamCases(t, func(endpoint string) Provider {
+			return NewGoogleProvider(endpoint, "g-key", "engine-1", time.Second)
+		}, []upstreamCase{
+			{
+				name:  "second page",
+				query: Query{Query: "q", Count: 10, Page: 2, SafeSearch: "moderate"},
+				body:  `{}`,
+				check: func(t *testing.T, _ *http.Request, params url.Values, _ map[string]any) {
+					expectParam(t, params, "start", "11")
+					expectParam(t, params, "safe", "active")
+				},
+			},
+			{
+				name:  "beyond result cap",
+				query: Query{Query: "q", Count: 10, Offset: 95},
+				body:  `{}`,
+				check: func(t *testing.T, _ *http.Request, params url.Values, _ map[string]any) {
+					expectParam(t, params, "start", "")
+				},
+			},
+		})
+	})
+	t.Run("tavily local window", func(t *testing.T) {
+		runUpstreamCases(t, func(endpoint string) Provider {
+			return NewTavilyProvider(endpoint, "tvly-key", time.Second)
+		}, []upstreamCase{{
+			name:  "skips offset locally",
+			query: Query{Query: "q", Count: 2, Offset: 1},
+			body:  `{"results":[{"url":"https://a.example/"},{"url":"https://b.example/"},{"url":"https://c.example/"}]}`,
+			check: func(t *testing.T, _ *http.Request, _ url.Values, payload map[string]any) {
+				expectField(t, payload, "max_results", float64(3))
+			},
+			wantURLs: []string{"https://b.example/", "https://c.example/"},
+		}})
+	})
+}
+
+func TestBingImageResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v7.0/images/search" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		expectParam(t, r.URL.Query(), "offset", "5")
+		expectParam(t, r.URL.Query(), "safeSearch", "Strict")
+		expectParam(t, r.URL.Query(), "responseFilter", "")
+		io.WriteString(w, `{"value":[{"name":"Cat","hostPageUrl":"https://cats.example/page",
+			"contentUrl":"https://cats.example/cat.jpg","thumbnailUrl":"https://tse.example/th",
+			"width":800,"height":600}]}`)
+	}))
+	defer server.Close()
+
+	res, err := NewBingProvider(server.URL+"/v7.0/search", "bing-key", time.Second).Search(context.Background(),
+		Query{Query: "cat", Count: 5, Page: 2, Type: "images", SafeSearch: "strict"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if res.Type != TypeImages || res.Offset != 5 || res.Total != 1 {
+		t.Fatalf("response = %+v", res)
+	}
+	got := res.Results[0]
+	want := ResultItem{Title: "Cat", URL: "https://cats.example/page", SiteName: "cats.example", Type: TypeImages,
+		Thumbnail: "https://tse.example/th", Source: "cats.example", ImageURL: "https://cats.example/cat.jpg", Width: 800, Height: 600}
+	if got != want {
+		t.Errorf("item = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestUnsupportedQueryFieldsAreNoted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"results":[{"title":"A","url":"https://a.example/","content":"a"}]}`)
+	}))
+	defer server.Close()
+
+	res, err := NewTavilyProvider(server.URL, "tvly-key", time.Second).Search(context.Background(),
+		Query{Query: "q", Type: "videos", SafeSearch: "strict"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if res.Type != TypeWeb {
+		t.Errorf("type = %q, want web fallback", res.Type)
+	}
+	if res.Note != "tavily ignored: type=videos, safesearch" {
+		t.Errorf("note = %q", res.Note)
+	}
+}
+
+func TestSearxngFiltersWholePages(t *testing.T) {
+	pages := map[string]string{
+		"1": `{"results":[{"url":"https://spam.example/1"},{"url":"https://go.dev/a"},{"url":"https://spam.example/2"}]}`,
+		"2": `{"results":[{"url":"https://go.dev/b"},{"url":"https://spam.example/3"},{"url":"https://go.dev/c"}]}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		expectParam(t, params, "q", "generics -site:spam.example")
+		io.WriteString(w, pages[params.Get("pageno")])
+	}))
+	defer server.Close()
+
+	res, err := NewSearxngProvider(server.URL, "", time.Second).Search(context.Background(), Query{Query: "generics", Count: 3, ExcludeDomains: []string{"spam.example"}})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	want := []string{"https://go.dev/a", "https://go.dev/b", "https://go.dev/c"}
+	if res.Total != len(want) {
+		t.Fatalf("total = %d, want %d: %+v", res.Total, len(want), res.Results)
+	}
+	for i, link := range want {
+		if res.Results[i].URL != link {
+			t.Errorf("result[%d].URL = %q, want %q", i, res.Results[i].URL, link)
+		}
+	}
+}
@@ -42,6 +42,14 @@ type ResolveInput struct {
 	BraveAPIKey     string
 	SearxngEndpoint string
 	SerpAPIKey      string
+	TavilyAPIKey    string
+	BingAPIKey      string
+	GoogleAPIKey    string
+	GoogleEngineID  string
+	ExaAPIKey       string
+	// Exhausted, when set, reports providers whose quota has run out;
+	// auto-selection skips them.
+	Exhausted func(provider string) bool
 }
 
 func ResolveProviderName(input ResolveInput, registry *Registry) string {
@@ -55,21 +63,36 @@ func ResolveProviderName(input ResolveInput, registry *Registry) string {
 		return configured
 	}
 
-	if strings.TrimSpace(input.BraveAPIKey) != "" && registry.Has(ProviderBrave) {
+	usable := func(name string) bool {
+		return registry.Has(name) && (input.Exhausted == nil || !input.Exhausted(name))
+	}
+	if strings.TrimSpace(input.BraveAPIKey) != "" && usable(ProviderBrave) {
 		return ProviderBrave
 	}
-	if strings.TrimSpace(input.SearxngEndpoint) != "" && registry.Has(ProviderSearxng) {
+	if strings.TrimSpace(input.SearxngEndpoint) != "" && usable(ProviderSearxng) {
 		return ProviderSearxng
 	}
-	if strings.TrimSpace(input.SerpAPIKey) != "" && registry.Has(ProviderSerpAPI) {
+	if strings.TrimSpace(input.SerpAPIKey) != "" && usable(ProviderSerpAPI) {
 		return ProviderSerpAPI
 	}
-	if registry.Has(ProviderDuckDuckGo) {
+	if strings.TrimSpace(input.TavilyAPIKey) != "" && usable(ProviderTavily) {
+		return ProviderTavily
+	}
+	if strings.TrimSpace(input.ExaAPIKey) != "" && usable(ProviderExa) {
+		return ProviderExa
+	}
+	if strings.TrimSpace(input.BingAPIKey) != "" && usable(ProviderBing) {
+		return ProviderBing
+	}
+	if strings.TrimSpace(input.GoogleAPIKey) != "" && strings.TrimSpace(input.GoogleEngineID) != "" && usable(ProviderGoogle) {
+		return ProviderGoogle
+	}
+	if usable(ProviderDuckDuckGo) {
 		return ProviderDuckDuckGo
 	}
 
 	// fallback to first provider name deterministically not guaranteed; caller should still check.
-	for _, candidate := range []string{ProviderBrave, ProviderSearxng, ProviderSerpAPI} {
+	for _, candidate := range []string{ProviderBrave, ProviderSearxng, ProviderSerpAPI, ProviderTavily, ProviderExa, ProviderBing, ProviderGoogle} {
 		if registry.Has(candidate) {
 			return candidate
 		}
@@ -0,0 +1,101 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// trustedBoost is how many positions a result from a trusted domain moves up.
+const trustedBoost = 3
+
+// nearDuplicateThreshold is the title token overlap (Jaccard) above which two
+// results are considered the same story.
+const nearDuplicateThreshold = 0.8
+
+// Rerank reorders provider results: results from trusted domains move up a
+// few places, and results repeating an earlier URL or a near-identical title
+// (syndicated copies, SEO mirrors) are moved to the end. The relative order
+// of everything else is preserved.
+func Rerank(items []ResultItem, trusted []string) []ResultItem {
+	if len(items) < 2 {
+		return items
+	}
+	order := make([]int, len(items))
+	score := make([]int, len(items))
+	for i, item := range items {
+		order[i] = i
+		score[i] = -i
+		if len(trusted) > 0 && matchesDomain(hostname(item.URL), trusted) {
+			score[i] += trustedBoost
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool { return score[order[a]] > score[order[b]] })
+
+	kept := make([]ResultItem, 0, len(items))
+	var demoted []ResultItem
+	seenURLs := make(map[string]struct{}, len(items))
+	var seenTitles [][]string
+	for _, i := range order {
+		item := items[i]
+		url := strings.TrimSpace(item.URL)
+		tokens := titleTokens(item.Title)
+		if _, dup := seenURLs[url]; dup || nearDuplicate(tokens, seenTitles) {
+			demoted = append(demoted, item)
+			continue
+		}
+		seenURLs[url] = struct{}{}
+		if len(tokens) > 0 {
+			seenTitles = append(seenTitles, tokens)
+		}
+		kept = append(kept, item)
+	}
+	return append(kept, demoted...)
+}
+
+// titleTokens lowercases a title into words, dropping a trailing
+// " - Site Name" / " | Site Name" suffix so mirrors of one article compare
+// equal.
+func titleTokens(title string) []string {
+	for _, sep := range []string{" | ", " - ", " — "} {
+		if i := strings.LastIndex(title, sep); i > 0 {
+			title = title[:i]
+		}
+	}
+	return strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func nearDuplicate(tokens []string, seen [][]string) bool {
+	if len(tokens) < 3 {
+		// Too short to judge; "Home" or "Docs" titles are common and distinct.
+		return false
+	}
+	for _, other := range seen {
+		if jaccard(tokens, other) >= nearDuplicateThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+func jaccard(a, b []string) float64 {
+	set := make(map[string]int, len(a)+len(b))
+	for _, t := range a {
+		set[t] |= 1
+	}
+	for _, t := range b {
+		set[t] |= 2
+	}
+	both := 0
+	for _, mask := range set {
+		if mask == 3 {
+			both++
+		}
+	}
+	if len(set) == 0 {
+		return 0
+	}
+	return float64(both) / float64(len(set))
+}
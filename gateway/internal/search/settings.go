@@ -0,0 +1,257 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/netguard"
+)
+
+// Settings is everything needed to build a Registry and pick a provider:
+// the process-wide configuration, optionally overlaid with a workspace's own
+// provider choice and keys.
+type Settings struct {
+	DefaultProvider    string
+	Timeout            time.Duration
+	DuckDuckGoEndpoint string
+	BraveEndpoint      string
+	BraveAPIKey        string
+	SearxngEndpoint    string
+	SearxngAPIKey      string
+	SerpAPIEndpoint    string
+	SerpAPIKey         string
+	TavilyEndpoint     string
+	TavilyAPIKey       string
+	BingEndpoint       string
+	BingAPIKey         string
+	GoogleEndpoint     string
+	GoogleAPIKey       string
+	GoogleEngineID     string
+	ExaEndpoint        string
+	ExaAPIKey          string
+	// SafeSearch is applied when the caller does not ask for a level.
+	SafeSearch     string
+	IncludeDomains []string
+	ExcludeDomains []string
+	// Rerank turns on result re-ranking, boosting TrustedDomains.
+	Rerank         bool
+	TrustedDomains []string
+	// rerankSet marks Rerank as explicitly configured by a workspace, so
+	// Overlay can also switch it off.
+	rerankSet bool
+	// searxngGuarded marks SearxngEndpoint as supplied by a workspace, so it
+	// is only dialled at public addresses.
+	searxngGuarded bool
+}
+
+// NewRegistryFromSettings builds one provider instance per supported
+// provider from s.
+func NewRegistryFromSettings(s Settings) *Registry {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 12 * time.Second
+	}
+	searxng := NewSearxngProvider(s.SearxngEndpoint, s.SearxngAPIKey, timeout)
+	if s.searxngGuarded {
+		searxng.client = guardedClient(timeout)
+	}
+	return NewRegistry(
+		NewDuckDuckGoProvider(s.DuckDuckGoEndpoint, timeout),
+		NewBraveProvider(s.BraveEndpoint, s.BraveAPIKey, timeout),
+		searxng,
+		NewSerpAPIProvider(s.SerpAPIEndpoint, s.SerpAPIKey, timeout),
+		NewTavilyProvider(s.TavilyEndpoint, s.TavilyAPIKey, timeout),
+		NewBingProvider(s.BingEndpoint, s.BingAPIKey, timeout),
+		NewGoogleProvider(s.GoogleEndpoint, s.GoogleAPIKey, s.GoogleEngineID, timeout),
+		NewExaProvider(s.ExaEndpoint, s.ExaAPIKey, timeout),
+	)
+}
+
+// ResolveInput returns the selection input for s with Requested unset.
+func (s Settings) ResolveInput() ResolveInput {
+	return ResolveInput{
+		Configured:      strings.TrimSpace(strings.ToLower(s.DefaultProvider)),
+		BraveAPIKey:     s.BraveAPIKey,
+		SearxngEndpoint: s.SearxngEndpoint,
+		SerpAPIKey:      s.SerpAPIKey,
+		TavilyAPIKey:    s.TavilyAPIKey,
+		BingAPIKey:      s.BingAPIKey,
+		GoogleAPIKey:    s.GoogleAPIKey,
+		GoogleEngineID:  s.GoogleEngineID,
+		ExaAPIKey:       s.ExaAPIKey,
+	}
+}
+
+// Credential returns what identifies the account a provider is billed to:
+// its API key, plus the instance for SearXNG and the engine for Google.
+// Comparing it against the gateway settings tells workspace-owned keys apart.
+func (s Settings) Credential(provider string) string {
+	switch provider {
+	case ProviderBrave:
+		return s.BraveAPIKey
+	case ProviderSearxng:
+		return s.SearxngEndpoint + " " + s.SearxngAPIKey
+	case ProviderSerpAPI:
+		return s.SerpAPIKey
+	case ProviderTavily:
+		return s.TavilyAPIKey
+	case ProviderBing:
+		return s.BingAPIKey
+	case ProviderGoogle:
+		return s.GoogleAPIKey + " " + s.GoogleEngineID
+	case ProviderExa:
+		return s.ExaAPIKey
+	}
+	return ""
+}
+
+// Overlay returns s with every non-empty field of ws applied on top. Domain
+// lists replace the base lists rather than extending them. A workspace
+// SearXNG endpoint drops the base SearXNG key; only the workspace's own key
+// goes with it.
+func (s Settings) Overlay(ws Settings) Settings {
+	pick := func(base *string, override string) {
+		if override = strings.TrimSpace(override); override != "" {
+			*base = override
+		}
+	}
+	pick(&s.DefaultProvider, ws.DefaultProvider)
+	pick(&s.BraveAPIKey, ws.BraveAPIKey)
+	if strings.TrimSpace(ws.SearxngEndpoint) != "" {
+		// The gateway's key is never sent to an instance the workspace chose.
+		s.SearxngEndpoint = strings.TrimSpace(ws.SearxngEndpoint)
+		s.SearxngAPIKey = ""
+		s.searxngGuarded = true
+	}
+	pick(&s.SearxngAPIKey, ws.SearxngAPIKey)
+	pick(&s.SerpAPIKey, ws.SerpAPIKey)
+	pick(&s.TavilyAPIKey, ws.TavilyAPIKey)
+	pick(&s.BingAPIKey, ws.BingAPIKey)
+	pick(&s.GoogleAPIKey, ws.GoogleAPIKey)
+	pick(&s.GoogleEngineID, ws.GoogleEngineID)
+	pick(&s.ExaAPIKey, ws.ExaAPIKey)
+	pick(&s.SafeSearch, ws.SafeSearch)
+	if len(ws.IncludeDomains) > 0 {
+		s.IncludeDomains = ws.IncludeDomains
+	}
+	if len(ws.ExcludeDomains) > 0 {
+		s.ExcludeDomains = ws.ExcludeDomains
+	}
+	if ws.rerankSet {
+		s.Rerank = ws.Rerank
+	}
+	if len(ws.TrustedDomains) > 0 {
+		s.TrustedDomains = ws.TrustedDomains
+	}
+	return s
+}
+
+// workspaceConfig is the JSON stored in a workspace's web_search_config_json.
+// Only keys, the SearXNG instance and policy are per workspace; the other
+// provider endpoints stay under the gateway's control.
+type workspaceConfig struct {
+	SafeSearch     string   `json:"safeSearch"`
+	IncludeDomains []string `json:"includeDomains"`
+	ExcludeDomains []string `json:"excludeDomains"`
+	Rerank         struct {
+		Enabled        *bool    `json:"enabled"`
+		TrustedDomains []string `json:"trustedDomains"`
+	} `json:"rerank"`
+	Brave struct {
+		APIKey string `json:"apiKey"`
+	} `json:"brave"`
+	Searxng struct {
+		Endpoint string `json:"endpoint"`
+		APIKey   string `json:"apiKey"`
+	} `json:"searxng"`
+	SerpAPI struct {
+		APIKey string `json:"apiKey"`
+	} `json:"serpapi"`
+	Tavily struct {
+		APIKey string `json:"apiKey"`
+	} `json:"tavily"`
+	Bing struct {
+		APIKey string `json:"apiKey"`
+	} `json:"bing"`
+	Google struct {
+		APIKey   string `json:"apiKey"`
+		EngineID string `json:"engineId"`
+	} `json:"google"`
+	Exa struct {
+		APIKey string `json:"apiKey"`
+	} `json:"exa"`
+}
+
+// ParseWorkspaceSettings decodes a workspace's stored provider and config
+// JSON into the overrides to pass to Overlay. An empty or "auto" provider
+// leaves the gateway default in place.
+func ParseWorkspaceSettings(provider, configJSON string) (Settings, error) {
+	out := Settings{}
+	if provider = strings.TrimSpace(strings.ToLower(provider)); provider != ProviderAuto {
+		out.DefaultProvider = provider
+	}
+	if strings.TrimSpace(configJSON) == "" {
+		return out, nil
+	}
+	var cfg workspaceConfig
+	if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
+		return out, err
+	}
+	out.SafeSearch = normalizeSafeSearch(cfg.SafeSearch)
+	out.IncludeDomains = cleanDomains(cfg.IncludeDomains)
+	out.ExcludeDomains = cleanDomains(cfg.ExcludeDomains)
+	if cfg.Rerank.Enabled != nil {
+		out.Rerank, out.rerankSet = *cfg.Rerank.Enabled, true
+	}
+	out.TrustedDomains = cleanDomains(cfg.Rerank.TrustedDomains)
+	out.BraveAPIKey = cfg.Brave.APIKey
+	if err := ValidateEndpoint(cfg.Searxng.Endpoint); err != nil {
+		return out, err
+	}
+	out.SearxngEndpoint = cfg.Searxng.Endpoint
+	out.SearxngAPIKey = cfg.Searxng.APIKey
+	out.SerpAPIKey = cfg.SerpAPI.APIKey
+	out.TavilyAPIKey = cfg.Tavily.APIKey
+	out.BingAPIKey = cfg.Bing.APIKey
+	out.GoogleAPIKey = cfg.Google.APIKey
+	out.GoogleEngineID = cfg.Google.EngineID
+	out.ExaAPIKey = cfg.Exa.APIKey
+	return out, nil
+}
+
+var errInvalidEndpoint = errors.New("endpoint must be an http or https URL")
+
+// ValidateEndpoint checks a workspace-supplied provider endpoint. Empty means
+// "use the gateway's own" and is valid. Where the host resolves to is checked
+// at dial time instead (see guardedClient).
+func ValidateEndpoint(raw string) error {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return errInvalidEndpoint
+	}
+	return nil
+}
+
+// guardedClient is an HTTP client for workspace-supplied endpoints: it never
+// connects to loopback, private or link-local addresses, including after a
+// redirect or DNS change.
+func guardedClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// No proxy: the address guard must see the real destination.
+			Proxy:                 nil,
+			DialContext:           netguard.Dialer(10*time.Second, false).DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: timeout,
+		},
+	}
+}
@@ -10,15 +10,53 @@ const (
 	ProviderBrave      = "brave"
 	ProviderSearxng    = "searxng"
 	ProviderSerpAPI    = "serpapi"
+	ProviderTavily     = "tavily"
+	ProviderBing       = "bing"
+	ProviderGoogle     = "google"
+	ProviderExa        = "exa"
 	ProviderAuto       = "auto"
 )
 
+const (
+	TypeWeb    = "web"
+	TypeNews   = "news"
+	TypeImages = "images"
+	TypeVideos = "videos"
+)
+
+const (
+	SafeSearchOff      = "off"
+	SafeSearchModerate = "moderate"
+	SafeSearchStrict   = "strict"
+)
+
+// maxOffset bounds how deep a caller may page; most APIs stop well before.
+const maxOffset = 200
+
 type Query struct {
 	Query      string
 	Count      int
 	Country    string
 	SearchLang string
 	Freshness  string
+	// Offset is the number of results to skip. Page (1-based) is an
+	// alternative: when Offset is zero, Normalize derives it from Page.
+	Offset int
+	Page   int
+	// Type is one of the Type* constants; empty means web.
+	Type string
+	// SafeSearch is one of the SafeSearch* constants; empty leaves the
+	// provider default.
+	SafeSearch string
+	// IncludeDomains restricts results to these domains (and subdomains);
+	// ExcludeDomains drops them. Providers translate them natively or into
+	// site: operators where they can, and results are post-filtered as well.
+	IncludeDomains []string
+	ExcludeDomains []string
+	// Rerank enables Rerank on the provider's results before truncation,
+	// boosting TrustedDomains.
+	Rerank         bool
+	TrustedDomains []string
 }
 
 func (q Query) Normalize() Query {
@@ -28,6 +66,19 @@ func (q Query) Normalize() Query {
 		Country:    strings.TrimSpace(strings.ToLower(q.Country)),
 		SearchLang: strings.TrimSpace(strings.ToLower(q.SearchLang)),
 		Freshness:  strings.TrimSpace(strings.ToLower(q.Freshness)),
+		Offset:     q.Offset,
+		Type:       normalizeType(q.Type),
+		SafeSearch: normalizeSafeSearch(q.SafeSearch),
+		Rerank:     q.Rerank,
+	}
+	if len(q.IncludeDomains) > 0 {
+		out.IncludeDomains = cleanDomains(q.IncludeDomains)
+	}
+	if len(q.ExcludeDomains) > 0 {
+		out.ExcludeDomains = cleanDomains(q.ExcludeDomains)
+	}
+	if len(q.TrustedDomains) > 0 {
+		out.TrustedDomains = cleanDomains(q.TrustedDomains)
 	}
 	if out.Count <= 0 {
 		out.Count = 5
@@ -35,26 +86,141 @@ func (q Query) Normalize() Query {
 	if out.Count > 10 {
 		out.Count = 10
 	}
+	if out.Offset <= 0 && q.Page > 1 {
+		out.Offset = (q.Page - 1) * out.Count
+	}
+	if out.Offset < 0 {
+		out.Offset = 0
+	}
+	if out.Offset > maxOffset {
+		out.Offset = maxOffset
+	}
+	out.Page = out.Offset/out.Count + 1
 	return out
 }
 
+func normalizeType(t string) string {
+	switch strings.TrimSpace(strings.ToLower(t)) {
+	case "news":
+		return TypeNews
+	case "images", "image":
+		return TypeImages
+	case "videos", "video":
+		return TypeVideos
+	default:
+		return TypeWeb
+	}
+}
+
+func normalizeSafeSearch(s string) string {
+	switch strings.TrimSpace(strings.ToLower(s)) {
+	case "off", "0", "false":
+		return SafeSearchOff
+	case "moderate", "1", "medium":
+		return SafeSearchModerate
+	case "strict", "2", "true", "active":
+		return SafeSearchStrict
+	default:
+		return ""
+	}
+}
+
+// ResultItem is one search hit. The type-specific fields are filled only
+// when the provider returns them: Thumbnail for news/images/videos, Source
+// for the publisher or platform, ImageURL/Width/Height for images and
+// Duration for videos.
 type ResultItem struct {
 	Title       string `json:"title"`
 	URL         string `json:"url"`
 	Description string `json:"description"`
 	Published   string `json:"published,omitempty"`
 	SiteName    string `json:"siteName,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Thumbnail   string `json:"thumbnail,omitempty"`
+	Source      string `json:"source,omitempty"`
+	ImageURL    string `json:"imageUrl,omitempty"`
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+	Duration    string `json:"duration,omitempty"`
 }
 
 type Response struct {
 	Query     string       `json:"query"`
 	Provider  string       `json:"provider"`
+	Type      string       `json:"type,omitempty"`
+	Offset    int          `json:"offset,omitempty"`
 	Results   []ResultItem `json:"results"`
 	Total     int          `json:"total"`
 	Note      string       `json:"note,omitempty"`
 	ErrorType string       `json:"errorType,omitempty"`
 }
 
+// freshnessWindow maps the accepted freshness spellings (pd/day/d, ...) to
+// day, week, month or year; empty when unrecognised.
+func freshnessWindow(freshness string) string {
+	switch strings.TrimSpace(strings.ToLower(freshness)) {
+	case "pd", "day", "d":
+		return "day"
+	case "pw", "week", "w":
+		return "week"
+	case "pm", "month", "m":
+		return "month"
+	case "py", "year", "y":
+		return "year"
+	default:
+		return ""
+	}
+}
+
+// unsupportedNote tells the caller which requested Query fields a provider
+// could not honour, e.g. "brave ignored: safesearch". Empty when none.
+func unsupportedNote(provider string, fields []string) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	return provider + " ignored: " + strings.Join(fields, ", ")
+}
+
+func joinNotes(notes ...string) string {
+	parts := make([]string, 0, len(notes))
+	for _, note := range notes {
+		if note = strings.TrimSpace(note); note != "" {
+			parts = append(parts, note)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// emptyNote is the note for a page without results.
+func emptyNote(q Query) string {
+	if q.Offset > 0 {
+		return "No more results"
+	}
+	if q.Type != TypeWeb {
+		return "No public " + q.Type + " results found"
+	}
+	return "No public web results found"
+}
+
+// localWindow emulates Offset for providers without native paging: it
+// reports how many results to fetch so that the page can be cut locally,
+// or ok=false when the window exceeds the provider's per-request maximum.
+func localWindow(q Query, maxResults int) (fetch int, ok bool) {
+	fetch = q.Offset + q.Count
+	if fetch > maxResults {
+		return q.Count, false
+	}
+	return fetch, true
+}
+
+// skipResults drops the first offset items (after de-duplication).
+func skipResults(items []ResultItem, offset int) []ResultItem {
+	if offset >= len(items) {
+		return []ResultItem{}
+	}
+	return items[offset:]
+}
+
 type Provider interface {
 	Name() string
 	Search(ctx context.Context, query Query) (Response, error)
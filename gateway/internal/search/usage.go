@@ -0,0 +1,213 @@
+package search
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/logging"
+)
+
+// UsageEvent is one upstream search call.
+type UsageEvent struct {
+	Provider    string
+	WorkspaceID string
+	RunID       string
+	// ErrorType is empty for a successful call.
+	ErrorType string
+	Latency   time.Duration
+	Results   int
+	// Billable marks calls made with the gateway's own key, which count
+	// against its quota; calls on a workspace's own key do not.
+	Billable  bool
+	Timestamp time.Time
+}
+
+// ProviderUsage is the running total for one provider since the gateway
+// started. QuotaUsed counts billable calls in the current UTC month.
+type ProviderUsage struct {
+	Calls          int64            `json:"calls"`
+	Successes      int64            `json:"successes"`
+	Errors         map[string]int64 `json:"errors,omitempty"`
+	TotalLatencyMs int64            `json:"totalLatencyMs"`
+	QuotaUsed      int              `json:"quotaUsed"`
+	MonthlyQuota   int              `json:"monthlyQuota,omitempty"`
+}
+
+// Usage keeps per-provider call counters and enforces monthly quotas on the
+// gateway's own keys. Counts live in memory, so each gateway instance tracks
+// its own share and a restart starts the month over.
+type Usage struct {
+	mu     sync.Mutex
+	quotas map[string]int
+	month  string
+	used   map[string]int
+	stats  map[string]*ProviderUsage
+	now    func() time.Time
+}
+
+// NewUsage creates a tracker; a quota of zero or less means unlimited.
+func NewUsage(quotas map[string]int) *Usage {
+	u := &Usage{
+		used:  map[string]int{},
+		stats: map[string]*ProviderUsage{},
+		now:   time.Now,
+	}
+	u.SetQuotas(quotas)
+	return u
+}
+
+// SetQuotas replaces the quotas (e.g. after a config reload) and keeps the
+// counters.
+func (u *Usage) SetQuotas(quotas map[string]int) {
+	clean := make(map[string]int, len(quotas))
+	for name, quota := range quotas {
+		if quota > 0 {
+			clean[strings.ToLower(name)] = quota
+		}
+	}
+	u.mu.Lock()
+	u.quotas = clean
+	u.mu.Unlock()
+}
+
+// Record adds ev to the counters. Config errors never reach the upstream
+// and are not charged to the quota.
+func (u *Usage) Record(ev UsageEvent) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.rollMonth()
+	s := u.stats[ev.Provider]
+	if s == nil {
+		s = &ProviderUsage{}
+		u.stats[ev.Provider] = s
+	}
+	s.Calls++
+	s.TotalLatencyMs += ev.Latency.Milliseconds()
+	if ev.ErrorType == "" {
+		s.Successes++
+	} else {
+		if s.Errors == nil {
+			s.Errors = map[string]int64{}
+		}
+		s.Errors[ev.ErrorType]++
+	}
+	if ev.Billable && ev.ErrorType != ErrorTypeConfig {
+		u.used[ev.Provider]++
+	}
+}
+
+// Exhausted reports whether provider has used up its monthly quota.
+func (u *Usage) Exhausted(provider string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.rollMonth()
+	quota, ok := u.quotas[provider]
+	return ok && u.used[provider] >= quota
+}
+
+// Snapshot returns a copy of the counters keyed by provider, including
+// providers that have a quota but no calls yet.
+func (u *Usage) Snapshot() map[string]ProviderUsage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.rollMonth()
+	out := make(map[string]ProviderUsage, len(u.stats))
+	for name, s := range u.stats {
+		c := *s
+		if s.Errors != nil {
+			c.Errors = make(map[string]int64, len(s.Errors))
+			for k, v := range s.Errors {
+				c.Errors[k] = v
+			}
+		}
+		out[name] = c
+	}
+	for name, quota := range u.quotas {
+		c := out[name]
+		c.MonthlyQuota = quota
+		out[name] = c
+	}
+	for name, used := range u.used {
+		c := out[name]
+		c.QuotaUsed = used
+		out[name] = c
+	}
+	return out
+}
+
+// rollMonth resets quota usage when the UTC month changes. Callers hold mu.
+func (u *Usage) rollMonth() {
+	month := u.now().UTC().Format("2006-01")
+	if month != u.month {
+		u.month = month
+		u.used = map[string]int{}
+	}
+}
+
+// UsageSink ships a batch of usage events, e.g. to the Chat service.
+type UsageSink func(ctx context.Context, events []UsageEvent) error
+
+// UsageReporter queues usage events and hands them to a UsageSink in small
+// batches from a background worker, so reporting never delays a search.
+// When the queue is full, events are dropped and logged.
+type UsageReporter struct {
+	sink  UsageSink
+	queue chan UsageEvent
+	wg    sync.WaitGroup
+}
+
+const maxUsageBatch = 100
+
+func NewUsageReporter(sink UsageSink, queueSize int) *UsageReporter {
+	if queueSize <= 0 {
+		queueSize = 1024
+	}
+	r := &UsageReporter{sink: sink, queue: make(chan UsageEvent, queueSize)}
+	r.wg.Add(1)
+	go r.run()
+	return r
+}
+
+// Report queues ev; ctx only scopes the drop warning to the calling request.
+func (r *UsageReporter) Report(ctx context.Context, ev UsageEvent) {
+	select {
+	case r.queue <- ev:
+	default:
+		logging.FromContext(ctx).Warn("search usage: queue full, dropping event",
+			slog.String("search_provider", ev.Provider), slog.String("workspace_id", ev.WorkspaceID))
+	}
+}
+
+func (r *UsageReporter) run() {
+	defer r.wg.Done()
+	batch := make([]UsageEvent, 0, maxUsageBatch)
+	for ev := range r.queue {
+		batch = append(batch[:0], ev)
+	drain:
+		for len(batch) < maxUsageBatch {
+			select {
+			case next, ok := <-r.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := r.sink(ctx, batch); err != nil {
+			slog.Error("search usage: report failed", slog.Int("events", len(batch)), slog.Any("err", err))
+		}
+		cancel()
+	}
+}
+
+// Close flushes queued events and stops the worker.
+func (r *UsageReporter) Close() {
+	close(r.queue)
+	r.wg.Wait()
+}
@@ -0,0 +1,49 @@
+package search
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUsageQuota(t *testing.T) {
+	now := fixedNow
+	u := NewUsage(map[string]int{ProviderBrave: 2, ProviderSerpAPI: 0})
+	u.now = func() time.Time { return now }
+
+	u.Record(UsageEvent{Provider: ProviderBrave, Billable: true, Latency: 120 * time.Millisecond})
+	u.Record(UsageEvent{Provider: ProviderBrave, Billable: false})
+	u.Record(UsageEvent{Provider: ProviderBrave, Billable: true, ErrorType: ErrorTypeConfig})
+	if u.Exhausted(ProviderBrave) {
+		t.Fatal("workspace-key and config-error calls should not count against the quota")
+	}
+	u.Record(UsageEvent{Provider: ProviderBrave, Billable: true, ErrorType: ErrorTypeRateLimit})
+	if !u.Exhausted(ProviderBrave) {
+		t.Fatal("brave should be exhausted after two billable calls")
+	}
+	if u.Exhausted(ProviderSerpAPI) {
+		t.Error("a zero quota means unlimited")
+	}
+
+	got := u.Snapshot()[ProviderBrave]
+	if got.Calls != 4 || got.Successes != 2 || got.Errors[ErrorTypeRateLimit] != 1 || got.QuotaUsed != 2 || got.MonthlyQuota != 2 || got.TotalLatencyMs != 120 {
+		t.Errorf("unexpected snapshot %+v", got)
+	}
+
+	input := ResolveInput{BraveAPIKey: "b", TavilyAPIKey: "t", Exhausted: u.Exhausted}
+	registry := NewRegistryFromSettings(Settings{})
+	if got := ResolveProviderName(input, registry); got != ProviderTavily {
+		t.Errorf("auto-selection picked %q, want tavily", got)
+	}
+	input.Requested = ProviderBrave
+	if got := ResolveProviderName(input, registry); got != ProviderBrave {
+		t.Errorf("explicit request should be honored, got %q", got)
+	}
+
+	now = now.AddDate(0, 1, 0)
+	if u.Exhausted(ProviderBrave) {
+		t.Error("quota should reset at the start of the month")
+	}
+	if got := u.Snapshot()[ProviderBrave]; got.Calls != 4 || got.QuotaUsed != 0 {
+		t.Errorf("counters after month roll = %+v", got)
+	}
+}
@@ -0,0 +1,136 @@
+package search
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SettingsLoader fetches a workspace's search overrides (see
+// ParseWorkspaceSettings).
+type SettingsLoader func(ctx context.Context, workspaceID string) (Settings, error)
+
+// WorkspaceRegistries resolves per-workspace settings on top of the global
+// ones and builds a Registry for each workspace on first use. Results are
+// cached for ttl; a failed lookup falls back to the global settings and is
+// retried after a shorter delay.
+type WorkspaceRegistries struct {
+	base     Settings
+	registry *Registry
+	load     SettingsLoader
+	ttl      time.Duration
+	retry    time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*workspaceEntry
+}
+
+type workspaceEntry struct {
+	settings  Settings
+	registry  *Registry
+	expiresAt time.Time
+}
+
+// maxWorkspaceEntries bounds the cache; expired entries are swept when the
+// bound is reached.
+const maxWorkspaceEntries = 1024
+
+func NewWorkspaceRegistries(base Settings, load SettingsLoader, ttl time.Duration) *WorkspaceRegistries {
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	base.IncludeDomains = cleanDomains(base.IncludeDomains)
+	base.ExcludeDomains = cleanDomains(base.ExcludeDomains)
+	base.TrustedDomains = cleanDomains(base.TrustedDomains)
+	retry := 5 * time.Second
+	if ttl < retry {
+		retry = ttl
+	}
+	return &WorkspaceRegistries{
+		base:     base,
+		registry: NewRegistryFromSettings(base),
+		load:     load,
+		ttl:      ttl,
+		retry:    retry,
+		now:      time.Now,
+		entries:  make(map[string]*workspaceEntry),
+	}
+}
+
+// Base returns the global settings and their registry.
+func (w *WorkspaceRegistries) Base() (Settings, *Registry) {
+	return w.base, w.registry
+}
+
+// Invalidate drops the cached entry for workspaceID.
+func (w *WorkspaceRegistries) Invalidate(workspaceID string) {
+	w.mu.Lock()
+	delete(w.entries, workspaceID)
+	w.mu.Unlock()
+}
+
+// Resolve returns the effective settings and registry for workspaceID. With
+// no workspace or no loader it returns the global ones. A loader error is
+// returned alongside the global settings so the caller can log it and carry
+// on.
+func (w *WorkspaceRegistries) Resolve(ctx context.Context, workspaceID string) (Settings, *Registry, error) {
+	workspaceID = strings.TrimSpace(workspaceID)
+	if workspaceID == "" || w.load == nil {
+		return w.base, w.registry, nil
+	}
+
+	now := w.now()
+	w.mu.Lock()
+	entry := w.entries[workspaceID]
+	w.mu.Unlock()
+	if entry != nil && now.Before(entry.expiresAt) {
+		return entry.settings, entry.registry, nil
+	}
+
+	overrides, err := w.load(ctx, workspaceID)
+	if err != nil {
+		w.store(workspaceID, &workspaceEntry{settings: w.base, registry: w.registry, expiresAt: now.Add(w.retry)})
+		return w.base, w.registry, err
+	}
+	entry = &workspaceEntry{settings: w.base.Overlay(overrides), expiresAt: now.Add(w.ttl)}
+	if providersDiffer(w.base, entry.settings) {
+		entry.registry = NewRegistryFromSettings(entry.settings)
+	} else {
+		entry.registry = w.registry
+	}
+	w.store(workspaceID, entry)
+	return entry.settings, entry.registry, nil
+}
+
+func (w *WorkspaceRegistries) store(workspaceID string, entry *workspaceEntry) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.entries) >= maxWorkspaceEntries {
+		now := w.now()
+		for id, e := range w.entries {
+			if !now.Before(e.expiresAt) {
+				delete(w.entries, id)
+			}
+		}
+		if len(w.entries) >= maxWorkspaceEntries {
+			w.entries = make(map[string]*workspaceEntry)
+		}
+	}
+	w.entries[workspaceID] = entry
+}
+
+// providersDiffer reports whether b needs its own provider instances, i.e.
+// whether any key or endpoint differs from a.
+func providersDiffer(a, b Settings) bool {
+	return a.BraveAPIKey != b.BraveAPIKey ||
+		a.SearxngEndpoint != b.SearxngEndpoint ||
+		a.SearxngAPIKey != b.SearxngAPIKey ||
+		a.SerpAPIKey != b.SerpAPIKey ||
+		a.TavilyAPIKey != b.TavilyAPIKey ||
+		a.BingAPIKey != b.BingAPIKey ||
+		a.GoogleAPIKey != b.GoogleAPIKey ||
+		a.GoogleEngineID != b.GoogleEngineID ||
+		a.ExaAPIKey != b.ExaAPIKey
+}
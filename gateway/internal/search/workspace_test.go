@@ -0,0 +1,160 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/netguard"
+)
+
+func TestParseWorkspaceSettings(t *testing.T) {
+	got, err := ParseWorkspaceSettings("Tavily", `{
+		"safeSearch": "strict",
+		"includeDomains": ["https://www.Go.dev/doc", " pkg.go.dev "],
+		"rerank": {"enabled": true, "trustedDomains": ["go.dev"]},
+		"tavily": {"apiKey": "tvly-ws"},
+		"google": {"apiKey": "g", "engineId": "cx"}}`)
+	if err != nil {
+		t.Fatalf("ParseWorkspaceSettings: %v", err)
+	}
+	want := Settings{
+		DefaultProvider: ProviderTavily,
+		SafeSearch:      SafeSearchStrict,
+		IncludeDomains:  []string{"go.dev", "pkg.go.dev"},
+		ExcludeDomains:  []string{},
+		Rerank:          true,
+		TrustedDomains:  []string{"go.dev"},
+		rerankSet:       true,
+		TavilyAPIKey:    "tvly-ws",
+		GoogleAPIKey:    "g",
+		GoogleEngineID:  "cx",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+
+	if got, _ := ParseWorkspaceSettings("auto", ""); got.DefaultProvider != "" {
+		t.Errorf("auto provider should inherit, got %q", got.DefaultProvider)
+	}
+	if _, err := ParseWorkspaceSettings("", "{"); err == nil {
+		t.Error("expected error for malformed config")
+	}
+	for _, endpoint := range []string{"file:///etc/passwd", "gopher://host", "//host/search", "https://user:pw@host"} {
+		if _, err := ParseWorkspaceSettings("", `{"searxng": {"endpoint": "`+endpoint+`"}}`); err == nil {
+			t.Errorf("expected error for searxng endpoint %q", endpoint)
+		}
+	}
+}
+
+func TestWorkspaceSearxngEndpointIsGuarded(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"results": []}`))
+	}))
+	defer server.Close()
+
+	// The gateway's own endpoint may be internal.
+	base := Settings{SearxngEndpoint: server.URL}
+	if _, err := NewRegistryFromSettings(base).providers[ProviderSearxng].Search(context.Background(), Query{Query: "go"}); err != nil {
+		t.Fatalf("gateway endpoint: %v", err)
+	}
+
+	// A workspace pointing it at a loopback address is refused at dial time.
+	ws, err := ParseWorkspaceSettings("searxng", `{"searxng": {"endpoint": "`+server.URL+`"}}`)
+	if err != nil {
+		t.Fatalf("ParseWorkspaceSettings: %v", err)
+	}
+	_, err = NewRegistryFromSettings(Settings{}.Overlay(ws)).providers[ProviderSearxng].Search(context.Background(), Query{Query: "go"})
+	if !errors.Is(err, netguard.ErrBlockedAddress) || ClassifyError(err) != ErrorTypeConfig {
+		t.Fatalf("workspace endpoint: err = %v (%s), want blocked config error", err, ClassifyError(err))
+	}
+}
+
+func TestOverlayKeepsSearxngKeyWithItsEndpoint(t *testing.T) {
+	base := Settings{SearxngEndpoint: "http://searxng.internal", SearxngAPIKey: "gateway-key"}
+	tests := []struct {
+		name         string
+		ws           Settings
+		wantEndpoint string
+		wantKey      string
+	}{
+		{"no override", Settings{}, "http://searxng.internal", "gateway-key"},
+		{"key only", Settings{SearxngAPIKey: "ws-key"}, "http://searxng.internal", "ws-key"},
+		{"endpoint only", Settings{SearxngEndpoint: "https://search.example.com"}, "https://search.example.com", ""},
+		{"endpoint and key", Settings{SearxngEndpoint: "https://search.example.com", SearxngAPIKey: "ws-key"}, "https://search.example.com", "ws-key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := base.Overlay(tt.ws)
+			if got.SearxngEndpoint != tt.wantEndpoint || got.SearxngAPIKey != tt.wantKey {
+				t.Errorf("endpoint, key = %q, %q; want %q, %q", got.SearxngEndpoint, got.SearxngAPIKey, tt.wantEndpoint, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestWorkspaceRegistriesResolve(t *testing.T) {
+	base := Settings{DefaultProvider: ProviderAuto, BraveAPIKey: "global-brave"}
+	calls := map[string]int{}
+	load := func(ctx context.Context, workspaceID string) (Settings, error) {
+		calls[workspaceID]++
+		switch workspaceID {
+		case "ws-own":
+			return Settings{DefaultProvider: ProviderExa, ExaAPIKey: "ws-exa"}, nil
+		case "ws-plain":
+			return Settings{SafeSearch: SafeSearchStrict}, nil
+		default:
+			return Settings{}, errors.New("settings service unavailable")
+		}
+	}
+	now := fixedNow
+	w := NewWorkspaceRegistries(base, load, time.Minute)
+	w.now = func() time.Time { return now }
+	_, baseRegistry := w.Base()
+
+	settings, registry, err := w.Resolve(context.Background(), "ws-own")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if registry == baseRegistry {
+		t.Error("workspace with its own keys should get its own registry")
+	}
+	if got := ResolveProviderName(settings.ResolveInput(), registry); got != ProviderExa {
+		t.Errorf("provider = %q, want exa", got)
+	}
+	if settings.BraveAPIKey != "global-brave" {
+		t.Errorf("unset keys should fall back to global, got %q", settings.BraveAPIKey)
+	}
+
+	if _, registry, _ := w.Resolve(context.Background(), "ws-plain"); registry != baseRegistry {
+		t.Error("workspace without key overrides should share the global registry")
+	}
+
+	w.Resolve(context.Background(), "ws-own")
+	if calls["ws-own"] != 1 {
+		t.Errorf("cached lookup hit the loader %d times", calls["ws-own"])
+	}
+	now = now.Add(2 * time.Minute)
+	w.Resolve(context.Background(), "ws-own")
+	if calls["ws-own"] != 2 {
+		t.Errorf("expired entry was not reloaded")
+	}
+
+	settings, registry, err = w.Resolve(context.Background(), "ws-down")
+	if err == nil || registry != baseRegistry || settings.BraveAPIKey != "global-brave" {
+		t.Errorf("loader failure should fall back to global settings, got err=%v", err)
+	}
+	w.Resolve(context.Background(), "ws-down")
+	if calls["ws-down"] != 1 {
+		t.Errorf("failed lookup retried before the retry delay")
+	}
+
+	if _, registry, _ := w.Resolve(context.Background(), ""); registry != baseRegistry {
+		t.Error("no workspace should use the global registry")
+	}
+}
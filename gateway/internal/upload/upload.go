@@ -0,0 +1,478 @@
+// Package upload implements resumable uploads: a client opens a session
+// for a file of known size and SHA-256, sends it in chunks at explicit
+// offsets, can ask how much has arrived after an interruption, and
+// finalizes once every byte is in. Finalizing runs in the background; the
+// session records its outcome until it is collected. Sessions live on local
+// disk next to their partial data and are garbage-collected when abandoned.
+package upload
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound         = errors.New("upload session not found")
+	ErrOffsetMismatch   = errors.New("chunk offset does not match upload offset")
+	ErrTooLarge         = errors.New("upload exceeds declared size")
+	ErrIncomplete       = errors.New("upload is incomplete")
+	ErrChecksumMismatch = errors.New("upload checksum mismatch; the data was discarded, upload it again from offset 0")
+	ErrInvalidChecksum  = errors.New("checksum must be a hex sha256 digest")
+	ErrNotRetryable     = errors.New("upload failed and cannot be retried")
+	ErrFinalized        = errors.New("upload is already being finalized")
+)
+
+// Session statuses. A session is uploading until Begin hands it to
+// finalization, which ends in completed or failed. A failed session whose
+// data was kept may be finalized again.
+const (
+	StatusUploading  = "uploading"
+	StatusProcessing = "processing"
+	StatusCompleted  = "completed"
+	StatusFailed     = "failed"
+)
+
+// Session is the persisted state of one upload. DocumentID is set once it is
+// completed; Code and Error describe why it failed.
+type Session struct {
+	ID              string    `json:"id"`
+	KnowledgeBaseID string    `json:"knowledgeBaseId"`
+	UserID          string    `json:"userId"`
+	FileName        string    `json:"fileName"`
+	Size            int64     `json:"size"`
+	Offset          int64     `json:"offset"`
+	Checksum        string    `json:"checksum"`
+	Status          string    `json:"status"`
+	DocumentID      string    `json:"documentId,omitempty"`
+	Code            string    `json:"code,omitempty"`
+	Error           string    `json:"error,omitempty"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+// Complete reports whether every declared byte has been received.
+func (s Session) Complete() bool {
+	return s.Offset == s.Size
+}
+
+// Options configures a Store.
+type Options struct {
+	Dir          string
+	MaxFileBytes int64
+	// MaxChunkBytes bounds a single WriteChunk call.
+	MaxChunkBytes int64
+	// TTL is how long a session may sit idle before it is collected.
+	TTL time.Duration
+}
+
+// Store keeps sessions as <id>.json plus <id>.part under Dir.
+type Store struct {
+	opts Options
+	now  func() time.Time
+
+	mu    sync.Mutex
+	locks map[string]*sessionLock
+}
+
+// sessionLock serializes access to one session. It is dropped from
+// Store.locks once nobody holds or waits for it.
+type sessionLock struct {
+	sync.Mutex
+	refs int
+}
+
+func NewStore(opts Options) (*Store, error) {
+	if opts.MaxChunkBytes <= 0 {
+		opts.MaxChunkBytes = 8 << 20
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = 24 * time.Hour
+	}
+	dir, err := filepath.Abs(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	opts.Dir = dir
+	s := &Store{opts: opts, now: time.Now, locks: map[string]*sessionLock{}}
+	s.failInterrupted()
+	return s, nil
+}
+
+// failInterrupted marks sessions that were being finalized when the process
+// stopped as failed, so their clients can retry.
+func (s *Store) failInterrupted() {
+	entries, err := os.ReadDir(s.opts.Dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		if sess, err := s.load(id); err == nil && sess.Status == StatusProcessing {
+			sess.Status, sess.Code, sess.Error = StatusFailed, "INTERRUPTED", "processing was interrupted; retry completion"
+			s.save(sess)
+		}
+	}
+}
+
+// MaxChunkBytes is the largest chunk WriteChunk accepts.
+func (s *Store) MaxChunkBytes() int64 {
+	return s.opts.MaxChunkBytes
+}
+
+// Create opens a session for in. The checksum is normalized to lower-case
+// hex, with an optional "sha256:" prefix accepted.
+func (s *Store) Create(in Session) (Session, error) {
+	sum := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(in.Checksum), "sha256:"))
+	if raw, err := hex.DecodeString(sum); err != nil || len(raw) != sha256.Size {
+		return Session{}, ErrInvalidChecksum
+	}
+	if in.Size <= 0 {
+		return Session{}, fmt.Errorf("size must be positive")
+	}
+	if s.opts.MaxFileBytes > 0 && in.Size > s.opts.MaxFileBytes {
+		return Session{}, ErrTooLarge
+	}
+	now := s.now().UTC()
+	sess := Session{
+		ID:              uuid.NewString(),
+		KnowledgeBaseID: in.KnowledgeBaseID,
+		UserID:          in.UserID,
+		FileName:        in.FileName,
+		Size:            in.Size,
+		Checksum:        sum,
+		Status:          StatusUploading,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	part, err := os.OpenFile(s.partPath(sess.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return Session{}, err
+	}
+	part.Close()
+	if err := s.save(sess); err != nil {
+		os.Remove(s.partPath(sess.ID))
+		return Session{}, err
+	}
+	return sess, nil
+}
+
+// Get loads a session.
+func (s *Store) Get(id string) (Session, error) {
+	unlock := s.lock(id)
+	defer unlock()
+	return s.load(id)
+}
+
+// WriteChunk appends up to MaxChunkBytes from r at offset, which must equal
+// the bytes received so far. A chunk cut short by a dropped connection
+// keeps what arrived, so the client resumes from the returned offset; so
+// does a chunk that runs past MaxChunkBytes or the declared size, which
+// also fails with ErrTooLarge.
+func (s *Store) WriteChunk(id string, offset int64, r io.Reader) (Session, error) {
+	unlock := s.lock(id)
+	defer unlock()
+	sess, err := s.load(id)
+	if err != nil {
+		return Session{}, err
+	}
+	if sess.Status != StatusUploading {
+		return sess, ErrFinalized
+	}
+	if offset != sess.Offset {
+		return sess, ErrOffsetMismatch
+	}
+	part, err := os.OpenFile(s.partPath(id), os.O_WRONLY, 0o644)
+	if err != nil {
+		return sess, err
+	}
+	defer part.Close()
+	// Drop anything past the recorded offset left by an earlier write that
+	// failed before its metadata was saved.
+	if err := part.Truncate(sess.Offset); err != nil {
+		return sess, err
+	}
+	if _, err := part.Seek(sess.Offset, io.SeekStart); err != nil {
+		return sess, err
+	}
+
+	limit := min(s.opts.MaxChunkBytes, sess.Size-sess.Offset)
+	written, copyErr := io.Copy(part, io.LimitReader(r, limit))
+	sess.Offset += written
+	sess.UpdatedAt = s.now().UTC()
+	if err := s.save(sess); err != nil {
+		return sess, err
+	}
+	if copyErr != nil {
+		return sess, copyErr
+	}
+	// Anything left in r did not fit in the chunk or the declared size.
+	var probe [1]byte
+	if n, _ := r.Read(probe[:]); n > 0 {
+		return sess, ErrTooLarge
+	}
+	return sess, nil
+}
+
+// Begin moves a complete session to processing. started is false when it is
+// already processing or completed, so repeated completion requests start one
+// finalization. A failed session is started again unless its data is gone.
+func (s *Store) Begin(id string) (sess Session, started bool, err error) {
+	unlock := s.lock(id)
+	defer unlock()
+	sess, err = s.load(id)
+	if err != nil {
+		return Session{}, false, err
+	}
+	switch sess.Status {
+	case StatusProcessing, StatusCompleted:
+		return sess, false, nil
+	case StatusFailed:
+		if _, err := os.Stat(s.partPath(id)); err != nil {
+			return sess, false, ErrNotRetryable
+		}
+	}
+	if !sess.Complete() {
+		return sess, false, ErrIncomplete
+	}
+	sess.Status, sess.Code, sess.Error = StatusProcessing, "", ""
+	sess.UpdatedAt = s.now().UTC()
+	if err := s.save(sess); err != nil {
+		return sess, false, err
+	}
+	return sess, true, nil
+}
+
+// Finish records a completed finalization and drops the session's data; the
+// session itself stays, so the client can read the document ID, until it is
+// collected.
+func (s *Store) Finish(id, documentID string) error {
+	return s.settle(id, func(sess *Session) {
+		sess.Status, sess.DocumentID = StatusCompleted, documentID
+	}, true)
+}
+
+// Fail records a failed finalization. With discard the data is dropped and
+// the session cannot be retried.
+func (s *Store) Fail(id, code, message string, discard bool) error {
+	return s.settle(id, func(sess *Session) {
+		sess.Status, sess.Code, sess.Error = StatusFailed, code, message
+	}, discard)
+}
+
+func (s *Store) settle(id string, apply func(*Session), dropData bool) error {
+	unlock := s.lock(id)
+	defer unlock()
+	sess, err := s.load(id)
+	if err != nil {
+		return err
+	}
+	apply(&sess)
+	sess.UpdatedAt = s.now().UTC()
+	if err := s.save(sess); err != nil {
+		return err
+	}
+	if dropData {
+		os.Remove(s.partPath(id))
+	}
+	return nil
+}
+
+// Assemble verifies a complete session's checksum and returns its data
+// opened at the start. The session is kept until Remove, so a caller that
+// fails to store the data can let the client retry completion.
+//
+// Data that does not match the checksum cannot become valid by retrying, so
+// on ErrChecksumMismatch the data is dropped and the session is back to
+// uploading from offset 0, with the mismatch recorded in Code and Error.
+func (s *Store) Assemble(id string) (Session, *os.File, error) {
+	unlock := s.lock(id)
+	defer unlock()
+	sess, err := s.load(id)
+	if err != nil {
+		return Session{}, nil, err
+	}
+	if !sess.Complete() {
+		return sess, nil, ErrIncomplete
+	}
+	part, err := os.Open(s.partPath(id))
+	if err != nil {
+		return sess, nil, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, part); err != nil {
+		part.Close()
+		return sess, nil, err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != sess.Checksum {
+		part.Close()
+		if err := os.Truncate(s.partPath(id), 0); err != nil {
+			return sess, nil, err
+		}
+		sess.Status, sess.Offset = StatusUploading, 0
+		sess.Code, sess.Error = "CHECKSUM_MISMATCH", ErrChecksumMismatch.Error()
+		sess.UpdatedAt = s.now().UTC()
+		if err := s.save(sess); err != nil {
+			return sess, nil, err
+		}
+		return sess, nil, ErrChecksumMismatch
+	}
+	if _, err := part.Seek(0, io.SeekStart); err != nil {
+		part.Close()
+		return sess, nil, err
+	}
+	return sess, part, nil
+}
+
+// Remove deletes a session and its data.
+func (s *Store) Remove(id string) error {
+	unlock := s.lock(id)
+	defer unlock()
+	if _, err := s.load(id); err != nil {
+		return err
+	}
+	s.remove(id)
+	return nil
+}
+
+// Collect removes sessions idle for longer than the TTL and returns how
+// many it removed.
+func (s *Store) Collect() int {
+	entries, err := os.ReadDir(s.opts.Dir)
+	if err != nil {
+		slog.Warn("upload: list sessions failed", slog.Any("err", err))
+		return 0
+	}
+	cutoff := s.now().Add(-s.opts.TTL)
+	removed := 0
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			// A .part without metadata is left by a crash inside Create.
+			if id, ok := strings.CutSuffix(entry.Name(), ".part"); ok {
+				if _, err := os.Stat(s.metaPath(id)); errors.Is(err, os.ErrNotExist) {
+					if info, err := entry.Info(); err == nil && info.ModTime().Before(cutoff) {
+						os.Remove(filepath.Join(s.opts.Dir, entry.Name()))
+					}
+				}
+			}
+			continue
+		}
+		unlock := s.lock(id)
+		sess, err := s.load(id)
+		if err == nil && sess.UpdatedAt.Before(cutoff) {
+			s.remove(id)
+			removed++
+		}
+		unlock()
+	}
+	return removed
+}
+
+// RunCollector calls Collect every interval until ctx is done.
+func (s *Store) RunCollector(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n := s.Collect(); n > 0 {
+				slog.Info("upload: collected abandoned sessions", slog.Int("sessions", n))
+			}
+		}
+	}
+}
+
+// lock serializes access to session id and returns the matching unlock.
+// Locks are reference-counted so the map only holds sessions in use.
+func (s *Store) lock(id string) func() {
+	s.mu.Lock()
+	l, ok := s.locks[id]
+	if !ok {
+		l = &sessionLock{}
+		s.locks[id] = l
+	}
+	l.refs++
+	s.mu.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		s.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(s.locks, id)
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *Store) load(id string) (Session, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return Session{}, ErrNotFound
+	}
+	raw, err := os.ReadFile(s.metaPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return Session{}, ErrNotFound
+	}
+	if err != nil {
+		return Session{}, err
+	}
+	var sess Session
+	if err := json.Unmarshal(raw, &sess); err != nil {
+		return Session{}, fmt.Errorf("decode upload session %s: %w", id, err)
+	}
+	if sess.Status == "" {
+		// Written before sessions had a status.
+		sess.Status = StatusUploading
+	}
+	return sess, nil
+}
+
+// save writes the metadata through a temp file so a crash never leaves a
+// truncated session behind.
+func (s *Store) save(sess Session) error {
+	raw, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+	tmp := s.metaPath(sess.ID) + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.metaPath(sess.ID))
+}
+
+func (s *Store) remove(id string) {
+	os.Remove(s.partPath(id))
+	os.Remove(s.metaPath(id))
+}
+
+func (s *Store) metaPath(id string) string {
+	return filepath.Join(s.opts.Dir, id+".json")
+}
+
+func (s *Store) partPath(id string) string {
+	return filepath.Join(s.opts.Dir, id+".part")
+}
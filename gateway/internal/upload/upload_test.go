@@ -0,0 +1,219 @@
+package upload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := NewStore(Options{Dir: t.TempDir(), MaxFileBytes: 1 << 20, MaxChunkBytes: 4, TTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func checksum(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// failingReader yields data and then a connection error, like a client that
+// drops mid-chunk.
+type failingReader struct{ data string }
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, io.ErrUnexpectedEOF
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestResumableUpload(t *testing.T) {
+	store := newTestStore(t)
+	const data = "hello, world"
+	sess, err := store.Create(Session{KnowledgeBaseID: "kb", UserID: "u", FileName: "a.txt", Size: int64(len(data)), Checksum: "sha256:" + strings.ToUpper(checksum(data))})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if sess, err = store.WriteChunk(sess.ID, 0, strings.NewReader("hell")); err != nil || sess.Offset != 4 {
+		t.Fatalf("first chunk: offset=%d err=%v", sess.Offset, err)
+	}
+	if _, err := store.WriteChunk(sess.ID, 0, strings.NewReader("hell")); !errors.Is(err, ErrOffsetMismatch) {
+		t.Fatalf("replayed chunk err = %v, want offset mismatch", err)
+	}
+	// A dropped connection keeps what arrived.
+	if sess, err = store.WriteChunk(sess.ID, 4, &failingReader{data: "o,"}); err == nil || sess.Offset != 6 {
+		t.Fatalf("interrupted chunk: offset=%d err=%v", sess.Offset, err)
+	}
+	if _, err := store.WriteChunk(sess.ID, 6, strings.NewReader(" wor-too-long")); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("oversized chunk err = %v, want too large", err)
+	}
+	if _, _, err := store.Assemble(sess.ID); !errors.Is(err, ErrIncomplete) {
+		t.Fatalf("early assemble err = %v, want incomplete", err)
+	}
+	if sess, err = store.Get(sess.ID); err != nil || sess.Offset != 10 {
+		t.Fatalf("progress: offset=%d err=%v", sess.Offset, err)
+	}
+	if sess, err = store.WriteChunk(sess.ID, 10, strings.NewReader("ld")); err != nil || !sess.Complete() {
+		t.Fatalf("last chunk: %+v err=%v", sess, err)
+	}
+
+	for i := 0; i < 2; i++ { // completion may be retried until Remove
+		_, f, err := store.Assemble(sess.ID)
+		if err != nil {
+			t.Fatalf("Assemble: %v", err)
+		}
+		got, _ := io.ReadAll(f)
+		f.Close()
+		if string(got) != data {
+			t.Fatalf("assembled %q, want %q", got, data)
+		}
+	}
+	if err := store.Remove(sess.ID); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := store.Get(sess.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("removed session err = %v", err)
+	}
+}
+
+func TestAssembleRejectsChecksumMismatch(t *testing.T) {
+	store := newTestStore(t)
+	sess, err := store.Create(Session{FileName: "a.txt", Size: 3, Checksum: checksum("abc")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.WriteChunk(sess.ID, 0, strings.NewReader("abd"))
+	if _, _, err := store.Assemble(sess.ID); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("err = %v, want checksum mismatch", err)
+	}
+	// The bad data is dropped and the client can send the file again.
+	reset, err := store.Get(sess.ID)
+	if err != nil || reset.Offset != 0 || reset.Status != StatusUploading || reset.Code != "CHECKSUM_MISMATCH" {
+		t.Fatalf("after mismatch: %+v, %v", reset, err)
+	}
+	if _, err := store.WriteChunk(sess.ID, 0, strings.NewReader("abc")); err != nil {
+		t.Fatalf("re-upload: %v", err)
+	}
+	_, data, err := store.Assemble(sess.ID)
+	if err != nil {
+		t.Fatalf("Assemble after re-upload: %v", err)
+	}
+	data.Close()
+	if _, err := store.Create(Session{Size: 3, Checksum: "md5:abc"}); !errors.Is(err, ErrInvalidChecksum) {
+		t.Errorf("bad checksum err = %v", err)
+	}
+	if _, err := store.Create(Session{Size: 2 << 20, Checksum: checksum("")}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("oversized file err = %v", err)
+	}
+}
+
+func TestCollectAbandonedSessions(t *testing.T) {
+	store := newTestStore(t)
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	stale, _ := store.Create(Session{Size: 3, Checksum: checksum("abc")})
+	now = now.Add(30 * time.Minute)
+	fresh, _ := store.Create(Session{Size: 3, Checksum: checksum("abc")})
+
+	now = now.Add(45 * time.Minute)
+	if n := store.Collect(); n != 1 {
+		t.Fatalf("collected %d sessions, want 1", n)
+	}
+	if _, err := store.Get(stale.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("stale session still present: %v", err)
+	}
+	if _, err := os.Stat(store.partPath(stale.ID)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("stale data still present: %v", err)
+	}
+	if _, err := store.Get(fresh.ID); err != nil {
+		t.Errorf("fresh session collected: %v", err)
+	}
+}
+
+func TestFinalizeLifecycle(t *testing.T) {
+	store := newTestStore(t)
+	sess, _ := store.Create(Session{Size: 3, Checksum: checksum("abc")})
+	if _, _, err := store.Begin(sess.ID); !errors.Is(err, ErrIncomplete) {
+		t.Fatalf("Begin on partial upload err = %v, want incomplete", err)
+	}
+	store.WriteChunk(sess.ID, 0, strings.NewReader("abc"))
+
+	if _, started, err := store.Begin(sess.ID); err != nil || !started {
+		t.Fatalf("Begin: started=%v err=%v", started, err)
+	}
+	if got, started, _ := store.Begin(sess.ID); started || got.Status != StatusProcessing {
+		t.Fatalf("second Begin started=%v status=%s, want one finalization", started, got.Status)
+	}
+	if _, err := store.WriteChunk(sess.ID, 3, strings.NewReader("x")); !errors.Is(err, ErrFinalized) {
+		t.Errorf("chunk after Begin err = %v", err)
+	}
+
+	// A failure that keeps the data can be retried.
+	store.Fail(sess.ID, "UNAVAILABLE", "service down", false)
+	if _, started, err := store.Begin(sess.ID); err != nil || !started {
+		t.Fatalf("retry Begin: started=%v err=%v", started, err)
+	}
+	if err := store.Finish(sess.ID, "doc-1"); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := store.Get(sess.ID)
+	if got.Status != StatusCompleted || got.DocumentID != "doc-1" || got.Code != "" {
+		t.Errorf("completed session = %+v", got)
+	}
+	if _, err := os.Stat(store.partPath(sess.ID)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("completed data still present: %v", err)
+	}
+
+	// A discarded failure cannot.
+	other, _ := store.Create(Session{Size: 3, Checksum: checksum("abc")})
+	store.WriteChunk(other.ID, 0, strings.NewReader("abc"))
+	store.Begin(other.ID)
+	store.Fail(other.ID, "MALWARE_DETECTED", "infected", true)
+	if _, _, err := store.Begin(other.ID); !errors.Is(err, ErrNotRetryable) {
+		t.Errorf("Begin after discard err = %v, want not retryable", err)
+	}
+}
+
+func TestNewStoreFailsInterruptedFinalization(t *testing.T) {
+	store := newTestStore(t)
+	sess, _ := store.Create(Session{Size: 3, Checksum: checksum("abc")})
+	store.WriteChunk(sess.ID, 0, strings.NewReader("abc"))
+	store.Begin(sess.ID)
+
+	reopened, err := NewStore(store.opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := reopened.Get(sess.ID)
+	if got.Status != StatusFailed || got.Code != "INTERRUPTED" {
+		t.Fatalf("after restart status=%s code=%s, want failed/INTERRUPTED", got.Status, got.Code)
+	}
+	if _, started, err := reopened.Begin(sess.ID); err != nil || !started {
+		t.Errorf("retry after restart: started=%v err=%v", started, err)
+	}
+}
+
+func TestLocksAreReleased(t *testing.T) {
+	store := newTestStore(t)
+	sess, _ := store.Create(Session{Size: 3, Checksum: checksum("abc")})
+	store.Get(sess.ID)
+	store.Get("00000000-0000-0000-0000-000000000000")
+	store.Get("not-a-uuid")
+	store.WriteChunk(sess.ID, 0, strings.NewReader("abc"))
+	store.Remove(sess.ID)
+	if n := len(store.locks); n != 0 {
+		t.Errorf("%d session locks left behind", n)
+	}
+}
@@ -0,0 +1,258 @@
+// Package walq holds what the gateway's durable queues share: an
+// append-only JSON-lines log that is replayed on open and rewritten once
+// enough of it is superseded, the FIFO of entries awaiting an attempt, and
+// a scheduler that hands due entries to a bounded pool of workers.
+//
+// The queues keep their own entry and record types and their own mutex.
+// Log and Pending are not safe for concurrent use and are only touched
+// under that mutex; Scheduler is.
+package walq
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// compactAfter is how many superseded records trigger a log rewrite.
+const compactAfter = 1024
+
+// Log is a write-ahead log of records of type R. Every record goes through
+// apply, whether appended or replayed, so the owner's in-memory state is
+// always what the log describes.
+type Log[R any] struct {
+	path     string
+	name     string
+	apply    func(R)
+	snapshot func(emit func(R) error) error
+	file     *os.File
+	garbage  int
+}
+
+// OpenLog replays the log at path through apply and compacts it. snapshot
+// writes the owner's current state as records, and may first drop state
+// that has expired; name prefixes log messages.
+func OpenLog[R any](path, name string, apply func(R), snapshot func(emit func(R) error) error) (*Log[R], error) {
+	l := &Log[R]{path: path, name: name, apply: apply, snapshot: snapshot}
+	if err := l.replay(); err != nil {
+		return nil, err
+	}
+	if err := l.Compact(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log[R]) replay() error {
+	f, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for scanner.Scan() {
+		var rec R
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// A torn final line from a crash mid-append; compaction drops it.
+			slog.Warn(l.name+": skipping unreadable log record", slog.Any("err", err))
+			continue
+		}
+		l.apply(rec)
+	}
+	return scanner.Err()
+}
+
+// Append applies rec and writes it to the log, fsyncing when sync is set.
+func (l *Log[R]) Append(rec R, sync bool) error {
+	l.apply(rec)
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if sync {
+		return l.file.Sync()
+	}
+	return nil
+}
+
+// Supersede counts n records that no longer describe live state.
+func (l *Log[R]) Supersede(n int) {
+	l.garbage += n
+}
+
+// Compact rewrites the log as the owner's snapshot.
+func (l *Log[R]) Compact() error {
+	tmp := l.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	err = l.snapshot(func(rec R) error { return enc.Encode(rec) })
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, l.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if l.file != nil {
+		l.file.Close()
+	}
+	l.file, err = os.OpenFile(l.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	l.garbage = 0
+	return err
+}
+
+// MaybeCompact compacts once enough records have been superseded.
+func (l *Log[R]) MaybeCompact() {
+	if l.garbage < compactAfter {
+		return
+	}
+	if err := l.Compact(); err != nil {
+		slog.Warn(l.name+": compact failed", slog.Any("err", err))
+	}
+}
+
+// Close closes the log file.
+func (l *Log[R]) Close() error {
+	return l.file.Close()
+}
+
+// Pending is the arrival-ordered list of entry IDs awaiting an attempt,
+// and which of them a worker has claimed. The zero value is empty.
+type Pending struct {
+	ids      []string
+	inflight map[string]bool
+}
+
+// Push queues id behind the entries already pending.
+func (p *Pending) Push(id string) {
+	p.ids = append(p.ids, id)
+}
+
+// Remove unqueues id.
+func (p *Pending) Remove(id string) {
+	for i, pid := range p.ids {
+		if pid == id {
+			p.ids = append(p.ids[:i], p.ids[i+1:]...)
+			return
+		}
+	}
+}
+
+// Len reports how many entries are pending, claimed or not.
+func (p *Pending) Len() int {
+	return len(p.ids)
+}
+
+// Claim takes the oldest unclaimed entry whose next attempt is due, or
+// reports how long until one is.
+func (p *Pending) Claim(now time.Time, next func(id string) time.Time) (string, time.Duration, bool) {
+	wait := time.Minute
+	for _, id := range p.ids {
+		if p.inflight[id] {
+			continue
+		}
+		if d := next(id).Sub(now); d > 0 {
+			wait = min(wait, d)
+			continue
+		}
+		if p.inflight == nil {
+			p.inflight = map[string]bool{}
+		}
+		p.inflight[id] = true
+		return id, 0, true
+	}
+	return "", wait, false
+}
+
+// Done releases a claim once its attempt is settled.
+func (p *Pending) Done(id string) {
+	delete(p.inflight, id)
+}
+
+// Backoff doubles from base up to limit: attempt 1 waits base.
+func Backoff(base, limit time.Duration, attempt int) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < limit; i++ {
+		d *= 2
+	}
+	return min(d, limit)
+}
+
+// Scheduler runs claimed entries of type T on a bounded pool of workers.
+type Scheduler[T any] struct {
+	concurrency int
+	wake        chan struct{}
+}
+
+// NewScheduler returns a scheduler running at most concurrency workers.
+func NewScheduler[T any](concurrency int) *Scheduler[T] {
+	return &Scheduler[T]{concurrency: concurrency, wake: make(chan struct{}, 1)}
+}
+
+// Signal wakes Run to claim again, after an entry was queued or settled.
+func (s *Scheduler[T]) Signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run claims entries and hands each to work on its own goroutine until ctx
+// ends, then waits for the workers. claim reports how long to wait when
+// nothing is due; Signal cuts the wait short.
+func (s *Scheduler[T]) Run(ctx context.Context, claim func() (T, time.Duration, bool), work func(T)) {
+	sem := make(chan struct{}, s.concurrency)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		entry, wait, ok := claim()
+		if !ok {
+			<-sem
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-s.wake:
+			case <-timer.C:
+			}
+			timer.Stop()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			work(entry)
+		}()
+	}
+}
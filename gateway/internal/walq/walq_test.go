@@ -0,0 +1,187 @@
+package walq
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type rec struct {
+	Op  string `json:"op"`
+	Key string `json:"key"`
+}
+
+// kv is a minimal log owner: set and del records over a map.
+type kv struct{ m map[string]bool }
+
+func (s *kv) apply(r rec) {
+	switch r.Op {
+	case "set":
+		s.m[r.Key] = true
+	case "del":
+		delete(s.m, r.Key)
+	}
+}
+
+func (s *kv) snapshot(emit func(rec) error) error {
+	for k := range s.m {
+		if err := emit(rec{Op: "set", Key: k}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func openKV(t *testing.T, path string) (*kv, *Log[rec]) {
+	t.Helper()
+	s := &kv{m: map[string]bool{}}
+	l, err := OpenLog(path, "test", s.apply, s.snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return s, l
+}
+
+func TestLogReplaysAndCompacts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	s, l := openKV(t, path)
+	for _, r := range []rec{{"set", "a"}, {"set", "b"}, {"del", "a"}} {
+		if err := l.Append(r, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !s.m["b"] || s.m["a"] {
+		t.Fatalf("state = %v", s.m)
+	}
+	l.Close()
+
+	// A torn final line is skipped on replay.
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	f.WriteString(`{"op":"set","ke`)
+	f.Close()
+
+	s, l = openKV(t, path)
+	if len(s.m) != 1 || !s.m["b"] {
+		t.Fatalf("replayed state = %v", s.m)
+	}
+	raw, _ := os.ReadFile(path)
+	if string(raw) != `{"op":"set","key":"b"}`+"\n" {
+		t.Errorf("compacted log = %q", raw)
+	}
+	if err := l.Append(rec{"set", "c"}, false); err != nil {
+		t.Fatalf("append after compaction: %v", err)
+	}
+}
+
+func TestLogMaybeCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	_, l := openKV(t, path)
+	for i := 0; i < 3; i++ {
+		l.Append(rec{"set", "a"}, false)
+	}
+	l.Supersede(compactAfter - 1)
+	l.MaybeCompact()
+	if raw, _ := os.ReadFile(path); len(raw) == 0 || string(raw) == `{"op":"set","key":"a"}`+"\n" {
+		t.Fatalf("compacted before the threshold: %q", raw)
+	}
+	l.Supersede(1)
+	l.MaybeCompact()
+	if raw, _ := os.ReadFile(path); string(raw) != `{"op":"set","key":"a"}`+"\n" {
+		t.Errorf("log after threshold = %q", raw)
+	}
+}
+
+func TestPendingClaim(t *testing.T) {
+	now := time.Now()
+	next := map[string]time.Time{"a": now.Add(time.Second), "b": now, "c": now.Add(-time.Second)}
+	due := func(id string) time.Time { return next[id] }
+	var p Pending
+	p.Push("a")
+	p.Push("b")
+	p.Push("c")
+
+	for _, want := range []string{"b", "c"} {
+		if id, _, ok := p.Claim(now, due); !ok || id != want {
+			t.Fatalf("claim = %q, %v, want %q", id, ok, want)
+		}
+	}
+	if _, wait, ok := p.Claim(now, due); ok || wait != time.Second {
+		t.Fatalf("claim with nothing due = %v, wait %v", ok, wait)
+	}
+	p.Done("b")
+	if id, _, ok := p.Claim(now, due); !ok || id != "b" {
+		t.Errorf("released entry not claimable: %q, %v", id, ok)
+	}
+	p.Remove("b")
+	if p.Len() != 2 {
+		t.Errorf("len = %d, want 2", p.Len())
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 30: 5 * time.Second} {
+		if got := Backoff(time.Second, 5*time.Second, attempt); got != want {
+			t.Errorf("Backoff(attempt %d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+func TestSchedulerBoundsWorkers(t *testing.T) {
+	s := NewScheduler[int](2)
+	var mu sync.Mutex
+	queue := []int{1, 2, 3, 4, 5}
+	claim := func() (int, time.Duration, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(queue) == 0 {
+			return 0, time.Minute, false
+		}
+		n := queue[0]
+		queue = queue[1:]
+		return n, 0, true
+	}
+	var running, peak int
+	var done atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan struct{})
+	go func() {
+		s.Run(ctx, claim, func(int) {
+			mu.Lock()
+			running++
+			peak = max(peak, running)
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			done.Add(1)
+		})
+		close(finished)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for done.Load() < 5 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	// A late entry is picked up after Signal rather than the minute wait.
+	mu.Lock()
+	queue = append(queue, 6)
+	mu.Unlock()
+	s.Signal()
+	for done.Load() < 6 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-finished
+	if done.Load() != 6 {
+		t.Fatalf("ran %d entries, want 6", done.Load())
+	}
+	if peak > 2 {
+		t.Errorf("peak workers = %d, want at most 2", peak)
+	}
+}
@@ -0,0 +1,26 @@
+// Package walqtest helps tests drive queues built on package walq.
+package walqtest
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// RunUntil calls run, which should block running a queue until its context
+// ends, until cond holds or a second passes.
+func RunUntil(t *testing.T, run func(ctx context.Context), cond func() bool) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { run(ctx); close(done) }()
+	deadline := time.Now().Add(time.Second)
+	for !cond() && time.Now().Before(deadline) {
+		time.Sleep(2 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	if !cond() {
+		t.Fatal("condition not reached")
+	}
+}
@@ -0,0 +1,426 @@
+// Package webhookq is the gateway's durable queue for inbound channel
+// webhooks. An event is appended to a write-ahead log before the platform
+// is acknowledged, then delivered to the service with retries and
+// exponential backoff. Events that keep failing, or fail permanently, are
+// kept as dead letters until someone retries or drops them.
+package webhookq
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/walq"
+)
+
+var (
+	ErrNotFound = errors.New("webhook event not found")
+	ErrFull     = errors.New("webhook queue is full")
+	// ErrUnverifiedFull refuses an unverified event once the share of the
+	// queue reserved for them is used up.
+	ErrUnverifiedFull = errors.New("webhook queue is full of unverified events")
+	ErrClosed         = errors.New("webhook queue is closed")
+)
+
+// Event is one inbound webhook request.
+type Event struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channelId"`
+	// EventID is the platform's own event ID, used to drop redeliveries.
+	EventID string              `json:"eventId,omitempty"`
+	Body    string              `json:"body"`
+	Headers map[string][]string `json:"headers"`
+	// Verified is false when the signature could not be checked at
+	// ingress; the service then checks it on delivery.
+	Verified      bool       `json:"verified"`
+	ReceivedAt    time.Time  `json:"receivedAt"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"lastError,omitempty"`
+	NextAttemptAt time.Time  `json:"nextAttemptAt"`
+	DeadAt        *time.Time `json:"deadAt,omitempty"`
+}
+
+// dedupeKey identifies an event for duplicate detection: the platform
+// event ID when there is one, otherwise a hash of the body.
+func (e Event) dedupeKey() string {
+	id := e.EventID
+	if id == "" {
+		sum := sha256.Sum256([]byte(e.Body))
+		id = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	return e.ChannelID + "\x00" + id
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a delivery error as not worth retrying; the event goes
+// straight to the dead letters.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// Options configures a Queue.
+type Options struct {
+	Dir string
+	// Sync fsyncs the log after every enqueue, before the platform is
+	// acknowledged.
+	Sync bool
+	// MaxEvents bounds pending plus dead events.
+	MaxEvents int
+	// MaxUnverified and MaxUnverifiedBytes bound the unverified events
+	// among them. Anyone can post one, so they get a small share of the
+	// queue and cannot crowd out verified deliveries.
+	MaxUnverified      int
+	MaxUnverifiedBytes int64
+	// DedupeWindow is how long an event ID is remembered.
+	DedupeWindow time.Duration
+	MaxAttempts  int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	Concurrency  int
+	// OnDone, when set, is called with an event once it leaves the queue
+	// for good: acknowledged, or dropped from the dead letters.
+	OnDone func(Event)
+}
+
+// DeliverFunc hands an event to the service. A nil error acknowledges it.
+type DeliverFunc func(ctx context.Context, ev Event) error
+
+// record is one line of the write-ahead log.
+type record struct {
+	Op    string    `json:"op"` // enqueue | attempt | ack | dead | retry | drop | seen
+	Event *Event    `json:"event,omitempty"`
+	ID    string    `json:"id,omitempty"`
+	Key   string    `json:"key,omitempty"`
+	Error string    `json:"error,omitempty"`
+	At    time.Time `json:"at"`
+	Next  time.Time `json:"next,omitempty"`
+}
+
+// Queue is the write-ahead-logged queue. The whole state is kept in memory
+// and rebuilt from the log on Open.
+type Queue struct {
+	opts  Options
+	now   func() time.Time
+	sched *walq.Scheduler[Event]
+
+	mu      sync.Mutex
+	log     *walq.Log[record]
+	events  map[string]*Event
+	pending walq.Pending
+	seen    map[string]time.Time
+	closed  bool
+
+	// Live unverified events and the size of their bodies.
+	unverified      int
+	unverifiedBytes int64
+}
+
+// Open replays the log in opts.Dir and compacts it.
+func Open(opts Options) (*Queue, error) {
+	if opts.MaxEvents <= 0 {
+		opts.MaxEvents = 10000
+	}
+	if opts.MaxUnverified <= 0 {
+		opts.MaxUnverified = 100
+	}
+	if opts.MaxUnverifiedBytes <= 0 {
+		opts.MaxUnverifiedBytes = 16 << 20
+	}
+	if opts.DedupeWindow <= 0 {
+		opts.DedupeWindow = 24 * time.Hour
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 8
+	}
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = time.Second
+	}
+	if opts.MaxDelay < opts.BaseDelay {
+		opts.MaxDelay = 5 * time.Minute
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	q := &Queue{
+		opts:   opts,
+		now:    time.Now,
+		sched:  walq.NewScheduler[Event](opts.Concurrency),
+		events: map[string]*Event{},
+		seen:   map[string]time.Time{},
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	log, err := walq.OpenLog(filepath.Join(opts.Dir, "webhooks.wal"), "webhookq", q.apply, q.snapshot)
+	if err != nil {
+		return nil, err
+	}
+	q.log = log
+	return q, nil
+}
+
+// apply folds one record into the in-memory state.
+func (q *Queue) apply(rec record) {
+	switch rec.Op {
+	case "enqueue":
+		if rec.Event == nil {
+			return
+		}
+		ev := *rec.Event
+		q.events[ev.ID] = &ev
+		q.countUnverified(&ev, 1)
+		if ev.DeadAt == nil {
+			q.pending.Push(ev.ID)
+		}
+		q.seen[ev.dedupeKey()] = ev.ReceivedAt
+	case "seen":
+		q.seen[rec.Key] = rec.At
+	case "attempt":
+		if ev, ok := q.events[rec.ID]; ok {
+			ev.Attempts++
+			ev.LastError = rec.Error
+			ev.NextAttemptAt = rec.Next
+		}
+	case "dead":
+		if ev, ok := q.events[rec.ID]; ok {
+			at := rec.At
+			ev.Attempts++
+			ev.LastError = rec.Error
+			ev.DeadAt = &at
+			q.pending.Remove(rec.ID)
+		}
+	case "retry":
+		if ev, ok := q.events[rec.ID]; ok && ev.DeadAt != nil {
+			ev.DeadAt = nil
+			ev.Attempts = 0
+			ev.NextAttemptAt = rec.At
+			q.pending.Push(rec.ID)
+		}
+	case "ack", "drop":
+		q.countUnverified(q.events[rec.ID], -1)
+		delete(q.events, rec.ID)
+		q.pending.Remove(rec.ID)
+	}
+}
+
+// countUnverified adds (sign 1) or removes (sign -1) ev from the
+// unverified totals. Nil and verified events are ignored.
+func (q *Queue) countUnverified(ev *Event, sign int) {
+	if ev == nil || ev.Verified {
+		return
+	}
+	q.unverified += sign
+	q.unverifiedBytes += int64(sign * len(ev.Body))
+}
+
+// snapshot writes the current state for compaction: live events plus the
+// dedupe keys still inside the window.
+func (q *Queue) snapshot(emit func(record) error) error {
+	now := q.now()
+	live := map[string]bool{}
+	ids := make([]string, 0, len(q.events))
+	for id := range q.events {
+		ids = append(ids, id)
+	}
+	// Arrival order, so replay rebuilds the same pending order.
+	sort.Slice(ids, func(i, j int) bool {
+		return q.events[ids[i]].ReceivedAt.Before(q.events[ids[j]].ReceivedAt)
+	})
+	for _, id := range ids {
+		ev := q.events[id]
+		live[ev.dedupeKey()] = true
+		if err := emit(record{Op: "enqueue", Event: ev, At: now}); err != nil {
+			return err
+		}
+	}
+	for key, at := range q.seen {
+		if now.Sub(at) > q.opts.DedupeWindow {
+			delete(q.seen, key)
+			continue
+		}
+		if !live[key] {
+			if err := emit(record{Op: "seen", Key: key, At: at}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Enqueue durably records ev. It reports duplicate, without queueing,
+// when the same event was seen within the dedupe window.
+func (q *Queue) Enqueue(ev Event) (Event, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return Event{}, false, ErrClosed
+	}
+	now := q.now().UTC()
+	if at, ok := q.seen[ev.dedupeKey()]; ok && now.Sub(at) <= q.opts.DedupeWindow {
+		return ev, true, nil
+	}
+	if len(q.events) >= q.opts.MaxEvents {
+		return Event{}, false, ErrFull
+	}
+	if !ev.Verified && (q.unverified >= q.opts.MaxUnverified ||
+		q.unverifiedBytes+int64(len(ev.Body)) > q.opts.MaxUnverifiedBytes) {
+		return Event{}, false, ErrUnverifiedFull
+	}
+	ev.ID = uuid.NewString()
+	if ev.ReceivedAt.IsZero() {
+		ev.ReceivedAt = now
+	}
+	ev.Attempts, ev.LastError, ev.DeadAt = 0, "", nil
+	ev.NextAttemptAt = ev.ReceivedAt
+	if err := q.log.Append(record{Op: "enqueue", Event: &ev, At: now}, q.opts.Sync); err != nil {
+		// Not durable, so not accepted: forget it and let the platform
+		// redeliver.
+		q.countUnverified(q.events[ev.ID], -1)
+		delete(q.events, ev.ID)
+		delete(q.seen, ev.dedupeKey())
+		q.pending.Remove(ev.ID)
+		return Event{}, false, err
+	}
+	q.sched.Signal()
+	return ev, false, nil
+}
+
+// Run delivers due events on up to Concurrency goroutines until ctx ends.
+func (q *Queue) Run(ctx context.Context, deliver DeliverFunc) {
+	q.sched.Run(ctx, q.claim, func(ev Event) { q.settle(ev, deliver(ctx, ev)) })
+}
+
+// claim takes the oldest due event, or reports how long until one is due.
+func (q *Queue) claim() (Event, time.Duration, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	id, wait, ok := q.pending.Claim(q.now(), func(id string) time.Time { return q.events[id].NextAttemptAt })
+	if !ok {
+		return Event{}, wait, false
+	}
+	return *q.events[id], 0, true
+}
+
+// settle records the outcome of a delivery.
+func (q *Queue) settle(ev Event, err error) {
+	acked := false
+	defer func() {
+		if acked {
+			q.done(ev)
+		}
+	}()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending.Done(ev.ID)
+	if q.closed {
+		return
+	}
+	if _, ok := q.events[ev.ID]; !ok {
+		return
+	}
+	now := q.now().UTC()
+	var rec record
+	var permanent permanentError
+	switch {
+	case err == nil:
+		rec = record{Op: "ack", ID: ev.ID, At: now}
+		q.log.Supersede(2 + ev.Attempts)
+		acked = true
+	case errors.As(err, &permanent) || ev.Attempts+1 >= q.opts.MaxAttempts:
+		rec = record{Op: "dead", ID: ev.ID, Error: err.Error(), At: now}
+		slog.Warn("webhookq: event dead-lettered", slog.String("event", ev.ID),
+			slog.String("channel", ev.ChannelID), slog.Any("err", err))
+	default:
+		rec = record{Op: "attempt", ID: ev.ID, Error: err.Error(), At: now, Next: now.Add(walq.Backoff(q.opts.BaseDelay, q.opts.MaxDelay, ev.Attempts+1))}
+	}
+	q.log.Supersede(1)
+	if err := q.log.Append(rec, false); err != nil {
+		slog.Warn("webhookq: log append failed", slog.Any("err", err))
+	}
+	q.log.MaybeCompact()
+	q.sched.Signal()
+}
+
+// DeadLetters lists a channel's dead events, newest first.
+func (q *Queue) DeadLetters(channelID string) []Event {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var out []Event
+	for _, ev := range q.events {
+		if ev.DeadAt != nil && ev.ChannelID == channelID {
+			out = append(out, *ev)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].DeadAt.After(*out[j].DeadAt) })
+	return out
+}
+
+// Retry moves a dead event back to the queue with a fresh attempt budget.
+func (q *Queue) Retry(channelID, id string) (Event, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	ev, ok := q.events[id]
+	if !ok || ev.DeadAt == nil || ev.ChannelID != channelID {
+		return Event{}, ErrNotFound
+	}
+	if err := q.log.Append(record{Op: "retry", ID: id, At: q.now().UTC()}, q.opts.Sync); err != nil {
+		return Event{}, err
+	}
+	q.log.Supersede(1)
+	q.sched.Signal()
+	return *ev, nil
+}
+
+// Drop deletes a dead event.
+func (q *Queue) Drop(channelID, id string) error {
+	q.mu.Lock()
+	ev, ok := q.events[id]
+	if !ok || ev.DeadAt == nil || ev.ChannelID != channelID {
+		q.mu.Unlock()
+		return ErrNotFound
+	}
+	dropped := *ev
+	q.log.Supersede(2 + ev.Attempts)
+	err := q.log.Append(record{Op: "drop", ID: id, At: q.now().UTC()}, q.opts.Sync)
+	q.log.MaybeCompact()
+	q.mu.Unlock()
+	q.done(dropped)
+	return err
+}
+
+// done runs the OnDone hook, without q.mu held.
+func (q *Queue) done(ev Event) {
+	if q.opts.OnDone != nil {
+		q.opts.OnDone(ev)
+	}
+}
+
+// Close stops accepting events and closes the log. Deliveries still in
+// flight are not recorded and will be retried after restart.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	return q.log.Close()
+}
@@ -0,0 +1,208 @@
+package webhookq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/liukai/next-ai-agent-user-backend/gateway/internal/walq/walqtest"
+)
+
+func openQueue(t *testing.T, dir string) *Queue {
+	t.Helper()
+	q, err := Open(Options{Dir: dir, Sync: true, MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 4 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { q.Close() })
+	return q
+}
+
+// runUntil runs the queue until cond holds or a second passes.
+func runUntil(t *testing.T, q *Queue, deliver DeliverFunc, cond func() bool) {
+	t.Helper()
+	walqtest.RunUntil(t, func(ctx context.Context) { q.Run(ctx, deliver) }, cond)
+}
+
+func TestEnqueueDedupesAndSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	q := openQueue(t, dir)
+	first, dup, err := q.Enqueue(Event{ChannelID: "ch1", EventID: "evt-1", Body: "{}", Headers: map[string][]string{"x-a": {"1", "2"}}})
+	if err != nil || dup {
+		t.Fatalf("enqueue = %v, dup %v", err, dup)
+	}
+	if _, dup, _ := q.Enqueue(Event{ChannelID: "ch1", EventID: "evt-1", Body: "{}"}); !dup {
+		t.Error("same event ID was not deduped")
+	}
+	if _, dup, _ := q.Enqueue(Event{ChannelID: "ch2", EventID: "evt-1", Body: "{}"}); dup {
+		t.Error("event IDs must be scoped to the channel")
+	}
+	if _, dup, _ := q.Enqueue(Event{ChannelID: "ch1", Body: "same"}); dup {
+		t.Error("first body-keyed event reported as duplicate")
+	}
+	if _, dup, _ := q.Enqueue(Event{ChannelID: "ch1", Body: "same"}); !dup {
+		t.Error("identical body without event ID was not deduped")
+	}
+	q.Close()
+
+	q = openQueue(t, dir)
+	var mu sync.Mutex
+	var got []Event
+	runUntil(t, q, func(_ context.Context, ev Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, ev)
+		return nil
+	}, func() bool { mu.Lock(); defer mu.Unlock(); return len(got) == 3 })
+	var replayed bool
+	for _, ev := range got {
+		replayed = replayed || (ev.ID == first.ID && len(ev.Headers["x-a"]) == 2)
+	}
+	if !replayed {
+		t.Errorf("replayed events = %+v, want %s with both header values", got, first.ID)
+	}
+	q.Close()
+
+	q = openQueue(t, dir)
+	if _, dup, _ := q.Enqueue(Event{ChannelID: "ch1", EventID: "evt-1", Body: "{}"}); !dup {
+		t.Error("dedupe key lost across restart")
+	}
+}
+
+func TestRetriesThenDeadLetters(t *testing.T) {
+	dir := t.TempDir()
+	q := openQueue(t, dir)
+	flaky, _, _ := q.Enqueue(Event{ChannelID: "ch1", EventID: "flaky", Body: "a"})
+	poison, _, _ := q.Enqueue(Event{ChannelID: "ch1", EventID: "poison", Body: "b"})
+	bad, _, _ := q.Enqueue(Event{ChannelID: "ch1", EventID: "bad", Body: "c"})
+
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	acked := map[string]bool{}
+	deliver := func(_ context.Context, ev Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[ev.ID]++
+		switch ev.ID {
+		case flaky.ID:
+			if attempts[ev.ID] < 2 {
+				return errors.New("unavailable")
+			}
+		case poison.ID:
+			return errors.New("still unavailable")
+		case bad.ID:
+			return Permanent(errors.New("invalid signature"))
+		}
+		acked[ev.ID] = true
+		return nil
+	}
+	runUntil(t, q, deliver, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(q.DeadLetters("ch1")) == 2 && acked[flaky.ID]
+	})
+
+	if attempts[poison.ID] != 3 || attempts[bad.ID] != 1 || attempts[flaky.ID] != 2 {
+		t.Errorf("attempts = %v", attempts)
+	}
+	dead := q.DeadLetters("ch1")
+	if dead[0].LastError == "" || dead[1].Attempts == 0 {
+		t.Errorf("dead letters = %+v", dead)
+	}
+	if len(q.DeadLetters("ch2")) != 0 {
+		t.Error("dead letters leaked across channels")
+	}
+	q.Close()
+
+	q = openQueue(t, dir)
+	var done []string
+	q.opts.OnDone = func(ev Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		done = append(done, ev.ID)
+	}
+	if len(q.DeadLetters("ch1")) != 2 {
+		t.Fatalf("dead letters after restart = %+v", q.DeadLetters("ch1"))
+	}
+	if _, err := q.Retry("ch2", poison.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("retry from another channel err = %v", err)
+	}
+	if _, err := q.Retry("ch1", poison.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Drop("ch1", bad.ID); err != nil {
+		t.Fatal(err)
+	}
+	var redelivered bool
+	runUntil(t, q, func(_ context.Context, ev Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		redelivered = ev.ID == poison.ID && ev.Attempts == 0
+		return nil
+	}, func() bool { mu.Lock(); defer mu.Unlock(); return redelivered && len(done) == 2 })
+	if len(q.DeadLetters("ch1")) != 0 {
+		t.Errorf("dead letters = %+v", q.DeadLetters("ch1"))
+	}
+	if len(done) != 2 || done[0] != bad.ID || done[1] != poison.ID {
+		t.Errorf("OnDone saw %v, want the dropped then the acked event", done)
+	}
+}
+
+func TestEnqueueFull(t *testing.T) {
+	q, err := Open(Options{Dir: t.TempDir(), MaxEvents: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	q.Enqueue(Event{ChannelID: "ch1", Body: "a"})
+	if _, _, err := q.Enqueue(Event{ChannelID: "ch1", Body: "b"}); !errors.Is(err, ErrFull) {
+		t.Errorf("err = %v, want ErrFull", err)
+	}
+}
+
+func TestEnqueueCapsUnverified(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{Dir: dir, MaxUnverified: 2, MaxUnverifiedBytes: 8}
+	q, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := q.Enqueue(Event{ChannelID: "ch1", Body: "123456789"}); !errors.Is(err, ErrUnverifiedFull) {
+		t.Errorf("oversized unverified err = %v, want ErrUnverifiedFull", err)
+	}
+	first, _, _ := q.Enqueue(Event{ChannelID: "ch1", Body: "a"})
+	q.Enqueue(Event{ChannelID: "ch1", Body: "b"})
+	if _, _, err := q.Enqueue(Event{ChannelID: "ch1", Body: "c"}); !errors.Is(err, ErrUnverifiedFull) {
+		t.Errorf("third unverified err = %v, want ErrUnverifiedFull", err)
+	}
+	if _, _, err := q.Enqueue(Event{ChannelID: "ch1", Body: "123456789", Verified: true}); err != nil {
+		t.Errorf("verified event refused: %v", err)
+	}
+	q.Close()
+
+	// The totals are rebuilt on replay and freed once an event is settled.
+	q, err = Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if _, _, err := q.Enqueue(Event{ChannelID: "ch1", Body: "c"}); !errors.Is(err, ErrUnverifiedFull) {
+		t.Errorf("unverified after restart err = %v, want ErrUnverifiedFull", err)
+	}
+	var mu sync.Mutex
+	acked := false
+	runUntil(t, q, func(_ context.Context, ev Event) error {
+		if ev.ID != first.ID {
+			return errors.New("later")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		acked = true
+		return nil
+	}, func() bool { mu.Lock(); defer mu.Unlock(); return acked })
+	if _, _, err := q.Enqueue(Event{ChannelID: "ch1", Body: "c"}); err != nil {
+		t.Errorf("unverified after ack err = %v", err)
+	}
+}
@@ -235,7 +235,7 @@ export async function runCoordinator(params: CoordinatorParams): Promise<void> {
       riskLevel: "low",
     });
     try {
-      const webSearchTool = makeWebSearchTool();
+      const webSearchTool = makeWebSearchTool({ workspaceId: params.workspaceId, runId: params.runId });
       const preflightResult = await webSearchTool.execute(searchArgs, { toolCallId });
       params.emit({
         type: "tool-result",
@@ -262,7 +262,7 @@ export async function runCoordinator(params: CoordinatorParams): Promise<void> {
   }
 
   if (webSearchAllowed && !shouldForceWebSearch) {
-    tools["web_search"] = makeWebSearchTool();
+    tools["web_search"] = makeWebSearchTool({ workspaceId: params.workspaceId, runId: params.runId });
   }
 
   const pluginTools = buildRuntimePluginToolset({
@@ -222,6 +222,16 @@ const orchestrator = new DefaultOrchestrator({
     } satisfies RunResult;
   },
   eventBus,
+  onRunCompleted: (request, result) => {
+    if (result.status !== "completed" || !result.fullText.trim()) return;
+    void publishWorkspaceEvent(request.workspaceId, "agent.reply", {
+      runId: request.runId,
+      sessionId: request.sessionKey,
+      agentId: request.coordinatorAgentId,
+      lane: request.lane,
+      text: result.fullText,
+    });
+  },
 });
 
 // ─── Channel run (async, no SSE) ──────────────────────────────────────────────
@@ -928,6 +938,31 @@ async function processChannelRun(body: ChannelRunBody): Promise<void> {
   app.log.info({ runId, channelId: body.channelId }, "Channel reply sent");
 }
 
+// Reports a workspace event to the gateway, which delivers it to the
+// workspace's outgoing webhook channels. Failures are logged, not retried.
+async function publishWorkspaceEvent(
+  workspaceId: string,
+  type: string,
+  data: Record<string, unknown>,
+): Promise<void> {
+  try {
+    const response = await fetch(`${config.gatewayAddr}/internal/events`, {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+        "X-Runtime-Secret": config.runtimeSecret,
+      },
+      body: JSON.stringify({ workspaceId, type, occurredAt: new Date().toISOString(), data }),
+      signal: AbortSignal.timeout(config.channelSendTimeoutMs),
+    });
+    if (!response.ok) {
+      app.log.warn({ status: response.status, type }, "Gateway rejected workspace event");
+    }
+  } catch (err) {
+    app.log.warn({ err, type }, "Failed to publish workspace event");
+  }
+}
+
 async function sendReplyToChannel(params: {
   channelId: string;
   chatId: string;
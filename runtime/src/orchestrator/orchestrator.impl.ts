@@ -21,6 +21,8 @@ export interface DefaultOrchestratorOptions {
   eventBus: EventBus;
   laneConfigs?: readonly LaneConfig[];
   lockTimeoutMs?: number;
+  /** Called after a run's handler returns, before run-end is emitted. Errors are ignored. */
+  onRunCompleted?: (request: OrchestratorRunRequest, result: RunResult) => void;
 
   // ─── Optional overrides (plugin injection points) ─────────────────────
   sessionLock?: SessionLock;
@@ -48,6 +50,7 @@ export class DefaultOrchestrator implements Orchestrator {
   private readonly laneManager: LaneManager;
   private readonly runExecutor: RunExecutor;
   private readonly eventBus: EventBus;
+  private readonly onRunCompleted?: (request: OrchestratorRunRequest, result: RunResult) => void;
   private readonly runs = new Map<string, TrackedRun>();
   private readonly shutdownAc = new AbortController();
   /** Pending await resolvers for executeAndAwait() callers. */
@@ -65,6 +68,7 @@ export class DefaultOrchestrator implements Orchestrator {
 
     this.laneManager = options.laneManager ?? new LaneManager(configs);
     this.eventBus = options.eventBus;
+    this.onRunCompleted = options.onRunCompleted;
     this.runRetentionMs = 10 * 60 * 1000; // 10 minutes
 
     this.runExecutor = new RunExecutor({
@@ -284,6 +288,12 @@ export class DefaultOrchestrator implements Orchestrator {
         waiter.resolve(result);
       }
 
+      try {
+        this.onRunCompleted?.(tracked.request, result);
+      } catch {
+        // completion hooks must not fail the run
+      }
+
       // Emit run-end
       if (this.eventBus.hasRun(tracked.request.runId)) {
         this.eventBus.emit(tracked.request.runId, {
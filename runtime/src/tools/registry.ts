@@ -36,7 +36,7 @@ export function buildToolset(params: ToolRegistryParams): Record<string, Runtime
       reranker: params.reranker,
       rerankerModel: params.rerankerModel,
     }),
-    web_search: makeWebSearchTool(),
+    web_search: makeWebSearchTool({ workspaceId: params.workspaceId, runId: params.runId }),
     delegate_to_agent: makeDelegateTool(params),
   };
 
@@ -8,12 +8,21 @@ interface WebSearchItem {
   url: string;
   published?: string;
   siteName?: string;
+  type?: string;
+  thumbnail?: string;
+  source?: string;
+  imageUrl?: string;
+  width?: number;
+  height?: number;
+  duration?: string;
 }
 
 interface GatewayWebSearchResponse {
   query?: unknown;
   provider?: unknown;
   engine?: unknown; // backward compatibility
+  type?: unknown;
+  offset?: unknown;
   results?: unknown;
   total?: unknown;
   note?: unknown;
@@ -26,6 +35,14 @@ function clampResults(value: number | undefined): number {
   return Math.max(1, Math.min(10, parsed));
 }
 
+function optionalString(value: unknown): string {
+  return typeof value === "string" ? value.trim() : "";
+}
+
+function optionalNumber(value: unknown): number {
+  return typeof value === "number" && Number.isFinite(value) && value > 0 ? value : 0;
+}
+
 function parseItems(raw: unknown, limit: number): WebSearchItem[] {
   if (!Array.isArray(raw)) return [];
   const normalized: WebSearchItem[] = [];
@@ -43,6 +60,13 @@ function parseItems(raw: unknown, limit: number): WebSearchItem[] {
     const url = typeof item.url === "string" ? item.url.trim() : "";
     const published = typeof item.published === "string" ? item.published.trim() : "";
     const siteName = typeof item.siteName === "string" ? item.siteName.trim() : "";
+    const type = optionalString(item.type);
+    const thumbnail = optionalString(item.thumbnail);
+    const source = optionalString(item.source);
+    const imageUrl = optionalString(item.imageUrl);
+    const width = optionalNumber(item.width);
+    const height = optionalNumber(item.height);
+    const duration = optionalString(item.duration);
     if (!title && !snippet && !url) continue;
     normalized.push({
       title: title || snippet || "(untitled)",
@@ -50,6 +74,13 @@ function parseItems(raw: unknown, limit: number): WebSearchItem[] {
       url,
       ...(published ? { published } : {}),
       ...(siteName ? { siteName } : {}),
+      ...(type ? { type } : {}),
+      ...(thumbnail ? { thumbnail } : {}),
+      ...(source ? { source } : {}),
+      ...(imageUrl ? { imageUrl } : {}),
+      ...(width ? { width } : {}),
+      ...(height ? { height } : {}),
+      ...(duration ? { duration } : {}),
     });
     if (normalized.length >= limit) break;
   }
@@ -83,6 +114,10 @@ const WebSearchParams = Type.Object({
       Type.Literal("brave"),
       Type.Literal("searxng"),
       Type.Literal("serpapi"),
+      Type.Literal("tavily"),
+      Type.Literal("bing"),
+      Type.Literal("google"),
+      Type.Literal("exa"),
     ], { description: "Search provider selection" }),
   ),
   country: Type.Optional(Type.String({ description: "Optional country code (e.g. us, cn)" })),
@@ -95,9 +130,39 @@ const WebSearchParams = Type.Object({
       Type.Literal("py"),
     ], { description: "Optional freshness window: day/week/month/year" }),
   ),
+  offset: Type.Optional(Type.Number({ description: "Number of results to skip, for paging" })),
+  page: Type.Optional(Type.Number({ description: "1-based result page; used when offset is not set" })),
+  type: Type.Optional(
+    Type.Union([
+      Type.Literal("web"),
+      Type.Literal("news"),
+      Type.Literal("images"),
+      Type.Literal("videos"),
+    ], { description: "Result type (default web)" }),
+  ),
+  include_domains: Type.Optional(
+    Type.Array(Type.String(), { description: "Only return results from these domains (e.g. go.dev)" }),
+  ),
+  exclude_domains: Type.Optional(
+    Type.Array(Type.String(), { description: "Never return results from these domains" }),
+  ),
+  safesearch: Type.Optional(
+    Type.Union([
+      Type.Literal("off"),
+      Type.Literal("moderate"),
+      Type.Literal("strict"),
+    ], { description: "Optional safe search level" }),
+  ),
 });
 
-export function makeWebSearchTool(): RuntimeTool<typeof WebSearchParams> {
+export interface WebSearchDeps {
+  /** Lets the gateway apply the workspace's own provider, keys and filters. */
+  workspaceId?: string;
+  /** Attributes the gateway's search usage events to this run. */
+  runId?: string;
+}
+
+export function makeWebSearchTool(deps: WebSearchDeps = {}): RuntimeTool<typeof WebSearchParams> {
   return {
     name: "web_search",
     description:
@@ -121,12 +186,20 @@ export function makeWebSearchTool(): RuntimeTool<typeof WebSearchParams> {
 
       const url = `${config.gatewayAddr.replace(/\/+$/, "")}/internal/tools/web-search`;
       const payload = JSON.stringify({
+        ...(deps.workspaceId ? { workspaceId: deps.workspaceId } : {}),
+        ...(deps.runId ? { runId: deps.runId } : {}),
         query: safeQuery,
         count: limit,
         provider: args.provider ?? "auto",
         ...(args.country ? { country: args.country } : {}),
         ...(args.search_lang ? { search_lang: args.search_lang } : {}),
         ...(args.freshness ? { freshness: args.freshness } : {}),
+        ...(args.offset ? { offset: Math.max(0, Math.floor(args.offset)) } : {}),
+        ...(args.page ? { page: Math.max(1, Math.floor(args.page)) } : {}),
+        ...(args.type ? { type: args.type } : {}),
+        ...(args.safesearch ? { safesearch: args.safesearch } : {}),
+        ...(args.include_domains?.length ? { include_domains: args.include_domains } : {}),
+        ...(args.exclude_domains?.length ? { exclude_domains: args.exclude_domains } : {}),
       });
 
       try {
@@ -174,6 +247,8 @@ export function makeWebSearchTool(): RuntimeTool<typeof WebSearchParams> {
           query: safeQuery,
           provider: resolvedProvider,
           engine: resolvedProvider,
+          type: typeof parsed.type === "string" ? parsed.type : args.type ?? "web",
+          ...(typeof parsed.offset === "number" && parsed.offset > 0 ? { offset: parsed.offset } : {}),
           results,
           total: typeof parsed.total === "number" ? parsed.total : results.length,
           note: results.length === 0 ? note || "No public web results found" : note,
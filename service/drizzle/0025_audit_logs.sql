@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS `audit_logs` (
+  `id` text PRIMARY KEY NOT NULL,
+  `org_id` text NOT NULL DEFAULT '',
+  `workspace_id` text NOT NULL DEFAULT '',
+  `user_id` text NOT NULL DEFAULT '',
+  `method` text NOT NULL,
+  `route` text NOT NULL DEFAULT '',
+  `path` text NOT NULL,
+  `params_json` text,
+  `request_id` text,
+  `status` integer NOT NULL DEFAULT 0,
+  `latency_ms` integer NOT NULL DEFAULT 0,
+  `changes_json` text,
+  `created_at` text NOT NULL DEFAULT (datetime('now'))
+);
+--> statement-breakpoint
+CREATE INDEX IF NOT EXISTS `audit_logs_org_created_at_idx` ON `audit_logs` (`org_id`, `created_at`);
+--> statement-breakpoint
+CREATE INDEX IF NOT EXISTS `audit_logs_user_id_idx` ON `audit_logs` (`user_id`);
@@ -0,0 +1,2 @@
+ALTER TABLE `workspace_settings` ADD `web_search_provider` text DEFAULT '';--> statement-breakpoint
+ALTER TABLE `workspace_settings` ADD `web_search_config_json` text DEFAULT '{}';
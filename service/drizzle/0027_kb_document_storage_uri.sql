@@ -0,0 +1 @@
+ALTER TABLE `kb_documents` ADD `storage_uri` text;
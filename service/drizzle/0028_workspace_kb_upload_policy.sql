@@ -0,0 +1 @@
+ALTER TABLE `workspace_settings` ADD `kb_upload_policy_json` text DEFAULT '{}';
@@ -0,0 +1,4 @@
+ALTER TABLE `channel_messages` ADD `chat_id` text;--> statement-breakpoint
+ALTER TABLE `channel_messages` ADD `platform_message_id` text;--> statement-breakpoint
+ALTER TABLE `channel_messages` ADD `message_type` text DEFAULT 'text' NOT NULL;--> statement-breakpoint
+CREATE INDEX IF NOT EXISTS `channel_messages_platform_message_id_idx` ON `channel_messages` (`channel_id`,`platform_message_id`);
@@ -0,0 +1,15 @@
+ALTER TABLE `channel_messages` ADD `thread_id` text;--> statement-breakpoint
+ALTER TABLE `channel_messages` ADD `agent_id` text;--> statement-breakpoint
+CREATE INDEX IF NOT EXISTS `channel_messages_channel_created_idx` ON `channel_messages` (`channel_id`,`created_at`,`id`);--> statement-breakpoint
+CREATE VIRTUAL TABLE IF NOT EXISTS `channel_messages_fts` USING fts5(`content`, content='channel_messages', content_rowid='rowid', tokenize='trigram');--> statement-breakpoint
+CREATE TRIGGER IF NOT EXISTS `channel_messages_fts_ai` AFTER INSERT ON `channel_messages` BEGIN
+  INSERT INTO `channel_messages_fts`(rowid, `content`) VALUES (new.rowid, coalesce(new.`content`, ''));
+END;--> statement-breakpoint
+CREATE TRIGGER IF NOT EXISTS `channel_messages_fts_ad` AFTER DELETE ON `channel_messages` BEGIN
+  INSERT INTO `channel_messages_fts`(`channel_messages_fts`, rowid, `content`) VALUES ('delete', old.rowid, coalesce(old.`content`, ''));
+END;--> statement-breakpoint
+CREATE TRIGGER IF NOT EXISTS `channel_messages_fts_au` AFTER UPDATE OF `content` ON `channel_messages` BEGIN
+  INSERT INTO `channel_messages_fts`(`channel_messages_fts`, rowid, `content`) VALUES ('delete', old.rowid, coalesce(old.`content`, ''));
+  INSERT INTO `channel_messages_fts`(rowid, `content`) VALUES (new.rowid, coalesce(new.`content`, ''));
+END;--> statement-breakpoint
+INSERT INTO `channel_messages_fts`(`channel_messages_fts`) VALUES ('rebuild');
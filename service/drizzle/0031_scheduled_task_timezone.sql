@@ -0,0 +1 @@
+ALTER TABLE `scheduled_tasks` ADD `timezone` text;
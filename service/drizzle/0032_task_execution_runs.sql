@@ -0,0 +1,3 @@
+ALTER TABLE `task_executions` ADD `session_id` text;--> statement-breakpoint
+ALTER TABLE `task_executions` ADD `run_id` text;--> statement-breakpoint
+CREATE INDEX IF NOT EXISTS `task_executions_task_started_idx` ON `task_executions` (`task_id`,`started_at`,`id`);
@@ -0,0 +1,2 @@
+ALTER TABLE `scheduled_tasks` ADD `trigger_json` text;--> statement-breakpoint
+ALTER TABLE `scheduled_tasks` ADD `trigger_token` text;
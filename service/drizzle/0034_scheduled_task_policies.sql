@@ -0,0 +1,6 @@
+ALTER TABLE `scheduled_tasks` ADD `concurrency_policy` text DEFAULT 'allow' NOT NULL;--> statement-breakpoint
+ALTER TABLE `scheduled_tasks` ADD `retry_max_attempts` integer DEFAULT 1 NOT NULL;--> statement-breakpoint
+ALTER TABLE `scheduled_tasks` ADD `retry_backoff_seconds` integer DEFAULT 60 NOT NULL;--> statement-breakpoint
+ALTER TABLE `scheduled_tasks` ADD `paused_at` text;--> statement-breakpoint
+ALTER TABLE `task_executions` ADD `attempt` integer DEFAULT 1 NOT NULL;--> statement-breakpoint
+ALTER TABLE `task_executions` ADD `scheduled_for` text;
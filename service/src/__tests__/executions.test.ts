@@ -0,0 +1,104 @@
+import { describe, it, expect, beforeAll, afterAll } from "vitest";
+import { eq } from "drizzle-orm";
+import { db } from "../db/index.js";
+import { scheduledTasks, taskExecutions } from "../db/schema.js";
+import { listExecutions } from "../modules/scheduler/scheduler.service.js";
+
+// The workspace is seeded by test-seed.ts (run: npx tsx src/__tests__/test-seed.ts);
+// the task and its executions are created and removed by this file.
+const workspaceId = "test-ws-authz-001";
+const taskId = "test-task-executions-001";
+
+const executions = [
+  { id: "exec-1", status: "success", startedAt: "2026-03-01T10:00:00.000Z" },
+  { id: "exec-2", status: "failed", startedAt: "2026-03-02T10:00:00.000Z" },
+  // exec-3 and exec-4 started in the same millisecond; id breaks the tie.
+  { id: "exec-3", status: "success", startedAt: "2026-03-03T10:00:00.000Z" },
+  { id: "exec-4", status: "success", startedAt: "2026-03-03T10:00:00.000Z" },
+  { id: "exec-5", status: "running", startedAt: "2026-03-04T10:00:00.000Z" },
+];
+
+beforeAll(() => {
+  db.insert(scheduledTasks).values({ id: taskId, workspaceId, name: "Executions Test Task", scheduleType: "cron", status: "paused" }).run();
+  db.insert(taskExecutions).values(executions.map((e) => ({ ...e, taskId }))).run();
+});
+
+afterAll(() => {
+  db.delete(taskExecutions).where(eq(taskExecutions.taskId, taskId)).run();
+  db.delete(scheduledTasks).where(eq(scheduledTasks.id, taskId)).run();
+});
+
+const ids = (page: ReturnType<typeof listExecutions>) => page.executions.map((e) => e.id);
+
+describe("listExecutions paging", () => {
+  it("pages newest first until the cursor runs out", () => {
+    const first = listExecutions(taskId, { limit: 2 });
+    expect(ids(first)).toEqual(["exec-5", "exec-4"]);
+    expect(first.nextCursor).not.toBe("");
+
+    const second = listExecutions(taskId, { limit: 2, cursor: first.nextCursor });
+    expect(ids(second)).toEqual(["exec-3", "exec-2"]);
+
+    const third = listExecutions(taskId, { limit: 2, cursor: second.nextCursor });
+    expect(ids(third)).toEqual(["exec-1"]);
+    expect(third.nextCursor).toBe("");
+  });
+
+  it("has no cursor when everything fits on one page", () => {
+    const page = listExecutions(taskId, { limit: 5 });
+    expect(page.executions).toHaveLength(5);
+    expect(page.nextCursor).toBe("");
+  });
+
+  it("keeps the cursor across filters", () => {
+    const first = listExecutions(taskId, { status: "success", limit: 1 });
+    expect(ids(first)).toEqual(["exec-4"]);
+    const rest = listExecutions(taskId, { status: "success", cursor: first.nextCursor });
+    expect(ids(rest)).toEqual(["exec-3", "exec-1"]);
+  });
+});
+
+describe("listExecutions filters", () => {
+  it("filters by status", () => {
+    expect(ids(listExecutions(taskId, { status: "failed" }))).toEqual(["exec-2"]);
+    expect(ids(listExecutions(taskId, { status: "cancelled" }))).toEqual([]);
+  });
+
+  it("rejects an unknown status", () => {
+    expect(() => listExecutions(taskId, { status: "done" })).toThrow("status must be one of");
+  });
+
+  it("filters by start time, from inclusive and to exclusive", () => {
+    const page = listExecutions(taskId, { from: "2026-03-02T10:00:00Z", to: "2026-03-04T10:00:00Z" });
+    expect(ids(page)).toEqual(["exec-4", "exec-3", "exec-2"]);
+    // Offsets are normalized to UTC before comparing.
+    expect(ids(listExecutions(taskId, { from: "2026-03-04T18:00:00+08:00" }))).toEqual(["exec-5"]);
+  });
+
+  it("rejects timestamps that do not parse", () => {
+    expect(() => listExecutions(taskId, { from: "yesterday" })).toThrow("from must be an ISO-8601 timestamp");
+    expect(() => listExecutions(taskId, { to: "2026-13-45" })).toThrow("to must be an ISO-8601 timestamp");
+  });
+});
+
+describe("listExecutions cursor", () => {
+  it("rejects a cursor that is not one of ours", () => {
+    const malformed = [
+      "not-a-cursor",
+      Buffer.from("{\"s\":1,\"i\":\"exec-1\"}").toString("base64url"),
+      Buffer.from("{\"i\":\"exec-1\"}").toString("base64url"),
+    ];
+    for (const cursor of malformed) {
+      expect(() => listExecutions(taskId, { cursor })).toThrow("Invalid cursor");
+    }
+  });
+
+  it("tags invalid input for the gRPC layer", () => {
+    try {
+      listExecutions(taskId, { cursor: "not-a-cursor" });
+      expect.unreachable();
+    } catch (err) {
+      expect((err as { code?: string }).code).toBe("INVALID_ARGUMENT");
+    }
+  });
+});
@@ -0,0 +1,57 @@
+/**
+ * A stand-in for the runtime's HTTP API in scheduler tests: each
+ * scheduled-run request stays open until the test finishes it, and
+ * rejects when the scheduler aborts it.
+ */
+import { vi } from "vitest";
+import { eq, inArray } from "drizzle-orm";
+import { db } from "../db/index.js";
+import { chatSessions, scheduledTasks, taskExecutions } from "../db/schema.js";
+
+export interface FakeRun {
+  executionId: string;
+  instruction: string;
+  aborted: boolean;
+  finish(status?: "completed" | "failed"): void;
+}
+
+export function stubRuntime(): FakeRun[] {
+  const runs: FakeRun[] = [];
+  vi.stubGlobal("fetch", (url: string | URL, init: RequestInit = {}) => {
+    // Cancels and anything else the scheduler calls just succeed.
+    if (!String(url).endsWith("/runtime/scheduled-run")) return Promise.resolve(new Response(null, { status: 204 }));
+    const body = JSON.parse(String(init.body)) as { executionId: string; instruction: string };
+    return new Promise<Response>((resolve, reject) => {
+      const run: FakeRun = {
+        executionId: body.executionId,
+        instruction: body.instruction,
+        aborted: false,
+        finish: (status = "completed") => resolve(new Response(
+          JSON.stringify({ data: { runId: `run-${body.executionId}`, status, resultSummary: "" } }),
+          { status: 200, headers: { "Content-Type": "application/json" } },
+        )),
+      };
+      init.signal?.addEventListener("abort", () => {
+        run.aborted = true;
+        reject(init.signal!.reason);
+      });
+      runs.push(run);
+    });
+  });
+  return runs;
+}
+
+/** Remove a test task with its executions and the sessions they opened. */
+export function removeTask(taskId: string) {
+  const sessionIds = db.select({ id: taskExecutions.sessionId }).from(taskExecutions)
+    .where(eq(taskExecutions.taskId, taskId)).all()
+    .map((row) => row.id)
+    .filter((id): id is string => !!id);
+  if (sessionIds.length) db.delete(chatSessions).where(inArray(chatSessions.id, sessionIds)).run();
+  db.delete(taskExecutions).where(eq(taskExecutions.taskId, taskId)).run();
+  db.delete(scheduledTasks).where(eq(scheduledTasks.id, taskId)).run();
+}
+
+export function executionStatus(execId: string): string | undefined {
+  return db.select().from(taskExecutions).where(eq(taskExecutions.id, execId)).get()?.status;
+}
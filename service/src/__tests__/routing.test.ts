@@ -0,0 +1,130 @@
+import { describe, it, expect, beforeAll, afterAll } from "vitest";
+import { eq } from "drizzle-orm";
+import { db } from "../db/index.js";
+import { channels, channelMessages, routingRules } from "../db/schema.js";
+import { createRoutingRule, simulateRoutingRules } from "../modules/channel/channel.service.js";
+
+// The workspace is seeded by test-seed.ts (run: npx tsx src/__tests__/test-seed.ts);
+// the channel, rules and messages below are created and removed by this file.
+const workspaceId = "test-ws-authz-001";
+const channelId = "test-channel-routing-001";
+
+beforeAll(() => {
+  db.insert(channels).values({ id: channelId, workspaceId, name: "Routing Test Channel", type: "slack", configJson: "{}", status: "active" }).run();
+  createRoutingRule({ channelId, field: "content", operator: "contains", value: "help", targetAgentId: "agent-support", priority: 1 });
+  createRoutingRule({ channelId, field: "content", operator: "starts_with", value: "/bill", targetAgentId: "agent-billing", priority: 10 });
+  db.insert(channelMessages).values([
+    { id: "routing-msg-1", channelId, direction: "inbound", sender: "u1", content: "/bill help", chatId: "c1", createdAt: "2026-01-01 00:00:01" },
+    { id: "routing-msg-2", channelId, direction: "inbound", sender: "u2", content: "need help", chatId: "c1", createdAt: "2026-01-01 00:00:02" },
+    { id: "routing-msg-3", channelId, direction: "outbound", content: "on it", chatId: "c1", createdAt: "2026-01-01 00:00:03" },
+    { id: "routing-msg-4", channelId, direction: "inbound", sender: "u3", content: "hello", chatId: "c1", createdAt: "2026-01-01 00:00:04" },
+  ]).run();
+});
+
+afterAll(() => {
+  db.delete(channelMessages).where(eq(channelMessages.channelId, channelId)).run();
+  db.delete(routingRules).where(eq(routingRules.channelId, channelId)).run();
+  db.delete(channels).where(eq(channels.id, channelId)).run();
+});
+
+function simulate(content: string, rules: Parameters<typeof simulateRoutingRules>[0]["rules"]) {
+  return simulateRoutingRules({ channelId, message: { content }, draft: true, rules }).results[0];
+}
+
+describe("simulateRoutingRules rule evaluation", () => {
+  it("evaluates rules highest priority first", () => {
+    const result = simulate("/bill help", [
+      { id: "low", operator: "contains", value: "help", targetAgentId: "agent-low", priority: 1 },
+      { id: "high", operator: "contains", value: "bill", targetAgentId: "agent-high", priority: 5 },
+    ]);
+    expect(result.targetAgentId).toBe("agent-high");
+    expect(result.trace.map((e) => [e.ruleId, e.outcome])).toEqual([
+      ["high", "matched"],
+      ["low", "not_reached"],
+    ]);
+    expect(result.trace[1].detail).toBe("rule high matched first");
+  });
+
+  it("skips disabled rules and reports them", () => {
+    const result = simulate("help", [
+      { id: "off", operator: "contains", value: "help", targetAgentId: "agent-off", priority: 5, enabled: false },
+      { id: "on", operator: "contains", value: "help", targetAgentId: "agent-on", priority: 1 },
+    ]);
+    expect(result.targetAgentId).toBe("agent-on");
+    expect(result.trace.map((e) => e.outcome)).toEqual(["disabled", "matched"]);
+  });
+
+  it("reports an invalid regex without stopping evaluation", () => {
+    const result = simulate("abc", [
+      { id: "bad", operator: "regex", value: "(", targetAgentId: "agent-bad", priority: 5 },
+      { id: "good", operator: "regex", value: "^a", targetAgentId: "agent-good", priority: 1 },
+    ]);
+    expect(result.trace[0].outcome).toBe("invalid_rule");
+    expect(result.trace[0].detail).toMatch(/^invalid regex: /);
+    expect(result.matchedRule?.id).toBe("good");
+  });
+
+  it("reports no match with an empty target", () => {
+    const result = simulate("nothing", [{ id: "r", operator: "equals", value: "x", targetAgentId: "agent-x" }]);
+    expect(result.matchedRule).toBeUndefined();
+    expect(result.targetAgentId).toBe("");
+    expect(result.trace[0].outcome).toBe("not_matched");
+  });
+});
+
+describe("simulateRoutingRules draft comparison", () => {
+  it("compares draft rules with the saved ones", () => {
+    const same = simulate("/bill me", [{ operator: "starts_with", value: "/bill", targetAgentId: "agent-billing" }]);
+    expect(same.targetAgentId).toBe("agent-billing");
+    expect(same.savedTargetAgentId).toBe("agent-billing");
+    expect(same.changed).toBe(false);
+
+    const moved = simulate("/bill me", [{ operator: "starts_with", value: "/bill", targetAgentId: "agent-finance" }]);
+    expect(moved.trace[0].ruleId).toBe("draft-1");
+    expect(moved.savedTargetAgentId).toBe("agent-billing");
+    expect(moved.changed).toBe(true);
+  });
+
+  it("uses the saved rules when not a draft", () => {
+    const result = simulateRoutingRules({ channelId, message: { content: "/bill help" } }).results[0];
+    expect(result.targetAgentId).toBe("agent-billing");
+    expect(result.savedTargetAgentId).toBe("agent-billing");
+    expect(result.changed).toBe(false);
+    expect(result.trace.map((e) => e.outcome)).toEqual(["matched", "not_reached"]);
+  });
+});
+
+describe("simulateRoutingRules sources", () => {
+  it("routes a sample message", () => {
+    const { results } = simulateRoutingRules({ channelId, message: { content: "need help", sender: "u9" } });
+    expect(results).toHaveLength(1);
+    expect(results[0].messageId).toBe("");
+    expect(results[0].message).toEqual({ content: "need help", sender: "u9", chatId: "", threadId: "" });
+    expect(results[0].targetAgentId).toBe("agent-support");
+  });
+
+  it("replays one stored inbound message", () => {
+    const { results } = simulateRoutingRules({ channelId, messageId: "routing-msg-1" });
+    expect(results).toHaveLength(1);
+    expect(results[0].messageId).toBe("routing-msg-1");
+    expect(results[0].message.sender).toBe("u1");
+    expect(results[0].targetAgentId).toBe("agent-billing");
+  });
+
+  it("rejects outbound and unknown messages", () => {
+    expect(() => simulateRoutingRules({ channelId, messageId: "routing-msg-3" })).toThrow("Only inbound messages");
+    expect(() => simulateRoutingRules({ channelId, messageId: "missing" })).toThrow("not found");
+  });
+
+  it("replays the last inbound messages newest first", () => {
+    const { results } = simulateRoutingRules({ channelId, replayLast: 2 });
+    expect(results.map((r) => r.messageId)).toEqual(["routing-msg-4", "routing-msg-2"]);
+    expect(results.map((r) => r.targetAgentId)).toEqual(["", "agent-support"]);
+  });
+
+  it("requires exactly one source", () => {
+    expect(() => simulateRoutingRules({ channelId })).toThrow("exactly one");
+    expect(() => simulateRoutingRules({ channelId, message: { content: "x" }, replayLast: 1 })).toThrow("exactly one");
+    expect(() => simulateRoutingRules({ channelId, replayLast: 201 })).toThrow("at most 200");
+  });
+});
@@ -0,0 +1,146 @@
+import { describe, it, expect, beforeAll, beforeEach, afterEach, afterAll, vi } from "vitest";
+import { asc, eq } from "drizzle-orm";
+import { v4 as uuidv4 } from "uuid";
+import { db } from "../db/index.js";
+import { scheduledTasks, taskExecutions } from "../db/schema.js";
+import { backfillTask, runTask } from "../modules/scheduler/scheduler.service.js";
+import { executionStatus, removeTask, stubRuntime, type FakeRun } from "./fake-runtime.js";
+
+// The workspace is seeded by test-seed.ts (run: npx tsx src/__tests__/test-seed.ts);
+// the tasks and their executions are created and removed by this file.
+const workspaceId = "test-ws-authz-001";
+const taskId = "test-task-scheduler-001";
+
+let runs: FakeRun[];
+
+beforeAll(() => {
+  db.insert(scheduledTasks).values({
+    id: taskId, workspaceId, name: "Scheduler Test Task", instruction: "Report",
+    scheduleType: "cron", cronExpression: "0 * * * *", timezone: "UTC", targetAgentId: "agent-scheduler",
+  }).run();
+});
+
+beforeEach(() => {
+  runs = stubRuntime();
+  db.delete(taskExecutions).where(eq(taskExecutions.taskId, taskId)).run();
+  db.update(scheduledTasks)
+    .set({ status: "active", scheduleType: "cron", runCount: 0, maxRuns: null, concurrencyPolicy: "allow" })
+    .where(eq(scheduledTasks.id, taskId))
+    .run();
+});
+
+afterEach(async () => {
+  // Backfills start their next run once the previous one is done.
+  await vi.waitFor(() => {
+    for (const run of runs) run.finish();
+    const open = db.select().from(taskExecutions).where(eq(taskExecutions.taskId, taskId)).all()
+      .filter((e) => e.status === "running" || e.status === "queued");
+    expect(open).toEqual([]);
+  });
+  vi.unstubAllGlobals();
+});
+
+afterAll(() => removeTask(taskId));
+
+const code = async (promise: Promise<unknown>) => promise.then(() => undefined, (err) => (err as { code?: string }).code);
+const codeOf = (fn: () => unknown) => code(Promise.resolve().then(fn));
+
+function insertExecution(values: Partial<typeof taskExecutions.$inferInsert>) {
+  db.insert(taskExecutions).values({ id: uuidv4(), taskId, status: "success", ...values }).run();
+}
+
+describe("backfillTask slot coverage", () => {
+  const slot = (hour: number) => `2026-03-01T${String(hour).padStart(2, "0")}:00:00.000Z`;
+
+  it("skips slots that already ran, by slot or by start time", () => {
+    insertExecution({ scheduledFor: slot(1), startedAt: slot(1) });
+    // A cancelled execution leaves its slot open.
+    insertExecution({ scheduledFor: slot(2), startedAt: slot(2), status: "cancelled" });
+    // Older executions have no slot; starting within a minute of one covers it.
+    insertExecution({ startedAt: "2026-03-01T03:00:59.000Z", status: "failed" });
+    insertExecution({ startedAt: "2026-03-01T04:01:00.000Z" });
+
+    const { executions, skipped } = backfillTask(taskId, [slot(4), slot(3), slot(2), slot(1)]);
+    expect(skipped).toBe(2);
+    expect(executions.map((e) => e.scheduledFor)).toEqual([slot(2), slot(4)]);
+    expect(executions.every((e) => e.status === "queued")).toBe(true);
+  });
+
+  it("normalizes and dedupes the requested slots", () => {
+    const { executions, skipped } = backfillTask(taskId, ["2026-03-01T13:00:00+08:00", slot(5)]);
+    expect(skipped).toBe(0);
+    expect(executions.map((e) => e.scheduledFor)).toEqual([slot(5)]);
+  });
+
+  it("runs the queued slots oldest first, one at a time", async () => {
+    backfillTask(taskId, [slot(7), slot(6)]);
+    await vi.waitFor(() => expect(runs).toHaveLength(1));
+    const first = db.select().from(taskExecutions).where(eq(taskExecutions.id, runs[0].executionId)).get()!;
+    expect(first.scheduledFor).toBe(slot(6));
+
+    runs[0].finish();
+    await vi.waitFor(() => expect(runs).toHaveLength(2));
+    expect(executionStatus(runs[0].executionId)).toBe("success");
+    const rows = db.select().from(taskExecutions).where(eq(taskExecutions.taskId, taskId))
+      .orderBy(asc(taskExecutions.scheduledFor)).all();
+    expect(rows.map((e) => e.id)).toEqual([runs[0].executionId, runs[1].executionId]);
+  });
+
+  it("rejects requests it cannot serve", async () => {
+    expect(await codeOf(() => backfillTask(taskId, []))).toBe("INVALID_ARGUMENT");
+    const tooMany = Array.from({ length: 101 }, (_, i) => new Date(Date.UTC(2026, 0, 1, 0, i)).toISOString());
+    expect(await codeOf(() => backfillTask(taskId, tooMany))).toBe("INVALID_ARGUMENT");
+    expect(await codeOf(() => backfillTask(taskId, [new Date(Date.now() + 3_600_000).toISOString()]))).toBe("INVALID_ARGUMENT");
+    expect(await codeOf(() => backfillTask(taskId, ["not a time"]))).toBe("INVALID_ARGUMENT");
+
+    db.update(scheduledTasks).set({ maxRuns: 1 }).where(eq(scheduledTasks.id, taskId)).run();
+    expect(await codeOf(() => backfillTask(taskId, [slot(8), slot(9)]))).toBe("FAILED_PRECONDITION");
+
+    db.update(scheduledTasks).set({ maxRuns: null, status: "paused" }).where(eq(scheduledTasks.id, taskId)).run();
+    expect(await codeOf(() => backfillTask(taskId, [slot(8)]))).toBe("FAILED_PRECONDITION");
+
+    db.update(scheduledTasks).set({ status: "active", scheduleType: "once" }).where(eq(scheduledTasks.id, taskId)).run();
+    expect(await codeOf(() => backfillTask(taskId, [slot(8)]))).toBe("FAILED_PRECONDITION");
+    expect(runs).toHaveLength(0);
+  });
+});
+
+describe("concurrency policies", () => {
+  const setPolicy = (policy: string) =>
+    db.update(scheduledTasks).set({ concurrencyPolicy: policy }).where(eq(scheduledTasks.id, taskId)).run();
+
+  it("allow runs alongside the run in progress", async () => {
+    setPolicy("allow");
+    void runTask(taskId);
+    void runTask(taskId);
+    await vi.waitFor(() => expect(runs).toHaveLength(2));
+    expect(runs.map((r) => executionStatus(r.executionId))).toEqual(["running", "running"]);
+  });
+
+  it("forbid refuses to start while a run is in progress", async () => {
+    setPolicy("forbid");
+    void runTask(taskId);
+    await vi.waitFor(() => expect(runs).toHaveLength(1));
+    expect(await code(runTask(taskId))).toBe("FAILED_PRECONDITION");
+    expect(runs).toHaveLength(1);
+
+    runs[0].finish();
+    await vi.waitFor(() => expect(executionStatus(runs[0].executionId)).toBe("success"));
+    void runTask(taskId);
+    await vi.waitFor(() => expect(runs).toHaveLength(2));
+  });
+
+  it("replace cancels the run in progress", async () => {
+    setPolicy("replace");
+    void runTask(taskId);
+    await vi.waitFor(() => expect(runs).toHaveLength(1));
+    void runTask(taskId);
+    await vi.waitFor(() => expect(runs).toHaveLength(2));
+
+    await vi.waitFor(() => expect(executionStatus(runs[0].executionId)).toBe("cancelled"));
+    expect(runs[0].aborted).toBe(true);
+    const replaced = db.select().from(taskExecutions).where(eq(taskExecutions.id, runs[0].executionId)).get()!;
+    expect(JSON.parse(replaced.result!)).toEqual({ error: "Replaced by a newer run" });
+    expect(executionStatus(runs[1].executionId)).toBe("running");
+  });
+});
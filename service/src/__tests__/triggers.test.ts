@@ -0,0 +1,196 @@
+import { describe, it, expect, beforeAll, beforeEach, afterEach, afterAll, vi } from "vitest";
+import { eq } from "drizzle-orm";
+import { db } from "../db/index.js";
+import { scheduledTasks } from "../db/schema.js";
+import { fireWebhookTrigger } from "../modules/scheduler/scheduler.service.js";
+import {
+  channelMessageMatches,
+  forgetTriggerSlots,
+  parseTriggerConfig,
+  takeTriggerSlot,
+  triggerTokenMatches,
+  triggeredInstruction,
+} from "../modules/scheduler/triggers.js";
+import { executionStatus, removeTask, stubRuntime, type FakeRun } from "./fake-runtime.js";
+
+describe("takeTriggerSlot", () => {
+  const taskId = "test-trigger-slots";
+  beforeEach(() => forgetTriggerSlots(taskId));
+
+  it("allows up to the limit within a minute", () => {
+    const t0 = 1_000_000;
+    expect(takeTriggerSlot(taskId, 2, t0)).toBe(true);
+    expect(takeTriggerSlot(taskId, 2, t0 + 1_000)).toBe(true);
+    expect(takeTriggerSlot(taskId, 2, t0 + 2_000)).toBe(false);
+  });
+
+  it("frees slots as fires leave the window", () => {
+    const t0 = 1_000_000;
+    takeTriggerSlot(taskId, 1, t0);
+    expect(takeTriggerSlot(taskId, 1, t0 + 59_999)).toBe(false);
+    expect(takeTriggerSlot(taskId, 1, t0 + 60_000)).toBe(true);
+  });
+
+  it("does not count rejected fires", () => {
+    const t0 = 1_000_000;
+    takeTriggerSlot(taskId, 1, t0);
+    takeTriggerSlot(taskId, 1, t0 + 30_000);
+    expect(takeTriggerSlot(taskId, 1, t0 + 60_000)).toBe(true);
+  });
+
+  it("keeps tasks apart and forgets on request", () => {
+    takeTriggerSlot(taskId, 1, 0);
+    expect(takeTriggerSlot(`${taskId}-other`, 1, 0)).toBe(true);
+    forgetTriggerSlots(`${taskId}-other`);
+    forgetTriggerSlots(taskId);
+    expect(takeTriggerSlot(taskId, 1, 0)).toBe(true);
+  });
+});
+
+describe("triggerTokenMatches", () => {
+  it("matches only the exact token", () => {
+    expect(triggerTokenMatches("s3cret-token", "s3cret-token")).toBe(true);
+    expect(triggerTokenMatches("s3cret-token", "s3cret-tokex")).toBe(false);
+    expect(triggerTokenMatches("s3cret-token", "s3cret")).toBe(false);
+  });
+
+  it("never matches a missing token", () => {
+    expect(triggerTokenMatches(null, "")).toBe(false);
+    expect(triggerTokenMatches(undefined, "x")).toBe(false);
+    expect(triggerTokenMatches("", "")).toBe(false);
+    expect(triggerTokenMatches("s3cret-token", "")).toBe(false);
+  });
+});
+
+describe("channelMessageMatches", () => {
+  const config = (matchers: Array<{ operator: string; value: string }>) =>
+    parseTriggerConfig("channel_message", JSON.stringify({ matchers }));
+
+  it("matches every message without matchers", () => {
+    expect(channelMessageMatches(config([]), "anything")).toBe(true);
+  });
+
+  it("matches when any matcher does", () => {
+    const c = config([{ operator: "starts_with", value: "/deploy" }, { operator: "regex", value: "^incident #\\d+" }]);
+    expect(channelMessageMatches(c, "/deploy api")).toBe(true);
+    expect(channelMessageMatches(c, "incident #42 opened")).toBe(true);
+    expect(channelMessageMatches(c, "hello")).toBe(false);
+  });
+
+  it("treats an unusable matcher as no match", () => {
+    const c = { ...config([]), matchers: [{ operator: "regex", value: "(" }] };
+    expect(channelMessageMatches(c, "(")).toBe(false);
+  });
+});
+
+describe("parseTriggerConfig", () => {
+  it("defaults and bounds the rate limit", () => {
+    expect(parseTriggerConfig("webhook", "").rateLimitPerMinute).toBe(6);
+    expect(() => parseTriggerConfig("webhook", "{\"rateLimitPerMinute\":0}")).toThrow("rateLimitPerMinute");
+    expect(() => parseTriggerConfig("webhook", "{\"rateLimitPerMinute\":61}")).toThrow("rateLimitPerMinute");
+  });
+
+  it("rejects malformed matchers", () => {
+    expect(() => parseTriggerConfig("webhook", "[]")).toThrow("must be a JSON object");
+    expect(() => parseTriggerConfig("channel_message", "{\"matchers\":[{\"operator\":\"like\"}]}")).toThrow("matchers[0].operator");
+    expect(() => parseTriggerConfig("channel_message", "{\"matchers\":[{\"operator\":\"regex\",\"value\":\"(\"}]}"))
+      .toThrow("matchers[0].value is not a valid regex");
+  });
+});
+
+describe("triggeredInstruction", () => {
+  it("appends the event, truncating long payloads", () => {
+    expect(triggeredInstruction("Do it", "webhook", "{}")).toBe("Do it\n\n---\nTriggered by: webhook\nPayload:\n{}");
+    const long = triggeredInstruction("Do it", "webhook", "x".repeat(20_000));
+    expect(long.endsWith("x\n[truncated]")).toBe(true);
+    expect(long.length).toBeLessThan(16_100);
+  });
+});
+
+// fireWebhookTrigger goes through fireTriggeredTask, which every trigger
+// type uses to start its runs.
+describe("fireWebhookTrigger", () => {
+  // The workspace is seeded by test-seed.ts (run: npx tsx src/__tests__/test-seed.ts).
+  const workspaceId = "test-ws-authz-001";
+  const taskId = "test-task-trigger-001";
+  const token = "test-trigger-token";
+  let runs: FakeRun[];
+
+  beforeAll(() => {
+    db.insert(scheduledTasks).values({
+      id: taskId, workspaceId, name: "Trigger Test Task", instruction: "Summarize the event",
+      scheduleType: "webhook", triggerToken: token, triggerJson: "{\"rateLimitPerMinute\":2}", targetAgentId: "agent-trigger",
+    }).run();
+  });
+
+  beforeEach(() => {
+    runs = stubRuntime();
+    forgetTriggerSlots(taskId);
+    db.update(scheduledTasks).set({ status: "active", runCount: 0, maxRuns: null }).where(eq(scheduledTasks.id, taskId)).run();
+  });
+
+  afterEach(async () => {
+    for (const run of runs) run.finish();
+    await vi.waitFor(() => {
+      for (const run of runs) expect(executionStatus(run.executionId)).not.toBe("running");
+    });
+    vi.unstubAllGlobals();
+  });
+
+  afterAll(() => removeTask(taskId));
+
+  const code = (fn: () => unknown) => {
+    try {
+      fn();
+    } catch (err) {
+      return (err as { code?: string }).code;
+    }
+    return undefined;
+  };
+
+  it("starts a run with the event as input", async () => {
+    const execution = fireWebhookTrigger(taskId, token, "{\"order\":42}", "application/json");
+    expect(execution.status).toBe("running");
+    expect(runs).toHaveLength(1);
+    expect(runs[0].executionId).toBe(execution.id);
+    expect(runs[0].instruction).toBe(
+      "Summarize the event\n\n---\nTriggered by: webhook (application/json)\nPayload:\n{\"order\":42}",
+    );
+    expect(db.select().from(scheduledTasks).where(eq(scheduledTasks.id, taskId)).get()!.runCount).toBe(1);
+
+    runs[0].finish();
+    await vi.waitFor(() => expect(executionStatus(execution.id)).toBe("success"));
+  });
+
+  it("rejects wrong tokens and unknown tasks alike", () => {
+    expect(code(() => fireWebhookTrigger(taskId, "wrong", "{}", ""))).toBe("UNAUTHENTICATED");
+    expect(code(() => fireWebhookTrigger(taskId, "", "{}", ""))).toBe("UNAUTHENTICATED");
+    expect(code(() => fireWebhookTrigger("no-such-task", token, "{}", ""))).toBe("UNAUTHENTICATED");
+    expect(runs).toHaveLength(0);
+  });
+
+  it("enforces the rate limit", () => {
+    fireWebhookTrigger(taskId, token, "{}", "");
+    fireWebhookTrigger(taskId, token, "{}", "");
+    expect(code(() => fireWebhookTrigger(taskId, token, "{}", ""))).toBe("RESOURCE_EXHAUSTED");
+    expect(runs).toHaveLength(2);
+  });
+
+  it("refuses tasks that cannot run", () => {
+    db.update(scheduledTasks).set({ status: "paused" }).where(eq(scheduledTasks.id, taskId)).run();
+    expect(code(() => fireWebhookTrigger(taskId, token, "{}", ""))).toBe("FAILED_PRECONDITION");
+
+    db.update(scheduledTasks).set({ status: "active", maxRuns: 1, runCount: 1 }).where(eq(scheduledTasks.id, taskId)).run();
+    expect(code(() => fireWebhookTrigger(taskId, token, "{}", ""))).toBe("FAILED_PRECONDITION");
+    expect(runs).toHaveLength(0);
+  });
+
+  it("completes the task once its last run finishes", async () => {
+    db.update(scheduledTasks).set({ maxRuns: 1 }).where(eq(scheduledTasks.id, taskId)).run();
+    fireWebhookTrigger(taskId, token, "{}", "");
+    runs[0].finish();
+    await vi.waitFor(() => {
+      expect(db.select().from(scheduledTasks).where(eq(scheduledTasks.id, taskId)).get()!.status).toBe("completed");
+    });
+  });
+});
@@ -0,0 +1,30 @@
+import crypto from "crypto";
+import { describe, it, expect } from "vitest";
+import { slackPlugin } from "../modules/channel/plugins/slack.js";
+import { telegramPlugin } from "../modules/channel/plugins/telegram.js";
+
+describe("webhook verification", () => {
+  it("accepts a telegram secret token sent once", () => {
+    const headers = { "x-telegram-bot-api-secret-token": ["s3cret"] };
+    expect(telegramPlugin.verifyWebhook("{}", headers, { secretToken: "s3cret" })).toBe(true);
+  });
+
+  it("rejects a repeated telegram secret token", () => {
+    const headers = { "x-telegram-bot-api-secret-token": ["s3cret", "other"] };
+    expect(telegramPlugin.verifyWebhook("{}", headers, { secretToken: "s3cret" })).toBe(false);
+  });
+
+  it("rejects a slack request with a second signature", () => {
+    const body = '{"type":"event_callback"}';
+    const ts = "1700000000";
+    const sig = "v0=" + crypto.createHmac("sha256", "signing").update(`v0:${ts}:${body}`).digest("hex");
+    const config = { signingSecret: "signing" };
+    const receivedAt = Number(ts) * 1000;
+
+    const signed = { "x-slack-request-timestamp": [ts], "x-slack-signature": [sig] };
+    expect(slackPlugin.verifyWebhook(body, signed, config, receivedAt)).toBe(true);
+
+    const doubled = { ...signed, "x-slack-signature": ["v0=forged", sig] };
+    expect(slackPlugin.verifyWebhook(body, doubled, config, receivedAt)).toBe(false);
+  });
+});
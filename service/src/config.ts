@@ -6,6 +6,7 @@ export const config = {
   jwtRefreshExpiry: process.env.JWT_REFRESH_EXPIRY ?? "30d",
   dbPath: process.env.DB_PATH ?? "../data/app.db",
   runtimeAddr: process.env.RUNTIME_ADDR ?? "http://localhost:8082",
+  gatewayAddr: process.env.GATEWAY_ADDR ?? "http://localhost:8080",
   runtimeSecret: process.env.RUNTIME_SECRET ?? "dev-runtime-secret",
   encryptionSecret: process.env.ENCRYPTION_SECRET ?? process.env.JWT_SECRET ?? "dev-secret-change-in-production",
 };
@@ -325,7 +325,9 @@ export const kbDocuments = sqliteTable("kb_documents", {
   type: text("type"),
   size: integer("size"),
   status: text("status").notNull().default("processing"),
+  // Legacy host path; new uploads set storageUri instead.
   filePath: text("file_path"),
+  storageUri: text("storage_uri"),
   chunkCount: integer("chunk_count").notNull().default(0),
   processedAt: text("processed_at"),
   errorMessage: text("error_message"),
@@ -379,13 +381,22 @@ export const channelMessages = sqliteTable("channel_messages", {
   direction: text("direction").notNull(), // inbound | outbound
   sender: text("sender"),
   content: text("content"),
-  status: text("status").notNull().default("received"),
+  status: text("status").notNull().default("received"), // received | sent | edited | recalled
+  chatId: text("chat_id"),
+  platformMessageId: text("platform_message_id"),
+  messageType: text("message_type").notNull().default("text"), // text | markdown | image | file | card
+  threadId: text("thread_id"),
+  agentId: text("agent_id"), // agent the message was routed to or sent by
   createdAt: text("created_at")
     .notNull()
     .default(sql`(datetime('now'))`),
 }, (t) => ({
   idxChannel: index("channel_messages_channel_id_idx").on(t.channelId),
+  idxPlatformMessage: index("channel_messages_platform_message_id_idx").on(t.channelId, t.platformMessageId),
+  idxChannelCreated: index("channel_messages_channel_created_idx").on(t.channelId, t.createdAt, t.id),
 }));
+// channel_messages_fts (FTS5, trigram) mirrors content for search; it is
+// created and kept in sync by triggers in drizzle/0030_channel_message_history.sql.
 
 export const routingRules = sqliteTable("routing_rules", {
   id: text("id").primaryKey(),
@@ -555,14 +566,22 @@ export const scheduledTasks = sqliteTable("scheduled_tasks", {
     .references(() => workspaces.id, { onDelete: "cascade" }),
   name: text("name").notNull(),
   instruction: text("instruction"),
-  // schedule_type: "cron" | "once"
+  // schedule_type: "cron" | "once" | "webhook" | "channel_message" | "kb_document"
   scheduleType: text("schedule_type").notNull().default("cron"),
   cronExpression: text("cron_expression"),  // for "cron" type
+  timezone: text("timezone"),               // IANA zone for cron_expression; null = server local time
   runAt: text("run_at"),                    // for "once" type (ISO datetime)
+  triggerJson: text("trigger_json"),        // for event types: JSON trigger config
+  triggerToken: text("trigger_token"),      // for "webhook" type: secret for /triggers/{taskId}
   maxRuns: integer("max_runs"),             // null = unlimited, N = stop after N runs
   runCount: integer("run_count").notNull().default(0),
   targetAgentId: text("target_agent_id"),
   status: text("status").notNull().default("active"),
+  // concurrency_policy: "allow" | "forbid" | "replace" a run still in progress
+  concurrencyPolicy: text("concurrency_policy").notNull().default("allow"),
+  retryMaxAttempts: integer("retry_max_attempts").notNull().default(1),       // 1 = no retries
+  retryBackoffSeconds: integer("retry_backoff_seconds").notNull().default(60), // doubled per retry
+  pausedAt: text("paused_at"),
   createdAt: text("created_at")
     .notNull()
     .default(sql`(datetime('now'))`),
@@ -579,7 +598,13 @@ export const taskExecutions = sqliteTable("task_executions", {
   startedAt: text("started_at"),
   endedAt: text("ended_at"),
   result: text("result"),
-});
+  sessionId: text("session_id"),          // the execution's dedicated chat session
+  runId: text("run_id"),                  // set when the run finishes; look up by session before then
+  attempt: integer("attempt").notNull().default(1),
+  scheduledFor: text("scheduled_for"),    // the cron/once slot; null for manual and triggered runs
+}, (t) => ({
+  idxTaskStarted: index("task_executions_task_started_idx").on(t.taskId, t.startedAt, t.id),
+}));
 
 // ─── Monitoring ──────────────────────────────────────────────────────────────
 
@@ -756,6 +781,9 @@ export const workspaceSettings = sqliteTable("workspace_settings", {
   ocrConfigJson: text("ocr_config_json").default("{}"),
   documentProcessingProvider: text("document_processing_provider").default(""),
   documentProcessingConfigJson: text("document_processing_config_json").default("{}"),
+  webSearchProvider: text("web_search_provider").default(""),
+  webSearchConfigJson: text("web_search_config_json").default("{}"),
+  kbUploadPolicyJson: text("kb_upload_policy_json").default("{}"),
   createdAt: text("created_at")
     .notNull()
     .default(sql`(datetime('now'))`),
@@ -870,3 +898,26 @@ export const usageRecords = sqliteTable("usage_records", {
   idxAgent: index("usage_records_agent_id_idx").on(t.agentId),
   uniqTask: uniqueIndex("usage_records_task_uniq").on(t.taskId, t.recordType),
 }));
+
+// ─── Audit Log (gateway mutating calls) ─────────────────────────────────────
+
+export const auditLogs = sqliteTable("audit_logs", {
+  id: text("id").primaryKey(),
+  orgId: text("org_id").notNull().default(""),
+  workspaceId: text("workspace_id").notNull().default(""),
+  userId: text("user_id").notNull().default(""),
+  method: text("method").notNull(),
+  route: text("route").notNull().default(""),
+  path: text("path").notNull(),
+  paramsJson: text("params_json"),
+  requestId: text("request_id"),
+  status: integer("status").notNull().default(0),
+  latencyMs: integer("latency_ms").notNull().default(0),
+  changesJson: text("changes_json"), // redacted by the gateway
+  createdAt: text("created_at")
+    .notNull()
+    .default(sql`(datetime('now'))`),
+}, (t) => ({
+  idxOrgCreatedAt: index("audit_logs_org_created_at_idx").on(t.orgId, t.createdAt),
+  idxUser: index("audit_logs_user_id_idx").on(t.userId),
+}));
@@ -4,7 +4,7 @@ import path from "path";
 import { fileURLToPath } from "url";
 const __dirname = path.dirname(fileURLToPath(import.meta.url));
 import { login, signup, logout, refresh, getMe } from "../modules/auth/auth.service.js";
-import { getOrg, updateOrg, listMembers, listWorkspaces, getDashboardStats, listOrgs } from "../modules/org/org.service.js";
+import { getOrg, updateOrg, listMembers, listWorkspaces, getDashboardStats, listOrgs, listMemberships, recordAuditEntries, listAuditEntries } from "../modules/org/org.service.js";
 import {
   getWorkspace,
   createWorkspace,
@@ -13,6 +13,7 @@ import {
 } from "../modules/workspace/workspace.service.js";
 import {
   getWorkspaceSettings,
+  getWebSearchSettings,
   updateWorkspaceSettings,
   listProviders, createProvider, updateProvider, deleteProvider, testProvider,
   listModels, listAllModels, createModel, updateModel, deleteModel, listModelSeries, listModelCatalog,
@@ -29,16 +30,19 @@ import {
   listKnowledgeBaseDocuments,
   createKnowledgeBaseDocument,
   deleteKnowledgeBaseDocument,
+  getKnowledgeBaseUploadPolicy,
   searchKnowledgeBase,
 } from "../modules/tools/tools.service.js";
 import {
   listChannels, getChannel, createChannel, updateChannel, deleteChannel,
-  listRoutingRules, createRoutingRule, updateRoutingRule, deleteRoutingRule,
-  handleWebhook, listChannelMessages, sendChannelMessage, bootstrapChannelConnections, isSupportedChannelType,
+  listRoutingRules, createRoutingRule, updateRoutingRule, deleteRoutingRule, simulateRoutingRules,
+  verifyWebhook, handleWebhook, listOutgoingWebhooks, listEmailChannels, listChannelMessages, sendChannelMessage,
+  sendRichChannelMessage, updateChannelMessage, deleteChannelMessage, bootstrapChannelConnections, isSupportedChannelType,
 } from "../modules/channel/channel.service.js";
-import { getPlugin } from "../modules/channel/plugins/index.js";
+import { getPlugin, type WebhookHeaders } from "../modules/channel/plugins/index.js";
 import {
-  listTasks, createTask, updateTask, deleteTask, runTask, listExecutions, bootstrapScheduler,
+  listTasks, createTask, updateTask, deleteTask, runTask, listExecutions, getExecution, bootstrapScheduler,
+  fireWebhookTrigger, rotateTriggerToken, getTask, pauseTask, resumeTask, backfillTask,
 } from "../modules/scheduler/scheduler.service.js";
 import {
   getAgentConfig, createRun, appendMessage, updateRunStatus, createAgentTask, updateAgentTask,
@@ -46,7 +50,7 @@ import {
   getContinueContextByMessageId, getContinueContextByRunId,
 } from "../modules/agent-run/agent-run.service.js";
 import {
-  listSessions, createSession, updateSession, deleteSession, listMessages, saveUserMessage, updateUserMessage,
+  listSessions, createSession, getSession, updateSession, deleteSession, listMessages, saveUserMessage, updateUserMessage,
   getRuntimeMetrics, listUsageRecords,
   reportWorkspacePluginUsageEvents,
   listAgents, createAgent, getAgent, updateAgent, deleteAgent,
@@ -150,6 +154,25 @@ function readNumberField(input: Record<string, unknown>, keys: string[]): number
   return undefined;
 }
 
+// Webhook headers keyed by lower-cased name with every value kept, so the
+// channel plugins can refuse a repeated signature header. Prefers
+// headerValues, which the gateway always sends, over the legacy single-value
+// map.
+function webhookHeaders(request: {
+  headers?: Record<string, string>;
+  headerValues?: Record<string, { values?: string[] }>;
+}): WebhookHeaders {
+  const out: WebhookHeaders = {};
+  for (const [key, value] of Object.entries(request.headers ?? {})) {
+    out[key.toLowerCase()] = [value];
+  }
+  for (const [key, entry] of Object.entries(request.headerValues ?? {})) {
+    const values = entry?.values ?? [];
+    if (values.length > 0) out[key.toLowerCase()] = [...values];
+  }
+  return out;
+}
+
 function pluginConfigFieldToProto(field: PluginConfigField): Record<string, unknown> {
   return {
     key: field.key,
@@ -357,6 +380,13 @@ export function startGrpcServer(port: number): grpc.Server {
       }
       catch (err) { handleError(callback, err); }
     },
+    listMemberships(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
+      try {
+        const userId = call.request.userContext?.userId;
+        if (!userId) { callback(grpcError(grpc.status.UNAUTHENTICATED, "missing user context")); return; }
+        callback(null, { memberships: listMemberships(userId) });
+      } catch (err) { handleError(callback, err); }
+    },
     getDashboardStats(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
       try {
         assertOrgMember(call.request.orgId, call.request.userContext?.userId);
@@ -369,6 +399,25 @@ export function startGrpcServer(port: number): grpc.Server {
         });
       } catch (err) { handleError(callback, err); }
     },
+    // Called by the gateway's audit sink (service-to-service, no user context)
+    recordAuditEntries(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
+      try {
+        recordAuditEntries(call.request.entries ?? []);
+        callback(null, {});
+      } catch (err) { handleError(callback, err); }
+    },
+    listAuditEntries(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
+      try {
+        assertOrgMember(call.request.orgId, call.request.userContext?.userId);
+        callback(null, {
+          entries: listAuditEntries(call.request.orgId, {
+            workspaceId: call.request.workspaceId,
+            userId: call.request.userId,
+            limit: call.request.limit,
+          }),
+        });
+      } catch (err) { handleError(callback, err); }
+    },
   });
 
   // ── Workspace ─────────────────────────────────────────────────────────────
@@ -438,6 +487,9 @@ export function startGrpcServer(port: number): grpc.Server {
           ocrConfigJson: call.request.ocrConfigJson,
           documentProcessingProvider: call.request.documentProcessingProvider,
           documentProcessingConfigJson: call.request.documentProcessingConfigJson,
+          webSearchProvider: call.request.webSearchProvider,
+          webSearchConfigJson: call.request.webSearchConfigJson,
+          kbUploadPolicyJson: call.request.kbUploadPolicyJson,
           setName: call.request.setName,
           setDescription: call.request.setDescription,
           setDefaultModel: call.request.setDefaultModel,
@@ -452,9 +504,18 @@ export function startGrpcServer(port: number): grpc.Server {
           setOcrConfigJson: call.request.setOcrConfigJson,
           setDocumentProcessingProvider: call.request.setDocumentProcessingProvider,
           setDocumentProcessingConfigJson: call.request.setDocumentProcessingConfigJson,
+          setWebSearchProvider: call.request.setWebSearchProvider,
+          setWebSearchConfigJson: call.request.setWebSearchConfigJson,
+          setKbUploadPolicyJson: call.request.setKbUploadPolicyJson,
         }));
       } catch (err) { handleError(callback, err); }
     },
+    // getWebSearchSettings is called by the gateway on behalf of the AI runtime; no user context auth required.
+    getWebSearchSettings(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
+      try {
+        callback(null, getWebSearchSettings(call.request.workspaceId));
+      } catch (err) { handleError(callback, err); }
+    },
     listProviders(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
       try {
         assertWorkspaceMember(call.request.workspaceId, call.request.userContext?.userId);
@@ -693,6 +754,7 @@ export function startGrpcServer(port: number): grpc.Server {
           type: call.request.type,
           size: call.request.size,
           filePath: call.request.filePath,
+          storageUri: call.request.storageUri,
         }));
       } catch (err) { handleError(callback, err); }
     },
@@ -706,6 +768,12 @@ export function startGrpcServer(port: number): grpc.Server {
         callback(null, {});
       } catch (err) { handleError(callback, err); }
     },
+    getKnowledgeBaseUploadPolicy(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
+      try {
+        assertKnowledgeBaseMember(call.request.id, call.request.userContext?.userId);
+        callback(null, getKnowledgeBaseUploadPolicy(call.request.id));
+      } catch (err) { handleError(callback, err); }
+    },
     async searchKnowledgeBase(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
       try {
         assertKnowledgeBaseMember(call.request.knowledgeBaseId, call.request.userContext?.userId);
@@ -803,16 +871,72 @@ export function startGrpcServer(port: number): grpc.Server {
       }
       catch (err) { handleError(callback, err); }
     },
+    simulateRoutingRules(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
+      try {
+        const req = call.request;
+        assertChannelMember(req.channelId, req.userContext?.userId);
+        callback(null, simulateRoutingRules({
+          channelId: req.channelId,
+          message: req.message ?? undefined,
+          messageId: req.messageId,
+          replayLast: req.replayLast,
+          draft: req.draft,
+          rules: req.rules,
+        }));
+      } catch (err) { handleError(callback, err); }
+    },
+    verifyWebhook(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
+      try {
+        const result = verifyWebhook(
+          call.request.channelId,
+          call.request.body,
+          webhookHeaders(call.request),
+          Number(call.request.receivedAtMs) || undefined,
+        );
+        callback(null, result);
+      } catch (err) { handleError(callback, err); }
+    },
     handleWebhook(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
       try {
-        const result = handleWebhook(call.request.channelId, call.request.body, call.request.headers ?? {});
+        const result = handleWebhook(call.request.channelId, call.request.body, webhookHeaders(call.request), {
+          verified: Boolean(call.request.verified),
+          receivedAt: Number(call.request.receivedAtMs) || undefined,
+        });
         callback(null, result);
       } catch (err) { handleError(callback, err); }
     },
+    // Called by the gateway, not on behalf of a user.
+    listOutgoingWebhooks(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
+      try {
+        callback(null, {
+          webhooks: listOutgoingWebhooks({
+            workspaceId: call.request.workspaceId || undefined,
+            channelId: call.request.channelId || undefined,
+          }),
+        });
+      } catch (err) { handleError(callback, err); }
+    },
+    listEmailChannels(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
+      try {
+        callback(null, { channels: listEmailChannels({ channelId: call.request.channelId || undefined }) });
+      } catch (err) { handleError(callback, err); }
+    },
     listChannelMessages(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
       try {
         assertChannelMember(call.request.channelId, call.request.userContext?.userId);
-        callback(null, { messages: listChannelMessages(call.request.channelId, call.request.limit || 50) });
+        const req = call.request;
+        callback(null, listChannelMessages(req.channelId, {
+          limit: req.limit,
+          cursor: req.cursor,
+          chatId: req.chatId,
+          threadId: req.threadId,
+          direction: req.direction,
+          agentId: req.agentId,
+          from: req.from,
+          to: req.to,
+          q: req.q,
+          order: req.order,
+        }));
       } catch (err) { handleError(callback, err); }
     },
     async testConnection(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
@@ -840,6 +964,39 @@ export function startGrpcServer(port: number): grpc.Server {
         callback(null, {})
       } catch (err) { handleError(callback, err) }
     },
+    // Rich send, edit and recall are runtime calls too (X-Runtime-Secret at the gateway).
+    async sendRichChannelMessage(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
+      try {
+        const sent = await sendRichChannelMessage({
+          channelId: call.request.channelId,
+          chatId: call.request.chatId,
+          threadId: call.request.threadId || undefined,
+          message: call.request.message ?? undefined,
+        })
+        callback(null, sent)
+      } catch (err) { handleError(callback, err) }
+    },
+    async updateChannelMessage(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
+      try {
+        const sent = await updateChannelMessage({
+          channelId: call.request.channelId,
+          chatId: call.request.chatId,
+          messageId: call.request.messageId,
+          message: call.request.message ?? undefined,
+        })
+        callback(null, sent)
+      } catch (err) { handleError(callback, err) }
+    },
+    async deleteChannelMessage(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
+      try {
+        await deleteChannelMessage({
+          channelId: call.request.channelId,
+          chatId: call.request.chatId,
+          messageId: call.request.messageId,
+        })
+        callback(null, {})
+      } catch (err) { handleError(callback, err) }
+    },
   });
 
   server.bindAsync(
@@ -872,9 +1029,14 @@ export function startGrpcServer(port: number): grpc.Server {
           instruction: call.request.instruction,
           scheduleType: call.request.scheduleType || "cron",
           cronExpression: call.request.cronExpression,
+          timezone: call.request.timezone,
+          triggerJson: call.request.triggerJson,
           runAt: call.request.runAt,
           maxRuns: call.request.maxRuns || undefined,
           targetAgentId: call.request.targetAgentId,
+          concurrencyPolicy: call.request.concurrencyPolicy || undefined,
+          retryMaxAttempts: call.request.retryMaxAttempts || undefined,
+          retryBackoffSeconds: call.request.retryBackoffSeconds || undefined,
         }));
       } catch (err) { handleError(callback, err); }
     },
@@ -886,9 +1048,14 @@ export function startGrpcServer(port: number): grpc.Server {
           instruction: call.request.instruction,
           scheduleType: call.request.scheduleType,
           cronExpression: call.request.cronExpression,
+          timezone: call.request.timezone,
+          triggerJson: call.request.triggerJson,
           runAt: call.request.runAt,
           maxRuns: call.request.maxRuns || undefined,
           targetAgentId: call.request.targetAgentId,
+          concurrencyPolicy: call.request.concurrencyPolicy || undefined,
+          retryMaxAttempts: call.request.retryMaxAttempts || undefined,
+          retryBackoffSeconds: call.request.retryBackoffSeconds || undefined,
           status: call.request.status,
         }));
       } catch (err) { handleError(callback, err); }
@@ -906,8 +1073,60 @@ export function startGrpcServer(port: number): grpc.Server {
       catch (err) { handleError(callback, err); }
     },
     listExecutions(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
-      try { callback(null, { executions: listExecutions(call.request.taskId, call.request.limit || 20) }); }
-      catch (err) { handleError(callback, err); }
+      try {
+        assertSchedulerTaskMember(call.request.taskId, call.request.userContext?.userId);
+        callback(null, listExecutions(call.request.taskId, {
+          limit: call.request.limit || undefined,
+          cursor: call.request.cursor || undefined,
+          status: call.request.status || undefined,
+          from: call.request.from || undefined,
+          to: call.request.to || undefined,
+        }));
+      } catch (err) { handleError(callback, err); }
+    },
+    getExecution(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
+      try {
+        assertSchedulerTaskMember(call.request.taskId, call.request.userContext?.userId);
+        callback(null, getExecution(call.request.taskId, call.request.executionId));
+      } catch (err) { handleError(callback, err); }
+    },
+    // Called by the gateway's public /triggers route: the token is the credential.
+    fireTrigger(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
+      try {
+        callback(null, fireWebhookTrigger(
+          call.request.taskId, call.request.token, call.request.payload, call.request.contentType,
+        ));
+      } catch (err) { handleError(callback, err); }
+    },
+    rotateTriggerToken(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
+      try {
+        assertSchedulerTaskMember(call.request.taskId, call.request.userContext?.userId);
+        callback(null, rotateTriggerToken(call.request.taskId));
+      } catch (err) { handleError(callback, err); }
+    },
+    getTask(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
+      try {
+        assertSchedulerTaskMember(call.request.taskId, call.request.userContext?.userId);
+        callback(null, getTask(call.request.taskId));
+      } catch (err) { handleError(callback, err); }
+    },
+    pauseTask(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
+      try {
+        assertSchedulerTaskMember(call.request.taskId, call.request.userContext?.userId);
+        callback(null, pauseTask(call.request.taskId));
+      } catch (err) { handleError(callback, err); }
+    },
+    resumeTask(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
+      try {
+        assertSchedulerTaskMember(call.request.taskId, call.request.userContext?.userId);
+        callback(null, resumeTask(call.request.taskId));
+      } catch (err) { handleError(callback, err); }
+    },
+    backfillTask(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
+      try {
+        assertSchedulerTaskMember(call.request.taskId, call.request.userContext?.userId);
+        callback(null, backfillTask(call.request.taskId, call.request.slots ?? []));
+      } catch (err) { handleError(callback, err); }
     },
   });
 
@@ -1108,6 +1327,13 @@ export function startGrpcServer(port: number): grpc.Server {
       }
       catch (err) { handleError(callback, err); }
     },
+    getSession(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
+      try {
+        assertSessionMember(call.request.sessionId, call.request.userContext?.userId);
+        callback(null, getSession(call.request.sessionId));
+      }
+      catch (err) { handleError(callback, err); }
+    },
     updateSession(call: grpc.ServerUnaryCall<any, any>, callback: grpc.sendUnaryData<any>) {
       try {
         assertSessionMember(call.request.sessionId, call.request.userContext?.userId);
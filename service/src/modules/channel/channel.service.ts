@@ -1,4 +1,4 @@
-import { and, eq, inArray } from 'drizzle-orm'
+import { and, asc, desc, eq, gt, gte, inArray, lt, or, sql, type SQL } from 'drizzle-orm'
 import * as Lark from '@larksuiteoapi/node-sdk'
 import { v4 as uuidv4 } from 'uuid'
 import { db } from '../../db/index.js'
@@ -11,7 +11,9 @@ import {
   routingRules,
 } from '../../db/schema.js'
 import { config } from '../../config.js'
-import { getPlugin, type ParsedMessage } from './plugins/index.js'
+import { getPlugin, outgoingWebhookEvents, type OutboundMessage, type ParsedMessage, type WebhookHeaders } from './plugins/index.js'
+import { readGatewayBlob } from '../../utils/gateway-blobs.js'
+import { publishGatewayEvent } from '../../utils/gateway-events.js'
 import './plugins/index.js' // ensure all plugins are registered on import
 
 type ChannelRow = typeof channels.$inferSelect
@@ -48,6 +50,8 @@ const SUPPORTED_CHANNEL_TYPES = new Set([
   'feishu',
   'dingtalk',
   'wecom',
+  'outgoing_webhook',
+  'email',
 ])
 
 export function isSupportedChannelType(type: string): boolean {
@@ -80,6 +84,11 @@ function assertChannelPluginInstalled(workspaceId: string, channelType: string):
   )
 }
 
+function assertValidChannelConfig(channelType: string, configJson?: string): void {
+  const problem = getPlugin(channelType).validateConfig?.(parseChannelConfig(configJson))
+  if (problem) throw Object.assign(new Error(`Invalid ${channelType} config: ${problem}`), { code: 'INVALID_ARGUMENT' })
+}
+
 function parseChannelConfig(configJson?: string | null): Record<string, string> {
   if (!configJson) return {}
   try {
@@ -206,25 +215,39 @@ function dispatchInboundToRuntime(params: {
   })
 }
 
+type InboundMessageListener = (channel: ChannelRow, parsed: ParsedMessage) => void
+
+const inboundMessageListeners: InboundMessageListener[] = []
+
+/** Observe every inbound channel message after it is logged and routed. */
+export function onInboundChannelMessage(listener: InboundMessageListener): void {
+  inboundMessageListeners.push(listener)
+}
+
 function ingestParsedMessage(channel: ChannelRow, parsed: ParsedMessage): void {
-  db.insert(channelMessages).values({
+  const { rule: matchedRule } = routeMessage(listRoutingRules(channel.id), parsed.content)
+
+  logChannelMessage(channel.workspaceId, {
     id: uuidv4(),
     channelId: channel.id,
     direction: 'inbound',
     sender: parsed.sender,
     content: parsed.content,
     status: 'received',
-  }).run()
+    chatId: parsed.chatId || null,
+    threadId: parsed.threadId || null,
+    platformMessageId: parsed.messageId || null,
+    agentId: matchedRule?.targetAgentId || null,
+  })
 
-  const rules = db
-    .select()
-    .from(routingRules)
-    .where(eq(routingRules.channelId, channel.id))
-    .all()
-    .filter((r) => r.enabled)
-    .sort((a, b) => (b.priority ?? 0) - (a.priority ?? 0))
+  for (const listener of inboundMessageListeners) {
+    try {
+      listener(channel, parsed)
+    } catch (err) {
+      console.error(`[channel] inbound message listener failed: ${err instanceof Error ? err.message : err}`)
+    }
+  }
 
-  const matchedRule = rules.find((rule) => matchRule(rule, parsed.content))
   if (!matchedRule?.targetAgentId) return
 
   const agentId = matchedRule.targetAgentId
@@ -527,6 +550,7 @@ export function createChannel(data: {
   const channelType = data.type.trim().toLowerCase()
   assertSupportedChannelType(channelType)
   assertChannelPluginInstalled(data.workspaceId, channelType)
+  assertValidChannelConfig(channelType, data.configJson)
   const id = uuidv4()
   db.insert(channels).values({
     id,
@@ -548,6 +572,7 @@ export function updateChannel(channelId: string, data: {
 }) {
   const ch = db.select().from(channels).where(eq(channels.id, channelId)).get()
   if (!ch) throw Object.assign(new Error('Channel not found'), { code: 'NOT_FOUND' })
+  if (data.configJson !== undefined) assertValidChannelConfig(ch.type, data.configJson)
 
   db.update(channels).set({
     ...(data.name && { name: data.name }),
@@ -625,29 +650,214 @@ export function deleteRoutingRule(ruleId: string) {
   db.delete(routingRules).where(eq(routingRules.id, ruleId)).run()
 }
 
+// ─── Routing Simulation ───────────────────────────────────────────────────────
+
+const MAX_SIMULATION_REPLAY = 200
+
+type SimulatedMessage = { content?: string; sender?: string; chatId?: string; threadId?: string }
+
+type DraftRoutingRule = {
+  id?: string
+  field?: string
+  operator?: string
+  value?: string
+  targetAgentId?: string
+  priority?: number
+  enabled?: boolean
+}
+
+/**
+ * Dry-run routing for a sample message, one stored inbound message or the
+ * last N of them. Nothing is recorded and no agent is dispatched. With
+ * draft set, draftRules stand in for the saved rules and every result also
+ * says where the saved rules would have sent the message.
+ */
+export function simulateRoutingRules(data: {
+  channelId: string
+  message?: SimulatedMessage
+  messageId?: string
+  replayLast?: number
+  draft?: boolean
+  rules?: DraftRoutingRule[]
+}) {
+  const sources = [data.message?.content !== undefined, !!data.messageId, (data.replayLast ?? 0) > 0]
+  if (sources.filter(Boolean).length !== 1) {
+    throw invalidArgument('Provide exactly one of message, messageId or replayLast')
+  }
+
+  let samples: Array<{ messageId: string; message: Required<SimulatedMessage> }>
+  if (data.messageId) {
+    const row = db.select().from(channelMessages).where(and(
+      eq(channelMessages.channelId, data.channelId),
+      eq(channelMessages.id, data.messageId),
+    )).get()
+    if (!row) throw Object.assign(new Error('Channel message not found'), { code: 'NOT_FOUND' })
+    if (row.direction !== 'inbound') throw invalidArgument('Only inbound messages can be replayed')
+    samples = [storedSample(row)]
+  } else if (data.replayLast) {
+    if (data.replayLast > MAX_SIMULATION_REPLAY) {
+      throw invalidArgument(`replayLast must be at most ${MAX_SIMULATION_REPLAY}`)
+    }
+    samples = db.select().from(channelMessages)
+      .where(and(eq(channelMessages.channelId, data.channelId), eq(channelMessages.direction, 'inbound')))
+      .orderBy(desc(channelMessages.createdAt), desc(channelMessages.id))
+      .limit(data.replayLast)
+      .all()
+      .map(storedSample)
+  } else {
+    samples = [{
+      messageId: '',
+      message: {
+        content: data.message?.content ?? '',
+        sender: data.message?.sender ?? '',
+        chatId: data.message?.chatId ?? '',
+        threadId: data.message?.threadId ?? '',
+      },
+    }]
+  }
+
+  const saved = listRoutingRules(data.channelId)
+  const rules = data.draft
+    ? (data.rules ?? []).map((rule, i): RoutingRuleRow => ({
+      id: rule.id || `draft-${i + 1}`,
+      channelId: data.channelId,
+      field: rule.field || 'content',
+      operator: rule.operator ?? '',
+      value: rule.value ?? null,
+      targetAgentId: rule.targetAgentId || null,
+      priority: rule.priority ?? 0,
+      enabled: rule.enabled ?? true,
+    }))
+    : saved
+
+  return {
+    results: samples.map(({ messageId, message }) => {
+      const { rule, trace } = routeMessage(rules, message.content)
+      const targetAgentId = rule?.targetAgentId ?? ''
+      const savedTargetAgentId = data.draft ? routeMessage(saved, message.content).rule?.targetAgentId ?? '' : targetAgentId
+      return {
+        messageId,
+        message,
+        matchedRule: rule,
+        targetAgentId,
+        trace,
+        savedTargetAgentId,
+        changed: targetAgentId !== savedTargetAgentId,
+      }
+    }),
+  }
+}
+
+function storedSample(row: typeof channelMessages.$inferSelect) {
+  return {
+    messageId: row.id,
+    message: {
+      content: row.content ?? '',
+      sender: row.sender ?? '',
+      chatId: row.chatId ?? '',
+      threadId: row.threadId ?? '',
+    },
+  }
+}
+
+// ─── Outgoing Webhooks ────────────────────────────────────────────────────────
+//
+// Outgoing webhook channels are delivered to by the gateway, which looks
+// them up here when it fans an event out and before each attempt.
+
+export function listOutgoingWebhooks(filter: { workspaceId?: string; channelId?: string }) {
+  if (!filter.workspaceId === !filter.channelId) throw invalidArgument('Provide exactly one of workspaceId or channelId')
+  const scope = filter.channelId ? eq(channels.id, filter.channelId) : eq(channels.workspaceId, filter.workspaceId!)
+  return db.select().from(channels)
+    .where(and(scope, eq(channels.type, 'outgoing_webhook')))
+    .all()
+    .map((ch) => {
+      const cfg = parseChannelConfig(ch.configJson)
+      return {
+        channelId: ch.id,
+        workspaceId: ch.workspaceId,
+        url: cfg.url ?? '',
+        secret: cfg.secret ?? '',
+        events: outgoingWebhookEvents(cfg),
+        active: ch.status === 'active',
+      }
+    })
+}
+
+/**
+ * Active email channels with their config, for the gateway's IMAP poller.
+ * With channelId, just that channel (if it is an active email channel).
+ */
+export function listEmailChannels(filter: { channelId?: string }) {
+  const conds = [eq(channels.type, 'email'), eq(channels.status, 'active')]
+  if (filter.channelId) conds.push(eq(channels.id, filter.channelId))
+  return db.select().from(channels)
+    .where(and(...conds))
+    .all()
+    .map((ch) => ({ channelId: ch.id, workspaceId: ch.workspaceId, configJson: ch.configJson ?? '{}' }))
+}
+
+/**
+ * Report a workspace event for delivery to the workspace's outgoing
+ * webhooks. Skipped without a gateway call when nothing subscribes to it.
+ */
+export function publishWorkspaceEvent(workspaceId: string, type: string, data: Record<string, unknown>): void {
+  const subscribed = listOutgoingWebhooks({ workspaceId })
+    .some((hook) => hook.active && (hook.events.includes('*') || hook.events.includes(type)))
+  if (subscribed) void publishGatewayEvent({ workspaceId, type, data })
+}
+
 // ─── Webhook Handling ─────────────────────────────────────────────────────────
+//
+// The gateway calls verifyWebhook while the platform waits, queues the
+// event, then delivers it through handleWebhook. Delivery skips the
+// signature check for events the gateway already verified.
 
-export function handleWebhook(
+function loadWebhookChannel(channelId: string) {
+  const ch = db.select().from(channels).where(eq(channels.id, channelId)).get()
+  if (!ch || ch.status !== 'active') return null
+  return { ch, config: parseChannelConfig(ch.configJson), plugin: getPlugin(ch.type) }
+}
+
+export function verifyWebhook(
   channelId: string,
   body: string,
-  headers: Record<string, string>,
-): { accepted: boolean; challenge?: string; message: string } {
-  const ch = db.select().from(channels).where(eq(channels.id, channelId)).get()
-  if (!ch || ch.status !== 'active') {
+  headers: WebhookHeaders,
+  receivedAt?: number,
+): { accepted: boolean; challenge?: string; eventId?: string; message: string } {
+  const target = loadWebhookChannel(channelId)
+  if (!target) {
     return { accepted: false, message: 'Channel not found or inactive' }
   }
-
-  const channelConfig = parseChannelConfig(ch.configJson)
-  const plugin = getPlugin(ch.type)
+  const { config: channelConfig, plugin } = target
 
   const challenge = plugin.handleChallenge?.(body, channelConfig)
   if (challenge !== null && challenge !== undefined) {
     return { accepted: true, challenge, message: 'challenge' }
   }
 
-  if (!plugin.verifyWebhook(body, headers, channelConfig)) {
+  if (!plugin.verifyWebhook(body, headers, channelConfig, receivedAt)) {
     return { accepted: false, message: 'Invalid signature' }
   }
+  return { accepted: true, eventId: plugin.eventId?.(body) ?? '', message: 'ok' }
+}
+
+export function handleWebhook(
+  channelId: string,
+  body: string,
+  headers: WebhookHeaders,
+  opts: { verified?: boolean; receivedAt?: number } = {},
+): { accepted: boolean; challenge?: string; message: string } {
+  const target = loadWebhookChannel(channelId)
+  if (!target) {
+    return { accepted: false, message: 'Channel not found or inactive' }
+  }
+  const { ch, config: channelConfig, plugin } = target
+
+  if (!opts.verified) {
+    const verdict = verifyWebhook(channelId, body, headers, opts.receivedAt)
+    if (!verdict.accepted || verdict.challenge) return verdict
+  }
 
   const parsed = plugin.parseMessage(body)
   if (!parsed) {
@@ -658,26 +868,197 @@ export function handleWebhook(
   return { accepted: true, message: 'ok' }
 }
 
-function matchRule(rule: typeof routingRules.$inferSelect, content: string): boolean {
+type RoutingRuleRow = typeof routingRules.$inferSelect
+
+type RuleOutcome = 'matched' | 'not_matched' | 'disabled' | 'invalid_rule' | 'not_reached'
+
+type RuleEvaluation = { ruleId: string; priority: number; outcome: RuleOutcome; detail: string }
+
+export const ROUTING_OPERATORS = ['contains', 'starts_with', 'equals', 'regex']
+
+/**
+ * Evaluate one matcher against message content. Scheduler channel-message
+ * triggers use the same matchers as routing rules.
+ */
+export function evaluateRule(
+  rule: Pick<RoutingRuleRow, 'operator' | 'value'>,
+  content: string,
+): { outcome: RuleOutcome; detail: string } {
   const val = rule.value ?? ''
+  let matched: boolean
   switch (rule.operator) {
-    case 'contains': return content.includes(val)
-    case 'starts_with': return content.startsWith(val)
-    case 'equals': return content === val
-    case 'regex': try { return new RegExp(val).test(content) } catch { return false }
-    default: return false
+    case 'contains': matched = content.includes(val); break
+    case 'starts_with': matched = content.startsWith(val); break
+    case 'equals': matched = content === val; break
+    case 'regex':
+      try {
+        matched = new RegExp(val).test(content)
+      } catch (err) {
+        return { outcome: 'invalid_rule', detail: `invalid regex: ${err instanceof Error ? err.message : err}` }
+      }
+      break
+    default:
+      return { outcome: 'invalid_rule', detail: `unknown operator "${rule.operator}"` }
+  }
+  return {
+    outcome: matched ? 'matched' : 'not_matched',
+    detail: `content ${matched ? '' : 'does not '}satisfy ${rule.operator} ${JSON.stringify(val)}`,
+  }
+}
+
+/**
+ * Evaluate rules highest priority first; the first enabled rule that
+ * matches wins. Inbound routing and the simulator both go through here.
+ */
+function routeMessage(rules: RoutingRuleRow[], content: string): { rule?: RoutingRuleRow; trace: RuleEvaluation[] } {
+  const ordered = [...rules].sort((a, b) => (b.priority ?? 0) - (a.priority ?? 0))
+  const trace: RuleEvaluation[] = []
+  let matched: RoutingRuleRow | undefined
+  for (const rule of ordered) {
+    const entry = { ruleId: rule.id, priority: rule.priority ?? 0 }
+    if (matched) {
+      trace.push({ ...entry, outcome: 'not_reached', detail: `rule ${matched.id} matched first` })
+    } else if (!rule.enabled) {
+      trace.push({ ...entry, outcome: 'disabled', detail: 'rule is disabled' })
+    } else {
+      const result = evaluateRule(rule, content)
+      trace.push({ ...entry, ...result })
+      if (result.outcome === 'matched') matched = rule
+    }
   }
+  return { rule: matched, trace }
 }
 
 // ─── Messages ─────────────────────────────────────────────────────────────────
 
-export function listChannelMessages(channelId: string, limit = 50) {
-  return db
+const MAX_MESSAGE_PAGE = 200
+
+export type ChannelMessageFilter = {
+  limit?: number
+  cursor?: string
+  chatId?: string
+  threadId?: string
+  direction?: string
+  agentId?: string
+  from?: string
+  to?: string
+  q?: string
+  order?: string
+}
+
+type MessageCursor = { createdAt: string; id: string }
+
+function encodeMessageCursor(row: MessageCursor): string {
+  return Buffer.from(JSON.stringify({ c: row.createdAt, i: row.id })).toString('base64url')
+}
+
+function decodeMessageCursor(cursor: string): MessageCursor {
+  try {
+    const parsed = JSON.parse(Buffer.from(cursor, 'base64url').toString('utf8')) as { c?: unknown; i?: unknown }
+    if (typeof parsed.c === 'string' && typeof parsed.i === 'string') return { createdAt: parsed.c, id: parsed.i }
+  } catch { /* fall through */ }
+  throw invalidArgument('Invalid cursor')
+}
+
+/** ISO-8601 to the 'YYYY-MM-DD HH:MM:SS' UTC form created_at is stored in. */
+function sqliteTimestamp(value: string, field: string): string {
+  const date = new Date(value)
+  if (Number.isNaN(date.getTime())) throw invalidArgument(`${field} must be an ISO-8601 timestamp`)
+  return date.toISOString().replace('T', ' ').slice(0, 19)
+}
+
+/**
+ * Full-text match on content. The FTS index uses trigrams, so queries
+ * shorter than three characters fall back to a substring scan.
+ */
+function contentMatches(q: string): SQL {
+  if ([...q].length < 3) {
+    const pattern = `%${q.replace(/[\\%_]/g, (c) => `\\${c}`)}%`
+    return sql`${channelMessages.content} LIKE ${pattern} ESCAPE '\\'`
+  }
+  const phrase = `"${q.replace(/"/g, '""')}"`
+  return sql`${channelMessages}.rowid IN (SELECT rowid FROM channel_messages_fts WHERE channel_messages_fts MATCH ${phrase})`
+}
+
+/**
+ * Page through a channel's message log, newest first unless order is
+ * 'asc'. Pages are keyed on (created_at, id); pass nextCursor back to get
+ * the following page.
+ */
+export function listChannelMessages(channelId: string, filter: ChannelMessageFilter = {}) {
+  const limit = Math.min(Math.max(filter.limit || 50, 1), MAX_MESSAGE_PAGE)
+  const ascending = (filter.order ?? '').toLowerCase() === 'asc'
+  if (filter.order && !['asc', 'desc'].includes(filter.order.toLowerCase())) {
+    throw invalidArgument('order must be asc or desc')
+  }
+
+  const conditions: SQL[] = [eq(channelMessages.channelId, channelId)]
+  if (filter.chatId) conditions.push(eq(channelMessages.chatId, filter.chatId))
+  if (filter.threadId) conditions.push(eq(channelMessages.threadId, filter.threadId))
+  if (filter.agentId) conditions.push(eq(channelMessages.agentId, filter.agentId))
+  if (filter.direction) {
+    if (filter.direction !== 'inbound' && filter.direction !== 'outbound') {
+      throw invalidArgument('direction must be inbound or outbound')
+    }
+    conditions.push(eq(channelMessages.direction, filter.direction))
+  }
+  if (filter.from) conditions.push(gte(channelMessages.createdAt, sqliteTimestamp(filter.from, 'from')))
+  if (filter.to) conditions.push(lt(channelMessages.createdAt, sqliteTimestamp(filter.to, 'to')))
+  const q = filter.q?.trim()
+  if (q) conditions.push(contentMatches(q))
+  if (filter.cursor) {
+    const cursor = decodeMessageCursor(filter.cursor)
+    const after = ascending ? gt : lt
+    conditions.push(or(
+      after(channelMessages.createdAt, cursor.createdAt),
+      and(eq(channelMessages.createdAt, cursor.createdAt), after(channelMessages.id, cursor.id)),
+    )!)
+  }
+
+  const direction = ascending ? asc : desc
+  const rows = db
     .select()
     .from(channelMessages)
-    .where(eq(channelMessages.channelId, channelId))
+    .where(and(...conditions))
+    .orderBy(direction(channelMessages.createdAt), direction(channelMessages.id))
+    .limit(limit + 1)
     .all()
-    .slice(-limit)
+
+  const messages = rows.slice(0, limit)
+  const last = messages[messages.length - 1]
+  return {
+    messages,
+    nextCursor: rows.length > limit && last ? encodeMessageCursor(last) : '',
+  }
+}
+
+/** Log a channel message and report it to the workspace's outgoing webhooks. */
+function logChannelMessage(workspaceId: string, values: typeof channelMessages.$inferInsert): void {
+  db.insert(channelMessages).values(values).run()
+  publishWorkspaceEvent(workspaceId, 'channel.message', {
+    channelId: values.channelId,
+    messageId: values.id,
+    direction: values.direction,
+    chatId: values.chatId ?? '',
+    threadId: values.threadId ?? '',
+    sender: values.sender ?? '',
+    content: values.content ?? '',
+    messageType: values.messageType ?? 'text',
+    agentId: values.agentId ?? '',
+    platformMessageId: values.platformMessageId ?? '',
+  })
+}
+
+/** The agent currently handling a chat, for attributing outbound messages. */
+function chatAgentId(channelId: string, chatId: string): string | null {
+  if (!chatId) return null
+  const session = db
+    .select({ agentId: channelSessions.agentId })
+    .from(channelSessions)
+    .where(and(eq(channelSessions.channelId, channelId), eq(channelSessions.chatId, chatId)))
+    .orderBy(desc(channelSessions.lastActiveAt))
+    .get()
+  return session?.agentId ?? null
 }
 
 export async function sendChannelMessage(data: {
@@ -699,14 +1080,159 @@ export async function sendChannelMessage(data: {
     )
   }
 
-  await plugin.sendMessage(data.chatId, data.text, channelConfig, data.threadId)
+  await plugin.sendMessage(data.chatId, data.text, channelConfig, data.threadId, data.channelId)
 
-  db.insert(channelMessages).values({
+  logChannelMessage(ch.workspaceId, {
     id: uuidv4(),
     channelId: data.channelId,
     direction: 'outbound',
     sender: 'agent',
     content: data.text,
     status: 'sent',
-  }).run()
+    chatId: data.chatId || null,
+    threadId: data.threadId || null,
+    agentId: chatAgentId(data.channelId, data.chatId),
+  })
+}
+
+// ─── Rich outbound messages ───────────────────────────────────────────────────
+
+type OutboundMessageInput = {
+  type: string
+  text?: string
+  cardJson?: string
+  attachments?: Array<{ name?: string; mimeType?: string; storageUri?: string }>
+}
+
+const OUTBOUND_MESSAGE_TYPES = new Set(['text', 'markdown', 'image', 'file', 'card'])
+
+function invalidArgument(message: string) {
+  return Object.assign(new Error(message), { code: 'INVALID_ARGUMENT' })
+}
+
+function loadActiveChannel(channelId: string) {
+  const ch = db.select().from(channels).where(eq(channels.id, channelId)).get()
+  if (!ch || ch.status !== 'active') throw Object.assign(new Error('Channel not found or inactive'), { code: 'NOT_FOUND' })
+  return { ch, config: parseChannelConfig(ch.configJson), plugin: getPlugin(ch.type) }
+}
+
+/** Validate an outbound message and load its attachment from the gateway. */
+async function resolveOutboundMessage(input: OutboundMessageInput | undefined): Promise<OutboundMessage> {
+  const type = (input?.type ?? 'text').trim().toLowerCase() || 'text'
+  if (!OUTBOUND_MESSAGE_TYPES.has(type)) throw invalidArgument(`Unsupported message type: ${type}`)
+  const message: OutboundMessage = { type: type as OutboundMessage['type'], text: input?.text ?? '' }
+
+  if (type === 'card') {
+    try {
+      message.card = JSON.parse(input?.cardJson ?? '')
+    } catch {
+      throw invalidArgument('card must be valid JSON')
+    }
+  }
+  if (type === 'image' || type === 'file') {
+    const attachment = input?.attachments?.[0]
+    if (!attachment?.storageUri || input?.attachments?.length !== 1) {
+      throw invalidArgument(`${type} messages need exactly one attachment`)
+    }
+    message.attachment = {
+      name: attachment.name || type,
+      mimeType: attachment.mimeType || 'application/octet-stream',
+      data: await readGatewayBlob(attachment.storageUri),
+    }
+  }
+  return message
+}
+
+/** What the message log stores for an outbound message. */
+function outboundLogContent(message: OutboundMessage): string {
+  if (message.type === 'image' || message.type === 'file') {
+    return `[${message.type}: ${message.attachment?.name ?? ''}]${message.text ? ` ${message.text}` : ''}`
+  }
+  return message.text || (message.type === 'card' ? '[card]' : '')
+}
+
+export async function sendRichChannelMessage(data: {
+  channelId: string
+  chatId: string
+  threadId?: string
+  message?: OutboundMessageInput
+}): Promise<{ messageId: string; chatId: string }> {
+  const { ch, config: channelConfig, plugin } = loadActiveChannel(data.channelId)
+  const message = await resolveOutboundMessage(data.message)
+
+  let sent: { messageId: string; chatId?: string }
+  if (plugin.sendRichMessage) {
+    sent = await plugin.sendRichMessage(data.chatId, message, channelConfig, data.threadId, data.channelId)
+  } else if (plugin.sendMessage && message.type === 'text') {
+    // Plugins without rich support can still send plain text, but report
+    // no message ID.
+    await plugin.sendMessage(data.chatId, message.text, channelConfig, data.threadId, data.channelId)
+    sent = { messageId: '' }
+  } else {
+    throw Object.assign(
+      new Error(`Plugin ${plugin.type} does not support ${message.type} messages`),
+      { code: 'UNIMPLEMENTED' },
+    )
+  }
+
+  logChannelMessage(ch.workspaceId, {
+    id: uuidv4(),
+    channelId: data.channelId,
+    direction: 'outbound',
+    sender: 'agent',
+    content: outboundLogContent(message),
+    status: 'sent',
+    chatId: sent.chatId || data.chatId,
+    threadId: data.threadId || null,
+    platformMessageId: sent.messageId || null,
+    messageType: message.type,
+    agentId: chatAgentId(data.channelId, sent.chatId || data.chatId),
+  })
+  return { messageId: sent.messageId, chatId: sent.chatId || data.chatId }
+}
+
+export async function updateChannelMessage(data: {
+  channelId: string
+  chatId: string
+  messageId: string
+  message?: OutboundMessageInput
+}): Promise<{ messageId: string; chatId: string }> {
+  const { config: channelConfig, plugin } = loadActiveChannel(data.channelId)
+  if (!data.messageId) throw invalidArgument('messageId is required')
+  const message = await resolveOutboundMessage(data.message)
+  if (message.type === 'image' || message.type === 'file') {
+    throw invalidArgument(`${message.type} messages cannot be edited`)
+  }
+  if (!plugin.updateMessage) {
+    throw Object.assign(new Error(`Plugin ${plugin.type} does not support editing messages`), { code: 'UNIMPLEMENTED' })
+  }
+  const sent = await plugin.updateMessage(data.chatId, data.messageId, message, channelConfig)
+
+  db.update(channelMessages).set({
+    content: outboundLogContent(message),
+    messageType: message.type,
+    status: 'edited',
+  }).where(and(
+    eq(channelMessages.channelId, data.channelId),
+    eq(channelMessages.platformMessageId, data.messageId),
+  )).run()
+  return { messageId: sent.messageId || data.messageId, chatId: sent.chatId || data.chatId }
+}
+
+export async function deleteChannelMessage(data: {
+  channelId: string
+  chatId: string
+  messageId: string
+}): Promise<void> {
+  const { config: channelConfig, plugin } = loadActiveChannel(data.channelId)
+  if (!data.messageId) throw invalidArgument('messageId is required')
+  if (!plugin.deleteMessage) {
+    throw Object.assign(new Error(`Plugin ${plugin.type} does not support recalling messages`), { code: 'UNIMPLEMENTED' })
+  }
+  await plugin.deleteMessage(data.chatId, data.messageId, channelConfig)
+
+  db.update(channelMessages).set({ status: 'recalled' }).where(and(
+    eq(channelMessages.channelId, data.channelId),
+    eq(channelMessages.platformMessageId, data.messageId),
+  )).run()
 }
@@ -1,4 +1,5 @@
 import crypto from 'crypto'
+import { webhookHeader } from './headers.js'
 import type { ChannelPlugin, ParsedMessage, TestResult } from './types.js'
 
 export const discordPlugin: ChannelPlugin = {
@@ -9,8 +10,8 @@ export const discordPlugin: ChannelPlugin = {
     const publicKey = config.publicKey
     if (!publicKey) return true
 
-    const signature = headers['x-signature-ed25519']
-    const timestamp = headers['x-signature-timestamp']
+    const signature = webhookHeader(headers, 'x-signature-ed25519')
+    const timestamp = webhookHeader(headers, 'x-signature-timestamp')
     if (!signature || !timestamp) return false
 
     try {
@@ -32,6 +33,13 @@ export const discordPlugin: ChannelPlugin = {
     return null
   },
 
+  eventId(body): string | null {
+    try {
+      const parsed = JSON.parse(body) as Record<string, unknown>
+      return typeof parsed.id === 'string' && parsed.id ? parsed.id : null
+    } catch { return null }
+  },
+
   parseMessage(body): ParsedMessage | null {
     try {
       const parsed = JSON.parse(body) as Record<string, unknown>
@@ -0,0 +1,133 @@
+/**
+ * Email channel — the gateway owns the mail protocols. It polls the
+ * account's IMAP mailbox and queues each new message as a webhook event
+ * (already verified, so verifyWebhook never accepts public posts), and
+ * sends replies over SMTP threaded under the mail they answer.
+ *
+ * Config: address, password, username (defaults to address), imapHost,
+ * imapPort, imapSecurity (tls|none), smtpHost, smtpPort, smtpSecurity
+ * (tls|starttls|none), mailbox (default INBOX), subject (for new threads).
+ */
+import { config as appConfig } from '../../../config.js'
+import type { ChannelPlugin, OutboundMessage, ParsedMessage, SentMessage, TestResult } from './types.js'
+
+type EmailEvent = {
+  messageId: string
+  threadId: string
+  from: string
+  fromName?: string
+  subject: string
+  text: string
+  attachments: { name: string; mimeType: string; size: number; storageUri: string }[]
+}
+
+const EMAIL_RE = /^[^\s@<>]+@[^\s@<>]+$/
+
+function configError(config: Record<string, string>): string | null {
+  if (!EMAIL_RE.test((config.address ?? '').trim())) return 'address must be an email address'
+  if (!config.password) return 'password is required'
+  if (!(config.imapHost ?? '').trim() || !(config.smtpHost ?? '').trim()) return 'imapHost and smtpHost are required'
+  const imapSecurity = (config.imapSecurity ?? '').trim().toLowerCase()
+  if (imapSecurity && !['tls', 'none'].includes(imapSecurity)) return 'imapSecurity must be tls or none'
+  const smtpSecurity = (config.smtpSecurity ?? '').trim().toLowerCase()
+  if (smtpSecurity && !['tls', 'starttls', 'none'].includes(smtpSecurity)) {
+    return 'smtpSecurity must be tls, starttls or none'
+  }
+  for (const key of ['imapPort', 'smtpPort']) {
+    const raw = (config[key] ?? '').trim()
+    if (!raw) continue
+    const port = Number(raw)
+    if (!Number.isInteger(port) || port <= 0 || port > 65535) return `${key} must be a port number`
+  }
+  return null
+}
+
+async function callGateway<T>(path: string, body: unknown): Promise<T> {
+  const gatewayBase = (appConfig.gatewayAddr ?? '').trim().replace(/\/+$/, '')
+  const res = await fetch(`${gatewayBase}${path}`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json', 'X-Runtime-Secret': appConfig.runtimeSecret },
+    body: JSON.stringify(body),
+    signal: AbortSignal.timeout(60_000),
+  })
+  const payload = await res.json().catch(() => ({})) as { data?: T; error?: string }
+  if (!res.ok || payload.data === undefined) {
+    throw new Error(`email gateway ${path} failed (${res.status}): ${payload.error ?? 'no detail'}`)
+  }
+  return payload.data
+}
+
+async function send(
+  chatId: string,
+  message: OutboundMessage,
+  config: Record<string, string>,
+  threadId?: string,
+  channelId?: string,
+): Promise<SentMessage> {
+  const sent = await callGateway<{ messageId: string; threadId: string }>('/internal/email/send', {
+    channelId: channelId ?? '',
+    config,
+    to: chatId,
+    threadId: threadId ?? '',
+    text: message.text,
+    attachments: message.attachment
+      ? [{ name: message.attachment.name, mimeType: message.attachment.mimeType, data: message.attachment.data.toString('base64') }]
+      : [],
+  })
+  return { messageId: sent.messageId, chatId }
+}
+
+export const emailPlugin: ChannelPlugin = {
+  type: 'email',
+  label: 'Email',
+
+  // Mail arrives only through the gateway's IMAP poller.
+  verifyWebhook(): boolean {
+    return false
+  },
+
+  eventId(body): string | null {
+    try {
+      return (JSON.parse(body) as Partial<EmailEvent>).messageId || null
+    } catch { return null }
+  },
+
+  parseMessage(body): ParsedMessage | null {
+    try {
+      const event = JSON.parse(body) as EmailEvent
+      if (!event.from) return null
+      const lines = [event.subject, '', event.text.trim()]
+      for (const a of event.attachments ?? []) lines.push(`[attachment: ${a.name}]`)
+      const content = lines.join('\n').trim()
+      if (!content) return null
+      return {
+        content,
+        sender: event.fromName ? `${event.fromName} <${event.from}>` : event.from,
+        chatId: event.from,
+        threadId: event.threadId || event.messageId,
+        messageId: event.messageId,
+      }
+    } catch { return null }
+  },
+
+  validateConfig: configError,
+
+  /** Logs in to both servers through the gateway; nothing is sent. */
+  async testConnection(config): Promise<TestResult> {
+    const error = configError(config)
+    if (error) return { success: false, error }
+    try {
+      const result = await callGateway<{ success: boolean; error?: string }>('/internal/email/test', { config })
+      return result.success ? { success: true, botName: config.address.trim() } : { success: false, error: result.error }
+    } catch (err) {
+      return { success: false, error: err instanceof Error ? err.message : String(err) }
+    }
+  },
+
+  async sendMessage(chatId, text, config, threadId, channelId): Promise<void> {
+    await send(chatId, { type: 'text', text }, config, threadId, channelId)
+  },
+
+  // Mail has no cards or markdown rendering; the text goes as the body.
+  sendRichMessage: send,
+}
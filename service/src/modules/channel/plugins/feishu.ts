@@ -5,7 +5,8 @@
  */
 import crypto from 'crypto'
 import * as Lark from '@larksuiteoapi/node-sdk'
-import type { ChannelPlugin, ParsedMessage, TestResult } from './types.js'
+import { webhookHeader } from './headers.js'
+import type { ChannelPlugin, OutboundMessage, ParsedMessage, TestResult } from './types.js'
 
 // Client cache keyed by appId
 const clientCache = new Map<string, { client: Lark.Client; appSecret: string }>()
@@ -18,6 +19,70 @@ function getLarkClient(appId: string, appSecret: string): Lark.Client {
   return client
 }
 
+function requireLarkClient(config: Record<string, string>): Lark.Client {
+  const { appId, appSecret } = config
+  if (!appId || !appSecret) throw new Error('缺少 appId / appSecret')
+  return getLarkClient(appId, appSecret)
+}
+
+// Feishu file messages take one of a few fixed file types; anything else
+// is sent as a generic stream.
+function feishuFileType(name: string): 'pdf' | 'doc' | 'xls' | 'ppt' | 'stream' {
+  const ext = name.split('.').pop()?.toLowerCase() ?? ''
+  if (ext === 'pdf') return 'pdf'
+  if (ext === 'doc' || ext === 'docx') return 'doc'
+  if (ext === 'xls' || ext === 'xlsx') return 'xls'
+  if (ext === 'ppt' || ext === 'pptx') return 'ppt'
+  return 'stream'
+}
+
+function markdownCard(text: string) {
+  return { config: { wide_screen_mode: true }, elements: [{ tag: 'markdown', content: text }] }
+}
+
+/**
+ * Map an outbound message to a Feishu msg_type and content, uploading the
+ * attachment first when there is one. Images with a caption become a post
+ * so the caption travels with the image.
+ */
+async function feishuContent(
+  client: Lark.Client,
+  message: OutboundMessage,
+): Promise<{ msgType: string; content: string }> {
+  switch (message.type) {
+    case 'text':
+      return { msgType: 'text', content: JSON.stringify({ text: message.text }) }
+    case 'markdown':
+      return { msgType: 'interactive', content: JSON.stringify(markdownCard(message.text)) }
+    case 'card':
+      return { msgType: 'interactive', content: JSON.stringify(message.card) }
+    case 'image': {
+      const attachment = message.attachment!
+      const uploaded = await client.im.image.create({
+        data: { image_type: 'message', image: attachment.data },
+      })
+      const imageKey = uploaded?.image_key
+      if (!imageKey) throw new Error('Feishu image upload returned no image_key')
+      if (!message.text) return { msgType: 'image', content: JSON.stringify({ image_key: imageKey }) }
+      return {
+        msgType: 'post',
+        content: JSON.stringify({
+          zh_cn: { title: '', content: [[{ tag: 'img', image_key: imageKey }], [{ tag: 'text', text: message.text }]] },
+        }),
+      }
+    }
+    case 'file': {
+      const attachment = message.attachment!
+      const uploaded = await client.im.file.create({
+        data: { file_type: feishuFileType(attachment.name), file_name: attachment.name, file: attachment.data },
+      })
+      const fileKey = uploaded?.file_key
+      if (!fileKey) throw new Error('Feishu file upload returned no file_key')
+      return { msgType: 'file', content: JSON.stringify({ file_key: fileKey }) }
+    }
+  }
+}
+
 export const feishuPlugin: ChannelPlugin = {
   type: 'feishu',
   label: '飞书',
@@ -31,10 +96,12 @@ export const feishuPlugin: ChannelPlugin = {
     const { encryptKey } = config
     if (!encryptKey) return true
 
-    const timestamp = headers['x-lark-request-timestamp'] ?? ''
-    const nonce = headers['x-lark-request-nonce'] ?? ''
-    const signature = headers['x-lark-signature'] ?? ''
-    if (!timestamp || !signature) return true // unsigned request (e.g. plain challenge)
+    // unsigned request (e.g. plain challenge); a repeated header is not unsigned
+    if (!headers['x-lark-request-timestamp']?.[0] || !headers['x-lark-signature']?.[0]) return true
+    const timestamp = webhookHeader(headers, 'x-lark-request-timestamp')
+    const signature = webhookHeader(headers, 'x-lark-signature')
+    const nonce = headers['x-lark-request-nonce'] ? webhookHeader(headers, 'x-lark-request-nonce') : ''
+    if (timestamp === undefined || signature === undefined || nonce === undefined) return false
 
     const toSign = timestamp + nonce + encryptKey + body
     const expected = crypto.createHash('sha256').update(toSign).digest('hex')
@@ -68,6 +135,19 @@ export const feishuPlugin: ChannelPlugin = {
     return null
   },
 
+  /**
+   * Event ID from the v2 schema header, or the v1 uuid. Encrypted bodies
+   * carry none in the clear.
+   */
+  eventId(body): string | null {
+    try {
+      const parsed = JSON.parse(body) as Record<string, unknown>
+      const header = parsed.header as Record<string, unknown> | undefined
+      const id = header?.event_id ?? parsed.uuid
+      return typeof id === 'string' && id ? id : null
+    } catch { return null }
+  },
+
   /**
    * Parse incoming Feishu IM message event (im.message.receive_v1).
    */
@@ -145,4 +225,50 @@ export const feishuPlugin: ChannelPlugin = {
       },
     })
   },
+
+  async sendRichMessage(chatId, message, config, threadId) {
+    const client = requireLarkClient(config)
+    // File messages carry no caption, so the caption goes first as text.
+    if (message.type === 'file' && message.text) {
+      await feishuPlugin.sendRichMessage!(chatId, { type: 'text', text: message.text }, config, threadId)
+    }
+    const { msgType, content } = await feishuContent(client, message)
+    const response = threadId
+      ? await client.im.message.reply({
+        path: { message_id: threadId },
+        data: { msg_type: msgType, content, reply_in_thread: true },
+      })
+      : await client.im.message.create({
+        params: { receive_id_type: 'chat_id' },
+        data: { receive_id: chatId, msg_type: msgType, content },
+      })
+    if (response.code !== undefined && response.code !== 0) {
+      throw new Error(`Feishu API error: ${response.msg ?? `code ${response.code}`}`)
+    }
+    return { messageId: response.data?.message_id ?? '', chatId: response.data?.chat_id ?? chatId }
+  },
+
+  /**
+   * Text messages are replaced with message.update; markdown and card
+   * messages are cards, which Feishu edits with message.patch.
+   */
+  async updateMessage(chatId, messageId, message, config) {
+    const client = requireLarkClient(config)
+    const { msgType, content } = await feishuContent(client, message)
+    const response = msgType === 'interactive'
+      ? await client.im.message.patch({ path: { message_id: messageId }, data: { content } })
+      : await client.im.message.update({ path: { message_id: messageId }, data: { msg_type: msgType, content } })
+    if (response.code !== undefined && response.code !== 0) {
+      throw new Error(`Feishu API error: ${response.msg ?? `code ${response.code}`}`)
+    }
+    return { messageId, chatId }
+  },
+
+  async deleteMessage(_chatId, messageId, config) {
+    const client = requireLarkClient(config)
+    const response = await client.im.message.delete({ path: { message_id: messageId } })
+    if (response.code !== undefined && response.code !== 0) {
+      throw new Error(`Feishu API error: ${response.msg ?? `code ${response.code}`}`)
+    }
+  },
 }
@@ -0,0 +1,11 @@
+import type { WebhookHeaders } from './types.js'
+
+/**
+ * The value of a header sent exactly once. A repeated header is ambiguous,
+ * since the platform signed one value and a proxy or attacker added the
+ * other, so it reads as undefined, like a missing one.
+ */
+export function webhookHeader(headers: WebhookHeaders, name: string): string | undefined {
+  const values = headers[name]
+  return values?.length === 1 ? values[0] : undefined
+}
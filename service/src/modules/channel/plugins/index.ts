@@ -5,6 +5,8 @@ import { discordPlugin } from './discord.js'
 import { telegramPlugin } from './telegram.js'
 import { dingtalkPlugin } from './dingtalk.js'
 import { wecomPlugin } from './wecom.js'
+import { outgoingWebhookPlugin } from './outgoing-webhook.js'
+import { emailPlugin } from './email.js'
 
 registerPlugin(feishuPlugin)
 registerPlugin(slackPlugin)
@@ -12,6 +14,9 @@ registerPlugin(discordPlugin)
 registerPlugin(telegramPlugin)
 registerPlugin(dingtalkPlugin)
 registerPlugin(wecomPlugin)
+registerPlugin(outgoingWebhookPlugin)
+registerPlugin(emailPlugin)
 
 export { getPlugin, listPlugins, hasPlugin } from './registry.js'
-export type { ChannelPlugin, ParsedMessage, TestResult } from './types.js'
+export { outgoingWebhookEvents } from './outgoing-webhook.js'
+export type { ChannelPlugin, OutboundMessage, ParsedMessage, SentMessage, TestResult, WebhookHeaders } from './types.js'
@@ -0,0 +1,60 @@
+/**
+ * Outgoing webhook channel — no platform behind it. The gateway POSTs
+ * workspace events to the configured URL, signed with the channel secret,
+ * and keeps the delivery log. Config: url, secret, events (comma-separated
+ * event types, or "*" for all).
+ */
+import type { ChannelPlugin, TestResult } from './types.js'
+
+export const OUTGOING_WEBHOOK_EVENTS = ['agent.reply', 'task.execution', 'channel.message'] as const
+
+const MIN_SECRET_LENGTH = 16
+
+export function outgoingWebhookEvents(config: Record<string, string>): string[] {
+  return (config.events ?? '*')
+    .split(',')
+    .map((e) => e.trim())
+    .filter(Boolean)
+}
+
+function configError(config: Record<string, string>): string | null {
+  let url: URL
+  try {
+    url = new URL(config.url ?? '')
+  } catch {
+    return 'url must be an absolute http(s) URL'
+  }
+  if (url.protocol !== 'https:' && url.protocol !== 'http:') return 'url must be an absolute http(s) URL'
+  if ((config.secret ?? '').length < MIN_SECRET_LENGTH) {
+    return `secret must be at least ${MIN_SECRET_LENGTH} characters`
+  }
+  const events = outgoingWebhookEvents(config)
+  if (events.length === 0) return 'events must list at least one event type'
+  const unknown = events.filter((e) => e !== '*' && !(OUTGOING_WEBHOOK_EVENTS as readonly string[]).includes(e))
+  if (unknown.length > 0) {
+    return `unknown event types: ${unknown.join(', ')} (expected ${OUTGOING_WEBHOOK_EVENTS.join(', ')} or *)`
+  }
+  return null
+}
+
+export const outgoingWebhookPlugin: ChannelPlugin = {
+  type: 'outgoing_webhook',
+  label: 'Outgoing Webhook',
+
+  // Nothing is received on this channel.
+  verifyWebhook(): boolean {
+    return false
+  },
+
+  parseMessage() {
+    return null
+  },
+
+  validateConfig: configError,
+
+  /** Checks the config only; send a ping from the channel to test the endpoint. */
+  async testConnection(config): Promise<TestResult> {
+    const error = configError(config)
+    return error ? { success: false, error } : { success: true, botName: new URL(config.url).host }
+  },
+}
@@ -1,20 +1,21 @@
 import crypto from 'crypto'
+import { webhookHeader } from './headers.js'
 import type { ChannelPlugin, ParsedMessage, TestResult } from './types.js'
 
 export const slackPlugin: ChannelPlugin = {
   type: 'slack',
   label: 'Slack',
 
-  verifyWebhook(body, headers, config): boolean {
+  verifyWebhook(body, headers, config, receivedAt): boolean {
     const secret = config.signingSecret
     if (!secret) return true
 
-    const ts = headers['x-slack-request-timestamp']
-    const sig = headers['x-slack-signature']
+    const ts = webhookHeader(headers, 'x-slack-request-timestamp')
+    const sig = webhookHeader(headers, 'x-slack-signature')
     if (!ts || !sig) return false
 
     // Reject requests older than 5 minutes
-    if (Math.abs(Date.now() / 1000 - Number(ts)) > 300) return false
+    if (Math.abs((receivedAt ?? Date.now()) / 1000 - Number(ts)) > 300) return false
 
     const base = `v0:${ts}:${body}`
     const expected = 'v0=' + crypto.createHmac('sha256', secret).update(base).digest('hex')
@@ -31,6 +32,13 @@ export const slackPlugin: ChannelPlugin = {
     return null
   },
 
+  eventId(body): string | null {
+    try {
+      const parsed = JSON.parse(body) as Record<string, unknown>
+      return typeof parsed.event_id === 'string' ? parsed.event_id : null
+    } catch { return null }
+  },
+
   parseMessage(body): ParsedMessage | null {
     try {
       const parsed = JSON.parse(body) as Record<string, unknown>
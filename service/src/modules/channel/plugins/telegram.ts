@@ -1,4 +1,5 @@
 import crypto from 'crypto'
+import { webhookHeader } from './headers.js'
 import type { ChannelPlugin, ParsedMessage, TestResult } from './types.js'
 
 export const telegramPlugin: ChannelPlugin = {
@@ -10,7 +11,7 @@ export const telegramPlugin: ChannelPlugin = {
     const secretToken = config.secretToken
     if (!secretToken) return true // skip if not configured
 
-    const received = headers['x-telegram-bot-api-secret-token']
+    const received = webhookHeader(headers, 'x-telegram-bot-api-secret-token')
     if (!received) return false
 
     // Constant-time comparison
@@ -24,6 +25,13 @@ export const telegramPlugin: ChannelPlugin = {
     return null
   },
 
+  eventId(body): string | null {
+    try {
+      const parsed = JSON.parse(body) as Record<string, unknown>
+      return parsed.update_id !== undefined ? String(parsed.update_id) : null
+    } catch { return null }
+  },
+
   parseMessage(body): ParsedMessage | null {
     try {
       const parsed = JSON.parse(body) as Record<string, unknown>
@@ -6,6 +6,28 @@ export interface ParsedMessage {
   messageId?: string
 }
 
+/** A typed outbound message, with its attachment already loaded. */
+export interface OutboundMessage {
+  type: 'text' | 'markdown' | 'image' | 'file' | 'card'
+  /** Body for text/markdown, caption for image/file, fallback for card */
+  text: string
+  /** Parsed platform card payload, for card */
+  card?: unknown
+  /** The image or file, for image/file */
+  attachment?: { name: string; mimeType: string; data: Buffer }
+}
+
+export interface SentMessage {
+  messageId: string
+  chatId?: string
+}
+
+/**
+ * Webhook request headers keyed by lower-cased name, with every value the
+ * request carried, in order.
+ */
+export type WebhookHeaders = Record<string, string[]>
+
 export interface TestResult {
   success: boolean
   botName?: string
@@ -18,13 +40,26 @@ export interface ChannelPlugin {
   /** Human-readable display name */
   readonly label: string
 
-  /** Verify incoming webhook request authenticity */
+  /**
+   * Verify incoming webhook request authenticity.
+   * receivedAt: epoch ms the gateway received the request; timestamp
+   * freshness checks must use it rather than the current time, since
+   * queued events may be verified again minutes later.
+   * Read signature headers with webhookHeader, which refuses repeated ones.
+   */
   verifyWebhook(
     body: string,
-    headers: Record<string, string>,
+    headers: WebhookHeaders,
     config: Record<string, string>,
+    receivedAt?: number,
   ): boolean
 
+  /**
+   * Return the platform's unique ID for this delivery, so redeliveries
+   * can be dropped. Null when the body carries none.
+   */
+  eventId?(body: string): string | null
+
   /** Parse webhook body into a structured message */
   parseMessage(body: string): ParsedMessage | null
 
@@ -34,6 +69,12 @@ export interface ChannelPlugin {
    */
   handleChallenge?(body: string, config: Record<string, string>): string | null
 
+  /**
+   * Check a channel config before it is saved. Returns the problem, or
+   * null when the config is acceptable.
+   */
+  validateConfig?(config: Record<string, string>): string | null
+
   /** Test that the provided credentials can authenticate with the platform */
   testConnection(config: Record<string, string>): Promise<TestResult>
 
@@ -41,11 +82,37 @@ export interface ChannelPlugin {
    * Send a text reply back to the platform.
    * chatId: platform chat/conversation ID (from ParsedMessage.chatId)
    * threadId: optional, reply inside a thread (Feishu root_id)
+   * channelId: the sending channel, for plugins that keep state per channel
    */
   sendMessage?(
     chatId: string,
     text: string,
     config: Record<string, string>,
     threadId?: string,
+    channelId?: string,
+  ): Promise<void>
+
+  /** Send a typed message and return the platform message ID */
+  sendRichMessage?(
+    chatId: string,
+    message: OutboundMessage,
+    config: Record<string, string>,
+    threadId?: string,
+    channelId?: string,
+  ): Promise<SentMessage>
+
+  /** Replace the content of a message the bot sent */
+  updateMessage?(
+    chatId: string,
+    messageId: string,
+    message: OutboundMessage,
+    config: Record<string, string>,
+  ): Promise<SentMessage>
+
+  /** Recall a message the bot sent */
+  deleteMessage?(
+    chatId: string,
+    messageId: string,
+    config: Record<string, string>,
   ): Promise<void>
 }
@@ -12,6 +12,14 @@ export const wecomPlugin: ChannelPlugin = {
     return null
   },
 
+  eventId(body): string | null {
+    try {
+      const parsed = JSON.parse(body) as Record<string, unknown>
+      const id = parsed.msgId ?? parsed.messageId
+      return id ? String(id) : null
+    } catch { return null }
+  },
+
   parseMessage(body): ParsedMessage | null {
     try {
       const parsed = JSON.parse(body) as Record<string, unknown>
@@ -107,6 +107,12 @@ export function createSession(workspaceId: string, title: string) {
   return db.select().from(chatSessions).where(eq(chatSessions.id, id)).get()!;
 }
 
+export function getSession(sessionId: string) {
+  const session = db.select().from(chatSessions).where(eq(chatSessions.id, sessionId)).get();
+  if (!session) throw Object.assign(new Error("Session not found"), { code: "NOT_FOUND" });
+  return session;
+}
+
 export function updateSession(data: {
   sessionId: string;
   title?: string;
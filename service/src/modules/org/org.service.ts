@@ -1,4 +1,4 @@
-import { eq, inArray } from "drizzle-orm";
+import { and, desc, eq, inArray } from "drizzle-orm";
 import { v4 as uuidv4 } from "uuid";
 import { db } from "../../db/index.js";
 import {
@@ -11,6 +11,7 @@ import {
   channels,
   agentRuns,
   usageRecords,
+  auditLogs,
 } from "../../db/schema.js";
 
 function resolveOrgByRef(orgRef: string) {
@@ -39,6 +40,28 @@ export function listOrgs(userId: string) {
   );
 }
 
+/** The caller's orgs with their slug, role and each org's workspace IDs. */
+export function listMemberships(userId: string) {
+  const memberRows = db
+    .select({ orgId: orgMembers.orgId, role: orgMembers.role, slug: organizations.slug })
+    .from(orgMembers)
+    .innerJoin(organizations, eq(organizations.id, orgMembers.orgId))
+    .where(eq(orgMembers.userId, userId))
+    .all();
+  if (memberRows.length === 0) return [];
+  const workspaceRows = db
+    .select({ id: workspaces.id, orgId: workspaces.orgId })
+    .from(workspaces)
+    .where(inArray(workspaces.orgId, memberRows.map((r) => r.orgId)))
+    .all();
+  return memberRows.map((r) => ({
+    orgId: r.orgId,
+    slug: r.slug,
+    role: r.role,
+    workspaceIds: workspaceRows.filter((w) => w.orgId === r.orgId).map((w) => w.id),
+  }));
+}
+
 export function getOrg(orgRef: string) {
   return resolveOrgByRef(orgRef);
 }
@@ -201,3 +224,73 @@ export function createOrg(slug: string, name: string, userId: string) {
   db.insert(orgMembers).values({ id: uuidv4(), orgId: id, userId, role: "owner" }).run();
   return db.select().from(organizations).where(eq(organizations.id, id)).get()!;
 }
+
+// ─── Audit Log ───────────────────────────────────────────────────────────────
+
+export interface AuditEntryInput {
+  id: string;
+  orgId?: string;
+  workspaceId?: string;
+  userId?: string;
+  method: string;
+  route?: string;
+  path: string;
+  params?: Record<string, string>;
+  requestId?: string;
+  status?: number;
+  latencyMs?: number | string;
+  changesJson?: string;
+  createdAt?: string;
+}
+
+export function recordAuditEntries(entries: AuditEntryInput[]) {
+  if (entries.length === 0) return;
+  db.insert(auditLogs)
+    .values(entries.map((e) => ({
+      id: e.id || uuidv4(),
+      orgId: e.orgId ?? "",
+      workspaceId: e.workspaceId ?? "",
+      userId: e.userId ?? "",
+      method: e.method,
+      route: e.route ?? "",
+      path: e.path,
+      paramsJson: e.params && Object.keys(e.params).length > 0 ? JSON.stringify(e.params) : null,
+      requestId: e.requestId || null,
+      status: e.status ?? 0,
+      latencyMs: Number(e.latencyMs ?? 0),
+      changesJson: e.changesJson || null,
+      ...(e.createdAt && { createdAt: e.createdAt }),
+    })))
+    .onConflictDoNothing()
+    .run();
+}
+
+export function listAuditEntries(orgRef: string, filter: { workspaceId?: string; userId?: string; limit?: number }) {
+  const org = resolveOrgByRef(orgRef);
+  const limit = Math.min(Math.max(filter.limit || 50, 1), 500);
+  const conditions = [eq(auditLogs.orgId, org.id)];
+  if (filter.workspaceId) conditions.push(eq(auditLogs.workspaceId, filter.workspaceId));
+  if (filter.userId) conditions.push(eq(auditLogs.userId, filter.userId));
+  return db
+    .select()
+    .from(auditLogs)
+    .where(and(...conditions))
+    .orderBy(desc(auditLogs.createdAt))
+    .limit(limit)
+    .all()
+    .map((row) => ({
+      id: row.id,
+      orgId: row.orgId,
+      workspaceId: row.workspaceId,
+      userId: row.userId,
+      method: row.method,
+      route: row.route,
+      path: row.path,
+      params: row.paramsJson ? JSON.parse(row.paramsJson) : {},
+      requestId: row.requestId ?? "",
+      status: row.status,
+      latencyMs: row.latencyMs,
+      changesJson: row.changesJson ?? "",
+      createdAt: row.createdAt,
+    }));
+}
@@ -404,6 +404,52 @@ const BUILTIN_MARKETPLACE_RECORDS: MarketplaceSeedRecord[] = [
       updatedAt: "2026-03-01T00:00:00.000Z",
     },
   },
+  {
+    row: {
+      id: "outgoing_webhook",
+      name: "outgoing-webhook-channel",
+      type: "channel",
+      description: "Outgoing Webhook 事件推送渠道。",
+      author: "OpenClaw",
+      version: "builtin",
+      pricingModel: "free",
+      price: 0,
+      rating: 5,
+      iconUrl: "🔗",
+    },
+    metadata: {
+      displayName: "Outgoing Webhook 渠道插件",
+      longDescription: "安装后可创建 Outgoing Webhook 渠道：智能体回复、定时任务执行和渠道消息会以 HMAC-SHA256 签名的 POST 请求推送到配置的 URL，失败自动重试，并可查看投递记录与手动重发。",
+      tags: ["channel", "webhook"],
+      permissions: [],
+      screenshots: [],
+      publishedAt: "2026-10-18T00:00:00.000Z",
+      updatedAt: "2026-10-18T00:00:00.000Z",
+    },
+  },
+  {
+    row: {
+      id: "email",
+      name: "email-channel",
+      type: "channel",
+      description: "Email（IMAP/SMTP）邮件渠道。",
+      author: "OpenClaw",
+      version: "builtin",
+      pricingModel: "free",
+      price: 0,
+      rating: 5,
+      iconUrl: "✉️",
+    },
+    metadata: {
+      displayName: "Email 渠道插件",
+      longDescription: "安装后可创建 Email 渠道：网关定时轮询 IMAP 收件箱，解析邮件正文、附件与回复链后交给智能体处理；回复通过 SMTP 发送，并带上 In-Reply-To/References 头以保持在同一邮件会话中。",
+      tags: ["channel", "email"],
+      permissions: [],
+      screenshots: [],
+      publishedAt: "2026-10-18T00:00:00.000Z",
+      updatedAt: "2026-10-18T00:00:00.000Z",
+    },
+  },
 ];
 
 let builtinMarketplaceBootstrapPromise: Promise<void> | null = null;
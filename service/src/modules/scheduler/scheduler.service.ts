@@ -1,13 +1,70 @@
 import { CronJob } from "cron";
-import { eq } from "drizzle-orm";
+import { and, asc, desc, eq, gte, inArray, isNull, lt, ne, or, sql, type SQL } from "drizzle-orm";
 import { v4 as uuidv4 } from "uuid";
 import { db } from "../../db/index.js";
-import { scheduledTasks, taskExecutions, chatSessions } from "../../db/schema.js";
+import { scheduledTasks, taskExecutions, chatSessions, agentRuns, usageRecords } from "../../db/schema.js";
 import { config } from "../../config.js";
+import { onInboundChannelMessage, publishWorkspaceEvent } from "../channel/channel.service.js";
+import { onKnowledgeDocumentIndexed } from "../tools/tools.service.js";
+import {
+  channelMessageMatches,
+  forgetTriggerSlots,
+  isTriggerScheduleType,
+  newTriggerToken,
+  parseTriggerConfig,
+  takeTriggerSlot,
+  triggerTokenMatches,
+  triggeredInstruction,
+} from "./triggers.js";
 
 // In-memory registry of running cron jobs
 const runningJobs = new Map<string, CronJob>();
 
+// Runs in progress per task, for concurrency policies and backfill.
+interface ActiveRun {
+  abort: AbortController;
+  done: Promise<void>;
+  cancelReason: string;  // set once the run is being cancelled
+}
+const activeRuns = new Map<string, Map<string, ActiveRun>>();
+
+// Timers for retries of failed runs. Kept in memory only: retries still
+// waiting when the service restarts are not run.
+const pendingRetries = new Map<string, Set<NodeJS.Timeout>>();
+
+export const CONCURRENCY_POLICIES = ["allow", "forbid", "replace"];
+const MAX_RETRY_ATTEMPTS = 10;
+const DEFAULT_RETRY_BACKOFF_SECONDS = 60;
+const MAX_RETRY_BACKOFF_SECONDS = 3600;
+const MAX_RETRY_DELAY_MS = 24 * 60 * 60 * 1000;
+const MAX_BACKFILL_SLOTS = 100;
+
+interface RunPolicy {
+  concurrencyPolicy?: string;
+  retryMaxAttempts?: number;
+  retryBackoffSeconds?: number;
+}
+
+function checkRunPolicy(policy: RunPolicy) {
+  if (policy.concurrencyPolicy !== undefined && !CONCURRENCY_POLICIES.includes(policy.concurrencyPolicy)) {
+    throw invalidArgument(`concurrencyPolicy must be one of ${CONCURRENCY_POLICIES.join(", ")}`);
+  }
+  const n = policy.retryMaxAttempts;
+  if (n !== undefined && (!Number.isInteger(n) || n < 1 || n > MAX_RETRY_ATTEMPTS)) {
+    throw invalidArgument(`retryMaxAttempts must be between 1 and ${MAX_RETRY_ATTEMPTS}`);
+  }
+  const backoff = policy.retryBackoffSeconds;
+  if (backoff !== undefined && (!Number.isInteger(backoff) || backoff < 1 || backoff > MAX_RETRY_BACKOFF_SECONDS)) {
+    throw invalidArgument(`retryBackoffSeconds must be between 1 and ${MAX_RETRY_BACKOFF_SECONDS}`);
+  }
+}
+
+function requireTask(taskId: string) {
+  const task = db.select().from(scheduledTasks).where(eq(scheduledTasks.id, taskId)).get();
+  if (!task) throw Object.assign(new Error("Task not found"), { code: "NOT_FOUND" });
+  return task;
+}
+
 // ─── Task CRUD ────────────────────────────────────────────────────────────────
 
 export function listTasks(workspaceId: string) {
@@ -18,12 +75,17 @@ export function createTask(data: {
   workspaceId: string;
   name: string;
   instruction?: string;
-  scheduleType: "cron" | "once";
+  scheduleType: string;
   cronExpression?: string;
+  timezone?: string;
+  triggerJson?: string;
   runAt?: string;
   maxRuns?: number;
   targetAgentId?: string;
-}) {
+} & RunPolicy) {
+  checkRunPolicy(data);
+  const triggered = isTriggerScheduleType(data.scheduleType);
+  if (triggered) parseTriggerConfig(data.scheduleType, data.triggerJson);
   const id = uuidv4();
   db.insert(scheduledTasks).values({
     id,
@@ -32,9 +94,15 @@ export function createTask(data: {
     instruction: data.instruction ?? null,
     scheduleType: data.scheduleType,
     cronExpression: data.cronExpression ?? null,
+    timezone: data.timezone || null,
+    triggerJson: triggered ? data.triggerJson || null : null,
+    triggerToken: data.scheduleType === "webhook" ? newTriggerToken() : null,
     runAt: data.runAt ?? null,
     maxRuns: data.maxRuns ?? null,
     targetAgentId: data.targetAgentId ?? null,
+    concurrencyPolicy: data.concurrencyPolicy ?? "allow",
+    retryMaxAttempts: data.retryMaxAttempts ?? 1,
+    retryBackoffSeconds: data.retryBackoffSeconds ?? DEFAULT_RETRY_BACKOFF_SECONDS,
   }).run();
 
   const task = db.select().from(scheduledTasks).where(eq(scheduledTasks.id, id)).get()!;
@@ -47,13 +115,20 @@ export function updateTask(taskId: string, data: {
   instruction?: string;
   scheduleType?: string;
   cronExpression?: string;
+  timezone?: string;
+  triggerJson?: string;
   runAt?: string;
   maxRuns?: number;
   targetAgentId?: string;
   status?: string;
-}) {
+} & RunPolicy) {
   const task = db.select().from(scheduledTasks).where(eq(scheduledTasks.id, taskId)).get();
   if (!task) throw Object.assign(new Error("Task not found"), { code: "NOT_FOUND" });
+  checkRunPolicy(data);
+  const scheduleType = data.scheduleType || task.scheduleType;
+  const triggered = isTriggerScheduleType(scheduleType);
+  const triggerJson = data.triggerJson !== undefined ? data.triggerJson : task.triggerJson;
+  if (triggered) parseTriggerConfig(scheduleType, triggerJson);
 
   // Stop existing job before rescheduling
   stopJob(taskId);
@@ -63,45 +138,340 @@ export function updateTask(taskId: string, data: {
     ...(data.instruction !== undefined && { instruction: data.instruction }),
     ...(data.scheduleType && { scheduleType: data.scheduleType }),
     ...(data.cronExpression !== undefined && { cronExpression: data.cronExpression }),
+    ...(data.timezone !== undefined && { timezone: data.timezone || null }),
+    triggerJson: triggered ? triggerJson || null : null,
+    triggerToken: scheduleType !== "webhook" ? null : task.triggerToken || newTriggerToken(),
     ...(data.runAt !== undefined && { runAt: data.runAt }),
     ...(data.maxRuns !== undefined && { maxRuns: data.maxRuns }),
     ...(data.targetAgentId !== undefined && { targetAgentId: data.targetAgentId }),
-    ...(data.status && { status: data.status }),
+    ...(data.status && {
+      status: data.status,
+      pausedAt: data.status === "paused" ? task.pausedAt ?? new Date().toISOString() : null,
+    }),
+    ...(data.concurrencyPolicy !== undefined && { concurrencyPolicy: data.concurrencyPolicy }),
+    ...(data.retryMaxAttempts !== undefined && { retryMaxAttempts: data.retryMaxAttempts }),
+    ...(data.retryBackoffSeconds !== undefined && { retryBackoffSeconds: data.retryBackoffSeconds }),
   }).where(eq(scheduledTasks.id, taskId)).run();
 
   const updated = db.select().from(scheduledTasks).where(eq(scheduledTasks.id, taskId)).get()!;
   if (updated.status === "active") scheduleTask(updated);
+  else clearPendingRetries(taskId);
   return updated;
 }
 
+export function getTask(taskId: string) {
+  return requireTask(taskId);
+}
+
+/**
+ * Stop a task's schedule and triggers until it is resumed. Runs already in
+ * progress finish; retries not yet started are dropped.
+ */
+export function pauseTask(taskId: string) {
+  const task = requireTask(taskId);
+  if (task.status === "completed") {
+    throw Object.assign(new Error("Completed tasks cannot be paused"), { code: "FAILED_PRECONDITION" });
+  }
+  if (task.status !== "paused") {
+    stopJob(taskId);
+    clearPendingRetries(taskId);
+    db.update(scheduledTasks)
+      .set({ status: "paused", pausedAt: new Date().toISOString() })
+      .where(eq(scheduledTasks.id, taskId))
+      .run();
+  }
+  return requireTask(taskId);
+}
+
+/** Reactivate a paused task. Slots that passed while paused are not run; backfill them if needed. */
+export function resumeTask(taskId: string) {
+  const task = requireTask(taskId);
+  if (task.status === "completed") {
+    throw Object.assign(new Error("Completed tasks cannot be resumed"), { code: "FAILED_PRECONDITION" });
+  }
+  if (task.status !== "active") {
+    db.update(scheduledTasks).set({ status: "active", pausedAt: null }).where(eq(scheduledTasks.id, taskId)).run();
+    scheduleTask(requireTask(taskId));
+  }
+  return requireTask(taskId);
+}
+
 export function deleteTask(taskId: string) {
   const task = db.select().from(scheduledTasks).where(eq(scheduledTasks.id, taskId)).get();
   if (!task) throw Object.assign(new Error("Task not found"), { code: "NOT_FOUND" });
   stopJob(taskId);
+  clearPendingRetries(taskId);
+  forgetTriggerSlots(taskId);
   db.delete(scheduledTasks).where(eq(scheduledTasks.id, taskId)).run();
 }
 
+/** Replace a webhook task's trigger token; the old one stops working at once. */
+export function rotateTriggerToken(taskId: string) {
+  const task = db.select().from(scheduledTasks).where(eq(scheduledTasks.id, taskId)).get();
+  if (!task) throw Object.assign(new Error("Task not found"), { code: "NOT_FOUND" });
+  if (task.scheduleType !== "webhook") {
+    throw Object.assign(new Error("Only webhook tasks have a trigger token"), { code: "FAILED_PRECONDITION" });
+  }
+  db.update(scheduledTasks).set({ triggerToken: newTriggerToken() }).where(eq(scheduledTasks.id, taskId)).run();
+  return db.select().from(scheduledTasks).where(eq(scheduledTasks.id, taskId)).get()!;
+}
+
 // ─── Executions ───────────────────────────────────────────────────────────────
 
-export function listExecutions(taskId: string, limit = 20) {
-  return db
+const MAX_EXECUTION_PAGE = 200;
+const EXECUTION_STATUSES = ["queued", "running", "success", "failed", "cancelled"];
+
+type ExecutionRow = typeof taskExecutions.$inferSelect;
+
+export interface ExecutionFilter {
+  limit?: number;
+  cursor?: string;
+  status?: string;
+  from?: string;
+  to?: string;
+}
+
+function invalidArgument(message: string): Error {
+  return Object.assign(new Error(message), { code: "INVALID_ARGUMENT" });
+}
+
+function encodeExecutionCursor(row: ExecutionRow): string {
+  return Buffer.from(JSON.stringify({ s: row.startedAt ?? "", i: row.id })).toString("base64url");
+}
+
+function decodeExecutionCursor(cursor: string): { startedAt: string; id: string } {
+  try {
+    const parsed = JSON.parse(Buffer.from(cursor, "base64url").toString("utf8")) as { s?: unknown; i?: unknown };
+    if (typeof parsed.s === "string" && typeof parsed.i === "string") return { startedAt: parsed.s, id: parsed.i };
+  } catch { /* fall through */ }
+  throw invalidArgument("Invalid cursor");
+}
+
+function isoTimestamp(value: string, field: string): string {
+  const date = new Date(value);
+  if (Number.isNaN(date.getTime())) throw invalidArgument(`${field} must be an ISO-8601 timestamp`);
+  return date.toISOString();
+}
+
+/** The run behind an execution; while it is in progress, found through its session. */
+function executionRun(row: ExecutionRow) {
+  if (row.runId) return db.select().from(agentRuns).where(eq(agentRuns.id, row.runId)).get() ?? null;
+  if (!row.sessionId) return null;
+  return db.select().from(agentRuns)
+    .where(eq(agentRuns.sessionId, row.sessionId))
+    .orderBy(desc(agentRuns.createdAt))
+    .get() ?? null;
+}
+
+function withRunId(row: ExecutionRow): ExecutionRow {
+  if (row.runId || row.status !== "running") return row;
+  return { ...row, runId: executionRun(row)?.id ?? null };
+}
+
+/**
+ * Page through a task's executions, newest first. Pages are keyed on
+ * (started_at, id); pass nextCursor back to get the following page.
+ */
+export function listExecutions(taskId: string, filter: ExecutionFilter = {}) {
+  const limit = Math.min(Math.max(filter.limit || 20, 1), MAX_EXECUTION_PAGE);
+  const conditions: SQL[] = [eq(taskExecutions.taskId, taskId)];
+  if (filter.status) {
+    if (!EXECUTION_STATUSES.includes(filter.status)) {
+      throw invalidArgument(`status must be one of ${EXECUTION_STATUSES.join(", ")}`);
+    }
+    conditions.push(eq(taskExecutions.status, filter.status));
+  }
+  if (filter.from) conditions.push(gte(taskExecutions.startedAt, isoTimestamp(filter.from, "from")));
+  if (filter.to) conditions.push(lt(taskExecutions.startedAt, isoTimestamp(filter.to, "to")));
+  if (filter.cursor) {
+    const cursor = decodeExecutionCursor(filter.cursor);
+    conditions.push(or(
+      lt(taskExecutions.startedAt, cursor.startedAt),
+      and(eq(taskExecutions.startedAt, cursor.startedAt), lt(taskExecutions.id, cursor.id)),
+    )!);
+  }
+
+  const rows = db
     .select()
     .from(taskExecutions)
-    .where(eq(taskExecutions.taskId, taskId))
-    .all()
-    .slice(-limit);
+    .where(and(...conditions))
+    .orderBy(desc(taskExecutions.startedAt), desc(taskExecutions.id))
+    .limit(limit + 1)
+    .all();
+
+  const executions = rows.slice(0, limit);
+  const last = executions[executions.length - 1];
+  return {
+    executions: executions.map(withRunId),
+    nextCursor: rows.length > limit && last ? encodeExecutionCursor(last) : "",
+  };
+}
+
+/** One execution with the run it started and that run's usage records. */
+export function getExecution(taskId: string, executionId: string) {
+  const row = db.select().from(taskExecutions)
+    .where(and(eq(taskExecutions.id, executionId), eq(taskExecutions.taskId, taskId)))
+    .get();
+  if (!row) throw Object.assign(new Error("Execution not found"), { code: "NOT_FOUND" });
+
+  const run = executionRun(row);
+  const usage = run
+    ? db.select().from(usageRecords)
+      .where(eq(usageRecords.runId, run.id))
+      .orderBy(asc(usageRecords.recordedAt), asc(usageRecords.id))
+      .all()
+    : [];
+  return {
+    execution: { ...row, runId: run?.id ?? row.runId },
+    run: run && {
+      id: run.id,
+      sessionId: run.sessionId,
+      status: run.status,
+      startedAt: run.startedAt,
+      endedAt: run.endedAt,
+      totalInputTokens: run.totalInputTokens,
+      totalOutputTokens: run.totalOutputTokens,
+      totalTokens: run.totalTokens,
+    },
+    usageRecords: usage.map((u) => ({
+      id: u.id,
+      recordType: u.recordType,
+      scope: u.scope,
+      status: u.status,
+      agentId: u.agentId,
+      agentName: u.agentName,
+      providerName: u.providerName,
+      modelName: u.modelName,
+      inputTokens: u.inputTokens,
+      outputTokens: u.outputTokens,
+      totalTokens: u.totalTokens,
+      recordedAt: u.recordedAt,
+    })),
+  };
 }
 
 export async function runTask(taskId: string): Promise<typeof taskExecutions.$inferSelect> {
   const task = db.select().from(scheduledTasks).where(eq(scheduledTasks.id, taskId)).get();
   if (!task) throw Object.assign(new Error("Task not found"), { code: "NOT_FOUND" });
+  if (!admitRun(task)) {
+    throw Object.assign(new Error("A run of this task is still in progress"), { code: "FAILED_PRECONDITION" });
+  }
   return executeTask(task);
 }
 
+/**
+ * Queue runs for the given cron slots that have no execution yet. The runs
+ * go oldest first, one at a time, each waiting for other runs of the task
+ * to finish. Returns the queued executions and how many slots were skipped.
+ */
+export function backfillTask(taskId: string, slots: string[]) {
+  const task = requireTask(taskId);
+  if (task.scheduleType !== "cron") {
+    throw Object.assign(new Error("Only cron tasks can be backfilled"), { code: "FAILED_PRECONDITION" });
+  }
+  if (task.status !== "active") {
+    throw Object.assign(new Error("Resume the task before backfilling it"), { code: "FAILED_PRECONDITION" });
+  }
+  if (slots.length === 0) throw invalidArgument("slots is required");
+  if (slots.length > MAX_BACKFILL_SLOTS) throw invalidArgument(`At most ${MAX_BACKFILL_SLOTS} slots can be backfilled at once`);
+
+  const wanted = [...new Set(slots.map((slot) => isoTimestamp(slot, "slots")))].sort();
+  const now = new Date().toISOString();
+  if (wanted[wanted.length - 1] > now) throw invalidArgument("slots must be in the past");
+  const covered = coveredSlots(taskId, wanted);
+  const missed = wanted.filter((slot) => !covered.has(slot));
+  if (task.maxRuns && task.runCount + missed.length > task.maxRuns) {
+    throw Object.assign(
+      new Error(`Backfilling ${missed.length} runs would exceed max runs (${task.runCount} of ${task.maxRuns} used)`),
+      { code: "FAILED_PRECONDITION" },
+    );
+  }
+
+  const execIds = missed.map((scheduledFor) => {
+    const id = uuidv4();
+    db.insert(taskExecutions).values({ id, taskId, status: "queued", startedAt: now, scheduledFor }).run();
+    return id;
+  });
+  void runBackfill(taskId, execIds);
+  return {
+    executions: execIds.length
+      ? db.select().from(taskExecutions).where(inArray(taskExecutions.id, execIds)).orderBy(asc(taskExecutions.scheduledFor)).all()
+      : [],
+    skipped: wanted.length - missed.length,
+  };
+}
+
+/**
+ * The slots that already have an execution other than a cancelled one.
+ * Executions from before slots were recorded count for a slot when they
+ * started within a minute of it.
+ */
+function coveredSlots(taskId: string, slots: string[]): Set<string> {
+  const last = new Date(Date.parse(slots[slots.length - 1]) + 60_000).toISOString();
+  const rows = db.select({ scheduledFor: taskExecutions.scheduledFor, startedAt: taskExecutions.startedAt })
+    .from(taskExecutions)
+    .where(and(
+      eq(taskExecutions.taskId, taskId),
+      ne(taskExecutions.status, "cancelled"),
+      or(
+        inArray(taskExecutions.scheduledFor, slots),
+        and(isNull(taskExecutions.scheduledFor), gte(taskExecutions.startedAt, slots[0]), lt(taskExecutions.startedAt, last)),
+      ),
+    ))
+    .all();
+
+  const covered = new Set<string>();
+  for (const row of rows) {
+    if (row.scheduledFor) {
+      covered.add(row.scheduledFor);
+      continue;
+    }
+    const started = Date.parse(row.startedAt ?? "");
+    for (const slot of slots) {
+      const at = Date.parse(slot);
+      if (started >= at && started < at + 60_000) covered.add(slot);
+    }
+  }
+  return covered;
+}
+
+async function runBackfill(taskId: string, execIds: string[]) {
+  for (const [i, execId] of execIds.entries()) {
+    await waitForIdle(taskId);
+    const task = db.select().from(scheduledTasks).where(eq(scheduledTasks.id, taskId)).get();
+    if (!task || task.status !== "active") {
+      db.update(taskExecutions).set({
+        status: "cancelled",
+        endedAt: new Date().toISOString(),
+        result: JSON.stringify({ error: "Task is no longer active" }),
+      }).where(inArray(taskExecutions.id, execIds.slice(i))).run();
+      return;
+    }
+    db.update(taskExecutions)
+      .set({ status: "running", startedAt: new Date().toISOString() })
+      .where(eq(taskExecutions.id, execId))
+      .run();
+    countRun(taskId);
+    await runExecution(task, execId, task.instruction ?? "");
+  }
+  completeIfMaxRuns(taskId);
+}
+
 // ─── Cron Engine ──────────────────────────────────────────────────────────────
 
+// The gateway accepts these aliases; the cron package only knows the names
+// they stand for.
+const CRON_PRESET_ALIASES: Record<string, string> = { "@annually": "@yearly", "@midnight": "@daily" };
+
+function cronPattern(expression: string): string {
+  const trimmed = expression.trim();
+  return CRON_PRESET_ALIASES[trimmed] ?? trimmed;
+}
+
 function scheduleTask(task: typeof scheduledTasks.$inferSelect) {
   if (task.status !== "active") return;
+  // Event-triggered tasks have no timer; see the Triggers section.
+  if (isTriggerScheduleType(task.scheduleType)) return;
 
   if (task.scheduleType === "once") {
     if (!task.runAt) return;
@@ -109,8 +479,11 @@ function scheduleTask(task: typeof scheduledTasks.$inferSelect) {
     if (runDate <= new Date()) return; // already past
 
     const job = new CronJob(runDate, async () => {
-      await executeTask(task);
       job.stop();
+      const current = db.select().from(scheduledTasks).where(eq(scheduledTasks.id, task.id)).get();
+      if (current?.status === "active" && admitRun(current)) {
+        await executeTask(current, runDate.toISOString());
+      }
       runningJobs.delete(task.id);
     });
     job.start();
@@ -121,25 +494,43 @@ function scheduleTask(task: typeof scheduledTasks.$inferSelect) {
   // cron type
   if (!task.cronExpression) return;
 
-  const job = new CronJob(task.cronExpression, async () => {
+  const job = new CronJob(cronPattern(task.cronExpression), async () => {
     const current = db.select().from(scheduledTasks).where(eq(scheduledTasks.id, task.id)).get();
     if (!current || current.status !== "active") { job.stop(); runningJobs.delete(task.id); return; }
+    if (!admitRun(current)) {
+      console.log(`[Scheduler] Task "${current.name}" skipped a run: the previous run is still in progress`);
+      return;
+    }
 
-    await executeTask(current);
+    // Fires land within a second of their slot.
+    await executeTask(current, new Date(Math.round(Date.now() / 1000) * 1000).toISOString());
 
-    // Check max runs
-    const updated = db.select().from(scheduledTasks).where(eq(scheduledTasks.id, task.id)).get()!;
-    if (updated.maxRuns && updated.runCount >= updated.maxRuns) {
-      db.update(scheduledTasks).set({ status: "completed" }).where(eq(scheduledTasks.id, task.id)).run();
+    if (completeIfMaxRuns(task.id)) {
       job.stop();
       runningJobs.delete(task.id);
     }
-  });
+  }, null, false, task.timezone ?? undefined);
   job.start();
   runningJobs.set(task.id, job);
 }
 
-async function executeTask(task: typeof scheduledTasks.$inferSelect) {
+/** Mark a task completed once it has used up max_runs; true if it was. */
+function completeIfMaxRuns(taskId: string): boolean {
+  const task = db.select().from(scheduledTasks).where(eq(scheduledTasks.id, taskId)).get();
+  if (!task?.maxRuns || task.runCount < task.maxRuns) return false;
+  db.update(scheduledTasks).set({ status: "completed" }).where(eq(scheduledTasks.id, taskId)).run();
+  return true;
+}
+
+function executeTask(task: typeof scheduledTasks.$inferSelect, scheduledFor?: string) {
+  return runExecution(task, beginExecution(task, { scheduledFor }), task.instruction ?? "");
+}
+
+/** Record a running execution; first attempts count against the task's runs. */
+function beginExecution(
+  task: typeof scheduledTasks.$inferSelect,
+  { attempt = 1, scheduledFor }: { attempt?: number; scheduledFor?: string | null } = {},
+): string {
   const execId = uuidv4();
   const startedAt = new Date().toISOString();
 
@@ -148,16 +539,107 @@ async function executeTask(task: typeof scheduledTasks.$inferSelect) {
     taskId: task.id,
     status: "running",
     startedAt,
+    attempt,
+    scheduledFor: scheduledFor ?? null,
   }).run();
 
-  // Increment run count
+  if (attempt === 1) countRun(task.id);
+  return execId;
+}
+
+function countRun(taskId: string) {
   db.update(scheduledTasks)
-    .set({ runCount: (task.runCount ?? 0) + 1 })
-    .where(eq(scheduledTasks.id, task.id))
+    .set({ runCount: sql`${scheduledTasks.runCount} + 1` })
+    .where(eq(scheduledTasks.id, taskId))
     .run();
+}
+
+// ─── Concurrency and retries ─────────────────────────────────────────────────
+
+/**
+ * Apply the task's concurrency policy before starting a run. False means
+ * the run must not start: "forbid" with a run in progress. Under "replace"
+ * the runs in progress are cancelled.
+ */
+function admitRun(task: typeof scheduledTasks.$inferSelect): boolean {
+  const running = activeRuns.get(task.id);
+  if (!running?.size || task.concurrencyPolicy === "allow") return true;
+  if (task.concurrencyPolicy === "forbid") return false;
+  for (const execId of running.keys()) void cancelExecution(task.id, execId, "Replaced by a newer run");
+  return true;
+}
+
+/** Cancel a run in progress: in the runtime if it got there, then locally. */
+async function cancelExecution(taskId: string, execId: string, reason: string) {
+  const active = activeRuns.get(taskId)?.get(execId);
+  if (!active || active.cancelReason) return;
+  active.cancelReason = reason;
+
+  const row = db.select().from(taskExecutions).where(eq(taskExecutions.id, execId)).get();
+  const run = row && executionRun(row);
+  if (run) {
+    try {
+      await fetch(`${config.runtimeAddr}/runtime/runs/${encodeURIComponent(run.id)}/cancel`, {
+        method: "POST",
+        headers: { "X-Runtime-Secret": config.runtimeSecret },
+        signal: AbortSignal.timeout(10_000),
+      });
+    } catch (err) {
+      console.warn(`[Scheduler] Could not cancel run ${run.id}: ${err instanceof Error ? err.message : err}`);
+    }
+  }
+  active.abort.abort();
+}
+
+async function waitForIdle(taskId: string) {
+  for (let running = activeRuns.get(taskId); running?.size; running = activeRuns.get(taskId)) {
+    await Promise.allSettled([...running.values()].map((r) => r.done));
+  }
+}
+
+/** Start the next attempt of a failed run after its backoff, per the task's retry policy. */
+function scheduleRetry(task: typeof scheduledTasks.$inferSelect, failed: ExecutionRow, instruction: string) {
+  if (failed.status !== "failed" || failed.attempt >= task.retryMaxAttempts) return;
+  const delay = Math.min(task.retryBackoffSeconds * 1000 * 2 ** (failed.attempt - 1), MAX_RETRY_DELAY_MS);
+
+  const timer = setTimeout(() => {
+    pendingRetries.get(task.id)?.delete(timer);
+    const current = db.select().from(scheduledTasks).where(eq(scheduledTasks.id, task.id)).get();
+    if (!current || current.status !== "active") return;
+    if (!admitRun(current)) {
+      console.log(`[Scheduler] Task "${current.name}" dropped a retry: another run is in progress`);
+      return;
+    }
+    const execId = beginExecution(current, { attempt: failed.attempt + 1, scheduledFor: failed.scheduledFor });
+    void runExecution(current, execId, instruction);
+  }, delay);
+  timer.unref();
+
+  const timers = pendingRetries.get(task.id) ?? new Set<NodeJS.Timeout>();
+  timers.add(timer);
+  pendingRetries.set(task.id, timers);
+}
+
+function clearPendingRetries(taskId: string) {
+  for (const timer of pendingRetries.get(taskId) ?? []) clearTimeout(timer);
+  pendingRetries.delete(taskId);
+}
+
+// ─── Execution ───────────────────────────────────────────────────────────────
+
+async function runExecution(task: typeof scheduledTasks.$inferSelect, execId: string, instruction: string) {
+  let finish!: () => void;
+  const active: ActiveRun = {
+    abort: new AbortController(),
+    done: new Promise<void>((resolve) => { finish = resolve; }),
+    cancelReason: "",
+  };
+  const running = activeRuns.get(task.id) ?? new Map<string, ActiveRun>();
+  running.set(execId, active);
+  activeRuns.set(task.id, running);
 
   try {
-    if (!task.targetAgentId || !task.instruction) {
+    if (!task.targetAgentId || !instruction) {
       throw new Error("targetAgentId and instruction are required for execution");
     }
 
@@ -172,6 +654,7 @@ async function executeTask(task: typeof scheduledTasks.$inferSelect) {
         messageCount: 0,
       })
       .run();
+    db.update(taskExecutions).set({ sessionId }).where(eq(taskExecutions.id, execId)).run();
 
     // Dispatch to runtime via HTTP — blocks until agent run completes
     const response = await fetch(`${config.runtimeAddr}/runtime/scheduled-run`, {
@@ -184,10 +667,11 @@ async function executeTask(task: typeof scheduledTasks.$inferSelect) {
         workspaceId: task.workspaceId,
         sessionId,
         agentId: task.targetAgentId,
-        instruction: task.instruction,
+        instruction,
         executionId: execId,
       }),
-      signal: AbortSignal.timeout(660_000), // 11 min (slightly above scheduled lane 600s timeout)
+      // 11 min (slightly above scheduled lane 600s timeout)
+      signal: AbortSignal.any([active.abort.signal, AbortSignal.timeout(660_000)]),
     });
 
     if (!response.ok) {
@@ -202,8 +686,10 @@ async function executeTask(task: typeof scheduledTasks.$inferSelect) {
 
     const endedAt = new Date().toISOString();
     db.update(taskExecutions).set({
-      status: result.data.status === "completed" ? "success" : "failed",
+      status: result.data.status === "completed" ? "success"
+        : active.cancelReason || result.data.status === "cancelled" ? "cancelled" : "failed",
       endedAt,
+      runId: result.data.runId,
       result: JSON.stringify({
         runId: result.data.runId,
         sessionId,
@@ -213,17 +699,131 @@ async function executeTask(task: typeof scheduledTasks.$inferSelect) {
     }).where(eq(taskExecutions.id, execId)).run();
 
   } catch (err: any) {
-    console.error(`[Scheduler] Task "${task.name}" execution failed:`, err.message);
+    if (!active.cancelReason) console.error(`[Scheduler] Task "${task.name}" execution failed:`, err.message);
     db.update(taskExecutions).set({
-      status: "failed",
+      status: active.cancelReason ? "cancelled" : "failed",
       endedAt: new Date().toISOString(),
-      result: JSON.stringify({ error: err.message }),
+      result: JSON.stringify({ error: active.cancelReason || err.message }),
     }).where(eq(taskExecutions.id, execId)).run();
+  } finally {
+    running.delete(execId);
+    if (running.size === 0 && activeRuns.get(task.id) === running) activeRuns.delete(task.id);
+    finish();
   }
 
+  const execution = db.select().from(taskExecutions).where(eq(taskExecutions.id, execId)).get()!;
+  publishWorkspaceEvent(task.workspaceId, "task.execution", {
+    taskId: task.id,
+    taskName: task.name,
+    executionId: execution.id,
+    status: execution.status,
+    startedAt: execution.startedAt,
+    endedAt: execution.endedAt,
+    result: execution.result ? JSON.parse(execution.result) : null,
+  });
+  scheduleRetry(task, execution, instruction);
+  return execution;
+}
+
+// ─── Triggers ────────────────────────────────────────────────────────────────
+
+/**
+ * Start a run of an event-triggered task with the event as input. The run
+ * continues in the background; the returned execution is still running.
+ */
+function fireTriggeredTask(task: typeof scheduledTasks.$inferSelect, source: string, payload: string) {
+  if (task.status !== "active") {
+    throw Object.assign(new Error("Task is not active"), { code: "FAILED_PRECONDITION" });
+  }
+  if (task.maxRuns && task.runCount >= task.maxRuns) {
+    throw Object.assign(new Error("Task has reached its maximum runs"), { code: "FAILED_PRECONDITION" });
+  }
+  const trigger = parseTriggerConfig(task.scheduleType, task.triggerJson);
+  if (!takeTriggerSlot(task.id, trigger.rateLimitPerMinute)) {
+    throw Object.assign(
+      new Error(`Trigger rate limit of ${trigger.rateLimitPerMinute} runs per minute exceeded`),
+      { code: "RESOURCE_EXHAUSTED" },
+    );
+  }
+  if (!admitRun(task)) {
+    throw Object.assign(new Error("A run of this task is still in progress"), { code: "FAILED_PRECONDITION" });
+  }
+  const execId = beginExecution(task);
+  void runExecution(task, execId, triggeredInstruction(task.instruction ?? "", source, payload))
+    .then(() => completeIfMaxRuns(task.id));
   return db.select().from(taskExecutions).where(eq(taskExecutions.id, execId)).get()!;
 }
 
+/** An inbound call to a webhook task's trigger URL. */
+export function fireWebhookTrigger(taskId: string, token: string, payload: string, contentType: string) {
+  const task = db.select().from(scheduledTasks).where(eq(scheduledTasks.id, taskId)).get();
+  // Unknown tasks and wrong tokens look the same to the caller.
+  if (!task || task.scheduleType !== "webhook" || !triggerTokenMatches(task.triggerToken, token)) {
+    throw Object.assign(new Error("Invalid trigger token"), { code: "UNAUTHENTICATED" });
+  }
+  return fireTriggeredTask(task, `webhook (${contentType || "unknown content type"})`, payload);
+}
+
+/** Fire each matching task of one event type, logging what cannot run. */
+function fireEventTriggers(
+  workspaceId: string,
+  scheduleType: string,
+  matches: (trigger: ReturnType<typeof parseTriggerConfig>) => boolean,
+  source: string,
+  payload: Record<string, unknown>,
+) {
+  const tasks = db.select().from(scheduledTasks).where(and(
+    eq(scheduledTasks.workspaceId, workspaceId),
+    eq(scheduledTasks.scheduleType, scheduleType),
+    eq(scheduledTasks.status, "active"),
+  )).all();
+  for (const task of tasks) {
+    try {
+      if (!matches(parseTriggerConfig(task.scheduleType, task.triggerJson))) continue;
+      fireTriggeredTask(task, source, JSON.stringify(payload, null, 2));
+    } catch (err) {
+      console.warn(`[Scheduler] Trigger for task "${task.name}" skipped: ${err instanceof Error ? err.message : err}`);
+    }
+  }
+}
+
+let triggerListenersRegistered = false;
+
+function registerTriggerListeners() {
+  if (triggerListenersRegistered) return;
+  triggerListenersRegistered = true;
+
+  onInboundChannelMessage((channel, parsed) => {
+    fireEventTriggers(
+      channel.workspaceId,
+      "channel_message",
+      (trigger) => (!trigger.channelId || trigger.channelId === channel.id)
+        && channelMessageMatches(trigger, parsed.content),
+      `channel message (${channel.name})`,
+      {
+        channelId: channel.id,
+        channelName: channel.name,
+        channelType: channel.type,
+        sender: parsed.sender,
+        chatId: parsed.chatId ?? "",
+        threadId: parsed.threadId ?? "",
+        messageId: parsed.messageId ?? "",
+        content: parsed.content,
+      },
+    );
+  });
+
+  onKnowledgeDocumentIndexed((doc) => {
+    fireEventTriggers(
+      doc.workspaceId,
+      "kb_document",
+      (trigger) => !trigger.knowledgeBaseId || trigger.knowledgeBaseId === doc.knowledgeBaseId,
+      `knowledge base document added (${doc.knowledgeBaseName})`,
+      { ...doc },
+    );
+  });
+}
+
 function stopJob(taskId: string) {
   const job = runningJobs.get(taskId);
   if (job) { job.stop(); runningJobs.delete(taskId); }
@@ -233,6 +833,13 @@ function stopJob(taskId: string) {
 // Call on server startup to restore active tasks from DB
 
 export function bootstrapScheduler() {
+  // Backfill queues do not survive a restart.
+  db.update(taskExecutions).set({
+    status: "cancelled",
+    endedAt: new Date().toISOString(),
+    result: JSON.stringify({ error: "Service restarted before the backfill reached this slot" }),
+  }).where(eq(taskExecutions.status, "queued")).run();
+
   const activeTasks = db
     .select()
     .from(scheduledTasks)
@@ -242,5 +849,6 @@ export function bootstrapScheduler() {
   for (const task of activeTasks) {
     scheduleTask(task);
   }
+  registerTriggerListeners();
   console.log(`[Scheduler] Restored ${activeTasks.length} active tasks`);
 }
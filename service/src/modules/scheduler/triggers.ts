@@ -0,0 +1,132 @@
+import crypto from "crypto";
+import { ROUTING_OPERATORS, evaluateRule } from "../channel/channel.service.js";
+
+// Event-triggered tasks run when something happens rather than on a clock:
+//   webhook          — a POST to the gateway's /triggers/{taskId} with the task's token
+//   channel_message  — an inbound channel message matching the trigger's matchers
+//   kb_document      — a knowledge base document finishing indexing
+// The event payload is appended to the task instruction as the run's input.
+
+export const TRIGGER_SCHEDULE_TYPES = ["webhook", "channel_message", "kb_document"];
+
+const DEFAULT_RATE_LIMIT_PER_MINUTE = 6;
+const MAX_RATE_LIMIT_PER_MINUTE = 60;
+const MAX_TRIGGER_PAYLOAD_CHARS = 16_000;
+
+export interface TriggerMatcher {
+  operator: string;
+  value: string;
+}
+
+export interface TriggerConfig {
+  /** Runs allowed per task per minute; further events are dropped. */
+  rateLimitPerMinute: number;
+  /** channel_message: only this channel; empty = any channel in the workspace. */
+  channelId: string;
+  /** channel_message: fires when any matcher matches; none = every message. */
+  matchers: TriggerMatcher[];
+  /** kb_document: only this knowledge base; empty = any in the workspace. */
+  knowledgeBaseId: string;
+}
+
+function invalidArgument(message: string): Error {
+  return Object.assign(new Error(message), { code: "INVALID_ARGUMENT" });
+}
+
+export function isTriggerScheduleType(type: string | null | undefined): boolean {
+  return TRIGGER_SCHEDULE_TYPES.includes(type ?? "");
+}
+
+/** Parse and check a task's trigger_json; throws INVALID_ARGUMENT. */
+export function parseTriggerConfig(scheduleType: string, raw: string | null | undefined): TriggerConfig {
+  let input: Record<string, unknown> = {};
+  if (raw && raw.trim()) {
+    try {
+      input = JSON.parse(raw) as Record<string, unknown>;
+    } catch {
+      throw invalidArgument("triggerJson must be a JSON object");
+    }
+    if (!input || typeof input !== "object" || Array.isArray(input)) {
+      throw invalidArgument("triggerJson must be a JSON object");
+    }
+  }
+
+  const rate = input.rateLimitPerMinute ?? DEFAULT_RATE_LIMIT_PER_MINUTE;
+  if (typeof rate !== "number" || !Number.isInteger(rate) || rate < 1 || rate > MAX_RATE_LIMIT_PER_MINUTE) {
+    throw invalidArgument(`rateLimitPerMinute must be an integer from 1 to ${MAX_RATE_LIMIT_PER_MINUTE}`);
+  }
+  const config: TriggerConfig = {
+    rateLimitPerMinute: rate,
+    channelId: typeof input.channelId === "string" ? input.channelId : "",
+    matchers: [],
+    knowledgeBaseId: typeof input.knowledgeBaseId === "string" ? input.knowledgeBaseId : "",
+  };
+
+  if (scheduleType === "channel_message" && input.matchers !== undefined) {
+    if (!Array.isArray(input.matchers)) throw invalidArgument("matchers must be an array");
+    config.matchers = input.matchers.map((m, i) => {
+      const matcher = (m ?? {}) as Record<string, unknown>;
+      const operator = typeof matcher.operator === "string" ? matcher.operator : "";
+      const value = typeof matcher.value === "string" ? matcher.value : "";
+      if (!ROUTING_OPERATORS.includes(operator)) {
+        throw invalidArgument(`matchers[${i}].operator must be one of ${ROUTING_OPERATORS.join(", ")}`);
+      }
+      if (operator === "regex") {
+        try {
+          new RegExp(value);
+        } catch (err) {
+          throw invalidArgument(`matchers[${i}].value is not a valid regex: ${err instanceof Error ? err.message : err}`);
+        }
+      }
+      return { operator, value };
+    });
+  }
+  return config;
+}
+
+export function newTriggerToken(): string {
+  return crypto.randomBytes(24).toString("base64url");
+}
+
+export function triggerTokenMatches(expected: string | null | undefined, provided: string): boolean {
+  if (!expected || !provided) return false;
+  const a = Buffer.from(expected);
+  const b = Buffer.from(provided);
+  return a.length === b.length && crypto.timingSafeEqual(a, b);
+}
+
+export function channelMessageMatches(config: TriggerConfig, content: string): boolean {
+  if (config.matchers.length === 0) return true;
+  return config.matchers.some((m) => evaluateRule(m, content).outcome === "matched");
+}
+
+// ─── Rate limiting ───────────────────────────────────────────────────────────
+// A sliding one-minute window per task, in memory: limits reset when the
+// service restarts.
+
+const recentFires = new Map<string, number[]>();
+
+/** Record a fire for taskId unless it is over its limit; false when over. */
+export function takeTriggerSlot(taskId: string, limitPerMinute: number, now = Date.now()): boolean {
+  const windowStart = now - 60_000;
+  const fires = (recentFires.get(taskId) ?? []).filter((t) => t > windowStart);
+  if (fires.length >= limitPerMinute) {
+    recentFires.set(taskId, fires);
+    return false;
+  }
+  fires.push(now);
+  recentFires.set(taskId, fires);
+  return true;
+}
+
+export function forgetTriggerSlots(taskId: string): void {
+  recentFires.delete(taskId);
+}
+
+/** The instruction a triggered run receives: the task's, then the event. */
+export function triggeredInstruction(instruction: string, source: string, payload: string): string {
+  const body = payload.length > MAX_TRIGGER_PAYLOAD_CHARS
+    ? `${payload.slice(0, MAX_TRIGGER_PAYLOAD_CHARS)}\n[truncated]`
+    : payload;
+  return `${instruction}\n\n---\nTriggered by: ${source}\nPayload:\n${body}`;
+}
@@ -34,6 +34,21 @@ export interface WorkspaceSettingsView {
   ocrConfigJson: string;
   documentProcessingProvider: string;
   documentProcessingConfigJson: string;
+  webSearchProvider: string;
+  webSearchConfigJson: string;
+  kbUploadPolicyJson: string;
+}
+
+export interface KbUploadPolicy {
+  allowedTypes: string[];
+  maxFileBytes: number;
+  storageQuotaBytes: number;
+}
+
+export interface WebSearchSettingsView {
+  workspaceId: string;
+  provider: string;
+  configJson: string;
 }
 
 const DEFAULT_OCR_PROVIDER = "system_ocr";
@@ -42,6 +57,10 @@ const SUPPORTED_OCR_PROVIDERS = new Set(["system_ocr", "tesseract", "paddleocr"]
 const SUPPORTED_DOCUMENT_PROCESSING_PROVIDERS = new Set([
   "mineru", "doc2x", "mistral", "open_mineru", "paddleocr",
 ]);
+// An empty web search provider means "inherit the gateway default".
+const SUPPORTED_WEB_SEARCH_PROVIDERS = new Set([
+  "", "auto", "duckduckgo", "brave", "searxng", "serpapi", "tavily", "bing", "google", "exa",
+]);
 const DEFAULT_DOCUMENT_PROCESSING_CONFIG = {
   mineru:      { apiKey: "", apiHost: "https://mineru.net" },
   doc2x:       { apiKey: "", apiHost: "https://api.doc2x.noedgeai.com" },
@@ -95,6 +114,28 @@ function normalizeDocumentProcessingProvider(raw: string | null | undefined): st
   return SUPPORTED_DOCUMENT_PROCESSING_PROVIDERS.has(normalized) ? normalized : DEFAULT_DOCUMENT_PROCESSING_PROVIDER;
 }
 
+function normalizeWebSearchProvider(raw: string | null | undefined): string {
+  const normalized = (raw ?? "").trim().toLowerCase();
+  return SUPPORTED_WEB_SEARCH_PROVIDERS.has(normalized) ? normalized : "";
+}
+
+// Zero limits and an empty type list defer to the gateway's configuration.
+function normalizeKbUploadPolicy(raw: string | null | undefined): KbUploadPolicy {
+  const parsed = parseJsonObject(raw);
+  const allowedTypes = Array.isArray(parsed.allowedTypes)
+    ? [...new Set(parsed.allowedTypes
+      .filter((t): t is string => typeof t === "string")
+      .map((t) => t.trim().toLowerCase().replace(/^\./, ""))
+      .filter(Boolean))]
+    : [];
+  const limit = (v: unknown) => (typeof v === "number" && Number.isFinite(v) && v > 0 ? Math.floor(v) : 0);
+  return {
+    allowedTypes,
+    maxFileBytes: limit(parsed.maxFileBytes),
+    storageQuotaBytes: limit(parsed.storageQuotaBytes),
+  };
+}
+
 function normalizeConfigJson(raw: string | null | undefined, fallback: Record<string, unknown>): string {
   const parsed = parseJsonObject(raw);
   return JSON.stringify(Object.keys(parsed).length > 0 ? parsed : fallback);
@@ -121,6 +162,9 @@ function ensureWorkspaceSettingsRow(workspaceId: string) {
       ocrConfigJson: "{}",
       documentProcessingProvider: DEFAULT_DOCUMENT_PROCESSING_PROVIDER,
       documentProcessingConfigJson: JSON.stringify(DEFAULT_DOCUMENT_PROCESSING_CONFIG),
+      webSearchProvider: "",
+      webSearchConfigJson: "{}",
+      kbUploadPolicyJson: "{}",
     }).run();
     row = db.select().from(workspaceSettings).where(eq(workspaceSettings.workspaceId, workspaceId)).get();
   }
@@ -148,6 +192,9 @@ function toWorkspaceSettingsView(
     ocrConfigJson: normalizeConfigJson(row.ocrConfigJson, {}),
     documentProcessingProvider: normalizeDocumentProcessingProvider(row.documentProcessingProvider),
     documentProcessingConfigJson: normalizeConfigJson(row.documentProcessingConfigJson, DEFAULT_DOCUMENT_PROCESSING_CONFIG),
+    webSearchProvider: normalizeWebSearchProvider(row.webSearchProvider),
+    webSearchConfigJson: normalizeConfigJson(row.webSearchConfigJson, {}),
+    kbUploadPolicyJson: JSON.stringify(normalizeKbUploadPolicy(row.kbUploadPolicyJson)),
   };
 }
 
@@ -156,6 +203,20 @@ export function getWorkspaceSettings(workspaceId: string): WorkspaceSettingsView
   return toWorkspaceSettingsView(workspace, row);
 }
 
+export function getWebSearchSettings(workspaceId: string): WebSearchSettingsView {
+  const { row } = ensureWorkspaceSettingsRow(workspaceId);
+  return {
+    workspaceId,
+    provider: normalizeWebSearchProvider(row.webSearchProvider),
+    configJson: normalizeConfigJson(row.webSearchConfigJson, {}),
+  };
+}
+
+export function getKbUploadPolicy(workspaceId: string): KbUploadPolicy {
+  const { row } = ensureWorkspaceSettingsRow(workspaceId);
+  return normalizeKbUploadPolicy(row.kbUploadPolicyJson);
+}
+
 export function updateWorkspaceSettings(
   workspaceId: string,
   data: {
@@ -165,12 +226,16 @@ export function updateWorkspaceSettings(
     codeModelIds?: string[]; agentModelIds?: string[]; subAgentModelIds?: string[];
     ocrProvider?: string; ocrConfigJson?: string;
     documentProcessingProvider?: string; documentProcessingConfigJson?: string;
+    webSearchProvider?: string; webSearchConfigJson?: string;
+    kbUploadPolicyJson?: string;
     setName?: boolean; setDescription?: boolean; setDefaultModel?: boolean;
     setDefaultTemperature?: boolean; setMaxTokensPerRequest?: boolean;
     setAssistantModelIds?: boolean; setFallbackModelIds?: boolean;
     setCodeModelIds?: boolean; setAgentModelIds?: boolean; setSubAgentModelIds?: boolean;
     setOcrProvider?: boolean; setOcrConfigJson?: boolean;
     setDocumentProcessingProvider?: boolean; setDocumentProcessingConfigJson?: boolean;
+    setWebSearchProvider?: boolean; setWebSearchConfigJson?: boolean;
+    setKbUploadPolicyJson?: boolean;
   },
 ): WorkspaceSettingsView {
   ensureWorkspaceSettingsRow(workspaceId);
@@ -200,6 +265,9 @@ export function updateWorkspaceSettings(
   if (data.setDocumentProcessingConfigJson) {
     settingsPatch.documentProcessingConfigJson = normalizeConfigJson(data.documentProcessingConfigJson, DEFAULT_DOCUMENT_PROCESSING_CONFIG);
   }
+  if (data.setWebSearchProvider)    settingsPatch.webSearchProvider    = normalizeWebSearchProvider(data.webSearchProvider);
+  if (data.setWebSearchConfigJson)  settingsPatch.webSearchConfigJson  = normalizeConfigJson(data.webSearchConfigJson, {});
+  if (data.setKbUploadPolicyJson)   settingsPatch.kbUploadPolicyJson   = JSON.stringify(normalizeKbUploadPolicy(data.kbUploadPolicyJson));
   if (Object.keys(settingsPatch).length > 0) {
     db.update(workspaceSettings).set({ ...settingsPatch, updatedAt: now }).where(eq(workspaceSettings.workspaceId, workspaceId)).run();
   }
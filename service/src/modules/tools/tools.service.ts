@@ -1,4 +1,4 @@
-import { and, desc, eq } from "drizzle-orm";
+import { and, desc, eq, sql } from "drizzle-orm";
 import { promises as fs } from "fs";
 import { v4 as uuidv4 } from "uuid";
 import { db } from "../../db/index.js";
@@ -14,6 +14,8 @@ import {
 } from "../../db/schema.js";
 
 import { config } from "../../config.js";
+import { getKbUploadPolicy } from "../settings/settings.service.js";
+import { gatewayBlobUrl, readGatewayBlob } from "../../utils/gateway-blobs.js";
 
 const DEFAULT_KB_CHUNK_SIZE = 1200;
 const DEFAULT_KB_CHUNK_OVERLAP = 200;
@@ -518,8 +520,47 @@ function parseEmbeddingJson(raw: string): number[] | null {
   }
 }
 
+type DocumentSource = { filePath: string | null; storageUri: string | null };
+
+function hasDocumentSource(doc: DocumentSource): boolean {
+  return Boolean(doc.storageUri || doc.filePath);
+}
+
+// Documents created before the blob store only have a host filePath.
+async function readDocumentSource(doc: DocumentSource): Promise<string> {
+  if (!doc.storageUri) {
+    return fs.readFile(doc.filePath!, "utf-8");
+  }
+  return (await readGatewayBlob(doc.storageUri)).toString("utf-8");
+}
+
+function deleteDocumentSource(doc: DocumentSource) {
+  if (doc.storageUri) {
+    const storageUri = doc.storageUri;
+    void fetch(gatewayBlobUrl(storageUri), {
+      method: "DELETE",
+      headers: { "X-Runtime-Secret": config.runtimeSecret },
+      signal: AbortSignal.timeout(30_000),
+    })
+      .then(async (response) => {
+        if (!response.ok) {
+          const detail = await response.text().catch(() => "");
+          console.warn(`[kb-blob] Delete failed (${response.status}): ${detail.slice(0, 200)}`, { storageUri });
+        }
+      })
+      .catch((err) => {
+        const message = err instanceof Error ? err.message : String(err);
+        console.warn(`[kb-blob] Delete request failed: ${message}`, { storageUri });
+      });
+    return;
+  }
+  if (doc.filePath) {
+    void fs.unlink(doc.filePath).catch(() => undefined);
+  }
+}
+
 async function readDocumentTextContent(doc: typeof kbDocuments.$inferSelect): Promise<string> {
-  if (!doc.filePath) {
+  if (!hasDocumentSource(doc)) {
     throw Object.assign(new Error("Uploaded file path is missing"), { code: "INVALID_ARGUMENT" });
   }
 
@@ -530,13 +571,31 @@ async function readDocumentTextContent(doc: typeof kbDocuments.$inferSelect): Pr
     });
   }
 
-  const content = await fs.readFile(doc.filePath, "utf-8");
+  const content = await readDocumentSource(doc);
   if (!content.trim()) {
     throw Object.assign(new Error("Document content is empty"), { code: "INVALID_ARGUMENT" });
   }
   return content;
 }
 
+export type IndexedKnowledgeDocument = {
+  workspaceId: string;
+  knowledgeBaseId: string;
+  knowledgeBaseName: string;
+  documentId: string;
+  documentName: string;
+  type: string;
+  size: number;
+  chunkCount: number;
+};
+
+const documentIndexedListeners: Array<(doc: IndexedKnowledgeDocument) => void> = [];
+
+/** Observe knowledge base documents as they finish indexing. */
+export function onKnowledgeDocumentIndexed(listener: (doc: IndexedKnowledgeDocument) => void): void {
+  documentIndexedListeners.push(listener);
+}
+
 function queueKnowledgeBaseDocumentProcessing(documentId: string) {
   if (runningKbDocumentProcessors.has(documentId)) return;
   runningKbDocumentProcessors.add(documentId);
@@ -639,6 +698,24 @@ async function processKnowledgeBaseDocument(documentId: string): Promise<void> {
       documentName: documentRow.name,
       chunks: syncChunks,
     });
+
+    const indexed: IndexedKnowledgeDocument = {
+      workspaceId: kb.workspaceId,
+      knowledgeBaseId: documentRow.knowledgeBaseId,
+      knowledgeBaseName: kb.name,
+      documentId,
+      documentName: documentRow.name,
+      type: documentRow.type ?? "",
+      size: documentRow.size ?? 0,
+      chunkCount: chunks.length,
+    };
+    for (const listener of documentIndexedListeners) {
+      try {
+        listener(indexed);
+      } catch (err) {
+        console.error(`[kb] document indexed listener failed: ${err instanceof Error ? err.message : err}`);
+      }
+    }
   } catch (error: unknown) {
     const message = error instanceof Error ? error.message : String(error);
     db.delete(kbDocumentChunks)
@@ -785,7 +862,7 @@ export function updateKnowledgeBase(data: {
 
 function requeueKnowledgeBaseDocumentsForReindex(knowledgeBaseId: string) {
   const docs = db
-    .select({ id: kbDocuments.id, filePath: kbDocuments.filePath })
+    .select({ id: kbDocuments.id, filePath: kbDocuments.filePath, storageUri: kbDocuments.storageUri })
     .from(kbDocuments)
     .where(eq(kbDocuments.knowledgeBaseId, knowledgeBaseId))
     .all();
@@ -797,7 +874,7 @@ function requeueKnowledgeBaseDocumentsForReindex(knowledgeBaseId: string) {
 
   const now = new Date().toISOString();
   for (const doc of docs) {
-    if (!doc.filePath) {
+    if (!hasDocumentSource(doc)) {
       db.update(kbDocuments)
         .set({
           status: "failed",
@@ -825,15 +902,14 @@ function requeueKnowledgeBaseDocumentsForReindex(knowledgeBaseId: string) {
 export function deleteKnowledgeBase(id: string) {
   const kb = ensureKnowledgeBaseExists(id);
   const docs = db
-    .select({ id: kbDocuments.id, filePath: kbDocuments.filePath })
+    .select({ id: kbDocuments.id, filePath: kbDocuments.filePath, storageUri: kbDocuments.storageUri })
     .from(kbDocuments)
     .where(eq(kbDocuments.knowledgeBaseId, id))
     .all();
   const documentIds = docs.map((d) => d.id);
   db.delete(knowledgeBases).where(eq(knowledgeBases.id, id)).run();
   for (const doc of docs) {
-    if (!doc.filePath) continue;
-    void fs.unlink(doc.filePath).catch(() => undefined);
+    deleteDocumentSource(doc);
   }
 
   // Notify runtime to remove all KB entries from memory system
@@ -856,7 +932,7 @@ export function listKnowledgeBaseDocuments(knowledgeBaseId: string) {
     .all();
 
   return docs.map((doc) => {
-    if ((doc.status === "pending" || doc.status === "processing") && !doc.filePath) {
+    if ((doc.status === "pending" || doc.status === "processing") && !hasDocumentSource(doc)) {
       const failedAt = new Date().toISOString();
       db.update(kbDocuments)
         .set({
@@ -887,6 +963,7 @@ export function createKnowledgeBaseDocument(data: {
   type?: string;
   size?: number | string;
   filePath?: string;
+  storageUri?: string;
 }) {
   ensureKnowledgeBaseExists(data.knowledgeBaseId);
   const name = data.name.trim();
@@ -899,7 +976,9 @@ export function createKnowledgeBaseDocument(data: {
   const docType = inferDocumentType(name, data.type);
   const rawSize = typeof data.size === "string" ? Number(data.size) : data.size;
   const size = Number.isFinite(rawSize) ? Math.max(0, Math.floor(rawSize!)) : 0;
-  const filePath = data.filePath?.trim() || null;
+  const storageUri = data.storageUri?.trim() || null;
+  const filePath = storageUri ? null : data.filePath?.trim() || null;
+  const hasSource = Boolean(storageUri || filePath);
 
   db.insert(kbDocuments)
     .values({
@@ -908,11 +987,12 @@ export function createKnowledgeBaseDocument(data: {
       name,
       type: docType,
       size,
-      status: filePath ? "pending" : "failed",
+      status: hasSource ? "pending" : "failed",
       filePath,
+      storageUri,
       chunkCount: 0,
-      processedAt: filePath ? null : now,
-      errorMessage: filePath ? null : "Uploaded file path missing",
+      processedAt: hasSource ? null : now,
+      errorMessage: hasSource ? null : "Uploaded file path missing",
       createdAt: now,
     })
     .run();
@@ -923,13 +1003,30 @@ export function createKnowledgeBaseDocument(data: {
     .where(eq(knowledgeBases.id, data.knowledgeBaseId))
     .run();
 
-  if (filePath) {
+  if (hasSource) {
     queueKnowledgeBaseDocumentProcessing(id);
   }
 
   return db.select().from(kbDocuments).where(eq(kbDocuments.id, id)).get()!;
 }
 
+export function getKnowledgeBaseUploadPolicy(knowledgeBaseId: string) {
+  const kb = ensureKnowledgeBaseExists(knowledgeBaseId);
+  const policy = getKbUploadPolicy(kb.workspaceId);
+  const usage = db
+    .select({ usedBytes: sql<number>`coalesce(sum(${kbDocuments.size}), 0)` })
+    .from(kbDocuments)
+    .innerJoin(knowledgeBases, eq(kbDocuments.knowledgeBaseId, knowledgeBases.id))
+    .where(eq(knowledgeBases.workspaceId, kb.workspaceId))
+    .get();
+  return {
+    knowledgeBaseId,
+    workspaceId: kb.workspaceId,
+    ...policy,
+    usedBytes: Number(usage?.usedBytes ?? 0),
+  };
+}
+
 export function deleteKnowledgeBaseDocument(data: {
   knowledgeBaseId: string;
   documentId: string;
@@ -953,9 +1050,7 @@ export function deleteKnowledgeBaseDocument(data: {
     .run();
   db.delete(kbDocuments).where(eq(kbDocuments.id, data.documentId)).run();
 
-  if (doc.filePath) {
-    void fs.unlink(doc.filePath).catch(() => undefined);
-  }
+  deleteDocumentSource(doc);
 
   const now = new Date().toISOString();
   const nextCount = countKnowledgeBaseDocuments(data.knowledgeBaseId);
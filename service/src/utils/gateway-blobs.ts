@@ -0,0 +1,21 @@
+import { config } from "../config.js";
+
+// Uploaded files live in the gateway's blob store (local disk or S3); the
+// service reaches them through the gateway's internal blob endpoint by
+// storage URI, authenticated with the runtime secret.
+export function gatewayBlobUrl(storageUri: string): string {
+  const gatewayBase = (config.gatewayAddr ?? "").trim().replace(/\/+$/, "");
+  return `${gatewayBase}/internal/blobs?uri=${encodeURIComponent(storageUri)}`;
+}
+
+export async function readGatewayBlob(storageUri: string): Promise<Buffer> {
+  const response = await fetch(gatewayBlobUrl(storageUri), {
+    headers: { "X-Runtime-Secret": config.runtimeSecret },
+    signal: AbortSignal.timeout(60_000),
+  });
+  if (!response.ok) {
+    const detail = await response.text().catch(() => "");
+    throw new Error(`Failed to read uploaded file (${response.status}): ${detail.slice(0, 200)}`);
+  }
+  return Buffer.from(await response.arrayBuffer());
+}
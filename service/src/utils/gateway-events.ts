@@ -0,0 +1,26 @@
+import { config } from "../config.js";
+
+// Workspace events (agent replies, task executions, channel messages) are
+// delivered to outgoing webhook channels by the gateway. Reporting one is
+// fire and forget: a failure is logged and the event is not delivered.
+export async function publishGatewayEvent(event: {
+  workspaceId: string;
+  type: string;
+  data: unknown;
+}): Promise<void> {
+  const gatewayBase = (config.gatewayAddr ?? "").trim().replace(/\/+$/, "");
+  try {
+    const response = await fetch(`${gatewayBase}/internal/events`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json", "X-Runtime-Secret": config.runtimeSecret },
+      body: JSON.stringify({ ...event, occurredAt: new Date().toISOString() }),
+      signal: AbortSignal.timeout(10_000),
+    });
+    if (!response.ok) {
+      const detail = await response.text().catch(() => "");
+      console.error(`[events] gateway rejected ${event.type} event (${response.status}): ${detail.slice(0, 200)}`);
+    }
+  } catch (err) {
+    console.error(`[events] failed to publish ${event.type} event: ${err instanceof Error ? err.message : err}`);
+  }
+}